@@ -0,0 +1,38 @@
+package ssz
+
+import (
+	"reflect"
+
+	"github.com/524119574/go-ssz/types"
+	"github.com/pkg/errors"
+)
+
+// RegisterAlias maps an external type we cannot modify or tag onto a supported
+// prototype type. Wherever a value of the external type appears in a structure,
+// convertTo is called to turn it into a value of the prototype's type before it is
+// marshaled, and convertFrom is called to turn a decoded prototype value back into
+// the external type when unmarshaling. For example, a vendor hash type with
+// unexported fields can be serialized as a [32]byte:
+//
+//  err := RegisterAlias(vendor.Hash{}, func(v interface{}) (interface{}, error) {
+//      return v.(vendor.Hash).Bytes32(), nil
+//  }, func(v interface{}) (interface{}, error) {
+//      return vendor.HashFromBytes32(v.([32]byte)), nil
+//  }, [32]byte{})
+//
+// Registering more than one alias for the same external type returns an error.
+func RegisterAlias(
+	external interface{},
+	convertTo func(v interface{}) (interface{}, error),
+	convertFrom func(v interface{}) (interface{}, error),
+	prototype interface{},
+) error {
+	if external == nil || prototype == nil {
+		return errors.New("untyped-value nil cannot be registered as an alias")
+	}
+	if err := types.RegisterAlias(reflect.TypeOf(external), reflect.TypeOf(prototype), convertTo, convertFrom); err != nil {
+		return err
+	}
+	resetCodecs()
+	return nil
+}
@@ -0,0 +1,170 @@
+package ssz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/524119574/go-ssz/types"
+)
+
+// opaqueRoot mimics a third-party type which cannot be tagged and whose
+// contents are not reachable through reflection.
+type opaqueRoot struct {
+	data [32]byte
+}
+
+// opaqueBalance mimics an arbitrary precision type which is serialized as a
+// variable-size list of little-endian limbs.
+type opaqueBalance struct {
+	limbs []uint64
+}
+
+// opaqueUint32 and opaqueUint64 are aliased to basic types of different widths,
+// so vectors of them are packed.
+type opaqueUint32 struct {
+	v uint32
+}
+
+type opaqueUint64 struct {
+	v uint64
+}
+
+type aliasContainer struct {
+	Slot     uint64
+	Root     opaqueRoot
+	Roots    []opaqueRoot
+	Fixed    [2]opaqueRoot
+	Pointer  *opaqueRoot
+	Balance  opaqueBalance
+	Balances []opaqueBalance
+}
+
+func init() {
+	if err := RegisterAlias(opaqueRoot{}, func(v interface{}) (interface{}, error) {
+		return v.(opaqueRoot).data, nil
+	}, func(v interface{}) (interface{}, error) {
+		return opaqueRoot{data: v.([32]byte)}, nil
+	}, [32]byte{}); err != nil {
+		panic(err)
+	}
+	if err := RegisterAlias(opaqueBalance{}, func(v interface{}) (interface{}, error) {
+		return v.(opaqueBalance).limbs, nil
+	}, func(v interface{}) (interface{}, error) {
+		return opaqueBalance{limbs: v.([]uint64)}, nil
+	}, []uint64{}); err != nil {
+		panic(err)
+	}
+	if err := RegisterAlias(opaqueUint32{}, func(v interface{}) (interface{}, error) {
+		return v.(opaqueUint32).v, nil
+	}, func(v interface{}) (interface{}, error) {
+		return opaqueUint32{v: v.(uint32)}, nil
+	}, uint32(0)); err != nil {
+		panic(err)
+	}
+	if err := RegisterAlias(opaqueUint64{}, func(v interface{}) (interface{}, error) {
+		return v.(opaqueUint64).v, nil
+	}, func(v interface{}) (interface{}, error) {
+		return opaqueUint64{v: v.(uint64)}, nil
+	}, uint64(0)); err != nil {
+		panic(err)
+	}
+}
+
+func TestRegisterAlias_Conflict(t *testing.T) {
+	err := RegisterAlias(opaqueRoot{}, func(v interface{}) (interface{}, error) {
+		return nil, errors.New("unused")
+	}, func(v interface{}) (interface{}, error) {
+		return nil, errors.New("unused")
+	}, [32]byte{})
+	if err == nil {
+		t.Error("Expected registering an alias twice to fail")
+	}
+}
+
+func TestRegisterAlias_TopLevel(t *testing.T) {
+	root := opaqueRoot{data: [32]byte{1, 2, 3}}
+	enc, err := Marshal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, root.data[:]) {
+		t.Errorf("Expected %v, received %v", root.data[:], enc)
+	}
+	var dec opaqueRoot
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if dec != root {
+		t.Errorf("Expected %v, received %v", root, dec)
+	}
+}
+
+func TestRegisterAlias_NestedRoundTrip(t *testing.T) {
+	item := aliasContainer{
+		Slot:     5,
+		Root:     opaqueRoot{data: [32]byte{1}},
+		Roots:    []opaqueRoot{{data: [32]byte{2}}, {data: [32]byte{3}}},
+		Fixed:    [2]opaqueRoot{{data: [32]byte{4}}, {data: [32]byte{5}}},
+		Pointer:  &opaqueRoot{data: [32]byte{6}},
+		Balance:  opaqueBalance{limbs: []uint64{7, 8}},
+		Balances: []opaqueBalance{{limbs: []uint64{9}}, {limbs: []uint64{10, 11}}},
+	}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Slot + Root + Roots offset + Fixed + Pointer + Balance offset + Balances offset.
+	fixedSize := 8 + 32 + 4 + 64 + 32 + 4 + 4
+	if offset := binary.LittleEndian.Uint32(enc[40:44]); offset != uint32(fixedSize) {
+		t.Errorf("Expected first offset %d, received %d", fixedSize, offset)
+	}
+	if !bytes.Equal(enc[44:45], []byte{4}) || !bytes.Equal(enc[76:77], []byte{5}) {
+		t.Errorf("Unexpected encoding of the fixed alias vector: %v", enc[44:108])
+	}
+	var dec aliasContainer
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(item, dec) {
+		t.Errorf("Expected %v, received %v", item, dec)
+	}
+}
+
+func TestRegisterAlias_PackedVectorsOfDifferentWidthsCached(t *testing.T) {
+	narrow := [4]opaqueUint32{{1}, {2}, {3}, {4}}
+	wide := [4]opaqueUint64{{1}, {2}, {3}, {4}}
+	wantNarrow, err := HashTreeRoot([4]uint32{1, 2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantWide, err := HashTreeRoot([4]uint64{1, 2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	types.ToggleCache(true)
+	defer types.ToggleCache(false)
+	// Entries are admitted asynchronously, so the narrow vector is hashed until
+	// its root is returned from the cache.
+	before := CacheStats().BasicArrays.Hits
+	for i := 0; i < 100 && CacheStats().BasicArrays.Hits == before; i++ {
+		root, err := HashTreeRoot(narrow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root != wantNarrow {
+			t.Fatalf("Expected the root %#x of the narrow vector, received %#x", wantNarrow, root)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	root, err := HashTreeRoot(wide)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != wantWide {
+		t.Errorf("Expected the root %#x of the wide vector, received %#x", wantWide, root)
+	}
+}
@@ -0,0 +1,122 @@
+package ssz
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+// The fixtures below cover every basic type width as well as their vectors
+// and lists. Their golden encodings and roots are spelled out byte by byte,
+// so they hold regardless of the byte order of the machine running the tests.
+
+type archBasics struct {
+	Bool   bool
+	Uint8  uint8
+	Uint16 uint16
+	Int32  int32
+	Uint32 uint32
+	Uint64 uint64
+}
+
+type archVectors struct {
+	Bools   [3]bool
+	Uint16s [3]uint16
+	Uint32s [2]uint32
+	Uint64s [2]uint64
+	Root    [32]byte
+}
+
+type archLists struct {
+	Uint16s []uint16 `ssz-max:"16"`
+	Uint32s []uint32 `ssz-max:"16"`
+	Uint64s []uint64 `ssz-max:"16"`
+	Roots   [][]byte `ssz-size:"?,32" ssz-max:"4"`
+}
+
+var archFixtures = []struct {
+	name   string
+	value  interface{}
+	golden string
+	root   string
+}{
+	{
+		name: "basic types",
+		value: &archBasics{
+			Bool:   true,
+			Uint8:  0x01,
+			Uint16: 0x0201,
+			Int32:  -2,
+			Uint32: 0x04030201,
+			Uint64: 0x0807060504030201,
+		},
+		golden: "01" + "01" + "0102" + "feffffff" + "01020304" + "0102030405060708",
+		root:   "3f0acc2255826132b4891197f4c67f1d777c671ca5dc8542c36f01326bd28cad",
+	},
+	{
+		name: "vectors of basic types",
+		value: &archVectors{
+			Bools:   [3]bool{true, false, true},
+			Uint16s: [3]uint16{0x0102, 0x0304, 0x0506},
+			Uint32s: [2]uint32{0x01020304, 0x05060708},
+			Uint64s: [2]uint64{0x0102030405060708, 0x1112131415161718},
+			Root:    [32]byte{0xaa, 31: 0xbb},
+		},
+		golden: "010001" + "020104030605" + "0403020108070605" + "0807060504030201" + "1817161514131211" +
+			"aa000000000000000000000000000000000000000000000000000000000000bb",
+		root:   "8bf5a35097ec70da159fb5facc0b7de3cadd57f0ed185a555b653ded8da77983",
+	},
+	{
+		name: "lists of basic types",
+		value: &archLists{
+			Uint16s: []uint16{0x0102},
+			Uint32s: []uint32{0x01020304, 0x05060708},
+			Uint64s: []uint64{0x0102030405060708},
+			Roots:   [][]byte{append([]byte{0xcc}, make([]byte, 31)...)},
+		},
+		golden: "10000000" + "12000000" + "1a000000" + "22000000" +
+			"0201" + "0403020108070605" + "0807060504030201" +
+			"cc00000000000000000000000000000000000000000000000000000000000000",
+		root:   "1646ab5906446c632a97bef5b85b4c02ecb74d2c45bfe8561f459941ceeb1e39",
+	},
+}
+
+func TestArchitectureIndependence_Marshal(t *testing.T) {
+	for _, tt := range archFixtures {
+		t.Run(tt.name, func(t *testing.T) {
+			golden, err := hex.DecodeString(tt.golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			enc, err := Marshal(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(enc, golden) {
+				t.Errorf("Expected encoding %s, received %#x", tt.golden, enc)
+			}
+			decoded := reflect.New(reflect.TypeOf(tt.value).Elem()).Interface()
+			if err := Unmarshal(golden, decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, tt.value) {
+				t.Errorf("Expected %+v, received %+v", tt.value, decoded)
+			}
+		})
+	}
+}
+
+func TestArchitectureIndependence_HashTreeRoot(t *testing.T) {
+	for _, tt := range archFixtures {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := HashTreeRoot(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hex.EncodeToString(root[:]) != tt.root {
+				t.Errorf("Expected root %s, received %#x", tt.root, root)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package ssz
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/524119574/go-ssz/types"
+)
+
+// TestingT is the part of testing.TB used by AssertRootMatches.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// AssertRootMatches reports a test failure unless the hash tree root of val is
+// the one given in hex, with or without a 0x prefix, and returns whether it is.
+// When val is a container, the failure lists its chunk count, tree depth and
+// the roots of its fields, to tell which field hashes differently or whether a
+// field added by a fork changed the depth of the tree.
+//
+//  func TestBlockRoot(t *testing.T) {
+//      ssz.AssertRootMatches(t, block, "0x9d1c...")
+//  }
+func AssertRootMatches(t TestingT, val interface{}, expectedHex string) bool {
+	t.Helper()
+	want, err := hex.DecodeString(strings.TrimPrefix(expectedHex, "0x"))
+	if err != nil || len(want) != 32 {
+		t.Errorf("Expected root %q is not 32 bytes of hex", expectedHex)
+		return false
+	}
+	root, err := HashTreeRoot(val)
+	if err != nil {
+		t.Errorf("Could not compute the root of %T: %v", val, err)
+		return false
+	}
+	if bytes.Equal(root[:], want) {
+		return true
+	}
+	msg := fmt.Sprintf("Expected %T to have root %#x, received %#x", val, want, root)
+	if container, err := types.InspectContainerRoot(reflect.ValueOf(val)); err == nil {
+		msg += "\n" + container.String()
+	}
+	t.Errorf("%s", msg)
+	return false
+}
@@ -0,0 +1,48 @@
+package ssz
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// recordingT records the failures reported to it.
+type recordingT struct {
+	failures []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertRootMatches(t *testing.T) {
+	val := &fingerprintCheckpoint{Epoch: 3, Root: [32]byte{1}}
+	root, err := HashTreeRoot(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &recordingT{}
+	if !AssertRootMatches(rec, val, fmt.Sprintf("%#x", root)) || !AssertRootMatches(rec, val, fmt.Sprintf("%x", root)) {
+		t.Errorf("Expected the root to match, received %v", rec.failures)
+	}
+
+	if AssertRootMatches(rec, val, strings.Repeat("00", 32)) {
+		t.Fatal("Expected a mismatched root to be reported")
+	}
+	if len(rec.failures) != 1 {
+		t.Fatalf("Expected a single failure, received %v", rec.failures)
+	}
+	msg := rec.failures[0]
+	for _, want := range []string{"2 chunks, depth 1", "Epoch: 0x0300", "Root: 0x0100"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected the failure to contain %q, received %q", want, msg)
+		}
+	}
+
+	rec = &recordingT{}
+	if AssertRootMatches(rec, val, "0x1234") || len(rec.failures) != 1 {
+		t.Errorf("Expected a short expected root to be reported, received %v", rec.failures)
+	}
+}
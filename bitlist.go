@@ -0,0 +1,24 @@
+package ssz
+
+import (
+	"github.com/524119574/go-ssz/types"
+)
+
+// Bitlist is an SSZ list of bits, such as the aggregation bits of attestations,
+// whose bytes are followed by a delimiting bit marking its length. Its limit is
+// declared in bits by the ssz-max tag of the field holding it:
+//
+//  type Attestation struct {
+//      AggregationBits ssz.Bitlist `ssz-max:"2048"`
+//      Data            *AttestationData
+//  }
+//
+// Unmarshaling rejects bitlists without a delimiting bit or with more bits than
+// their limit, and their root mixes in the number of bits rather than of bytes.
+// The Bitlist type of github.com/prysmaticlabs/go-bitfield is handled alike.
+type Bitlist = types.Bitlist
+
+// NewBitlist returns a Bitlist of n bits, all of which are unset.
+func NewBitlist(n uint64) Bitlist {
+	return types.NewBitlist(n)
+}
@@ -0,0 +1,178 @@
+package ssz
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type bitlistAttestation struct {
+	AggregationBits Bitlist `ssz-max:"16"`
+	Slot            uint64
+	CustodyBits     *Bitlist `ssz-max:"8"`
+}
+
+func TestBitlist_RoundTrip(t *testing.T) {
+	bits := NewBitlist(10)
+	bits.SetBitAt(0, true)
+	bits.SetBitAt(9, true)
+	custody := NewBitlist(3)
+	custody.SetBitAt(1, true)
+	att := &bitlistAttestation{AggregationBits: bits, Slot: 5, CustodyBits: &custody}
+	enc, err := Marshal(att)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		16, 0, 0, 0, // Offset of AggregationBits.
+		5, 0, 0, 0, 0, 0, 0, 0, // Slot.
+		18, 0, 0, 0, // Offset of CustodyBits.
+		0x01, 0x06, // AggregationBits, delimited at bit 10.
+		0x0a, // CustodyBits, delimited at bit 3.
+	}
+	if !bytes.Equal(enc, want) {
+		t.Fatalf("Marshaled %#x, expected %#x", enc, want)
+	}
+	var decoded bitlistAttestation
+	if err := Unmarshal(enc, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded.AggregationBits, bits) || !bytes.Equal(*decoded.CustodyBits, custody) || decoded.Slot != 5 {
+		t.Errorf("Unmarshaled %+v, expected %+v", decoded, att)
+	}
+	if n := decoded.AggregationBits.Len(); n != 10 {
+		t.Errorf("Decoded bitlist has %d bits, expected 10", n)
+	}
+	var streamed bytes.Buffer
+	if err := NewEncoder(&streamed).Encode(att); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(streamed.Bytes(), want) {
+		t.Errorf("Encoded %#x, expected %#x", streamed.Bytes(), want)
+	}
+	var fromStream bitlistAttestation
+	if err := NewDecoder(bytes.NewReader(want)).Decode(&fromStream); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fromStream.AggregationBits, bits) {
+		t.Errorf("Decoded %#x, expected %#x", fromStream.AggregationBits, bits)
+	}
+}
+
+func TestBitlist_EmptyMarshalsDelimiterOnly(t *testing.T) {
+	att := &bitlistAttestation{}
+	enc, err := Marshal(att)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := SizeSSZ(att)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(len(enc)) != size {
+		t.Errorf("Marshaled %d bytes, SizeSSZ returned %d", len(enc), size)
+	}
+	var decoded bitlistAttestation
+	if err := Unmarshal(enc, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.AggregationBits.Len() != 0 || decoded.CustodyBits.Len() != 0 {
+		t.Errorf("Expected empty bitlists, received %+v", decoded)
+	}
+	emptyRoot, err := HashTreeRoot(att)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zeroRoot, err := HashTreeRoot(&bitlistAttestation{AggregationBits: NewBitlist(0), CustodyBits: &Bitlist{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if emptyRoot != zeroRoot {
+		t.Errorf("Root of nil bitlists %#x differs from that of empty ones %#x", emptyRoot, zeroRoot)
+	}
+}
+
+func TestBitlist_OverLimit(t *testing.T) {
+	att := &bitlistAttestation{AggregationBits: NewBitlist(17)}
+	if _, err := Marshal(att); err == nil || !strings.Contains(err.Error(), "exceeding its ssz-max of 16") {
+		t.Errorf("Expected marshaling 17 bits to fail, received %v", err)
+	}
+	if _, err := HashTreeRoot(att); err == nil || !strings.Contains(err.Error(), "exceeds its ssz-max of 16") {
+		t.Errorf("Expected hashing 17 bits to fail, received %v", err)
+	}
+	enc := []byte{
+		16, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0,
+		19, 0, 0, 0,
+		0x00, 0x00, 0x02, // 17 bits.
+		0x01,
+	}
+	var decoded bitlistAttestation
+	err := Unmarshal(enc, &decoded)
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Path() != "AggregationBits" {
+		t.Fatalf("Expected an error in field AggregationBits, received %v", err)
+	}
+	if err := NewDecoder(bytes.NewReader(enc)).Decode(&decoded); err == nil {
+		t.Error("Expected decoding 17 bits from a stream to fail")
+	}
+}
+
+func TestBitlist_MissingDelimiter(t *testing.T) {
+	att := &bitlistAttestation{AggregationBits: Bitlist{0x01, 0x00}}
+	if _, err := Marshal(att); err == nil || !strings.Contains(err.Error(), "no delimiting bit") {
+		t.Errorf("Expected a bitlist without delimiting bit to be rejected, received %v", err)
+	}
+	var decoded Bitlist
+	if err := Unmarshal([]byte{}, &decoded); !errors.Is(err, ErrInputTooShort) {
+		t.Errorf("Expected an empty bitlist to be too short, received %v", err)
+	}
+	if err := Unmarshal([]byte{0xff, 0x00}, &decoded); err == nil {
+		t.Error("Expected a bitlist ending with a zero byte to be rejected")
+	}
+}
+
+func TestBitlist_RootRequiresLimit(t *testing.T) {
+	if _, err := HashTreeRoot(NewBitlist(3)); err == nil || !strings.Contains(err.Error(), "without an ssz-max limit") {
+		t.Errorf("Expected hashing a bitlist without limit to fail, received %v", err)
+	}
+	// The root is that of the bits without the delimiting bit, 0b101, mixed in
+	// with their number.
+	bits := NewBitlist(3)
+	bits.SetBitAt(0, true)
+	bits.SetBitAt(2, true)
+	root, err := HashTreeRootWithCapacity(bits, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withDelimiter, err := HashTreeRootWithCapacity(Bitlist{0x0d}, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != withDelimiter {
+		t.Errorf("Root %#x differs from the root of the same bits %#x", root, withDelimiter)
+	}
+	other, err := HashTreeRootWithCapacity(Bitlist{0x15}, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root == other {
+		t.Error("Expected bitlists of different lengths to have different roots")
+	}
+}
+
+func TestBitlist_SizeTags(t *testing.T) {
+	type unbounded struct {
+		Bits Bitlist `ssz-size:"?" ssz-max:"8"`
+	}
+	if _, err := Marshal(&unbounded{Bits: NewBitlist(8)}); err != nil {
+		t.Errorf("Expected an unbounded ssz-size tag to be accepted, received %v", err)
+	}
+	type sized struct {
+		Bits Bitlist `ssz-size:"8"`
+	}
+	if _, err := Marshal(&sized{Bits: NewBitlist(8)}); err == nil || !strings.Contains(err.Error(), "ssz-max") {
+		t.Errorf("Expected a fixed ssz-size tag to be rejected, received %v", err)
+	}
+}
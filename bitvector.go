@@ -0,0 +1,25 @@
+package ssz
+
+import (
+	"github.com/524119574/go-ssz/types"
+)
+
+// Bitvector is an SSZ vector of bits, such as the justification bits of beacon
+// states. Its length is declared in bits, rather than in bytes, by the ssz-size
+// tag of the field holding it:
+//
+//  type BeaconState struct {
+//      JustificationBits ssz.Bitvector `ssz-size:"4"`
+//  }
+//
+// A Bitvector of 4 bits is serialized as a single byte, whose bits beyond the
+// first 4 must be unset, and hashed as a single chunk. Bitvectors are only
+// serialized as struct fields, as their length is held by the tag, while
+// HashTreeRoot hashes them on their own too, their root only depending on the
+// chunks their bytes fill.
+type Bitvector = types.Bitvector
+
+// NewBitvector returns a Bitvector of n bits, all of which are unset.
+func NewBitvector(n uint64) Bitvector {
+	return types.NewBitvector(n)
+}
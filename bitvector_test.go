@@ -0,0 +1,120 @@
+package ssz
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type bitvectorState struct {
+	Slot              uint64
+	JustificationBits Bitvector  `ssz-size:"4"`
+	Participation     *Bitvector `ssz-size:"12"`
+}
+
+func TestBitvector_RoundTrip(t *testing.T) {
+	justification := NewBitvector(4)
+	justification.SetBitAt(0, true)
+	justification.SetBitAt(3, true)
+	participation := NewBitvector(12)
+	participation.SetBitAt(11, true)
+	state := &bitvectorState{Slot: 3, JustificationBits: justification, Participation: &participation}
+	enc, err := Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{3, 0, 0, 0, 0, 0, 0, 0, 0x09, 0x00, 0x08}
+	if !bytes.Equal(enc, want) {
+		t.Fatalf("Marshaled %#x, expected %#x", enc, want)
+	}
+	var decoded bitvectorState
+	if err := Unmarshal(enc, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded.JustificationBits, justification) || !bytes.Equal(*decoded.Participation, participation) {
+		t.Errorf("Unmarshaled %+v, expected %+v", decoded, state)
+	}
+	var streamed bytes.Buffer
+	if err := NewEncoder(&streamed).Encode(state); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(streamed.Bytes(), want) {
+		t.Errorf("Encoded %#x, expected %#x", streamed.Bytes(), want)
+	}
+	var fromStream bitvectorState
+	if err := NewDecoder(bytes.NewReader(want)).Decode(&fromStream); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fromStream.JustificationBits, justification) || !bytes.Equal(*fromStream.Participation, participation) {
+		t.Errorf("Decoded %+v, expected %+v", fromStream, state)
+	}
+	size, err := SizeSSZ(&bitvectorState{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != uint64(len(want)) {
+		t.Errorf("SizeSSZ returned %d, expected %d", size, len(want))
+	}
+}
+
+func TestBitvector_EmptyMarshalsUnsetBits(t *testing.T) {
+	enc, err := Marshal(&bitvectorState{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make([]byte, 11)
+	if !bytes.Equal(enc, want) {
+		t.Errorf("Marshaled %#x, expected %#x", enc, want)
+	}
+	emptyRoot, err := HashTreeRoot(&bitvectorState{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	participation := NewBitvector(12)
+	zeroRoot, err := HashTreeRoot(&bitvectorState{JustificationBits: NewBitvector(4), Participation: &participation})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if emptyRoot != zeroRoot {
+		t.Errorf("Root of empty bitvectors %#x differs from that of unset ones %#x", emptyRoot, zeroRoot)
+	}
+}
+
+func TestBitvector_BitsBeyondLength(t *testing.T) {
+	state := &bitvectorState{JustificationBits: Bitvector{0x10}}
+	if _, err := Marshal(state); err == nil || !strings.Contains(err.Error(), "bits set beyond its length") {
+		t.Errorf("Expected a set bit beyond the length to be rejected, received %v", err)
+	}
+	if _, err := HashTreeRoot(state); err == nil {
+		t.Error("Expected hashing a set bit beyond the length to fail")
+	}
+	enc := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0x00, 0x00, 0x10}
+	var decoded bitvectorState
+	err := Unmarshal(enc, &decoded)
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Path() != "Participation" {
+		t.Errorf("Expected an error in field Participation, received %v", err)
+	}
+	if _, err := Marshal(&bitvectorState{JustificationBits: Bitvector{0x01, 0x00}}); err == nil || !strings.Contains(err.Error(), "has 2 bytes, expected 1") {
+		t.Errorf("Expected a bitvector of the wrong size to be rejected, received %v", err)
+	}
+}
+
+func TestBitvector_RequiresLength(t *testing.T) {
+	type untagged struct {
+		Bits Bitvector
+	}
+	if _, err := Marshal(&untagged{}); err == nil || !strings.Contains(err.Error(), "has no length") {
+		t.Errorf("Expected a bitvector without ssz-size tag to be rejected, received %v", err)
+	}
+	type zero struct {
+		Bits Bitvector `ssz-size:"0"`
+	}
+	if _, err := Marshal(&zero{}); err == nil {
+		t.Error("Expected a bitvector of no bits to be rejected")
+	}
+	if _, err := Marshal(NewBitvector(8)); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected a bitvector outside of a struct to be unsupported, received %v", err)
+	}
+}
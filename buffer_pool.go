@@ -0,0 +1,110 @@
+package ssz
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// LargeBufferThreshold is the encoded size in bytes from which Marshal obtains its
+// output buffer from the configured BufferPool instead of allocating a fresh one.
+const LargeBufferThreshold = 1 << 20
+
+// pageSize is the granularity of the size classes used by the pool returned by NewBufferPool.
+const pageSize = 4096
+
+// BufferPool supplies and reclaims the large output buffers used when marshaling
+// values such as archival beacon states, which would otherwise allocate and release
+// hundreds of megabytes per call.
+type BufferPool interface {
+	// Get returns a buffer with a capacity of at least size bytes.
+	Get(size int) []byte
+	// Put hands a buffer no longer referenced by the caller back to the pool.
+	Put(buf []byte)
+}
+
+type bufferPoolHolder struct {
+	pool BufferPool
+}
+
+var bufferPool atomic.Value
+
+func init() {
+	bufferPool.Store(bufferPoolHolder{})
+}
+
+// SetBufferPool configures the pool consulted by Marshal for outputs of at least
+// LargeBufferThreshold bytes. Passing nil restores the default behavior of plain
+// allocation. Buffers returned by Marshal are owned by the caller, who may hand
+// them back with RecycleBuffer once they are no longer referenced.
+func SetBufferPool(p BufferPool) {
+	bufferPool.Store(bufferPoolHolder{pool: p})
+}
+
+// RecycleBuffer returns a buffer obtained from Marshal to the configured
+// BufferPool. The buffer must not be used after it has been recycled. It is a
+// no-op if no pool is configured or the buffer was too small to be pooled.
+func RecycleBuffer(buf []byte) {
+	p := bufferPool.Load().(bufferPoolHolder).pool
+	if p == nil || cap(buf) < LargeBufferThreshold {
+		return
+	}
+	p.Put(buf[:cap(buf)])
+}
+
+// allocateBuffer returns a zeroed buffer of the given size, taken from the
+// configured pool if it is large enough to qualify.
+func allocateBuffer(size uint64) []byte {
+	p := bufferPool.Load().(bufferPoolHolder).pool
+	if p == nil || size < LargeBufferThreshold {
+		return make([]byte, size)
+	}
+	buf := p.Get(int(size))
+	if uint64(cap(buf)) < size {
+		return make([]byte, size)
+	}
+	buf = buf[:size]
+	// Pooled buffers contain data from previous encodings.
+	for i := range buf {
+		buf[i] = 0
+	}
+	return buf
+}
+
+type pagedBufferPool struct {
+	lock    sync.Mutex
+	classes map[int]*sync.Pool
+}
+
+// NewBufferPool returns a BufferPool which keeps released buffers in page-sized
+// size classes, so states whose encoded size varies slightly between calls still
+// reuse the same buffers. Capacities are always a multiple of 4096 bytes.
+func NewBufferPool() BufferPool {
+	return &pagedBufferPool{classes: make(map[int]*sync.Pool)}
+}
+
+func (p *pagedBufferPool) Get(size int) []byte {
+	class := (size + pageSize - 1) / pageSize * pageSize
+	if buf, ok := p.class(class).Get().(*[]byte); ok {
+		return (*buf)[:size]
+	}
+	return make([]byte, size, class)
+}
+
+func (p *pagedBufferPool) Put(buf []byte) {
+	if cap(buf) == 0 || cap(buf)%pageSize != 0 {
+		return
+	}
+	buf = buf[:cap(buf)]
+	p.class(cap(buf)).Put(&buf)
+}
+
+func (p *pagedBufferPool) class(size int) *sync.Pool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	pool, ok := p.classes[size]
+	if !ok {
+		pool = &sync.Pool{}
+		p.classes[size] = pool
+	}
+	return pool
+}
@@ -0,0 +1,138 @@
+package ssz
+
+import (
+	"bytes"
+	"testing"
+)
+
+type countingPool struct {
+	gets        int
+	puts        int
+	reused      int
+	free        [][]byte
+	outstanding map[*byte]bool
+}
+
+func (p *countingPool) Get(size int) []byte {
+	p.gets++
+	for i, buf := range p.free {
+		if cap(buf) >= size {
+			p.free = append(p.free[:i], p.free[i+1:]...)
+			p.reused++
+			p.outstanding[&buf[:1][0]] = true
+			return buf[:size]
+		}
+	}
+	buf := make([]byte, size)
+	p.outstanding[&buf[0]] = true
+	return buf
+}
+
+func (p *countingPool) Put(buf []byte) {
+	p.puts++
+	delete(p.outstanding, &buf[:1][0])
+	p.free = append(p.free, buf)
+}
+
+func largeBeaconState(seed byte) *beaconState {
+	roots := make([][]byte, 65536)
+	for i := 0; i < len(roots); i++ {
+		roots[i] = make([]byte, 32)
+		roots[i][0] = seed
+	}
+	return &beaconState{BlockRoots: roots}
+}
+
+func TestBufferPool_ReusesLargeBuffers(t *testing.T) {
+	pool := &countingPool{outstanding: make(map[*byte]bool)}
+	SetBufferPool(pool)
+	defer SetBufferPool(nil)
+
+	for i := 0; i < 3; i++ {
+		enc, err := Marshal(largeBeaconState(byte(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(enc) != 65536*32 || enc[0] != byte(i) {
+			t.Fatalf("Unexpected encoding of length %d", len(enc))
+		}
+		RecycleBuffer(enc)
+	}
+	if pool.gets != 3 || pool.puts != 3 {
+		t.Errorf("Expected 3 gets and 3 puts, received %d and %d", pool.gets, pool.puts)
+	}
+	if pool.reused != 2 {
+		t.Errorf("Expected 2 reused buffers, received %d", pool.reused)
+	}
+}
+
+func TestBufferPool_NoReuseWhileReferenced(t *testing.T) {
+	pool := &countingPool{outstanding: make(map[*byte]bool)}
+	SetBufferPool(pool)
+	defer SetBufferPool(nil)
+
+	first, err := Marshal(largeBeaconState(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Marshal(largeBeaconState(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &first[0] == &second[0] {
+		t.Fatal("Expected a buffer still referenced by the caller not to be handed out again")
+	}
+	if first[0] != 1 || second[0] != 2 {
+		t.Errorf("Expected distinct encodings, received %d and %d", first[0], second[0])
+	}
+	if len(pool.outstanding) != 2 {
+		t.Errorf("Expected 2 outstanding buffers, received %d", len(pool.outstanding))
+	}
+	RecycleBuffer(first)
+	third, err := Marshal(largeBeaconState(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &third[0] != &first[0] {
+		t.Error("Expected the recycled buffer to be reused")
+	}
+	if second[0] != 2 {
+		t.Error("Expected the referenced buffer not to be overwritten")
+	}
+}
+
+func TestBufferPool_SmallOutputsBypassPool(t *testing.T) {
+	pool := &countingPool{outstanding: make(map[*byte]bool)}
+	SetBufferPool(pool)
+	defer SetBufferPool(nil)
+
+	if _, err := Marshal(fork{Epoch: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if pool.gets != 0 {
+		t.Errorf("Expected small outputs not to use the pool, received %d gets", pool.gets)
+	}
+}
+
+func TestBufferPool_PagedPoolMatchesPlainAllocation(t *testing.T) {
+	state := largeBeaconState(7)
+	want, err := Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetBufferPool(NewBufferPool())
+	defer SetBufferPool(nil)
+	for i := 0; i < 2; i++ {
+		got, err := Marshal(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(want, got) {
+			t.Fatal("Expected pooled encoding to match plain allocation")
+		}
+		if cap(got)%pageSize != 0 {
+			t.Errorf("Expected page-sized capacity, received %d", cap(got))
+		}
+		RecycleBuffer(got)
+	}
+}
@@ -0,0 +1,63 @@
+// Package ristretto provides a hash tree root cache backed by
+// github.com/dgraph-io/ristretto, which go-ssz uses unless built with the
+// nocache tag. It is kept in its own package so that builds without caching,
+// such as WASM light clients, do not depend on ristretto at all.
+package ristretto
+
+import (
+	"github.com/dgraph-io/ristretto"
+)
+
+// Cache is a hash tree root cache backed by ristretto.
+type Cache struct {
+	cache   *ristretto.Cache
+	maxCost int64
+}
+
+// New creates a cache tracking the access frequency of numCounters keys and
+// holding entries up to a total cost of maxCost.
+func New(numCounters int64, maxCost int64) (*Cache, error) {
+	cache, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters: numCounters,
+		MaxCost:     maxCost,
+		BufferItems: 64, // number of keys per Get buffer.
+		Metrics:     true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{cache: cache, maxCost: maxCost}, nil
+}
+
+// Evictions returns the number of entries evicted to make room for others.
+func (c *Cache) Evictions() uint64 {
+	return c.cache.Metrics.KeysEvicted()
+}
+
+// Cost returns the total cost of the entries the cache holds.
+func (c *Cache) Cost() int64 {
+	return int64(c.cache.Metrics.CostAdded() - c.cache.Metrics.CostEvicted())
+}
+
+// MaxCost returns the total cost of the entries the cache holds at most.
+func (c *Cache) MaxCost() int64 {
+	return c.maxCost
+}
+
+// Get returns the value stored for the key, if any.
+func (c *Cache) Get(key string) (interface{}, bool) {
+	return c.cache.Get(key)
+}
+
+// Set stores the value for the key at the given cost. As ristretto admits
+// entries asynchronously and may reject them, the value is not guaranteed to
+// be returned by a later Get.
+func (c *Cache) Set(key string, value interface{}, cost int64) bool {
+	return c.cache.Set(key, value, cost)
+}
+
+// Close stops the goroutines of the cache. The cache must not be used
+// afterwards.
+func (c *Cache) Close() {
+	c.cache.Close()
+}
@@ -0,0 +1,55 @@
+package ristretto
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCache_SetGet(t *testing.T) {
+	cache, err := New(100, 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	if cache.MaxCost() != 1<<10 {
+		t.Errorf("Expected a maximum cost of %d, received %d", 1<<10, cache.MaxCost())
+	}
+	root := [32]byte{1, 2, 3}
+	cache.Set("key", root, 32)
+	// Writes are applied asynchronously, so we wait for the entry to land.
+	for i := 0; i < 100; i++ {
+		if res, ok := cache.Get("key"); ok {
+			if res.([32]byte) != root {
+				t.Errorf("Expected %#x, received %#x", root, res)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected the entry to be cached")
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	if _, err := New(0, 0); err == nil {
+		t.Error("Expected a cache without counters to fail")
+	}
+}
+
+func TestCache_Metrics(t *testing.T) {
+	cache, err := New(100, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	for i := 0; i < 10; i++ {
+		cache.Set(string(rune('a'+i)), [32]byte{byte(i)}, 32)
+		time.Sleep(5 * time.Millisecond)
+	}
+	// Entries beyond the maximum cost are either evicted or rejected.
+	if cost := cache.Cost(); cost <= 0 || cost > cache.MaxCost() {
+		t.Errorf("Expected a cost between 1 and %d, received %d", cache.MaxCost(), cost)
+	}
+	if added := uint64(cache.Cost()/32) + cache.Evictions(); added > 10 {
+		t.Errorf("Expected at most 10 entries to be added, received %d", added)
+	}
+}
@@ -0,0 +1,44 @@
+package ssz
+
+import (
+	"github.com/524119574/go-ssz/types"
+)
+
+// CacheConfig sizes the hash tree root caches, or disables them altogether so
+// that hashing never starts the goroutines of ristretto:
+//
+//  err := ssz.Configure(ssz.CacheConfig{RootsArrayMaxCost: 64 << 20})
+//
+// Fields left at zero keep their default.
+type CacheConfig = types.CacheConfig
+
+// Configure applies config to the hash tree root caches, returning the error of
+// the cache backend if it cannot be created with it. Existing caches are closed
+// and start over with the config the next time they are used. Configure must
+// not be called while values are hashed.
+func Configure(config CacheConfig) error {
+	return types.Configure(config)
+}
+
+// Close stops the goroutines of the hash tree root caches and drops their
+// entries, for programs which need a clean shutdown, such as test suites with
+// goroutine leak detectors. Caching keeps working afterwards, starting over
+// with empty caches.
+func Close() {
+	types.Close()
+}
+
+// CacheStats returns the hits, misses, evictions and cost of the hash tree root
+// caches shared by the process, along with the hits and misses of the tries of
+// vectors, to tell whether the caches are sized well. The counters only ever
+// grow and are read without blocking hashing:
+//
+//  before := ssz.CacheStats()
+//  ...
+//  after := ssz.CacheStats()
+//  hits := after.RootsArrays.Hits - before.RootsArrays.Hits
+//
+// The counters of a HashCache are returned by its Stats method.
+func CacheStats() types.CacheStats {
+	return types.ReadCacheStats()
+}
@@ -0,0 +1,51 @@
+package ssz
+
+import (
+	"testing"
+
+	"github.com/524119574/go-ssz/types"
+)
+
+func TestConfigure(t *testing.T) {
+	state := optsStateFixture(3)
+	want, err := HashTreeRoot(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Configure(CacheConfig{RootsArrayMaxCost: -1}); err == nil {
+		t.Error("Expected a negative maximum cost to fail")
+	}
+	if err := Configure(CacheConfig{Disabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	defer Configure(CacheConfig{})
+	defer Close()
+	for i := 0; i < 2; i++ {
+		root, err := HashTreeRootOpts(state, WithCache(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root != want {
+			t.Errorf("Expected root %#x with the caches disabled, received %#x", want, root)
+		}
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	types.ToggleCache(true)
+	defer types.ToggleCache(false)
+	state := optsStateFixture(4)
+	if _, err := HashTreeRoot(state); err != nil {
+		t.Fatal(err)
+	}
+	before := CacheStats()
+	state.BlockRoots[1][5]++
+	if _, err := HashTreeRoot(state); err != nil {
+		t.Fatal(err)
+	}
+	after := CacheStats()
+	if after.Layers.Hits <= before.Layers.Hits || after.Layers.UpdatedLeaves-before.Layers.UpdatedLeaves != 1 {
+		t.Errorf("Expected hashing the state again to update its trie over the changed root, received %+v then %+v", before.Layers, after.Layers)
+	}
+
+}
@@ -0,0 +1,270 @@
+package ssz
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/524119574/go-ssz/types"
+)
+
+// Codec marshals, unmarshals and hashes the values of a single type, whose
+// factory and size are resolved once when the codec is created rather than on
+// every call. Marshal, Unmarshal and HashTreeRoot go through the codec of the
+// type of the value they are given, so a Codec always agrees with them:
+//
+//  codec, err := ssz.CodecFor(&Attestation{})
+//  if err != nil {
+//      return err
+//  }
+//  for _, att := range attestations {
+//      enc, err := codec.Marshal(att)
+//      ...
+//  }
+//
+// A codec handles values of its type and pointers to them alike, and is safe
+// for concurrent use.
+type Codec struct {
+	typ     reflect.Type
+	factory types.SSZAble
+	// size is the size of the encoding of every value of the type, when it
+	// does not depend on the value.
+	size     uint64
+	constant bool
+
+	checkOnce sync.Once
+	checkErr  error
+	maxOnce   sync.Once
+	maxSize   uint64
+	maxErr    error
+}
+
+// codecs caches the *Codec of every type by the type of its values, with
+// pointers removed.
+var codecs sync.Map
+
+var (
+	// codecsLock is held for writing while the codecs are reset, and for
+	// reading while one is stored, so that a codec created before a reset is
+	// never stored after it.
+	codecsLock sync.RWMutex
+	// codecsGeneration counts the resets of the codecs.
+	codecsGeneration uint64
+)
+
+// CodecFor returns the codec of the type of prototype, or of the type it points
+// to when prototype is a pointer. Codecs are created once per type and shared
+// by every caller.
+func CodecFor(prototype interface{}) (*Codec, error) {
+	if prototype == nil {
+		return nil, errors.New("untyped-value nil has no codec")
+	}
+	return codecForType(reflect.TypeOf(prototype))
+}
+
+func codecForType(typ reflect.Type) (*Codec, error) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if c, ok := codecs.Load(typ); ok {
+		return c.(*Codec), nil
+	}
+	codecsLock.RLock()
+	generation := codecsGeneration
+	codecsLock.RUnlock()
+	factory, err := types.SSZFactory(reflect.New(typ).Elem(), typ)
+	if err != nil {
+		return nil, err
+	}
+	c := &Codec{typ: typ, factory: factory}
+	c.size, c.constant = types.ConstantSize(typ)
+	codecsLock.RLock()
+	if generation != codecsGeneration {
+		// An alias, a codec or a union was registered while the codec was
+		// created, which may have changed its factory or size, so it is
+		// created again.
+		codecsLock.RUnlock()
+		return codecForType(typ)
+	}
+	actual, _ := codecs.LoadOrStore(typ, c)
+	codecsLock.RUnlock()
+	return actual.(*Codec), nil
+}
+
+// RegisterCodec installs codec as the way values of typ are marshaled,
+// unmarshaled and hashed wherever they appear, such as types of other packages
+// to which methods cannot be added:
+//
+//  err := ssz.RegisterCodec(reflect.TypeOf(bls.Signature{}), signatureCodec{}, func(reflect.Value) uint64 {
+//      return 96
+//  }, false)
+//
+// The size function returns the size of the encoding of a value, and
+// variableSize tells whether values are laid out as variable-size fields in
+// containers. The codec is given values of typ itself, never pointers to them,
+// and is consulted before any other way of handling typ. Registering a codec
+// for a type more than once returns an error. RegisterCodec is safe for
+// concurrent use, such as from the init functions of several packages.
+func RegisterCodec(typ reflect.Type, codec types.SSZAble, size func(val reflect.Value) uint64, variableSize bool) error {
+	if err := types.RegisterCodec(typ, codec, size, variableSize); err != nil {
+		return err
+	}
+	resetCodecs()
+	return nil
+}
+
+// resetCodecs forgets the codecs created so far, whose factories may have been
+// replaced by a registered alias.
+func resetCodecs() {
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+	codecsGeneration++
+	codecs.Range(func(key, _ interface{}) bool {
+		codecs.Delete(key)
+		return true
+	})
+}
+
+// value returns val as a reflect.Value after checking it is of the type of the
+// codec or a pointer to it.
+func (c *Codec) value(val interface{}) (reflect.Value, error) {
+	if val == nil {
+		return reflect.Value{}, errors.New("untyped-value nil cannot be marshaled")
+	}
+	rval := reflect.ValueOf(val)
+	if rval.Type() != c.typ && rval.Type() != reflect.PtrTo(c.typ) {
+		return reflect.Value{}, fmt.Errorf("codec of type %v cannot handle values of type %v", c.typ, rval.Type())
+	}
+	return rval, nil
+}
+
+// encodedSize returns the size of the encoding of rval.
+func (c *Codec) encodedSize(rval reflect.Value) uint64 {
+	if c.constant {
+		return c.size
+	}
+	return types.DetermineSize(rval)
+}
+
+// Marshal returns the encoding of val, as Marshal does.
+func (c *Codec) Marshal(val interface{}) ([]byte, error) {
+	enc, err := c.marshal(val)
+	return enc, newError(OpMarshal, val, err)
+}
+
+func (c *Codec) marshal(val interface{}) ([]byte, error) {
+	if v, ok := val.(Marshaler); ok {
+		return v.MarshalSSZ()
+	}
+	rval, err := c.value(val)
+	if err != nil {
+		return nil, err
+	}
+	// We pre-allocate a buffer-size depending on the value's calculated total byte size.
+	buf := allocateBuffer(c.encodedSize(rval))
+	if err := marshalValue(c.factory, rval, buf); err != nil {
+		RecycleBuffer(buf)
+		return nil, err
+	}
+	return buf, nil
+}
+
+// Unmarshal decodes input into the object pointed by val, as Unmarshal does.
+func (c *Codec) Unmarshal(input []byte, val interface{}) error {
+	return newError(OpUnmarshal, val, c.unmarshal(input, val, nil))
+}
+
+func (c *Codec) unmarshal(input []byte, val interface{}, ctx *types.DecodeContext) error {
+	if val == nil {
+		return errors.New("cannot unmarshal into untyped, nil value")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if v, ok := val.(Unmarshaler); ok {
+		return v.UnmarshalSSZ(input)
+	}
+	rval, err := unmarshalTarget(input, val)
+	if err != nil {
+		return err
+	}
+	if rval.Type() != reflect.PtrTo(c.typ) {
+		return fmt.Errorf("codec of type %v cannot unmarshal into values of type %v", c.typ, rval.Type())
+	}
+	return c.decode(input, rval, ctx)
+}
+
+// decode decodes input into the value pointed by rval, which must be a pointer
+// to a value of the type of the codec.
+func (c *Codec) decode(input []byte, rval reflect.Value, ctx *types.DecodeContext) error {
+	consumed, err := c.factory.Unmarshal(rval.Elem(), c.typ, input, 0, ctx)
+	if err != nil {
+		return err
+	}
+	return checkDecodedSize(rval.Interface(), consumed, uint64(len(input)))
+}
+
+// HashTreeRoot returns the hash tree root of val, as HashTreeRoot does.
+func (c *Codec) HashTreeRoot(val interface{}) ([32]byte, error) {
+	root, err := c.hashTreeRoot(val)
+	return root, newError(OpHash, val, err)
+}
+
+func (c *Codec) hashTreeRoot(val interface{}) ([32]byte, error) {
+	return c.root(val, nil)
+}
+
+// root returns the hash tree root of val computed with ctx. Pointers are hashed
+// as the values they point to, and nil pointers as zero values, as the factories
+// of lists and vectors only handle values.
+func (c *Codec) root(val interface{}, ctx *types.HashContext) ([32]byte, error) {
+	if val == nil {
+		return [32]byte{}, errors.New("untyped nil is not supported")
+	}
+	rval, err := c.value(val)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	if rval.Kind() == reflect.Ptr {
+		if rval.IsNil() {
+			rval = reflect.New(c.typ)
+		}
+		rval = rval.Elem()
+	}
+	return c.factory.Root(rval, c.typ, "", 0, ctx)
+}
+
+// Size returns the size of the encoding of val, as SizeSSZ does.
+func (c *Codec) Size(val interface{}) (uint64, error) {
+	size, err := c.sizeOf(val)
+	return size, newError(OpMarshal, val, err)
+}
+
+func (c *Codec) sizeOf(val interface{}) (uint64, error) {
+	if v, ok := val.(Marshaler); ok {
+		return uint64(v.SizeSSZ()), nil
+	}
+	rval, err := c.value(val)
+	if err != nil {
+		return 0, err
+	}
+	c.checkOnce.Do(func() {
+		c.checkErr = types.CheckType(c.typ)
+	})
+	if c.checkErr != nil {
+		return 0, c.checkErr
+	}
+	return c.encodedSize(rval), nil
+}
+
+// MaxSize returns the size of the longest encoding of a value of the type of
+// the codec, given the ssz-max tags of its lists and strings, such as to bound
+// the frames read from a peer. Types holding lists without a limit have no such
+// size, and an error is returned for them.
+func (c *Codec) MaxSize() (uint64, error) {
+	c.maxOnce.Do(func() {
+		c.maxSize, c.maxErr = types.MaxSize(c.typ)
+	})
+	return c.maxSize, newError(OpMarshal, reflect.Zero(c.typ).Interface(), c.maxErr)
+}
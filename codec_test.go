@@ -0,0 +1,450 @@
+package ssz
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/524119574/go-ssz/types"
+)
+
+func TestCodec_AgreesWithPackageFunctions(t *testing.T) {
+	type block struct {
+		Slot    uint64
+		Parent  *fork
+		Data    []byte `ssz-max:"16"`
+		Comment string `ssz-max:"8"`
+	}
+	block1 := newDepositBlockFixture()
+	tests := []struct {
+		name   string
+		val    interface{}
+		target interface{}
+	}{
+		{name: "fork", val: fork{PreviousVersion: [4]byte{1}, Epoch: 5}, target: &fork{}},
+		{name: "pointer to fork", val: &fork{CurrentVersion: [4]byte{2}, Epoch: 9}, target: &fork{}},
+		{name: "nil pointer", val: (*fork)(nil), target: &fork{}},
+		{name: "variable container", val: &block{Slot: 3, Parent: &fork{Epoch: 1}, Data: []byte{1, 2}, Comment: "abc"}, target: &block{}},
+		{name: "deposit block", val: block1, target: &depositBlockFixture{}},
+		{name: "tagged deposit block", val: taggedDepositBlock(block1), target: &taggedDepositBlockFixture{}},
+		{name: "list", val: []uint64{1, 2, 3}, target: &[]uint64{}},
+		{name: "generated encoders", val: &generatedCheckpoint{Epoch: 7}, target: &generatedCheckpoint{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec, err := CodecFor(tt.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := Marshal(tt.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			enc, err := codec.Marshal(tt.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(enc, want) {
+				t.Errorf("Codec.Marshal = %#x, want %#x", enc, want)
+			}
+
+			size, err := codec.Size(tt.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantSize, err := SizeSSZ(tt.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != wantSize || size != uint64(len(want)) {
+				t.Errorf("Codec.Size = %d, SizeSSZ = %d, encoding of %d bytes", size, wantSize, len(want))
+			}
+
+			wantRoot, err := HashTreeRoot(tt.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			root, err := codec.HashTreeRoot(tt.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if root != wantRoot {
+				t.Errorf("Codec.HashTreeRoot = %#x, want %#x", root, wantRoot)
+			}
+
+			wantDecoded := reflect.New(reflect.TypeOf(tt.target).Elem()).Interface()
+			if err := Unmarshal(want, wantDecoded); err != nil {
+				t.Fatal(err)
+			}
+			if err := codec.Unmarshal(want, tt.target); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tt.target, wantDecoded) {
+				t.Errorf("Codec.Unmarshal = %+v, want %+v", tt.target, wantDecoded)
+			}
+		})
+	}
+}
+
+func TestCodecFor_SharedPerType(t *testing.T) {
+	c1, err := CodecFor(fork{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := CodecFor(&fork{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c1 != c2 {
+		t.Error("Expected values and pointers of a type to share their codec")
+	}
+}
+
+func TestCodecFor_RejectsUnsupportedTypes(t *testing.T) {
+	tests := []interface{}{
+		nil,
+		complex64(1),
+		make(chan uint64),
+		int(1),
+	}
+	for _, tt := range tests {
+		if _, err := CodecFor(tt); err == nil {
+			t.Errorf("Expected no codec for %T", tt)
+		}
+	}
+}
+
+func TestCodec_RejectsValuesOfOtherTypes(t *testing.T) {
+	codec, err := CodecFor(fork{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &limitsCheckpoint{Epoch: 1}
+	if _, err := codec.Marshal(other); err == nil || !strings.Contains(err.Error(), "cannot handle values of type") {
+		t.Errorf("Expected Marshal to reject %T, received %v", other, err)
+	}
+	if _, err := codec.HashTreeRoot(other); err == nil {
+		t.Errorf("Expected HashTreeRoot to reject %T", other)
+	}
+	if _, err := codec.Size(other); err == nil {
+		t.Errorf("Expected Size to reject %T", other)
+	}
+	if err := codec.Unmarshal(make([]byte, 40), other); err == nil || !strings.Contains(err.Error(), "cannot unmarshal into values of type") {
+		t.Errorf("Expected Unmarshal to reject %T, received %v", other, err)
+	}
+	if err := codec.Unmarshal(make([]byte, 16), fork{}); err == nil {
+		t.Error("Expected Unmarshal to reject a non-pointer target")
+	}
+}
+
+func TestCodec_MaxSize(t *testing.T) {
+	type attestations struct {
+		Items []*taggedLimitsAttestation `ssz-max:"128"`
+	}
+	type comment struct {
+		Slot uint64
+		Text string `ssz-max:"64"`
+	}
+	tests := []struct {
+		name string
+		val  interface{}
+		want uint64
+	}{
+		{name: "basic", val: uint64(0), want: 8},
+		{name: "fixed container", val: fork{}, want: 16},
+		{name: "string", val: comment{}, want: 8 + 4 + 64},
+		// 72 bytes of eth1 data, a 32 bytes graffiti, the offset of the
+		// deposits and 16 deposits of 1240 bytes.
+		{name: "deposit block", val: depositBlockFixture{}, want: 72 + 32 + 4 + 16*1240},
+		{name: "tagged deposit block", val: &taggedDepositBlockFixture{}, want: 72 + 32 + 4 + 16*1240},
+		// The offset and 2048 bits of an attestation, followed by its 40 bytes
+		// checkpoint.
+		{name: "variable container", val: taggedLimitsAttestation{}, want: 4 + 2048 + 40},
+		{name: "list of variable containers", val: attestations{}, want: 4 + 128*(4+4+2048+40)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec, err := CodecFor(tt.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			size, err := codec.MaxSize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != tt.want {
+				t.Errorf("MaxSize = %d, want %d", size, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodec_MaxSizeRequiresLimits(t *testing.T) {
+	type overflowing struct {
+		Lists [][]uint64 `ssz-size:"?,4294967296" ssz-max:"4294967296"`
+	}
+	tests := []interface{}{
+		[]uint64{},
+		"text",
+		limitsBlock{},
+		taggedLimitsBody{},
+		overflowing{},
+	}
+	for _, tt := range tests {
+		codec, err := CodecFor(tt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size, err := codec.MaxSize(); err == nil {
+			t.Errorf("Expected no maximum size for %T, received %d", tt, size)
+		}
+	}
+}
+
+func BenchmarkCodec_Marshal(b *testing.B) {
+	block := taggedDepositBlock(newDepositBlockFixture())
+	codec, err := CodecFor(block)
+	if err != nil {
+		b.Fatal(err)
+	}
+	// Uncached resolves the codec on every call, as Marshal did before codecs
+	// were shared.
+	b.Run("Uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			resetCodecs()
+			if _, err := Marshal(block); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := Marshal(block); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Codec", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := codec.Marshal(block); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkCodec_Unmarshal(b *testing.B) {
+	item := fork{PreviousVersion: [4]byte{1}, CurrentVersion: [4]byte{2}, Epoch: 3}
+	enc, err := Marshal(item)
+	if err != nil {
+		b.Fatal(err)
+	}
+	codec, err := CodecFor(item)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("Uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			resetCodecs()
+			var decoded fork
+			if err := Unmarshal(enc, &decoded); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Unmarshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var decoded fork
+			if err := Unmarshal(enc, &decoded); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Codec", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var decoded fork
+			if err := codec.Unmarshal(enc, &decoded); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// vendorSignature mimics a type of another package, which only exposes its
+// bytes through functions.
+type vendorSignature struct {
+	raw [8]byte
+}
+
+func (s vendorSignature) Bytes() [8]byte {
+	return s.raw
+}
+
+func vendorSignatureFromBytes(b [8]byte) (vendorSignature, error) {
+	if b == ([8]byte{}) {
+		return vendorSignature{}, errors.New("infinite signature")
+	}
+	return vendorSignature{raw: b}, nil
+}
+
+// signatureCodec encodes vendorSignature values as their 8 bytes, reversed so
+// that its use shows in their encoding.
+type signatureCodec struct{}
+
+func (signatureCodec) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
+	raw := val.Interface().(vendorSignature).Bytes()
+	for i := range raw {
+		buf[startOffset+uint64(i)] = raw[len(raw)-1-i]
+	}
+	return startOffset + 8, nil
+}
+
+func (signatureCodec) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64, ctx *types.DecodeContext) (uint64, error) {
+	if startOffset+8 > uint64(len(input)) {
+		return 0, ErrInputTooShort
+	}
+	var raw [8]byte
+	for i := range raw {
+		raw[i] = input[startOffset+7-uint64(i)]
+	}
+	sig, err := vendorSignatureFromBytes(raw)
+	if err != nil {
+		return 0, err
+	}
+	val.Set(reflect.ValueOf(sig))
+	return startOffset + 8, nil
+}
+
+func (signatureCodec) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *types.HashContext) ([32]byte, error) {
+	return HashTreeRoot(val.Interface().(vendorSignature).Bytes())
+}
+
+type signedVendorBlock struct {
+	Slot       uint64
+	Signature  vendorSignature
+	Proposer   *vendorSignature
+	Signatures []vendorSignature `ssz-max:"4"`
+}
+
+func init() {
+	if err := RegisterCodec(reflect.TypeOf(vendorSignature{}), signatureCodec{}, func(reflect.Value) uint64 {
+		return 8
+	}, false); err != nil {
+		panic(err)
+	}
+}
+
+func TestRegisterCodec_RoundTripInContainer(t *testing.T) {
+	block := &signedVendorBlock{
+		Slot:       1,
+		Signature:  vendorSignature{raw: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		Proposer:   &vendorSignature{raw: [8]byte{9}},
+		Signatures: []vendorSignature{{raw: [8]byte{7: 1}}, {raw: [8]byte{7: 2}}},
+	}
+	enc, err := Marshal(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		1, 0, 0, 0, 0, 0, 0, 0,
+		8, 7, 6, 5, 4, 3, 2, 1,
+		0, 0, 0, 0, 0, 0, 0, 9,
+		28, 0, 0, 0,
+		1, 0, 0, 0, 0, 0, 0, 0,
+		2, 0, 0, 0, 0, 0, 0, 0,
+	}
+	if !bytes.Equal(enc, want) {
+		t.Fatalf("Marshaled %v, expected %v", enc, want)
+	}
+	size, err := SizeSSZ(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != uint64(len(want)) {
+		t.Errorf("SizeSSZ returned %d, expected %d", size, len(want))
+	}
+	var decoded signedVendorBlock
+	if err := Unmarshal(enc, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, block) {
+		t.Errorf("Unmarshaled %+v, expected %+v", decoded, block)
+	}
+	var streamed bytes.Buffer
+	if err := NewEncoder(&streamed).Encode(block); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(streamed.Bytes(), want) {
+		t.Errorf("Encoded %v, expected %v", streamed.Bytes(), want)
+	}
+	type plainBlock struct {
+		Slot       uint64
+		Signature  [8]byte
+		Proposer   [8]byte
+		Signatures [][8]byte `ssz-max:"4"`
+	}
+	plainRoot, err := HashTreeRoot(&plainBlock{
+		Slot:       1,
+		Signature:  block.Signature.raw,
+		Proposer:   block.Proposer.raw,
+		Signatures: [][8]byte{block.Signatures[0].raw, block.Signatures[1].raw},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := HashTreeRoot(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != plainRoot {
+		t.Errorf("Root is %#x, expected %#x", root, plainRoot)
+	}
+	// The codec rejects signatures of zero bytes.
+	invalid := append([]byte{}, want...)
+	copy(invalid[8:16], make([]byte, 8))
+	if err := Unmarshal(invalid, &decoded); err == nil || !strings.Contains(err.Error(), "infinite signature") {
+		t.Errorf("Expected the codec to reject its input, received %v", err)
+	}
+}
+
+func TestRegisterCodec_Concurrent(t *testing.T) {
+	const n = 8
+	typs := make([]reflect.Type, n)
+	for i := range typs {
+		typs[i] = reflect.StructOf([]reflect.StructField{{Name: fmt.Sprintf("Codec%d", i), Type: reflect.TypeOf(uint64(0))}})
+	}
+	size := func(reflect.Value) uint64 {
+		return 8
+	}
+	errs := make(chan error, n)
+	for _, typ := range typs {
+		go func(typ reflect.Type) {
+			errs <- RegisterCodec(typ, signatureCodec{}, size, false)
+		}(typ)
+	}
+	for range typs {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	for _, typ := range typs {
+		if factory, err := types.SSZFactory(reflect.New(typ).Elem(), typ); err != nil || reflect.TypeOf(factory).Elem().Name() != "registeredSSZ" {
+			t.Errorf("Expected the codec of %v to be registered, received %T, %v", typ, factory, err)
+		}
+	}
+	if err := RegisterCodec(typs[0], signatureCodec{}, size, false); err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Errorf("Expected registering a codec twice to fail, received %v", err)
+	}
+	if err := RegisterCodec(reflect.TypeOf(&vendorSignature{}), signatureCodec{}, size, false); err == nil {
+		t.Error("Expected registering a codec for a pointer type to fail")
+	}
+}
@@ -0,0 +1,220 @@
+package ssz
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// The types below are only used by TestConcurrentMarshalUnmarshal, so that
+// their codecs, fields and flat decoders are first built by its goroutines
+// concurrently.
+
+type stressCheckpoint struct {
+	Epoch uint64
+	Root  [32]byte
+}
+
+type stressAttestation struct {
+	AggregationBits Bitlist `ssz-max:"64"`
+	Slot            uint64
+	Source          stressCheckpoint
+	Target          *stressCheckpoint
+	Signature       [96]byte
+}
+
+type stressValidator struct {
+	Pubkey    [48]byte
+	Balance   uint64
+	Slashed   bool
+	Exit      uint64
+	LastRoots [4][32]byte
+}
+
+type stressBlockBody struct {
+	Graffiti     []byte               `ssz-max:"32"`
+	Attestations []*stressAttestation `ssz-max:"16"`
+	Deposits     [][32]byte           `ssz-max:"8"`
+	Comment      string               `ssz-max:"64"`
+}
+
+type stressBlock struct {
+	Slot       uint64
+	ParentRoot [32]byte
+	Body       stressBlockBody
+	Validators []stressValidator `ssz-max:"32"`
+	Balances   []uint64          `ssz-max:"32"`
+}
+
+// stressValues returns a distinct value of every stress type for seed.
+func stressValues(seed int) []interface{} {
+	bits := NewBitlist(uint64(seed%60 + 1))
+	bits.SetBitAt(uint64(seed%60), true)
+	att := &stressAttestation{
+		AggregationBits: bits,
+		Slot:            uint64(seed),
+		Source:          stressCheckpoint{Epoch: uint64(seed), Root: [32]byte{byte(seed)}},
+		Target:          &stressCheckpoint{Epoch: uint64(seed + 1)},
+	}
+	att.Signature[seed%96] = byte(seed)
+	validator := &stressValidator{Balance: uint64(seed) * 32, Slashed: seed%2 == 0, Exit: ^uint64(0)}
+	validator.Pubkey[seed%48] = byte(seed)
+	validator.LastRoots[seed%4][0] = byte(seed)
+	block := &stressBlock{
+		Slot:       uint64(seed),
+		ParentRoot: [32]byte{byte(seed), 1},
+		Body: stressBlockBody{
+			Graffiti:     []byte(fmt.Sprintf("graffiti %d", seed)),
+			Attestations: []*stressAttestation{att, att},
+			Deposits:     [][32]byte{{byte(seed)}},
+			Comment:      fmt.Sprintf("block %d", seed),
+		},
+		Validators: []stressValidator{*validator},
+		Balances:   []uint64{uint64(seed), 2},
+	}
+	return []interface{}{&stressCheckpoint{Epoch: uint64(seed)}, att, validator, &block.Body, block}
+}
+
+// newStressAliasType returns a struct type of its own for every n, holding a
+// uint32 and enough other fields that creating its codec takes a while.
+func newStressAliasType(n int) reflect.Type {
+	fields := []reflect.StructField{{Name: fmt.Sprintf("V%d", n), Type: reflect.TypeOf(uint32(0))}}
+	for i := 0; i < 64; i++ {
+		fields = append(fields, reflect.StructField{Name: fmt.Sprintf("F%d", i), Type: reflect.TypeOf(stressCheckpoint{})})
+	}
+	return reflect.StructOf(fields)
+}
+
+// stressAliasType registers the type newStressAliasType returns for n as an
+// alias of uint64, which its first field is converted to.
+func stressAliasType(n int) (reflect.Type, error) {
+	typ := newStressAliasType(n)
+	err := RegisterAlias(reflect.New(typ).Elem().Interface(), func(v interface{}) (interface{}, error) {
+		return reflect.ValueOf(v).Field(0).Uint(), nil
+	}, func(v interface{}) (interface{}, error) {
+		val := reflect.New(typ).Elem()
+		val.Field(0).SetUint(v.(uint64))
+		return val.Interface(), nil
+	}, uint64(0))
+	return typ, err
+}
+
+// stressAliasTypes counts the types registered by stressAliasType, so that
+// every test registers new ones.
+var stressAliasTypes int32
+
+func TestConcurrentMarshalUnmarshal(t *testing.T) {
+	const goroutines = 32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	done := make(chan struct{})
+	// Aliases are registered throughout, which resets the codecs and the flat
+	// decoders while the goroutines below build them.
+	registered := make(chan struct{})
+	go func() {
+		defer close(registered)
+		<-start
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := stressAliasType(int(atomic.AddInt32(&stressAliasTypes, 1))); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			<-start
+			values := stressValues(g)
+			for i := range values {
+				// Each goroutine starts with another type, so that all of them
+				// are being built at once.
+				val := values[(i+g)%len(values)]
+				enc, err := Marshal(val)
+				if err != nil {
+					t.Errorf("marshaling %T: %v", val, err)
+					return
+				}
+				decoded := reflect.New(reflect.TypeOf(val).Elem()).Interface()
+				if err := Unmarshal(enc, decoded); err != nil {
+					t.Errorf("unmarshaling %T: %v", val, err)
+					return
+				}
+				reencoded, err := Marshal(decoded)
+				if err != nil {
+					t.Errorf("marshaling decoded %T: %v", val, err)
+					return
+				}
+				if !bytes.Equal(enc, reencoded) {
+					t.Errorf("Expected %T to survive a round trip, encoded %#x then %#x", val, enc, reencoded)
+				}
+				root, err := HashTreeRoot(val)
+				if err != nil {
+					t.Errorf("hashing %T: %v", val, err)
+					return
+				}
+				if decodedRoot, err := HashTreeRoot(decoded); err != nil || decodedRoot != root {
+					t.Errorf("Expected decoded %T to have root %#x, received %#x (%v)", val, root, decodedRoot, err)
+				}
+			}
+		}(g)
+	}
+	close(start)
+	wg.Wait()
+	close(done)
+	<-registered
+}
+
+func TestCodecFor_ConcurrentWithAliasRegistration(t *testing.T) {
+	for round := 0; round < 50; round++ {
+		n := int(atomic.AddInt32(&stressAliasTypes, 1))
+		typ := newStressAliasType(n)
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		building := make(chan struct{}, 8)
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				building <- struct{}{}
+				for i := 0; i < 20; i++ {
+					if _, err := codecForType(typ); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}()
+		}
+		close(start)
+		// The alias is registered once the codec is being created.
+		<-building
+		// reflect.StructOf returns typ again for the same fields.
+		if _, err := stressAliasType(n); err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+		// The codec created last must see the alias, whichever codec was being
+		// created while it was registered.
+		c, err := codecForType(typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		enc, err := c.Marshal(reflect.New(typ).Interface())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(enc) != 8 {
+			t.Fatalf("Expected the codec of the alias registered in round %d to encode 8 bytes, received %d", round, len(enc))
+		}
+	}
+}
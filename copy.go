@@ -0,0 +1,51 @@
+package ssz
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"github.com/524119574/go-ssz/types"
+)
+
+// Copy sets the value dst points to to a deep copy of src, the value which
+// unmarshaling the encoding of src into dst would give, without encoding it.
+// src is a value of the type dst points to, or a pointer to one. As with
+// Unmarshal, nil pointers of src are copied as pointers to zero values, nil
+// slices as empty ones and slices standing for vectors as zero vectors of the
+// lengths declared by their ssz-size tags, while the fields which are not
+// serialized, such as the XXX_ fields of protobuf types and fields tagged
+// ssz:"-", are left untouched in dst. The copy shares no pointers or slices with
+// src, so it can be mutated freely:
+//
+//	var copied BeaconState
+//	if err := Copy(state, &copied); err != nil {
+//		return err
+//	}
+func Copy(src, dst interface{}) error {
+	return newError(OpUnmarshal, dst, copyValue(src, dst))
+}
+
+func copyValue(src, dst interface{}) error {
+	if src == nil || dst == nil {
+		return errors.New("cannot copy from or to untyped, nil value")
+	}
+	rdst := reflect.ValueOf(dst)
+	if rdst.Kind() != reflect.Ptr {
+		return errors.New("can only copy into a pointer target")
+	}
+	if rdst.IsNil() {
+		return errors.New("cannot output to pointer of nil value")
+	}
+	rsrc := reflect.ValueOf(src)
+	if rsrc.Type() == rdst.Type() {
+		if rsrc.IsNil() {
+			rsrc = reflect.New(rdst.Type().Elem())
+		}
+		rsrc = rsrc.Elem()
+	}
+	if rsrc.Type() != rdst.Type().Elem() {
+		return fmt.Errorf("cannot copy a value of type %v into %v", rsrc.Type(), rdst.Type())
+	}
+	return types.Copy(rdst.Elem(), rsrc)
+}
@@ -0,0 +1,153 @@
+package ssz
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type copyBody struct {
+	Epoch uint64
+	Roots [][32]byte `ssz-max:"16"`
+}
+
+type copyState struct {
+	Slot       uint64
+	Body       *copyBody
+	Bodies     []*copyBody `ssz-max:"4"`
+	Vectors    [][]byte    `ssz-size:"2,4"`
+	Bits       Bitlist     `ssz-max:"8"`
+	Name       string      `ssz-max:"8"`
+	Balance    BigUint256
+	Payload    unionPayload
+	Checkpoint [2]copyBody
+
+	XXX_sizecache int32
+	Cached        uint64 `ssz:"-"`
+}
+
+func TestCopy_MatchesRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		src  *copyState
+	}{
+		{name: "Zero", src: &copyState{}},
+		{
+			name: "Populated",
+			src: &copyState{
+				Slot:       3,
+				Body:       &copyBody{Epoch: 1, Roots: [][32]byte{{1}, {2}}},
+				Bodies:     []*copyBody{nil, {Epoch: 2}},
+				Vectors:    [][]byte{{1, 2, 3, 4}, {5, 6, 7, 8}},
+				Bits:       Bitlist{0x0d},
+				Name:       "state",
+				Balance:    BigUint256{Int: big.NewInt(1e9)},
+				Payload:    unionPayload{Selector: 3, Value: &unionMemo{Text: []byte("hi")}},
+				Checkpoint: [2]copyBody{{Epoch: 4}, {Roots: [][32]byte{{3}}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := Marshal(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := &copyState{XXX_sizecache: 7, Cached: 9}
+			if err := Unmarshal(enc, want); err != nil {
+				t.Fatal(err)
+			}
+			got := &copyState{XXX_sizecache: 7, Cached: 9}
+			if err := Copy(tt.src, got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Copied %+v, expected %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestCopy_SharesNothing(t *testing.T) {
+	src := copyState{
+		Body:    &copyBody{Roots: [][32]byte{{1}}},
+		Bodies:  []*copyBody{{Epoch: 1}},
+		Vectors: [][]byte{{1, 2, 3, 4}, {5, 6, 7, 8}},
+		Bits:    Bitlist{0x0d},
+		Balance: BigUint256{Int: big.NewInt(5)},
+	}
+	var dst copyState
+	if err := Copy(src, &dst); err != nil {
+		t.Fatal(err)
+	}
+	dst.Body.Roots[0][0] = 9
+	dst.Bodies[0].Epoch = 9
+	dst.Vectors[0][0] = 9
+	dst.Bits[0] = 0x0f
+	dst.Balance.SetInt64(9)
+	if src.Body.Roots[0][0] != 1 || src.Bodies[0].Epoch != 1 || src.Vectors[0][0] != 1 || src.Bits[0] != 0x0d || src.Balance.Int64() != 5 {
+		t.Errorf("Mutating the copy changed the source to %+v", src)
+	}
+}
+
+func TestCopy_Errors(t *testing.T) {
+	var dst copyState
+	tests := []struct {
+		src, dst interface{}
+		err      string
+	}{
+		{src: nil, dst: &dst, err: "untyped, nil"},
+		{src: copyState{}, dst: dst, err: "pointer target"},
+		{src: copyState{}, dst: (*copyState)(nil), err: "nil value"},
+		{src: copyBody{}, dst: &dst, err: "cannot copy a value of type ssz.copyBody"},
+		{src: copyState{Vectors: [][]byte{{1}}}, dst: &dst, err: "expected 2"},
+		{src: copyState{Bits: Bitlist{0x00}}, dst: &dst, err: "no delimiting bit"},
+		{src: 1, dst: new(int), err: "unsupported"},
+	}
+	for _, tt := range tests {
+		if err := Copy(tt.src, tt.dst); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Expected copying %T into %T to fail with %q, received %v", tt.src, tt.dst, tt.err, err)
+		}
+	}
+}
+
+type copyRoots struct {
+	Roots [][32]byte `ssz-max:"8192"`
+}
+
+func copyRootsFixture() *copyRoots {
+	roots := make([][32]byte, 8192)
+	for i := range roots {
+		roots[i][0] = byte(i)
+	}
+	return &copyRoots{Roots: roots}
+}
+
+func BenchmarkCopy_Roots(b *testing.B) {
+	src := copyRootsFixture()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var dst copyRoots
+		if err := Copy(src, &dst); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCopy_RootsMarshalUnmarshal(b *testing.B) {
+	src := copyRootsFixture()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		enc, err := Marshal(src)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var dst copyRoots
+		if err := Unmarshal(enc, &dst); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
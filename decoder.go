@@ -0,0 +1,81 @@
+package ssz
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"github.com/524119574/go-ssz/types"
+)
+
+// Decoder reads the SSZ encodings of values from an io.Reader. Unlike
+// Unmarshal, which is given the whole encoding of a value, it reads the fixed
+// part of containers first and then each of their variable-size fields in
+// turn, and reads fixed-size containers one field at a time, so that large
+// values can be decoded from a file without reading it into memory first:
+//
+//  f, err := os.Open("state.ssz")
+//  if err != nil {
+//      return err
+//  }
+//  defer f.Close()
+//  state := &BeaconState{}
+//  if err := ssz.NewDecoder(bufio.NewReader(f)).Decode(state); err != nil {
+//      return err
+//  }
+//
+// A fixed-size value is read from as many bytes as its encoding holds, so that
+// several of them can be decoded one after the other, while the encoding of a
+// variable-size value extends to the end of the reader.
+type Decoder struct {
+	r   io.Reader
+	dec *types.StreamDecoder
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r, dec: types.NewStreamDecoder(r, nil)}
+}
+
+// Decode reads the encoding of a value and decodes it into the object pointed
+// by pointer val, which then holds the same value as Unmarshal would decode
+// from the same bytes. Types with generated decoders are decoded from the rest
+// of the reader.
+func (d *Decoder) Decode(val interface{}) error {
+	return newError(OpUnmarshal, val, d.decode(val))
+}
+
+func (d *Decoder) decode(val interface{}) error {
+	if val == nil {
+		return errors.New("cannot unmarshal into untyped, nil value")
+	}
+	if v, ok := val.(Unmarshaler); ok {
+		input, err := ioutil.ReadAll(d.r)
+		if err != nil {
+			return err
+		}
+		return v.UnmarshalSSZ(input)
+	}
+	rval := reflect.ValueOf(val)
+	rtyp := rval.Type()
+	// val must be a pointer, otherwise we refuse to unmarshal
+	if rtyp.Kind() != reflect.Ptr {
+		return errors.New("can only unmarshal into a pointer target")
+	}
+	if rval.IsNil() {
+		return errors.New("cannot output to pointer of nil value")
+	}
+	read, err := d.dec.Decode(rval.Elem())
+	if err != nil {
+		return err
+	}
+	// Empty containers and zero-length vectors are the only types which
+	// serialize to zero bytes, so they are the only ones we decode from an
+	// empty reader.
+	if read == 0 && !types.IsZeroSizeType(rtyp.Elem()) {
+		return fmt.Errorf("no data to unmarshal from, reader is empty: %w", ErrInputTooShort)
+	}
+	return checkDecodedSize(val, types.DetermineSize(rval), read)
+}
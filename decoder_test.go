@@ -0,0 +1,184 @@
+package ssz
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecoder_MatchesUnmarshal(t *testing.T) {
+	block := newDepositBlockFixture()
+	values := []interface{}{
+		newLargeStateFixture(),
+		&block,
+		taggedDepositBlock(block),
+		&fingerprintCheckpoint{Epoch: 3, Root: [32]byte{1}},
+		&[]uint64{1, 2, 3},
+		&scaledDeposit{Index: 1, Amount: 32, Memo: []byte("memo")},
+	}
+	for _, fixture := range interleavedFieldFixtures {
+		values = append(values, fixture.value)
+	}
+	for _, val := range values {
+		enc, err := Marshal(val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		typ := reflect.TypeOf(val).Elem()
+		want := reflect.New(typ)
+		if err := Unmarshal(enc, want.Interface()); err != nil {
+			t.Fatal(err)
+		}
+		// Reading a byte at a time makes every read of the decoder come up short.
+		for _, r := range []io.Reader{bytes.NewReader(enc), iotest.OneByteReader(bytes.NewReader(enc))} {
+			got := reflect.New(typ)
+			if err := NewDecoder(r).Decode(got.Interface()); err != nil {
+				t.Fatalf("Could not decode %v: %v", typ, err)
+			}
+			if !reflect.DeepEqual(got.Interface(), want.Interface()) {
+				t.Errorf("Expected the decoded %v to match Unmarshal", typ)
+			}
+		}
+	}
+}
+
+func TestDecoder_DecodesConsecutiveFixedSizeValues(t *testing.T) {
+	var stream bytes.Buffer
+	enc := NewEncoder(&stream)
+	for epoch := uint64(1); epoch <= 3; epoch++ {
+		if err := enc.Encode(&fingerprintCheckpoint{Epoch: epoch}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Encode(&fixedVarFixed{Slot: 4, Data: []byte{1, 2}, Epoch: 5}); err != nil {
+		t.Fatal(err)
+	}
+	dec := NewDecoder(&stream)
+	for epoch := uint64(1); epoch <= 3; epoch++ {
+		checkpoint := &fingerprintCheckpoint{}
+		if err := dec.Decode(checkpoint); err != nil {
+			t.Fatal(err)
+		}
+		if checkpoint.Epoch != epoch {
+			t.Errorf("Expected epoch %d, received %d", epoch, checkpoint.Epoch)
+		}
+	}
+	last := &fixedVarFixed{}
+	if err := dec.Decode(last); err != nil {
+		t.Fatal(err)
+	}
+	if want := (&fixedVarFixed{Slot: 4, Data: []byte{1, 2}, Epoch: 5}); !reflect.DeepEqual(last, want) {
+		t.Errorf("Expected %+v, received %+v", want, last)
+	}
+	if err := dec.Decode(&fingerprintCheckpoint{}); err == nil {
+		t.Error("Expected decoding from an exhausted reader to fail")
+	}
+}
+
+func TestDecoder_RejectsInvalidInput(t *testing.T) {
+	enc, err := Marshal(&fixedVarVarFixed{Slot: 1, Data: []byte{1, 2}, Indices: []uint64{3}, Epoch: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapped := append([]byte{}, enc...)
+	// The offsets of the two variable-size fields follow the slot.
+	copy(swapped[8:16], append(append([]byte{}, enc[12:16]...), enc[8:12]...))
+	// Offsets are 4 bytes long, so one with every bit set points far beyond the
+	// input rather than wrapping around.
+	beyond := append([]byte{}, enc...)
+	copy(beyond[12:16], []byte{0xff, 0xff, 0xff, 0xff})
+	tests := []struct {
+		name  string
+		input []byte
+		val   interface{}
+		want  string
+	}{
+		{name: "empty", input: nil, val: &fixedVarFixed{}, want: "truncated"},
+		{name: "truncated fixed part", input: enc[:10], val: &fixedVarVarFixed{}, want: "truncated"},
+		{name: "truncated fixed-size value", input: make([]byte, 39), val: &fingerprintCheckpoint{}, want: "truncated"},
+		{name: "offsets out of order", input: swapped, val: &fixedVarVarFixed{}, want: "out of order"},
+		{name: "offset beyond input", input: beyond, val: &fixedVarVarFixed{}, want: "truncated"},
+		{name: "empty list", input: nil, val: &[]uint64{}, want: "no data"},
+	}
+	for _, tt := range tests {
+		err := NewDecoder(bytes.NewReader(tt.input)).Decode(tt.val)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, received %v", tt.name, tt.want, err)
+		}
+		if _, ok := err.(*Error); !ok {
+			t.Errorf("%s: expected an *Error, received %T", tt.name, err)
+		}
+	}
+}
+
+func TestDecoder_VariableFieldPositions(t *testing.T) {
+	type varFirst struct {
+		Data  []byte
+		Slot  uint64
+		Epoch uint16
+	}
+	type varLast struct {
+		Slot  uint64
+		Epoch uint16
+		Data  []byte
+	}
+	type varEverywhere struct {
+		Head []byte
+		Slot uint32
+		Mid  []uint16
+		Flag bool
+		Tail []byte
+	}
+	tests := []struct {
+		name string
+		// enc is written out by hand, with the offsets of the variable-size
+		// fields at the byte positions of their fields in the fixed part.
+		enc  string
+		want interface{}
+	}{
+		{
+			name: "first",
+			enc:  "0e000000" + "0500000000000000" + "0700" + "010203",
+			want: &varFirst{Data: []byte{1, 2, 3}, Slot: 5, Epoch: 7},
+		},
+		{
+			name: "middle",
+			enc:  "0500000000000000" + "14000000" + "0700000000000000" + "010203",
+			want: &fixedVarFixed{Slot: 5, Data: []byte{1, 2, 3}, Epoch: 7},
+		},
+		{
+			name: "last",
+			enc:  "0500000000000000" + "0700" + "0e000000" + "010203",
+			want: &varLast{Slot: 5, Epoch: 7, Data: []byte{1, 2, 3}},
+		},
+		{
+			name: "first, middle and last",
+			enc:  "11000000" + "09000000" + "13000000" + "01" + "17000000" + "aabb" + "01000200" + "cc",
+			want: &varEverywhere{Head: []byte{0xaa, 0xbb}, Slot: 9, Mid: []uint16{1, 2}, Flag: true, Tail: []byte{0xcc}},
+		},
+	}
+	for _, tt := range tests {
+		enc, err := hex.DecodeString(tt.enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := reflect.New(reflect.TypeOf(tt.want).Elem()).Interface()
+		if err := NewDecoder(bytes.NewReader(enc)).Decode(got); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %+v, received %+v", tt.name, tt.want, got)
+		}
+		unmarshaled := reflect.New(reflect.TypeOf(tt.want).Elem()).Interface()
+		if err := Unmarshal(enc, unmarshaled); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(unmarshaled, tt.want) {
+			t.Errorf("%s: expected Unmarshal to decode %+v, received %+v", tt.name, tt.want, unmarshaled)
+		}
+	}
+}
@@ -2,184 +2,19 @@ package ssz
 
 import (
 	"reflect"
-	"unsafe"
-)
-
-// During deepValueEqual, must keep track of checks that are
-// in progress. The comparison algorithm assumes that all
-// checks in progress are true when it reencounters them.
-// Visited comparisons are stored in a map indexed by visit.
-type visit struct {
-	a1  unsafe.Pointer
-	a2  unsafe.Pointer
-	typ reflect.Type
-}
-
-// Copyright 2009 The Go Authors. All rights reserved.
-// Use of this source code is governed by a BSD-style
-// license that can be found in the LICENSE file.
-//
-// This file extends Go's reflect.DeepEqual function into a ssz.DeepEqual
-// function that is compliant with the supported types of ssz and its
-// intricacies when determining equality of empty values.
-//
-// Tests for deep equality using reflected types. The map argument tracks
-// comparisons that have already been seen, which allows short circuiting on
-// recursive types.
-func deepValueEqual(v1, v2 reflect.Value, visited map[visit]bool, depth int) bool {
-	if !v1.IsValid() || !v2.IsValid() {
-		return v1.IsValid() == v2.IsValid()
-	}
-	if v1.Type() != v2.Type() {
-		return false
-	}
-
-	// if depth > 10 { panic("deepValueEqual") }	// for debugging
-
-	// We want to avoid putting more in the visited map than we need to.
-	// For any possible reference cycle that might be encountered,
-	// hard(t) needs to return true for at least one of the types in the cycle.
-	hard := func(k reflect.Kind) bool {
-		switch k {
-		case reflect.Slice, reflect.Ptr, reflect.Interface:
-			return true
-		}
-		return false
-	}
-
-	if v1.CanAddr() && v2.CanAddr() && hard(v1.Kind()) {
-		addr1 := unsafe.Pointer(v1.UnsafeAddr())
-		addr2 := unsafe.Pointer(v2.UnsafeAddr())
-		if uintptr(addr1) > uintptr(addr2) {
-			// Canonicalize order to reduce number of entries in visited.
-			// Assumes non-moving garbage collector.
-			addr1, addr2 = addr2, addr1
-		}
-
-		// Short circuit if references are already seen.
-		typ := v1.Type()
-		v := visit{addr1, addr2, typ}
-		if visited[v] {
-			return true
-		}
-
-		// Remember for later.
-		visited[v] = true
-	}
 
-	switch v1.Kind() {
-	case reflect.String:
-		return v1.String() == v2.String()
-	case reflect.Array:
-		for i := 0; i < v1.Len(); i++ {
-			if !deepValueEqual(v1.Index(i), v2.Index(i), visited, depth+1) {
-				return false
-			}
-		}
-		return true
-	case reflect.Slice:
-		if v1.IsNil() && v2.Len() == 0 {
-			return true
-		}
-		if v1.Len() == 0 && v2.IsNil() {
-			return true
-		}
-		if v1.IsNil() && v2.IsNil() {
-			return true
-		}
-		if v1.Len() != v2.Len() {
-			return false
-		}
-		if v1.Pointer() == v2.Pointer() {
-			return true
-		}
-		for i := 0; i < v1.Len(); i++ {
-			if !deepValueEqual(v1.Index(i), v2.Index(i), visited, depth+1) {
-				return false
-			}
-		}
-		return true
-	case reflect.Interface:
-		if v1.IsNil() || v2.IsNil() {
-			return v1.IsNil() == v2.IsNil()
-		}
-		return deepValueEqual(v1.Elem(), v2.Elem(), visited, depth+1)
-	case reflect.Ptr:
-		if v1.Pointer() == v2.Pointer() {
-			return true
-		}
-		return deepValueEqual(v1.Elem(), v2.Elem(), visited, depth+1)
-	case reflect.Struct:
-		for i, n := 0, v1.NumField(); i < n; i++ {
-			if !deepValueEqual(v1.Field(i), v2.Field(i), visited, depth+1) {
-				return false
-			}
-		}
-		return true
-	case reflect.Uint64:
-		return v1.Interface().(uint64) == v2.Interface().(uint64)
-	case reflect.Uint32:
-		return v1.Interface().(uint32) == v2.Interface().(uint32)
-	case reflect.Int32:
-		return v1.Interface().(int32) == v2.Interface().(int32)
-	case reflect.Uint16:
-		return v1.Interface().(uint16) == v2.Interface().(uint16)
-	case reflect.Uint8:
-		return v1.Interface().(uint8) == v2.Interface().(uint8)
-	case reflect.Bool:
-		return v1.Interface().(bool) == v2.Interface().(bool)
-	default:
-		return false
-	}
-}
+	"github.com/524119574/go-ssz/types"
+)
 
-// DeepEqual reports whether two SSZ-able values x and y are ``deeply equal,'' defined as follows:
-// Two values of identical type are deeply equal if one of the following cases applies:
-//
-// Values of distinct types are never deeply equal.
-//
-// Array values are deeply equal when their corresponding elements are deeply equal.
-//
-// Struct values are deeply equal if their corresponding fields,
-// both exported and unexported, are deeply equal.
-//
-// Interface values are deeply equal if they hold deeply equal concrete values.
-//
-// Pointer values are deeply equal if they are equal using Go's == operator
-// or if they point to deeply equal values.
-//
-// Slice values are deeply equal when all of the following are true:
-// they are both nil, one is nil and the other is empty or vice-versa,
-// they have the same length, and either they point to the same initial entry of the same array
-// (that is, &x[0] == &y[0]) or their corresponding elements (up to length) are deeply equal.
-//
-// Other values - numbers, bools, strings, and channels - are deeply equal
-// if they are equal using Go's == operator.
-//
-// In general DeepEqual is a recursive relaxation of Go's == operator.
-// However, this idea is impossible to implement without some inconsistency.
-// Specifically, it is possible for a value to be unequal to itself,
-// either because it is of func type (uncomparable in general)
-// or because it is a floating-point NaN value (not equal to itself in floating-point comparison),
-// or because it is an array, struct, or interface containing
-// such a value.
-//
-// On the other hand, pointer values are always equal to themselves,
-// even if they point at or contain such problematic values,
-// because they compare equal using Go's == operator, and that
-// is a sufficient condition to be deeply equal, regardless of content.
-// DeepEqual has been defined so that the same short-cut applies
-// to slices and maps: if x and y are the same slice or the same map,
-// they are deeply equal regardless of content.
-//
-// As DeepEqual traverses the data values it may find a cycle. The
-// second and subsequent times that DeepEqual compares two pointer
-// values that have been compared before, it treats the values as
-// equal rather than examining the values to which they point.
-// This ensures that DeepEqual terminates.
-//
-// Credits go to the Go team as this is an extension of the official Go source code's
-// reflect.DeepEqual function to handle special SSZ edge cases.
+// DeepEqual reports whether x and y, values of the same type, are equal under
+// SSZ semantics, which is whether they have the same encoding. Unlike
+// reflect.DeepEqual, it treats nil and empty slices as equal, as well as nil
+// pointers and pointers to zero values, and ignores the fields which are not
+// serialized, such as the XXX_ fields of protobuf types and fields tagged
+// ssz:"-". The values are walked and compared field by field without being
+// encoded, except for types with encodings of their own, such as aliases,
+// unions and types with codecs or Marshaler methods, whose encodings are
+// compared. Values of distinct types are never equal.
 func DeepEqual(x, y interface{}) bool {
 	if x == nil || y == nil {
 		return x == y
@@ -189,5 +24,5 @@ func DeepEqual(x, y interface{}) bool {
 	if v1.Type() != v2.Type() {
 		return false
 	}
-	return deepValueEqual(v1, v2, make(map[visit]bool), 0)
+	return types.DeepEqual(v1, v2)
 }
@@ -0,0 +1,145 @@
+package ssz
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+type deepEqualBody struct {
+	Epoch uint64
+}
+
+type deepEqualBlock struct {
+	Slot     uint64
+	Graffiti []byte   `ssz-max:"32"`
+	Roots    [][]byte `ssz-size:"2,4"`
+	Body     *deepEqualBody
+	Bits     Bitlist `ssz-max:"8"`
+	Name     string  `ssz-max:"8"`
+	Balance  BigUint256
+
+	XXX_unrecognized []byte
+	Cached           uint64 `ssz:"-"`
+}
+
+func TestDeepEqual(t *testing.T) {
+	tests := []struct {
+		name string
+		x, y interface{}
+		want bool
+	}{
+		{
+			name: "NilAndEmptySlices",
+			x:    &deepEqualBlock{Slot: 5},
+			y:    &deepEqualBlock{Slot: 5, Graffiti: []byte{}, Roots: [][]byte{}},
+			want: true,
+		},
+		{
+			name: "NilAndZeroVectors",
+			x:    deepEqualBlock{},
+			y:    deepEqualBlock{Roots: [][]byte{{0, 0, 0, 0}, {0, 0, 0, 0}}},
+			want: true,
+		},
+		{
+			name: "NilPointerAndZeroValue",
+			x:    &deepEqualBlock{Body: nil},
+			y:    &deepEqualBlock{Body: &deepEqualBody{}},
+			want: true,
+		},
+		{
+			name: "NilPointers",
+			x:    (*deepEqualBlock)(nil),
+			y:    &deepEqualBlock{Bits: Bitlist{0x01}},
+			want: true,
+		},
+		{
+			name: "IgnoredFields",
+			x:    deepEqualBlock{XXX_unrecognized: []byte{1}, Cached: 1},
+			y:    deepEqualBlock{XXX_unrecognized: []byte{2}, Cached: 2},
+			want: true,
+		},
+		{
+			name: "AliasesOfEqualValues",
+			x:    deepEqualBlock{Balance: BigUint256{Int: big.NewInt(7)}},
+			y:    deepEqualBlock{Balance: BigUint256{Int: big.NewInt(7)}},
+			want: true,
+		},
+		{
+			name: "NilAndZeroAlias",
+			x:    deepEqualBlock{Balance: BigUint256{}},
+			y:    deepEqualBlock{Balance: BigUint256{Int: big.NewInt(0)}},
+			want: true,
+		},
+		{
+			name: "DifferentAliases",
+			x:    deepEqualBlock{Balance: BigUint256{Int: big.NewInt(7)}},
+			y:    deepEqualBlock{Balance: BigUint256{Int: big.NewInt(8)}},
+			want: false,
+		},
+		{
+			name: "DifferentLengths",
+			x:    &deepEqualBlock{Graffiti: []byte{1}},
+			y:    &deepEqualBlock{Graffiti: []byte{1, 0}},
+			want: false,
+		},
+		{
+			name: "DifferentNestedValues",
+			x:    &deepEqualBlock{Body: &deepEqualBody{Epoch: 1}},
+			y:    &deepEqualBlock{Body: &deepEqualBody{Epoch: 2}},
+			want: false,
+		},
+		{
+			name: "DifferentBits",
+			x:    &deepEqualBlock{Bits: Bitlist{0x05}},
+			y:    &deepEqualBlock{Bits: Bitlist{0x06}},
+			want: false,
+		},
+		{
+			name: "Unions",
+			x:    unionPayload{Selector: 3, Value: &unionMemo{Text: []byte{}}},
+			y:    unionPayload{Selector: 3, Value: &unionMemo{}},
+			want: true,
+		},
+		{
+			name: "DifferentSelectors",
+			x:    unionPayload{Selector: 0},
+			y:    unionPayload{Selector: 1, Value: uint64(0)},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeepEqual(tt.x, tt.y); got != tt.want {
+				t.Errorf("DeepEqual returned %v, expected %v", got, tt.want)
+			}
+			if got := DeepEqual(tt.y, tt.x); got != tt.want {
+				t.Errorf("DeepEqual with swapped arguments returned %v, expected %v", got, tt.want)
+			}
+			// Values are equal exactly when their encodings are.
+			encX, err := Marshal(tt.x)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encY, err := Marshal(tt.y)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(encX, encY) != tt.want {
+				t.Errorf("Encodings %#x and %#x disagree with DeepEqual", encX, encY)
+			}
+		})
+	}
+}
+
+func TestDeepEqual_DistinctTypes(t *testing.T) {
+	if DeepEqual(uint64(1), uint32(1)) {
+		t.Error("Expected values of distinct types to differ")
+	}
+	if DeepEqual(nil, &deepEqualBlock{}) {
+		t.Error("Expected untyped nil to differ from a typed value")
+	}
+	if !DeepEqual(nil, nil) {
+		t.Error("Expected untyped nils to be equal")
+	}
+}
@@ -0,0 +1,228 @@
+package ssz
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/524119574/go-ssz/types"
+)
+
+// The fixtures below mirror the shapes of the eth1 data and deposits of the
+// beacon chain, whose proofs of 33 roots sit right before a fixed size container.
+// Their golden encodings in testdata and roots were generated with fastssz.
+
+type eth1DataFixture struct {
+	DepositRoot  [32]byte
+	DepositCount uint64
+	BlockHash    [32]byte
+}
+
+type depositDataFixture struct {
+	Pubkey                [48]byte
+	WithdrawalCredentials [32]byte
+	Amount                uint64
+	Signature             [96]byte
+}
+
+type depositFixture struct {
+	Proof [33][32]byte
+	Data  depositDataFixture
+}
+
+type depositBlockFixture struct {
+	Eth1Data eth1DataFixture
+	Graffiti [32]byte
+	Deposits []depositFixture `ssz-max:"16"`
+}
+
+// The same shapes, with byte slices and ssz-size tags as in generated
+// protobuf types.
+
+type taggedEth1DataFixture struct {
+	DepositRoot  []byte `ssz-size:"32"`
+	DepositCount uint64
+	BlockHash    []byte `ssz-size:"32"`
+}
+
+type taggedDepositDataFixture struct {
+	Pubkey                []byte `ssz-size:"48"`
+	WithdrawalCredentials []byte `ssz-size:"32"`
+	Amount                uint64
+	Signature             []byte `ssz-size:"96"`
+}
+
+type taggedDepositFixture struct {
+	Proof [][]byte `ssz-size:"33,32"`
+	Data  *taggedDepositDataFixture
+}
+
+type taggedDepositBlockFixture struct {
+	Eth1Data *taggedEth1DataFixture
+	Graffiti []byte                  `ssz-size:"32"`
+	Deposits []*taggedDepositFixture `ssz-max:"16"`
+}
+
+func fillFixtureBytes(b []byte, seed byte) {
+	for i := range b {
+		b[i] = seed + byte(i)
+	}
+}
+
+func newEth1DataFixture() eth1DataFixture {
+	var e eth1DataFixture
+	fillFixtureBytes(e.DepositRoot[:], 0x01)
+	e.DepositCount = 16384
+	fillFixtureBytes(e.BlockHash[:], 0x21)
+	return e
+}
+
+func newDepositFixture(seed byte) depositFixture {
+	var d depositFixture
+	for i := range d.Proof {
+		fillFixtureBytes(d.Proof[i][:], seed+byte(i))
+	}
+	fillFixtureBytes(d.Data.Pubkey[:], seed+0x40)
+	fillFixtureBytes(d.Data.WithdrawalCredentials[:], seed+0x80)
+	d.Data.Amount = 32000000000 + uint64(seed)
+	fillFixtureBytes(d.Data.Signature[:], seed+0xc0)
+	return d
+}
+
+func newDepositBlockFixture() depositBlockFixture {
+	b := depositBlockFixture{
+		Eth1Data: newEth1DataFixture(),
+		Deposits: []depositFixture{newDepositFixture(7), newDepositFixture(9)},
+	}
+	fillFixtureBytes(b.Graffiti[:], 0x55)
+	return b
+}
+
+func taggedEth1Data(e eth1DataFixture) *taggedEth1DataFixture {
+	return &taggedEth1DataFixture{
+		DepositRoot:  append([]byte{}, e.DepositRoot[:]...),
+		DepositCount: e.DepositCount,
+		BlockHash:    append([]byte{}, e.BlockHash[:]...),
+	}
+}
+
+func taggedDeposit(d depositFixture) *taggedDepositFixture {
+	tagged := &taggedDepositFixture{
+		Proof: make([][]byte, len(d.Proof)),
+		Data: &taggedDepositDataFixture{
+			Pubkey:                append([]byte{}, d.Data.Pubkey[:]...),
+			WithdrawalCredentials: append([]byte{}, d.Data.WithdrawalCredentials[:]...),
+			Amount:                d.Data.Amount,
+			Signature:             append([]byte{}, d.Data.Signature[:]...),
+		},
+	}
+	for i := range d.Proof {
+		tagged.Proof[i] = append([]byte{}, d.Proof[i][:]...)
+	}
+	return tagged
+}
+
+func taggedDepositBlock(b depositBlockFixture) *taggedDepositBlockFixture {
+	tagged := &taggedDepositBlockFixture{
+		Eth1Data: taggedEth1Data(b.Eth1Data),
+		Graffiti: append([]byte{}, b.Graffiti[:]...),
+		Deposits: make([]*taggedDepositFixture, len(b.Deposits)),
+	}
+	for i, d := range b.Deposits {
+		tagged.Deposits[i] = taggedDeposit(d)
+	}
+	return tagged
+}
+
+func TestDepositShapes_GoldenVectors(t *testing.T) {
+	block := newDepositBlockFixture()
+	deposit := newDepositFixture(3)
+	tests := []struct {
+		name   string
+		file   string
+		root   string
+		val    interface{}
+		tagged interface{}
+		size   uint64
+	}{
+		{
+			name:   "eth1 data",
+			file:   "eth1_data.ssz",
+			root:   "6274ee7a1f074f2f662dd0313b873e30a850b8a4cd51af17eee3ccf70c2e0bbe",
+			val:    block.Eth1Data,
+			tagged: taggedEth1Data(block.Eth1Data),
+			size:   72,
+		},
+		{
+			name:   "deposit",
+			file:   "deposit.ssz",
+			root:   "526c16665068cb7ff8673da9c45aa34327138842a2df3b560c2f6ac89e54413b",
+			val:    deposit,
+			tagged: taggedDeposit(deposit),
+			size:   33*32 + 184,
+		},
+		{
+			name:   "block with deposits",
+			file:   "deposit_block.ssz",
+			root:   "8e7c559f1adc9663261876a3ba18d1774de67d48fd8eb846cea95c9dbefb3777",
+			val:    block,
+			tagged: taggedDepositBlock(block),
+			size:   72 + 32 + 4 + 2*(33*32+184),
+		},
+	}
+	for _, tt := range tests {
+		want, err := ioutil.ReadFile(filepath.Join("testdata", tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantRoot, err := hex.DecodeString(tt.root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uint64(len(want)) != tt.size {
+			t.Fatalf("%s: golden encoding holds %d bytes, expected %d", tt.name, len(want), tt.size)
+		}
+		for _, val := range []interface{}{tt.val, tt.tagged} {
+			typ := reflect.TypeOf(val)
+			if size := types.DetermineSize(reflect.ValueOf(val)); size != tt.size {
+				t.Errorf("%s: expected %v to have size %d, received %d", tt.name, typ, tt.size, size)
+			}
+			enc, err := Marshal(val)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if !bytes.Equal(enc, want) {
+				t.Errorf("%s: expected %v to encode as %#x, received %#x", tt.name, typ, want, enc)
+			}
+			// Hashing twice with caching enabled checks the cached layers too.
+			for _, cached := range []bool{false, true, true} {
+				types.ToggleCache(cached)
+				root, err := HashTreeRoot(val)
+				types.ToggleCache(false)
+				if err != nil {
+					t.Fatalf("%s: %v", tt.name, err)
+				}
+				if !bytes.Equal(root[:], wantRoot) {
+					t.Errorf("%s: expected %v to have root %#x with caching %v, received %#x", tt.name, typ, wantRoot, cached, root)
+				}
+			}
+			decoded := reflect.New(reflect.Indirect(reflect.ValueOf(val)).Type())
+			if err := Unmarshal(want, decoded.Interface()); err != nil {
+				t.Fatalf("%s: %v: %v", tt.name, typ, err)
+			}
+			if !reflect.DeepEqual(decoded.Elem().Interface(), reflect.Indirect(reflect.ValueOf(val)).Interface()) {
+				t.Errorf("%s: expected %#x to decode as %v, received %v", tt.name, want, val, decoded.Interface())
+			}
+		}
+	}
+}
+
+func TestDepositShapes_ZeroDeposit(t *testing.T) {
+	// A nil proof and nil data hash as zeroed values of their sizes.
+	for _, val := range []interface{}{depositFixture{}, &taggedDepositFixture{}} {
+		AssertRootMatches(t, val, "0xa14b699cfcbfe24befcdd2c8bfd6a9ed5c4a9c167af373bf02dafb6ff664c2c8")
+	}
+}
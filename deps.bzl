@@ -142,6 +142,14 @@ def go_ssz_dependencies():
         importpath = "github.com/ferranbt/fastssz",
     )
 
+    _maybe(
+        go_repository,
+        name = "com_github_golang_snappy",
+        importpath = "github.com/golang/snappy",
+        sum = "h1:ur2rms48b3Ep1dxh7aUV2FZEQ8jEVO2F6ILKx8ofkAg=",
+        version = "v0.0.3-0.20201103224600-674baa8c7fc3",
+    )
+
 def _maybe(repo_rule, name, **kwargs):
     if name not in native.existing_rules():
         repo_rule(name = name, **kwargs)
@@ -0,0 +1,73 @@
+package ssz
+
+import (
+	"io"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"github.com/524119574/go-ssz/types"
+)
+
+// Encoder writes the SSZ encodings of values to an io.Writer. Unlike Marshal,
+// which holds the whole encoding of a value in a single buffer, it writes
+// containers field by field and lists element by element through a buffer of
+// bounded size, so that values such as beacon states of hundreds of megabytes
+// can be written to a file or a connection as they are encoded:
+//
+//  f, err := os.Create("state.ssz")
+//  if err != nil {
+//      return err
+//  }
+//  defer f.Close()
+//  if err := ssz.NewEncoder(f).Encode(state); err != nil {
+//      return err
+//  }
+//
+// The bytes written are identical to those returned by Marshal.
+type Encoder struct {
+	enc *types.StreamEncoder
+}
+
+// NewEncoder returns an Encoder writing to w through a buffer of
+// types.DefaultStreamBufferSize bytes.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{enc: types.NewStreamEncoder(w, types.DefaultStreamBufferSize)}
+}
+
+// NewEncoderSize returns an Encoder writing to w through a buffer of size
+// bytes, which bounds the memory it holds for the encodings of containers and
+// lists while writing them. The buffer never grows: the offsets of
+// variable-size fields and elements are computed from their sizes before they
+// are written, so their encodings are not held back until the offsets are
+// known. Larger buffers make for fewer and larger writes.
+func NewEncoderSize(w io.Writer, size int) (*Encoder, error) {
+	if size <= 0 {
+		return nil, errors.Errorf("buffer size of an encoder must be positive, received %d", size)
+	}
+	return &Encoder{enc: types.NewStreamEncoder(w, size)}, nil
+}
+
+// Encode writes the encoding of val to the writer. When the writer fails, the
+// returned *Error holds the path of the field being written at the time, and
+// its cause is the error of the writer, after which Encode keeps returning it.
+func (e *Encoder) Encode(val interface{}) error {
+	err := e.encode(val)
+	if writeErr, ok := err.(*types.WriteError); ok {
+		return &Error{Op: OpMarshal, Type: reflect.TypeOf(val), Path: writeErr.Path, Offset: -1, Err: writeErr.Err}
+	}
+	return newError(OpMarshal, val, err)
+}
+
+func (e *Encoder) encode(val interface{}) error {
+	if val == nil {
+		return errors.New("untyped-value nil cannot be marshaled")
+	}
+	if v, ok := val.(Marshaler); ok {
+		enc, err := v.MarshalSSZ()
+		if err != nil {
+			return err
+		}
+		return e.enc.WriteEncoding(enc)
+	}
+	return e.enc.Encode(reflect.ValueOf(val))
+}
@@ -0,0 +1,196 @@
+package ssz
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// largeStateFixture is larger than the buffers of Encoder, so that it is written
+// field by field, and its encoding spans a few megabytes.
+type largeStateFixture struct {
+	Slot       uint64
+	BlockRoots [8192][32]byte
+	StateRoots [65536][32]byte
+	Balances   []uint64 `ssz-max:"1099511627776"`
+	Deposits   []depositFixture
+	Graffiti   []byte
+	Blocks     []*taggedDepositBlockFixture
+}
+
+func newLargeStateFixture() *largeStateFixture {
+	s := &largeStateFixture{
+		Slot:     12345,
+		Balances: make([]uint64, 20000),
+		Graffiti: bytes.Repeat([]byte{0x77}, 100000),
+	}
+	for i := range s.BlockRoots {
+		fillFixtureBytes(s.BlockRoots[i][:], byte(i))
+	}
+	for i := range s.StateRoots {
+		fillFixtureBytes(s.StateRoots[i][:], byte(i>>8))
+	}
+	for i := range s.Balances {
+		s.Balances[i] = 32000000000 + uint64(i)
+	}
+	for i := 0; i < 80; i++ {
+		s.Deposits = append(s.Deposits, newDepositFixture(byte(i)))
+	}
+	for i := 0; i < 40; i++ {
+		block := newDepositBlockFixture()
+		for j := 0; j < i; j++ {
+			block.Deposits = append(block.Deposits, newDepositFixture(byte(j)))
+		}
+		s.Blocks = append(s.Blocks, taggedDepositBlock(block))
+	}
+	return s
+}
+
+// failingWriter fails once it is given more than limit bytes in total.
+type failingWriter struct {
+	written int
+	limit   int
+}
+
+var errWriterFull = errors.New("writer is full")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.written+len(p) > w.limit {
+		return 0, errWriterFull
+	}
+	w.written += len(p)
+	return len(p), nil
+}
+
+func TestEncoder_MatchesMarshal(t *testing.T) {
+	block := newDepositBlockFixture()
+	values := []interface{}{
+		uint64(7),
+		[]uint64{1, 2, 3},
+		&fingerprintCheckpoint{Epoch: 3, Root: [32]byte{1}},
+		block,
+		taggedDepositBlock(block),
+		(*depositBlockFixture)(nil),
+		newLargeStateFixture(),
+		*newLargeStateFixture(),
+	}
+	for _, fixture := range interleavedFieldFixtures {
+		values = append(values, fixture.value)
+	}
+	for _, val := range values {
+		want, err := Marshal(val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode(val); err != nil {
+			t.Fatalf("Could not encode %T: %v", val, err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("Expected the encoding of %T to match Marshal, received %d bytes instead of %d", val, buf.Len(), len(want))
+		}
+	}
+}
+
+// sizedWriter records the sizes of the writes it is given.
+type sizedWriter struct {
+	bytes.Buffer
+	sizes []int
+}
+
+func (w *sizedWriter) Write(p []byte) (int, error) {
+	w.sizes = append(w.sizes, len(p))
+	return w.Buffer.Write(p)
+}
+
+func TestNewEncoderSize_MatchesMarshal(t *testing.T) {
+	val := newLargeStateFixture()
+	want, err := Marshal(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{64, 4096} {
+		var w sizedWriter
+		enc, err := NewEncoderSize(&w, size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Encode(val); err != nil {
+			t.Fatalf("Could not encode with a buffer of %d bytes: %v", size, err)
+		}
+		if !bytes.Equal(w.Bytes(), want) {
+			t.Errorf("Expected the encoding with a buffer of %d bytes to match Marshal, received %d bytes instead of %d", size, w.Len(), len(want))
+		}
+		// Only the graffiti, written straight from the value, exceeds the
+		// buffer.
+		for _, n := range w.sizes {
+			if n > size && n != len(val.Graffiti) {
+				t.Errorf("Expected writes through a buffer of %d bytes to be at most as large, received a write of %d bytes", size, n)
+				break
+			}
+		}
+	}
+	for _, size := range []int{0, -1} {
+		if _, err := NewEncoderSize(&bytes.Buffer{}, size); err == nil {
+			t.Errorf("Expected a buffer size of %d to be rejected", size)
+		}
+	}
+}
+
+func TestEncoder_EncodesConsecutiveValues(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	first := &fingerprintCheckpoint{Epoch: 1}
+	second := &generatedCheckpoint{Epoch: 2}
+	if err := enc.Encode(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(second); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(&struct{ Foo complex128 }{}); err == nil {
+		t.Error("Expected an unsupported type to be rejected")
+	}
+	firstEnc, err := Marshal(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondEnc, err := Marshal(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(firstEnc, secondEnc...); !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("Expected %#x, received %#x", want, buf.Bytes())
+	}
+}
+
+func TestEncoder_WriterFailure(t *testing.T) {
+	val := newLargeStateFixture()
+	typ := reflect.TypeOf(val)
+	tests := []struct {
+		limit int
+		path  string
+	}{
+		// The slot is buffered until the first batch of block roots fills the buffer.
+		{limit: 0, path: "BlockRoots"},
+		{limit: 8 + 8192*32 + 65536*32 + 4*4 + 80000, path: "Balances"},
+	}
+	for _, tt := range tests {
+		enc := NewEncoder(&failingWriter{limit: tt.limit})
+		err := enc.Encode(val)
+		var sszErr *Error
+		if !errors.As(err, &sszErr) {
+			t.Fatalf("Expected an *Error, received %v", err)
+		}
+		if sszErr.Op != OpMarshal || sszErr.Type != typ || sszErr.Path != tt.path || !errors.Is(err, errWriterFull) {
+			t.Errorf("Expected a failure to write field %s, received %v", tt.path, err)
+		}
+		if err := enc.Encode(uint64(1)); !errors.Is(err, errWriterFull) {
+			t.Errorf("Expected the failure of the writer to be returned again, received %v", err)
+		}
+	}
+	if err := NewEncoder(&bytes.Buffer{}).Encode(nil); err == nil {
+		t.Error("Expected untyped nil to be rejected")
+	}
+}
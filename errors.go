@@ -0,0 +1,119 @@
+package ssz
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/524119574/go-ssz/types"
+)
+
+// Op is the operation an Error was returned by.
+type Op string
+
+// The operations reported by errors.
+const (
+	OpMarshal   Op = "marshal"
+	OpUnmarshal Op = "unmarshal"
+	OpHash      Op = "hash"
+)
+
+// Error is the error returned by Marshal, MarshalAt, Unmarshal and its variants,
+// TypedDecoder and the HashTreeRoot functions. It describes which operation
+// failed on which type and, where they are known, the path of the field and the
+// offset in the input at which it failed, while Err holds the cause. Errors such
+// as ErrBudgetExceeded or *ErrBufferTooSmall are matched through Err with
+// errors.Is and errors.As:
+//
+//  var tooSmall *ssz.ErrBufferTooSmall
+//  if errors.As(err, &tooSmall) {
+//      region = remap(tooSmall.Needed)
+//  }
+type Error struct {
+	Op Op
+	// Type is the type of the value given to the failing function, which is
+	// nil for untyped nil values.
+	Type reflect.Type
+	// Path is the path of the field which failed, such as "Body.Deposits" or
+	// "Body.Attestations[3].Data.Target", or empty when the failure concerns
+	// the value as a whole or the field is not known.
+	Path string
+	// Offset is the offset in the input of unmarshaling at which decoding
+	// failed, or -1 when it is not known.
+	Offset int64
+	Err    error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %v", e.Op, e.Type)
+	if e.Path != "" {
+		fmt.Fprintf(&b, " field %s", e.Path)
+	}
+	if e.Offset >= 0 {
+		fmt.Fprintf(&b, " at byte %d", e.Offset)
+	}
+	cause := e.Err
+	// The path of a field error is already part of the message.
+	if fieldErr, ok := cause.(*FieldError); ok && fieldErr.Path() == e.Path {
+		cause = fieldErr.Err
+	}
+	fmt.Fprintf(&b, ": %v", cause)
+	return b.String()
+}
+
+// Unwrap returns the cause of the error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// newError returns err as an *Error of the operation op on val, unless it is nil
+// or already one.
+func newError(op Op, val interface{}, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	sszErr := &Error{Op: op, Type: reflect.TypeOf(val), Offset: -1, Err: err}
+	if fieldErr, ok := err.(*FieldError); ok {
+		sszErr.Path = fieldErr.Path()
+	}
+	return sszErr
+}
+
+// FieldError is the cause of an Error concerning a field or element within the
+// value, whose Path locates it, such as "Body.Attestations[3].Data.Target". Err
+// holds the error the field failed with:
+//
+//  var fieldErr *ssz.FieldError
+//  if errors.As(err, &fieldErr) {
+//      log.Printf("field %s is invalid: %v", fieldErr.Path(), fieldErr.Err)
+//  }
+type FieldError = types.FieldError
+
+// The errors wrapped by the causes of unmarshaling failures, which tell
+// malformed input apart from programmer errors, such as values of types which
+// cannot be serialized. They are matched with errors.Is:
+//
+//  if errors.Is(err, ssz.ErrOffsetOutOfBounds) || errors.Is(err, ssz.ErrInputTooShort) {
+//      peer.Penalize()
+//  }
+var (
+	// ErrOffsetOutOfBounds is wrapped when an offset points beyond the input
+	// or inside the part of the encoding preceding the variable-size values.
+	ErrOffsetOutOfBounds = types.ErrOffsetOutOfBounds
+	// ErrOffsetsNotIncreasing is wrapped when the offset of a variable-size
+	// value precedes the offset of the value before it.
+	ErrOffsetsNotIncreasing = types.ErrOffsetsNotIncreasing
+	// ErrInputTooShort is wrapped when the input ends before the value it
+	// holds, or holds no value at all.
+	ErrInputTooShort = types.ErrInputTooShort
+	// ErrSizeMismatch is wrapped when the size of the input does not match
+	// the size of the value decoded from it, such as when bytes are left over.
+	ErrSizeMismatch = types.ErrSizeMismatch
+	// ErrUnsupportedType is wrapped when a value is of a type which cannot be
+	// serialized.
+	ErrUnsupportedType = types.ErrUnsupportedType
+)
@@ -0,0 +1,364 @@
+package ssz
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type pathCheckpoint struct {
+	Epoch     uint64
+	Root      [32]byte
+	Finalized bool
+}
+
+type pathAttestationData struct {
+	Slot   uint64
+	Target *pathCheckpoint
+}
+
+type pathAttestation struct {
+	Bits []byte `ssz-max:"8"`
+	Data *pathAttestationData
+}
+
+type pathBody struct {
+	Attestations []*pathAttestation `ssz-max:"16"`
+}
+
+type pathBlock struct {
+	Slot uint64
+	Body *pathBody
+}
+
+// checkError verifies err is an *Error of the operation op on a value of type
+// typ, caused by an error with the message cause, within one of its fields or
+// not.
+func checkError(t *testing.T, err error, op Op, typ reflect.Type, cause string) {
+	t.Helper()
+	sszErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Expected an *Error, received %T: %v", err, err)
+	}
+	if sszErr.Op != op || sszErr.Type != typ {
+		t.Errorf("Expected an error of %s on %v, received %s on %v", op, typ, sszErr.Op, sszErr.Type)
+	}
+	received := sszErr.Err
+	if fieldErr, ok := received.(*FieldError); ok {
+		received = fieldErr.Err
+	}
+	if received == nil || received.Error() != cause {
+		t.Errorf("Expected error caused by %q, received %v", cause, received)
+	}
+}
+
+func TestError_Error(t *testing.T) {
+	typ := reflect.TypeOf(&fingerprintCheckpoint{})
+	cause := errors.New("offset out of bounds")
+	tests := []struct {
+		err  *Error
+		want string
+	}{
+		{
+			err:  &Error{Op: OpMarshal, Type: typ, Offset: -1, Err: cause},
+			want: "marshal *ssz.fingerprintCheckpoint: offset out of bounds",
+		},
+		{
+			err:  &Error{Op: OpUnmarshal, Type: typ, Path: "Root", Offset: 8, Err: cause},
+			want: "unmarshal *ssz.fingerprintCheckpoint field Root at byte 8: offset out of bounds",
+		},
+		{
+			err:  &Error{Op: OpHash, Offset: -1, Err: cause},
+			want: "hash <nil>: offset out of bounds",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Expected %q, received %q", tt.want, got)
+		}
+	}
+}
+
+func TestError_ReturnedByEntryPoints(t *testing.T) {
+	decoder, err := NewTypedDecoder(&fingerprintCheckpoint{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsupported := &struct{ Foo complex128 }{}
+	unsupportedTyp := reflect.TypeOf(unsupported)
+	checkpointTyp := reflect.TypeOf(&fingerprintCheckpoint{})
+	short := []byte{1, 2, 3}
+	tests := []struct {
+		name string
+		call func() error
+		op   Op
+		typ  reflect.Type
+	}{
+		{
+			name: "Marshal",
+			call: func() error { _, err := Marshal(unsupported); return err },
+			op:   OpMarshal,
+			typ:  unsupportedTyp,
+		},
+		{
+			name: "MarshalAt",
+			call: func() error { _, err := MarshalAt(make([]byte, 8), unsupported); return err },
+			op:   OpMarshal,
+			typ:  unsupportedTyp,
+		},
+		{
+			name: "MarshalWithFingerprint",
+			call: func() error { _, err := MarshalWithFingerprint(unsupported); return err },
+			op:   OpMarshal,
+			typ:  unsupportedTyp,
+		},
+		{
+			name: "IsZeroValue",
+			call: func() error { _, err := IsZeroValue(unsupported); return err },
+			op:   OpMarshal,
+			typ:  unsupportedTyp,
+		},
+		{
+			name: "Unmarshal",
+			call: func() error { return Unmarshal(short, &fingerprintCheckpoint{}) },
+			op:   OpUnmarshal,
+			typ:  checkpointTyp,
+		},
+		{
+			name: "UnmarshalWithOptions",
+			call: func() error { return UnmarshalWithOptions(short, &fingerprintCheckpoint{}, UnmarshalOptions{}) },
+			op:   OpUnmarshal,
+			typ:  checkpointTyp,
+		},
+		{
+			name: "UnmarshalWithFingerprint",
+			call: func() error { return UnmarshalWithFingerprint(short, &fingerprintCheckpoint{}) },
+			op:   OpUnmarshal,
+			typ:  checkpointTyp,
+		},
+		{
+			name: "TypedDecoder.Decode",
+			call: func() error { return decoder.Decode(short, &fingerprintCheckpoint{}) },
+			op:   OpUnmarshal,
+			typ:  checkpointTyp,
+		},
+		{
+			name: "TypedDecoder.DecodeNew",
+			call: func() error { _, err := decoder.DecodeNew(short); return err },
+			op:   OpUnmarshal,
+			typ:  checkpointTyp,
+		},
+		{
+			name: "HashTreeRoot",
+			call: func() error { _, err := HashTreeRoot(unsupported); return err },
+			op:   OpHash,
+			typ:  unsupportedTyp,
+		},
+		{
+			name: "HashTreeRootWithCapacity",
+			call: func() error { _, err := HashTreeRootWithCapacity([]complex128{1}, 4); return err },
+			op:   OpHash,
+			typ:  reflect.TypeOf([]complex128{}),
+		},
+		{
+			name: "HashTreeRootWithLimits",
+			call: func() error { _, err := HashTreeRootWithLimits(unsupported, nil); return err },
+			op:   OpHash,
+			typ:  unsupportedTyp,
+		},
+		{
+			name: "ElementRoots",
+			call: func() error { _, err := ElementRoots([]complex128{1}); return err },
+			op:   OpHash,
+			typ:  reflect.TypeOf([]complex128{}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			sszErr, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("Expected an *Error, received %T: %v", err, err)
+			}
+			if sszErr.Op != tt.op || sszErr.Type != tt.typ || sszErr.Err == nil {
+				t.Errorf("Expected an error of %s on %v, received %+v", tt.op, tt.typ, sszErr)
+			}
+		})
+	}
+}
+
+func TestError_Offset(t *testing.T) {
+	enc, err := Marshal(&fingerprintCheckpoint{Epoch: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trailing := append(enc, 0xff, 0xff)
+	err = Unmarshal(trailing, &fingerprintCheckpoint{})
+	checkError(t, err, OpUnmarshal, reflect.TypeOf(&fingerprintCheckpoint{}), "unexpected amount of data, expected: 40, received: 42, leaving 2 trailing bytes: size mismatch")
+	if offset := err.(*Error).Offset; offset != 40 {
+		t.Errorf("Expected the trailing data to be reported at byte 40, received %d", offset)
+	}
+	fingerprinted, err := MarshalWithFingerprint(&fingerprintCheckpoint{Epoch: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = UnmarshalWithFingerprint(append(fingerprinted, 0xff), &fingerprintCheckpoint{})
+	if sszErr, ok := err.(*Error); !ok || sszErr.Offset != FingerprintSize+40 {
+		t.Errorf("Expected the trailing data to be reported at byte %d, received %v", FingerprintSize+40, err)
+	}
+	err = Unmarshal([]byte{1, 2, 3}, &fingerprintCheckpoint{})
+	if sszErr, ok := err.(*Error); !ok || sszErr.Offset != -1 {
+		t.Errorf("Expected no offset for an error within a codec, received %v", err)
+	}
+}
+
+func TestError_Unwrap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := UnmarshalWithOptions([]byte{1, 0, 0, 0, 0, 0, 0, 0}, new(uint64), UnmarshalOptions{Context: ctx})
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected the error to match ErrBudgetExceeded, received %v", err)
+	}
+	var sszErr *Error
+	if !errors.As(fmt.Errorf("decoding block: %w", err), &sszErr) || sszErr.Op != OpUnmarshal {
+		t.Errorf("Expected a wrapped *Error to be found, received %v", err)
+	}
+}
+
+// checkFieldError verifies err is an *Error caused by a *FieldError at the path
+// want, found by errors.As and rendered once in the message.
+func checkFieldError(t *testing.T, err error, want string) {
+	t.Helper()
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("Expected a *FieldError, received %T: %v", err, err)
+	}
+	if fieldErr.Path() != want {
+		t.Errorf("Expected the path %q, received %q", want, fieldErr.Path())
+	}
+	sszErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Expected an *Error, received %T: %v", err, err)
+	}
+	if sszErr.Path != want {
+		t.Errorf("Expected Error.Path %q, received %q", want, sszErr.Path)
+	}
+	if msg := err.Error(); !strings.Contains(msg, " field "+want+": ") || strings.Count(msg, want) != 1 {
+		t.Errorf("Expected the path %q to be rendered once, received %q", want, msg)
+	}
+}
+
+func TestError_FieldPath(t *testing.T) {
+	type unsupportedBody struct {
+		Slot  uint64
+		Extra map[string]uint64
+	}
+	type unsupportedBlock struct {
+		Body []unsupportedBody `ssz-max:"4"`
+	}
+	unsupported := &unsupportedBlock{Body: []unsupportedBody{{}, {Extra: map[string]uint64{}}}}
+	_, err := Marshal(unsupported)
+	checkFieldError(t, err, "Body[0].Extra")
+	_, err = HashTreeRoot(unsupported)
+	checkFieldError(t, err, "Body[0].Extra")
+	err = NewEncoder(ioutil.Discard).Encode(unsupported)
+	checkFieldError(t, err, "Body[0].Extra")
+
+	block := &pathBlock{Body: &pathBody{}}
+	for i := 0; i < 4; i++ {
+		block.Body.Attestations = append(block.Body.Attestations, &pathAttestation{
+			Bits: []byte{byte(i)},
+			Data: &pathAttestationData{Slot: uint64(i), Target: &pathCheckpoint{Epoch: uint64(i)}},
+		})
+	}
+	enc, err := Marshal(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The block holds its slot and the offset of its body, which holds the
+	// offset of the attestations, followed by their offset table.
+	const table = 8 + 4 + 4
+
+	invalidBool := append([]byte{}, enc...)
+	third := table + binary.LittleEndian.Uint32(enc[table+3*4:])
+	// Each attestation holds the offset of its bits, then the slot, epoch and
+	// root preceding the finalized flag.
+	invalidBool[third+4+8+8+32] = 2
+	err = Unmarshal(invalidBool, &pathBlock{})
+	checkFieldError(t, err, "Body.Attestations[3].Data.Target.Finalized")
+	err = NewDecoder(bytes.NewReader(invalidBool)).Decode(&pathBlock{})
+	checkFieldError(t, err, "Body.Attestations[3].Data.Target.Finalized")
+
+	invalidOffset := append([]byte{}, enc...)
+	binary.LittleEndian.PutUint32(invalidOffset[table+2*4:], 0xfffffff0)
+	err = Unmarshal(invalidOffset, &pathBlock{})
+	checkFieldError(t, err, "Body.Attestations[2]")
+}
+
+func TestError_Sentinels(t *testing.T) {
+	block := &pathBlock{Body: &pathBody{}}
+	for i := 0; i < 4; i++ {
+		block.Body.Attestations = append(block.Body.Attestations, &pathAttestation{
+			Bits: []byte{byte(i)},
+			Data: &pathAttestationData{Target: &pathCheckpoint{}},
+		})
+	}
+	enc, err := Marshal(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const table = 8 + 4 + 4
+	outOfBounds := append([]byte{}, enc...)
+	binary.LittleEndian.PutUint32(outOfBounds[table+2*4:], 0xfffffff0)
+	notIncreasing := append([]byte{}, enc...)
+	copy(notIncreasing[table+3*4:], enc[table+4:table+2*4])
+	forkEnc, err := Marshal(&fork{Epoch: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sentinels := []error{ErrOffsetOutOfBounds, ErrOffsetsNotIncreasing, ErrInputTooShort, ErrSizeMismatch, ErrUnsupportedType}
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "offset beyond the input", err: Unmarshal(outOfBounds, &pathBlock{}), want: ErrOffsetOutOfBounds},
+		{name: "offset inside the offset table", err: Unmarshal([]byte{0, 0, 0, 0}, &[]*pathAttestation{}), want: ErrOffsetOutOfBounds},
+		{name: "decreasing offsets", err: Unmarshal(notIncreasing, &pathBlock{}), want: ErrOffsetsNotIncreasing},
+		{name: "truncated input", err: Unmarshal(forkEnc[:10], &fork{}), want: ErrInputTooShort},
+		{name: "empty input", err: Unmarshal([]byte{}, &fork{}), want: ErrInputTooShort},
+		{name: "empty reader", err: NewDecoder(bytes.NewReader(nil)).Decode(&fork{}), want: ErrInputTooShort},
+		{name: "trailing bytes", err: Unmarshal(append(forkEnc, 0), &fork{}), want: ErrSizeMismatch},
+		{name: "partial element", err: Unmarshal(make([]byte, 9), &[]uint64{}), want: ErrSizeMismatch},
+		{name: "unsupported kind", err: Unmarshal(make([]byte, 8), &struct{ Foo complex128 }{}), want: ErrUnsupportedType},
+		{name: "platform-dependent width", err: func() error { _, err := SizeSSZ(struct{ Foo int }{}); return err }(), want: ErrUnsupportedType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err == nil {
+				t.Fatal("Expected an error")
+			}
+			for _, sentinel := range sentinels {
+				if got := errors.Is(tt.err, sentinel); got != (sentinel == tt.want) {
+					t.Errorf("errors.Is(%v, %v) = %v", tt.err, sentinel, got)
+				}
+			}
+		})
+	}
+	if err := Unmarshal(forkEnc, fork{}); err == nil {
+		t.Fatal("Expected an error for a non-pointer target")
+	} else {
+		for _, sentinel := range sentinels {
+			if errors.Is(err, sentinel) {
+				t.Errorf("Expected %v not to be malformed input, matched %v", err, sentinel)
+			}
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package ssz
+
+import (
+	"github.com/524119574/go-ssz/types"
+)
+
+// Marshaler is implemented by types with an SSZ encoding of their own, such as
+// those with encoders generated by fastssz's sszgen. It has the method set of
+// fastssz's Marshaler, so that generated encoders are used without go-ssz
+// depending on fastssz. Values of such types are encoded with their methods
+// wherever they appear, whether on their own or as fields and elements of
+// other values, and sized with SizeSSZ. Whether they are laid out as fixed-size
+// or variable-size fields is given by their Go layout, which is what sszgen
+// generates encoders from.
+type Marshaler = types.Marshaler
+
+// Unmarshaler is implemented by types with an SSZ decoding of their own. It has
+// the method set of fastssz's Unmarshaler.
+type Unmarshaler = types.Unmarshaler
+
+// HashRooter is implemented by types with a hash tree root of their own, which
+// is used in place of theirs by reflection when they also implement Marshaler
+// or Unmarshaler. Types implementing neither are always hashed by reflection,
+// so that their HashTreeRoot methods can call HashTreeRoot.
+type HashRooter = types.HashRooter
@@ -0,0 +1,241 @@
+package ssz
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	fssz "github.com/ferranbt/fastssz"
+)
+
+// generatedCheckpoint mimics a type with encoders generated by sszgen, whose
+// encoding deliberately differs from the reflection based one.
+type generatedCheckpoint struct {
+	Epoch uint64
+}
+
+var (
+	_ fssz.Marshaler   = (*generatedCheckpoint)(nil)
+	_ fssz.Unmarshaler = (*generatedCheckpoint)(nil)
+)
+
+func (c *generatedCheckpoint) MarshalSSZTo(dst []byte) ([]byte, error) {
+	return append(fssz.MarshalUint64(dst, c.Epoch), 0xff), nil
+}
+
+func (c *generatedCheckpoint) MarshalSSZ() ([]byte, error) {
+	return c.MarshalSSZTo(make([]byte, 0, c.SizeSSZ()))
+}
+
+func (c *generatedCheckpoint) SizeSSZ() int {
+	return 9
+}
+
+func (c *generatedCheckpoint) UnmarshalSSZ(buf []byte) error {
+	if len(buf) != c.SizeSSZ() || buf[8] != 0xff {
+		return errors.New("invalid generated checkpoint")
+	}
+	c.Epoch = fssz.UnmarshallUint64(buf)
+	return nil
+}
+
+func TestMarshal_UsesFastsszEncoders(t *testing.T) {
+	enc, err := Marshal(&generatedCheckpoint{Epoch: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{7, 0, 0, 0, 0, 0, 0, 0, 0xff}
+	if !bytes.Equal(enc, want) {
+		t.Errorf("Expected %v, received %v", want, enc)
+	}
+	dec := &generatedCheckpoint{}
+	if err := Unmarshal(enc, dec); err != nil {
+		t.Fatal(err)
+	}
+	if dec.Epoch != 7 {
+		t.Errorf("Expected epoch 7, received %d", dec.Epoch)
+	}
+	typed, err := NewTypedDecoder(&generatedCheckpoint{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := typed.DecodeNew(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.(*generatedCheckpoint).Epoch != 7 {
+		t.Errorf("Expected epoch 7, received %d", res.(*generatedCheckpoint).Epoch)
+	}
+}
+
+func TestNocacheBuild_DropsOptionalDependencies(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil || testing.Short() {
+		t.Skip("Requires the go tool")
+	}
+	out, err := exec.Command(goTool, "list", "-tags", "nocache", "-deps", ".").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Could not list dependencies: %v: %s", err, out)
+	}
+	for _, dep := range strings.Fields(string(out)) {
+		if strings.Contains(dep, "ristretto") || strings.Contains(dep, "fastssz") {
+			t.Errorf("Expected nocache build not to depend on %s", dep)
+		}
+	}
+}
+
+func TestMarshalAt_UsesFastsszEncoders(t *testing.T) {
+	buf := make([]byte, 9)
+	n, err := MarshalAt(buf, &generatedCheckpoint{Epoch: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{7, 0, 0, 0, 0, 0, 0, 0, 0xff}; n != 9 || !bytes.Equal(buf, want) {
+		t.Errorf("Expected %v, received %d bytes %v", want, n, buf)
+	}
+	if _, err := MarshalAt(buf[:8], &generatedCheckpoint{}); err == nil {
+		t.Error("Expected a buffer one byte short to be rejected")
+	}
+}
+
+// gweiBalances is a list of balances with an encoding of its own, which packs
+// every balance into 4 bytes.
+type gweiBalances []uint64
+
+var (
+	_ Marshaler   = gweiBalances{}
+	_ Unmarshaler = (*gweiBalances)(nil)
+	_ HashRooter  = gweiBalances{}
+)
+
+func (b gweiBalances) MarshalSSZTo(dst []byte) ([]byte, error) {
+	for _, balance := range b {
+		dst = fssz.MarshalUint32(dst, uint32(balance))
+	}
+	return dst, nil
+}
+
+func (b gweiBalances) MarshalSSZ() ([]byte, error) {
+	return b.MarshalSSZTo(make([]byte, 0, b.SizeSSZ()))
+}
+
+func (b gweiBalances) SizeSSZ() int {
+	return 4 * len(b)
+}
+
+func (b *gweiBalances) UnmarshalSSZ(buf []byte) error {
+	if len(buf)%4 != 0 {
+		return errors.New("invalid gwei balances")
+	}
+	*b = make(gweiBalances, len(buf)/4)
+	for i := range *b {
+		(*b)[i] = uint64(fssz.UnmarshallUint32(buf[4*i:]))
+	}
+	return nil
+}
+
+func (b gweiBalances) HashTreeRoot() ([32]byte, error) {
+	// Its root takes a limit of its own, rather than that of the field.
+	return HashTreeRootWithCapacity([]uint64(b), 32)
+}
+
+type customValidators struct {
+	Checkpoint generatedCheckpoint
+	Balances   gweiBalances `ssz-max:"16"`
+}
+
+type customState struct {
+	Slot       uint64
+	Validators *customValidators
+}
+
+func TestMarshal_UsesEncodersOfNestedFields(t *testing.T) {
+	state := &customState{
+		Slot: 3,
+		Validators: &customValidators{
+			Checkpoint: generatedCheckpoint{Epoch: 7},
+			Balances:   gweiBalances{1, 2},
+		},
+	}
+	enc, err := Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		3, 0, 0, 0, 0, 0, 0, 0, 12, 0, 0, 0,
+		7, 0, 0, 0, 0, 0, 0, 0, 0xff, 13, 0, 0, 0,
+		1, 0, 0, 0, 2, 0, 0, 0,
+	}
+	if !bytes.Equal(enc, want) {
+		t.Fatalf("Marshaled %v, expected %v", enc, want)
+	}
+	size, err := SizeSSZ(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != uint64(len(want)) {
+		t.Errorf("SizeSSZ returned %d, expected %d", size, len(want))
+	}
+	var decoded customState
+	if err := Unmarshal(enc, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, state) {
+		t.Errorf("Unmarshaled %+v, expected %+v", decoded.Validators, state.Validators)
+	}
+	var streamed bytes.Buffer
+	if err := NewEncoder(&streamed).Encode(state); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(streamed.Bytes(), want) {
+		t.Errorf("Encoded %v, expected %v", streamed.Bytes(), want)
+	}
+	var fromStream customState
+	if err := NewDecoder(bytes.NewReader(want)).Decode(&fromStream); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&fromStream, state) {
+		t.Errorf("Decoded %+v, expected %+v", fromStream.Validators, state.Validators)
+	}
+	// The checkpoint encodes 9 bytes, the last of which it requires to be 0xff.
+	bad := append([]byte{}, want...)
+	bad[20] = 0
+	if err := Unmarshal(bad, &decoded); err == nil || !strings.Contains(err.Error(), "invalid generated checkpoint") {
+		t.Errorf("Expected the checkpoint decoder to reject its input, received %v", err)
+	}
+}
+
+func TestHashTreeRoot_UsesRootsOfNestedFields(t *testing.T) {
+	// The balances are hashed with their own method, and the checkpoint, which
+	// has none, by reflection over its fields.
+	type plainValidators struct {
+		Checkpoint struct{ Epoch uint64 }
+		Balances   []uint64 `ssz-max:"32"`
+	}
+	type plainState struct {
+		Slot       uint64
+		Validators *plainValidators
+	}
+	plain := &plainState{Slot: 3, Validators: &plainValidators{Balances: []uint64{1, 2}}}
+	plain.Validators.Checkpoint.Epoch = 7
+	want, err := HashTreeRoot(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := HashTreeRoot(&customState{
+		Slot: 3,
+		Validators: &customValidators{
+			Checkpoint: generatedCheckpoint{Epoch: 7},
+			Balances:   gweiBalances{1, 2},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != want {
+		t.Errorf("Root is %#x, expected %#x", root, want)
+	}
+}
@@ -0,0 +1,38 @@
+package ssz
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+	"github.com/524119574/go-ssz/types"
+)
+
+// UnmarshalField decodes a single field of the container encoded in input into
+// that field of the container val points to, without decoding the others. The
+// field is named by its Go name, or by its ssz-name tag when it has one. The
+// fixed-size fields before it locate fixed-size fields directly, and the offsets
+// of the field and of the variable-size field following it locate variable-size
+// ones, so only those bytes of the input are read:
+//
+//	var block BeaconBlock
+//	err := UnmarshalField(enc, &block, "Slot")
+//
+// The other fields of val are left untouched, and the parts of the input they
+// are encoded in are not validated.
+func UnmarshalField(input []byte, val interface{}, fieldName string) error {
+	return newError(OpUnmarshal, val, unmarshalField(input, val, fieldName))
+}
+
+func unmarshalField(input []byte, val interface{}, fieldName string) error {
+	if val == nil {
+		return errors.New("cannot unmarshal into untyped, nil value")
+	}
+	rval := reflect.ValueOf(val)
+	if rval.Kind() != reflect.Ptr {
+		return errors.New("can only unmarshal into a pointer target")
+	}
+	if rval.IsNil() {
+		return errors.New("cannot output to pointer of nil value")
+	}
+	return types.UnmarshalField(rval.Elem(), rval.Type().Elem(), input, fieldName, nil)
+}
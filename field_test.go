@@ -0,0 +1,129 @@
+package ssz
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prysmaticlabs/go-bitfield"
+)
+
+type fieldHeader struct {
+	Slot       uint64
+	ParentRoot [32]byte
+}
+
+type fieldBlock struct {
+	Slot        uint64
+	Graffiti    []byte `ssz-max:"32"`
+	Header      *fieldHeader
+	Name        string           `ssz-max:"16" ssz-name:"name"`
+	Attestation bitfield.Bitlist `ssz-max:"64"`
+	Balances    []uint64         `ssz-max:"8"`
+	Proposer    uint32
+}
+
+func fieldBlockFixture() *fieldBlock {
+	return &fieldBlock{
+		Slot:        12,
+		Graffiti:    []byte("graffiti"),
+		Header:      &fieldHeader{Slot: 11, ParentRoot: [32]byte{1, 2, 3}},
+		Name:        "block",
+		Attestation: bitfield.Bitlist{0x0d},
+		Balances:    []uint64{1, 2, 3},
+		Proposer:    7,
+	}
+}
+
+func TestUnmarshalField(t *testing.T) {
+	block := fieldBlockFixture()
+	enc, err := Marshal(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]string{
+		"Slot":        "Slot",
+		"Graffiti":    "Graffiti",
+		"Header":      "Header",
+		"name":        "Name",
+		"Attestation": "Attestation",
+		"Balances":    "Balances",
+		"Proposer":    "Proposer",
+	}
+	for name, goName := range names {
+		t.Run(name, func(t *testing.T) {
+			decoded := &fieldBlock{Proposer: 99}
+			if err := UnmarshalField(enc, decoded, name); err != nil {
+				t.Fatal(err)
+			}
+			got := reflect.ValueOf(decoded).Elem().FieldByName(goName).Interface()
+			want := reflect.ValueOf(block).Elem().FieldByName(goName).Interface()
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Decoded %s as %v, expected %v", name, got, want)
+			}
+			// The other fields are left as they were.
+			if goName != "Proposer" && decoded.Proposer != 99 {
+				t.Errorf("Decoding %s changed Proposer to %d", name, decoded.Proposer)
+			}
+		})
+	}
+}
+
+func TestUnmarshalField_OnlyReadsItsRange(t *testing.T) {
+	block := fieldBlockFixture()
+	enc, err := Marshal(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Corrupting the offset of the name leaves the fields it does not bound
+	// decodable, while the name itself is rejected.
+	// The name follows the slot, the offset of the graffiti and the header.
+	nameOffset := 8 + 4 + 40
+	corrupted := append([]byte{}, enc...)
+	corrupted[nameOffset] = 0xff
+	corrupted[nameOffset+1] = 0xff
+	var decoded fieldBlock
+	if err := UnmarshalField(corrupted, &decoded, "Proposer"); err != nil || decoded.Proposer != block.Proposer {
+		t.Errorf("Expected Proposer to decode as %d, received %d, %v", block.Proposer, decoded.Proposer, err)
+	}
+	if err := UnmarshalField(corrupted, &decoded, "Balances"); err != nil || !reflect.DeepEqual(decoded.Balances, block.Balances) {
+		t.Errorf("Expected Balances to decode as %v, received %v, %v", block.Balances, decoded.Balances, err)
+	}
+	err = UnmarshalField(corrupted, &decoded, "name")
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Path() != "name" {
+		t.Errorf("Expected an error in field name, received %v", err)
+	}
+	if err := UnmarshalField(corrupted, &decoded, "Graffiti"); !errors.Is(err, ErrOffsetOutOfBounds) {
+		t.Errorf("Expected the offset following Graffiti to be out of bounds, received %v", err)
+	}
+}
+
+func TestUnmarshalField_Errors(t *testing.T) {
+	enc, err := Marshal(fieldBlockFixture())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var block fieldBlock
+	var slot uint64
+	tests := []struct {
+		input []byte
+		val   interface{}
+		name  string
+		err   string
+	}{
+		{input: enc, val: &block, name: "Missing", err: "has no field Missing"},
+		{input: enc, val: &block, name: "Name", err: "has no field Name"},
+		{input: enc, val: block, name: "Slot", err: "pointer target"},
+		{input: enc, val: (*fieldBlock)(nil), name: "Slot", err: "nil value"},
+		{input: enc, val: nil, name: "Slot", err: "nil value"},
+		{input: enc, val: &slot, name: "Slot", err: "only decode single fields of containers"},
+		{input: enc[:20], val: &block, name: "Slot", err: "truncated"},
+	}
+	for _, tt := range tests {
+		if err := UnmarshalField(tt.input, tt.val, tt.name); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Expected decoding %s into %T to fail with %q, received %v", tt.name, tt.val, tt.err, err)
+		}
+	}
+}
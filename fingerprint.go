@@ -0,0 +1,100 @@
+package ssz
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/524119574/go-ssz/types"
+)
+
+// FingerprintSize is the number of bytes of the fingerprint prepended to
+// encodings by MarshalWithFingerprint.
+const FingerprintSize = 4
+
+// ErrTypeFingerprintMismatch is returned by UnmarshalWithFingerprint when the
+// fingerprint of the input is not the one of the type it is decoded into.
+type ErrTypeFingerprintMismatch struct {
+	Expected [FingerprintSize]byte
+	Received [FingerprintSize]byte
+}
+
+func (e *ErrTypeFingerprintMismatch) Error() string {
+	return fmt.Sprintf("input has type fingerprint %#x, expected %#x", e.Received, e.Expected)
+}
+
+var fingerprints sync.Map
+
+// TypeFingerprint returns the fingerprint of the type of val, which is the start
+// of the SHA-256 hash of the canonical form of its schema as given by
+// types.Describe. It only depends on the layout of the type, so it is the same
+// across processes and Go versions, and types which only differ by their names
+// share it.
+func TypeFingerprint(val interface{}) ([FingerprintSize]byte, error) {
+	if val == nil {
+		return [FingerprintSize]byte{}, errors.New("untyped-value nil has no fingerprint")
+	}
+	typ := reflect.TypeOf(val)
+	if fingerprint, ok := fingerprints.Load(typ); ok {
+		return fingerprint.([FingerprintSize]byte), nil
+	}
+	schema, err := types.Describe(typ)
+	if err != nil {
+		return [FingerprintSize]byte{}, errors.Wrapf(err, "could not describe type %v", typ)
+	}
+	var fingerprint [FingerprintSize]byte
+	hash := sha256.Sum256([]byte(schema.String()))
+	copy(fingerprint[:], hash[:])
+	fingerprints.Store(typ, fingerprint)
+	return fingerprint, nil
+}
+
+// MarshalWithFingerprint marshals val as Marshal does, prefixed with the
+// fingerprint of its type, so that UnmarshalWithFingerprint rejects it when it
+// is decoded into a type of another layout, even one of the same size. It is
+// meant for transports carrying many types of messages.
+func MarshalWithFingerprint(val interface{}) ([]byte, error) {
+	fingerprint, err := TypeFingerprint(val)
+	if err != nil {
+		return nil, newError(OpMarshal, val, err)
+	}
+	enc, err := Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	return append(fingerprint[:], enc...), nil
+}
+
+// UnmarshalWithFingerprint checks that input starts with the fingerprint of the
+// type of val before unmarshaling the rest of it into val as Unmarshal does. The
+// error returned when the fingerprints differ wraps an
+// *ErrTypeFingerprintMismatch.
+func UnmarshalWithFingerprint(input []byte, val interface{}) error {
+	if len(input) < FingerprintSize {
+		return newError(OpUnmarshal, val, fmt.Errorf("input of %d bytes is too short to hold a type fingerprint: %w", len(input), ErrInputTooShort))
+	}
+	fingerprint, err := TypeFingerprint(val)
+	if err != nil {
+		return newError(OpUnmarshal, val, err)
+	}
+	var received [FingerprintSize]byte
+	copy(received[:], input)
+	if received != fingerprint {
+		return &Error{
+			Op:     OpUnmarshal,
+			Type:   reflect.TypeOf(val),
+			Offset: 0,
+			Err:    &ErrTypeFingerprintMismatch{Expected: fingerprint, Received: received},
+		}
+	}
+	err = Unmarshal(input[FingerprintSize:], val)
+	// Offsets are reported from the start of input, fingerprint included.
+	if e, ok := err.(*Error); ok && e.Offset >= 0 {
+		shifted := *e
+		shifted.Offset += FingerprintSize
+		return &shifted
+	}
+	return err
+}
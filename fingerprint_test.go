@@ -0,0 +1,105 @@
+package ssz
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type fingerprintCheckpoint struct {
+	Epoch uint64
+	Root  [32]byte
+}
+
+// fingerprintRenamedCheckpoint has the layout of fingerprintCheckpoint under
+// another name.
+type fingerprintRenamedCheckpoint struct {
+	Epoch uint64
+	Root  [32]byte
+}
+
+// fingerprintVote has the size of fingerprintCheckpoint but another layout.
+type fingerprintVote struct {
+	Root  [32]byte
+	Epoch uint64
+}
+
+func TestUnmarshalWithFingerprint_RoundTrip(t *testing.T) {
+	val := &fingerprintCheckpoint{Epoch: 5, Root: [32]byte{1, 2, 3}}
+	enc, err := MarshalWithFingerprint(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint, err := TypeFingerprint(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := Marshal(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(fingerprint[:], plain...); !bytes.Equal(enc, want) {
+		t.Errorf("Expected %#x, received %#x", want, enc)
+	}
+	decoded := &fingerprintCheckpoint{}
+	if err := UnmarshalWithFingerprint(enc, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, val) {
+		t.Errorf("Expected %v, received %v", val, decoded)
+	}
+}
+
+func TestUnmarshalWithFingerprint_RejectsOtherTypes(t *testing.T) {
+	enc, err := MarshalWithFingerprint(fingerprintCheckpoint{Epoch: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without fingerprints, the encoding decodes into the other type just fine.
+	if err := Unmarshal(enc[FingerprintSize:], &fingerprintVote{}); err != nil {
+		t.Fatal(err)
+	}
+	err = UnmarshalWithFingerprint(enc, &fingerprintVote{})
+	var mismatch *ErrTypeFingerprintMismatch
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected an *ErrTypeFingerprintMismatch, received %v", err)
+	}
+	want, err := TypeFingerprint(fingerprintVote{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mismatch.Expected != want || !bytes.Equal(mismatch.Received[:], enc[:FingerprintSize]) {
+		t.Errorf("Expected fingerprints %#x and %#x, received %#x and %#x", want, enc[:FingerprintSize], mismatch.Expected, mismatch.Received)
+	}
+	if err := UnmarshalWithFingerprint(enc[:2], &fingerprintCheckpoint{}); err == nil {
+		t.Error("Expected an input shorter than a fingerprint to be rejected")
+	}
+}
+
+func TestTypeFingerprint_SharedBySameLayout(t *testing.T) {
+	checkpoint, err := TypeFingerprint(&fingerprintCheckpoint{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamed, err := TypeFingerprint(fingerprintRenamedCheckpoint{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint != renamed {
+		t.Errorf("Expected types of the same layout to share a fingerprint, received %#x and %#x", checkpoint, renamed)
+	}
+	enc, err := MarshalWithFingerprint(fingerprintRenamedCheckpoint{Epoch: 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &fingerprintCheckpoint{}
+	if err := UnmarshalWithFingerprint(enc, decoded); err != nil || decoded.Epoch != 9 {
+		t.Errorf("Expected epoch 9, received %d (%v)", decoded.Epoch, err)
+	}
+	// The fingerprint is derived from the canonical schema
+	// "container{Epoch: uint64, Root: vector[uint8, 32]}", so it never changes.
+	if want := [FingerprintSize]byte{0xd6, 0xe1, 0xc0, 0xd4}; checkpoint != want {
+		t.Errorf("Expected fingerprint %#x, received %#x", want, checkpoint)
+	}
+}
@@ -0,0 +1,117 @@
+package ssz
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+)
+
+// UnknownForkDigestError is returned by a ForkRouter when no type has been
+// registered for the fork digest of a payload.
+type UnknownForkDigestError struct {
+	Digest [4]byte
+}
+
+func (e *UnknownForkDigestError) Error() string {
+	return fmt.Sprintf("no type registered for fork digest %#x", e.Digest)
+}
+
+type forkRoute struct {
+	name string
+	typ  reflect.Type
+}
+
+// ForkRouter decodes payloads whose container version is determined by the fork
+// digest they were received with, such as gossip messages, into the Go type
+// registered for that digest.
+//
+//  router := NewForkRouter(WithSnappy())
+//  if err := router.Register(phase0Digest, "beacon_block", &BeaconBlock{}); err != nil {
+//      return err
+//  }
+//  if err := router.Register(altairDigest, "beacon_block", &BeaconBlockAltair{}); err != nil {
+//      return err
+//  }
+//  block, err := router.Decode(digest, payload)
+type ForkRouter struct {
+	lock   sync.RWMutex
+	routes map[[4]byte]forkRoute
+	snappy bool
+}
+
+// ForkRouterOption configures a ForkRouter.
+type ForkRouterOption func(r *ForkRouter)
+
+// WithSnappy makes the router snappy-decompress payloads (block format, as used
+// by the ssz_snappy encoding) before decoding them.
+func WithSnappy() ForkRouterOption {
+	return func(r *ForkRouter) {
+		r.snappy = true
+	}
+}
+
+// NewForkRouter creates an empty ForkRouter.
+func NewForkRouter(opts ...ForkRouterOption) *ForkRouter {
+	r := &ForkRouter{routes: make(map[[4]byte]forkRoute)}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Register maps a fork digest to the type of the given prototype, which may be a
+// value or a pointer. The name describes the registered type in error messages.
+// A digest can only be registered once.
+func (r *ForkRouter) Register(digest [4]byte, name string, prototype interface{}) error {
+	if prototype == nil {
+		return errors.New("untyped-value nil cannot be registered")
+	}
+	typ := reflect.TypeOf(prototype)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if existing, ok := r.routes[digest]; ok {
+		return fmt.Errorf("fork digest %#x is already registered for %s", digest, existing.name)
+	}
+	r.routes[digest] = forkRoute{name: name, typ: typ}
+	return nil
+}
+
+// Decode unmarshals a payload into a newly allocated value of the type registered
+// for the digest and returns a pointer to it. An *UnknownForkDigestError is
+// returned if the digest was never registered.
+func (r *ForkRouter) Decode(digest [4]byte, payload []byte) (interface{}, error) {
+	r.lock.RLock()
+	route, ok := r.routes[digest]
+	r.lock.RUnlock()
+	if !ok {
+		return nil, &UnknownForkDigestError{Digest: digest}
+	}
+	if r.snappy {
+		decoded, err := snappy.Decode(nil, payload)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not snappy decode %s payload", route.name)
+		}
+		payload = decoded
+	}
+	val := reflect.New(route.typ).Interface()
+	if err := Unmarshal(payload, val); err != nil {
+		return nil, errors.Wrapf(err, "could not decode %s for fork digest %#x", route.name, digest)
+	}
+	return val, nil
+}
+
+// Root decodes a payload as Decode does and returns the hash tree root of the
+// decoded value.
+func (r *ForkRouter) Root(digest [4]byte, payload []byte) ([32]byte, error) {
+	val, err := r.Decode(digest, payload)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return HashTreeRoot(val)
+}
@@ -0,0 +1,132 @@
+package ssz
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/snappy"
+)
+
+type routedBlockV1 struct {
+	Slot       uint64
+	ParentRoot [32]byte
+}
+
+type routedBlockV2 struct {
+	Slot       uint64
+	ParentRoot [32]byte
+	Graffiti   []byte
+}
+
+var (
+	digestV1 = [4]byte{0xb5, 0x30, 0x3f, 0x2a}
+	digestV2 = [4]byte{0xaf, 0xca, 0xab, 0xa0}
+)
+
+func newTestForkRouter(t *testing.T, opts ...ForkRouterOption) *ForkRouter {
+	router := NewForkRouter(opts...)
+	if err := router.Register(digestV1, "block_v1", &routedBlockV1{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.Register(digestV2, "block_v2", routedBlockV2{}); err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+func TestForkRouter_Decode(t *testing.T) {
+	router := newTestForkRouter(t)
+	v1 := &routedBlockV1{Slot: 3, ParentRoot: [32]byte{1}}
+	v2 := &routedBlockV2{Slot: 4, ParentRoot: [32]byte{2}, Graffiti: []byte("hi")}
+	enc1, err := Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc2, err := Marshal(v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec1, err := router.Decode(digestV1, enc1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := dec1.(*routedBlockV1); !ok || !DeepEqual(got, v1) {
+		t.Errorf("Wanted %v, received %v", v1, dec1)
+	}
+	dec2, err := router.Decode(digestV2, enc2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := dec2.(*routedBlockV2); !ok || !DeepEqual(got, v2) {
+		t.Errorf("Wanted %v, received %v", v2, dec2)
+	}
+	if _, err := router.Decode(digestV1, enc2); err == nil {
+		t.Error("Expected decoding a v2 block under the v1 digest to fail")
+	}
+}
+
+func TestForkRouter_UnknownDigest(t *testing.T) {
+	router := newTestForkRouter(t)
+	unknown := [4]byte{1, 2, 3, 4}
+	_, err := router.Decode(unknown, []byte{1})
+	var digestErr *UnknownForkDigestError
+	if !errors.As(err, &digestErr) {
+		t.Fatalf("Expected an UnknownForkDigestError, received %v", err)
+	}
+	if digestErr.Digest != unknown {
+		t.Errorf("Wanted digest %#x, received %#x", unknown, digestErr.Digest)
+	}
+	if _, err := router.Root(unknown, []byte{1}); !errors.As(err, &digestErr) {
+		t.Errorf("Expected an UnknownForkDigestError, received %v", err)
+	}
+}
+
+func TestForkRouter_DuplicateDigest(t *testing.T) {
+	router := newTestForkRouter(t)
+	if err := router.Register(digestV1, "block_v2", &routedBlockV2{}); err == nil {
+		t.Error("Expected registering a digest twice to fail")
+	}
+}
+
+func TestForkRouter_Snappy(t *testing.T) {
+	router := newTestForkRouter(t, WithSnappy())
+	v2 := &routedBlockV2{Slot: 9, Graffiti: []byte("snappy")}
+	enc, err := Marshal(v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := router.Decode(digestV2, snappy.Encode(nil, enc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !DeepEqual(dec, v2) {
+		t.Errorf("Wanted %v, received %v", v2, dec)
+	}
+	if _, err := router.Decode(digestV2, enc); err == nil {
+		t.Error("Expected an uncompressed payload to fail decoding")
+	}
+}
+
+func TestForkRouter_Root(t *testing.T) {
+	router := newTestForkRouter(t)
+	for digest, block := range map[[4]byte]interface{}{
+		digestV1: &routedBlockV1{Slot: 3, ParentRoot: [32]byte{1}},
+		digestV2: &routedBlockV2{Slot: 4, Graffiti: []byte("hi")},
+	} {
+		enc, err := Marshal(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := HashTreeRoot(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := router.Root(digest, enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Wanted %#x, received %#x", want, got)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+//go:build go1.18
+// +build go1.18
+
+package ssz
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type fuzzByte uint8
+
+type fuzzCheckpoint struct {
+	Epoch uint64
+	Root  []byte `ssz-size:"32"`
+}
+
+type fuzzItem struct {
+	Valid bool
+	Data  []byte `ssz-max:"8"`
+}
+
+type fuzzNested struct {
+	Index uint16
+	Items []fuzzItem `ssz-max:"4"`
+	Flags []bool     `ssz-max:"8"`
+	Bits  Bitlist    `ssz-max:"16"`
+	Pair  *[2][]byte
+}
+
+type fuzzContainer struct {
+	Flag        bool
+	Small       uint8
+	Index       uint32
+	Slot        uint64
+	Version     [4]byte
+	Roots       [][]byte  `ssz-size:"2,32"`
+	Justified   Bitvector `ssz-size:"4"`
+	Balance     Uint256
+	Bits        Bitlist `ssz-max:"64"`
+	Data        []byte  `ssz-max:"64"`
+	Name        string  `ssz-max:"16"`
+	Source      *fuzzCheckpoint
+	Checkpoints []fuzzCheckpoint `ssz-max:"8"`
+	Balances    []uint64         `ssz-max:"16"`
+	Nested      []*fuzzNested    `ssz-max:"4"`
+	Matrix      [][]uint16       `ssz-size:"?,2" ssz-max:"4"`
+	Named       []fuzzByte       `ssz-max:"8"`
+	Epochs      *[]uint64        `ssz-max:"4"`
+	Graffiti    *string          `ssz-max:"8"`
+}
+
+func fuzzSeeds(f *testing.F, vals ...interface{}) {
+	for _, val := range vals {
+		enc, err := Marshal(val)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(enc)
+	}
+	f.Add([]byte{})
+	f.Add([]byte{0})
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff})
+}
+
+// checkStable checks a value decoded from data is encoded and decoded again to
+// the same value and encoding.
+func checkStable(t *testing.T, decoded interface{}) {
+	enc, err := Marshal(decoded)
+	if err != nil {
+		t.Fatalf("Could not marshal decoded value %+v: %v", decoded, err)
+	}
+	again := reflect.New(reflect.TypeOf(decoded).Elem()).Interface()
+	if err := Unmarshal(enc, again); err != nil {
+		t.Fatalf("Could not unmarshal re-encoded value %#x: %v", enc, err)
+	}
+	reencoded, err := Marshal(again)
+	if err != nil {
+		t.Fatalf("Could not marshal decoded value %+v: %v", again, err)
+	}
+	if !bytes.Equal(reencoded, enc) {
+		t.Fatalf("Expected %#x to be re-encoded as is, received %#x", enc, reencoded)
+	}
+}
+
+func FuzzUnmarshalStruct(f *testing.F) {
+	fuzzSeeds(f,
+		&fuzzContainer{},
+		&fuzzContainer{
+			Flag:        true,
+			Slot:        9,
+			Roots:       [][]byte{make([]byte, 32), make([]byte, 32)},
+			Justified:   Bitvector{0x05},
+			Bits:        Bitlist{0x0d},
+			Data:        []byte("data"),
+			Name:        "name",
+			Source:      &fuzzCheckpoint{Epoch: 1, Root: make([]byte, 32)},
+			Checkpoints: []fuzzCheckpoint{{Epoch: 2, Root: make([]byte, 32)}},
+			Balances:    []uint64{1, 2},
+			Nested: []*fuzzNested{
+				{Index: 1, Items: []fuzzItem{{Valid: true, Data: []byte{1}}, {}}, Flags: []bool{true}, Bits: Bitlist{0x03}, Pair: &[2][]byte{{1}, {2, 3}}},
+				{},
+			},
+			Matrix:   [][]uint16{{1, 2}, {3, 4}},
+			Named:    []fuzzByte{1, 2},
+			Epochs:   &[]uint64{3},
+			Graffiti: new(string),
+		},
+	)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decoded := &fuzzContainer{}
+		if err := Unmarshal(data, decoded); err != nil {
+			return
+		}
+		checkStable(t, decoded)
+	})
+}
+
+func FuzzUnmarshalCompositeSlice(f *testing.F) {
+	fuzzSeeds(f,
+		&[]fuzzNested{{Items: []fuzzItem{{Data: []byte{1, 2}}}}, {Flags: []bool{true, false}}},
+		&[]fuzzCheckpoint{{Epoch: 1, Root: make([]byte, 32)}},
+		&[][]byte{{1}, {2, 3}},
+	)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, decoded := range []interface{}{
+			&[]fuzzNested{},
+			&[]*fuzzItem{},
+			&[]fuzzCheckpoint{},
+			&[][4]byte{},
+			&[][]uint64{},
+		} {
+			if err := Unmarshal(data, decoded); err != nil {
+				continue
+			}
+			checkStable(t, decoded)
+		}
+	})
+}
+
+func FuzzUnmarshalRootsArray(f *testing.F) {
+	roots := [4][32]byte{{1}, {2}, {3}, {4}}
+	fuzzSeeds(f, &roots, &[][32]byte{{1}, {2}})
+	enc, err := Marshal(&roots)
+	if err != nil {
+		f.Fatal(err)
+	}
+	// Truncated vectors of roots, which are rejected rather than read past the
+	// end of the input.
+	f.Add(enc[:99])
+	f.Add(enc[:32])
+	f.Add(enc[:4])
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, decoded := range []interface{}{
+			&[4][32]byte{},
+			&[][32]byte{},
+			&struct {
+				Roots [][]byte `ssz-size:"4,32"`
+			}{},
+		} {
+			if err := Unmarshal(data, decoded); err != nil {
+				continue
+			}
+			checkStable(t, decoded)
+		}
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	fuzzSeeds(f,
+		&fuzzContainer{},
+		&fuzzContainer{Data: []byte{1}, Nested: []*fuzzNested{{Items: []fuzzItem{{}}}}},
+	)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decoded := &fuzzContainer{}
+		err := NewDecoder(bytes.NewReader(data)).Decode(decoded)
+		unmarshaled := &fuzzContainer{}
+		if unmarshalErr := Unmarshal(data, unmarshaled); (err == nil) != (unmarshalErr == nil) {
+			t.Fatalf("Expected Decode to return the error Unmarshal returns, %v, received %v", unmarshalErr, err)
+		}
+		if err != nil {
+			return
+		}
+		if !DeepEqual(decoded, unmarshaled) {
+			t.Fatalf("Expected Decode to decode %+v, received %+v", unmarshaled, decoded)
+		}
+		checkStable(t, decoded)
+	})
+}
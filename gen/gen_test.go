@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+var goldenTypes = []string{"fork", "beaconState", "simpleNonProtoMessage", "block"}
+
+// copyGolden copies the named files of testdata/golden to dir.
+func copyGolden(t *testing.T, dir string, names ...string) {
+	for _, name := range names {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", "golden", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func runTest(t *testing.T, dir string, name string) {
+	cmd := exec.Command("go", "test", "-count=1", "-run", "^"+name+"$", ".")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%s failed: %v\n%s", name, err, out)
+	}
+}
+
+// TestGenerate_Golden records the encodings of the golden types by reflection,
+// generates their methods, and checks the methods reproduce the encodings.
+func TestGenerate_Golden(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping compiling generated methods in short mode")
+	}
+	// The package is copied within the module to import it.
+	dir, err := ioutil.TempDir("testdata", "golden-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	copyGolden(t, dir, "types.go", "record_test.go")
+	runTest(t, dir, "TestRecord")
+	if err := os.Remove(filepath.Join(dir, "record_test.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := generate(dir, goldenTypes, ""); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "fork_ssz.go")
+	src, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(src, []byte("// Code generated by go-ssz. DO NOT EDIT.\n")) {
+		t.Errorf("Expected a generated code header, received %q", src[:bytes.IndexByte(src, '\n')+1])
+	}
+	// Generating the methods again replaces those generated before.
+	if err := generate(dir, goldenTypes, ""); err != nil {
+		t.Fatal(err)
+	}
+	regenerated, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(regenerated, src) {
+		t.Errorf("Expected the same methods to be generated again, received\n%s", regenerated)
+	}
+
+	copyGolden(t, dir, "compare_test.go")
+	runTest(t, dir, "TestCompare")
+}
+
+func TestGenerate_RestoresOutputOnFailure(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping compiling generated methods in short mode")
+	}
+	dir, err := ioutil.TempDir("testdata", "golden-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	copyGolden(t, dir, "types.go")
+	out := filepath.Join(dir, "missing_ssz.go")
+	previous := []byte("package golden\n")
+	if err := ioutil.WriteFile(out, previous, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(dir, []string{"missing"}, ""); err == nil {
+		t.Fatal("Expected an undeclared type to be rejected")
+	}
+	restored, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(restored, previous) {
+		t.Errorf("Expected %q to be restored, received %q", previous, restored)
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "zz_sszgen_*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("Expected the temporary test to be removed, found %v", matches)
+	}
+}
@@ -0,0 +1,165 @@
+// Command gen generates static MarshalSSZ, MarshalSSZTo, UnmarshalSSZ and
+// SizeSSZ methods for struct types, which Marshal, Unmarshal and SizeSSZ then
+// use in place of reflection. The methods follow the same fields and ssz-size
+// and ssz-max tags as reflection, and produce the same encodings. It is meant
+// to be run by go:generate from the package declaring the types:
+//
+//	//go:generate go run github.com/524119574/go-ssz/gen -type=BeaconState,Fork
+//
+// The types are loaded by compiling a temporary test of their package, so the
+// package must build and may declare unexported types. The methods are written
+// to the file named by -output, which defaults to the lowercase name of the
+// first type followed by _ssz.go, and which is replaced on every run.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of struct type names; must be set")
+	output    = flag.String("output", "", "output file name; default <type>_ssz.go")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of gen:\n")
+	fmt.Fprintf(os.Stderr, "\tgen -type T[,T...] [-output file] [directory]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	if err := generate(dir, strings.Split(*typeNames, ","), *output); err != nil {
+		fmt.Fprintf(os.Stderr, "gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// generate writes the methods of the named types of the package in dir to the
+// file out, relative to dir unless absolute.
+func generate(dir string, names []string, out string) error {
+	for _, name := range names {
+		if name == "" {
+			return errors.New("empty type name")
+		}
+	}
+	if out == "" {
+		out = strings.ToLower(names[0]) + "_ssz.go"
+	}
+	if !filepath.IsAbs(out) {
+		out = filepath.Join(dir, out)
+	}
+	out, err := filepath.Abs(out)
+	if err != nil {
+		return err
+	}
+	pkg, err := packageName(dir)
+	if err != nil {
+		return err
+	}
+	// The methods generated before must not be compiled, as the types would be
+	// taken for types with encodings of their own. They are restored if the
+	// methods cannot be generated again.
+	previous, err := ioutil.ReadFile(out)
+	switch {
+	case err == nil:
+		if err := os.Remove(out); err != nil {
+			return err
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+	if err := runGenerator(dir, pkg, names, out); err != nil {
+		if previous != nil {
+			if restoreErr := ioutil.WriteFile(out, previous, 0644); restoreErr != nil {
+				return errors.Wrapf(err, "could not restore %s: %v", out, restoreErr)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// packageName returns the name of the package in dir.
+func packageName(dir string) (string, error) {
+	cmd := exec.Command("go", "list", "-f", "{{.Name}}", ".")
+	cmd.Dir = dir
+	cmd.Stderr = os.Stderr
+	name, err := cmd.Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "could not load package in %s", dir)
+	}
+	return strings.TrimSpace(string(name)), nil
+}
+
+var generatorTemplate = `package %s
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	ssztypes "github.com/524119574/go-ssz/types"
+)
+
+func %s(t *testing.T) {
+	src, err := ssztypes.GenerateMethods(%q, %s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(%q, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+`
+
+// runGenerator compiles and runs a temporary test of the package in dir which
+// generates the methods of the named types and writes them to out.
+func runGenerator(dir string, pkg string, names []string, out string) error {
+	typs := make([]string, len(names))
+	for i, name := range names {
+		typs[i] = fmt.Sprintf("reflect.TypeOf(%s{})", name)
+	}
+	id := fmt.Sprintf("%d", rand.New(rand.NewSource(time.Now().UnixNano())).Int63())
+	testName := "TestSSZGenerate" + id
+	src := fmt.Sprintf(generatorTemplate, pkg, testName, pkg, strings.Join(typs, ", "), out)
+	file := filepath.Join(dir, "zz_sszgen_"+id+"_test.go")
+	if err := ioutil.WriteFile(file, []byte(src), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(file)
+
+	var stdout bytes.Buffer
+	cmd := exec.Command("go", "test", "-count=1", "-run", "^"+testName+"$", ".")
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		os.Stderr.Write(stdout.Bytes())
+		return errors.Wrapf(err, "could not generate methods of %s", strings.Join(names, ", "))
+	}
+	if _, err := os.Stat(out); err != nil {
+		return errors.Wrap(err, "generator did not write its output")
+	}
+	return nil
+}
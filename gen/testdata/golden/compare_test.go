@@ -0,0 +1,73 @@
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	ssz "github.com/524119574/go-ssz"
+)
+
+func TestCompare(t *testing.T) {
+	data, err := ioutil.ReadFile("records.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records map[string]record
+	if err := json.Unmarshal(data, &records); err != nil {
+		t.Fatal(err)
+	}
+	for name, val := range samples() {
+		want, ok := records[name]
+		if !ok {
+			t.Fatalf("%s: no recorded encoding", name)
+		}
+		m, ok := val.(ssz.Marshaler)
+		if !ok {
+			t.Fatalf("%s: expected %T to have generated methods", name, val)
+		}
+		enc, err := m.MarshalSSZ()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(enc, want.Encoding) {
+			t.Errorf("%s: expected MarshalSSZ to return %#x, received %#x", name, want.Encoding, enc)
+		}
+		if size := m.SizeSSZ(); size != len(want.Encoding) {
+			t.Errorf("%s: expected SizeSSZ to return %d, received %d", name, len(want.Encoding), size)
+		}
+		prefixed, err := m.MarshalSSZTo([]byte{0xff})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(prefixed, append([]byte{0xff}, want.Encoding...)) {
+			t.Errorf("%s: expected MarshalSSZTo to append %#x, received %#x", name, want.Encoding, prefixed[1:])
+		}
+		enc, err = ssz.Marshal(val)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(enc, want.Encoding) {
+			t.Errorf("%s: expected Marshal to return %#x, received %#x", name, want.Encoding, enc)
+		}
+		decoded := reflect.New(reflect.TypeOf(val).Elem()).Interface()
+		if err := ssz.Unmarshal(want.Encoding, decoded); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if enc, err = ssz.Marshal(decoded); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(enc, want.Encoding) {
+			t.Errorf("%s: expected the decoded value to be encoded as %#x, received %#x", name, want.Encoding, enc)
+		}
+		root, err := ssz.HashTreeRoot(val)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if root != want.Root {
+			t.Errorf("%s: expected root %#x, received %#x", name, want.Root, root)
+		}
+	}
+}
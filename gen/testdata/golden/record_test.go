@@ -0,0 +1,31 @@
+package golden
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	ssz "github.com/524119574/go-ssz"
+)
+
+func TestRecord(t *testing.T) {
+	records := make(map[string]record)
+	for name, val := range samples() {
+		enc, err := ssz.Marshal(val)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		root, err := ssz.HashTreeRoot(val)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		records[name] = record{Encoding: enc, Root: root}
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile("records.json", data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,108 @@
+// Package golden holds the types the generated methods are checked against:
+// record_test.go records their encodings and roots by reflection, and
+// compare_test.go checks the generated methods reproduce them.
+package golden
+
+import (
+	ssz "github.com/524119574/go-ssz"
+)
+
+type fork struct {
+	PreviousVersion [4]byte
+	CurrentVersion  [4]byte
+	Epoch           uint64
+}
+
+type beaconState struct {
+	BlockRoots [][]byte `ssz-size:"65536,32"`
+}
+
+type simpleNonProtoMessage struct {
+	Foo []byte
+	Bar uint64
+}
+
+type checkpoint struct {
+	Epoch uint64
+	Root  []byte `ssz-size:"32"`
+}
+
+type attestationData struct {
+	Slot   uint64
+	Index  uint16
+	Offset int32
+	Valid  bool
+	Source checkpoint
+	Target *checkpoint
+}
+
+type attestation struct {
+	AggregationBits ssz.Bitlist `ssz-max:"2048"`
+	Data            *attestationData
+	Signature       [96]byte
+}
+
+type block struct {
+	Slot         uint64
+	ParentRoot   [32]byte
+	Graffiti     string         `ssz-max:"32"`
+	Attestations []*attestation `ssz-max:"128"`
+	Roots        [][]byte       `ssz-size:"?,32" ssz-max:"64"`
+	Balances     []uint64       `ssz-max:"1024"`
+	Forks        [2]fork
+	Checkpoints  [3]checkpoint
+	Messages     []simpleNonProtoMessage `ssz-max:"4"`
+	Flags        []bool                  `ssz-max:"16"`
+	Skipped      uint64                  `ssz:"-"`
+}
+
+// record holds the encoding and root of a value computed by reflection.
+type record struct {
+	Encoding []byte
+	Root     [32]byte
+}
+
+// samples returns the values whose encodings are compared, by name.
+func samples() map[string]interface{} {
+	roots := make([][]byte, 65536)
+	for i := range roots {
+		roots[i] = make([]byte, 32)
+		roots[i][0] = byte(i)
+		roots[i][31] = byte(i >> 8)
+	}
+	root := func(b byte) []byte {
+		r := make([]byte, 32)
+		r[0] = b
+		return r
+	}
+	return map[string]interface{}{
+		"fork": &fork{
+			PreviousVersion: [4]byte{1, 2, 3, 4},
+			CurrentVersion:  [4]byte{5, 6, 7, 8},
+			Epoch:           18446744073709551615,
+		},
+		"zero fork":                       &fork{},
+		"beacon state":                    &beaconState{BlockRoots: roots},
+		"simple message":                  &simpleNonProtoMessage{Foo: []byte("foo"), Bar: 42},
+		"empty simple message":            &simpleNonProtoMessage{},
+		"attestation with nil data":       &attestation{AggregationBits: ssz.Bitlist{0x0d}},
+		"attestation with empty bitlist":  &attestation{Data: &attestationData{Source: checkpoint{Root: root(0)}}},
+		"attestation with nested targets": &attestation{AggregationBits: ssz.Bitlist{0xff, 0x01}, Data: &attestationData{Slot: 3, Index: 65535, Offset: -2, Valid: true, Source: checkpoint{Epoch: 1, Root: root(1)}, Target: &checkpoint{Epoch: 2, Root: root(2)}}, Signature: [96]byte{0: 1, 95: 2}},
+		"empty block":                     &block{},
+		"block": &block{
+			Slot:       9,
+			ParentRoot: [32]byte{0: 0xaa, 31: 0xbb},
+			Graffiti:   "hello",
+			Attestations: []*attestation{
+				{AggregationBits: ssz.Bitlist{0x05}, Data: &attestationData{Slot: 1, Source: checkpoint{Root: root(3)}}},
+				{AggregationBits: ssz.Bitlist{0x01}},
+			},
+			Roots:       [][]byte{root(4), root(5), root(6)},
+			Balances:    []uint64{32000000000, 0, 1},
+			Forks:       [2]fork{{Epoch: 1}, {PreviousVersion: [4]byte{1}, Epoch: 2}},
+			Checkpoints: [3]checkpoint{{Epoch: 1, Root: root(7)}, {}, {Epoch: 3, Root: root(8)}},
+			Messages:    []simpleNonProtoMessage{{Foo: []byte{1, 2}, Bar: 3}, {}, {Bar: 4}},
+			Flags:       []bool{true, false, true},
+		},
+	}
+}
@@ -0,0 +1,23 @@
+package ssz
+
+import (
+	"reflect"
+
+	"github.com/524119574/go-ssz/types"
+)
+
+// GeneralizedIndex returns the generalized index of the node reached by path in
+// the hash tree of values of typ, such as that of a field to prove with a
+// Proof. The elements of path are field names, element indices and "__len__"
+// for the length of a list:
+//
+//	index, err := GeneralizedIndex(reflect.TypeOf(BeaconState{}), "Validators", 3, "EffectiveBalance")
+//
+// Basic elements of lists and vectors are packed into chunks, so the index of
+// such an element is that of the chunk holding it. The tree is shaped by the
+// type alone, with the limits of lists declared by their ssz-max tags, so paths
+// into lists without one are rejected, as the shape of their trees depends on
+// their lengths.
+func GeneralizedIndex(typ reflect.Type, path ...interface{}) (uint64, error) {
+	return types.GeneralizedIndex(typ, path)
+}
@@ -0,0 +1,46 @@
+package ssz
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGeneralizedIndex_MatchesProofs(t *testing.T) {
+	state, proofs := proofFixture(t)
+	root := mustRoot(t, state)
+	paths := [][]interface{}{
+		{"Slot"},
+		{"Checkpoint"},
+		{"Roots", 1},
+		{"Checkpoint", "Epoch"},
+		{},
+	}
+	for i, path := range paths {
+		index, err := GeneralizedIndex(reflect.TypeOf(state), path...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if index != proofs[i].Index {
+			t.Errorf("Generalized index of %v is %d, expected %d", path, index, proofs[i].Index)
+		}
+		proof := *proofs[i]
+		proof.Index = index
+		if ok, err := VerifyProof(root, &proof); err != nil || !ok {
+			t.Errorf("Expected the proof of %v to be valid, received %v, %v", path, ok, err)
+		}
+	}
+}
+
+func TestGeneralizedIndex_Length(t *testing.T) {
+	index, err := GeneralizedIndex(reflect.TypeOf(proofState{}), "Roots", "__len__")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The length is the right sibling of the data tree of the list at 5.
+	if index != 5*2+1 {
+		t.Errorf("Generalized index of the length is %d, expected %d", index, 5*2+1)
+	}
+	if _, err := GeneralizedIndex(reflect.TypeOf(proofState{}), "Slot", "__len__"); err == nil {
+		t.Error("Expected the length of a uint64 to be rejected")
+	}
+}
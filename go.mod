@@ -8,6 +8,7 @@ require (
 	github.com/ethereum/go-ethereum v1.9.25
 	github.com/ferranbt/fastssz v0.0.0-20201210095258-318e164fe1dd
 	github.com/ghodss/yaml v1.0.0
+	github.com/golang/snappy v0.0.3-0.20201103224600-674baa8c7fc3
 	github.com/minio/highwayhash v1.0.1
 	github.com/minio/sha256-simd v0.1.1
 	github.com/pkg/errors v0.9.1
@@ -0,0 +1,91 @@
+package ssz
+
+import (
+	"github.com/pkg/errors"
+	"github.com/524119574/go-ssz/types"
+)
+
+// HashCache holds caches of its own, which computations given it with
+// WithHashCache consult instead of the caches shared by the rest of the
+// process. Close stops its goroutines once it is no longer used.
+type HashCache = types.HashCache
+
+// NewHashCache returns an empty cache for WithHashCache.
+func NewHashCache() *HashCache {
+	return types.NewHashCache()
+}
+
+// HashOption configures a single computation of HashTreeRootOpts.
+type HashOption func(o *hashOptions)
+
+type hashOptions struct {
+	cache     *bool
+	hashCache *HashCache
+	hasher    string
+}
+
+// WithCache makes the computation consult the caches if enabled is true, and
+// hash everything from scratch otherwise, whatever types.ToggleCache was last
+// called with. It takes precedence over WithHashCache.
+func WithCache(enabled bool) HashOption {
+	return func(o *hashOptions) {
+		o.cache = &enabled
+	}
+}
+
+// WithHashCache makes the computation consult cache, rather than the caches
+// shared by the rest of the process, and enables caching for it.
+func WithHashCache(cache *HashCache) HashOption {
+	return func(o *hashOptions) {
+		o.hashCache = cache
+	}
+}
+
+// WithHasher makes the computation merkleize with the hash function registered
+// under name by RegisterHasher, as HashTreeRootWithHasher does.
+func WithHasher(name string) HashOption {
+	return func(o *hashOptions) {
+		o.hasher = name
+	}
+}
+
+// HashTreeRootOpts determines the root hash of val as HashTreeRoot does, with
+// the given options rather than the settings of the process, so that a hot
+// state can be hashed with caching while values being verified are hashed from
+// scratch at the same time:
+//
+//  cache := ssz.NewHashCache()
+//  defer cache.Close()
+//  headRoot, err := ssz.HashTreeRootOpts(headState, ssz.WithHashCache(cache))
+//  ...
+//  root, err := ssz.HashTreeRootOpts(block, ssz.WithCache(false))
+func HashTreeRootOpts(val interface{}, opts ...HashOption) ([32]byte, error) {
+	return observeHashTreeRoot(val, func() ([32]byte, error) {
+		root, err := hashTreeRootOpts(val, opts)
+		return root, newError(OpHash, val, err)
+	})
+}
+
+func hashTreeRootOpts(val interface{}, opts []HashOption) ([32]byte, error) {
+	if val == nil {
+		return [32]byte{}, errors.New("untyped nil is not supported")
+	}
+	o := &hashOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	var ctx *types.HashContext
+	if o.hasher != "" {
+		var err error
+		if ctx, err = ctx.WithHasher(o.hasher); err != nil {
+			return [32]byte{}, err
+		}
+	}
+	if o.hashCache != nil {
+		ctx = ctx.WithHashCache(o.hashCache)
+	}
+	if o.cache != nil {
+		ctx = ctx.WithCache(*o.cache)
+	}
+	return rootWithContext(val, ctx)
+}
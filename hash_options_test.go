@@ -0,0 +1,157 @@
+package ssz
+
+import (
+	"crypto/sha512"
+	"sync"
+	"testing"
+
+	"github.com/524119574/go-ssz/types"
+)
+
+type optsState struct {
+	Slot       uint64
+	BlockRoots [256][32]byte
+}
+
+func optsStateFixture(seed byte) *optsState {
+	state := &optsState{Slot: uint64(seed)}
+	for i := range state.BlockRoots {
+		state.BlockRoots[i][0], state.BlockRoots[i][1] = byte(i), seed
+	}
+	return state
+}
+
+// optsRootAndHashes returns the root of val computed with opts, along with the
+// number of hashes computing it took.
+func optsRootAndHashes(t *testing.T, val interface{}, opts ...HashOption) ([32]byte, uint64) {
+	var root [32]byte
+	hashes := types.CountHashes(func() {
+		var err error
+		if root, err = HashTreeRootOpts(val, opts...); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return root, hashes
+}
+
+func TestHashTreeRootOpts_WithCacheOverridesToggle(t *testing.T) {
+	state := optsStateFixture(1)
+	want, err := HashTreeRoot(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types.ToggleCache(true)
+	defer types.ToggleCache(false)
+	cold, coldHashes := optsRootAndHashes(t, state, WithCache(false))
+	again, againHashes := optsRootAndHashes(t, state, WithCache(false))
+	if cold != want || again != want {
+		t.Errorf("Expected root %#x, received %#x and %#x", want, cold, again)
+	}
+	if againHashes != coldHashes {
+		t.Errorf("Expected every computation without the cache to hash as much, received %d and %d hashes", coldHashes, againHashes)
+	}
+
+	types.ToggleCache(false)
+	optsRootAndHashes(t, state, WithCache(true))
+	state.BlockRoots[7][0]++
+	if want, err = HashTreeRoot(state); err != nil {
+		t.Fatal(err)
+	}
+	warm, warmHashes := optsRootAndHashes(t, state, WithCache(true))
+	if warm != want {
+		t.Errorf("Expected root %#x, received %#x", want, warm)
+	}
+	// The branch of the changed root (8 hashes) and the state (1 hash).
+	if warmHashes > 9 {
+		t.Errorf("Expected at most 9 hashes with the cache enabled for the call, received %d", warmHashes)
+	}
+}
+
+func TestHashTreeRootOpts_HashCachesKeptApart(t *testing.T) {
+	head, verified := NewHashCache(), NewHashCache()
+	defer head.Close()
+	defer verified.Close()
+	first, second := optsStateFixture(1), optsStateFixture(2)
+	// Hashed by value, both states would share the trie of their field in a
+	// single cache.
+	optsRootAndHashes(t, *first, WithHashCache(head))
+	optsRootAndHashes(t, *second, WithHashCache(verified))
+	for i := 0; i < 3; i++ {
+		for _, tt := range []struct {
+			state *optsState
+			cache *HashCache
+		}{{first, head}, {second, verified}} {
+			tt.state.BlockRoots[i][2]++
+			want, err := HashTreeRoot(tt.state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			root, hashes := optsRootAndHashes(t, *tt.state, WithHashCache(tt.cache))
+			if root != want {
+				t.Errorf("Expected root %#x, received %#x", want, root)
+			}
+			if hashes > 9 {
+				t.Errorf("Expected at most 9 hashes with a cache of its own, received %d", hashes)
+			}
+		}
+	}
+	// Disabling the cache takes precedence over the cache of the call.
+	if _, hashes := optsRootAndHashes(t, *first, WithHashCache(head), WithCache(false)); hashes < 255 {
+		t.Errorf("Expected the whole trie to be hashed with caching disabled, received %d hashes", hashes)
+	}
+}
+
+func TestHashTreeRootOpts_ConcurrentCachedAndCold(t *testing.T) {
+	cache := NewHashCache()
+	defer cache.Close()
+	states := []*optsState{optsStateFixture(1), optsStateFixture(2)}
+	want := make([][4][32]byte, len(states))
+	for j, state := range states {
+		copied := *state
+		for i := range want[j] {
+			copied.BlockRoots[i*50][3]++
+			var err error
+			if want[j][i], err = HashTreeRoot(&copied); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	var wg sync.WaitGroup
+	for j, opt := range []HashOption{WithHashCache(cache), WithCache(false)} {
+		wg.Add(1)
+		go func(j int, opt HashOption) {
+			defer wg.Done()
+			state := states[j]
+			for i := range want[j] {
+				state.BlockRoots[i*50][3]++
+				root, err := HashTreeRootOpts(state, opt)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if root != want[j][i] {
+					t.Errorf("Expected root %#x of state %d, received %#x", want[j][i], j, root)
+				}
+			}
+		}(j, opt)
+	}
+	wg.Wait()
+}
+
+func TestHashTreeRootOpts_WithHasher(t *testing.T) {
+	withTestHasher(t)
+	state := &hasherState{Slot: 3, Balances: []uint64{9}}
+	root, err := HashTreeRootOpts(state, WithHasher(testHasher), WithCache(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := referenceStateRoot(sha512.Sum512_256, state); root != want {
+		t.Errorf("Expected root %#x, received %#x", want, root)
+	}
+	if _, err := HashTreeRootOpts(state, WithHasher("keccak1024")); err == nil {
+		t.Error("Expected hashing with an unknown hasher to fail")
+	}
+	if _, err := HashTreeRootOpts(nil); err == nil {
+		t.Error("Expected hashing an untyped nil to fail")
+	}
+}
@@ -0,0 +1,51 @@
+package ssz
+
+import (
+	"github.com/pkg/errors"
+	"github.com/524119574/go-ssz/types"
+)
+
+// SHA256 names the hash function of the Simple Serialize specification, which
+// HashTreeRoot merkleizes with.
+const SHA256 = types.SHA256
+
+// RegisterHasher registers fn as a hash function HashTreeRootWithHasher can
+// merkleize with under the given name, such as keccak256 for roots verified by
+// EVM contracts:
+//
+//  err := RegisterHasher("keccak256", func(data []byte) (out [32]byte) {
+//      h := sha3.NewLegacyKeccak256()
+//      h.Write(data)
+//      copy(out[:], h.Sum(nil))
+//      return out
+//  })
+//
+// fn must be safe for concurrent use. Registering the same name more than once
+// returns an error.
+func RegisterHasher(name string, fn func(data []byte) [32]byte) error {
+	return types.RegisterHasher(name, fn)
+}
+
+// HashTreeRootWithHasher determines the root hash of val as HashTreeRoot does,
+// with every node of its tries, padding included, hashed with the hash function
+// registered under the given name by RegisterHasher rather than with sha256.
+// Types implementing HashRooter are hashed by their layout instead, as their
+// roots are computed with sha256. Roots cached for one hash function are never
+// returned for another.
+func HashTreeRootWithHasher(val interface{}, hasher string) ([32]byte, error) {
+	return observeHashTreeRoot(val, func() ([32]byte, error) {
+		root, err := hashTreeRootWithHasher(val, hasher)
+		return root, newError(OpHash, val, err)
+	})
+}
+
+func hashTreeRootWithHasher(val interface{}, hasher string) ([32]byte, error) {
+	if val == nil {
+		return [32]byte{}, errors.New("untyped nil is not supported")
+	}
+	ctx, err := (*types.HashContext)(nil).WithHasher(hasher)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return rootWithContext(val, ctx)
+}
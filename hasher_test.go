@@ -0,0 +1,196 @@
+package ssz
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/524119574/go-ssz/types"
+)
+
+const testHasher = "sha512_256"
+
+var registerTestHasher sync.Once
+
+// withTestHasher registers SHA-512/256 as a hash function other than sha256 to
+// merkleize with, once for all tests.
+func withTestHasher(t *testing.T) {
+	registerTestHasher.Do(func() {
+		if err := RegisterHasher(testHasher, sha512.Sum512_256); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// referenceRoot merkleizes chunks padded to limit chunks with hash, hashing
+// every node of the padding.
+func referenceRoot(hash func([]byte) [32]byte, chunks [][32]byte, limit int) [32]byte {
+	width := 1
+	for width < limit {
+		width *= 2
+	}
+	layer := make([][32]byte, width)
+	copy(layer, chunks)
+	for len(layer) > 1 {
+		next := make([][32]byte, len(layer)/2)
+		for i := range next {
+			next[i] = hash(append(append([]byte{}, layer[2*i][:]...), layer[2*i+1][:]...))
+		}
+		layer = next
+	}
+	return layer[0]
+}
+
+func referenceMixInLength(hash func([]byte) [32]byte, root [32]byte, length uint64) [32]byte {
+	var chunk [32]byte
+	binary.LittleEndian.PutUint64(chunk[:], length)
+	return hash(append(root[:], chunk[:]...))
+}
+
+type hasherCheckpoint struct {
+	Epoch uint64
+	Roots [3][32]byte
+}
+
+type hasherState struct {
+	Slot       uint64
+	Balances   []uint64 `ssz-max:"64"`
+	Checkpoint hasherCheckpoint
+}
+
+// referenceStateRoot returns the root of state hashed with hash.
+func referenceStateRoot(hash func([]byte) [32]byte, state *hasherState) [32]byte {
+	var slot, balances [32]byte
+	binary.LittleEndian.PutUint64(slot[:], state.Slot)
+	var chunks [][32]byte
+	for i, balance := range state.Balances {
+		if i%4 == 0 {
+			chunks = append(chunks, [32]byte{})
+		}
+		binary.LittleEndian.PutUint64(chunks[len(chunks)-1][8*(i%4):], balance)
+	}
+	balances = referenceMixInLength(hash, referenceRoot(hash, chunks, 16), uint64(len(state.Balances)))
+	var epoch [32]byte
+	binary.LittleEndian.PutUint64(epoch[:], state.Checkpoint.Epoch)
+	checkpoint := referenceRoot(hash, [][32]byte{
+		epoch,
+		referenceRoot(hash, state.Checkpoint.Roots[:], 3),
+	}, 2)
+	return referenceRoot(hash, [][32]byte{slot, balances, checkpoint}, 3)
+}
+
+func TestHashTreeRootWithHasher(t *testing.T) {
+	withTestHasher(t)
+	state := &hasherState{
+		Slot:       9,
+		Balances:   []uint64{1, 2, 3, 4, 5},
+		Checkpoint: hasherCheckpoint{Epoch: 2, Roots: [3][32]byte{{1}, {2}, {3}}},
+	}
+	root, err := HashTreeRootWithHasher(state, testHasher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := referenceStateRoot(sha512.Sum512_256, state); root != want {
+		t.Errorf("Expected root %#x, received %#x", want, root)
+	}
+	shaRoot, err := HashTreeRootWithHasher(state, SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := HashTreeRoot(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shaRoot != want {
+		t.Errorf("Expected the root HashTreeRoot computes, %#x, received %#x", want, shaRoot)
+	}
+}
+
+func TestHashTreeRootWithHasher_PaddingOfLargeLimits(t *testing.T) {
+	withTestHasher(t)
+	// The padding of a list of 2^40 elements is taken from the zero hashes of
+	// the hasher.
+	list := []uint64{7}
+	root, err := HashTreeRootWithHasher(&struct {
+		Balances []uint64 `ssz-max:"1099511627776"`
+	}{Balances: list}, testHasher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := [32]byte{7}
+	zero := [32]byte{}
+	for depth := 0; depth < 38; depth++ {
+		node = sha512.Sum512_256(append(node[:], zero[:]...))
+		zero = sha512.Sum512_256(append(zero[:], zero[:]...))
+	}
+	if want := referenceMixInLength(sha512.Sum512_256, node, 1); root != want {
+		t.Errorf("Expected root %#x, received %#x", want, root)
+	}
+}
+
+func TestHashTreeRootWithHasher_CachedPerHasher(t *testing.T) {
+	withTestHasher(t)
+	types.ToggleCache(true)
+	defer types.ToggleCache(false)
+	state := &hasherState{
+		Slot:       1,
+		Balances:   []uint64{1},
+		Checkpoint: hasherCheckpoint{Roots: [3][32]byte{{4}, {5}, {6}}},
+	}
+	want := map[string][32]byte{
+		testHasher: referenceStateRoot(sha512.Sum512_256, state),
+	}
+	var err error
+	if want[SHA256], err = HashTreeRoot(state); err != nil {
+		t.Fatal(err)
+	}
+	// Hashing the same values in turns with each hash function would return the
+	// roots of the other one if the caches were shared.
+	for i := 0; i < 3; i++ {
+		for _, hasher := range []string{testHasher, SHA256} {
+			root, err := HashTreeRootWithHasher(state, hasher)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if root != want[hasher] {
+				t.Errorf("Expected %s root %#x, received %#x", hasher, want[hasher], root)
+			}
+		}
+	}
+}
+
+func TestHashTreeRootWithHasher_IgnoresHashRooters(t *testing.T) {
+	withTestHasher(t)
+	val := &struct {
+		Balances gweiBalances `ssz-max:"16"`
+	}{Balances: gweiBalances{1, 2}}
+	root, err := HashTreeRootWithHasher(val, testHasher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := [32]byte{1, 0, 0, 0, 0, 0, 0, 0, 2}
+	balances := referenceMixInLength(sha512.Sum512_256, referenceRoot(sha512.Sum512_256, [][32]byte{chunk}, 4), 2)
+	if root != balances {
+		t.Errorf("Expected the root of the layout of the balances, %#x, received %#x", balances, root)
+	}
+}
+
+func TestRegisterHasher_Errors(t *testing.T) {
+	withTestHasher(t)
+	for _, name := range []string{SHA256, testHasher} {
+		if err := RegisterHasher(name, sha512.Sum512_256); err == nil || !strings.Contains(err.Error(), "already registered") {
+			t.Errorf("Expected registering %s again to fail, received %v", name, err)
+		}
+	}
+	if err := RegisterHasher("", sha512.Sum512_256); err == nil {
+		t.Error("Expected registering a hasher without a name to fail")
+	}
+	if err := RegisterHasher("nil", nil); err == nil {
+		t.Error("Expected registering a nil hash function to fail")
+	}
+	if _, err := HashTreeRootWithHasher(&hasherState{}, "keccak1024"); err == nil || !strings.Contains(err.Error(), "no hasher named") {
+		t.Errorf("Expected hashing with an unknown hasher to fail, received %v", err)
+	}
+}
@@ -0,0 +1,564 @@
+package ssz
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/protolambda/zssz"
+)
+
+// Containers interleaving fixed and variable-size fields, which exercise the
+// bookkeeping between the fixed section and the offsets into the variable one.
+type fixedVarFixed struct {
+	Slot  uint64
+	Data  []byte
+	Epoch uint64
+}
+
+type varFixedVar struct {
+	Head  []uint16
+	Epoch uint64
+	Tail  []byte
+}
+
+type fixedVarVarFixed struct {
+	Slot    uint64
+	Data    []byte
+	Indices []uint64
+	Epoch   uint64
+}
+
+// Containers mixing bool vectors and lists with other fields, where every bool
+// takes up a single byte of the fixed or variable section.
+type boolsFixedVar struct {
+	Flags [3]bool
+	Data  []byte
+	Slot  uint64
+}
+
+type varBoolsVar struct {
+	Head  []uint16
+	Flags [4]bool
+	Votes []bool
+}
+
+type fixedBoolListBoolsVar struct {
+	Slot  uint32
+	Votes []bool
+	Flags [2]bool
+	Tail  []byte
+}
+
+type boolFlags struct {
+	Flags [3]bool
+	Slot  uint16
+}
+
+type boolFlagsList struct {
+	Items []boolFlags
+	Votes []bool
+}
+
+// Containers with fields of 1, 2 and 3 bytes between variable-size fields, such
+// as the justification bits of the beacon state, where the fixed section
+// advances by less than an offset.
+type oneByteBetweenVars struct {
+	Slot              uint64
+	Roots             []uint64
+	JustificationBits [1]byte
+	Balances          []uint64
+}
+
+type taggedOneByteBetweenVars struct {
+	Roots             []uint64
+	JustificationBits []byte `ssz-size:"1"`
+	Balances          []uint64
+}
+
+type twoBytesBetweenVars struct {
+	Head  []byte
+	Bits  [2]byte
+	Tail  []uint16
+	Epoch uint16
+}
+
+type threeBytesBetweenVars struct {
+	Head []uint32
+	Bits [3]byte
+	Tail []byte
+	Flag uint8
+}
+
+// Containers whose first field is variable-size, so that their fixed section
+// starts with an offset rather than with a fixed-size field.
+type varFirstBytes struct {
+	Data []byte
+	Slot uint64
+	Flag bool
+}
+
+type varFirstTagged struct {
+	Roots [][]byte `ssz-size:"?,4"`
+	Epoch uint64
+}
+
+type varFirstContainers struct {
+	Items []fork
+	Slot  uint32
+}
+
+type varFirstTaggedLists struct {
+	Lists [][]byte `ssz-size:"2,?"`
+	Slot  uint16
+}
+
+// The golden encodings are checked against zssz, an implementation of SSZ
+// independent of this one. zssz reads the limits of lists from a Limit method
+// of their type, so each fixture has a reference type of the same shape whose
+// lists have one, which zsszReference copies the fixture into.
+
+const zsszListLimit = 1024
+
+type zsszBytes []byte
+
+func (*zsszBytes) Limit() uint64 { return zsszListLimit }
+
+type zsszUint16s []uint16
+
+func (*zsszUint16s) Limit() uint64 { return zsszListLimit }
+
+type zsszUint32s []uint32
+
+func (*zsszUint32s) Limit() uint64 { return zsszListLimit }
+
+type zsszUint64s []uint64
+
+func (*zsszUint64s) Limit() uint64 { return zsszListLimit }
+
+type zsszBools []bool
+
+func (*zsszBools) Limit() uint64 { return zsszListLimit }
+
+type zsszBoolFlags []boolFlags
+
+func (*zsszBoolFlags) Limit() uint64 { return zsszListLimit }
+
+type zsszBytes4s [][4]byte
+
+func (*zsszBytes4s) Limit() uint64 { return zsszListLimit }
+
+type zsszForks []fork
+
+func (*zsszForks) Limit() uint64 { return zsszListLimit }
+
+type fixedVarFixedReference struct {
+	Slot  uint64
+	Data  zsszBytes
+	Epoch uint64
+}
+
+type varFixedVarReference struct {
+	Head  zsszUint16s
+	Epoch uint64
+	Tail  zsszBytes
+}
+
+type fixedVarVarFixedReference struct {
+	Slot    uint64
+	Data    zsszBytes
+	Indices zsszUint64s
+	Epoch   uint64
+}
+
+type boolsFixedVarReference struct {
+	Flags [3]bool
+	Data  zsszBytes
+	Slot  uint64
+}
+
+type varBoolsVarReference struct {
+	Head  zsszUint16s
+	Flags [4]bool
+	Votes zsszBools
+}
+
+type fixedBoolListBoolsVarReference struct {
+	Slot  uint32
+	Votes zsszBools
+	Flags [2]bool
+	Tail  zsszBytes
+}
+
+type boolFlagsListReference struct {
+	Items zsszBoolFlags
+	Votes zsszBools
+}
+
+type oneByteBetweenVarsReference struct {
+	Slot              uint64
+	Roots             zsszUint64s
+	JustificationBits [1]byte
+	Balances          zsszUint64s
+}
+
+type taggedOneByteBetweenVarsReference struct {
+	Roots             zsszUint64s
+	JustificationBits [1]byte
+	Balances          zsszUint64s
+}
+
+type twoBytesBetweenVarsReference struct {
+	Head  zsszBytes
+	Bits  [2]byte
+	Tail  zsszUint16s
+	Epoch uint16
+}
+
+type threeBytesBetweenVarsReference struct {
+	Head zsszUint32s
+	Bits [3]byte
+	Tail zsszBytes
+	Flag uint8
+}
+
+type varFirstBytesReference struct {
+	Data zsszBytes
+	Slot uint64
+	Flag bool
+}
+
+type varFirstTaggedReference struct {
+	Roots zsszBytes4s
+	Epoch uint64
+}
+
+type varFirstContainersReference struct {
+	Items zsszForks
+	Slot  uint32
+}
+
+type varFirstTaggedListsReference struct {
+	Lists [2]zsszBytes
+	Slot  uint16
+}
+
+var zsszReferenceTypes = map[reflect.Type]reflect.Type{
+	reflect.TypeOf(fixedVarFixed{}):            reflect.TypeOf(fixedVarFixedReference{}),
+	reflect.TypeOf(varFixedVar{}):              reflect.TypeOf(varFixedVarReference{}),
+	reflect.TypeOf(fixedVarVarFixed{}):         reflect.TypeOf(fixedVarVarFixedReference{}),
+	reflect.TypeOf(boolsFixedVar{}):            reflect.TypeOf(boolsFixedVarReference{}),
+	reflect.TypeOf(varBoolsVar{}):              reflect.TypeOf(varBoolsVarReference{}),
+	reflect.TypeOf(fixedBoolListBoolsVar{}):    reflect.TypeOf(fixedBoolListBoolsVarReference{}),
+	reflect.TypeOf(boolFlagsList{}):            reflect.TypeOf(boolFlagsListReference{}),
+	reflect.TypeOf(oneByteBetweenVars{}):       reflect.TypeOf(oneByteBetweenVarsReference{}),
+	reflect.TypeOf(taggedOneByteBetweenVars{}): reflect.TypeOf(taggedOneByteBetweenVarsReference{}),
+	reflect.TypeOf(twoBytesBetweenVars{}):      reflect.TypeOf(twoBytesBetweenVarsReference{}),
+	reflect.TypeOf(threeBytesBetweenVars{}):    reflect.TypeOf(threeBytesBetweenVarsReference{}),
+	reflect.TypeOf(varFirstBytes{}):            reflect.TypeOf(varFirstBytesReference{}),
+	reflect.TypeOf(varFirstTagged{}):           reflect.TypeOf(varFirstTaggedReference{}),
+	reflect.TypeOf(varFirstContainers{}):       reflect.TypeOf(varFirstContainersReference{}),
+	reflect.TypeOf(varFirstTaggedLists{}):      reflect.TypeOf(varFirstTaggedListsReference{}),
+}
+
+// zsszReference copies src into dst, whose type has the same shape, matching
+// the fields of containers by name and converting lists and vectors element
+// by element.
+func zsszReference(src reflect.Value, dst reflect.Value) {
+	switch dst.Kind() {
+	case reflect.Struct:
+		for i := 0; i < dst.NumField(); i++ {
+			zsszReference(src.FieldByName(dst.Type().Field(i).Name), dst.Field(i))
+		}
+	case reflect.Slice:
+		dst.Set(reflect.MakeSlice(dst.Type(), src.Len(), src.Len()))
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < dst.Len(); i++ {
+			zsszReference(src.Index(i), dst.Index(i))
+		}
+	default:
+		dst.Set(src.Convert(dst.Type()))
+	}
+}
+
+var interleavedFieldFixtures = []struct {
+	name   string
+	value  interface{}
+	golden string
+}{
+	{
+		name:   "fixed-variable-fixed",
+		value:  &fixedVarFixed{Slot: 1, Data: []byte{0xaa, 0xbb, 0xcc}, Epoch: 2},
+		golden: "0100000000000000" + "14000000" + "0200000000000000" + "aabbcc",
+	},
+	{
+		name:   "fixed-variable-fixed with empty variable field",
+		value:  &fixedVarFixed{Slot: 1, Data: []byte{}, Epoch: 2},
+		golden: "0100000000000000" + "14000000" + "0200000000000000",
+	},
+	{
+		name:   "variable-fixed-variable",
+		value:  &varFixedVar{Head: []uint16{3, 4}, Epoch: 5, Tail: []byte{0xdd}},
+		golden: "10000000" + "0500000000000000" + "14000000" + "03000400" + "dd",
+	},
+	{
+		name:   "variable-fixed-variable with empty head",
+		value:  &varFixedVar{Head: []uint16{}, Epoch: 5, Tail: []byte{0xdd, 0xee}},
+		golden: "10000000" + "0500000000000000" + "10000000" + "ddee",
+	},
+	{
+		name:   "variable-fixed-variable with empty tail",
+		value:  &varFixedVar{Head: []uint16{7}, Epoch: 5, Tail: []byte{}},
+		golden: "10000000" + "0500000000000000" + "12000000" + "0700",
+	},
+	{
+		name:   "fixed-variable-variable-fixed",
+		value:  &fixedVarVarFixed{Slot: 9, Data: []byte{1, 2}, Indices: []uint64{6}, Epoch: 10},
+		golden: "0900000000000000" + "18000000" + "1a000000" + "0a00000000000000" + "0102" + "0600000000000000",
+	},
+	{
+		name:   "fixed-variable-variable-fixed with empty first variable field",
+		value:  &fixedVarVarFixed{Slot: 9, Data: []byte{}, Indices: []uint64{6, 7}, Epoch: 10},
+		golden: "0900000000000000" + "18000000" + "18000000" + "0a00000000000000" + "0600000000000000" + "0700000000000000",
+	},
+	{
+		name:   "fixed-variable-variable-fixed with empty variable fields",
+		value:  &fixedVarVarFixed{Slot: 9, Data: []byte{}, Indices: []uint64{}, Epoch: 10},
+		golden: "0900000000000000" + "18000000" + "18000000" + "0a00000000000000",
+	},
+	{
+		name:   "bool vector-variable-fixed",
+		value:  &boolsFixedVar{Flags: [3]bool{true, false, true}, Data: []byte{0xaa}, Slot: 3},
+		golden: "010001" + "0f000000" + "0300000000000000" + "aa",
+	},
+	{
+		name:   "variable-bool vector-bool list",
+		value:  &varBoolsVar{Head: []uint16{1}, Flags: [4]bool{false, true, true, false}, Votes: []bool{true, true, false}},
+		golden: "0c000000" + "00010100" + "0e000000" + "0100" + "010100",
+	},
+	{
+		name:   "variable-bool vector-bool list with empty lists",
+		value:  &varBoolsVar{Head: []uint16{}, Flags: [4]bool{true, true, true, true}, Votes: []bool{}},
+		golden: "0c000000" + "01010101" + "0c000000",
+	},
+	{
+		name:   "fixed-bool list-bool vector-variable",
+		value:  &fixedBoolListBoolsVar{Slot: 7, Votes: []bool{false, true}, Flags: [2]bool{true, false}, Tail: []byte{0xbb, 0xcc}},
+		golden: "07000000" + "0e000000" + "0100" + "10000000" + "0001" + "bbcc",
+	},
+	{
+		name: "list of containers with bool vectors-bool list",
+		value: &boolFlagsList{
+			Items: []boolFlags{{Flags: [3]bool{true, false, false}, Slot: 1}, {Slot: 2}},
+			Votes: []bool{true},
+		},
+		golden: "08000000" + "12000000" + "0100000100" + "0000000200" + "01",
+	},
+	{
+		name:   "1-byte field between variable fields",
+		value:  &oneByteBetweenVars{Slot: 4, Roots: []uint64{1}, JustificationBits: [1]byte{0x0f}, Balances: []uint64{2, 3}},
+		golden: "0400000000000000" + "11000000" + "0f" + "19000000" + "0100000000000000" + "0200000000000000" + "0300000000000000",
+	},
+	{
+		name:   "1-byte field between empty variable fields",
+		value:  &oneByteBetweenVars{Slot: 4, Roots: []uint64{}, JustificationBits: [1]byte{0x0a}, Balances: []uint64{}},
+		golden: "0400000000000000" + "11000000" + "0a" + "11000000",
+	},
+	{
+		name:   "tagged 1-byte field between variable fields",
+		value:  &taggedOneByteBetweenVars{Roots: []uint64{1, 2}, JustificationBits: []byte{0x05}, Balances: []uint64{3}},
+		golden: "09000000" + "05" + "19000000" + "0100000000000000" + "0200000000000000" + "0300000000000000",
+	},
+	{
+		name:   "2-byte field between variable fields",
+		value:  &twoBytesBetweenVars{Head: []byte{0xaa}, Bits: [2]byte{0x01, 0x80}, Tail: []uint16{6, 7}, Epoch: 8},
+		golden: "0c000000" + "0180" + "0d000000" + "0800" + "aa" + "0600" + "0700",
+	},
+	{
+		name:   "2-byte field between empty head and variable field",
+		value:  &twoBytesBetweenVars{Head: []byte{}, Bits: [2]byte{0xff, 0xff}, Tail: []uint16{9}, Epoch: 1},
+		golden: "0c000000" + "ffff" + "0c000000" + "0100" + "0900",
+	},
+	{
+		name:   "3-byte field between variable fields",
+		value:  &threeBytesBetweenVars{Head: []uint32{5}, Bits: [3]byte{1, 2, 3}, Tail: []byte{0xbb, 0xcc}, Flag: 1},
+		golden: "0c000000" + "010203" + "10000000" + "01" + "05000000" + "bbcc",
+	},
+	{
+		name:   "3-byte field between variable fields with empty tail",
+		value:  &threeBytesBetweenVars{Head: []uint32{5, 6}, Bits: [3]byte{0xee, 0, 0xdd}, Tail: []byte{}, Flag: 0},
+		golden: "0c000000" + "ee00dd" + "14000000" + "00" + "05000000" + "06000000",
+	},
+	{
+		name:   "variable bytes first",
+		value:  &varFirstBytes{Data: []byte{0xaa, 0xbb}, Slot: 3, Flag: true},
+		golden: "0d000000" + "0300000000000000" + "01" + "aabb",
+	},
+	{
+		name:   "empty variable bytes first",
+		value:  &varFirstBytes{Data: []byte{}, Slot: 3},
+		golden: "0d000000" + "0300000000000000" + "00",
+	},
+	{
+		name:   "tagged list of vectors first",
+		value:  &varFirstTagged{Roots: [][]byte{{1, 2, 3, 4}, {5, 6, 7, 8}}, Epoch: 6},
+		golden: "0c000000" + "0600000000000000" + "01020304" + "05060708",
+	},
+	{
+		name:   "empty tagged list of vectors first",
+		value:  &varFirstTagged{Roots: [][]byte{}, Epoch: 6},
+		golden: "0c000000" + "0600000000000000",
+	},
+	{
+		name:   "list of containers first",
+		value:  &varFirstContainers{Items: []fork{{PreviousVersion: [4]byte{1}, CurrentVersion: [4]byte{2}, Epoch: 3}}, Slot: 4},
+		golden: "08000000" + "04000000" + "01000000" + "02000000" + "0300000000000000",
+	},
+	{
+		name:   "tagged vector of lists first",
+		value:  &varFirstTaggedLists{Lists: [][]byte{{0xaa}, {0xbb, 0xcc}}, Slot: 5},
+		golden: "06000000" + "0500" + "08000000" + "09000000" + "aa" + "bbcc",
+	},
+	{
+		name:   "tagged vector of empty lists first",
+		value:  &varFirstTaggedLists{Lists: [][]byte{{}, {}}, Slot: 5},
+		golden: "06000000" + "0500" + "08000000" + "08000000",
+	},
+}
+
+func TestInterleavedFields_GoldenMatchesZssz(t *testing.T) {
+	for _, tt := range interleavedFieldFixtures {
+		t.Run(tt.name, func(t *testing.T) {
+			refTyp, ok := zsszReferenceTypes[reflect.TypeOf(tt.value).Elem()]
+			if !ok {
+				t.Fatalf("No zssz reference type for %T", tt.value)
+			}
+			ref := reflect.New(refTyp)
+			zsszReference(reflect.ValueOf(tt.value).Elem(), ref.Elem())
+			var buf bytes.Buffer
+			if _, err := zssz.Encode(&buf, ref.Interface(), zssz.GetSSZ(ref.Interface())); err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(buf.Bytes()); got != tt.golden {
+				t.Errorf("Expected zssz encoding %s, received %s", tt.golden, got)
+			}
+		})
+	}
+}
+
+func TestInterleavedFields_Marshal(t *testing.T) {
+	for _, tt := range interleavedFieldFixtures {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := Marshal(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden, err := hex.DecodeString(tt.golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(enc, golden) {
+				t.Errorf("Expected encoding %s, received %#x", tt.golden, enc)
+			}
+		})
+	}
+}
+
+func TestInterleavedFields_Unmarshal(t *testing.T) {
+	for _, tt := range interleavedFieldFixtures {
+		t.Run(tt.name, func(t *testing.T) {
+			golden, err := hex.DecodeString(tt.golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded := reflect.New(reflect.TypeOf(tt.value).Elem()).Interface()
+			if err := Unmarshal(golden, decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tt.value, decoded) {
+				t.Errorf("Expected %+v, received %+v", tt.value, decoded)
+			}
+		})
+	}
+}
+
+func TestInterleavedFields_TruncatedFixedPart(t *testing.T) {
+	item := &fixedVarVarFixed{Slot: 9, Data: []byte{1, 2}, Indices: []uint64{6}, Epoch: 10}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fixed part holds Slot, the two offsets and Epoch.
+	const fixedPartSize = 24
+	for n := 1; n < fixedPartSize; n++ {
+		err := Unmarshal(enc[:n], &fixedVarVarFixed{})
+		if err == nil {
+			t.Fatalf("Expected input truncated to %d bytes to be rejected", n)
+		}
+		want := fmt.Sprintf("expected %d bytes for its fixed part, received %d", fixedPartSize, n)
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error containing %q for input truncated to %d bytes, received %v", want, n, err)
+		}
+	}
+}
+
+func TestInterleavedFields_TruncatedOffsetTable(t *testing.T) {
+	type wrapper struct {
+		Items []*fixedVarFixed
+	}
+	item := &wrapper{Items: []*fixedVarFixed{{Slot: 1, Data: []byte{2}}, {Slot: 3}}}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cut the list right inside its own offset table, after the offset of
+	// the wrapper and 2 bytes into the offsets of its elements.
+	for _, n := range []int{5, 6, 7, 9, 10, 11} {
+		if err := Unmarshal(enc[:n], &wrapper{}); err == nil {
+			t.Errorf("Expected input truncated to %d bytes to be rejected", n)
+		}
+	}
+}
+
+func TestInterleavedFields_TruncatedAroundNarrowField(t *testing.T) {
+	item := &oneByteBetweenVars{Slot: 4, Roots: []uint64{1}, JustificationBits: [1]byte{0x0f}, Balances: []uint64{2}}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fixed part holds Slot, an offset, the justification bits and another
+	// offset, so it ends one byte after a multiple of the offset size.
+	const fixedPartSize = 17
+	for n := 1; n < fixedPartSize; n++ {
+		err := Unmarshal(enc[:n], &oneByteBetweenVars{})
+		want := fmt.Sprintf("expected %d bytes for its fixed part, received %d", fixedPartSize, n)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error containing %q for input truncated to %d bytes, received %v", want, n, err)
+		}
+	}
+}
+
+func TestInterleavedFields_VariableFirstFieldOffset(t *testing.T) {
+	item := &varFirstBytes{Data: []byte{0xaa, 0xbb}, Slot: 3, Flag: true}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fixed part holds the offset of Data, Slot and Flag, so the offset of
+	// Data must point right past it rather than into Slot or beyond.
+	const fixedPartSize = 13
+	for _, offset := range []uint32{0, 4, 8, 12, 14, 15, 16} {
+		corrupted := append([]byte{}, enc...)
+		corrupted[0] = byte(offset)
+		if err := Unmarshal(corrupted, &varFirstBytes{}); err == nil {
+			t.Errorf("Expected offset %d of a fixed part of %d bytes to be rejected", offset, fixedPartSize)
+		}
+		if err := NewDecoder(bytes.NewReader(corrupted)).Decode(&varFirstBytes{}); err == nil {
+			t.Errorf("Expected Decoder to reject offset %d of a fixed part of %d bytes", offset, fixedPartSize)
+		}
+	}
+}
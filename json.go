@@ -0,0 +1,52 @@
+package ssz
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+	"github.com/524119574/go-ssz/types"
+)
+
+// MarshalJSON returns the JSON representation of val used by the consensus specs
+// and the beacon node APIs, such as to compare values with those served by other
+// clients. It follows the same tags as Marshal: byte lists and vectors are
+// 0x-prefixed hex strings, integers are quoted decimal strings, booleans stay
+// booleans, and containers are objects keyed by the lowerCamelCase names of
+// their fields:
+//
+//	type checkpoint struct {
+//		Epoch uint64
+//		Root  []byte `ssz-size:"32"`
+//	}
+//
+//	// {"epoch":"3","root":"0x0000…"}
+//	enc, err := MarshalJSON(&checkpoint{Epoch: 3})
+func MarshalJSON(val interface{}) ([]byte, error) {
+	if val == nil {
+		return nil, errors.New("untyped-value nil cannot be marshaled")
+	}
+	rval := reflect.ValueOf(val)
+	enc, err := types.MarshalJSON(rval, rval.Type())
+	return enc, newError(OpMarshal, val, err)
+}
+
+// UnmarshalJSON decodes the JSON representation written by MarshalJSON into the
+// value val points to. Every field of a container must be present, and objects
+// holding keys which are not the names of its fields are rejected.
+func UnmarshalJSON(data []byte, val interface{}) error {
+	return newError(OpUnmarshal, val, unmarshalJSON(data, val))
+}
+
+func unmarshalJSON(data []byte, val interface{}) error {
+	if val == nil {
+		return errors.New("cannot unmarshal into untyped, nil value")
+	}
+	rval := reflect.ValueOf(val)
+	if rval.Kind() != reflect.Ptr {
+		return errors.New("can only unmarshal into a pointer target")
+	}
+	if rval.IsNil() {
+		return errors.New("cannot output to pointer of nil value")
+	}
+	return types.UnmarshalJSON(rval.Elem(), rval.Elem().Type(), data)
+}
@@ -0,0 +1,154 @@
+package ssz
+
+import (
+	"math/big"
+	"testing"
+)
+
+type jsonCheckpoint struct {
+	Epoch uint64
+	Root  []byte `ssz-size:"32"`
+}
+
+type jsonAttestation struct {
+	AggregationBits Bitlist `ssz-max:"2048"`
+	Slot            uint64
+	Index           uint32
+	Source          *jsonCheckpoint
+	Signature       [4]byte
+}
+
+type jsonState struct {
+	GenesisTime  uint64
+	Valid        bool
+	Fork         uint8
+	Balance      Uint256
+	Justified    Bitvector          `ssz-size:"4"`
+	BlockRoots   [][]byte           `ssz-size:"2,32"`
+	Graffiti     string             `ssz-max:"32"`
+	Attestations []*jsonAttestation `ssz-max:"128"`
+	Balances     []uint64           `ssz-max:"1024"`
+	Skipped      uint64             `ssz:"-"`
+}
+
+func TestMarshalJSON_Representation(t *testing.T) {
+	enc, err := MarshalJSON(&jsonAttestation{
+		AggregationBits: Bitlist{0x0d},
+		Slot:            18446744073709551615,
+		Index:           7,
+		Signature:       [4]byte{0xde, 0xad, 0xbe, 0xef},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The nil source is represented as the zero checkpoint it is encoded as.
+	want := `{"aggregationBits":"0x0d","slot":"18446744073709551615","index":"7",` +
+		`"source":{"epoch":"0","root":"0x0000000000000000000000000000000000000000000000000000000000000000"},` +
+		`"signature":"0xdeadbeef"}`
+	if string(enc) != want {
+		t.Errorf("Expected %s, received %s", want, enc)
+	}
+}
+
+func TestJSON_RoundTrip(t *testing.T) {
+	balance, err := Uint256FromBig(new(big.Int).Lsh(big.NewInt(1), 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	justified := NewBitvector(4)
+	justified.SetBitAt(2, true)
+	item := &jsonState{
+		GenesisTime: 1606824023,
+		Valid:       true,
+		Fork:        2,
+		Balance:     balance,
+		Justified:   justified,
+		BlockRoots:  [][]byte{make([]byte, 32), {0: 1, 31: 2}},
+		Graffiti:    "hello",
+		Attestations: []*jsonAttestation{
+			{AggregationBits: Bitlist{0x05}, Slot: 3, Source: &jsonCheckpoint{Epoch: 1, Root: make([]byte, 32)}},
+			{AggregationBits: Bitlist{0x01}},
+		},
+		Balances: []uint64{32000000000, 0, 1},
+		Skipped:  9,
+	}
+	enc, err := MarshalJSON(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &jsonState{}
+	if err := UnmarshalJSON(enc, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !DeepEqual(decoded, item) {
+		t.Errorf("Expected %+v, received %+v", item, decoded)
+	}
+	if decoded.Skipped != 0 {
+		t.Errorf("Expected fields left out of encodings to be left out, received %d", decoded.Skipped)
+	}
+	reencoded, err := MarshalJSON(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reencoded) != string(enc) {
+		t.Errorf("Expected %s, received %s", enc, reencoded)
+	}
+}
+
+func TestMarshalJSON_WideUints(t *testing.T) {
+	type wide struct {
+		Small Uint256
+		Large BigUint256
+	}
+	x, ok := new(big.Int).SetString("115792089237316195423570985008687907853269984665640564039457584007913129639935", 10)
+	if !ok {
+		t.Fatal("Could not parse the largest uint256")
+	}
+	enc, err := MarshalJSON(&wide{Small: Uint256{1}, Large: BigUint256{Int: x}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"small":"1","large":"115792089237316195423570985008687907853269984665640564039457584007913129639935"}`
+	if string(enc) != want {
+		t.Errorf("Expected %s, received %s", want, enc)
+	}
+	decoded := &wide{}
+	if err := UnmarshalJSON(enc, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Small != (Uint256{1}) || decoded.Large.Cmp(x) != 0 {
+		t.Errorf("Expected %v and %v, received %v and %v", Uint256{1}, x, decoded.Small, decoded.Large)
+	}
+}
+
+func TestUnmarshalJSON_Errors(t *testing.T) {
+	zeroRoot := `"0x0000000000000000000000000000000000000000000000000000000000000000"`
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "missing field", data: `{"epoch":"1"}`},
+		{name: "unknown field", data: `{"epoch":"1","root":` + zeroRoot + `,"slot":"2"}`},
+		{name: "unquoted integer", data: `{"epoch":1,"root":` + zeroRoot + `}`},
+		{name: "negative integer", data: `{"epoch":"-1","root":` + zeroRoot + `}`},
+		{name: "hex without prefix", data: `{"epoch":"1","root":"00"}`},
+		{name: "short vector", data: `{"epoch":"1","root":"0x00"}`},
+		{name: "invalid hex", data: `{"epoch":"1","root":"0xzz"}`},
+		{name: "null", data: `{"epoch":null,"root":` + zeroRoot + `}`},
+		{name: "not an object", data: `[]`},
+	}
+	for _, tt := range tests {
+		if err := UnmarshalJSON([]byte(tt.data), &jsonCheckpoint{}); err == nil {
+			t.Errorf("%s: expected %s to be rejected", tt.name, tt.data)
+		}
+	}
+	if err := UnmarshalJSON([]byte(`{"aggregationBits":"0x00","slot":"0","index":"0","source":{"epoch":"0","root":`+zeroRoot+`},"signature":"0x00000000"}`), &jsonAttestation{}); err == nil {
+		t.Error("Expected a bitlist without its delimiting bit to be rejected")
+	}
+	if err := UnmarshalJSON([]byte(`{}`), jsonCheckpoint{}); err == nil {
+		t.Error("Expected a non-pointer target to be rejected")
+	}
+	if _, err := MarshalJSON(nil); err == nil {
+		t.Error("Expected untyped nil to be rejected")
+	}
+}
@@ -0,0 +1,113 @@
+//go:build go1.18
+// +build go1.18
+
+package ssz
+
+import (
+	"fmt"
+)
+
+// List is a list of elements of type T which carries its own limit, so that it
+// is serialized and hashed as an SSZ list without an ssz-max tag, whether on its
+// own or as a struct field, where its limit overrides the tag of the field. A
+// List without a limit, such as the zero List, is hashed with the limit given
+// by the tag of the field holding it.
+//
+//  l := ssz.NewList[uint64](2048)
+//  if err := l.Append(5); err != nil {
+//      return err
+//  }
+//  root, err := l.HashTreeRoot()
+//
+// To be decoded into, a List must be created with its limit beforehand, as
+// lists decoding more elements than their limit are rejected.
+type List[T any] struct {
+	elems []T
+	limit uint64
+}
+
+// NewList returns an empty list of up to limit elements.
+func NewList[T any](limit uint64) *List[T] {
+	return &List[T]{limit: limit}
+}
+
+// Append adds elems to the end of the list, unless they would exceed its limit.
+func (l *List[T]) Append(elems ...T) error {
+	if l.limit > 0 && uint64(len(l.elems)+len(elems)) > l.limit {
+		return fmt.Errorf(
+			"cannot append %d elements to a list of %d elements, its limit is %d",
+			len(elems),
+			len(l.elems),
+			l.limit,
+		)
+	}
+	l.elems = append(l.elems, elems...)
+	return nil
+}
+
+// Elems returns the elements of the list.
+func (l *List[T]) Elems() []T {
+	return l.elems
+}
+
+// Len returns the number of elements of the list.
+func (l *List[T]) Len() int {
+	return len(l.elems)
+}
+
+// Limit returns the maximum number of elements of the list.
+func (l *List[T]) Limit() uint64 {
+	return l.limit
+}
+
+// HashTreeRoot returns the hash tree root of the list, mixed in with its length.
+func (l *List[T]) HashTreeRoot() ([32]byte, error) {
+	return HashTreeRoot(l)
+}
+
+// SSZElements implements types.Sequence.
+func (l *List[T]) SSZElements() interface{} {
+	return &l.elems
+}
+
+// SSZLimit implements types.Sequence.
+func (l *List[T]) SSZLimit() (uint64, bool) {
+	return l.limit, false
+}
+
+// Vector is a vector of elements of type T which carries its own length, so
+// that it is serialized and hashed as an SSZ vector without an ssz-size tag.
+// To be decoded into, a Vector must be created with its length beforehand.
+type Vector[T any] struct {
+	elems []T
+}
+
+// NewVector returns a vector of length zero values of type T.
+func NewVector[T any](length uint64) *Vector[T] {
+	return &Vector[T]{elems: make([]T, length)}
+}
+
+// Elems returns the elements of the vector, which may be modified in place.
+func (v *Vector[T]) Elems() []T {
+	return v.elems
+}
+
+// Len returns the number of elements of the vector.
+func (v *Vector[T]) Len() int {
+	return len(v.elems)
+}
+
+// HashTreeRoot returns the hash tree root of the vector.
+func (v *Vector[T]) HashTreeRoot() ([32]byte, error) {
+	return HashTreeRoot(v)
+}
+
+// SSZElements implements types.Sequence.
+func (v *Vector[T]) SSZElements() interface{} {
+	return &v.elems
+}
+
+// SSZLimit implements types.Sequence.
+func (v *Vector[T]) SSZLimit() (uint64, bool) {
+	return uint64(len(v.elems)), true
+}
@@ -0,0 +1,171 @@
+//go:build go1.18
+// +build go1.18
+
+package ssz
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type listItem struct {
+	Slot uint64
+	Data []byte `ssz-max:"8"`
+}
+
+type taggedListContainer struct {
+	Bytes []byte     `ssz-max:"32"`
+	Nums  []uint64   `ssz-max:"2048"`
+	Items []listItem `ssz-max:"16"`
+	Roots [][]byte   `ssz-size:"4,32"`
+}
+
+type wrappedListContainer struct {
+	Bytes List[byte]
+	Nums  List[uint64] `ssz-max:"4"`
+	Items *List[listItem]
+	Roots Vector[[32]byte]
+}
+
+func newWrappedListContainer() wrappedListContainer {
+	return wrappedListContainer{
+		Bytes: *NewList[byte](32),
+		Nums:  *NewList[uint64](2048),
+		Items: NewList[listItem](16),
+		Roots: *NewVector[[32]byte](4),
+	}
+}
+
+func listFixtures(t *testing.T) (taggedListContainer, wrappedListContainer) {
+	tagged := taggedListContainer{
+		Bytes: []byte{1, 2, 3},
+		Nums:  []uint64{5, 6, 7, 8, 9},
+		Items: []listItem{{Slot: 1, Data: []byte{1}}, {Slot: 2, Data: []byte{}}},
+		Roots: [][]byte{make([]byte, 32), make([]byte, 32), make([]byte, 32), make([]byte, 32)},
+	}
+	tagged.Roots[2][0] = 0xaa
+	wrapped := newWrappedListContainer()
+	if err := wrapped.Bytes.Append(tagged.Bytes...); err != nil {
+		t.Fatal(err)
+	}
+	if err := wrapped.Nums.Append(tagged.Nums...); err != nil {
+		t.Fatal(err)
+	}
+	if err := wrapped.Items.Append(tagged.Items...); err != nil {
+		t.Fatal(err)
+	}
+	wrapped.Roots.Elems()[2][0] = 0xaa
+	return tagged, wrapped
+}
+
+func TestList_MatchesTaggedSlices(t *testing.T) {
+	tagged, wrapped := listFixtures(t)
+	want, err := Marshal(tagged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Marshal(wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Expected encoding %#x, received %#x", want, got)
+	}
+	wantRoot, err := HashTreeRoot(tagged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The limit of Nums overrides its ssz-max tag of 4, which it exceeds.
+	gotRoot, err := HashTreeRoot(wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotRoot != wantRoot {
+		t.Errorf("Expected root %#x, received %#x", wantRoot, gotRoot)
+	}
+
+	decoded := newWrappedListContainer()
+	if err := Unmarshal(want, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, wrapped) {
+		t.Errorf("Expected %v to decode as %v, received %v", want, wrapped, decoded)
+	}
+}
+
+func TestList_BareValues(t *testing.T) {
+	nums := []uint64{1, 2, 3}
+	l := NewList[uint64](2048)
+	if err := l.Append(nums...); err != nil {
+		t.Fatal(err)
+	}
+	root, err := l.HashTreeRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := HashTreeRootWithCapacity(nums, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != want {
+		t.Errorf("Expected root %#x, received %#x", want, root)
+	}
+
+	enc, err := Marshal(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := NewList[uint64](2048)
+	if err := Unmarshal(enc, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Elems(), nums) {
+		t.Errorf("Expected %v, received %v", nums, decoded.Elems())
+	}
+
+	v := NewVector[uint64](4)
+	copy(v.Elems(), nums)
+	vectorRoot, err := v.HashTreeRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, err := HashTreeRoot([4]uint64{1, 2, 3}); err != nil || vectorRoot != want {
+		t.Errorf("Expected root %#x, received %#x (%v)", want, vectorRoot, err)
+	}
+}
+
+func TestList_EnforcesLimit(t *testing.T) {
+	l := NewList[uint64](2)
+	if err := l.Append(1, 2, 3); err == nil {
+		t.Error("Expected appending beyond the limit to fail")
+	}
+	if l.Len() != 0 {
+		t.Errorf("Expected a failed append to leave the list empty, it holds %d elements", l.Len())
+	}
+	enc, err := Marshal([]uint64{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Unmarshal(enc, l)
+	if err == nil || !strings.Contains(err.Error(), "more than its limit of 2") {
+		t.Errorf("Expected decoding beyond the limit to fail, received %v", err)
+	}
+}
+
+func TestList_Reset(t *testing.T) {
+	_, wrapped := listFixtures(t)
+	if err := Reset(&wrapped); err != nil {
+		t.Fatal(err)
+	}
+	if wrapped.Bytes.Len() != 0 || wrapped.Nums.Len() != 0 || wrapped.Items.Len() != 0 {
+		t.Errorf("Expected the lists to be emptied, received %+v", wrapped)
+	}
+	if wrapped.Nums.Limit() != 2048 || wrapped.Items.Limit() != 16 {
+		t.Errorf("Expected the limits to be kept, received %d and %d", wrapped.Nums.Limit(), wrapped.Items.Limit())
+	}
+	if wrapped.Roots.Len() != 4 || wrapped.Roots.Elems()[2] != [32]byte{} {
+		t.Errorf("Expected the vector to keep its length with zeroed elements, received %v", wrapped.Roots.Elems())
+	}
+}
@@ -0,0 +1,59 @@
+package ssz
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+// Proof is a Merkle proof of a single node of the hash tree of a value, such as
+// the root of one of its fields, identified by its generalized index: the root
+// of the tree has index 1, and the children of the node of index i have indices
+// 2*i and 2*i+1.
+type Proof struct {
+	// Index is the generalized index of the proven node.
+	Index uint64
+	// Leaf is the proven node.
+	Leaf [32]byte
+	// Branch holds the siblings of the nodes on the path from the proven node up
+	// to the root, starting with that of the proven node itself.
+	Branch [][32]byte
+}
+
+// VerifyProof reports whether proof proves its leaf is the node at its index of
+// the hash tree of root, by hashing the leaf with the nodes of the branch up to
+// the root. It returns an error, rather than false, when the proof is malformed,
+// such as when its branch does not hold one node per level between the index
+// and the root. The recomputed root is compared in constant time.
+func VerifyProof(root [32]byte, proof *Proof) (bool, error) {
+	if proof == nil {
+		return false, errors.New("nil proof cannot be verified")
+	}
+	if proof.Index == 0 {
+		return false, errors.New("generalized index 0 is not the index of any node")
+	}
+	depth := bits.Len64(proof.Index) - 1
+	if len(proof.Branch) != depth {
+		return false, errors.Errorf(
+			"branch of generalized index %d must hold %d nodes, received %d",
+			proof.Index,
+			depth,
+			len(proof.Branch),
+		)
+	}
+	node := proof.Leaf
+	buf := make([]byte, 64)
+	for i, sibling := range proof.Branch {
+		if proof.Index>>uint(i)&1 == 1 {
+			copy(buf, sibling[:])
+			copy(buf[32:], node[:])
+		} else {
+			copy(buf, node[:])
+			copy(buf[32:], sibling[:])
+		}
+		node = sha256.Sum256(buf)
+	}
+	return subtle.ConstantTimeCompare(node[:], root[:]) == 1, nil
+}
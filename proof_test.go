@@ -0,0 +1,145 @@
+package ssz
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+type proofCheckpoint struct {
+	Epoch uint64
+	Root  [32]byte
+}
+
+type proofState struct {
+	Slot       uint64
+	Roots      [][32]byte `ssz-max:"4"`
+	Checkpoint *proofCheckpoint
+}
+
+// merkleBranch returns the root of the tree over leaves, whose number is a
+// power of two, along with the branch of the leaf at position i.
+func merkleBranch(leaves [][32]byte, i int) ([32]byte, [][32]byte) {
+	var branch [][32]byte
+	for len(leaves) > 1 {
+		branch = append(branch, leaves[i^1])
+		parents := make([][32]byte, len(leaves)/2)
+		for j := range parents {
+			parents[j] = sha256.Sum256(append(leaves[2*j][:], leaves[2*j+1][:]...))
+		}
+		leaves = parents
+		i /= 2
+	}
+	return leaves[0], branch
+}
+
+func mustRoot(t *testing.T, val interface{}) [32]byte {
+	root, err := HashTreeRoot(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// proofFixture returns a state along with proofs of some of its nodes, whose
+// branches are built from the roots HashTreeRoot computes for its fields.
+func proofFixture(t *testing.T) (*proofState, []*Proof) {
+	state := &proofState{
+		Slot:       9,
+		Roots:      [][32]byte{{1}, {2}, {3}},
+		Checkpoint: &proofCheckpoint{Epoch: 2, Root: [32]byte{4}},
+	}
+	// The three fields of the state are the first leaves of a tree of four.
+	fields := [][32]byte{
+		mustRoot(t, state.Slot),
+		mustRoot(t, &struct {
+			Roots [][32]byte `ssz-max:"4"`
+		}{Roots: state.Roots}),
+		mustRoot(t, state.Checkpoint),
+		{},
+	}
+	_, slotBranch := merkleBranch(fields, 0)
+	_, rootsBranch := merkleBranch(fields, 1)
+	_, checkpointBranch := merkleBranch(fields, 2)
+
+	// The roots are the leaves of the data tree of the list, which is mixed in
+	// with its length.
+	data := [][32]byte{{1}, {2}, {3}, {}}
+	dataRoot, rootBranch := merkleBranch(data, 1)
+	rootBranch = append(rootBranch, [32]byte{3})
+	rootBranch = append(rootBranch, rootsBranch...)
+	if sha256.Sum256(append(dataRoot[:], rootBranch[2][:]...)) != fields[1] {
+		t.Fatal("Expected the root of the list to mix in its length")
+	}
+
+	checkpoint := [][32]byte{mustRoot(t, state.Checkpoint.Epoch), state.Checkpoint.Root}
+	_, epochBranch := merkleBranch(checkpoint, 0)
+	epochBranch = append(epochBranch, checkpointBranch...)
+
+	return state, []*Proof{
+		{Index: 4, Leaf: fields[0], Branch: slotBranch},
+		{Index: 6, Leaf: fields[2], Branch: checkpointBranch},
+		// The second root is at 2*4+1 under the data tree, at 2 under the list.
+		{Index: 5*8 + 1, Leaf: [32]byte{2}, Branch: rootBranch},
+		{Index: 6*2 + 0, Leaf: mustRoot(t, uint64(2)), Branch: epochBranch},
+		{Index: 1, Leaf: mustRoot(t, state)},
+	}
+}
+
+func TestVerifyProof(t *testing.T) {
+	state, proofs := proofFixture(t)
+	root := mustRoot(t, state)
+	for _, proof := range proofs {
+		ok, err := VerifyProof(root, proof)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Errorf("Expected the proof of generalized index %d to be valid", proof.Index)
+		}
+	}
+}
+
+func TestVerifyProof_FlippedBits(t *testing.T) {
+	state, proofs := proofFixture(t)
+	root := mustRoot(t, state)
+	for _, proof := range proofs[:4] {
+		leaf := *proof
+		leaf.Leaf[31] ^= 1
+		branch := *proof
+		branch.Branch = append([][32]byte{}, proof.Branch...)
+		branch.Branch[len(branch.Branch)-1][0] ^= 0x80
+		index := *proof
+		index.Index ^= 1
+		for name, p := range map[string]*Proof{"leaf": &leaf, "branch": &branch, "index": &index} {
+			ok, err := VerifyProof(root, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok {
+				t.Errorf("Expected the proof of generalized index %d with a flipped bit in its %s to be invalid", proof.Index, name)
+			}
+		}
+	}
+}
+
+func TestVerifyProof_Malformed(t *testing.T) {
+	state, proofs := proofFixture(t)
+	root := mustRoot(t, state)
+	tooShort := *proofs[2]
+	tooShort.Branch = tooShort.Branch[1:]
+	// Setting a bit above the leading one of the index deepens it.
+	deeper := *proofs[2]
+	deeper.Index ^= 1 << 6
+	tests := []*Proof{
+		nil,
+		{Index: 0},
+		{Index: 2},
+		&tooShort,
+		&deeper,
+	}
+	for _, proof := range tests {
+		if ok, err := VerifyProof(root, proof); err == nil || ok {
+			t.Errorf("Expected proof %+v to be rejected, received %v, %v", proof, ok, err)
+		}
+	}
+}
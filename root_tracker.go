@@ -0,0 +1,28 @@
+package ssz
+
+import (
+	"github.com/524119574/go-ssz/types"
+)
+
+// RootTracker keeps the trie of a vector of roots, so that the root of the
+// vector is updated in time logarithmic in its length when one of its roots
+// changes, such as the block roots of a beacon state on every slot:
+//
+//	tracker, err := NewRootTracker(state.BlockRoots[:])
+//	...
+//	state.BlockRoots[slot%SlotsPerHistoricalRoot] = blockRoot
+//	root, err := tracker.Update(slot%SlotsPerHistoricalRoot, blockRoot)
+//
+// Its root is the root HashTreeRoot computes for a [N][32]byte array of the
+// same roots. Vectors of containers are tracked over the roots of their
+// elements, as returned by ElementRoots. Sync updates the tracker to the
+// current roots of the vector, rehashing the branches of those which changed,
+// or the whole trie when their number changed. A RootTracker is not safe for
+// concurrent use.
+type RootTracker = types.RootTracker
+
+// NewRootTracker returns a tracker of the root of the vector of the given roots,
+// which must hold at least one root.
+func NewRootTracker(roots [][32]byte) (*RootTracker, error) {
+	return types.NewRootTracker(roots)
+}
@@ -0,0 +1,133 @@
+package ssz
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// flatRoots lays roots out back to back, as RootOfRootVector takes them.
+func flatRoots(roots [][32]byte) []byte {
+	flat := make([]byte, 0, 32*len(roots))
+	for _, root := range roots {
+		flat = append(flat, root[:]...)
+	}
+	return flat
+}
+
+func TestRootTracker_MatchesMerkleizationAfterRandomUpdates(t *testing.T) {
+	rng := rand.New(rand.NewSource(2796))
+	for _, n := range []int{1, 2, 3, 5, 8, 100, 1000, 8195} {
+		roots := make([][32]byte, n)
+		for i := range roots {
+			rng.Read(roots[i][:])
+		}
+		tracker, err := NewRootTracker(roots)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for step := 0; step < 50; step++ {
+			index := rng.Intn(n)
+			rng.Read(roots[index][:])
+			root, err := tracker.Update(uint64(index), roots[index])
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := RootOfRootVector(flatRoots(roots), uint64(n))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if root != want {
+				t.Fatalf("Expected root %#x of %d roots after updating root %d at step %d, received %#x", want, n, index, step, root)
+			}
+		}
+		// Several roots changed at once are synced in one call.
+		for i := 0; i < 10; i++ {
+			rng.Read(roots[rng.Intn(n)][:])
+		}
+		root, err := tracker.Sync(roots)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, err := RootOfRootVector(flatRoots(roots), uint64(n)); err != nil || root != want {
+			t.Fatalf("Expected root %#x of %d synced roots, received %#x (%v)", want, n, root, err)
+		}
+	}
+}
+
+func TestRootTracker_MatchesHashTreeRoot(t *testing.T) {
+	var blockRoots [64][32]byte
+	for i := range blockRoots {
+		blockRoots[i][0] = byte(i)
+	}
+	tracker, err := NewRootTracker(blockRoots[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockRoots[37] = [32]byte{0xff, 1}
+	root, err := tracker.Update(37, blockRoots[37])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := HashTreeRoot(blockRoots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != want {
+		t.Errorf("Expected the root of the block roots %#x, received %#x", want, root)
+	}
+
+	// Vectors of containers are tracked over the roots of their elements.
+	var checkpoints [5]limitsCheckpoint
+	elementRoots, err := ElementRoots(checkpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker, err = NewRootTracker(elementRoots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoints[3].Epoch = 9
+	checkpointRoot, err := HashTreeRoot(checkpoints[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root, err = tracker.Update(3, checkpointRoot); err != nil {
+		t.Fatal(err)
+	}
+	if want, err = HashTreeRoot(checkpoints); err != nil {
+		t.Fatal(err)
+	}
+	if root != want {
+		t.Errorf("Expected the root of the checkpoints %#x, received %#x", want, root)
+	}
+}
+
+func TestRootTracker_SyncRebuildsOnLengthChange(t *testing.T) {
+	tracker, err := NewRootTracker(make([][32]byte, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{5, 1, 16, 3} {
+		roots := make([][32]byte, n)
+		for i := range roots {
+			roots[i][0] = byte(n + i)
+		}
+		root, err := tracker.Sync(roots)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := RootOfRootVector(flatRoots(roots), uint64(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root != want || tracker.Len() != n {
+			t.Errorf("Expected root %#x of %d roots, received %#x of %d", want, n, root, tracker.Len())
+		}
+	}
+	if _, err := tracker.Sync(nil); err == nil {
+		t.Error("Expected syncing no roots to fail")
+	}
+	if tracker.Len() != 3 {
+		t.Errorf("Expected a failed sync to keep the 3 tracked roots, received %d", tracker.Len())
+	}
+}
@@ -0,0 +1,115 @@
+package ssz
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// scaledDeposit holds its amount in ether, while it is serialized in gwei.
+type scaledDeposit struct {
+	Index  uint64
+	Amount uint64 `ssz-scale:"1e9"`
+	Memo   []byte `ssz-max:"32"`
+}
+
+// wireDeposit is how scaledDeposit is serialized.
+type wireDeposit struct {
+	Index  uint64
+	Amount uint64
+	Memo   []byte `ssz-max:"32"`
+}
+
+func TestScale_UsesWireValue(t *testing.T) {
+	scaled := &scaledDeposit{Index: 3, Amount: 32, Memo: []byte("deposit")}
+	wire := &wireDeposit{Index: 3, Amount: 32000000000, Memo: []byte("deposit")}
+	enc, err := Marshal(scaled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Marshal(wire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, want) {
+		t.Errorf("Expected %#x, received %#x", want, enc)
+	}
+	var streamed bytes.Buffer
+	if err := NewEncoder(&streamed).Encode(scaled); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(streamed.Bytes(), want) {
+		t.Errorf("Expected the encoder to write %#x, received %#x", want, streamed.Bytes())
+	}
+	root, err := HashTreeRoot(wire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertRootMatches(t, scaled, fmt.Sprintf("%x", root))
+
+	dec := &scaledDeposit{}
+	if err := Unmarshal(enc, dec); err != nil {
+		t.Fatal(err)
+	}
+	if dec.Amount != 32 || dec.Index != 3 {
+		t.Errorf("Expected an amount of 32 at index 3, received %+v", dec)
+	}
+}
+
+func TestScale_RejectsUnrepresentableValues(t *testing.T) {
+	overflowing := &scaledDeposit{Amount: math.MaxUint64/1000000000 + 1}
+	if _, err := Marshal(overflowing); err == nil || !strings.Contains(err.Error(), "overflows uint64") {
+		t.Errorf("Expected an overflowing amount not to be marshaled, received %v", err)
+	}
+	if _, err := HashTreeRoot(overflowing); err == nil || !strings.Contains(err.Error(), "overflows uint64") {
+		t.Errorf("Expected an overflowing amount not to be hashed, received %v", err)
+	}
+	if _, err := Marshal(&scaledDeposit{Amount: math.MaxUint64 / 1000000000}); err != nil {
+		t.Errorf("Expected the largest representable amount to be marshaled, received %v", err)
+	}
+
+	enc, err := Marshal(&wireDeposit{Amount: 32000000001})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Unmarshal(enc, &scaledDeposit{})
+	if err == nil || !strings.Contains(err.Error(), "not a multiple of its scale of 1000000000") {
+		t.Errorf("Expected an amount with a remainder not to be unmarshaled, received %v", err)
+	}
+}
+
+func TestScale_RejectsInvalidTags(t *testing.T) {
+	tests := []struct {
+		val  interface{}
+		want string
+	}{
+		{
+			val: &struct {
+				Amount uint32 `ssz-scale:"1000"`
+			}{},
+			want: "only uint64 fields can be scaled",
+		},
+		{
+			val: &struct {
+				Amount uint64 `ssz-scale:"1e20"`
+			}{},
+			want: "invalid ssz-scale",
+		},
+		{
+			val: &struct {
+				Amount uint64 `ssz-scale:"0"`
+			}{},
+			want: "invalid ssz-scale",
+		},
+	}
+	for _, tt := range tests {
+		if _, err := Marshal(tt.val); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Expected %T to be rejected with %q, received %v", tt.val, tt.want, err)
+		}
+		if _, err := HashTreeRoot(tt.val); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Expected the root of %T to be rejected with %q, received %v", tt.val, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package ssz
+
+import (
+	"fmt"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+)
+
+// MarshalSnappy marshals val and compresses the encoding with the snappy block
+// format, as the ssz_snappy encoding of the Eth2 req/resp and gossip domains
+// does.
+func MarshalSnappy(val interface{}) ([]byte, error) {
+	enc, err := Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	return snappy.Encode(nil, enc), nil
+}
+
+// UnmarshalSnappy decompresses input, compressed with the snappy block format,
+// and unmarshals the result into val as Unmarshal does. The uncompressed length
+// declared by input is checked against the size of the longest encoding the
+// ssz-max tags of the type of val allow before anything is decompressed, so a
+// small input cannot make decompressing allocate unbounded memory. Types
+// holding lists without an ssz-max tag have no such size, and are rejected.
+func UnmarshalSnappy(input []byte, val interface{}) error {
+	if val == nil {
+		return errors.New("cannot unmarshal into untyped, nil value")
+	}
+	c, err := CodecFor(val)
+	if err != nil {
+		return newError(OpUnmarshal, val, err)
+	}
+	maxSize, err := c.MaxSize()
+	if err != nil {
+		return newError(OpUnmarshal, val, errors.Wrap(err, "could not bound the size of the snappy decoded input"))
+	}
+	size, err := snappy.DecodedLen(input)
+	if err != nil {
+		return newError(OpUnmarshal, val, errors.Wrap(err, "could not read the length of the snappy encoded input"))
+	}
+	if uint64(size) > maxSize {
+		err := fmt.Errorf("snappy encoded input declares %d bytes, more than the %d bytes of the longest encoding of its type: %w", size, maxSize, ErrSizeMismatch)
+		return newError(OpUnmarshal, val, err)
+	}
+	decoded, err := snappy.Decode(nil, input)
+	if err != nil {
+		return newError(OpUnmarshal, val, errors.Wrap(err, "could not snappy decode input"))
+	}
+	return Unmarshal(decoded, val)
+}
@@ -0,0 +1,92 @@
+package ssz
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/golang/snappy"
+)
+
+type snappyBlock struct {
+	Slot       uint64
+	ParentRoot [32]byte
+	Graffiti   []byte   `ssz-max:"32"`
+	Roots      [][]byte `ssz-size:"?,32" ssz-max:"16"`
+}
+
+type snappyUnbounded struct {
+	Slot  uint64
+	Items []uint64
+}
+
+func TestSnappy_RoundTrip(t *testing.T) {
+	tests := []interface{}{
+		&snappyBlock{},
+		&snappyBlock{Slot: 5, ParentRoot: [32]byte{1, 2}, Graffiti: []byte("graffiti")},
+		&snappyBlock{Slot: 1 << 40, Roots: [][]byte{make([]byte, 32), {31: 7}}},
+	}
+	for _, item := range tests {
+		compressed, err := MarshalSnappy(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		enc, err := Marshal(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := snappy.Encode(nil, enc); string(compressed) != string(want) {
+			t.Errorf("Expected the snappy block encoding %#x, received %#x", want, compressed)
+		}
+		decoded := &snappyBlock{}
+		if err := UnmarshalSnappy(compressed, decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !DeepEqual(decoded, item) {
+			t.Errorf("Expected %+v, received %+v", item, decoded)
+		}
+	}
+}
+
+func TestUnmarshalSnappy_DeclaredLengthTooLarge(t *testing.T) {
+	codec, err := CodecFor(&snappyBlock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxSize, err := codec.MaxSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The input only declares its uncompressed length, and would fail to
+	// decompress, so the size error shows it was rejected beforehand.
+	header := make([]byte, binary.MaxVarintLen64)
+	bomb := header[:binary.PutUvarint(header, 1<<31)]
+	err = UnmarshalSnappy(bomb, &snappyBlock{})
+	if !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("Expected the declared length to be rejected, received %v", err)
+	}
+	tooLarge := snappy.Encode(nil, make([]byte, maxSize+1))
+	if err := UnmarshalSnappy(tooLarge, &snappyBlock{}); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("Expected %d bytes to exceed the maximum size of %d, received %v", maxSize+1, maxSize, err)
+	}
+}
+
+func TestUnmarshalSnappy_Errors(t *testing.T) {
+	enc, err := MarshalSnappy(&snappyUnbounded{Slot: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UnmarshalSnappy(enc, &snappyUnbounded{}); err == nil {
+		t.Error("Expected types without a maximum size to be rejected")
+	}
+	if err := UnmarshalSnappy([]byte{0xff}, &snappyBlock{}); err == nil {
+		t.Error("Expected a truncated length to be rejected")
+	}
+	corrupt := append([]byte{8}, make([]byte, 4)...)
+	if err := UnmarshalSnappy(corrupt, &snappyBlock{}); err == nil {
+		t.Error("Expected corrupt input to be rejected")
+	}
+	if err := UnmarshalSnappy(enc, nil); err == nil {
+		t.Error("Expected a nil target to be rejected")
+	}
+}
@@ -0,0 +1,39 @@
+package spectests
+
+import (
+	"reflect"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/524119574/go-ssz/types"
+)
+
+// LoadValue decodes value.yaml of a case of the consensus spec tests into the
+// value val points to, such that a test harness can check Marshal(val) matches
+// serialized.ssz and HashTreeRoot(val) the root held by roots.yaml. The YAML
+// follows the conventions of the spec tests: byte lists and vectors, bitlists
+// and bitvectors are 0x-prefixed hex strings, integers are plain or quoted
+// decimal numbers, and containers are mappings keyed by the json tags of their
+// fields or else the snake_case forms of their names. The ssz-size and ssz-max
+// tags of the fields are followed as Unmarshal follows them, so a [][]byte
+// tagged ssz-size:"8192,32" holds 8192 roots of 32 bytes.
+func LoadValue(yamlBytes []byte, val interface{}) error {
+	if val == nil {
+		return errors.New("cannot load into untyped, nil value")
+	}
+	rval := reflect.ValueOf(val)
+	if rval.Kind() != reflect.Ptr {
+		return errors.New("can only load into a pointer target")
+	}
+	if rval.IsNil() {
+		return errors.New("cannot output to pointer of nil value")
+	}
+	data, err := yaml.YAMLToJSON(yamlBytes)
+	if err != nil {
+		return errors.Wrap(err, "could not convert YAML value")
+	}
+	if err := types.UnmarshalSpecJSON(rval.Elem(), rval.Elem().Type(), data); err != nil {
+		return errors.Wrapf(err, "could not load value of type %v", rval.Elem().Type())
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package spectests
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	ssz "github.com/524119574/go-ssz"
+	"github.com/prysmaticlabs/go-bitfield"
+)
+
+func TestLoadValue_PendingAttestation(t *testing.T) {
+	value := `aggregation_bits: '0x0d'
+data:
+  slot: 18446744073709551615
+  index: '3'
+  beacon_block_root: '0x0100000000000000000000000000000000000000000000000000000000000002'
+  source: {epoch: 1, root: '0x0000000000000000000000000000000000000000000000000000000000000000'}
+  target: {epoch: 2, root: '0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff'}
+inclusion_delay: 4
+proposer_index: 5
+`
+	want := &mainnetPendingAttestation{
+		AggregationBits: bitfield.Bitlist{0x0d},
+		Data: mainnetAttestationData{
+			Slot:            18446744073709551615,
+			Index:           3,
+			BeaconBlockRoot: append([]byte{1}, append(make([]byte, 30), 2)...),
+			Source:          mainnetCheckpoint{Epoch: 1, Root: make([]byte, 32)},
+			Target:          mainnetCheckpoint{Epoch: 2, Root: bytes.Repeat([]byte{0xff}, 32)},
+		},
+		InclusionDelay: 4,
+		ProposerIndex:  5,
+	}
+	loaded := &mainnetPendingAttestation{}
+	if err := LoadValue([]byte(value), loaded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, want) {
+		t.Errorf("Expected %+v, received %+v", want, loaded)
+	}
+	enc, err := ssz.Marshal(loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEnc, err := ssz.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, wantEnc) {
+		t.Errorf("Expected %#x, received %#x", wantEnc, enc)
+	}
+}
+
+func TestLoadValue_SizeTags(t *testing.T) {
+	var value strings.Builder
+	want := &minimalHistoricalBatch{}
+	for _, field := range []string{"block_roots", "state_roots"} {
+		fmt.Fprintf(&value, "%s:\n", field)
+		roots := make([][]byte, 64)
+		for i := range roots {
+			roots[i] = bytes.Repeat([]byte{byte(i)}, 32)
+			fmt.Fprintf(&value, "- '%#x'\n", roots[i])
+		}
+		if field == "block_roots" {
+			want.BlockRoots = roots
+		} else {
+			want.StateRoots = roots
+		}
+	}
+	loaded := &minimalHistoricalBatch{}
+	if err := LoadValue([]byte(value.String()), loaded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, want) {
+		t.Errorf("Expected %+v, received %+v", want, loaded)
+	}
+	root, err := ssz.HashTreeRoot(loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRoot, err := ssz.HashTreeRoot(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != wantRoot {
+		t.Errorf("Expected root %#x, received %#x", wantRoot, root)
+	}
+	// The vectors must hold as many roots as their tags declare.
+	short := "block_roots: ['0x00']\nstate_roots: []\n"
+	if err := LoadValue([]byte(short), &minimalHistoricalBatch{}); err == nil {
+		t.Error("Expected vectors of the wrong length to be rejected")
+	}
+}
+
+func TestLoadValue_Errors(t *testing.T) {
+	tests := []string{
+		"epoch: 1\n",
+		"epoch: 1\nroot: '0x00'\n",
+		"epoch: -1\nroot: '0x0000000000000000000000000000000000000000000000000000000000000000'\n",
+		"epoch: 1\nroot: '0x0000000000000000000000000000000000000000000000000000000000000000'\nslot: 2\n",
+		"epoch: [1\n",
+	}
+	for _, value := range tests {
+		if err := LoadValue([]byte(value), &mainnetCheckpoint{}); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+	if err := LoadValue([]byte("epoch: 1\n"), mainnetCheckpoint{}); err == nil {
+		t.Error("Expected a non-pointer target to be rejected")
+	}
+}
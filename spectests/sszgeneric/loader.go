@@ -0,0 +1,237 @@
+package sszgeneric
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/golang/snappy"
+)
+
+// Case is a test case of the containers family of the suite, read from a
+// folder such as containers/valid/VarTestStruct_random_0.
+type Case struct {
+	// Name is the name of the folder of the case.
+	Name string
+	// Container is the name of the container the case is about, such as
+	// VarTestStruct.
+	Container string
+	// Valid reports whether Serialized is a valid encoding of the container.
+	Valid bool
+	// Serialized is the encoding of the case, decompressed if it was stored
+	// snappy-compressed.
+	Serialized []byte
+	// Root is the hash tree root of valid cases.
+	Root [32]byte
+
+	value []byte
+}
+
+// LoadCases reads the valid and invalid cases of the containers family of the
+// suite from dir, which holds the valid and invalid case folders.
+func LoadCases(dir string) ([]*Case, error) {
+	var cases []*Case
+	for _, validity := range []string{"valid", "invalid"} {
+		folders, err := ioutil.ReadDir(filepath.Join(dir, validity))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, folder := range folders {
+			if !folder.IsDir() {
+				continue
+			}
+			c, err := loadCase(filepath.Join(dir, validity, folder.Name()), validity == "valid")
+			if err != nil {
+				return nil, fmt.Errorf("could not load case %s/%s: %v", validity, folder.Name(), err)
+			}
+			cases = append(cases, c)
+		}
+	}
+	sort.SliceStable(cases, func(i, j int) bool {
+		return cases[i].Valid && !cases[j].Valid
+	})
+	return cases, nil
+}
+
+func loadCase(dir string, valid bool) (*Case, error) {
+	name := filepath.Base(dir)
+	c := &Case{Name: name, Container: strings.SplitN(name, "_", 2)[0], Valid: valid}
+	serialized, err := ioutil.ReadFile(filepath.Join(dir, "serialized.ssz_snappy"))
+	if err == nil {
+		if serialized, err = snappy.Decode(nil, serialized); err != nil {
+			return nil, err
+		}
+	} else if os.IsNotExist(err) {
+		if serialized, err = ioutil.ReadFile(filepath.Join(dir, "serialized.ssz")); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+	c.Serialized = serialized
+	if !valid {
+		return c, nil
+	}
+
+	meta, err := readYAML(filepath.Join(dir, "meta.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	var m struct {
+		Root string `json:"root"`
+	}
+	if err := json.Unmarshal(meta, &m); err != nil {
+		return nil, err
+	}
+	root, err := decodeHex(m.Root)
+	if err != nil {
+		return nil, err
+	}
+	if len(root) != 32 {
+		return nil, fmt.Errorf("root %s is %d bytes long, expected 32", m.Root, len(root))
+	}
+	copy(c.Root[:], root)
+	if c.value, err = readYAML(filepath.Join(dir, "value.yaml")); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readYAML returns the JSON form of a YAML file.
+func readYAML(path string) ([]byte, error) {
+	enc, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.YAMLToJSON(enc)
+}
+
+// DecodeValue decodes the value of a valid case into the object pointed by val,
+// whose type is that of the container of the case. Byte lists and vectors are
+// read from 0x-prefixed hex strings and containers from maps keyed by field
+// name.
+func (c *Case) DecodeValue(val interface{}) error {
+	if !c.Valid {
+		return fmt.Errorf("case %s is invalid and has no value", c.Name)
+	}
+	rval := reflect.ValueOf(val)
+	if rval.Kind() != reflect.Ptr || rval.IsNil() {
+		return fmt.Errorf("can only decode into a non-nil pointer, received %T", val)
+	}
+	dec := json.NewDecoder(bytes.NewReader(c.value))
+	// Numbers are kept as strings so that uint64 values keep their precision.
+	dec.UseNumber()
+	var raw interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+	return decodeValue(rval.Elem(), raw, "")
+}
+
+func decodeValue(val reflect.Value, raw interface{}, path string) error {
+	typ := val.Type()
+	switch typ.Kind() {
+	case reflect.Bool:
+		b, ok := raw.(bool)
+		if !ok {
+			return fmt.Errorf("%s: expected a boolean, received %v", path, raw)
+		}
+		val.SetBool(b)
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := decodeUint(raw, typ.Bits())
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		val.SetUint(n)
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			if s, ok := raw.(string); ok {
+				b, err := decodeHex(s)
+				if err != nil {
+					return fmt.Errorf("%s: %v", path, err)
+				}
+				return setElems(val, len(b), path, func(elem reflect.Value, i int) error {
+					elem.SetUint(uint64(b[i]))
+					return nil
+				})
+			}
+		}
+		elems, ok := raw.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected a list, received %v", path, raw)
+		}
+		return setElems(val, len(elems), path, func(elem reflect.Value, i int) error {
+			return decodeValue(elem, elems[i], fmt.Sprintf("%s[%d]", path, i))
+		})
+	case reflect.Struct:
+		fields, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected a map, received %v", path, raw)
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			name := typ.Field(i).Name
+			fieldPath := name
+			if path != "" {
+				fieldPath = path + "." + name
+			}
+			field, ok := fields[name]
+			if !ok {
+				return fmt.Errorf("%s: missing field", fieldPath)
+			}
+			if err := decodeValue(val.Field(i), field, fieldPath); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unsupported type %v", path, typ)
+	}
+	return nil
+}
+
+// setElems sets the n elements of the list or vector val with set.
+func setElems(val reflect.Value, n int, path string, set func(elem reflect.Value, i int) error) error {
+	if val.Kind() == reflect.Array {
+		if n != val.Len() {
+			return fmt.Errorf("%s: expected %d elements, received %d", path, val.Len(), n)
+		}
+	} else {
+		val.Set(reflect.MakeSlice(val.Type(), n, n))
+	}
+	for i := 0; i < n; i++ {
+		if err := set(val.Index(i), i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeUint(raw interface{}, bitSize int) (uint64, error) {
+	switch n := raw.(type) {
+	case json.Number:
+		return strconv.ParseUint(n.String(), 10, bitSize)
+	case string:
+		// Large numbers are sometimes quoted.
+		return strconv.ParseUint(n, 10, bitSize)
+	default:
+		return 0, fmt.Errorf("expected a number, received %v", raw)
+	}
+}
+
+func decodeHex(s string) ([]byte, error) {
+	if !strings.HasPrefix(s, "0x") {
+		return nil, fmt.Errorf("expected a 0x-prefixed hex string, received %q", s)
+	}
+	return hex.DecodeString(s[2:])
+}
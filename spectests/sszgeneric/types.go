@@ -0,0 +1,66 @@
+// Package sszgeneric holds the containers of the ssz_generic test suite of the
+// consensus specs, along with a loader for its valid and invalid cases, so that
+// the library can be run against the cases generated by other implementations.
+package sszgeneric
+
+import (
+	"reflect"
+
+	"github.com/524119574/go-ssz/types"
+)
+
+// SingleFieldTestStruct is a container holding a single byte.
+type SingleFieldTestStruct struct {
+	A byte
+}
+
+// SmallTestStruct is a fixed-size container of two uint16 fields.
+type SmallTestStruct struct {
+	A uint16
+	B uint16
+}
+
+// FixedTestStruct is a fixed-size container of fields of different sizes.
+type FixedTestStruct struct {
+	A uint8
+	B uint64
+	C uint32
+}
+
+// VarTestStruct is a container with a list between two fixed-size fields.
+type VarTestStruct struct {
+	A uint16
+	B []uint16 `ssz-max:"1024"`
+	C uint8
+}
+
+// ComplexTestStruct nests the other containers in vectors and lists.
+type ComplexTestStruct struct {
+	A uint16
+	B []uint16 `ssz-max:"128"`
+	C uint8
+	D []byte `ssz-max:"256"`
+	E VarTestStruct
+	F [4]FixedTestStruct
+	G [2]VarTestStruct
+}
+
+// BitsStruct holds bitlists and bitvectors of lengths which are not multiples
+// of eight.
+type BitsStruct struct {
+	A types.Bitlist   `ssz-max:"5"`
+	B types.Bitvector `ssz-size:"2"`
+	C types.Bitvector `ssz-size:"1"`
+	D types.Bitlist   `ssz-max:"6"`
+	E types.Bitvector `ssz-size:"8"`
+}
+
+// Containers maps the names of the containers of the suite to their types.
+var Containers = map[string]reflect.Type{
+	"SingleFieldTestStruct": reflect.TypeOf(SingleFieldTestStruct{}),
+	"SmallTestStruct":       reflect.TypeOf(SmallTestStruct{}),
+	"FixedTestStruct":       reflect.TypeOf(FixedTestStruct{}),
+	"VarTestStruct":         reflect.TypeOf(VarTestStruct{}),
+	"ComplexTestStruct":     reflect.TypeOf(ComplexTestStruct{}),
+	"BitsStruct":            reflect.TypeOf(BitsStruct{}),
+}
@@ -1,10 +1,13 @@
 package ssz
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"reflect"
+	"strings"
+	"time"
 
-	fssz "github.com/ferranbt/fastssz"
 	"github.com/pkg/errors"
 	"github.com/524119574/go-ssz/types"
 )
@@ -44,35 +47,223 @@ import (
 // This will treat `Field2` as type [][32]byte when marshaling a
 // struct of that type.
 func Marshal(val interface{}) ([]byte, error) {
+	c := loadStatsCollector()
+	if c == nil {
+		enc, err := marshal(val)
+		return enc, newError(OpMarshal, val, err)
+	}
+	typ := statsType(val)
+	c.MarshalStart(typ)
+	start := time.Now()
+	enc, err := marshal(val)
+	err = newError(OpMarshal, val, err)
+	c.MarshalEnd(typ, len(enc), err, time.Since(start))
+	return enc, err
+}
+
+func marshal(val interface{}) ([]byte, error) {
 	if val == nil {
 		return nil, errors.New("untyped-value nil cannot be marshaled")
 	}
 
-	if v, ok := val.(fssz.Marshaler); ok {
+	if v, ok := val.(Marshaler); ok {
 		return v.MarshalSSZ()
 	}
 
-	rval := reflect.ValueOf(val)
-
-	// We pre-allocate a buffer-size depending on the value's calculated total byte size.
-	buf := make([]byte, types.DetermineSize(rval))
-	factory, err := types.SSZFactory(rval, rval.Type())
+	c, err := CodecFor(val)
 	if err != nil {
 		return nil, err
 	}
+	return c.marshal(val)
+}
+
+// marshalValue encodes rval into buf, which must be zeroed and exactly as long
+// as the encoding.
+func marshalValue(factory types.SSZAble, rval reflect.Value, buf []byte) error {
 	if rval.Type().Kind() == reflect.Ptr {
+		// A nil pointer is marshaled as the zero value of the type it points to.
+		elem := rval.Elem()
 		if rval.IsNil() {
-			return buf, nil
+			elem = reflect.New(rval.Type().Elem()).Elem()
 		}
-		if _, err := factory.Marshal(rval.Elem(), rval.Type().Elem(), buf, 0 /* start offset */); err != nil {
-			return nil, errors.Wrapf(err, "failed to marshal for type: %v", rval.Type().Elem())
+		_, err := factory.Marshal(elem, rval.Type().Elem(), buf, 0 /* start offset */)
+		return err
+	}
+	_, err := factory.Marshal(rval, rval.Type(), buf, 0 /* start offset */)
+	return err
+}
+
+// ErrBufferTooSmall is returned by MarshalAt when the encoding of a value does
+// not fit in the buffer it was given, which is then left untouched. Needed is
+// the size of the encoding, so that the caller can provide a larger buffer and
+// try again.
+type ErrBufferTooSmall struct {
+	Needed uint64
+	Got    uint64
+}
+
+func (e *ErrBufferTooSmall) Error() string {
+	return fmt.Sprintf("buffer of %d bytes is too small for an encoding of %d bytes", e.Got, e.Needed)
+}
+
+// MarshalAt marshals a value into the start of buf, such as a memory-mapped
+// region, and returns the number of bytes written. Unlike Marshal, it never
+// allocates or grows a buffer of its own: if the encoding is longer than buf,
+// it returns an error wrapping an *ErrBufferTooSmall without writing anything.
+//
+//  n, err := ssz.MarshalAt(region, state)
+//  var tooSmall *ssz.ErrBufferTooSmall
+//  if errors.As(err, &tooSmall) {
+//      region = remap(tooSmall.Needed)
+//      n, err = ssz.MarshalAt(region, state)
+//  }
+func MarshalAt(buf []byte, val interface{}) (uint64, error) {
+	c := loadStatsCollector()
+	if c == nil {
+		n, err := marshalAt(buf, val)
+		return n, newError(OpMarshal, val, err)
+	}
+	typ := statsType(val)
+	c.MarshalStart(typ)
+	start := time.Now()
+	n, err := marshalAt(buf, val)
+	err = newError(OpMarshal, val, err)
+	c.MarshalEnd(typ, int(n), err, time.Since(start))
+	return n, err
+}
+
+func marshalAt(buf []byte, val interface{}) (uint64, error) {
+	if val == nil {
+		return 0, errors.New("untyped-value nil cannot be marshaled")
+	}
+
+	if v, ok := val.(Marshaler); ok {
+		size := uint64(v.SizeSSZ())
+		if size > uint64(len(buf)) {
+			return 0, &ErrBufferTooSmall{Needed: size, Got: uint64(len(buf))}
+		}
+		// Capping the capacity makes an encoder writing more than it announced
+		// reallocate rather than write past the end of buf.
+		enc, err := v.MarshalSSZTo(buf[:0:size])
+		if err != nil {
+			return 0, err
+		}
+		if uint64(len(enc)) != size || (size > 0 && &enc[0] != &buf[0]) {
+			return 0, fmt.Errorf("generated encoder of %T wrote %d bytes, expected %d", val, len(enc), size)
+		}
+		return size, nil
+	}
+
+	rval := reflect.ValueOf(val)
+	c, err := CodecFor(val)
+	if err != nil {
+		return 0, err
+	}
+	size := c.encodedSize(rval)
+	if size > uint64(len(buf)) {
+		return 0, &ErrBufferTooSmall{Needed: size, Got: uint64(len(buf))}
+	}
+	// The encoders write into the zeroed buffers allocated by Marshal, so
+	// whatever buf held before is cleared first.
+	buf = buf[:size]
+	for i := range buf {
+		buf[i] = 0
+	}
+	if err := marshalValue(c.factory, rval, buf); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// SizeSSZ returns the number of bytes Marshal would produce for a value, such
+// as to size a network frame before marshaling into it. Fields are sized as
+// their ssz-size tags declare, so that a nil slice tagged `ssz-size:"65536,32"`
+// counts for the 2MB of zeros it is marshaled as. Unlike types.DetermineSize,
+// which counts them as zero bytes, values of types which cannot be marshaled,
+// such as complex numbers, channels and functions, are reported as errors.
+func SizeSSZ(val interface{}) (uint64, error) {
+	size, err := sizeSSZ(val)
+	return size, newError(OpMarshal, val, err)
+}
+
+func sizeSSZ(val interface{}) (uint64, error) {
+	if val == nil {
+		return 0, errors.New("untyped-value nil cannot be marshaled")
+	}
+	if v, ok := val.(Marshaler); ok {
+		return uint64(v.SizeSSZ()), nil
+	}
+	c, err := CodecFor(val)
+	if err != nil {
+		return 0, err
+	}
+	return c.sizeOf(val)
+}
+
+// MarshalTo appends the encoding of a value to dst and returns the extended
+// slice, growing it when its capacity is insufficient, the way MarshalAppend
+// works in protobuf. It produces the same bytes as Marshal, and lets callers
+// reuse their buffers, such as ones taken from a sync.Pool:
+//
+//  buf := pool.Get().([]byte)
+//  buf, err := ssz.MarshalTo(buf[:0], msg)
+//  if err != nil {
+//      return err
+//  }
+//  send(buf)
+//  pool.Put(buf)
+//
+// When marshaling fails, dst is returned as it was given.
+func MarshalTo(dst []byte, val interface{}) ([]byte, error) {
+	c := loadStatsCollector()
+	if c == nil {
+		enc, err := marshalTo(dst, val)
+		return enc, newError(OpMarshal, val, err)
+	}
+	typ := statsType(val)
+	c.MarshalStart(typ)
+	start := time.Now()
+	enc, err := marshalTo(dst, val)
+	err = newError(OpMarshal, val, err)
+	c.MarshalEnd(typ, len(enc)-len(dst), err, time.Since(start))
+	return enc, err
+}
+
+func marshalTo(dst []byte, val interface{}) ([]byte, error) {
+	if val == nil {
+		return dst, errors.New("untyped-value nil cannot be marshaled")
+	}
+
+	if v, ok := val.(Marshaler); ok {
+		enc, err := v.MarshalSSZTo(dst)
+		if err != nil {
+			return dst, err
 		}
-		return buf, nil
+		return enc, nil
 	}
-	if _, err := factory.Marshal(rval, rval.Type(), buf, 0 /* start offset */); err != nil {
-		return nil, errors.Wrapf(err, "failed to marshal for type: %v", rval.Type())
+
+	rval := reflect.ValueOf(val)
+	c, err := CodecFor(val)
+	if err != nil {
+		return dst, err
+	}
+	end := uint64(len(dst)) + c.encodedSize(rval)
+	enc := dst
+	if end > uint64(cap(dst)) {
+		enc = make([]byte, len(dst), end)
+		copy(enc, dst)
 	}
-	return buf, nil
+	enc = enc[:end]
+	// The spare capacity of dst may hold stale bytes, which the encoders
+	// expect to be zeroed.
+	buf := enc[len(dst):]
+	for i := range buf {
+		buf[i] = 0
+	}
+	if err := marshalValue(c.factory, rval, buf); err != nil {
+		return dst, err
+	}
+	return enc, nil
 }
 
 // Unmarshal SSZ encoded data and output it into the object pointed by pointer val.
@@ -88,40 +279,382 @@ func Marshal(val interface{}) ([]byte, error) {
 //      return fmt.Errorf("failed to unmarshal: %v", err)
 //  }
 func Unmarshal(input []byte, val interface{}) error {
+	return observeDecode(statsType(val), len(input), func() error {
+		return newError(OpUnmarshal, val, unmarshal(input, val, nil))
+	})
+}
+
+// ErrBudgetExceeded is returned by UnmarshalWithOptions when decoding is stopped
+// by the budget or deadline it was given. It is distinct from the errors
+// returned for malformed input, so callers can tell slow peers from faulty ones.
+var ErrBudgetExceeded = types.ErrBudgetExceeded
+
+// ErrAllocationLimit is returned by UnmarshalWithLimit when the lists, strings
+// and bitlists being decoded would allocate more memory than it allows.
+var ErrAllocationLimit = types.ErrAllocationLimit
+
+// UnmarshalOptions bounds the work done decoding untrusted input, and how
+// strictly it is checked.
+type UnmarshalOptions struct {
+	// Context stops decoding once it is done, such as when its deadline passes.
+	Context context.Context
+	// MaxElements bounds the number of container fields and list or vector
+	// elements decoded. Zero leaves it unlimited.
+	MaxElements uint64
+	// MaxDepth bounds how many containers, lists and vectors deep values may be
+	// nested. Zero leaves it unlimited.
+	MaxDepth uint64
+	// Strict rejects list fields holding more elements than their ssz-max tags
+	// allow, as the consensus specs require. Strings and bitlists exceeding
+	// their limits are always rejected.
+	Strict bool
+	// MaxBytes bounds the memory allocated for the lists, strings and bitlists
+	// decoded, as UnmarshalWithLimit does. Zero leaves it unlimited.
+	MaxBytes uint64
+}
+
+// UnmarshalWithOptions behaves as Unmarshal, except decoding fails with an error
+// wrapping ErrBudgetExceeded once it exceeds the bounds set in opts. The bounds
+// are checked as elements are decoded, with the context only checked every few
+// elements to keep decoding honest input as fast as with Unmarshal. Types with
+// generated fastssz methods decode with those, and only check the context once
+// before decoding.
+func UnmarshalWithOptions(input []byte, val interface{}, opts UnmarshalOptions) error {
+	ctx := types.NewDecodeContext(opts.Context, opts.MaxElements, opts.MaxDepth)
+	if opts.Strict {
+		ctx.SetStrict()
+	}
+	ctx.SetMaxBytes(opts.MaxBytes)
+	return observeDecode(statsType(val), len(input), func() error {
+		return newError(OpUnmarshal, val, unmarshal(input, val, ctx))
+	})
+}
+
+// UnmarshalStrict behaves as Unmarshal, except it enforces every rule the
+// consensus specs set for decoding, rejecting list fields holding more elements
+// than their ssz-max tags allow on top of what Unmarshal rejects: offsets which
+// do not start right after the fixed part or go backwards, trailing bytes, sizes
+// which are not a multiple of the size of the elements, and booleans and
+// bitlists with illegal encodings. Types with generated fastssz methods decode
+// with those, which are left to enforce the rules themselves.
+func UnmarshalStrict(input []byte, val interface{}) error {
+	return UnmarshalWithOptions(input, val, UnmarshalOptions{Strict: true})
+}
+
+// UnmarshalWithLimit behaves as Unmarshal, except decoding fails with an error
+// wrapping ErrAllocationLimit once the lists, strings and bitlists it decodes
+// would allocate more than maxBytes bytes in total. The memory taken by a list
+// is accounted for from the number of elements its encoding claims, before it
+// is allocated, so a small input cannot make the decoding allocate large lists.
+// Byte lists share the memory of input rather than allocating their own. Types
+// with generated fastssz methods decode with those, which are not bounded.
+func UnmarshalWithLimit(input []byte, val interface{}, maxBytes uint64) error {
+	return UnmarshalWithOptions(input, val, UnmarshalOptions{MaxBytes: maxBytes})
+}
+
+func unmarshal(input []byte, val interface{}, ctx *types.DecodeContext) error {
 	if val == nil {
 		return errors.New("cannot unmarshal into untyped, nil value")
 	}
-	if v, ok := val.(fssz.Unmarshaler); ok {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if v, ok := val.(Unmarshaler); ok {
 		return v.UnmarshalSSZ(input)
 	}
-	if len(input) == 0 {
-		return errors.New("no data to unmarshal from, input is an empty byte slice []byte{}")
+	rval, err := unmarshalTarget(input, val)
+	if err != nil {
+		return err
+	}
+	c, err := CodecFor(val)
+	if err != nil {
+		return err
 	}
+	return c.decode(input, rval, ctx)
+}
+
+// unmarshalTarget returns the value pointed by val after checking input can be
+// decoded into it.
+func unmarshalTarget(input []byte, val interface{}) (reflect.Value, error) {
 	rval := reflect.ValueOf(val)
 	rtyp := rval.Type()
+	// Empty containers and zero-length vectors are the only types which
+	// serialize to zero bytes, so they are the only ones we decode from empty input.
+	if len(input) == 0 && (rtyp.Kind() != reflect.Ptr || !types.IsZeroSizeType(rtyp.Elem())) {
+		return reflect.Value{}, fmt.Errorf("no data to unmarshal from, input is an empty byte slice []byte{}: %w", ErrInputTooShort)
+	}
 	// val must be a pointer, otherwise we refuse to unmarshal
 	if rtyp.Kind() != reflect.Ptr {
-		return errors.New("can only unmarshal into a pointer target")
+		return reflect.Value{}, errors.New("can only unmarshal into a pointer target")
 	}
 	if rval.IsNil() {
-		return errors.New("cannot output to pointer of nil value")
+		return reflect.Value{}, errors.New("cannot output to pointer of nil value")
+	}
+	return rval, nil
+}
+
+// checkDecodedSize verifies that the input decoded into val was exactly as long
+// as the encoding the decoders consumed from it, reporting the offset from which
+// they differ otherwise.
+func checkDecodedSize(val interface{}, expected uint64, received uint64) error {
+	if received == expected {
+		return nil
+	}
+	if received < expected {
+		return &Error{
+			Op:     OpUnmarshal,
+			Type:   reflect.TypeOf(val),
+			Offset: int64(received),
+			Err:    fmt.Errorf("unexpected amount of data, expected: %d, received: %d: %w", expected, received, ErrSizeMismatch),
+		}
 	}
-	factory, err := types.SSZFactory(rval.Elem(), rtyp.Elem())
+	return &Error{
+		Op:     OpUnmarshal,
+		Type:   reflect.TypeOf(val),
+		Offset: int64(expected),
+		Err: fmt.Errorf(
+			"unexpected amount of data, expected: %d, received: %d, leaving %d trailing bytes: %w",
+			expected,
+			received,
+			received-expected,
+			ErrSizeMismatch,
+		),
+	}
+}
+
+// IsZeroValue reports whether val encodes the same as the zero value of its
+// type. Nil and empty slices, nil pointers and zero-filled values all encode
+// as the zero value, so for example a pointer to a container with only zero
+// fields is reported as zero.
+func IsZeroValue(val interface{}) (bool, error) {
+	if val == nil {
+		return false, newError(OpMarshal, val, errors.New("untyped-value nil cannot be marshaled"))
+	}
+	enc, err := marshal(val)
 	if err != nil {
-		return err
+		return false, newError(OpMarshal, val, err)
 	}
-	if _, err := factory.Unmarshal(rval.Elem(), rval.Elem().Type(), input, 0); err != nil {
-		return errors.Wrapf(err, "could not unmarshal input into type: %v", rval.Elem().Type())
+	typ := reflect.TypeOf(val)
+	zero := reflect.New(typ)
+	if typ.Kind() == reflect.Ptr {
+		zero = reflect.New(typ.Elem())
 	}
+	zeroEnc, err := marshal(zero.Interface())
+	if err != nil {
+		return false, newError(OpMarshal, val, err)
+	}
+	return bytes.Equal(enc, zeroEnc), nil
+}
 
-	fixedSize := types.DetermineSize(rval)
-	totalLength := uint64(len(input))
-	if totalLength != fixedSize {
-		return fmt.Errorf(
-			"unexpected amount of data, expected: %d, received: %d",
-			fixedSize,
-			totalLength,
-		)
+// Reset clears the value pointed to by val so that it can be reused as the
+// target of Unmarshal, such as a value kept in a sync.Pool. Slices are
+// truncated to a length of zero while keeping their backing arrays, arrays and
+// basic fields are zeroed, and values behind pointers are cleared without being
+// freed. Decoding into a reset value reuses those backing arrays and pointers,
+// and no data from before the reset remains reachable through it.
+//
+//  block := pool.Get().(*Block)
+//  if err := ssz.Reset(block); err != nil {
+//      return err
+//  }
+//  if err := ssz.Unmarshal(enc, block); err != nil {
+//      return err
+//  }
+func Reset(val interface{}) error {
+	if val == nil {
+		return errors.New("cannot reset untyped, nil value")
+	}
+	rval := reflect.ValueOf(val)
+	if rval.Kind() != reflect.Ptr {
+		return errors.New("can only reset a pointer target")
 	}
+	if rval.IsNil() {
+		return errors.New("cannot reset pointer of nil value")
+	}
+	types.Reset(rval.Elem())
 	return nil
 }
+
+// HashTreeRoot determines the root hash using SSZ's Merkleization.
+// Given a struct with the following fields, one can tree hash it as follows:
+//  type exampleStruct struct {
+//      Field1 uint8
+//      Field2 []byte
+//  }
+//
+//  ex := exampleStruct{
+//      Field1: 10,
+//      Field2: []byte{1, 2, 3, 4},
+//  }
+//  root, err := HashTreeRoot(ex)
+//  if err != nil {
+//      return errors.Wrap(err, "failed to compute root")
+//  }
+func HashTreeRoot(val interface{}) ([32]byte, error) {
+	return observeHashTreeRoot(val, func() ([32]byte, error) {
+		root, err := hashTreeRoot(val)
+		return root, newError(OpHash, val, err)
+	})
+}
+
+func hashTreeRoot(val interface{}) ([32]byte, error) {
+	return rootWithContext(val, nil)
+}
+
+// rootWithContext returns the hash tree root of val computed with ctx. Bitvectors
+// are hashed on their own, as they have no codec outside struct fields.
+func rootWithContext(val interface{}, ctx *types.HashContext) ([32]byte, error) {
+	if val == nil {
+		return [32]byte{}, errors.New("untyped nil is not supported")
+	}
+	switch val.(type) {
+	case Bitvector, *Bitvector:
+		return types.BitvectorRoot(reflect.ValueOf(val), ctx)
+	}
+	c, err := CodecFor(val)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return c.root(val, ctx)
+}
+
+// HashTreeRootWithCapacity determines the root hash of a dynamic list
+// using SSZ's Merkleization and applies a max capacity value when computing the root.
+// If the input is not a slice, the function returns an error.
+//
+//  accountBalances := []uint64{1, 2, 3, 4}
+//  root, err := HashTreeRootWithCapacity(accountBalances, 100) // Max 100 accounts.
+//  if err != nil {
+//      return errors.Wrap(err, "failed to compute root")
+//  }
+func HashTreeRootWithCapacity(val interface{}, maxCapacity uint64) ([32]byte, error) {
+	return observeHashTreeRoot(val, func() ([32]byte, error) {
+		root, err := hashTreeRootWithCapacity(val, maxCapacity)
+		return root, newError(OpHash, val, err)
+	})
+}
+
+func hashTreeRootWithCapacity(val interface{}, maxCapacity uint64) ([32]byte, error) {
+	if val == nil {
+		return [32]byte{}, errors.New("untyped nil is not supported")
+	}
+	rval := reflect.ValueOf(val)
+	if rval.Kind() != reflect.Slice {
+		return [32]byte{}, fmt.Errorf("expected slice-kind input, received %v", rval.Kind())
+	}
+	factory, err := types.SSZFactory(rval, rval.Type())
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return factory.Root(rval, rval.Type(), "", maxCapacity, nil)
+}
+
+// ElementRoots returns the hash tree root of each element of a slice or an
+// array, such as the roots of attestations to deduplicate them, computing them
+// in a single pass rather than calling HashTreeRoot for every element. Nil
+// pointer elements have the root of the zero value they point to.
+//
+//  roots, err := ElementRoots(block.Body.Attestations)
+//  if err != nil {
+//      return errors.Wrap(err, "failed to compute attestation roots")
+//  }
+func ElementRoots(list interface{}) ([][32]byte, error) {
+	if list == nil {
+		return nil, newError(OpHash, list, errors.New("untyped nil is not supported"))
+	}
+	roots, err := types.ElementRoots(reflect.ValueOf(list))
+	if err != nil {
+		return nil, newError(OpHash, list, err)
+	}
+	return roots, nil
+}
+
+// RootOfRootList returns the hash tree root of a list of at most limit 32-byte
+// roots laid out back to back in flat, such as commitments hashed elsewhere,
+// without building a [][32]byte out of them. It is the root HashTreeRoot
+// returns for the same roots held in a [][32]byte field tagged ssz-max:"limit".
+//
+//  root, err := ssz.RootOfRootList(commitmentRoots, 4096)
+func RootOfRootList(flat []byte, limit uint64) ([32]byte, error) {
+	return observeHashTreeRoot(flat, func() ([32]byte, error) {
+		root, err := types.RootOfRootList(flat, limit)
+		return root, newError(OpHash, flat, err)
+	})
+}
+
+// RootOfRootVector returns the hash tree root of a vector of length 32-byte
+// roots laid out back to back in flat, which is the root HashTreeRoot returns
+// for the same roots held in a [length][32]byte array.
+func RootOfRootVector(flat []byte, length uint64) ([32]byte, error) {
+	return observeHashTreeRoot(flat, func() ([32]byte, error) {
+		root, err := types.RootOfRootVector(flat, length)
+		return root, newError(OpHash, flat, err)
+	})
+}
+
+// HashTreeRootWithLimits determines the root hash of a container whose list
+// fields cannot be tagged, such as types declared in other modules. The limits
+// map field paths to the capacities an ssz-max tag would declare for them, and
+// take precedence over the tags which do exist. Fields of containers within
+// lists are addressed through the path of the list itself.
+//
+//  root, err := HashTreeRootWithLimits(block, map[string]uint64{
+//      "Body.Attestations":                 128,
+//      "Body.Attestations.AggregationBits": 2048,
+//  })
+//
+// Every list reachable from val must have a limit, otherwise an error listing
+// the paths of those without one is returned.
+func HashTreeRootWithLimits(val interface{}, limits map[string]uint64) ([32]byte, error) {
+	return observeHashTreeRoot(val, func() ([32]byte, error) {
+		root, err := hashTreeRootWithLimits(val, limits)
+		return root, newError(OpHash, val, err)
+	})
+}
+
+func hashTreeRootWithLimits(val interface{}, limits map[string]uint64) ([32]byte, error) {
+	if val == nil {
+		return [32]byte{}, errors.New("untyped nil is not supported")
+	}
+	rval := reflect.ValueOf(val)
+	rtyp := rval.Type()
+	if rtyp.Kind() == reflect.Ptr {
+		rtyp = rtyp.Elem()
+	}
+	if rtyp.Kind() != reflect.Struct {
+		return [32]byte{}, fmt.Errorf("expected struct-kind input, received %v", rtyp.Kind())
+	}
+	if missing := types.MissingLimits(rtyp, limits); len(missing) > 0 {
+		return [32]byte{}, fmt.Errorf("no limit given for lists at: %s", strings.Join(missing, ", "))
+	}
+	factory, err := types.SSZFactory(rval, rval.Type())
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return factory.Root(rval, rval.Type(), "", 0, types.NewHashContext(limits))
+}
+
+// HashTreeRootTruncated determines the root hash of the container val as if
+// its last dropLast fields were not part of it, which is how roots signed by a
+// signature stored in the container itself are computed. dropLast must be less
+// than the number of fields HashTreeRoot hashes, and the dropped fields are not
+// hashed.
+func HashTreeRootTruncated(val interface{}, dropLast int) ([32]byte, error) {
+	return observeHashTreeRoot(val, func() ([32]byte, error) {
+		root, err := hashTreeRootTruncated(val, dropLast)
+		return root, newError(OpHash, val, err)
+	})
+}
+
+func hashTreeRootTruncated(val interface{}, dropLast int) ([32]byte, error) {
+	if val == nil {
+		return [32]byte{}, errors.New("untyped nil is not supported")
+	}
+	return types.TruncatedContainerRoot(reflect.ValueOf(val), dropLast)
+}
+
+// SigningRoot determines the root hash of the container val without its last
+// field, which holds the signature over that root.
+func SigningRoot(val interface{}) ([32]byte, error) {
+	return HashTreeRootTruncated(val, 1)
+}
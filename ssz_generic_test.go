@@ -0,0 +1,275 @@
+package ssz
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/524119574/go-ssz/spectests/sszgeneric"
+)
+
+// TestSSZGenericContainers runs the containers cases of the ssz_generic suite
+// of the consensus specs. A small corpus is bundled in testdata, and the cases
+// generated by other implementations can be run by pointing SSZ_GENERIC_DIR to
+// their containers folder.
+func TestSSZGenericContainers(t *testing.T) {
+	dir := os.Getenv("SSZ_GENERIC_DIR")
+	if dir == "" {
+		dir = "testdata/ssz_generic/containers"
+	}
+	cases, err := sszgeneric.LoadCases(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) == 0 {
+		t.Fatalf("No cases found in %s", dir)
+	}
+	for _, c := range cases {
+		c := c
+		validity := "invalid"
+		if c.Valid {
+			validity = "valid"
+		}
+		t.Run(validity+"/"+c.Name, func(t *testing.T) {
+			typ, ok := sszgeneric.Containers[c.Container]
+			if !ok {
+				t.Skipf("Container %s is not supported", c.Container)
+			}
+			if !c.Valid {
+				if err := Unmarshal(c.Serialized, reflect.New(typ).Interface()); err == nil {
+					t.Error("Expected invalid encoding to be rejected")
+				}
+				if err := UnmarshalStrict(c.Serialized, reflect.New(typ).Interface()); err == nil {
+					t.Error("Expected invalid encoding to be rejected in strict mode")
+				}
+				return
+			}
+			want := reflect.New(typ).Interface()
+			if err := c.DecodeValue(want); err != nil {
+				t.Fatal(err)
+			}
+			got := reflect.New(typ).Interface()
+			if err := Unmarshal(c.Serialized, got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Unmarshaled %+v, expected %+v", got, want)
+			}
+			strict := reflect.New(typ).Interface()
+			if err := UnmarshalStrict(c.Serialized, strict); err != nil {
+				t.Fatalf("Expected valid encoding to be accepted in strict mode: %v", err)
+			}
+			if !reflect.DeepEqual(strict, want) {
+				t.Errorf("Unmarshaled %+v in strict mode, expected %+v", strict, want)
+			}
+			enc, err := Marshal(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(enc, c.Serialized) {
+				t.Errorf("Marshaled %#x, expected %#x", enc, c.Serialized)
+			}
+			root, err := HashTreeRoot(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if root != c.Root {
+				t.Errorf("Computed root %#x, expected %#x", root, c.Root)
+			}
+		})
+	}
+}
+
+// TestSSZGenericBitlists runs the bitlist cases of the ssz_generic suite, whose
+// names start with the limit of the bitlist, such as bitlist_8_but_9. The
+// bundled corpus holds the invalid cases of the consensus specs, and valid
+// cases whose roots were computed by fastssz. Other cases can be run by
+// pointing SSZ_GENERIC_BITLIST_DIR to their bitlist folder.
+func TestSSZGenericBitlists(t *testing.T) {
+	dir := os.Getenv("SSZ_GENERIC_BITLIST_DIR")
+	if dir == "" {
+		dir = "testdata/ssz_generic/bitlist"
+	}
+	cases, err := sszgeneric.LoadCases(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) == 0 {
+		t.Fatalf("No cases found in %s", dir)
+	}
+	for _, c := range cases {
+		c := c
+		validity := "invalid"
+		if c.Valid {
+			validity = "valid"
+		}
+		t.Run(validity+"/"+c.Name, func(t *testing.T) {
+			// Cases without a limit, such as bitlist_no_delimiter_empty, are
+			// invalid whatever the limit.
+			limit, err := strconv.ParseUint(strings.Split(c.Name, "_")[1], 10, 64)
+			if err != nil {
+				limit = 8
+			}
+			// Limits are declared by field tags, so the bitlist is decoded as the
+			// only field of a container, whose encoding starts with its offset.
+			container := reflect.StructOf([]reflect.StructField{{
+				Name: "Bits",
+				Type: reflect.TypeOf(Bitlist{}),
+				Tag:  reflect.StructTag(`ssz-max:"` + strconv.FormatUint(limit, 10) + `"`),
+			}})
+			enc := append([]byte{4, 0, 0, 0}, c.Serialized...)
+			if !c.Valid {
+				if err := Unmarshal(enc, reflect.New(container).Interface()); err == nil {
+					t.Error("Expected invalid encoding to be rejected")
+				}
+				return
+			}
+			var want Bitlist
+			if err := c.DecodeValue(&want); err != nil {
+				t.Fatal(err)
+			}
+			got := reflect.New(container)
+			if err := Unmarshal(enc, got.Interface()); err != nil {
+				t.Fatal(err)
+			}
+			if bits := got.Elem().Field(0).Interface().(Bitlist); !bytes.Equal(bits, want) {
+				t.Errorf("Unmarshaled %#x, expected %#x", bits, want)
+			}
+			serialized, err := Marshal(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(serialized, c.Serialized) {
+				t.Errorf("Marshaled %#x, expected %#x", serialized, c.Serialized)
+			}
+			root, err := HashTreeRootWithCapacity(want, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if root != c.Root {
+				t.Errorf("Computed root %#x, expected %#x", root, c.Root)
+			}
+		})
+	}
+}
+
+// TestSSZGenericBitvectors runs the bitvector cases of the ssz_generic suite,
+// whose names start with the length of the bitvector, such as bitvec_4_max_0.
+// The bundled corpus was generated in the layout of the consensus specs, with
+// roots computed by fastssz. Other cases can be run by pointing
+// SSZ_GENERIC_BITVECTOR_DIR to their bitvector folder.
+func TestSSZGenericBitvectors(t *testing.T) {
+	dir := os.Getenv("SSZ_GENERIC_BITVECTOR_DIR")
+	if dir == "" {
+		dir = "testdata/ssz_generic/bitvector"
+	}
+	cases, err := sszgeneric.LoadCases(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) == 0 {
+		t.Fatalf("No cases found in %s", dir)
+	}
+	for _, c := range cases {
+		c := c
+		validity := "invalid"
+		if c.Valid {
+			validity = "valid"
+		}
+		t.Run(validity+"/"+c.Name, func(t *testing.T) {
+			length, err := strconv.ParseUint(strings.Split(c.Name, "_")[1], 10, 64)
+			if err != nil || length == 0 {
+				t.Skipf("Bitvectors of length %s are not supported", strings.Split(c.Name, "_")[1])
+			}
+			// Lengths are declared by field tags, so the bitvector is handled as
+			// the only field of a container, which has the same encoding and root.
+			container := reflect.StructOf([]reflect.StructField{{
+				Name: "Bits",
+				Type: reflect.TypeOf(Bitvector{}),
+				Tag:  reflect.StructTag(`ssz-size:"` + strconv.FormatUint(length, 10) + `"`),
+			}})
+			if !c.Valid {
+				if err := Unmarshal(c.Serialized, reflect.New(container).Interface()); err == nil {
+					t.Error("Expected invalid encoding to be rejected")
+				}
+				return
+			}
+			want := reflect.New(container)
+			if err := c.DecodeValue(want.Elem().Field(0).Addr().Interface()); err != nil {
+				t.Fatal(err)
+			}
+			got := reflect.New(container)
+			if err := Unmarshal(c.Serialized, got.Interface()); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Interface(), want.Interface()) {
+				t.Errorf("Unmarshaled %#x, expected %#x", got.Elem().Field(0).Bytes(), want.Elem().Field(0).Bytes())
+			}
+			enc, err := Marshal(want.Interface())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(enc, c.Serialized) {
+				t.Errorf("Marshaled %#x, expected %#x", enc, c.Serialized)
+			}
+			root, err := HashTreeRoot(want.Interface())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if root != c.Root {
+				t.Errorf("Computed root %#x, expected %#x", root, c.Root)
+			}
+		})
+	}
+}
+
+// TestUnmarshalStrict_ListLimits checks the lists of the ssz_generic containers
+// exceeding their limits, which the spec rejects, are only rejected in strict
+// mode.
+func TestUnmarshalStrict_ListLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		val  interface{}
+		new  func() interface{}
+		err  string
+	}{
+		{
+			name: "VarTestStruct",
+			val:  &sszgeneric.VarTestStruct{B: make([]uint16, 1025)},
+			new:  func() interface{} { return &sszgeneric.VarTestStruct{} },
+			err:  "list field B has 1025 elements, exceeding its ssz-max of 1024",
+		},
+		{
+			name: "ComplexTestStruct",
+			val:  &sszgeneric.ComplexTestStruct{D: make([]byte, 257)},
+			new:  func() interface{} { return &sszgeneric.ComplexTestStruct{} },
+			err:  "list field D has 257 elements, exceeding its ssz-max of 256",
+		},
+		{
+			name: "NestedVarTestStruct",
+			val:  &sszgeneric.ComplexTestStruct{G: [2]sszgeneric.VarTestStruct{{}, {B: make([]uint16, 1025)}}},
+			new:  func() interface{} { return &sszgeneric.ComplexTestStruct{} },
+			err:  "list field B has 1025 elements",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := Marshal(tt.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Unmarshal(enc, tt.new()); err != nil {
+				t.Fatalf("Expected lenient decoding to accept the list, received %v", err)
+			}
+			if err := UnmarshalStrict(enc, tt.new()); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected strict decoding to fail with %q, received %v", tt.err, err)
+			}
+			if err := UnmarshalWithOptions(enc, tt.new(), UnmarshalOptions{Strict: true}); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected decoding with the Strict option to fail with %q, received %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -2,12 +2,18 @@ package ssz
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
+	"io/ioutil"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/524119574/go-ssz/types"
 	"github.com/pkg/errors"
-	"github.com/prysmaticlabs/go-ssz/types"
 )
 
 type beaconState struct {
@@ -52,6 +58,58 @@ func TestNilElementDetermineSize(t *testing.T) {
 	}
 }
 
+func TestNilPointerDetermineSize_MatchesMarshal(t *testing.T) {
+	type variableItem struct {
+		Data []byte
+		Slot uint64
+	}
+	type nestedItem struct {
+		Fork     *fork
+		Variable *variableItem
+	}
+	type basicPointers struct {
+		Slot    *uint64
+		Version *[4]byte
+		Flag    uint8
+	}
+	tests := []struct {
+		name     string
+		nilVal   interface{}
+		zeroVal  interface{}
+		wantSize uint64
+	}{
+		{name: "Fixed", nilVal: (*fork)(nil), zeroVal: &fork{}, wantSize: 16},
+		{name: "Variable", nilVal: (*variableItem)(nil), zeroVal: &variableItem{}, wantSize: 12},
+		{name: "NestedPointers", nilVal: (*nestedItem)(nil), zeroVal: &nestedItem{}, wantSize: 32},
+		{name: "BasicPointers", nilVal: (*basicPointers)(nil), zeroVal: &basicPointers{}, wantSize: 13},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := types.DetermineSize(reflect.ValueOf(tt.nilVal))
+			if size != tt.wantSize {
+				t.Errorf("Wanted size %d, received %d", tt.wantSize, size)
+			}
+			nilEnc, err := Marshal(tt.nilVal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			zeroEnc, err := Marshal(tt.zeroVal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if uint64(len(nilEnc)) != size {
+				t.Errorf("Wanted marshaled length %d, received %d", size, len(nilEnc))
+			}
+			if !bytes.Equal(nilEnc, zeroEnc) {
+				t.Errorf("Expected nil pointer to marshal as zero value %v, received %v", zeroEnc, nilEnc)
+			}
+			if size := types.DetermineSize(reflect.ValueOf(tt.zeroVal)); size != tt.wantSize {
+				t.Errorf("Wanted zero value size %d, received %d", tt.wantSize, size)
+			}
+		})
+	}
+}
+
 func TestMarshalNilArray(t *testing.T) {
 	type ex struct {
 		Slot         uint64
@@ -99,6 +157,131 @@ func TestPartialDataMarshalUnmarshal(t *testing.T) {
 	}
 }
 
+func TestProtoMetadataFields_Skipped(t *testing.T) {
+	// The metadata fields are skipped wherever they appear among the fields.
+	type leadingMetadata struct {
+		XXX_sizecache int32 `json:"-"`
+		Foo           []byte
+		Bar           uint64
+	}
+	plain := &simpleNonProtoMessage{Foo: []byte{1, 2, 3}, Bar: 7}
+	values := []interface{}{
+		&simpleProtoMessage{Foo: []byte{1, 2, 3}, Bar: 7, XXX_unrecognized: []byte{9, 9}, XXX_sizecache: 5},
+		&leadingMetadata{XXX_sizecache: 5, Foo: []byte{1, 2, 3}, Bar: 7},
+	}
+	want, err := Marshal(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRoot, err := HashTreeRoot(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, val := range values {
+		enc, err := Marshal(val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(enc, want) {
+			t.Errorf("%T: expected the encoding %#x of the fields alone, received %#x", val, want, enc)
+		}
+		if size, err := SizeSSZ(val); err != nil || size != uint64(len(want)) {
+			t.Errorf("%T: expected a size of %d, received %d, %v", val, len(want), size, err)
+		}
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode(val); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%T: expected the encoder to write %#x, received %#x", val, want, buf.Bytes())
+		}
+		root, err := HashTreeRoot(val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root != wantRoot {
+			t.Errorf("%T: expected the root %#x of the fields alone, received %#x", val, wantRoot, root)
+		}
+		decoded := reflect.New(reflect.TypeOf(val).Elem())
+		if err := Unmarshal(enc, decoded.Interface()); err != nil {
+			t.Fatal(err)
+		}
+		if foo := decoded.Elem().FieldByName("Foo").Bytes(); !bytes.Equal(foo, plain.Foo) {
+			t.Errorf("%T: expected Foo to decode as %v, received %v", val, plain.Foo, foo)
+		}
+		if bar := decoded.Elem().FieldByName("Bar").Uint(); bar != plain.Bar {
+			t.Errorf("%T: expected Bar to decode as %d, received %d", val, plain.Bar, bar)
+		}
+	}
+}
+
+func TestSkippedFields(t *testing.T) {
+	type withSkipped struct {
+		Slot  uint64
+		Cache map[string][]byte `ssz:"-"`
+		Data  []byte            `ssz-max:"8"`
+		Hits  int               `ssz:"-"`
+		Epoch uint32
+	}
+	type withoutSkipped struct {
+		Slot  uint64
+		Data  []byte `ssz-max:"8"`
+		Epoch uint32
+	}
+	val := &withSkipped{Slot: 1, Cache: map[string][]byte{"a": {1}}, Data: []byte{2, 3}, Hits: 4, Epoch: 5}
+	plain := &withoutSkipped{Slot: 1, Data: []byte{2, 3}, Epoch: 5}
+	want, err := Marshal(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := Marshal(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, want) {
+		t.Errorf("Expected the encoding %#x of the serialized fields alone, received %#x", want, enc)
+	}
+	if size, err := SizeSSZ(val); err != nil || size != uint64(len(want)) {
+		t.Errorf("Expected a size of %d, received %d, %v", len(want), size, err)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(val); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("Expected the encoder to write %#x, received %#x", want, buf.Bytes())
+	}
+	wantRoot, err := HashTreeRoot(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root, err := HashTreeRoot(val); err != nil || root != wantRoot {
+		t.Errorf("Expected the root %#x, received %#x, %v", wantRoot, root, err)
+	}
+	codec, err := CodecFor(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxSize, err := codec.MaxSize(); err != nil || maxSize != 8+4+8+4 {
+		t.Errorf("Expected a maximum size of %d, received %d, %v", 8+4+8+4, maxSize, err)
+	}
+
+	// Decoding leaves the skipped fields as they were.
+	cache := map[string][]byte{"b": {6}}
+	for _, decode := range []func(*withSkipped) error{
+		func(dec *withSkipped) error { return Unmarshal(enc, dec) },
+		func(dec *withSkipped) error { return NewDecoder(bytes.NewReader(enc)).Decode(dec) },
+	} {
+		dec := &withSkipped{Cache: cache, Hits: 7}
+		if err := decode(dec); err != nil {
+			t.Fatal(err)
+		}
+		if expected := (&withSkipped{Slot: 1, Cache: cache, Data: []byte{2, 3}, Hits: 7, Epoch: 5}); !reflect.DeepEqual(dec, expected) {
+			t.Errorf("Expected %+v, received %+v", expected, dec)
+		}
+	}
+}
+
 func TestMarshal(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -113,17 +296,17 @@ func TestMarshal(t *testing.T) {
 		{
 			name:  "Unsupported",
 			input: complex(1, 1),
-			err:   errors.New("unsupported kind: complex128"),
+			err:   errors.New("unsupported kind: complex128: unsupported type"),
 		},
 		{
 			name:  "UnsupportedPointer",
 			input: &[]complex128{complex(1, 1), complex(1, 1)},
-			err:   errors.New("failed to marshal for type: []complex128: unsupported kind: complex128"),
+			err:   errors.New("unsupported kind: complex128: unsupported type"),
 		},
 		{
 			name:  "UnsupportedStructElement",
 			input: struct{ Foo complex128 }{complex(1, 1)},
-			err:   errors.New("failed to marshal for type: struct { Foo complex128 }: unsupported kind: complex128"),
+			err:   errors.New("unsupported kind: complex128: unsupported type"),
 		},
 		{
 			name:   "Simple",
@@ -146,8 +329,47 @@ func TestMarshal(t *testing.T) {
 				if err == nil {
 					t.Fatalf("missing expected error %v", test.err)
 				}
-				if test.err.Error() != err.Error() {
-					t.Errorf("incorrect error: expected %v; received %v", test.err, err)
+				checkError(t, err, OpMarshal, reflect.TypeOf(test.input), test.err.Error())
+			}
+		})
+	}
+}
+
+func TestPlatformWidthKinds_Rejected(t *testing.T) {
+	type intField struct {
+		Slot  uint64
+		Value int
+	}
+	type uintList struct {
+		Values []uint `ssz-max:"8"`
+	}
+	type uintptrVector struct {
+		Values [2]*uintptr
+	}
+	values := []interface{}{
+		new(int),
+		new(uint),
+		new(uintptr),
+		&[]int{1, 2},
+		&[4]uint{},
+		&intField{},
+		&uintList{Values: []uint{1}},
+		&uintptrVector{},
+	}
+	const want = "has a platform-dependent width"
+	for _, val := range values {
+		name := reflect.TypeOf(val).Elem().String()
+		t.Run(name, func(t *testing.T) {
+			checks := map[string]error{}
+			_, checks["Marshal"] = Marshal(val)
+			checks["Unmarshal"] = Unmarshal(make([]byte, 64), val)
+			_, checks["HashTreeRoot"] = HashTreeRoot(val)
+			_, checks["SizeSSZ"] = SizeSSZ(val)
+			checks["Encode"] = NewEncoder(ioutil.Discard).Encode(val)
+			checks["Decode"] = NewDecoder(bytes.NewReader(make([]byte, 64))).Decode(val)
+			for op, err := range checks {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected %s to fail with an error containing %q, received %v", op, want, err)
 				}
 			}
 		})
@@ -175,7 +397,7 @@ func TestUnmarshal(t *testing.T) {
 			name:   "OutputNotSupported",
 			input:  []byte{0x00, 0x00, 0x00, 0x00},
 			output: &struct{ Foo complex128 }{complex(1, 1)},
-			err:    errors.New("could not unmarshal input into type: struct { Foo complex128 }: unsupported kind: complex128"),
+			err:    errors.New("unsupported kind: complex128: unsupported type"),
 		},
 	}
 
@@ -190,9 +412,7 @@ func TestUnmarshal(t *testing.T) {
 				if err == nil {
 					t.Fatalf("missing expected error %v", test.err)
 				}
-				if test.err.Error() != err.Error() {
-					t.Errorf("unexpected error value %v (expected %v)", err, test.err)
-				}
+				checkError(t, err, OpUnmarshal, reflect.TypeOf(test.output), test.err.Error())
 			}
 		})
 	}
@@ -246,12 +466,6 @@ func TestEmptyDataUnmarshal(t *testing.T) {
 	}
 }
 
-func toBytes32(x []byte) [32]byte {
-	var y [32]byte
-	copy(y[:], x)
-	return y
-}
-
 func TestBoolArray_MissingByte(t *testing.T) {
 	objBytes := hexDecodeOrDie(t, "010101010101010101010101010101")
 	var result [16]bool
@@ -268,6 +482,176 @@ func TestBoolArray_ExtraByte(t *testing.T) {
 	}
 }
 
+// boolVector returns an addressable array of len(bits) bools holding bits.
+func boolVector(bits []bool) reflect.Value {
+	vec := reflect.New(reflect.ArrayOf(len(bits), reflect.TypeOf(false))).Elem()
+	reflect.Copy(vec, reflect.ValueOf(bits))
+	return vec
+}
+
+func TestBooleanVectors_HashTreeRoot(t *testing.T) {
+	max := func(n int) []bool {
+		bits := make([]bool, n)
+		for i := range bits {
+			bits[i] = true
+		}
+		return bits
+	}
+	random := func(n int) []bool {
+		bits := make([]bool, n)
+		for i := range bits {
+			bits[i] = i%3 == 0
+		}
+		return bits
+	}
+	// Bools are packed 32 per chunk, lists are padded to as many chunks as
+	// their limit requires and mixed in with their number of elements.
+	tests := []struct {
+		name  string
+		bits  []bool
+		limit uint64
+		root  string
+	}{
+		{name: "vec_bool_1_max", bits: max(1), root: "0100000000000000000000000000000000000000000000000000000000000000"},
+		{name: "vec_bool_8_random", bits: random(8), root: "0100000100000100000000000000000000000000000000000000000000000000"},
+		{name: "vec_bool_32_max", bits: max(32), root: "0101010101010101010101010101010101010101010101010101010101010101"},
+		{name: "vec_bool_33_max", bits: max(33), root: "0e538004bd522643079dc16316bd90985b62101ed2f0f51349f479efb17bc059"},
+		{name: "vec_bool_513_random", bits: random(513), root: "b4fcba693db4474bac13575473762a3f01f4e114626515c07aff390bec8718cc"},
+		{name: "list_bool_1_empty", bits: []bool{}, limit: 1, root: "f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a92759fb4b"},
+		{name: "list_bool_8_random", bits: random(5), limit: 8, root: "b3c099796db1ebc8fbeb4f96d673d2d99e9365720367ee544dcfe0ed7dbad296"},
+		{name: "list_bool_33_max", bits: max(33), limit: 33, root: "7f733b9608c95bfd7b0436231f995558c1ec8a6bea88391949332cdedd5d6cc2"},
+		{name: "list_bool_513_random", bits: random(40), limit: 513, root: "222b85fd41b136a660171ba6dd3622bdff1a47f942b8cf40f63feb334cc97bd6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := hexDecodeOrDie(t, tt.root)
+			var root [32]byte
+			var err error
+			if tt.limit == 0 {
+				vec := boolVector(tt.bits)
+				root, err = HashTreeRoot(vec.Interface())
+				if err != nil {
+					t.Fatal(err)
+				}
+				// Elements behind pointers are packed just the same.
+				ptrs := reflect.New(reflect.ArrayOf(len(tt.bits), reflect.TypeOf(new(bool)))).Elem()
+				for i := range tt.bits {
+					ptrs.Index(i).Set(vec.Index(i).Addr())
+				}
+				ptrRoot, err := HashTreeRoot(ptrs.Interface())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(ptrRoot[:], want) {
+					t.Errorf("Expected root %#x for pointers to bools, received %#x", want, ptrRoot)
+				}
+			} else {
+				root, err = HashTreeRootWithCapacity(tt.bits, tt.limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(root[:], want) {
+				t.Errorf("Expected root %#x, received %#x", want, root)
+			}
+		})
+	}
+}
+
+func TestBoolArray_NamedBoolType(t *testing.T) {
+	type flag bool
+	flags := [3]flag{true, false, true}
+	enc, err := Marshal(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{1, 0, 1}; !bytes.Equal(enc, want) {
+		t.Errorf("Expected encoding %v, received %v", want, enc)
+	}
+	root, err := HashTreeRoot(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, err := HashTreeRoot([3]bool{true, false, true}); err != nil || root != want {
+		t.Errorf("Expected root %#x, received %#x (%v)", want, root, err)
+	}
+	var dec [3]flag
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if dec != flags {
+		t.Errorf("Expected %v, received %v", flags, dec)
+	}
+}
+
+func TestBasicArrays_MissingAndExtraByte(t *testing.T) {
+	tests := []struct {
+		name   string
+		target func() interface{}
+		size   int
+	}{
+		{name: "[16]bool", target: func() interface{} { return &[16]bool{} }, size: 16},
+		{name: "[16]uint16", target: func() interface{} { return &[16]uint16{} }, size: 32},
+		{name: "[8]uint32", target: func() interface{} { return &[8]uint32{} }, size: 32},
+		{name: "[8]uint64", target: func() interface{} { return &[8]uint64{} }, size: 64},
+		{name: "struct field", target: func() interface{} { return &struct{ Vector [4]uint64 }{} }, size: 32},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Unmarshal(make([]byte, tt.size), tt.target()); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			err := Unmarshal(make([]byte, tt.size-1), tt.target())
+			want := fmt.Sprintf("expected %d bytes", tt.size)
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected missing byte to fail with error containing %q, received %v", want, err)
+			}
+			err = Unmarshal(make([]byte, tt.size+1), tt.target())
+			want = fmt.Sprintf("expected: %d, received: %d", tt.size, tt.size+1)
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected extra byte to fail with error containing %q, received %v", want, err)
+			}
+		})
+	}
+}
+
+func TestRootsArrays_TruncatedInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		target func() interface{}
+		size   int
+	}{
+		{name: "[4][32]byte", target: func() interface{} { return &[4][32]byte{} }, size: 128},
+		{name: "[][32]byte", target: func() interface{} { return &[][32]byte{} }, size: 32},
+		{name: "struct field", target: func() interface{} {
+			return &struct {
+				Roots [][]byte `ssz-size:"4,32"`
+			}{}
+		}, size: 128},
+	}
+	unmarshalers := map[string]func([]byte, interface{}) error{
+		"Unmarshal":       Unmarshal,
+		"UnmarshalStrict": UnmarshalStrict,
+		"UnmarshalWithLimit": func(input []byte, val interface{}) error {
+			return UnmarshalWithLimit(input, val, 1<<20)
+		},
+	}
+	for _, tt := range tests {
+		for name, unmarshal := range unmarshalers {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				if err := unmarshal(make([]byte, tt.size), tt.target()); err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				for _, size := range []int{tt.size - 29, 4} {
+					if err := unmarshal(make([]byte, size), tt.target()); !errors.Is(err, ErrInputTooShort) {
+						t.Errorf("Expected %d bytes to be rejected as too short, received %v", size, err)
+					}
+				}
+			})
+		}
+	}
+}
+
 func TestBoolArray_Correct(t *testing.T) {
 	objBytes := hexDecodeOrDie(t, "01010101010101010101010101010101")
 	var result [16]bool
@@ -283,3 +667,2289 @@ func hexDecodeOrDie(t *testing.T, s string) []byte {
 	}
 	return res
 }
+
+func TestHashTreeRoot(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  interface{}
+		output [32]byte
+		err    error
+	}{
+		{
+			name: "Nil",
+			err:  errors.New("untyped nil is not supported"),
+		},
+		{
+			name:  "UnsupportedKind",
+			input: complex(1, 1),
+			err:   errors.New("unsupported kind: complex128: unsupported type"),
+		},
+		{
+			name:  "NoInput",
+			input: &struct{ Foo complex128 }{},
+			err:   errors.New("unsupported kind: complex128: unsupported type"),
+		},
+		{
+			name: "Valid",
+			input: fork{
+				PreviousVersion: [4]byte{0x9f, 0x41, 0xbd, 0x5b},
+				CurrentVersion:  [4]byte{0xcb, 0xb0, 0xf1, 0xd7},
+				Epoch:           11971467576204192310,
+			},
+			output: [32]byte{0x3a, 0xd1, 0x26, 0x4c, 0x33, 0xbc, 0x66, 0xb4, 0x3a, 0x49, 0xb1, 0x25, 0x8b, 0x88, 0xf3, 0x4b, 0x8d, 0xbf, 0xa1, 0x64, 0x9f, 0x17, 0xe6, 0xdf, 0x55, 0x0f, 0x58, 0x96, 0x50, 0xd3, 0x49, 0x92},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := HashTreeRoot(test.input)
+			if test.err == nil {
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				if bytes.Compare(test.output[:], output[:]) != 0 {
+					t.Errorf("incorrect output: expected %v; received %v", test.output, output)
+				}
+			} else {
+				if err == nil {
+					t.Fatalf("missing expected error %v", test.err)
+				}
+				checkError(t, err, OpHash, reflect.TypeOf(test.input), test.err.Error())
+			}
+		})
+	}
+}
+
+func TestHashTreeRootWithCapacity(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       interface{}
+		maxCapacity uint64
+		output      [32]byte
+		err         error
+	}{
+		{
+			name: "Nil",
+			err:  errors.New("untyped nil is not supported"),
+		},
+		{
+			name:  "NotSlice",
+			input: "foo",
+			err:   errors.New("expected slice-kind input, received string"),
+		},
+		{
+			name:  "InvalidSlice1",
+			input: []complex128{complex(1, 1)},
+			err:   errors.New("unsupported kind: complex128: unsupported type"),
+		},
+		{
+			name:  "InvalidSlice2",
+			input: []struct{ Foo complex128 }{{Foo: complex(1, 1)}},
+			err:   errors.New("unsupported kind: complex128: unsupported type"),
+		},
+		{
+			name:   "NoInput",
+			input:  []uint32{},
+			output: [32]byte{0xf5, 0xa5, 0xfd, 0x42, 0xd1, 0x6a, 0x20, 0x30, 0x27, 0x98, 0xef, 0x6e, 0xd3, 0x09, 0x97, 0x9b, 0x43, 0x00, 0x3d, 0x23, 0x20, 0xd9, 0xf0, 0xe8, 0xea, 0x98, 0x31, 0xa9, 0x27, 0x59, 0xfb, 0x4b},
+		},
+		{
+			name: "Valid",
+			input: []fork{{
+				PreviousVersion: [4]byte{0x9f, 0x41, 0xbd, 0x5b},
+				CurrentVersion:  [4]byte{0xcb, 0xb0, 0xf1, 0xd7},
+				Epoch:           11971467576204192310,
+			}},
+			maxCapacity: 100,
+			output:      [32]byte{0x5c, 0xa4, 0xd8, 0xbf, 0x17, 0xb9, 0x53, 0x6d, 0x69, 0x56, 0xee, 0x48, 0xfa, 0x3d, 0xc6, 0x91, 0xe3, 0x52, 0x48, 0xbd, 0x09, 0xb2, 0x9b, 0x1b, 0x5b, 0xa4, 0x5a, 0x0e, 0xd5, 0xda, 0xe0, 0xd9},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := HashTreeRootWithCapacity(test.input, test.maxCapacity)
+			if test.err == nil {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if bytes.Compare(test.output[:], output[:]) != 0 {
+					t.Errorf("incorrect output: expected %v; received %v", test.output, output)
+				}
+			} else {
+				if err == nil {
+					t.Fatalf("missing expected error %v", test.err)
+				}
+				checkError(t, err, OpHash, reflect.TypeOf(test.input), test.err.Error())
+			}
+		})
+	}
+}
+
+func TestNilPointerHashTreeRoot(t *testing.T) {
+	type nilItem struct {
+		Field1 []*fork
+		Field2 uint64
+	}
+	i := &nilItem{
+		Field1: []*fork{nil},
+		Field2: 10,
+	}
+	if _, err := HashTreeRoot(i); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHashTreeRootWithCapacity_FailsWithNonSliceType(t *testing.T) {
+	forkItem := fork{
+		Epoch: 11971467576204192310,
+	}
+	capacity := uint64(100)
+	if _, err := HashTreeRootWithCapacity(forkItem, capacity); err == nil {
+		t.Error("Expected hash tree root to fail with non-slice type")
+	}
+}
+
+func TestHashTreeRootWithCapacity_HashesCorrectly(t *testing.T) {
+	capacity := uint64(1099511627776)
+	balances := make([]uint64, 512)
+	for i := 0; i < len(balances); i++ {
+		balances[i] = 32000000000
+	}
+	root, err := HashTreeRootWithCapacity(balances, capacity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Test case taken from validator balances of the state value in:
+	// https://github.com/ethereum/eth2.0-spec-tests/blob/v0.8.0/tests/sanity/slots/sanity_slots_mainnet.yaml.
+	want, err := hex.DecodeString("21a67313b0c6f988aac4fb6dd68686e1329243f7f6af21b722f6b83ca8fed9a8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(root[:], want) {
+		t.Errorf("Mismatched roots, wanted %#x == %#x", root, want)
+	}
+}
+
+// Regression test for https://github.com/prysmaticlabs/go-ssz/issues/46.
+func TestHashTreeRoot_EncodeSliceLengthCorrectly(t *testing.T) {
+	type accountBalances struct {
+		Balances []uint64 `ssz-max:"1099511627776"` // Large uint64 capacity.
+	}
+	acct := accountBalances{
+		Balances: make([]uint64, 512),
+	}
+	for i := 0; i < len(acct.Balances); i++ {
+		acct.Balances[i] = 32000000000
+	}
+	// Test case taken from validator balances of the state value in:
+	// https://github.com/ethereum/eth2.0-spec-tests/blob/v0.8.0/tests/sanity/slots/sanity_slots_mainnet.yaml.
+	AssertRootMatches(t, acct, "21a67313b0c6f988aac4fb6dd68686e1329243f7f6af21b722f6b83ca8fed9a8")
+}
+
+type limitItem struct {
+	Epoch uint64
+	Data  []byte `ssz-max:"4"`
+}
+
+// Lists are merkleized to as many chunks as their limits hold rather than as
+// their values hold, which the roots below were computed with following
+// merkleize and mix_in_length of the spec.
+func TestHashTreeRoot_ListsMerkleizedToLimit(t *testing.T) {
+	type uints struct {
+		Values []uint64 `ssz-max:"1024"`
+	}
+	type partialChunk struct {
+		Values []uint64 `ssz-max:"5"`
+	}
+	type uint16s struct {
+		Values []uint16 `ssz-max:"3"`
+	}
+	type registry struct {
+		Balances []uint64    `ssz-max:"1099511627776"`
+		Data     []byte      `ssz-max:"1099511627776"`
+		Items    []limitItem `ssz-max:"1099511627776"`
+		Bits     Bitlist     `ssz-max:"1099511627776"`
+		Roots    [][]byte    `ssz-size:"?,32" ssz-max:"1099511627776"`
+		Name     string      `ssz-max:"1099511627776"`
+	}
+	AssertRootMatches(t, uints{Values: []uint64{1, 2, 3}}, "7d71cb79deb3cc392afd800f19c07b5733b177b0bcd92f607052a1ffe314efb0")
+	AssertRootMatches(t, partialChunk{Values: []uint64{1, 2, 3, 4, 5}}, "40eb23170363bb91fc5146a327e122d3dc14cd61903036449bbef78752606e48")
+	AssertRootMatches(t, uint16s{Values: []uint16{1, 2}}, "cb214dc7037758a3ea0c1b1d8f2c8ca5d04508971a89c6b04b05a7bcd669cb84")
+	// Limits of 2^40 elements, such as that of the validator registry, are
+	// only hashable without materializing their padding.
+	fields := []string{
+		"f9112cc27170de4726eb26d4a4e8680b16a26e52540e5c831703eaddd5a7b23f",
+		"9e5c58d5919b3360e4509a14eb8cb8dbe36326521c00f716de7f99c21c721186",
+		"b4eaeb88d91f38062a8768e489bc471e5aeabb147a08bac178c7c7de51b5fd78",
+		"d70a234731285c6804c2a4f56711ddb8c82c99740f207854891028af34e27e5e",
+		"2a180d381c349433e67a7fca9fc150f9eaa9527812cb3f09d1a946e5304015ea",
+		"b173a3b3fd76fd499a019099ed7163b1455176df4737bbf2fd110e36b00b9ebf",
+	}
+	var chunks [][]byte
+	for _, field := range fields {
+		chunk, err := hex.DecodeString(field)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	// The container of six fields is merkleized to eight chunks.
+	for len(chunks) < 8 {
+		chunks = append(chunks, make([]byte, 32))
+	}
+	for len(chunks) > 1 {
+		var layer [][]byte
+		for i := 0; i < len(chunks); i += 2 {
+			root := sha256.Sum256(append(append([]byte{}, chunks[i]...), chunks[i+1]...))
+			layer = append(layer, root[:])
+		}
+		chunks = layer
+	}
+	AssertRootMatches(t, &registry{
+		Balances: []uint64{1, 2, 3},
+		Data:     []byte{1},
+		Items:    []limitItem{{}},
+		Bits:     Bitlist{0x01},
+		Roots:    [][]byte{make([]byte, 32)},
+	}, hex.EncodeToString(chunks[0]))
+}
+
+func TestHashTreeRoot_TopLevelBasicValues(t *testing.T) {
+	// chunk returns the given bytes right-padded with zero bytes to a chunk.
+	chunk := func(b ...byte) string {
+		var c [32]byte
+		copy(c[:], b)
+		return hex.EncodeToString(c[:])
+	}
+	var pubkey [48]byte
+	for i := range pubkey {
+		pubkey[i] = byte(i + 1)
+	}
+	var pubkeyTail [32]byte
+	copy(pubkeyTail[:], pubkey[32:])
+	pubkeyRoot := sha256.Sum256(append(pubkey[:32:32], pubkeyTail[:]...))
+	tests := []struct {
+		name string
+		val  interface{}
+		want string
+	}{
+		{name: "uint8", val: uint8(0xab), want: chunk(0xab)},
+		{name: "uint16", val: uint16(0x0102), want: chunk(0x02, 0x01)},
+		{name: "uint32", val: uint32(0x01020304), want: chunk(0x04, 0x03, 0x02, 0x01)},
+		{name: "uint64", val: uint64(0x0102030405060708), want: chunk(0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01)},
+		{name: "max uint64", val: ^uint64(0), want: chunk(0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)},
+		{name: "true", val: true, want: chunk(1)},
+		{name: "false", val: false, want: chunk()},
+		{name: "pointer to uint64", val: func() *uint64 { v := uint64(9); return &v }(), want: chunk(9)},
+		{name: "nil pointer to uint32", val: (*uint32)(nil), want: chunk()},
+		{name: "[20]byte", val: [20]byte{1, 2, 19: 20}, want: chunk(append([]byte{1, 2}, append(make([]byte, 17), 20)...)...)},
+		{name: "[32]byte", val: [32]byte{31: 0xff}, want: chunk(append(make([]byte, 31), 0xff)...)},
+		{name: "[48]byte", val: pubkey, want: hex.EncodeToString(pubkeyRoot[:])},
+		{name: "pointer to [32]byte", val: &[32]byte{7}, want: chunk(7)},
+		{name: "bitvector of 4 bits", val: Bitvector{0x05}, want: chunk(0x05)},
+		{name: "pointer to bitvector", val: &Bitvector{0x01, 0x80}, want: chunk(0x01, 0x80)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			AssertRootMatches(t, tt.val, tt.want)
+		})
+	}
+}
+
+func TestHashTreeRoot_TopLevelBitvectorMatchesField(t *testing.T) {
+	type justification struct {
+		Bits Bitvector `ssz-size:"300"`
+	}
+	bits := NewBitvector(300)
+	bits.SetBitAt(0, true)
+	bits.SetBitAt(299, true)
+	want, err := HashTreeRoot(&justification{Bits: bits})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := HashTreeRoot(bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != want {
+		t.Errorf("Expected the root of the bitvector field, %#x, received %#x", want, root)
+	}
+	if _, err := HashTreeRoot(Bitvector{}); err == nil {
+		t.Error("Expected a bitvector without bits to be rejected")
+	}
+}
+
+func TestHashTreeRoot_PointersToLists(t *testing.T) {
+	for _, val := range []interface{}{
+		[]uint64{1, 2, 3},
+		[][32]byte{{1}, {2}},
+		[]limitItem{{}},
+	} {
+		want, err := HashTreeRoot(val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ptr := reflect.New(reflect.TypeOf(val))
+		ptr.Elem().Set(reflect.ValueOf(val))
+		root, err := HashTreeRoot(ptr.Interface())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root != want {
+			t.Errorf("Expected a pointer to %T to have the root of the list, %#x, received %#x", val, want, root)
+		}
+		empty, err := HashTreeRoot(reflect.MakeSlice(reflect.TypeOf(val), 0, 0).Interface())
+		if err != nil {
+			t.Fatal(err)
+		}
+		nilRoot, err := HashTreeRoot(reflect.Zero(ptr.Type()).Interface())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nilRoot != empty {
+			t.Errorf("Expected a nil pointer to %T to have the root of an empty list, %#x, received %#x", val, empty, nilRoot)
+		}
+	}
+}
+
+func TestHashTreeRoot_ConcurrentAccess(t *testing.T) {
+	item := &truncateSignatureCase{
+		Slot:              10,
+		PreviousBlockRoot: []byte{'a', 'b'},
+		Signature:         []byte("TESTING23"),
+	}
+	var wg sync.WaitGroup
+	// We ensure the hash tree root function can be computed in a thread-safe manner.
+	// No panic from this test is a successful run.
+	wg.Add(100)
+	for i := 0; i < 100; i++ {
+		go func(tt *testing.T, w *sync.WaitGroup) {
+			defer w.Done()
+			if _, err := HashTreeRoot(item); err != nil {
+				tt.Error(err)
+			}
+		}(t, &wg)
+	}
+	wg.Wait()
+}
+
+type truncatedBlock struct {
+	Slot              uint64
+	PreviousBlockRoot []byte
+}
+
+func TestHashTreeRootTruncated(t *testing.T) {
+	item := &truncateSignatureCase{
+		Slot:              10,
+		PreviousBlockRoot: []byte{'a', 'b'},
+		Signature:         []byte("TESTING23"),
+	}
+	expected, err := HashTreeRoot(&truncatedBlock{Slot: 10, PreviousBlockRoot: []byte{'a', 'b'}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := HashTreeRootTruncated(item, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != expected {
+		t.Errorf("Expected the root of the container without its signature, %#x, received %#x", expected, root)
+	}
+	signingRoot, err := SigningRoot(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signingRoot != expected {
+		t.Errorf("Expected the signing root %#x, received %#x", expected, signingRoot)
+	}
+
+	slotRoot, err := HashTreeRootTruncated(item, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slotRoot != [32]byte{10} {
+		t.Errorf("Expected the root of the slot alone, %#x, received %#x", [32]byte{10}, slotRoot)
+	}
+	fullRoot, err := HashTreeRootTruncated(item, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	itemRoot, err := HashTreeRoot(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fullRoot != itemRoot {
+		t.Errorf("Expected dropping no field to compute the hash tree root %#x, received %#x", itemRoot, fullRoot)
+	}
+	nilRoot, err := SigningRoot((*truncateSignatureCase)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zeroRoot, err := HashTreeRoot(&truncatedBlock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nilRoot != zeroRoot {
+		t.Errorf("Expected the signing root of a nil container to be that of its zero value, %#x, received %#x", zeroRoot, nilRoot)
+	}
+}
+
+func TestHashTreeRootTruncated_Errors(t *testing.T) {
+	item := &truncateSignatureCase{}
+	for _, dropLast := range []int{-1, 3, 4} {
+		if _, err := HashTreeRootTruncated(item, dropLast); err == nil || !strings.Contains(err.Error(), "cannot drop") {
+			t.Errorf("Expected dropping %d fields to fail, received %v", dropLast, err)
+		}
+	}
+	if _, err := SigningRoot(&struct{}{}); err == nil {
+		t.Error("Expected the signing root of a container without fields to fail")
+	}
+	if _, err := SigningRoot([]uint64{1}); err == nil || !strings.Contains(err.Error(), "not a container") {
+		t.Errorf("Expected the signing root of a list to fail, received %v", err)
+	}
+	if _, err := SigningRoot(nil); err == nil {
+		t.Error("Expected the signing root of untyped nil to fail")
+	}
+}
+
+type limitsCheckpoint struct {
+	Epoch uint64
+	Root  [32]byte
+}
+
+type limitsAttestation struct {
+	AggregationBits []byte
+	Target          limitsCheckpoint
+}
+
+type limitsBody struct {
+	Graffiti     [32]byte
+	Attestations []*limitsAttestation
+	Deposits     [][]byte `ssz-max:"16"`
+}
+
+type limitsBlock struct {
+	Slot     uint64
+	Body     *limitsBody
+	Balances []uint64
+	Comment  string
+}
+
+type taggedLimitsAttestation struct {
+	AggregationBits []byte `ssz-max:"2048"`
+	Target          limitsCheckpoint
+}
+
+type taggedLimitsBody struct {
+	Graffiti     [32]byte
+	Attestations []*taggedLimitsAttestation `ssz-max:"128"`
+	Deposits     [][]byte                   `ssz-max:"16"`
+}
+
+type taggedLimitsBlock struct {
+	Slot     uint64
+	Body     *taggedLimitsBody
+	Balances []uint64 `ssz-max:"1099511627776"`
+	Comment  string   `ssz-max:"64"`
+}
+
+var blockLimits = map[string]uint64{
+	"Body.Attestations":                 128,
+	"Body.Attestations.AggregationBits": 2048,
+	"Balances":                          1099511627776,
+	"Comment":                           64,
+}
+
+func TestHashTreeRootWithLimits_MatchesTaggedCopy(t *testing.T) {
+	block := &limitsBlock{
+		Slot: 5,
+		Body: &limitsBody{
+			Graffiti: [32]byte{'g'},
+			Attestations: []*limitsAttestation{
+				{AggregationBits: []byte{1, 2, 3}, Target: limitsCheckpoint{Epoch: 1}},
+				{AggregationBits: []byte{4}, Target: limitsCheckpoint{Epoch: 2, Root: [32]byte{9}}},
+			},
+			Deposits: [][]byte{{1}, {2, 3}},
+		},
+		Balances: []uint64{32000000000, 31000000000},
+		Comment:  "hello",
+	}
+	tagged := &taggedLimitsBlock{
+		Slot: block.Slot,
+		Body: &taggedLimitsBody{
+			Graffiti: block.Body.Graffiti,
+			Deposits: block.Body.Deposits,
+		},
+		Balances: block.Balances,
+		Comment:  block.Comment,
+	}
+	for _, att := range block.Body.Attestations {
+		tagged.Body.Attestations = append(tagged.Body.Attestations, &taggedLimitsAttestation{
+			AggregationBits: att.AggregationBits,
+			Target:          att.Target,
+		})
+	}
+	want, err := HashTreeRoot(tagged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := HashTreeRootWithLimits(block, blockLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("Expected root %#x, received %#x", want, got)
+	}
+	otherLimits := map[string]uint64{"Balances": 1024}
+	for path, limit := range blockLimits {
+		if _, ok := otherLimits[path]; !ok {
+			otherLimits[path] = limit
+		}
+	}
+	other, err := HashTreeRootWithLimits(block, otherLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == want {
+		t.Error("Expected hashing with other limits to produce a different root")
+	}
+}
+
+func TestHashTreeRootWithLimits_OverridesTags(t *testing.T) {
+	type tagged struct {
+		Balances []uint64 `ssz-max:"8"`
+	}
+	type retagged struct {
+		Balances []uint64 `ssz-max:"1024"`
+	}
+	want, err := HashTreeRoot(retagged{Balances: []uint64{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := HashTreeRootWithLimits(tagged{Balances: []uint64{1, 2}}, map[string]uint64{"Balances": 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("Expected root %#x, received %#x", want, got)
+	}
+}
+
+func TestHashTreeRootWithLimits_ListsMissingLimits(t *testing.T) {
+	_, err := HashTreeRootWithLimits(&limitsBlock{}, map[string]uint64{"Balances": 1099511627776})
+	if err == nil {
+		t.Fatal("Expected missing limits to fail hashing")
+	}
+	want := "no limit given for lists at: Body.Attestations, Body.Attestations.AggregationBits, Comment"
+	checkError(t, err, OpHash, reflect.TypeOf(&limitsBlock{}), want)
+	if _, err := HashTreeRootWithLimits([]uint64{1}, nil); err == nil {
+		t.Error("Expected a non-container input to fail hashing")
+	}
+}
+
+type boundedString struct {
+	Slot uint64
+	Name string `ssz-max:"4"`
+}
+
+type unboundedString struct {
+	Slot uint64
+	Name string
+}
+
+func TestString_AtLimit(t *testing.T) {
+	item := boundedString{Slot: 1, Name: "abcd"}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec boundedString
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if dec != item {
+		t.Errorf("Expected %v, received %v", item, dec)
+	}
+	if _, err := HashTreeRoot(item); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestString_OverLimit(t *testing.T) {
+	item := boundedString{Slot: 1, Name: "abcde"}
+	want := "string field Name has length 5, exceeding its ssz-max of 4"
+	if _, err := Marshal(item); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected marshal error %q, received %v", want, err)
+	}
+	// An encoding of the same shape without the tag carries the oversized string.
+	enc, err := Marshal(unboundedString{Slot: 1, Name: "abcde"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec boundedString
+	if err := Unmarshal(enc, &dec); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected unmarshal error %q, received %v", want, err)
+	}
+	if _, err := HashTreeRoot(item); err == nil {
+		t.Error("Expected hashing an oversized string to fail")
+	}
+}
+
+func TestString_Unlimited(t *testing.T) {
+	item := unboundedString{Slot: 1, Name: "abcde"}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec unboundedString
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if dec != item {
+		t.Errorf("Expected %v, received %v", item, dec)
+	}
+	if _, err := HashTreeRoot(item); err == nil {
+		t.Error("Expected hashing a string without a limit to fail")
+	}
+	got, err := HashTreeRootWithLimits(item, map[string]uint64{"Name": 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type taggedString struct {
+		Slot uint64
+		Name string `ssz-max:"8"`
+	}
+	want, err := HashTreeRoot(taggedString{Slot: 1, Name: "abcde"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("Expected root %#x, received %#x", want, got)
+	}
+}
+
+func TestMarshal_RootsArrayRowShapes(t *testing.T) {
+	rows := [][]byte{make([]byte, 32), make([]byte, 32), nil}
+	rows[0][0], rows[1][0] = 1, 4
+	var arr [3][32]byte
+	for i := 0; i < len(rows); i++ {
+		copy(arr[i][:], rows[i])
+	}
+	type taggedRoots struct {
+		Roots [][]byte `ssz-size:"3,32"`
+	}
+	fromSlices, err := Marshal(taggedRoots{Roots: rows})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromArrays, err := Marshal(arr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fromSlices, fromArrays) {
+		t.Errorf("Expected identical encodings, received %v and %v", fromSlices, fromArrays)
+	}
+	if len(fromArrays) != 3*32 || fromArrays[0] != 1 || fromArrays[32] != 4 {
+		t.Errorf("Unexpected encoding of roots array: %v", fromArrays)
+	}
+}
+
+func TestMarshal_RootsArrayRejectsWrongLengthRow(t *testing.T) {
+	type taggedRoots struct {
+		Roots [][]byte `ssz-size:"2,32"`
+	}
+	item := taggedRoots{Roots: [][]byte{make([]byte, 32), {7, 8}}}
+	if _, err := Marshal(item); err == nil {
+		t.Error("Expected a root of length 2 to fail marshaling")
+	}
+	item = taggedRoots{Roots: [][]byte{make([]byte, 32), make([]byte, 33)}}
+	if _, err := Marshal(item); err == nil {
+		t.Error("Expected a root of length 33 to fail marshaling")
+	}
+}
+
+func TestUnmarshal_TruncatedBasicValues(t *testing.T) {
+	type basics struct {
+		A bool
+		B uint8
+		C uint16
+		D uint32
+		E uint64
+		F int32
+	}
+	item := &basics{A: true, B: 1, C: 2, D: 3, E: 4, F: 5}
+	values := []interface{}{
+		item,
+		&[2]basics{*item, *item},
+		new(bool),
+		new(uint8),
+		new(uint16),
+		new(uint32),
+		new(uint64),
+		new(int32),
+		&[3]uint16{},
+		&[2]uint64{},
+	}
+	for _, val := range values {
+		enc, err := Marshal(val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Every length short of the encoding is rejected with an error rather
+		// than a panic.
+		for n := 0; n < len(enc); n++ {
+			dec := reflect.New(reflect.TypeOf(val).Elem()).Interface()
+			if err := Unmarshal(enc[:n], dec); err == nil {
+				t.Errorf("Expected %T truncated to %d of %d bytes to be rejected", val, n, len(enc))
+			}
+		}
+	}
+	var u uint64
+	err := Unmarshal([]byte{1, 2, 3}, &u)
+	if err == nil || !strings.Contains(err.Error(), "input for type uint64 is truncated: expected 8 bytes, received 3") {
+		t.Errorf("Expected an error naming the type and its size, received %v", err)
+	}
+}
+
+func TestUnmarshal_MalformedCompositeListOffsets(t *testing.T) {
+	type container struct {
+		Slot  uint64
+		Lists [][]byte `ssz-max:"4"`
+	}
+	// The encoding of [][]byte{{1, 2}, {3}}: an offset table of 8 bytes
+	// followed by the two lists.
+	valid := []byte{8, 0, 0, 0, 10, 0, 0, 0, 1, 2, 3}
+	withOffsets := func(first, second []byte) []byte {
+		enc := append([]byte{}, valid...)
+		copy(enc[0:4], first)
+		copy(enc[4:8], second)
+		return enc
+	}
+	tests := []struct {
+		name  string
+		input []byte
+		want  string
+	}{
+		{name: "first offset beyond input", input: withOffsets([]byte{0xfc, 0xff, 0xff, 0xff}, valid[4:8]), want: "out of bounds"},
+		{name: "first offset with every bit set", input: withOffsets([]byte{0xff, 0xff, 0xff, 0xff}, valid[4:8]), want: "not a non-zero multiple of 4"},
+		{name: "first offset zero", input: withOffsets([]byte{0, 0, 0, 0}, valid[4:8]), want: "not a non-zero multiple of 4"},
+		{name: "first offset not a multiple of 4", input: withOffsets([]byte{6, 0, 0, 0}, valid[4:8]), want: "not a non-zero multiple of 4"},
+		{name: "offset inside the table", input: withOffsets(valid[0:4], []byte{4, 0, 0, 0}), want: "inside its offset table"},
+		{name: "offset beyond input", input: withOffsets(valid[0:4], []byte{0xff, 0xff, 0xff, 0xff}), want: "out of bounds"},
+		{name: "truncated offset table", input: []byte{8, 0, 0, 0, 10, 0}, want: "out of bounds"},
+	}
+	for _, tt := range tests {
+		var lists [][]byte
+		if err := Unmarshal(tt.input, &lists); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, received %v", tt.name, tt.want, err)
+		}
+		// The same list decoded as the last field of a container.
+		input := append(make([]byte, 12), tt.input...)
+		input[8] = 12
+		if err := Unmarshal(input, &container{}); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected decoding the container to fail with %q, received %v", tt.name, tt.want, err)
+		}
+	}
+	var lists [][]byte
+	if err := Unmarshal(valid, &lists); err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]byte{{1, 2}, {3}}; !reflect.DeepEqual(lists, want) {
+		t.Errorf("Expected %v, received %v", want, lists)
+	}
+}
+
+func TestUnmarshal_ReorderedCompositeListOffsets(t *testing.T) {
+	lists := [][]byte{{1}, {2, 3}, {4, 5, 6}}
+	enc, err := Marshal(lists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Swapping the offsets of the last two lists would otherwise decode the
+	// first one alone.
+	reordered := append([]byte{}, enc...)
+	copy(reordered[4:12], append(append([]byte{}, enc[8:12]...), enc[4:8]...))
+	var decoded [][]byte
+	err = Unmarshal(reordered, &decoded)
+	want := "offset 13 of element 2 of type [][]uint8 is out of order, expected at least the offset 15 of element 1"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected an error containing %q, received %v", want, err)
+	}
+
+	// Empty lists share their offset with the next list.
+	withEmpty := [][]byte{{}, {1}, {}, {}}
+	enc, err = Marshal(withEmpty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded = nil
+	if err := Unmarshal(enc, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(withEmpty) || !bytes.Equal(decoded[1], withEmpty[1]) {
+		t.Errorf("Expected %v, received %v", withEmpty, decoded)
+	}
+}
+
+func TestUnmarshal_CompositeVectorElementCount(t *testing.T) {
+	type attestationData struct {
+		Slot uint64
+		Bits []byte `ssz-max:"8"`
+	}
+	one, err := Marshal([1]*attestationData{{Slot: 1, Bits: []byte{1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	three, err := Marshal([3]*attestationData{{Slot: 1}, {Slot: 2}, {Slot: 3, Bits: []byte{3}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		input []byte
+		want  string
+	}{
+		{name: "too few elements", input: one, want: "holds an offset table of 4 bytes, expected 8 bytes for its 2 elements"},
+		{name: "too many elements", input: three, want: "holds an offset table of 12 bytes, expected 8 bytes for its 2 elements"},
+	}
+	for _, tt := range tests {
+		var vector [2]*attestationData
+		if err := Unmarshal(tt.input, &vector); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, received %v", tt.name, tt.want, err)
+		}
+		// The same vector as a tagged field of a container.
+		var tagged struct {
+			Items []*attestationData `ssz-size:"2"`
+		}
+		input := append([]byte{4, 0, 0, 0}, tt.input...)
+		if err := Unmarshal(input, &tagged); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected decoding the container to fail with %q, received %v", tt.name, tt.want, err)
+		}
+	}
+
+	two := [2]*attestationData{{Slot: 1, Bits: []byte{1, 2}}, {Slot: 2}}
+	enc, err := Marshal(two)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded [2]*attestationData
+	if err := Unmarshal(enc, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded[0].Slot != 1 || !bytes.Equal(decoded[0].Bits, two[0].Bits) || decoded[1].Slot != 2 {
+		t.Errorf("Expected %+v, received %+v", two, decoded)
+	}
+}
+
+func TestUnmarshal_PartialListElements(t *testing.T) {
+	type container struct {
+		Slot   uint64
+		Values []uint16 `ssz-max:"8"`
+	}
+	// The list holds one element and a half.
+	input := []byte{1, 0, 0, 0, 0, 0, 0, 0, 12, 0, 0, 0, 1, 0, 2}
+	want := "which is not a multiple of the 2 bytes of its elements"
+	if err := Unmarshal(input, &container{}); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected an error containing %q, received %v", want, err)
+	}
+	var values []uint16
+	if err := Unmarshal(input[12:], &values); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected an error containing %q, received %v", want, err)
+	}
+}
+
+func TestUnmarshal_TrailingBytes(t *testing.T) {
+	type withTail struct {
+		Checkpoints [2]fork
+		Indices     []uint64 `ssz-max:"8"`
+	}
+	tests := []struct {
+		name     string
+		val      interface{}
+		garbage  []byte
+		want     string
+		trailing bool
+	}{
+		{name: "fixed-size container", val: &fork{Epoch: 3}, garbage: []byte{0xff, 0xff}, want: "leaving 2 trailing bytes", trailing: true},
+		{name: "fixed-size vector", val: &[3]uint64{1, 2, 3}, garbage: []byte{0}, want: "leaving 1 trailing bytes", trailing: true},
+		{name: "nested fixed-size containers", val: &[2]fork{{Epoch: 1}, {Epoch: 2}}, garbage: make([]byte, 16), want: "leaving 16 trailing bytes", trailing: true},
+		// The last variable-size field of offset-based containers extends to
+		// the end of the input, so the garbage is decoded as part of it.
+		{name: "offset-based container", val: &fixedVarVarFixed{Slot: 1, Data: []byte{1}, Indices: []uint64{2}, Epoch: 3}, garbage: []byte{0xff, 0xff, 0xff}, want: "not a multiple of the 8 bytes"},
+		{name: "offset-based container after fixed-size containers", val: &withTail{Indices: []uint64{1, 2}}, garbage: []byte{0xff}, want: "not a multiple of the 8 bytes"},
+	}
+	for _, tt := range tests {
+		enc, err := Marshal(tt.val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		input := append(enc, tt.garbage...)
+		typ := reflect.TypeOf(tt.val).Elem()
+		decoded := reflect.New(typ).Interface()
+		err = Unmarshal(input, decoded)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, received %v", tt.name, tt.want, err)
+			continue
+		}
+		if tt.trailing {
+			if offset := err.(*Error).Offset; offset != int64(len(enc)) {
+				t.Errorf("%s: expected the trailing bytes to be reported at byte %d, received %d", tt.name, len(enc), offset)
+			}
+		}
+		dec, err := NewTypedDecoder(tt.val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dec.Decode(input, reflect.New(typ).Interface()); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected the typed decoder to fail with %q, received %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestStringFields_RoundTrip(t *testing.T) {
+	type label string
+	type profile struct {
+		Name    string `ssz-max:"32"`
+		Slot    uint64
+		Tags    []byte `ssz-max:"8"`
+		Comment label  `ssz-max:"64"`
+		Epoch   uint32
+	}
+	type profiles struct {
+		Owner string    `ssz-max:"16"`
+		Items []profile `ssz-max:"4"`
+		Count uint16
+	}
+	values := []interface{}{
+		&profile{Name: "alice", Slot: 7, Tags: []byte{1, 2}, Comment: "first", Epoch: 9},
+		&profile{Slot: 1, Tags: []byte{}, Comment: "only a comment"},
+		&profile{Name: "trailing empty", Tags: []byte{}, Epoch: 3},
+		&profiles{
+			Owner: "registry",
+			Items: []profile{{Name: "a", Slot: 1, Tags: []byte{}}, {Comment: "b", Tags: []byte{3}}, {Tags: []byte{}}},
+			Count: 3,
+		},
+	}
+	for _, val := range values {
+		enc, err := Marshal(val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := reflect.New(reflect.TypeOf(val).Elem()).Interface()
+		if err := Unmarshal(enc, decoded); err != nil {
+			t.Fatalf("Could not unmarshal %+v: %v", val, err)
+		}
+		if !reflect.DeepEqual(decoded, val) {
+			t.Errorf("Expected %+v, received %+v", val, decoded)
+		}
+	}
+}
+
+func TestUnmarshal_TaggedSliceOfArrays(t *testing.T) {
+	type taggedRoots struct {
+		Roots [][32]byte `ssz-size:"2,32"`
+		Votes [2][]byte  `ssz-size:"2,4"`
+	}
+	item := taggedRoots{
+		Roots: [][32]byte{{1}, {2}},
+		Votes: [2][]byte{{1, 2, 3, 4}, {5, 6, 7, 8}},
+	}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec taggedRoots
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(item, dec) {
+		t.Errorf("Expected %v, received %v", item, dec)
+	}
+}
+
+func TestUnmarshal_TaggedFieldShapeMismatch(t *testing.T) {
+	type mismatched struct {
+		Roots [2][32]byte `ssz-size:"?,32"`
+	}
+	if _, err := Marshal(mismatched{}); err == nil {
+		t.Error("Expected marshaling a mismatched field to fail")
+	}
+	var dec mismatched
+	err := Unmarshal(make([]byte, 64), &dec)
+	if err == nil || !strings.Contains(err.Error(), "field Roots of type [2][32]uint8") {
+		t.Errorf("Expected error naming the mismatched field, received %v", err)
+	}
+}
+
+func TestSizeTags_PointerElements(t *testing.T) {
+	type header struct {
+		Slot uint64
+		Root [32]byte
+	}
+	type block struct {
+		Slot uint64
+		Data []byte `ssz-max:"8"`
+	}
+	type taggedPointers struct {
+		Blocks  []*block    `ssz-size:"4"`
+		Headers [][]*header `ssz-size:"2,2"`
+		Roots   []*[32]byte `ssz-size:"4,32"`
+		Votes   []*[]byte   `ssz-size:"2,4"`
+	}
+	type arrayPointers struct {
+		Blocks  [4]*block
+		Headers [2][2]*header
+		Roots   [4]*[32]byte
+		Votes   [2]*[4]byte
+	}
+	item := taggedPointers{
+		Blocks: []*block{{Slot: 1, Data: []byte{1, 2}}, nil, {Slot: 3, Data: []byte{}}, {Slot: 4, Data: []byte{4}}},
+		Headers: [][]*header{
+			{{Slot: 1, Root: [32]byte{1}}, {Slot: 2}},
+			{nil, {Slot: 4, Root: [32]byte{4}}},
+		},
+		Roots: []*[32]byte{{1}, {2}, nil, {4}},
+		Votes: []*[]byte{{1, 2, 3, 4}, {5, 6, 7, 8}},
+	}
+	arrays := arrayPointers{
+		Blocks:  [4]*block{item.Blocks[0], nil, item.Blocks[2], item.Blocks[3]},
+		Headers: [2][2]*header{{item.Headers[0][0], item.Headers[0][1]}, {nil, item.Headers[1][1]}},
+		Roots:   [4]*[32]byte{item.Roots[0], item.Roots[1], nil, item.Roots[3]},
+		Votes:   [2]*[4]byte{{1, 2, 3, 4}, {5, 6, 7, 8}},
+	}
+	want, err := Marshal(arrays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, want) {
+		t.Errorf("Expected encoding %#x, received %#x", want, enc)
+	}
+	wantRoot, err := HashTreeRoot(arrays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := HashTreeRoot(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != wantRoot {
+		t.Errorf("Expected root %#x, received %#x", wantRoot, root)
+	}
+
+	var dec taggedPointers
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	// Nil elements are encoded as zero values, which is what they decode into.
+	item.Blocks[1] = &block{Data: []byte{}}
+	item.Headers[1][0] = &header{}
+	item.Roots[2] = &[32]byte{}
+	if !reflect.DeepEqual(item, dec) {
+		t.Errorf("Expected %v, received %v", item, dec)
+	}
+}
+
+func TestMarshalAt(t *testing.T) {
+	type block struct {
+		Slot   uint64
+		Parent *fork
+		Data   []byte `ssz-max:"16"`
+	}
+	item := &block{Slot: 3, Data: []byte{1, 2, 3}}
+	want, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := uint64(len(want))
+
+	// The buffer is filled with garbage, as a reused mapping would be.
+	buf := bytes.Repeat([]byte{0xaa}, len(want)+2)
+	n, err := MarshalAt(buf[:size], item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != size || !bytes.Equal(buf[:n], want) {
+		t.Errorf("Expected %d bytes %#x, received %d bytes %#x", size, want, n, buf[:n])
+	}
+	if !bytes.Equal(buf[size:], []byte{0xaa, 0xaa}) {
+		t.Errorf("Expected the bytes past the encoding to be left untouched, received %#x", buf[size:])
+	}
+	if n, err := MarshalAt(buf, item); err != nil || n != size || !bytes.Equal(buf[:n], want) {
+		t.Errorf("Expected %d bytes %#x into a larger buffer, received %d bytes %#x (%v)", size, want, n, buf[:n], err)
+	}
+
+	short := bytes.Repeat([]byte{0xaa}, len(want)-1)
+	_, err = MarshalAt(short, item)
+	var tooSmall *ErrBufferTooSmall
+	if !errors.As(err, &tooSmall) {
+		t.Fatalf("Expected an *ErrBufferTooSmall, received %v", err)
+	}
+	if tooSmall.Needed != size || tooSmall.Got != size-1 {
+		t.Errorf("Expected to need %d bytes and get %d, received %+v", size, size-1, tooSmall)
+	}
+	if !bytes.Equal(short, bytes.Repeat([]byte{0xaa}, len(short))) {
+		t.Errorf("Expected a buffer too small to be left untouched, received %#x", short)
+	}
+
+	if _, err := MarshalAt(buf, nil); err == nil {
+		t.Error("Expected marshaling nil to fail")
+	}
+}
+
+func TestRootOfRootList_MatchesHashTreeRoot(t *testing.T) {
+	type commitments struct {
+		Roots [][32]byte `ssz-max:"16"`
+	}
+	for _, n := range []int{0, 1, 3, 16} {
+		roots := make([][32]byte, n)
+		flat := make([]byte, 0, 32*n)
+		for i := range roots {
+			roots[i][0], roots[i][31] = byte(i+1), byte(n)
+			flat = append(flat, roots[i][:]...)
+		}
+		// The root of a list field is the only field root of its container.
+		want, err := HashTreeRootWithCapacity(roots, 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fieldRoots, err := types.InspectContainerRoot(reflect.ValueOf(commitments{Roots: roots}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fieldRoots.FieldRoots[0] != want {
+			t.Fatalf("Expected the list field root %#x to be %#x", fieldRoots.FieldRoots[0], want)
+		}
+		root, err := RootOfRootList(flat, 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root != want {
+			t.Errorf("Expected root %#x for %d roots, received %#x", want, n, root)
+		}
+	}
+}
+
+func TestRootOfRootVector_MatchesHashTreeRoot(t *testing.T) {
+	var roots [8][32]byte
+	flat := make([]byte, 0, len(roots)*32)
+	for i := range roots {
+		roots[i][0] = byte(i + 1)
+		flat = append(flat, roots[i][:]...)
+	}
+	want, err := HashTreeRoot(roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := RootOfRootVector(flat, uint64(len(roots)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != want {
+		t.Errorf("Expected root %#x, received %#x", want, root)
+	}
+	single, err := HashTreeRoot([1][32]byte{roots[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root, err := RootOfRootVector(flat[:32], 1); err != nil || root != single {
+		t.Errorf("Expected root %#x for a single root, received %#x (%v)", single, root, err)
+	}
+}
+
+func TestRootOfRoots_RejectsInvalidInput(t *testing.T) {
+	flat := make([]byte, 3*32)
+	if _, err := RootOfRootList(flat[:33], 4); err == nil {
+		t.Error("Expected roots which are not a multiple of 32 bytes to be rejected")
+	}
+	if _, err := RootOfRootList(flat, 2); err == nil {
+		t.Error("Expected a list over its limit to be rejected")
+	}
+	if _, err := RootOfRootVector(flat, 4); err == nil {
+		t.Error("Expected a vector of the wrong length to be rejected")
+	}
+	if _, err := RootOfRootVector(flat[:31], 1); err == nil {
+		t.Error("Expected roots which are not a multiple of 32 bytes to be rejected")
+	}
+}
+
+func TestSizeSSZ_MatchesMarshal(t *testing.T) {
+	type tagged struct {
+		Slot       uint64
+		BlockRoots [][]byte `ssz-size:"65536,32"`
+		Votes      [][]byte `ssz-size:"?,4" ssz-max:"16"`
+		Name       string   `ssz-max:"32"`
+	}
+	type nested struct {
+		Fork   *fork
+		Items  []*tagged `ssz-max:"4"`
+		Flags  [3]bool
+		Digest [4]byte
+	}
+	tests := []interface{}{
+		fork{Epoch: 3},
+		&fork{},
+		(*fork)(nil),
+		[]uint64{1, 2, 3},
+		tagged{Slot: 1},
+		&tagged{Votes: [][]byte{{1, 2, 3, 4}}, Name: "node"},
+		nested{Items: []*tagged{{Slot: 2}, nil}},
+		(*nested)(nil),
+		&generatedCheckpoint{Epoch: 5},
+	}
+	for _, tt := range tests {
+		enc, err := Marshal(tt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, err := SizeSSZ(tt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != uint64(len(enc)) {
+			t.Errorf("Expected size %d for %T, received %d", len(enc), tt, size)
+		}
+	}
+}
+
+func TestSizeSSZ_RejectsUnsupportedKinds(t *testing.T) {
+	type withComplex struct {
+		Slot  uint64
+		Value complex128
+	}
+	type withChannels struct {
+		Slot    uint64
+		Updates []chan uint64 `ssz-max:"4"`
+	}
+	tests := []interface{}{
+		nil,
+		complex64(1),
+		make(chan uint64),
+		func() {},
+		withComplex{},
+		&withChannels{},
+	}
+	for _, tt := range tests {
+		if size, err := SizeSSZ(tt); err == nil {
+			t.Errorf("Expected sizing %T to fail, received %d", tt, size)
+		}
+	}
+}
+
+func TestMarshalTo(t *testing.T) {
+	type block struct {
+		Slot   uint64
+		Parent *fork
+		Data   []byte `ssz-max:"16"`
+	}
+	item := &block{Slot: 3, Data: []byte{1, 2, 3}}
+	want, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := []byte{0xde, 0xad}
+
+	// A buffer with enough spare capacity, filled with garbage as a pooled one
+	// would be, is appended to in place.
+	buf := bytes.Repeat([]byte{0xaa}, 64)
+	copy(buf, prefix)
+	enc, err := MarshalTo(buf[:len(prefix)], item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, append(append([]byte{}, prefix...), want...)) {
+		t.Errorf("Expected %#x after the prefix, received %#x", want, enc)
+	}
+	if &enc[0] != &buf[0] {
+		t.Error("Expected the encoding to be appended in place")
+	}
+
+	// A buffer lacking capacity is grown, leaving the original untouched.
+	short := make([]byte, len(prefix), len(prefix)+1)
+	copy(short, prefix)
+	enc, err = MarshalTo(short, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, append(append([]byte{}, prefix...), want...)) {
+		t.Errorf("Expected %#x after the prefix, received %#x", want, enc)
+	}
+	if enc, err := MarshalTo(nil, item); err != nil || !bytes.Equal(enc, want) {
+		t.Errorf("Expected %#x, received %#x (%v)", want, enc, err)
+	}
+
+	// Nil pointers and generated encoders are marshaled as by Marshal.
+	var nilBlock *block
+	wantNil, err := Marshal(nilBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enc, err := MarshalTo(nil, nilBlock); err != nil || !bytes.Equal(enc, wantNil) {
+		t.Errorf("Expected %#x for a nil pointer, received %#x (%v)", wantNil, enc, err)
+	}
+	checkpoint := &generatedCheckpoint{Epoch: 5}
+	wantGenerated, err := Marshal(checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enc, err := MarshalTo(prefix[:len(prefix):len(prefix)], checkpoint); err != nil || !bytes.Equal(enc[len(prefix):], wantGenerated) {
+		t.Errorf("Expected %#x from the generated encoder, received %#x (%v)", wantGenerated, enc, err)
+	}
+
+	if enc, err := MarshalTo(prefix, nil); err == nil || !bytes.Equal(enc, prefix) {
+		t.Errorf("Expected marshaling nil to fail and return dst, received %#x (%v)", enc, err)
+	}
+}
+
+func TestUintsTag_DoesNotChangeEncoding(t *testing.T) {
+	type untagged struct {
+		Levels []uint8  `ssz-max:"16"`
+		Grid   [][]byte `ssz-size:"2,3"`
+		Root   [32]byte
+	}
+	type tagged struct {
+		Levels []uint8  `ssz:"uints" ssz-max:"16"`
+		Grid   [][]byte `ssz:"uints" ssz-size:"2,3"`
+		Root   [32]byte `ssz:"bytes"`
+	}
+	plain := untagged{Levels: []uint8{1, 2, 3}, Grid: [][]byte{{1, 2, 3}, {4, 5, 6}}, Root: [32]byte{7}}
+	item := tagged(plain)
+	want, err := Marshal(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, want) {
+		t.Errorf("Expected encoding %#x, received %#x", want, enc)
+	}
+	wantRoot, err := HashTreeRoot(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := HashTreeRoot(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != wantRoot {
+		t.Errorf("Expected root %#x, received %#x", wantRoot, root)
+	}
+	var dec tagged
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dec, item) {
+		t.Errorf("Expected %v, received %v", item, dec)
+	}
+}
+
+func TestElementRoots(t *testing.T) {
+	type attestation struct {
+		Slot uint64
+		Bits []byte `ssz-max:"8"`
+	}
+	tests := []struct {
+		name  string
+		list  interface{}
+		elems []interface{}
+	}{
+		{name: "Uint64s", list: []uint64{1, 2, 3}, elems: []interface{}{uint64(1), uint64(2), uint64(3)}},
+		{name: "Roots", list: [2][32]byte{{1}, {2}}, elems: []interface{}{[32]byte{1}, [32]byte{2}}},
+		{name: "Lists", list: [][]uint16{{1}, {}, {2, 3}}, elems: []interface{}{[]uint16{1}, []uint16{}, []uint16{2, 3}}},
+		{
+			name:  "Containers",
+			list:  []attestation{{Slot: 1, Bits: []byte{1}}, {Slot: 2}},
+			elems: []interface{}{attestation{Slot: 1, Bits: []byte{1}}, attestation{Slot: 2}},
+		},
+		{
+			name:  "NilPointers",
+			list:  &[]*attestation{{Slot: 1}, nil},
+			elems: []interface{}{&attestation{Slot: 1}, &attestation{}},
+		},
+		{name: "Empty", list: []fork{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roots, err := ElementRoots(tt.list)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(roots) != len(tt.elems) {
+				t.Fatalf("Expected %d roots, received %d", len(tt.elems), len(roots))
+			}
+			for i, elem := range tt.elems {
+				want, err := HashTreeRoot(elem)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if roots[i] != want {
+					t.Errorf("Expected root %#x for element %d, received %#x", want, i, roots[i])
+				}
+			}
+		})
+	}
+	if _, err := ElementRoots(fork{}); err == nil {
+		t.Error("Expected a container to be rejected")
+	}
+	if _, err := ElementRoots(nil); err == nil {
+		t.Error("Expected nil to be rejected")
+	}
+}
+
+type namedBody struct {
+	Slot  uint64
+	Data  []byte   `ssz-max:"8"`
+	Roots [][]byte `ssz-size:"2,4"`
+}
+
+type namedContainers struct {
+	Body   namedBody
+	Ptr    *namedBody
+	Bodies []namedBody `ssz-max:"4"`
+	Pair   [2]namedBody
+}
+
+type anonymousContainers struct {
+	Body struct {
+		Slot  uint64
+		Data  []byte   `ssz-max:"8"`
+		Roots [][]byte `ssz-size:"2,4"`
+	}
+	Ptr *struct {
+		Slot  uint64
+		Data  []byte   `ssz-max:"8"`
+		Roots [][]byte `ssz-size:"2,4"`
+	}
+	Bodies []struct {
+		Slot  uint64
+		Data  []byte   `ssz-max:"8"`
+		Roots [][]byte `ssz-size:"2,4"`
+	} `ssz-max:"4"`
+	Pair [2]struct {
+		Slot  uint64
+		Data  []byte   `ssz-max:"8"`
+		Roots [][]byte `ssz-size:"2,4"`
+	}
+}
+
+func TestAnonymousStructs_MatchNamedStructs(t *testing.T) {
+	body := namedBody{Slot: 1, Data: []byte{1, 2}, Roots: [][]byte{{1, 2, 3, 4}, {5, 6, 7, 8}}}
+	named := namedContainers{Body: body, Ptr: &body, Bodies: []namedBody{body, {Data: []byte{}, Roots: [][]byte{make([]byte, 4), make([]byte, 4)}}}, Pair: [2]namedBody{body, body}}
+	var anonymous anonymousContainers
+	anonymous.Body = body
+	ptr := anonymous.Body
+	anonymous.Ptr = &ptr
+	anonymous.Bodies = append(anonymous.Bodies, body, named.Bodies[1])
+	anonymous.Pair[0], anonymous.Pair[1] = body, body
+
+	want, err := Marshal(named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := Marshal(anonymous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, want) {
+		t.Errorf("Expected encoding %#x, received %#x", want, enc)
+	}
+	var dec anonymousContainers
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dec, anonymous) {
+		t.Errorf("Expected %v, received %v", anonymous, dec)
+	}
+
+	wantRoot, err := HashTreeRoot(named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := HashTreeRoot(anonymous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != wantRoot {
+		t.Errorf("Expected root %#x, received %#x", wantRoot, root)
+	}
+	// Anonymous structs share an empty type name, which must not mix up the
+	// tries cached for their fields.
+	first := struct{ Roots [4][32]byte }{Roots: [4][32]byte{{1}, {2}}}
+	second := struct {
+		Roots [4][32]byte
+		Slot  uint64
+	}{Roots: [4][32]byte{{3}, {4}}}
+	wantFirst, err := HashTreeRoot(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSecond, err := HashTreeRoot(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types.ToggleCache(true)
+	defer types.ToggleCache(false)
+	for i := 0; i < 3; i++ {
+		first.Roots[3][0]++
+		second.Roots[3][0]++
+		if root, err := HashTreeRoot(anonymous); err != nil || root != wantRoot {
+			t.Errorf("Expected root %#x with caching, received %#x (%v)", wantRoot, root, err)
+		}
+		if root, err := HashTreeRoot(first); err != nil || root == wantFirst {
+			t.Errorf("Expected the root of the first struct to change, received %#x (%v)", root, err)
+		}
+		if root, err := HashTreeRoot(second); err != nil || root == wantSecond {
+			t.Errorf("Expected the root of the second struct to change, received %#x (%v)", root, err)
+		}
+	}
+	types.ToggleCache(false)
+	for _, val := range []interface{}{first, second} {
+		want, err := HashTreeRoot(val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		types.ToggleCache(true)
+		root, err := HashTreeRoot(val)
+		types.ToggleCache(false)
+		if err != nil || root != want {
+			t.Errorf("Expected cached root %#x, received %#x (%v)", want, root, err)
+		}
+	}
+}
+
+func TestHashTreeRoot_ConcurrentStatesWithCache(t *testing.T) {
+	// Both states share the tries cached for beaconState.BlockRoots, which each
+	// goroutine updates as it changes a root of its own state before hashing it.
+	const iterations = 8
+	mutate := func(state *beaconState, i int) {
+		state.BlockRoots[i*4099][1] = byte(i + 1)
+	}
+	seeds := []byte{1, 2}
+	want := make([][iterations][32]byte, len(seeds))
+	for j, seed := range seeds {
+		state := largeBeaconState(seed)
+		for i := 0; i < iterations; i++ {
+			mutate(state, i)
+			root, err := HashTreeRoot(state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want[j][i] = root
+		}
+	}
+	types.ToggleCache(true)
+	defer types.ToggleCache(false)
+	var wg sync.WaitGroup
+	for j, seed := range seeds {
+		wg.Add(1)
+		go func(j int, state *beaconState) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				mutate(state, i)
+				root, err := HashTreeRoot(state)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if root != want[j][i] {
+					t.Errorf("Expected root %#x of state %d after %d changes, received %#x", want[j][i], j, i+1, root)
+				}
+			}
+		}(j, largeBeaconState(seed))
+	}
+	wg.Wait()
+}
+
+func TestContainerFieldTags_Rejected(t *testing.T) {
+	type sizedBody struct {
+		Body namedBody `ssz-size:"2,4"`
+	}
+	type limitedBody struct {
+		Body *namedBody `ssz-max:"8"`
+	}
+	type sizedAnonymous struct {
+		Body struct {
+			Data []byte
+		} `ssz-size:"32"`
+	}
+	for _, val := range []interface{}{&sizedBody{}, &limitedBody{}, &sizedAnonymous{}} {
+		wantErr := "place it on the field inside the container it applies to"
+		if _, err := Marshal(val); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("Expected marshaling %T to fail with %q, received %v", val, wantErr, err)
+		}
+		if _, err := HashTreeRoot(val); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("Expected hashing %T to fail with %q, received %v", val, wantErr, err)
+		}
+		if err := Unmarshal(make([]byte, 64), val); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("Expected unmarshaling %T to fail with %q, received %v", val, wantErr, err)
+		}
+	}
+}
+
+func BenchmarkMarshal_BeaconStateBlockRoots(b *testing.B) {
+	roots := make([][]byte, 65536)
+	for i := 0; i < len(roots); i++ {
+		roots[i] = make([]byte, 32)
+		roots[i][0] = byte(i)
+	}
+	state := &beaconState{BlockRoots: roots}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(state); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEmptyContainer_TopLevel(t *testing.T) {
+	type empty struct{}
+	enc, err := Marshal(empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(enc) != 0 {
+		t.Errorf("Expected empty container to serialize to zero bytes, received %v", enc)
+	}
+	if err := Unmarshal(enc, &empty{}); err != nil {
+		t.Errorf("Unexpected error unmarshaling an empty container: %v", err)
+	}
+	if err := Unmarshal([]byte{1}, &empty{}); err == nil {
+		t.Error("Expected trailing data after an empty container to fail unmarshaling")
+	}
+}
+
+func TestEmptyContainer_AsField(t *testing.T) {
+	type empty struct{}
+	type container struct {
+		Slot    uint64
+		Empty   empty
+		Vector  [0]uint64
+		Data    []byte
+		Nothing [0][]byte
+		Epoch   uint16
+	}
+	item := container{Slot: 5, Data: []byte{1, 2}, Epoch: 9}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{5, 0, 0, 0, 0, 0, 0, 0, 14, 0, 0, 0, 9, 0, 1, 2}
+	if !bytes.Equal(enc, want) {
+		t.Errorf("Wanted %v, received %v", want, enc)
+	}
+	if size := types.DetermineSize(reflect.ValueOf(item)); size != uint64(len(want)) {
+		t.Errorf("Wanted size %d, received %d", len(want), size)
+	}
+	dec := container{}
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !DeepEqual(item, dec) {
+		t.Errorf("Wanted %v, received %v", item, dec)
+	}
+}
+
+func TestZeroLengthVector_TopLevel(t *testing.T) {
+	enc, err := Marshal([0]uint64{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(enc) != 0 {
+		t.Errorf("Expected zero-length vector to serialize to zero bytes, received %v", enc)
+	}
+	if err := Unmarshal(enc, &[0]uint64{}); err != nil {
+		t.Errorf("Unexpected error unmarshaling a zero-length vector: %v", err)
+	}
+}
+
+func TestListOfEmptyContainers_Rejected(t *testing.T) {
+	type empty struct{}
+	type container struct {
+		Items []empty
+	}
+	if _, err := Marshal([]empty{{}, {}}); err == nil {
+		t.Error("Expected marshaling a list of empty containers to fail")
+	}
+	if _, err := Marshal(container{Items: []empty{{}}}); err == nil {
+		t.Error("Expected marshaling a container with a list of empty containers to fail")
+	}
+	if err := Unmarshal([]byte{4, 0, 0, 0}, &container{}); err == nil {
+		t.Error("Expected unmarshaling a list of empty containers to fail")
+	}
+	if _, err := Marshal([][0]uint64{{}, {}}); err == nil {
+		t.Error("Expected marshaling a list of zero-length vectors to fail")
+	}
+}
+
+func TestHashTreeRootWithLimits_RenamedFields(t *testing.T) {
+	type renamed struct {
+		Slot     uint64
+		Balances []uint64 `ssz-name:"balances"`
+		Comment  string   `ssz-max:"8"`
+	}
+	type tagged struct {
+		Slot     uint64
+		Balances []uint64 `ssz-max:"16"`
+		Comment  string   `ssz-max:"8"`
+	}
+	item := renamed{Slot: 1, Balances: []uint64{1, 2}, Comment: "a"}
+	want, err := HashTreeRoot(tagged{Slot: item.Slot, Balances: item.Balances, Comment: item.Comment})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := HashTreeRootWithLimits(item, map[string]uint64{"balances": 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("Expected root %#x, received %#x", want, got)
+	}
+	// Renaming only changes how a field is addressed, never its encoding.
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEnc, err := Marshal(tagged{Slot: item.Slot, Balances: item.Balances, Comment: item.Comment})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, wantEnc) {
+		t.Errorf("Expected encoding %#x, received %#x", wantEnc, enc)
+	}
+	if _, err := HashTreeRootWithLimits(item, map[string]uint64{"Balances": 16}); err == nil {
+		t.Error("Expected the Go name of a renamed field not to be accepted as its path")
+	}
+}
+
+func TestHashTreeRoot_DuplicateFieldNames(t *testing.T) {
+	type duplicate struct {
+		Slot  uint64
+		Epoch uint64 `ssz-name:"Slot"`
+	}
+	_, err := HashTreeRoot(duplicate{})
+	if err == nil || !strings.Contains(err.Error(), "both resolve to the name \"Slot\"") {
+		t.Errorf("Expected duplicate field names to be rejected, received %v", err)
+	}
+}
+
+type budgetNode struct {
+	Value    uint64
+	Children []*budgetNode `ssz-max:"1024"`
+}
+
+type budgetList struct {
+	Items []*budgetNode `ssz-max:"1048576"`
+}
+
+func nestedBudgetNodes(depth int) *budgetNode {
+	node := &budgetNode{Value: uint64(depth)}
+	if depth > 1 {
+		node.Children = []*budgetNode{nestedBudgetNodes(depth - 1)}
+	}
+	return node
+}
+
+func TestUnmarshalWithOptions_WithinBudget(t *testing.T) {
+	item := &budgetList{Items: []*budgetNode{nestedBudgetNodes(3), {Value: 9}}}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &budgetList{}
+	opts := UnmarshalOptions{Context: context.Background(), MaxElements: 100, MaxDepth: 10}
+	if err := UnmarshalWithOptions(enc, decoded, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := &budgetList{}
+	if err := Unmarshal(enc, want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("Expected %+v, received %+v", want, decoded)
+	}
+}
+
+func TestUnmarshalWithOptions_ManySmallElements(t *testing.T) {
+	// Every element is a valid container holding an empty list, which makes for
+	// the largest number of elements per byte of input.
+	item := &budgetList{Items: make([]*budgetNode, 10000)}
+	for i := range item.Items {
+		item.Items[i] = &budgetNode{}
+	}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = UnmarshalWithOptions(enc, &budgetList{}, UnmarshalOptions{MaxElements: 1000})
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected the element budget to be exceeded, received %v", err)
+	}
+}
+
+func TestUnmarshalWithOptions_DeepNesting(t *testing.T) {
+	enc, err := Marshal(nestedBudgetNodes(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = UnmarshalWithOptions(enc, &budgetNode{}, UnmarshalOptions{MaxDepth: 20})
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected the depth budget to be exceeded, received %v", err)
+	}
+	if err := UnmarshalWithOptions(enc, &budgetNode{}, UnmarshalOptions{MaxDepth: 200}); err != nil {
+		t.Errorf("Expected nesting within the budget to decode, received %v", err)
+	}
+}
+
+func TestUnmarshalWithOptions_Deadline(t *testing.T) {
+	item := &budgetList{Items: make([]*budgetNode, 1000)}
+	for i := range item.Items {
+		item.Items[i] = &budgetNode{}
+	}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = UnmarshalWithOptions(enc, &budgetList{}, UnmarshalOptions{Context: ctx})
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected an expired context to stop decoding, received %v", err)
+	}
+}
+
+func TestUnmarshalWithOptions_MalformedInputIsNotBudget(t *testing.T) {
+	enc, err := Marshal(&budgetList{Items: []*budgetNode{{Value: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = UnmarshalWithOptions(append(enc, 0), &budgetList{}, UnmarshalOptions{MaxElements: 1000})
+	if err == nil {
+		t.Fatal("Expected malformed input to fail decoding")
+	}
+	if errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected malformed input not to be reported as exceeding the budget, received %v", err)
+	}
+}
+
+func TestUnmarshalWithLimit_WithinLimit(t *testing.T) {
+	item := &budgetList{Items: []*budgetNode{nestedBudgetNodes(3), {Value: 9}}}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &budgetList{}
+	if err := UnmarshalWithLimit(enc, decoded, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	want := &budgetList{}
+	if err := Unmarshal(enc, want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("Expected %+v, received %+v", want, decoded)
+	}
+}
+
+func TestUnmarshalWithLimit_RejectsBeforeAllocating(t *testing.T) {
+	item := &budgetList{Items: make([]*budgetNode, 10000)}
+	for i := range item.Items {
+		item.Items[i] = &budgetNode{}
+	}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &budgetList{}
+	err = UnmarshalWithLimit(enc, decoded, 4096)
+	if !errors.Is(err, ErrAllocationLimit) {
+		t.Fatalf("Expected the allocation limit to be exceeded, received %v", err)
+	}
+	// The number of elements is claimed by the offsets, so the list is
+	// rejected before it grows.
+	if cap(decoded.Items) > 1 {
+		t.Errorf("Expected the list not to be allocated, it has a capacity of %d", cap(decoded.Items))
+	}
+}
+
+func TestUnmarshalWithLimit_CumulativeAllocations(t *testing.T) {
+	type allocFields struct {
+		First  string  `ssz-max:"1024"`
+		Second string  `ssz-max:"1024"`
+		Bits   Bitlist `ssz-max:"8192"`
+	}
+	item := &allocFields{First: string(make([]byte, 600)), Second: string(make([]byte, 600)), Bits: NewBitlist(16)}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each string fits within the limit on its own, but not both of them.
+	err = UnmarshalWithLimit(enc, &allocFields{}, 1000)
+	if !errors.Is(err, ErrAllocationLimit) {
+		t.Errorf("Expected the allocation limit to be exceeded, received %v", err)
+	}
+	// The bitlist of 16 bits takes 3 bytes along with its delimiting bit.
+	if err := UnmarshalWithLimit(enc, &allocFields{}, 1202); !errors.Is(err, ErrAllocationLimit) {
+		t.Errorf("Expected the bitlist to exceed the allocation limit, received %v", err)
+	}
+	if err := UnmarshalWithLimit(enc, &allocFields{}, 1203); err != nil {
+		t.Errorf("Expected the allocations to fit within the limit, received %v", err)
+	}
+	opts := UnmarshalOptions{MaxBytes: 1000}
+	if err := UnmarshalWithOptions(enc, &allocFields{}, opts); !errors.Is(err, ErrAllocationLimit) {
+		t.Errorf("Expected the MaxBytes option to bound allocations, received %v", err)
+	}
+}
+
+func TestUnmarshalWithLimit_MalformedInputIsNotLimit(t *testing.T) {
+	enc, err := Marshal(&budgetList{Items: []*budgetNode{{Value: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = UnmarshalWithLimit(append(enc, 0), &budgetList{}, 1<<20)
+	if err == nil {
+		t.Fatal("Expected malformed input to fail decoding")
+	}
+	if errors.Is(err, ErrAllocationLimit) {
+		t.Errorf("Expected malformed input not to be reported as exceeding the limit, received %v", err)
+	}
+}
+
+func benchmarkBudgetList() []byte {
+	item := &budgetList{Items: make([]*budgetNode, 1000)}
+	for i := range item.Items {
+		item.Items[i] = nestedBudgetNodes(3)
+	}
+	enc, err := Marshal(item)
+	if err != nil {
+		panic(err)
+	}
+	return enc
+}
+
+func BenchmarkUnmarshal_BudgetList(b *testing.B) {
+	enc := benchmarkBudgetList()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := Unmarshal(enc, &budgetList{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalWithOptions_BudgetList(b *testing.B) {
+	enc := benchmarkBudgetList()
+	opts := UnmarshalOptions{Context: context.Background(), MaxElements: 1 << 20, MaxDepth: 16}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := UnmarshalWithOptions(enc, &budgetList{}, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type canonicalCheckpoint struct {
+	Epoch uint64
+	Root  []byte `ssz-size:"32"`
+}
+
+type canonicalVariable struct {
+	Data []byte `ssz-max:"16"`
+}
+
+type canonicalState struct {
+	Source      *canonicalCheckpoint
+	Target      canonicalCheckpoint
+	Checkpoints [2]*canonicalCheckpoint
+	Variables   []*canonicalVariable `ssz-max:"4"`
+	Roots       [][]byte             `ssz-size:"?,32" ssz-max:"4"`
+	Fixed       [][]byte             `ssz-size:"2,32"`
+	Bytes       []byte               `ssz-max:"8"`
+}
+
+func TestZeroRepresentations_EncodeIdentically(t *testing.T) {
+	nilState := &canonicalState{}
+	emptyState := &canonicalState{
+		Source:      &canonicalCheckpoint{},
+		Target:      canonicalCheckpoint{Root: []byte{}},
+		Checkpoints: [2]*canonicalCheckpoint{{}, {Root: []byte{}}},
+		Variables:   []*canonicalVariable{},
+		Roots:       [][]byte{},
+		Fixed:       [][]byte{},
+		Bytes:       []byte{},
+	}
+	zeroState := &canonicalState{
+		Source:      &canonicalCheckpoint{Root: make([]byte, 32)},
+		Target:      canonicalCheckpoint{Root: make([]byte, 32)},
+		Checkpoints: [2]*canonicalCheckpoint{{Root: make([]byte, 32)}, {Root: make([]byte, 32)}},
+		Fixed:       [][]byte{make([]byte, 32), make([]byte, 32)},
+	}
+	want, err := Marshal(zeroState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRoot, err := HashTreeRoot(zeroState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, state := range map[string]*canonicalState{"nil": nilState, "empty": emptyState} {
+		enc, err := Marshal(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(enc, want) {
+			t.Errorf("Expected %s state to encode as %#x, received %#x", name, want, enc)
+		}
+		root, err := HashTreeRoot(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root != wantRoot {
+			t.Errorf("Expected %s state to hash to %#x, received %#x", name, wantRoot, root)
+		}
+		decoded := &canonicalState{}
+		if err := Unmarshal(enc, decoded); err != nil {
+			t.Fatalf("Could not decode %s state: %v", name, err)
+		}
+	}
+	// Unset elements of lists and vectors encode as zero elements too.
+	withUnset := &canonicalState{Variables: []*canonicalVariable{nil}, Roots: [][]byte{nil}}
+	withZero := &canonicalState{Variables: []*canonicalVariable{{}}, Roots: [][]byte{make([]byte, 32)}}
+	unsetEnc, err := Marshal(withUnset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zeroEnc, err := Marshal(withZero)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(unsetEnc, zeroEnc) {
+		t.Errorf("Expected unset elements to encode as %#x, received %#x", zeroEnc, unsetEnc)
+	}
+}
+
+func TestIsZeroValue(t *testing.T) {
+	tests := []struct {
+		name string
+		val  interface{}
+		want bool
+	}{
+		{name: "nil pointer", val: (*canonicalCheckpoint)(nil), want: true},
+		{name: "empty container", val: &canonicalCheckpoint{}, want: true},
+		{name: "zero-filled container", val: canonicalCheckpoint{Root: make([]byte, 32)}, want: true},
+		{name: "nested zero values", val: &canonicalState{Source: &canonicalCheckpoint{}, Variables: []*canonicalVariable{}}, want: true},
+		{name: "non-zero epoch", val: &canonicalCheckpoint{Epoch: 1}, want: false},
+		{name: "non-zero root", val: &canonicalCheckpoint{Root: append(make([]byte, 31), 1)}, want: false},
+		{name: "list holding a zero element", val: &canonicalState{Variables: []*canonicalVariable{{}}}, want: false},
+		{name: "zero uint64", val: uint64(0), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := IsZeroValue(tt.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected IsZeroValue to be %v, received %v", tt.want, got)
+			}
+		})
+	}
+	if _, err := IsZeroValue(nil); err == nil {
+		t.Error("Expected an untyped nil to be rejected")
+	}
+}
+
+type pooledItem struct {
+	Index uint64
+	Data  []byte `ssz-max:"32"`
+}
+
+type pooledBlock struct {
+	Slot  uint64
+	Roots [][32]byte `ssz-max:"64"`
+	Body  *canonicalCheckpoint
+	Items []*pooledItem `ssz-max:"16"`
+	Flags [4]byte
+}
+
+func TestReset_ReusesDecodeTarget(t *testing.T) {
+	large := &pooledBlock{
+		Slot:  9,
+		Roots: [][32]byte{{1}, {2}, {3}, {4}},
+		Body:  &canonicalCheckpoint{Epoch: 3, Root: bytes.Repeat([]byte{0xaa}, 32)},
+		Items: []*pooledItem{{Index: 1, Data: []byte{1, 2, 3}}, {Index: 2, Data: []byte{4}}, {Index: 3}},
+		Flags: [4]byte{1, 1, 1, 1},
+	}
+	small := &pooledBlock{
+		Slot:  2,
+		Roots: [][32]byte{{5}},
+		Body:  &canonicalCheckpoint{Epoch: 1, Root: make([]byte, 32)},
+		Items: []*pooledItem{{Index: 7, Data: []byte{9}}},
+	}
+	largeEnc, err := Marshal(large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smallEnc, err := Marshal(small)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := &pooledBlock{}
+	if err := Unmarshal(largeEnc, target); err != nil {
+		t.Fatal(err)
+	}
+	body, items := target.Body, target.Items[:cap(target.Items)]
+	if err := Reset(target); err != nil {
+		t.Fatal(err)
+	}
+	if target.Slot != 0 || len(target.Roots) != 0 || len(target.Items) != 0 || target.Flags != [4]byte{} {
+		t.Errorf("Expected a reset value, received %+v", target)
+	}
+	if target.Body != body || body.Epoch != 0 || len(body.Root) != 0 {
+		t.Errorf("Expected the body to be cleared in place, received %+v", target.Body)
+	}
+	for _, root := range target.Roots[:cap(target.Roots)] {
+		if root != [32]byte{} {
+			t.Errorf("Expected the backing array of roots to be cleared, received %#x", root)
+		}
+	}
+	for _, item := range items {
+		if item.Index != 0 || len(item.Data) != 0 {
+			t.Errorf("Expected the items to be cleared, received %+v", item)
+		}
+	}
+
+	if err := Unmarshal(smallEnc, target); err != nil {
+		t.Fatal(err)
+	}
+	fresh := &pooledBlock{}
+	if err := Unmarshal(smallEnc, fresh); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(target, fresh) {
+		t.Errorf("Expected %+v, received %+v", fresh, target)
+	}
+	if target.Items[0] != items[0] {
+		t.Error("Expected the decoded items to reuse the reset ones")
+	}
+
+	reusedAllocs := testing.AllocsPerRun(10, func() {
+		if err := Reset(target); err != nil {
+			t.Fatal(err)
+		}
+		if err := Unmarshal(smallEnc, target); err != nil {
+			t.Fatal(err)
+		}
+	})
+	freshAllocs := testing.AllocsPerRun(10, func() {
+		if err := Unmarshal(smallEnc, &pooledBlock{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if reusedAllocs >= freshAllocs {
+		t.Errorf("Expected decoding into a reset value to allocate less than %v times, allocated %v times", freshAllocs, reusedAllocs)
+	}
+}
+
+func TestReset_Errors(t *testing.T) {
+	for _, val := range []interface{}{nil, pooledBlock{}, (*pooledBlock)(nil)} {
+		if err := Reset(val); err == nil {
+			t.Errorf("Expected resetting %#v to fail", val)
+		}
+	}
+	num := uint64(5)
+	if err := Reset(&num); err != nil || num != 0 {
+		t.Errorf("Expected a reset uint64 to be zero, received %d (%v)", num, err)
+	}
+}
+
+type pointerFields struct {
+	Slot   uint64
+	Data   *[]byte   `ssz-max:"4"`
+	Epochs *[]uint64 `ssz-max:"4"`
+	Pair   *[2]uint16
+	Roots  *[2][]byte
+	Name   *string `ssz-max:"4"`
+}
+
+type valueFields struct {
+	Slot   uint64
+	Data   []byte   `ssz-max:"4"`
+	Epochs []uint64 `ssz-max:"4"`
+	Pair   [2]uint16
+	Roots  [2][]byte
+	Name   string `ssz-max:"4"`
+}
+
+func TestPointerFields_EncodedAsValues(t *testing.T) {
+	name := "name"
+	pointers := &pointerFields{Slot: 1, Data: &[]byte{1, 2}, Epochs: &[]uint64{3}, Pair: &[2]uint16{4, 5}, Roots: &[2][]byte{{6}, {}}, Name: &name}
+	values := &valueFields{Slot: 1, Data: []byte{1, 2}, Epochs: []uint64{3}, Pair: [2]uint16{4, 5}, Roots: [2][]byte{{6}, {}}, Name: name}
+	enc, err := Marshal(pointers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Marshal(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, want) {
+		t.Errorf("Expected %#x, received %#x", want, enc)
+	}
+	root, err := HashTreeRoot(pointers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRoot, err := HashTreeRoot(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != wantRoot {
+		t.Errorf("Expected root %#x, received %#x", wantRoot, root)
+	}
+	decoded := &pointerFields{}
+	if err := Unmarshal(enc, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !DeepEqual(decoded, pointers) {
+		t.Errorf("Expected %+v, received %+v", pointers, decoded)
+	}
+	// Nil pointers are encoded as the zero values they point to.
+	enc, err = Marshal(&pointerFields{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, err = Marshal(&valueFields{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, want) {
+		t.Errorf("Expected %#x, received %#x", want, enc)
+	}
+}
+
+func TestMarshal_NamedUintElements(t *testing.T) {
+	type slot uint64
+	type flags uint8
+	type namedElements struct {
+		Slots []slot  `ssz-max:"4"`
+		Flags []flags `ssz-max:"4"`
+		Pair  [2]slot
+	}
+	item := &namedElements{Slots: []slot{1, 2}, Flags: []flags{3}, Pair: [2]slot{4, 5}}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &namedElements{}
+	if err := Unmarshal(enc, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, item) {
+		t.Errorf("Expected %+v, received %+v", item, decoded)
+	}
+}
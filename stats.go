@@ -0,0 +1,209 @@
+package ssz
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StatsCollector receives a callback before and after every call to the
+// top-level encoding, decoding and hashing functions, such as Marshal,
+// Unmarshal, TypedDecoder.Decode and HashTreeRoot. The type reported is the
+// type of the value, or of the value pointed to for pointers, and bytes is the
+// size of the encoding produced or consumed. Callbacks may be invoked
+// concurrently.
+type StatsCollector interface {
+	DecodeStart(typ reflect.Type)
+	DecodeEnd(typ reflect.Type, bytes int, err error, duration time.Duration)
+	MarshalStart(typ reflect.Type)
+	MarshalEnd(typ reflect.Type, bytes int, err error, duration time.Duration)
+	HashTreeRootStart(typ reflect.Type)
+	HashTreeRootEnd(typ reflect.Type, err error, duration time.Duration)
+}
+
+type statsCollectorHolder struct {
+	collector StatsCollector
+}
+
+var statsCollector atomic.Value
+
+func init() {
+	statsCollector.Store(statsCollectorHolder{})
+}
+
+// SetStatsCollector configures the collector notified of top-level calls.
+// Passing nil disables collection, which is the default.
+func SetStatsCollector(c StatsCollector) {
+	statsCollector.Store(statsCollectorHolder{collector: c})
+}
+
+func loadStatsCollector() StatsCollector {
+	return statsCollector.Load().(statsCollectorHolder).collector
+}
+
+// statsType returns the type reported to collectors for a value.
+func statsType(val interface{}) reflect.Type {
+	typ := reflect.TypeOf(val)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		return typ.Elem()
+	}
+	return typ
+}
+
+// observeDecode runs a decoding function, reporting it to the configured
+// collector if there is one.
+func observeDecode(typ reflect.Type, bytes int, decode func() error) error {
+	c := loadStatsCollector()
+	if c == nil {
+		return decode()
+	}
+	c.DecodeStart(typ)
+	start := time.Now()
+	err := decode()
+	c.DecodeEnd(typ, bytes, err, time.Since(start))
+	return err
+}
+
+// observeHashTreeRoot runs a hashing function, reporting it to the configured
+// collector if there is one.
+func observeHashTreeRoot(val interface{}, hash func() ([32]byte, error)) ([32]byte, error) {
+	c := loadStatsCollector()
+	if c == nil {
+		return hash()
+	}
+	typ := statsType(val)
+	c.HashTreeRootStart(typ)
+	start := time.Now()
+	root, err := hash()
+	c.HashTreeRootEnd(typ, err, time.Since(start))
+	return root, err
+}
+
+// latencySamples is the number of most recent latencies per type and operation
+// kept by a MemoryStatsCollector to estimate their 99th percentile.
+const latencySamples = 1024
+
+// OperationStats summarizes the calls of one operation on one type.
+type OperationStats struct {
+	// Count is the number of calls, including failed ones.
+	Count uint64
+	// Errors is the number of calls which returned an error.
+	Errors uint64
+	// Bytes is the total size of the encodings produced or consumed.
+	Bytes uint64
+	// P99Latency is the 99th percentile latency of recent calls.
+	P99Latency time.Duration
+}
+
+// StatsSnapshot holds the statistics of a MemoryStatsCollector per type.
+type StatsSnapshot struct {
+	Decode       map[reflect.Type]OperationStats
+	Marshal      map[reflect.Type]OperationStats
+	HashTreeRoot map[reflect.Type]OperationStats
+}
+
+type operationStats struct {
+	count     uint64
+	errors    uint64
+	bytes     uint64
+	latencies []time.Duration
+	next      int
+}
+
+func (s *operationStats) record(bytes int, err error, duration time.Duration) {
+	s.count++
+	if err != nil {
+		s.errors++
+	}
+	s.bytes += uint64(bytes)
+	if len(s.latencies) < latencySamples {
+		s.latencies = append(s.latencies, duration)
+		return
+	}
+	s.latencies[s.next] = duration
+	s.next = (s.next + 1) % latencySamples
+}
+
+func (s *operationStats) snapshot() OperationStats {
+	res := OperationStats{Count: s.count, Errors: s.errors, Bytes: s.bytes}
+	if len(s.latencies) > 0 {
+		sorted := make([]time.Duration, len(s.latencies))
+		copy(sorted, s.latencies)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		res.P99Latency = sorted[(len(sorted)*99)/100]
+	}
+	return res
+}
+
+// MemoryStatsCollector is a StatsCollector keeping its statistics in memory,
+// for tests and simple uses such as periodically logging a Snapshot.
+type MemoryStatsCollector struct {
+	lock    sync.Mutex
+	decode  map[reflect.Type]*operationStats
+	marshal map[reflect.Type]*operationStats
+	hash    map[reflect.Type]*operationStats
+}
+
+// NewMemoryStatsCollector creates an empty MemoryStatsCollector.
+func NewMemoryStatsCollector() *MemoryStatsCollector {
+	return &MemoryStatsCollector{
+		decode:  make(map[reflect.Type]*operationStats),
+		marshal: make(map[reflect.Type]*operationStats),
+		hash:    make(map[reflect.Type]*operationStats),
+	}
+}
+
+func (c *MemoryStatsCollector) record(ops map[reflect.Type]*operationStats, typ reflect.Type, bytes int, err error, duration time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	s, ok := ops[typ]
+	if !ok {
+		s = &operationStats{}
+		ops[typ] = s
+	}
+	s.record(bytes, err, duration)
+}
+
+// DecodeStart implements StatsCollector.
+func (c *MemoryStatsCollector) DecodeStart(typ reflect.Type) {}
+
+// DecodeEnd implements StatsCollector.
+func (c *MemoryStatsCollector) DecodeEnd(typ reflect.Type, bytes int, err error, duration time.Duration) {
+	c.record(c.decode, typ, bytes, err, duration)
+}
+
+// MarshalStart implements StatsCollector.
+func (c *MemoryStatsCollector) MarshalStart(typ reflect.Type) {}
+
+// MarshalEnd implements StatsCollector.
+func (c *MemoryStatsCollector) MarshalEnd(typ reflect.Type, bytes int, err error, duration time.Duration) {
+	c.record(c.marshal, typ, bytes, err, duration)
+}
+
+// HashTreeRootStart implements StatsCollector.
+func (c *MemoryStatsCollector) HashTreeRootStart(typ reflect.Type) {}
+
+// HashTreeRootEnd implements StatsCollector.
+func (c *MemoryStatsCollector) HashTreeRootEnd(typ reflect.Type, err error, duration time.Duration) {
+	c.record(c.hash, typ, 0, err, duration)
+}
+
+// Snapshot returns a copy of the statistics collected so far.
+func (c *MemoryStatsCollector) Snapshot() StatsSnapshot {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	copyOps := func(ops map[reflect.Type]*operationStats) map[reflect.Type]OperationStats {
+		res := make(map[reflect.Type]OperationStats, len(ops))
+		for typ, s := range ops {
+			res[typ] = s.snapshot()
+		}
+		return res
+	}
+	return StatsSnapshot{
+		Decode:       copyOps(c.decode),
+		Marshal:      copyOps(c.marshal),
+		HashTreeRoot: copyOps(c.hash),
+	}
+}
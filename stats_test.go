@@ -0,0 +1,95 @@
+package ssz
+
+import (
+	"reflect"
+	"testing"
+)
+
+type statsCheckpoint struct {
+	Epoch uint64
+	Root  [32]byte
+}
+
+func TestMemoryStatsCollector(t *testing.T) {
+	collector := NewMemoryStatsCollector()
+	SetStatsCollector(collector)
+	defer SetStatsCollector(nil)
+
+	item := &statsCheckpoint{Epoch: 3, Root: [32]byte{1}}
+	enc, err := Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Marshal(struct{ Foo complex128 }{}); err == nil {
+		t.Fatal("Expected marshaling an unsupported type to fail")
+	}
+	for i := 0; i < 3; i++ {
+		if err := Unmarshal(enc, &statsCheckpoint{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Unmarshal(enc[:10], &statsCheckpoint{}); err == nil {
+		t.Fatal("Expected unmarshaling truncated input to fail")
+	}
+	dec, err := NewTypedDecoder(statsCheckpoint{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dec.DecodeNew(enc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := HashTreeRoot(item); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := HashTreeRootWithCapacity(item, 4); err == nil {
+		t.Fatal("Expected hashing a container with a capacity to fail")
+	}
+
+	typ := reflect.TypeOf(statsCheckpoint{})
+	snapshot := collector.Snapshot()
+	marshalStats := snapshot.Marshal[typ]
+	if marshalStats.Count != 1 || marshalStats.Errors != 0 || marshalStats.Bytes != uint64(len(enc)) {
+		t.Errorf("Unexpected marshal stats: %+v", marshalStats)
+	}
+	failedType := reflect.TypeOf(struct{ Foo complex128 }{})
+	if failed := snapshot.Marshal[failedType]; failed.Count != 1 || failed.Errors != 1 {
+		t.Errorf("Unexpected failed marshal stats: %+v", failed)
+	}
+	decodeStats := snapshot.Decode[typ]
+	if decodeStats.Count != 5 || decodeStats.Errors != 1 || decodeStats.Bytes != uint64(4*len(enc)+10) {
+		t.Errorf("Unexpected decode stats: %+v", decodeStats)
+	}
+	if decodeStats.P99Latency <= 0 {
+		t.Errorf("Expected a positive p99 decode latency, received %v", decodeStats.P99Latency)
+	}
+	hashStats := snapshot.HashTreeRoot[typ]
+	if hashStats.Count != 2 || hashStats.Errors != 1 {
+		t.Errorf("Unexpected hash tree root stats: %+v", hashStats)
+	}
+}
+
+func TestSetStatsCollector_Nil(t *testing.T) {
+	collector := NewMemoryStatsCollector()
+	SetStatsCollector(collector)
+	SetStatsCollector(nil)
+	if _, err := Marshal(&statsCheckpoint{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := collector.Snapshot().Marshal; len(got) != 0 {
+		t.Errorf("Expected no stats after removing the collector, received %v", got)
+	}
+}
+
+func TestOperationStats_P99(t *testing.T) {
+	s := &operationStats{}
+	for i := 1; i <= 2*latencySamples; i++ {
+		s.record(0, nil, 0)
+	}
+	// Only the most recent samples are kept.
+	for i := 1; i <= latencySamples; i++ {
+		s.record(0, nil, 1000)
+	}
+	if got := s.snapshot(); got.P99Latency != 1000 || got.Count != 3*latencySamples {
+		t.Errorf("Unexpected stats: %+v", got)
+	}
+}
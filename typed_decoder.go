@@ -0,0 +1,113 @@
+package ssz
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"github.com/524119574/go-ssz/types"
+)
+
+// TypedDecoder unmarshals encodings of a single type. Everything Unmarshal
+// works out on each call from the target value, such as the codec to use and
+// whether the type is fixed-size, is resolved once when the decoder is created,
+// which makes it suited to decoding many values of the same type, such as
+// blocks during a backfill. A TypedDecoder is safe for concurrent use.
+//
+//  dec, err := NewTypedDecoder(&BeaconBlock{})
+//  if err != nil {
+//      return err
+//  }
+//  for _, enc := range encodedBlocks {
+//      block := &BeaconBlock{}
+//      if err := dec.Decode(enc, block); err != nil {
+//          return err
+//      }
+//  }
+type TypedDecoder struct {
+	typ      reflect.Type
+	ptrType  reflect.Type
+	factory  types.SSZAble
+	fastssz  bool
+	zeroSize bool
+}
+
+// NewTypedDecoder creates a decoder for the type of the given prototype, which
+// may be a value or a pointer.
+func NewTypedDecoder(prototype interface{}) (*TypedDecoder, error) {
+	if prototype == nil {
+		return nil, errors.New("cannot create a decoder for untyped, nil value")
+	}
+	typ := reflect.TypeOf(prototype)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	d := &TypedDecoder{
+		typ:     typ,
+		ptrType: reflect.PtrTo(typ),
+	}
+	if d.ptrType.Implements(reflect.TypeOf((*Unmarshaler)(nil)).Elem()) {
+		d.fastssz = true
+		return d, nil
+	}
+	factory, err := types.SSZFactory(reflect.New(typ).Elem(), typ)
+	if err != nil {
+		return nil, err
+	}
+	d.factory = factory
+	d.zeroSize = types.IsZeroSizeType(typ)
+	return d, nil
+}
+
+// Decode unmarshals input into out, which must be a non-nil pointer to the
+// decoder's type. It behaves exactly as Unmarshal(input, out) would.
+func (d *TypedDecoder) Decode(input []byte, out interface{}) error {
+	return observeDecode(d.typ, len(input), func() error {
+		return newError(OpUnmarshal, out, d.decodeInto(input, out))
+	})
+}
+
+// DecodeNew unmarshals input into a newly allocated value of the decoder's type
+// and returns a pointer to it.
+func (d *TypedDecoder) DecodeNew(input []byte) (interface{}, error) {
+	rval := reflect.New(d.typ)
+	err := observeDecode(d.typ, len(input), func() error {
+		if d.fastssz {
+			return newError(OpUnmarshal, rval.Interface(), rval.Interface().(Unmarshaler).UnmarshalSSZ(input))
+		}
+		return newError(OpUnmarshal, rval.Interface(), d.decode(input, rval))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rval.Interface(), nil
+}
+
+func (d *TypedDecoder) decodeInto(input []byte, out interface{}) error {
+	if d.fastssz {
+		v, ok := out.(Unmarshaler)
+		if !ok || reflect.TypeOf(out) != d.ptrType {
+			return fmt.Errorf("decoder for type %v cannot decode into %T", d.typ, out)
+		}
+		return v.UnmarshalSSZ(input)
+	}
+	rval := reflect.ValueOf(out)
+	if rval.Type() != d.ptrType {
+		return fmt.Errorf("decoder for type %v cannot decode into %T", d.typ, out)
+	}
+	if rval.IsNil() {
+		return errors.New("cannot output to pointer of nil value")
+	}
+	return d.decode(input, rval)
+}
+
+func (d *TypedDecoder) decode(input []byte, rval reflect.Value) error {
+	if len(input) == 0 && !d.zeroSize {
+		return fmt.Errorf("no data to unmarshal from, input is an empty byte slice []byte{}: %w", ErrInputTooShort)
+	}
+	consumed, err := d.factory.Unmarshal(rval.Elem(), d.typ, input, 0, nil)
+	if err != nil {
+		return err
+	}
+	return checkDecodedSize(rval.Interface(), consumed, uint64(len(input)))
+}
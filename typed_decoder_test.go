@@ -0,0 +1,211 @@
+package ssz
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type decodedCheckpoint struct {
+	Epoch uint64
+	Root  [32]byte
+}
+
+type decodedAttestation struct {
+	AggregationBits []byte
+	Slot            uint64
+	Source          decodedCheckpoint
+	Target          *decodedCheckpoint
+	Signature       [96]byte
+}
+
+func testAttestations(count int) []*decodedAttestation {
+	atts := make([]*decodedAttestation, count)
+	for i := 0; i < count; i++ {
+		atts[i] = &decodedAttestation{
+			AggregationBits: []byte{byte(i), byte(i >> 8), 1},
+			Slot:            uint64(i),
+			Source:          decodedCheckpoint{Epoch: uint64(i / 32), Root: [32]byte{byte(i)}},
+			Target:          &decodedCheckpoint{Epoch: uint64(i/32 + 1), Root: [32]byte{byte(i + 1)}},
+			Signature:       [96]byte{byte(i), 2, 3},
+		}
+	}
+	return atts
+}
+
+func TestTypedDecoder_MatchesUnmarshal(t *testing.T) {
+	tests := []struct {
+		name      string
+		prototype interface{}
+		input     interface{}
+	}{
+		{name: "fixed-size container", prototype: decodedCheckpoint{}, input: decodedCheckpoint{Epoch: 3, Root: [32]byte{1}}},
+		{name: "variable-size container", prototype: &decodedAttestation{}, input: testAttestations(2)[1]},
+		{name: "list of uint64", prototype: []uint64{}, input: []uint64{1, 2, 3}},
+		{name: "empty container", prototype: struct{}{}, input: struct{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := Marshal(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec, err := NewTypedDecoder(tt.prototype)
+			if err != nil {
+				t.Fatal(err)
+			}
+			typ := reflect.TypeOf(tt.prototype)
+			if typ.Kind() == reflect.Ptr {
+				typ = typ.Elem()
+			}
+			want := reflect.New(typ).Interface()
+			if err := Unmarshal(enc, want); err != nil {
+				t.Fatal(err)
+			}
+			got := reflect.New(typ).Interface()
+			if err := dec.Decode(enc, got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("Decode: expected %v, received %v", want, got)
+			}
+			fresh, err := dec.DecodeNew(enc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(want, fresh) {
+				t.Errorf("DecodeNew: expected %v, received %v", want, fresh)
+			}
+		})
+	}
+}
+
+func TestTypedDecoder_ErrorsMatchUnmarshal(t *testing.T) {
+	enc, err := Marshal(testAttestations(1)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := NewTypedDecoder(decodedAttestation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := [][]byte{
+		{},
+		enc[:len(enc)-1],
+		append(append([]byte{}, enc...), 0),
+	}
+	for _, input := range inputs {
+		wantErr := Unmarshal(input, &decodedAttestation{})
+		_, gotErr := dec.DecodeNew(input)
+		if (wantErr == nil) != (gotErr == nil) {
+			t.Fatalf("Input of length %d: expected error %v, received %v", len(input), wantErr, gotErr)
+		}
+		if wantErr != nil && wantErr.Error() != gotErr.Error() {
+			t.Errorf("Input of length %d: expected error %q, received %q", len(input), wantErr, gotErr)
+		}
+	}
+}
+
+func TestTypedDecoder_RejectsOtherTargets(t *testing.T) {
+	dec, err := NewTypedDecoder(decodedCheckpoint{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := Marshal(decodedCheckpoint{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(enc, decodedCheckpoint{}); err == nil {
+		t.Error("Expected decoding into a non-pointer to fail")
+	}
+	if err := dec.Decode(enc, &decodedAttestation{}); err == nil {
+		t.Error("Expected decoding into a pointer of another type to fail")
+	}
+	var nilTarget *decodedCheckpoint
+	if err := dec.Decode(enc, nilTarget); err == nil {
+		t.Error("Expected decoding into a nil pointer to fail")
+	}
+	if _, err := NewTypedDecoder(nil); err == nil {
+		t.Error("Expected creating a decoder for nil to fail")
+	}
+}
+
+func TestTypedDecoder_ConcurrentUse(t *testing.T) {
+	atts := testAttestations(64)
+	encs := make([][]byte, len(atts))
+	for i, att := range atts {
+		enc, err := Marshal(att)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encs[i] = enc
+	}
+	dec, err := NewTypedDecoder(&decodedAttestation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, len(encs))
+	results := make([]interface{}, len(encs))
+	for i := range encs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = dec.DecodeNew(encs[i])
+		}(i)
+	}
+	wg.Wait()
+	for i := range atts {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if !reflect.DeepEqual(atts[i], results[i]) {
+			t.Errorf("Index %d: expected %v, received %v", i, atts[i], results[i])
+		}
+	}
+}
+
+func encodedAttestations(b *testing.B, count int) [][]byte {
+	atts := testAttestations(count)
+	encs := make([][]byte, count)
+	for i, att := range atts {
+		enc, err := Marshal(att)
+		if err != nil {
+			b.Fatal(err)
+		}
+		encs[i] = enc
+	}
+	return encs
+}
+
+func BenchmarkUnmarshal_10kContainers(b *testing.B) {
+	encs := encodedAttestations(b, 10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, enc := range encs {
+			att := &decodedAttestation{}
+			if err := Unmarshal(enc, att); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkTypedDecoder_10kContainers(b *testing.B) {
+	encs := encodedAttestations(b, 10000)
+	dec, err := NewTypedDecoder(&decodedAttestation{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, enc := range encs {
+			att := &decodedAttestation{}
+			if err := dec.Decode(enc, att); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// AliasConverter converts a value between an external type and the supported
+// prototype type registered for it.
+type AliasConverter func(v interface{}) (interface{}, error)
+
+type aliasSSZ struct {
+	typ         reflect.Type
+	protoType   reflect.Type
+	factory     SSZAble
+	convertTo   AliasConverter
+	convertFrom AliasConverter
+}
+
+var (
+	aliasLock sync.Mutex
+	// aliases holds a map[reflect.Type]*aliasSSZ which is replaced wholesale on every
+	// registration, allowing lookups on the hot path without taking a lock.
+	aliases atomic.Value
+)
+
+func init() {
+	aliases.Store(make(map[reflect.Type]*aliasSSZ))
+}
+
+// RegisterAlias maps an external type onto a supported prototype type. Whenever a value
+// of the external type is encountered, it is converted with convertTo into a value of the
+// prototype's type before marshaling, and decoded values of the prototype's type are
+// converted back with convertFrom when unmarshaling.
+func RegisterAlias(external reflect.Type, prototype reflect.Type, convertTo, convertFrom AliasConverter) error {
+	if external == nil || prototype == nil {
+		return fmt.Errorf("alias types must not be nil")
+	}
+	if convertTo == nil || convertFrom == nil {
+		return fmt.Errorf("alias for type %v requires both conversion functions", external)
+	}
+	if external == prototype {
+		return fmt.Errorf("type %v cannot be registered as an alias of itself", external)
+	}
+	if _, ok := lookupAlias(prototype); ok {
+		return fmt.Errorf("prototype type %v is itself a registered alias", prototype)
+	}
+	factory, err := SSZFactory(reflect.New(prototype).Elem(), prototype)
+	if err != nil {
+		return fmt.Errorf("prototype type %v is not serializable: %v", prototype, err)
+	}
+	aliasLock.Lock()
+	defer aliasLock.Unlock()
+	current := aliases.Load().(map[reflect.Type]*aliasSSZ)
+	if _, ok := current[external]; ok {
+		return fmt.Errorf("an alias for type %v is already registered", external)
+	}
+	updated := make(map[reflect.Type]*aliasSSZ, len(current)+1)
+	for k, v := range current {
+		updated[k] = v
+	}
+	updated[external] = &aliasSSZ{
+		typ:         external,
+		protoType:   prototype,
+		factory:     factory,
+		convertTo:   convertTo,
+		convertFrom: convertFrom,
+	}
+	aliases.Store(updated)
+	resetResolvedTypes()
+	return nil
+}
+
+func lookupAlias(typ reflect.Type) (*aliasSSZ, bool) {
+	a, ok := aliases.Load().(map[reflect.Type]*aliasSSZ)[typ]
+	return a, ok
+}
+
+func (a *aliasSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return a.Marshal(reflect.New(typ.Elem()).Elem(), typ.Elem(), buf, startOffset)
+		}
+		return a.Marshal(val.Elem(), typ.Elem(), buf, startOffset)
+	}
+	converted, err := a.toPrototype(val)
+	if err != nil {
+		return 0, err
+	}
+	return a.factory.Marshal(converted, a.protoType, buf, startOffset)
+}
+
+func (a *aliasSSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64, ctx *DecodeContext) (uint64, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			instantiateConcreteTypeForElement(val, typ.Elem())
+		}
+		return a.Unmarshal(val.Elem(), typ.Elem(), input, startOffset, ctx)
+	}
+	protoVal := reflect.New(a.protoType).Elem()
+	index, err := a.factory.Unmarshal(protoVal, a.protoType, input, startOffset, ctx)
+	if err != nil {
+		return 0, err
+	}
+	res, err := a.convertFrom(protoVal.Interface())
+	if err != nil {
+		return 0, fmt.Errorf("could not convert %v into alias type %v: %v", a.protoType, a.typ, err)
+	}
+	resVal := reflect.ValueOf(res)
+	if !resVal.IsValid() || resVal.Type() != a.typ {
+		return 0, fmt.Errorf("alias conversion for type %v returned %T", a.typ, res)
+	}
+	val.Set(resVal)
+	return index, nil
+}
+
+func (a *aliasSSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *HashContext) ([32]byte, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return a.Root(reflect.New(typ.Elem()).Elem(), typ.Elem(), fieldName, maxCapacity, ctx)
+		}
+		return a.Root(val.Elem(), typ.Elem(), fieldName, maxCapacity, ctx)
+	}
+	converted, err := a.toPrototype(val)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return a.factory.Root(converted, a.protoType, fieldName, maxCapacity, ctx)
+}
+
+func (a *aliasSSZ) toPrototype(val reflect.Value) (reflect.Value, error) {
+	res, err := a.convertTo(val.Interface())
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("could not convert alias type %v into %v: %v", a.typ, a.protoType, err)
+	}
+	resVal := reflect.ValueOf(res)
+	if !resVal.IsValid() || resVal.Type() != a.protoType {
+		return reflect.Value{}, fmt.Errorf("alias conversion for type %v returned %T, expected %v", a.typ, res, a.protoType)
+	}
+	return resVal, nil
+}
+
+func (a *aliasSSZ) size(val reflect.Value) uint64 {
+	if !isVariableSizeType(a.protoType) {
+		return determineFixedSize(reflect.New(a.protoType).Elem(), a.protoType)
+	}
+	converted, err := a.toPrototype(val)
+	if err != nil {
+		return 0
+	}
+	return determineVariableSize(converted, a.protoType)
+}
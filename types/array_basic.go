@@ -1,35 +1,109 @@
 package types
 
 import (
+	"fmt"
 	"reflect"
-	"sync"
 
-	"github.com/dgraph-io/ristretto"
+	"github.com/minio/highwayhash"
 )
 
 // BasicArraySizeCache for HashTreeRoot.
 const BasicArraySizeCache = 100000
 
-var fastSumHashKey = toBytes32([]byte("hash_fast_sum64_key"))
+var fastSumHashKey = padToBytes32([]byte("hash_fast_sum64_key"))
 
 type basicArraySSZ struct {
-	hashCache *ristretto.Cache
-	lock      sync.Mutex
+	*layerCache
+	hashCache Cache
 }
 
 func newBasicArraySSZ() *basicArraySSZ {
-	cache, _ := ristretto.NewCache(&ristretto.Config{
-		NumCounters: BasicArraySizeCache, // number of keys to track frequency of (1M).
-		MaxCost:     1 << 22,             // maximum cost of cache (3MB).
-		// 100,000 roots will take up approximately 3 MB in memory.
-		BufferItems: 64, // number of keys per Get buffer.
-	})
+	cache := newCache(func(c CacheConfig) int64 { return c.BasicArrayMaxCost })
 	return &basicArraySSZ{
-		hashCache: cache,
+		layerCache: newLayerCache(),
+		hashCache:  cache,
 	}
 }
 
+func (b *basicArraySSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *HashContext) ([32]byte, error) {
+	if f := ctx.basicArrayFactory(b); f != b {
+		return f.Root(val, typ, fieldName, maxCapacity, ctx)
+	}
+	// The key is taken before an empty slice standing for a vector of zeros
+	// is replaced with a new array, whose address differs on every call.
+	fieldName = layerKey(ctx.layerField(fieldName), val)
+	val = vectorValue(val, typ)
+	numItems := val.Len()
+	leaves := make([][]byte, numItems)
+	var factory SSZAble
+	var err error
+	if numItems > 0 {
+		factory, err = SSZFactory(val.Index(0), typ.Elem())
+		if err != nil {
+			return [32]byte{}, err
+		}
+	}
+	elemSize := packedElemSize(typ.Elem())
+	if elemSize == 0 && numItems > 0 {
+		// Elements such as fixed-size containers are hashed into roots of their
+		// own, which are cached per element along with the trie over them when
+		// hashed as a struct field.
+		leaves, err = b.elementRoots(val, typ, factory, fieldName, ctx)
+		if err != nil {
+			return [32]byte{}, err
+		}
+		if root, ok, err := b.cachedRoot(fieldName, leaves); ok {
+			return root, err
+		}
+	}
+	chunks := leaves
+	if elemSize > 0 || numItems == 0 {
+		// Basic elements, which reach this factory through aliases, are packed
+		// together rather than hashed into roots of their own.
+		for i := 0; i < numItems; i++ {
+			innerBuf := make([]byte, elemSize)
+			if _, err := factory.Marshal(val.Index(i), typ.Elem(), innerBuf, 0); err != nil {
+				return [32]byte{}, withIndex(i, err)
+			}
+			leaves[i] = innerBuf
+		}
+		chunks, err = pack(leaves)
+		if err != nil {
+			return [32]byte{}, err
+		}
+	}
+	// The key is taken over the chunks which are merkleized, so vectors whose
+	// elements differ in width are never given the root of one another.
+	hashKeyElements := make([]byte, BytesPerChunk*len(chunks))
+	for i, chunk := range chunks {
+		copy(hashKeyElements[i*BytesPerChunk:], chunk)
+	}
+	emptyKey := highwayhash.Sum(make([]byte, len(hashKeyElements)), fastSumHashKey[:])
+	hashKey := highwayhash.Sum(hashKeyElements, fastSumHashKey[:])
+	useCache := ctx.caching() && hashKey != emptyKey
+	if useCache {
+		res, ok := b.hashCache.Get(ctx.hasher().cacheKey(string(hashKey[:])))
+		if res != nil && ok {
+			return res.([32]byte), nil
+		}
+	}
+	var root [32]byte
+	if elemSize == 0 && numItems > 0 {
+		root = b.merkleize(ctx.hasher(), fieldName, leaves)
+	} else {
+		root, err = ctx.hasher().merkleize(chunks, uint64(len(chunks)), uint64(len(chunks)))
+		if err != nil {
+			return [32]byte{}, err
+		}
+	}
+	if useCache {
+		b.hashCache.Set(ctx.hasher().cacheKey(string(hashKey[:])), root, 32)
+	}
+	return root, nil
+}
+
 func (b *basicArraySSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
+	val = vectorValue(val, typ)
 	index := startOffset
 	var err error
 	if val.Len() == 0 {
@@ -42,34 +116,56 @@ func (b *basicArraySSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte,
 	for i := 0; i < val.Len(); i++ {
 		index, err = factory.Marshal(val.Index(i), typ.Elem(), buf, index)
 		if err != nil {
-			return 0, err
+			return 0, withIndex(i, err)
 		}
 	}
 	return index, nil
 }
 
-func (b *basicArraySSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64) (uint64, error) {
+func (b *basicArraySSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64, ctx *DecodeContext) (uint64, error) {
 	i := 0
 	index := startOffset
 	size := val.Len()
 	var err error
 	var factory SSZAble
+	if err := ctx.enter(); err != nil {
+		return 0, err
+	}
+	defer ctx.leave()
+	// The elements are fixed-size, so a vector which does not fit in the rest of
+	// the input is rejected before decoding any of them.
+	remaining := uint64(0)
+	if startOffset < uint64(len(input)) {
+		remaining = uint64(len(input)) - startOffset
+	}
+	if expected := determineFixedSize(val, typ); expected > remaining {
+		return 0, fmt.Errorf(
+			"input for type %v is truncated: expected %d bytes, received %d: %w",
+			typ,
+			expected,
+			remaining,
+			ErrInputTooShort,
+		)
+	}
+	pointers := val.Type().Elem().Kind() == reflect.Ptr
+	if pointers {
+		factory, err = elementFactory(typ.Elem().Elem())
+	} else {
+		factory, err = elementFactory(typ.Elem())
+	}
+	if err != nil {
+		return 0, err
+	}
 	for i < size {
-		if val.Index(i).Kind() == reflect.Ptr {
+		if err := ctx.step(1); err != nil {
+			return 0, err
+		}
+		if pointers {
 			instantiateConcreteTypeForElement(val.Index(i), typ.Elem().Elem())
-			factory, err = SSZFactory(val.Index(i), typ.Elem().Elem())
-			if err != nil {
-				return 0, err
-			}
-		} else {
-			factory, err = SSZFactory(val.Index(i), typ.Elem())
-			if err != nil {
-				return 0, err
-			}
 		}
-		index, err = factory.Unmarshal(val.Index(i), typ.Elem(), input, index)
+		index, err = factory.Unmarshal(val.Index(i), typ.Elem(), input, index, ctx)
 		if err != nil {
-			return 0, err
+			return 0, withIndex(i, err)
 		}
 		i++
 	}
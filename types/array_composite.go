@@ -1,17 +1,75 @@
 package types
 
 import (
-	"encoding/binary"
+	"fmt"
 	"reflect"
 )
 
-type compositeArraySSZ struct{}
+type compositeArraySSZ struct {
+	*layerCache
+}
 
 func newCompositeArraySSZ() *compositeArraySSZ {
-	return &compositeArraySSZ{}
+	return &compositeArraySSZ{layerCache: newLayerCache()}
+}
+
+func (b *compositeArraySSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *HashContext) ([32]byte, error) {
+	if f := ctx.compositeArrayFactory(b); f != b {
+		return f.Root(val, typ, fieldName, maxCapacity, ctx)
+	}
+	// The key is taken before an empty slice standing for a vector of zeros
+	// is replaced with a new array, whose address differs on every call.
+	fieldName = layerKey(ctx.layerField(fieldName), val)
+	val = vectorValue(val, typ)
+	var factory SSZAble
+	var err error
+	numItems := val.Len()
+	if numItems > 0 {
+		factory, err = SSZFactory(val.Index(0), typ.Elem())
+		if err != nil {
+			return [32]byte{}, err
+		}
+	}
+	if numItems > 0 && !isBasicType(typ.Elem().Kind()) {
+		// Elements are hashed into roots of their own, which are cached per
+		// element along with the trie over them when hashed as a struct field.
+		roots, err := b.elementRoots(val, typ, factory, fieldName, ctx)
+		if err != nil {
+			return [32]byte{}, err
+		}
+		return b.root(ctx.hasher(), fieldName, roots)
+	}
+	roots := make([][]byte, numItems)
+	elemSize := uint64(0)
+	if isBasicType(typ.Elem().Kind()) {
+		elemSize = determineFixedSize(val, typ.Elem())
+	} else {
+		elemSize = 32
+	}
+	limit := (uint64(val.Len())*elemSize + 31) / 32
+	for i := 0; i < val.Len(); i++ {
+		r, err := factory.Root(val.Index(i), typ.Elem(), "", 0, ctx)
+		if err != nil {
+			return [32]byte{}, withIndex(i, err)
+		}
+		roots[i] = r[:]
+	}
+	chunks, err := pack(roots)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	if val.Len() == 0 {
+		chunks = [][]byte{}
+	}
+	root, err := ctx.hasher().merkleize(chunks, uint64(len(chunks)), limit)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return root, nil
 }
 
 func (b *compositeArraySSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
+	val = vectorValue(val, typ)
 	index := startOffset
 	if val.Len() == 0 {
 		return index, nil
@@ -26,7 +84,7 @@ func (b *compositeArraySSZ) Marshal(val reflect.Value, typ reflect.Type, buf []b
 			// into the buffer at the last index we wrote at.
 			index, err = factory.Marshal(val.Index(i), typ.Elem(), buf, index)
 			if err != nil {
-				return 0, err
+				return 0, withIndex(i, err)
 			}
 		}
 		return index, nil
@@ -39,12 +97,10 @@ func (b *compositeArraySSZ) Marshal(val reflect.Value, typ reflect.Type, buf []b
 	for i := 0; i < val.Len(); i++ {
 		nextOffsetIndex, err = factory.Marshal(val.Index(i), typ.Elem(), buf, currentOffsetIndex)
 		if err != nil {
-			return 0, err
+			return 0, withIndex(i, err)
 		}
 		// Write the offset.
-		offsetBuf := make([]byte, BytesPerLengthOffset)
-		binary.LittleEndian.PutUint32(offsetBuf, uint32(currentOffsetIndex-startOffset))
-		copy(buf[fixedIndex:fixedIndex+BytesPerLengthOffset], offsetBuf)
+		writeOffset(buf, fixedIndex, currentOffsetIndex-startOffset)
 
 		// We increase the offset indices accordingly.
 		currentOffsetIndex = nextOffsetIndex
@@ -54,14 +110,36 @@ func (b *compositeArraySSZ) Marshal(val reflect.Value, typ reflect.Type, buf []b
 	return index, nil
 }
 
-func (b *compositeArraySSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64) (uint64, error) {
+func (b *compositeArraySSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64, ctx *DecodeContext) (uint64, error) {
+	if err := ctx.enter(); err != nil {
+		return 0, err
+	}
+	defer ctx.leave()
 	currentIndex := startOffset
 	nextIndex := currentIndex
-	offsetVal := input[startOffset : startOffset+BytesPerLengthOffset]
-	firstOffset := startOffset + uint64(binary.LittleEndian.Uint32(offsetVal))
+	offset, err := readOffset(input, startOffset)
+	if err != nil {
+		return 0, err
+	}
+	// The first offset is also the length of the offset table, which holds an
+	// offset for each of the elements of the vector.
+	if expected := uint64(typ.Len()) * BytesPerLengthOffset; offset != expected {
+		return 0, fmt.Errorf(
+			"input for type %v holds an offset table of %d bytes, expected %d bytes for its %d elements: %w",
+			typ,
+			offset,
+			expected,
+			typ.Len(),
+			ErrOffsetOutOfBounds,
+		)
+	}
+	firstOffset := startOffset + offset
 	currentOffset := firstOffset
 	nextOffset := currentOffset
 	endOffset := uint64(len(input))
+	if firstOffset > endOffset {
+		return 0, fmt.Errorf("offset %d of type %v is out of bounds, input has length %d: %w", offset, typ, endOffset-startOffset, ErrOffsetOutOfBounds)
+	}
 	i := 0
 	if val.Kind() == reflect.Slice {
 		instantiatedArray := reflect.MakeSlice(val.Type(), typ.Len(), typ.Len())
@@ -72,22 +150,45 @@ func (b *compositeArraySSZ) Unmarshal(val reflect.Value, typ reflect.Type, input
 		return 0, err
 	}
 	for currentIndex < firstOffset {
+		if err := ctx.step(1); err != nil {
+			return 0, err
+		}
 		nextIndex = currentIndex + BytesPerLengthOffset
 		if nextIndex == firstOffset {
 			nextOffset = endOffset
 		} else {
-			nextOffsetVal := input[nextIndex : nextIndex+BytesPerLengthOffset]
-			nextOffset = startOffset + uint64(binary.LittleEndian.Uint32(nextOffsetVal))
+			offset, err := readOffset(input, nextIndex)
+			if err != nil {
+				return 0, err
+			}
+			nextOffset = startOffset + offset
+			if nextOffset > endOffset {
+				err := fmt.Errorf("offset %d of type %v is out of bounds, input has length %d: %w", offset, typ, endOffset-startOffset, ErrOffsetOutOfBounds)
+				return 0, withIndex(i+1, err)
+			}
+		}
+		if nextOffset < currentOffset {
+			err := fmt.Errorf(
+				"offset %d of element %d of type %v is out of order, expected at least the offset %d of element %d: %w",
+				nextOffset-startOffset,
+				i+1,
+				typ,
+				currentOffset-startOffset,
+				i,
+				ErrOffsetsNotIncreasing,
+			)
+			return 0, withIndex(i+1, err)
 		}
 		if val.Index(i).Kind() == reflect.Ptr {
 			instantiateConcreteTypeForElement(val.Index(i), typ.Elem().Elem())
 		}
-		if _, err := factory.Unmarshal(val.Index(i), typ.Elem(), input[currentOffset:nextOffset], 0); err != nil {
-			return 0, err
+		if _, err := factory.Unmarshal(val.Index(i), typ.Elem(), input[currentOffset:nextOffset], 0, ctx); err != nil {
+			return 0, withIndex(i, err)
 		}
 		i++
 		currentIndex = nextIndex
 		currentOffset = nextOffset
 	}
-	return currentIndex, nil
+	// The last element extends to the end of the input.
+	return endOffset, nil
 }
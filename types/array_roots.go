@@ -1,122 +1,205 @@
 package types
 
 import (
+	"encoding/binary"
 	"fmt"
 	"reflect"
-	"sync"
 
-	"github.com/dgraph-io/ristretto"
+	"github.com/minio/highwayhash"
 )
 
 // RootsArraySizeCache for hash tree root.
 const RootsArraySizeCache = 100000
 
 type rootsArraySSZ struct {
-	hashCache    *ristretto.Cache
-	lock         sync.Mutex
-	cachedLeaves map[string][][]byte
-	layers       map[string][][][]byte
+	*layerCache
+	hashCache Cache
 }
 
 func newRootsArraySSZ() *rootsArraySSZ {
-	cache, _ := ristretto.NewCache(&ristretto.Config{
-		NumCounters: RootsArraySizeCache, // number of keys to track frequency of (100000).
-		MaxCost:     1 << 23,             // maximum cost of cache (3MB).
-		// 100,000 roots will take up approximately 3 MB in memory.
-		BufferItems: 64, // number of keys per Get buffer.
-	})
+	cache := newCache(func(c CacheConfig) int64 { return c.RootsArrayMaxCost })
 	return &rootsArraySSZ{
-		hashCache:    cache,
-		cachedLeaves: make(map[string][][]byte),
-		layers:       make(map[string][][][]byte),
+		layerCache: newLayerCache(),
+		hashCache:  cache,
 	}
 }
 
-func (a *rootsArraySSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
-	index := startOffset
-	if val.Len() == 0 {
-		return index, nil
-	}
-	for i := 0; i < val.Len(); i++ {
-		var item [32]byte
-		if res, ok := val.Index(i).Interface().([32]byte); ok {
-			item = res
-		} else if res, ok := val.Index(i).Interface().([]byte); ok {
-			item = toBytes32(res)
-		} else {
-			return 0, fmt.Errorf("expected array or slice of len 32, received %v", val.Index(i))
+func (a *rootsArraySSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *HashContext) ([32]byte, error) {
+	if f := ctx.rootsArrayFactory(a); f != a {
+		return f.Root(val, typ, fieldName, maxCapacity, ctx)
+	}
+	// The key is taken before an empty slice standing for a vector of zeros
+	// is replaced with a new array, whose address differs on every call.
+	fieldName = layerKey(ctx.layerField(fieldName), val)
+	val = vectorValue(val, typ)
+	numItems := val.Len()
+	// The rows are laid out contiguously, which gives us both the leaves and
+	// the input to the cache key without copying them twice.
+	hashKeyElements := make([]byte, BytesPerChunk*numItems)
+	if err := writeRoots(val, hashKeyElements); err != nil {
+		return [32]byte{}, err
+	}
+	emptyKey := highwayhash.Sum(make([]byte, len(hashKeyElements)), fastSumHashKey[:])
+	leaves := make([][]byte, numItems)
+	for i := 0; i < numItems; i++ {
+		leaves[i] = hashKeyElements[i*32 : (i+1)*32]
+	}
+	// We make sure to look into the layer cache only if a field name is provided,
+	// that is, if this function is called when calling HashTreeRoot on a struct type
+	// that has a field which is an array of roots. An example is:
+	//
+	// type BeaconState struct {
+	//   BlockRoots [2048][32]byte
+	// }
+	//
+	// which would allow us to look into the cache by the field "BlockRoots".
+	// If so, we recompute the root from the modified branches from the previous
+	// call to this function.
+	if root, ok, err := a.cachedRoot(fieldName, leaves); ok {
+		return root, err
+	}
+	hashKey := highwayhash.Sum(hashKeyElements, fastSumHashKey[:])
+	useCache := ctx.caching() && hashKey != emptyKey
+	if useCache {
+		res, ok := a.hashCache.Get(ctx.hasher().cacheKey(string(hashKey[:])))
+		if res != nil && ok {
+			return res.([32]byte), nil
 		}
-		copy(buf[index:index+uint64(len(item))], item[:])
-		index += uint64(len(item))
 	}
-	return index, nil
+	root := a.merkleize(ctx.hasher(), fieldName, leaves)
+	if useCache {
+		a.hashCache.Set(ctx.hasher().cacheKey(string(hashKey[:])), root, 32)
+	}
+	return root, nil
 }
 
-func (a *rootsArraySSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64) (uint64, error) {
-	i := 0
-	index := startOffset
-	for i < val.Len() {
-		val.Index(i).SetBytes(input[index : index+uint64(32)])
-		index += uint64(32)
-		i++
+// RootOfRootList returns the hash tree root of a list of at most limit roots,
+// given as the 32-byte roots laid out back to back in flat, as if it held a
+// [][32]byte list with an ssz-max tag of limit.
+func RootOfRootList(flat []byte, limit uint64) ([32]byte, error) {
+	leaves, err := rootLeaves(flat)
+	if err != nil {
+		return [32]byte{}, err
 	}
-	return index, nil
+	if uint64(len(leaves)) > limit {
+		return [32]byte{}, fmt.Errorf("list holds %d roots, more than its limit of %d", len(leaves), limit)
+	}
+	root, err := bitwiseMerkleize(leaves, uint64(len(leaves)), limit)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	output := make([]byte, 32)
+	binary.LittleEndian.PutUint64(output, uint64(len(leaves)))
+	return mixInLength(root, output), nil
 }
 
-func (a *rootsArraySSZ) recomputeRoot(idx int, chunks [][]byte, fieldName string) [32]byte {
-	root := chunks[idx]
-	for i := 0; i < len(a.layers[fieldName])-1; i++ {
-		subIndex := (uint64(idx) / (1 << uint64(i))) ^ 1
-		isLeft := uint64(idx) / (1 << uint64(i))
-		parentIdx := uint64(idx) / (1 << uint64(i+1))
-		item := a.layers[fieldName][i][subIndex]
-		if isLeft%2 != 0 {
-			parentHash := hash(append(item, root...))
-			root = parentHash[:]
-		} else {
-			parentHash := hash(append(root, item...))
-			root = parentHash[:]
-		}
-		// Update the cached layers at the parent index.
-		a.layers[fieldName][i+1][parentIdx] = root
+// RootOfRootVector returns the hash tree root of a vector of length roots,
+// given as the 32-byte roots laid out back to back in flat, as if it held a
+// [length][32]byte array.
+func RootOfRootVector(flat []byte, length uint64) ([32]byte, error) {
+	leaves, err := rootLeaves(flat)
+	if err != nil {
+		return [32]byte{}, err
 	}
-	return toBytes32(root)
+	if uint64(len(leaves)) != length {
+		return [32]byte{}, fmt.Errorf("vector holds %d roots, expected %d", len(leaves), length)
+	}
+	return bitwiseMerkleize(leaves, length, length)
 }
 
-func (a *rootsArraySSZ) merkleize(chunks [][]byte, fieldName string) [32]byte {
-	if len(chunks) == 1 {
-		var root [32]byte
-		copy(root[:], chunks[0])
-		return root
-	}
-	for !isPowerOf2(len(chunks)) {
-		chunks = append(chunks, make([]byte, BytesPerChunk))
-	}
-	hashLayer := chunks
-	if enableCache && fieldName != "" {
-		a.layers[fieldName][0] = hashLayer
-	}
-	// We keep track of the hash layers of a Merkle trie until we reach
-	// the top layer of length 1, which contains the single root element.
-	//        [Root]      -> Top layer has length 1.
-	//    [E]       [F]   -> This layer has length 2.
-	// [A]  [B]  [C]  [D] -> The bottom layer has length 4 (needs to be a power of two).
-	i := 1
-	for len(hashLayer) > 1 {
-		layer := [][]byte{}
-		for i := 0; i < len(hashLayer); i += 2 {
-			hashedChunk := hash(append(hashLayer[i], hashLayer[i+1]...))
-			layer = append(layer, hashedChunk[:])
+// rootLeaves splits flat into its 32-byte roots, without copying them.
+func rootLeaves(flat []byte) ([][]byte, error) {
+	if len(flat)%BytesPerChunk != 0 {
+		return nil, fmt.Errorf("roots are %d bytes long, which is not a multiple of %d", len(flat), BytesPerChunk)
+	}
+	leaves := make([][]byte, len(flat)/BytesPerChunk)
+	for i := range leaves {
+		leaves[i] = flat[i*BytesPerChunk : (i+1)*BytesPerChunk]
+	}
+	return leaves, nil
+}
+
+func (a *rootsArraySSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
+	val = vectorValue(val, typ)
+	end := startOffset + uint64(val.Len())*32
+	if err := writeRoots(val, buf[startOffset:end]); err != nil {
+		return 0, err
+	}
+	return end, nil
+}
+
+// writeRoots copies the 32 byte rows of an array or slice of roots into buf,
+// which must be exactly 32 bytes per row long.
+func writeRoots(val reflect.Value, buf []byte) error {
+	if val.Len() == 0 {
+		return nil
+	}
+	index := 0
+	// We determine the shape of the rows once rather than per element, as type assertions
+	// via Interface() would box every single root into a heap allocation.
+	elemTyp := val.Type().Elem()
+	switch {
+	case elemTyp.Kind() == reflect.Array && elemTyp.Elem().Kind() == reflect.Uint8 && elemTyp.Len() == 32:
+		var row [32]byte
+		rowVal := reflect.ValueOf(&row).Elem()
+		for i := 0; i < val.Len(); i++ {
+			reflect.Copy(rowVal, val.Index(i))
+			copy(buf[index:index+32], row[:])
+			index += 32
 		}
-		hashLayer = layer
-		if enableCache && fieldName != "" {
-			a.layers[fieldName][i] = hashLayer
+	case elemTyp.Kind() == reflect.Slice && elemTyp.Elem().Kind() == reflect.Uint8:
+		var emptyRow [32]byte
+		for i := 0; i < val.Len(); i++ {
+			item := val.Index(i).Bytes()
+			// Unset rows are marshaled as zero roots, while any other length than 32
+			// means the root is corrupt and must not be silently padded or truncated.
+			if len(item) == 0 {
+				item = emptyRow[:]
+			}
+			row, err := ToBytes32Strict(item)
+			if err != nil {
+				return fmt.Errorf("root at index %d is invalid: %v", i, err)
+			}
+			copy(buf[index:index+32], row[:])
+			index += 32
 		}
+	default:
+		return fmt.Errorf("expected array or slice of len 32, received %v", elemTyp)
+	}
+	return nil
+}
+
+func (a *rootsArraySSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64, ctx *DecodeContext) (uint64, error) {
+	i := 0
+	index := startOffset
+	// The rows are 32 bytes each, so a vector which does not fit in the rest of
+	// the input is rejected before copying any of them.
+	remaining := uint64(0)
+	if startOffset < uint64(len(input)) {
+		remaining = uint64(len(input)) - startOffset
+	}
+	if expected := uint64(val.Len()) * 32; expected > remaining {
+		return 0, fmt.Errorf(
+			"input for type %v is truncated: expected %d bytes, received %d: %w",
+			typ,
+			expected,
+			remaining,
+			ErrInputTooShort,
+		)
+	}
+	for i < val.Len() {
+		row := val.Index(i)
+		if row.Kind() == reflect.Array {
+			// Fixed-size rows such as [32]byte cannot be assigned a byte slice,
+			// so the bytes are copied into them instead.
+			reflect.Copy(row, reflect.ValueOf(input[index:index+uint64(32)]))
+		} else {
+			row.SetBytes(input[index : index+uint64(32)])
+		}
+		index += uint64(32)
 		i++
 	}
-	var root [32]byte
-	copy(root[:], hashLayer[0])
-	return root
+	return index, nil
 }
 
 func isPowerOf2(n int) bool {
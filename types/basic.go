@@ -5,25 +5,18 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
-
-	"github.com/dgraph-io/ristretto"
 )
 
 // BasicTypeCacheSize for HashTreeRoot.
 const BasicTypeCacheSize = 100000
 
 type basicSSZ struct {
-	hashCache *ristretto.Cache
+	hashCache Cache
 	lock      sync.Mutex
 }
 
 func newBasicSSZ() *basicSSZ {
-	cache, _ := ristretto.NewCache(&ristretto.Config{
-		NumCounters: BasicTypeCacheSize, // number of keys to track frequency of (100K).
-		MaxCost:     1 << 23,            // maximum cost of cache (3MB).
-		// 100,000 roots will take up approximately 3 MB in memory.
-		BufferItems: 64, // number of keys per Get buffer.
-	})
+	cache := newCache(func(c CacheConfig) int64 { return c.BasicMaxCost })
 	return &basicSSZ{
 		hashCache: cache,
 	}
@@ -32,6 +25,12 @@ func newBasicSSZ() *basicSSZ {
 func (b *basicSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
 	kind := typ.Kind()
 	switch {
+	case kind == reflect.Ptr:
+		// Nil pointers are marshaled as the zero value of the type they point to.
+		if val.IsNil() {
+			return b.Marshal(reflect.New(typ.Elem()).Elem(), typ.Elem(), buf, startOffset)
+		}
+		return b.Marshal(val.Elem(), typ.Elem(), buf, startOffset)
 	case kind == reflect.Bool:
 		return marshalBool(val, buf, startOffset)
 	case kind == reflect.Uint8:
@@ -49,17 +48,22 @@ func (b *basicSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, star
 	case kind == reflect.Array && isBasicType(typ.Elem().Kind()):
 		return b.marshalBasicArray(val, typ, buf, startOffset)
 	default:
-		return 0, fmt.Errorf("type %v is not serializable", val.Type())
+		return 0, fmt.Errorf("type %v is not serializable: %w", val.Type(), ErrUnsupportedType)
 	}
 }
 
-func (b *basicSSZ) Unmarshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
+func (b *basicSSZ) Unmarshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64, ctx *DecodeContext) (uint64, error) {
 	if startOffset >= uint64(len(buf)) {
-		return 0, fmt.Errorf("startOffset %d is greater than length of input %d", startOffset, len(buf))
+		return 0, fmt.Errorf("startOffset %d is greater than length of input %d: %w", startOffset, len(buf), ErrInputTooShort)
 	}
 
 	kind := typ.Kind()
 	switch {
+	case kind == reflect.Ptr:
+		if val.IsNil() {
+			instantiateConcreteTypeForElement(val, typ.Elem())
+		}
+		return b.Unmarshal(val.Elem(), typ.Elem(), buf, startOffset, ctx)
 	case kind == reflect.Bool:
 		return unmarshalBool(val, typ, buf, startOffset)
 	case kind == reflect.Uint8:
@@ -75,29 +79,32 @@ func (b *basicSSZ) Unmarshal(val reflect.Value, typ reflect.Type, buf []byte, st
 	case kind == reflect.Slice && typ.Elem().Kind() == reflect.Uint8:
 		return unmarshalByteArray(val, typ, buf, startOffset)
 	case kind == reflect.Array && isBasicType(typ.Elem().Kind()):
-		return basicArrayFactory.Unmarshal(val, typ, buf, startOffset)
+		return basicArrayFactory.Unmarshal(val, typ, buf, startOffset, ctx)
 	default:
-		return 0, fmt.Errorf("type %v is not serializable", val.Type())
+		return 0, fmt.Errorf("type %v is not serializable: %w", val.Type(), ErrUnsupportedType)
 	}
 }
 
-func (b *basicSSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64) ([32]byte, error) {
+func (b *basicSSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *HashContext) ([32]byte, error) {
+	if f := ctx.basicFactory(b); f != b {
+		return f.Root(val, typ, fieldName, maxCapacity, ctx)
+	}
 	var chunks [][]byte
 	var err error
 	var hashKey string
-	newVal := reflect.New(val.Type()).Elem()
-	newVal.Set(val)
-	if val.Type().Kind() == reflect.Slice && val.IsNil() {
-		newVal.Set(reflect.MakeSlice(val.Type(), typ.Len(), typ.Len()))
-	}
-	buf := make([]byte, DetermineSize(newVal))
+	newVal := vectorValue(val, typ)
+	buf := make([]byte, determineFixedSize(newVal, typ))
 	if _, err := b.Marshal(newVal, typ, buf, 0); err != nil {
 		return [32]byte{}, err
 	}
-	hashKey = string(buf)
-	res, ok := b.hashCache.Get(string(hashKey))
-	if res != nil && ok {
-		return res.([32]byte), nil
+	hashKey = ctx.hasher().cacheKey(string(buf))
+	// Basic values are cached unless the computation disabled caching itself.
+	useCache := ctx == nil || ctx.cache != cacheOff
+	if useCache {
+		res, ok := b.hashCache.Get(string(hashKey))
+		if res != nil && ok {
+			return res.([32]byte), nil
+		}
 	}
 
 	// In order to find the root of a basic type, we simply marshal it,
@@ -107,15 +114,18 @@ func (b *basicSSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, m
 	if err != nil {
 		return [32]byte{}, err
 	}
-	root, err := bitwiseMerkleize(chunks, uint64(len(chunks)), uint64(len(chunks)))
+	root, err := ctx.hasher().merkleize(chunks, uint64(len(chunks)), uint64(len(chunks)))
 	if err != nil {
 		return [32]byte{}, err
 	}
-	b.hashCache.Set(string(hashKey), root, 32)
+	if useCache {
+		b.hashCache.Set(string(hashKey), root, 32)
+	}
 	return root, nil
 }
 
 func (b *basicSSZ) marshalBasicArray(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
+	val = vectorValue(val, typ)
 	index := startOffset
 	var err error
 	for i := 0; i < val.Len(); i++ {
@@ -134,7 +144,7 @@ func marshalByteArray(val reflect.Value, typ reflect.Type, buf []byte, startOffs
 		}
 		return startOffset + uint64(val.Len()), nil
 	}
-	if val.IsNil() {
+	if val.Len() == 0 {
 		item := make([]byte, typ.Len())
 		copy(buf[startOffset:], item)
 		return startOffset + uint64(typ.Len()), nil
@@ -149,8 +159,22 @@ func unmarshalByteArray(val reflect.Value, typ reflect.Type, input []byte, start
 	return offset, nil
 }
 
+// checkBasicInput verifies the input holds the size bytes of a value of type
+// typ at startOffset, so that truncated input is rejected rather than sliced
+// out of range.
+func checkBasicInput(typ reflect.Type, input []byte, startOffset uint64, size uint64) error {
+	if startOffset+size > uint64(len(input)) {
+		received := uint64(0)
+		if startOffset < uint64(len(input)) {
+			received = uint64(len(input)) - startOffset
+		}
+		return fmt.Errorf("input for type %v is truncated: expected %d bytes, received %d: %w", typ, size, received, ErrInputTooShort)
+	}
+	return nil
+}
+
 func marshalBool(val reflect.Value, buf []byte, startOffset uint64) (uint64, error) {
-	if val.Interface().(bool) {
+	if val.Bool() {
 		buf[startOffset] = uint8(1)
 	} else {
 		buf[startOffset] = uint8(0)
@@ -159,6 +183,9 @@ func marshalBool(val reflect.Value, buf []byte, startOffset uint64) (uint64, err
 }
 
 func unmarshalBool(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64) (uint64, error) {
+	if err := checkBasicInput(typ, input, startOffset, 1); err != nil {
+		return 0, err
+	}
 	v := input[startOffset]
 	if v == 0 {
 		val.SetBool(false)
@@ -171,63 +198,74 @@ func unmarshalBool(val reflect.Value, typ reflect.Type, input []byte, startOffse
 }
 
 func marshalUint8(val reflect.Value, buf []byte, startOffset uint64) (uint64, error) {
-	buf[startOffset] = val.Interface().(uint8)
+	buf[startOffset] = uint8(val.Uint())
 	return startOffset + 1, nil
 }
 
 func unmarshalUint8(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64) (uint64, error) {
+	if err := checkBasicInput(typ, input, startOffset, 1); err != nil {
+		return 0, err
+	}
 	val.SetUint(uint64(input[startOffset]))
 	return startOffset + 1, nil
 }
 
 func marshalUint16(val reflect.Value, buf []byte, startOffset uint64) (uint64, error) {
-	binary.LittleEndian.PutUint16(buf[startOffset:], val.Interface().(uint16))
+	binary.LittleEndian.PutUint16(buf[startOffset:], uint16(val.Uint()))
 	return startOffset + 2, nil
 }
 
 func unmarshalUint16(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64) (uint64, error) {
+	if err := checkBasicInput(typ, input, startOffset, 2); err != nil {
+		return 0, err
+	}
 	offset := startOffset + 2
-	buf := make([]byte, 2)
-	copy(buf, input[startOffset:offset])
+	buf := input[startOffset:offset]
 	val.SetUint(uint64(binary.LittleEndian.Uint16(buf)))
 	return offset, nil
 }
 
 func marshalInt32(val reflect.Value, buf []byte, startOffset uint64) (uint64, error) {
-	binary.LittleEndian.PutUint32(buf[startOffset:], uint32(val.Interface().(int32)))
+	binary.LittleEndian.PutUint32(buf[startOffset:], uint32(val.Int()))
 	return startOffset + 4, nil
 }
 
 func unmarshalInt32(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64) (uint64, error) {
+	if err := checkBasicInput(typ, input, startOffset, 4); err != nil {
+		return 0, err
+	}
 	offset := startOffset + 4
-	buf := make([]byte, 4)
-	copy(buf, input[startOffset:offset])
+	buf := input[startOffset:offset]
 	val.SetInt(int64(binary.LittleEndian.Uint32(buf)))
 	return offset, nil
 }
 
 func marshalUint32(val reflect.Value, buf []byte, startOffset uint64) (uint64, error) {
-	binary.LittleEndian.PutUint32(buf[startOffset:], val.Interface().(uint32))
+	binary.LittleEndian.PutUint32(buf[startOffset:], uint32(val.Uint()))
 	return startOffset + 4, nil
 }
 
 func unmarshalUint32(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64) (uint64, error) {
+	if err := checkBasicInput(typ, input, startOffset, 4); err != nil {
+		return 0, err
+	}
 	offset := startOffset + 4
-	buf := make([]byte, 4)
-	copy(buf, input[startOffset:offset])
+	buf := input[startOffset:offset]
 	val.SetUint(uint64(binary.LittleEndian.Uint32(buf)))
 	return offset, nil
 }
 
 func marshalUint64(val reflect.Value, buf []byte, startOffset uint64) (uint64, error) {
-	binary.LittleEndian.PutUint64(buf[startOffset:], val.Interface().(uint64))
+	binary.LittleEndian.PutUint64(buf[startOffset:], val.Uint())
 	return startOffset + 8, nil
 }
 
 func unmarshalUint64(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64) (uint64, error) {
+	if err := checkBasicInput(typ, input, startOffset, 8); err != nil {
+		return 0, err
+	}
 	offset := startOffset + 8
-	buf := make([]byte, 8)
-	copy(buf, input[startOffset:offset])
+	buf := input[startOffset:offset]
 	val.SetUint(binary.LittleEndian.Uint64(buf))
 	return offset, nil
 }
@@ -0,0 +1,197 @@
+package types
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"reflect"
+)
+
+// Bitlist is a list of bits, such as the aggregation bits of attestations. Its
+// bytes hold the bits from the least significant bit of the first byte on,
+// followed by a delimiting bit set right after the last one, which marks the
+// length of the list. The limit of a Bitlist field is declared by its ssz-max
+// tag, in bits.
+type Bitlist []byte
+
+// NewBitlist returns a Bitlist of n bits, all of which are unset.
+func NewBitlist(n uint64) Bitlist {
+	b := make(Bitlist, n/8+1)
+	b[n/8] = 1 << (n % 8)
+	return b
+}
+
+// Len returns the number of bits of the list, which is zero when the list has
+// no delimiting bit.
+func (b Bitlist) Len() uint64 {
+	n, _ := bitlistLen(b)
+	return n
+}
+
+// BitAt returns whether the bit at index i is set, which is false for indices
+// beyond the length of the list.
+func (b Bitlist) BitAt(i uint64) bool {
+	if i >= b.Len() {
+		return false
+	}
+	return b[i/8]&(1<<(i%8)) != 0
+}
+
+// SetBitAt sets the bit at index i to v, leaving the list unchanged when i is
+// beyond its length.
+func (b Bitlist) SetBitAt(i uint64, v bool) {
+	if i >= b.Len() {
+		return
+	}
+	if v {
+		b[i/8] |= 1 << (i % 8)
+	} else {
+		b[i/8] &^= 1 << (i % 8)
+	}
+}
+
+// bitlistLen returns the number of bits held by the encoding of a bitlist,
+// which is the index of its delimiting bit, along with whether it has one.
+func bitlistLen(b []byte) (uint64, bool) {
+	if len(b) == 0 || b[len(b)-1] == 0 {
+		return 0, false
+	}
+	return uint64(len(b)-1)*8 + uint64(bits.Len8(b[len(b)-1])) - 1, true
+}
+
+var bitlistType = reflect.TypeOf(Bitlist{})
+
+// isBitlistType reports whether typ is Bitlist or the Bitlist type of
+// github.com/prysmaticlabs/go-bitfield, which have the same encoding.
+func isBitlistType(typ reflect.Type) bool {
+	if typ == bitlistType {
+		return true
+	}
+	return typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8 &&
+		typ.Name() == "Bitlist" && typ.PkgPath() == "github.com/prysmaticlabs/go-bitfield"
+}
+
+// checkBitlistCapacity verifies the value of a field fits the capacity declared
+// by its ssz-max tag, if any, when it is a bitlist.
+func checkBitlistCapacity(field reflect.StructField, capacity uint64, val reflect.Value) error {
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	if !isBitlistType(val.Type()) {
+		return nil
+	}
+	if n, _ := bitlistLen(val.Bytes()); capacity > 0 && n > capacity {
+		return fmt.Errorf("bitlist field %s has %d bits, exceeding its ssz-max of %d", field.Name, n, capacity)
+	}
+	return nil
+}
+
+// bitlistSize returns the size of the encoding of the bitlist val, where an
+// empty value is marshaled as a bitlist of no bits.
+func bitlistSize(val reflect.Value) uint64 {
+	if val.Len() == 0 {
+		return 1
+	}
+	return uint64(val.Len())
+}
+
+type bitlistSSZ struct{}
+
+func newBitlistSSZ() *bitlistSSZ {
+	return &bitlistSSZ{}
+}
+
+func (b *bitlistSSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *HashContext) ([32]byte, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return b.Root(reflect.New(typ.Elem()).Elem(), typ.Elem(), fieldName, maxCapacity, ctx)
+		}
+		return b.Root(val.Elem(), typ.Elem(), fieldName, maxCapacity, ctx)
+	}
+	// The root of a bitlist depends on its limit, like those of other lists.
+	if maxCapacity == 0 {
+		if fieldName == "" {
+			return [32]byte{}, fmt.Errorf("cannot hash a bitlist without an ssz-max limit")
+		}
+		return [32]byte{}, fmt.Errorf("cannot hash bitlist field %s without an ssz-max limit", fieldName)
+	}
+	enc := val.Bytes()
+	n, ok := bitlistLen(enc)
+	if !ok && len(enc) > 0 {
+		return [32]byte{}, fmt.Errorf("bitlist %#x has no delimiting bit", enc)
+	}
+	if n > maxCapacity {
+		return [32]byte{}, fmt.Errorf("bitlist of %d bits exceeds its ssz-max of %d", n, maxCapacity)
+	}
+	// The bits are hashed without the delimiting bit, whose position is mixed
+	// in as the length instead.
+	bitBytes := make([]byte, (n+7)/8)
+	copy(bitBytes, enc)
+	if n%8 != 0 {
+		bitBytes[n/8] &^= 1 << (n % 8)
+	}
+	chunks, err := pack([][]byte{bitBytes})
+	if err != nil {
+		return [32]byte{}, err
+	}
+	limit := (maxCapacity + 255) / 256
+	merkleRoot, err := ctx.hasher().merkleize(chunks, uint64(len(chunks)), limit)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	output := make([]byte, 32)
+	binary.LittleEndian.PutUint64(output, n)
+	return ctx.hasher().mixInLength(merkleRoot, output), nil
+}
+
+// Marshal writes the bitlist val, which must end with its delimiting bit. Empty
+// values are written as a bitlist of no bits.
+func (b *bitlistSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return b.Marshal(reflect.New(typ.Elem()).Elem(), typ.Elem(), buf, startOffset)
+		}
+		return b.Marshal(val.Elem(), typ.Elem(), buf, startOffset)
+	}
+	enc := val.Bytes()
+	if len(enc) == 0 {
+		enc = []byte{1}
+	}
+	if _, ok := bitlistLen(enc); !ok {
+		return 0, fmt.Errorf("bitlist %#x has no delimiting bit", enc)
+	}
+	end := startOffset + uint64(len(enc))
+	if end > uint64(len(buf)) {
+		return 0, fmt.Errorf("buffer of %d bytes is too small for a bitlist of %d bytes at offset %d", len(buf), len(enc), startOffset)
+	}
+	copy(buf[startOffset:end], enc)
+	return end, nil
+}
+
+// Unmarshal decodes the bitlist from the rest of the input after startOffset,
+// which bitlists always extend to the end of, rejecting inputs which do not end
+// with a delimiting bit.
+func (b *bitlistSSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64, ctx *DecodeContext) (uint64, error) {
+	if typ.Kind() == reflect.Ptr {
+		instantiateField(val, typ.Elem())
+		return b.Unmarshal(val.Elem(), typ.Elem(), input, startOffset, ctx)
+	}
+	if startOffset > uint64(len(input)) {
+		return 0, fmt.Errorf("offset %d of type %v is out of bounds, input has length %d: %w", startOffset, typ, len(input), ErrOffsetOutOfBounds)
+	}
+	enc := input[startOffset:]
+	if len(enc) == 0 {
+		return 0, fmt.Errorf("bitlist %v holds no bytes, expected at least its delimiting bit: %w", typ, ErrInputTooShort)
+	}
+	if enc[len(enc)-1] == 0 {
+		return 0, fmt.Errorf("bitlist %v ends with a zero byte, which has no delimiting bit", typ)
+	}
+	if err := ctx.alloc(uint64(len(enc))); err != nil {
+		return 0, err
+	}
+	val.SetBytes(append([]byte{}, enc...))
+	return uint64(len(input)), nil
+}
@@ -0,0 +1,84 @@
+package types
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestBitlist_Bits(t *testing.T) {
+	for _, n := range []uint64{0, 1, 7, 8, 9, 16, 2048} {
+		b := NewBitlist(n)
+		if b.Len() != n {
+			t.Errorf("NewBitlist(%d) has %d bits", n, b.Len())
+		}
+		if uint64(len(b)) != n/8+1 {
+			t.Errorf("NewBitlist(%d) has %d bytes, expected %d", n, len(b), n/8+1)
+		}
+		for i := uint64(0); i < n; i++ {
+			b.SetBitAt(i, true)
+		}
+		for i := uint64(0); i < n; i++ {
+			if !b.BitAt(i) {
+				t.Errorf("Bit %d of %d is unset", i, n)
+			}
+		}
+		// Setting bits beyond the length leaves the delimiting bit as it is.
+		b.SetBitAt(n, false)
+		b.SetBitAt(n+1, true)
+		if b.Len() != n || b.BitAt(n) {
+			t.Errorf("Setting bits beyond %d changed the length to %d", n, b.Len())
+		}
+		b.SetBitAt(0, false)
+		if n > 0 && b.BitAt(0) {
+			t.Errorf("Bit 0 of %d is still set", n)
+		}
+	}
+	if n := (Bitlist{}).Len(); n != 0 {
+		t.Errorf("Empty bitlist has %d bits", n)
+	}
+	if n := (Bitlist{0xff, 0x00}).Len(); n != 0 {
+		t.Errorf("Bitlist without delimiting bit has %d bits", n)
+	}
+}
+
+func TestBitlistUnmarshal_CopiesInput(t *testing.T) {
+	input := []byte{0xaa, 0x05}
+	var b Bitlist
+	end, err := newBitlistSSZ().Unmarshal(reflect.ValueOf(&b).Elem(), reflect.TypeOf(b), input, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if end != uint64(len(input)) || !bytes.Equal(b, []byte{0x05}) {
+		t.Errorf("Decoded %#x ending at %d, expected 0x05 ending at %d", b, end, len(input))
+	}
+	input[1] = 0x07
+	if b[0] != 0x05 {
+		t.Error("Decoded bitlist shares its bytes with the input")
+	}
+}
+
+func TestBitlist_Schema(t *testing.T) {
+	type attestation struct {
+		AggregationBits Bitlist `ssz-max:"2048"`
+		Slot            uint64
+	}
+	schema, err := Describe(reflect.TypeOf(attestation{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := schema.String(); s != "container{AggregationBits: bitlist[2048], Slot: uint64}" {
+		t.Errorf("Unexpected schema %s", s)
+	}
+	size, err := MaxSize(reflect.TypeOf(attestation{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An offset, 2048 bits followed by the delimiting bit, and the slot.
+	if size != 4+257+8 {
+		t.Errorf("Maximum size is %d, expected %d", size, 4+257+8)
+	}
+	if _, err := MaxSize(bitlistType); err == nil {
+		t.Error("Expected a bitlist without limit to have no maximum size")
+	}
+}
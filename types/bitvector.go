@@ -0,0 +1,194 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Bitvector is a vector of bits, such as the justification bits of beacon
+// states. Its bytes hold the bits from the least significant bit of the first
+// byte on, and the bits of the last byte beyond its length are unset. The length
+// of a Bitvector field is declared by its ssz-size tag, in bits rather than in
+// bytes, so that a Bitvector of 4 bits is tagged ssz-size:"4".
+type Bitvector []byte
+
+// NewBitvector returns a Bitvector of n bits, all of which are unset.
+func NewBitvector(n uint64) Bitvector {
+	return make(Bitvector, (n+7)/8)
+}
+
+// BitAt returns whether the bit at index i is set, which is false for indices
+// beyond the bytes of the vector.
+func (b Bitvector) BitAt(i uint64) bool {
+	if i/8 >= uint64(len(b)) {
+		return false
+	}
+	return b[i/8]&(1<<(i%8)) != 0
+}
+
+// SetBitAt sets the bit at index i to v, leaving the vector unchanged when i is
+// beyond its bytes.
+func (b Bitvector) SetBitAt(i uint64, v bool) {
+	if i/8 >= uint64(len(b)) {
+		return
+	}
+	if v {
+		b[i/8] |= 1 << (i % 8)
+	} else {
+		b[i/8] &^= 1 << (i % 8)
+	}
+}
+
+// bitvectorBit is the element type of the types standing for Bitvector fields,
+// which are arrays with one element per bit, so that the length of a field is
+// held by its type like that of other vectors.
+type bitvectorBit bool
+
+var bitvectorType = reflect.TypeOf(Bitvector{})
+var bitvectorBitType = reflect.TypeOf(bitvectorBit(false))
+
+// isBitvectorType reports whether typ stands for a Bitvector field of a length
+// declared by its ssz-size tag.
+func isBitvectorType(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Array && typ.Elem() == bitvectorBitType
+}
+
+// bitvectorFieldType returns the type standing for a Bitvector field, pointers
+// to which are kept, with the length in bits declared by its ssz-size tag.
+func bitvectorFieldType(field reflect.StructField) (reflect.Type, error) {
+	tag, ok := field.Tag.Lookup("ssz-size")
+	if !ok {
+		return nil, fmt.Errorf("bitvector field %s has no length, declare one in bits with an ssz-size tag", field.Name)
+	}
+	n, err := strconv.ParseUint(tag, 10, 32)
+	if err != nil || n == 0 {
+		return nil, fmt.Errorf("bitvector field %s has an ssz-size tag of %q, expected a positive number of bits", field.Name, tag)
+	}
+	_, pointers := stripPointers(field.Type)
+	typ := reflect.ArrayOf(int(n), bitvectorBitType)
+	for i := 0; i < pointers; i++ {
+		typ = reflect.PtrTo(typ)
+	}
+	return typ, nil
+}
+
+// bitvectorSize returns the size of the encoding of the bitvectors of type typ.
+func bitvectorSize(typ reflect.Type) uint64 {
+	return (uint64(typ.Len()) + 7) / 8
+}
+
+// bitvectorBytes returns the bytes of the bitvector val of type typ, which are
+// unset when val is empty, or is the zero value of typ itself.
+func bitvectorBytes(val reflect.Value, typ reflect.Type) ([]byte, error) {
+	size := bitvectorSize(typ)
+	if val.Kind() != reflect.Slice || val.Len() == 0 {
+		return make([]byte, size), nil
+	}
+	enc := val.Bytes()
+	if uint64(len(enc)) != size {
+		return nil, fmt.Errorf("bitvector of %d bits has %d bytes, expected %d", typ.Len(), len(enc), size)
+	}
+	if err := checkBitvectorPadding(enc, uint64(typ.Len())); err != nil {
+		return nil, err
+	}
+	return enc, nil
+}
+
+// checkBitvectorPadding verifies the bits of enc beyond the first n are unset.
+func checkBitvectorPadding(enc []byte, n uint64) error {
+	if n%8 != 0 && enc[len(enc)-1]>>(n%8) != 0 {
+		return fmt.Errorf("bitvector of %d bits has bits set beyond its length in its last byte %#02x", n, enc[len(enc)-1])
+	}
+	return nil
+}
+
+type bitvectorSSZ struct{}
+
+func newBitvectorSSZ() *bitvectorSSZ {
+	return &bitvectorSSZ{}
+}
+
+func (b *bitvectorSSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *HashContext) ([32]byte, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return b.Root(reflect.New(typ.Elem()).Elem(), typ.Elem(), fieldName, maxCapacity, ctx)
+		}
+		return b.Root(val.Elem(), typ.Elem(), fieldName, maxCapacity, ctx)
+	}
+	enc, err := bitvectorBytes(val, typ)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	chunks, err := pack([][]byte{enc})
+	if err != nil {
+		return [32]byte{}, err
+	}
+	// Bitvectors are hashed as exactly as many chunks as their bits fill.
+	limit := (uint64(typ.Len()) + 255) / 256
+	return ctx.hasher().merkleize(chunks, uint64(len(chunks)), limit)
+}
+
+// BitvectorRoot returns the hash tree root of the Bitvector val, or of the one
+// it points to, outside of a struct field. It is the root of a field of any of
+// the lengths its bytes can hold, as bitvectors are hashed as the chunks their
+// bytes fill, so no length in bits is needed.
+func BitvectorRoot(val reflect.Value, ctx *HashContext) ([32]byte, error) {
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			val = reflect.New(val.Type().Elem()).Elem()
+			continue
+		}
+		val = val.Elem()
+	}
+	if val.Type() != bitvectorType {
+		return [32]byte{}, fmt.Errorf("expected a bitvector, received %v", val.Type())
+	}
+	if val.Len() == 0 {
+		return [32]byte{}, fmt.Errorf("bitvector has no bits, expected at least one byte")
+	}
+	return bitvectorFactory.Root(val, reflect.ArrayOf(8*val.Len(), bitvectorBitType), "", 0, ctx)
+}
+
+// Marshal writes the bitvector val, rejecting values which are not of the size
+// of typ or have bits set beyond its length. Empty values are written as unset
+// bits.
+func (b *bitvectorSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return b.Marshal(reflect.New(typ.Elem()).Elem(), typ.Elem(), buf, startOffset)
+		}
+		return b.Marshal(val.Elem(), typ.Elem(), buf, startOffset)
+	}
+	enc, err := bitvectorBytes(val, typ)
+	if err != nil {
+		return 0, err
+	}
+	end := startOffset + uint64(len(enc))
+	if end > uint64(len(buf)) {
+		return 0, fmt.Errorf("buffer of %d bytes is too small for a bitvector of %d bytes at offset %d", len(buf), len(enc), startOffset)
+	}
+	copy(buf[startOffset:end], enc)
+	return end, nil
+}
+
+// Unmarshal decodes the bitvector at startOffset, rejecting inputs with bits
+// set beyond the length of typ.
+func (b *bitvectorSSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64, ctx *DecodeContext) (uint64, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		return b.Unmarshal(val.Elem(), typ.Elem(), input, startOffset, ctx)
+	}
+	size := bitvectorSize(typ)
+	if startOffset+size > uint64(len(input)) {
+		return 0, fmt.Errorf("bitvector of %d bits needs %d bytes at offset %d, input has length %d: %w", typ.Len(), size, startOffset, len(input), ErrInputTooShort)
+	}
+	enc := input[startOffset : startOffset+size]
+	if err := checkBitvectorPadding(enc, uint64(typ.Len())); err != nil {
+		return 0, err
+	}
+	val.SetBytes(append([]byte{}, enc...))
+	return startOffset + size, nil
+}
@@ -0,0 +1,48 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBitvector_Bits(t *testing.T) {
+	b := NewBitvector(12)
+	if len(b) != 2 {
+		t.Fatalf("NewBitvector(12) has %d bytes, expected 2", len(b))
+	}
+	b.SetBitAt(0, true)
+	b.SetBitAt(11, true)
+	b.SetBitAt(16, true)
+	if !b.BitAt(0) || !b.BitAt(11) || b.BitAt(1) || b.BitAt(16) {
+		t.Errorf("Unexpected bits %#x", []byte(b))
+	}
+	b.SetBitAt(11, false)
+	if b.BitAt(11) {
+		t.Error("Bit 11 is still set")
+	}
+}
+
+func TestBitvector_FieldType(t *testing.T) {
+	type state struct {
+		JustificationBits Bitvector  `ssz-size:"4"`
+		Participation     *Bitvector `ssz-size:"2048"`
+	}
+	typ := reflect.TypeOf(state{})
+	schema, err := Describe(typ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := schema.String(); s != "container{JustificationBits: bitvector[4], Participation: bitvector[2048]}" {
+		t.Errorf("Unexpected schema %s", s)
+	}
+	size, ok := ConstantSize(typ)
+	if !ok || size != 1+256 {
+		t.Errorf("ConstantSize returned %d, %v, expected %d", size, ok, 1+256)
+	}
+	if IsVariableSizeType(typ) {
+		t.Error("Expected a container of bitvectors to be fixed-size")
+	}
+	if err := CheckType(bitvectorType); err == nil {
+		t.Error("Expected a bitvector without a field to be rejected")
+	}
+}
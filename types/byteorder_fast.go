@@ -0,0 +1,9 @@
+// +build 386 amd64 arm arm64 ppc64le mips64le mipsle riscv64 wasm
+// +build !purego
+
+package types
+
+// fastPaths reports whether shortcuts that reinterpret the in-memory layout of
+// numeric values as their SSZ encoding may be used. They are only correct on
+// little-endian targets, and building with the purego tag disables them.
+const fastPaths = true
@@ -0,0 +1,9 @@
+// +build !386,!amd64,!arm,!arm64,!ppc64le,!mips64le,!mipsle,!riscv64,!wasm purego
+
+package types
+
+// fastPaths reports whether shortcuts that reinterpret the in-memory layout of
+// numeric values as their SSZ encoding may be used. Big-endian targets such as
+// s390x and builds with the purego tag always take the generic code paths,
+// which encode every value through binary.LittleEndian.
+const fastPaths = false
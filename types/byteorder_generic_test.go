@@ -0,0 +1,13 @@
+// +build purego
+
+package types
+
+import (
+	"testing"
+)
+
+func TestFastPaths_DisabledByPurego(t *testing.T) {
+	if fastPaths {
+		t.Error("Expected the purego tag to force the generic code paths")
+	}
+}
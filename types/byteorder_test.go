@@ -0,0 +1,14 @@
+package types
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestFastPaths_OnlyOnLittleEndian(t *testing.T) {
+	word := uint16(0x0102)
+	littleEndian := (*[2]byte)(unsafe.Pointer(&word))[0] == 0x02
+	if fastPaths && !littleEndian {
+		t.Error("Expected fast paths to be compiled out on a big-endian target")
+	}
+}
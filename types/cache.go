@@ -0,0 +1,237 @@
+package types
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Cache stores computed hash tree roots by key. Entries may be dropped at any
+// time, so a cache miss must never change the computed result.
+type Cache interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{}, cost int64) bool
+}
+
+// noopCache is the cache used when go-ssz is built without a caching backend.
+type noopCache struct{}
+
+func (noopCache) Get(key string) (interface{}, bool) {
+	return nil, false
+}
+
+func (noopCache) Set(key string, value interface{}, cost int64) bool {
+	return false
+}
+
+// CacheConfig sizes the hash tree root caches shared by the process, and those
+// of every HashCache. Fields left at zero keep their default.
+type CacheConfig struct {
+	// Disabled replaces every cache with one which holds nothing, so that no
+	// goroutine is ever started for them.
+	Disabled bool
+	// NumCounters is the number of keys whose access frequency each cache
+	// tracks to decide which entries to keep, 100000 by default.
+	NumCounters int64
+	// BasicMaxCost, BasicArrayMaxCost and RootsArrayMaxCost are the sizes in
+	// bytes of the caches of the roots of basic values, of vectors of basic
+	// values and of vectors of roots, 8MB, 4MB and 8MB by default.
+	BasicMaxCost      int64
+	BasicArrayMaxCost int64
+	RootsArrayMaxCost int64
+}
+
+var defaultCacheConfig = CacheConfig{
+	NumCounters:       RootsArraySizeCache,
+	BasicMaxCost:      1 << 23,
+	BasicArrayMaxCost: 1 << 22,
+	RootsArrayMaxCost: 1 << 23,
+}
+
+// cacheConfig holds the CacheConfig the backends of the caches are created with.
+var cacheConfig atomic.Value
+
+func init() {
+	cacheConfig.Store(defaultCacheConfig)
+}
+
+// withDefaults returns the config with its zero fields set to their default.
+func (c CacheConfig) withDefaults() CacheConfig {
+	if c.NumCounters == 0 {
+		c.NumCounters = defaultCacheConfig.NumCounters
+	}
+	if c.BasicMaxCost == 0 {
+		c.BasicMaxCost = defaultCacheConfig.BasicMaxCost
+	}
+	if c.BasicArrayMaxCost == 0 {
+		c.BasicArrayMaxCost = defaultCacheConfig.BasicArrayMaxCost
+	}
+	if c.RootsArrayMaxCost == 0 {
+		c.RootsArrayMaxCost = defaultCacheConfig.RootsArrayMaxCost
+	}
+	return c
+}
+
+// Configure sizes or disables the hash tree root caches. The caches are created
+// once with the config to report any error of their backend, then closed as
+// Close does, so that every cache starts over with the config the next time it
+// is used. Configure must not be called while values are hashed.
+func Configure(config CacheConfig) error {
+	config = config.withDefaults()
+	if !config.Disabled {
+		for name, maxCost := range map[string]int64{
+			"basic values":            config.BasicMaxCost,
+			"vectors of basic values": config.BasicArrayMaxCost,
+			"vectors of roots":        config.RootsArrayMaxCost,
+		} {
+			if config.NumCounters < 0 || maxCost < 0 {
+				return fmt.Errorf("cache of %s cannot have %d counters and a maximum cost of %d", name, config.NumCounters, maxCost)
+			}
+			backend, err := newCacheBackend(config.NumCounters, maxCost)
+			if err != nil {
+				return fmt.Errorf("could not create the cache of %s: %w", name, err)
+			}
+			if closer, ok := backend.(interface{ Close() }); ok {
+				closer.Close()
+			}
+		}
+	}
+	cacheConfig.Store(config)
+	Close()
+	return nil
+}
+
+// liveCaches holds the caches whose backend has been created since the last
+// call to Close.
+var liveCaches = struct {
+	sync.Mutex
+	caches map[*lazyCache]struct{}
+}{caches: make(map[*lazyCache]struct{})}
+
+// lazyCache is the hash tree root cache of a factory. The factories are created
+// once, when the package is initialized, but the backend of their caches is only
+// created on first use, through a sync.Once, so that programs which never hash
+// anything do not start the goroutines of ristretto, and again on the first use
+// following a call to Close.
+type lazyCache struct {
+	// The counters come first so that they are 64-bit aligned on 32-bit
+	// platforms, as atomic operations require.
+	hits   uint64
+	misses uint64
+	// evictions counts the evictions of the backends closed so far.
+	evictions uint64
+	maxCost   func(CacheConfig) int64
+	instance  atomic.Value // *cacheInstance
+}
+
+type cacheInstance struct {
+	once  sync.Once
+	cache Cache
+	// meter holds a meterHolder once the backend is created.
+	meter atomic.Value
+}
+
+// cacheMeter is implemented by backends which report their evictions and the
+// total cost of their entries, such as ristretto.
+type cacheMeter interface {
+	Evictions() uint64
+	Cost() int64
+}
+
+type meterHolder struct {
+	meter cacheMeter
+}
+
+// newCache creates the hash tree root cache of a factory, holding entries up to
+// the total cost maxCost selects from the CacheConfig the backend is created
+// with.
+func newCache(maxCost func(CacheConfig) int64) *lazyCache {
+	c := &lazyCache{maxCost: maxCost}
+	c.instance.Store(&cacheInstance{})
+	return c
+}
+
+func (c *lazyCache) backend() Cache {
+	inst := c.instance.Load().(*cacheInstance)
+	inst.once.Do(func() {
+		// Disabled caches are registered as well, so that Configure replaces
+		// them once caching is enabled again.
+		inst.cache = noopCache{}
+		config := cacheConfig.Load().(CacheConfig)
+		if !config.Disabled {
+			// Configure rejects configs the backend cannot be created with, so
+			// this only fails for another reason, in which case roots are
+			// computed without caching them.
+			if cache, err := newCacheBackend(config.NumCounters, c.maxCost(config)); err == nil {
+				inst.cache = cache
+			}
+		}
+		if meter, ok := inst.cache.(cacheMeter); ok {
+			inst.meter.Store(meterHolder{meter: meter})
+		}
+		liveCaches.Lock()
+		liveCaches.caches[c] = struct{}{}
+		liveCaches.Unlock()
+	})
+	return inst.cache
+}
+
+func (c *lazyCache) Get(key string) (interface{}, bool) {
+	res, ok := c.backend().Get(key)
+	if ok {
+		atomic.AddUint64(&c.hits, 1)
+	} else {
+		atomic.AddUint64(&c.misses, 1)
+	}
+	return res, ok
+}
+
+func (c *lazyCache) Set(key string, value interface{}, cost int64) bool {
+	return c.backend().Set(key, value, cost)
+}
+
+// close stops the backend of the cache, if it was created, and drops its
+// entries. The next use of the cache creates a new backend.
+func (c *lazyCache) close() {
+	inst := c.instance.Load().(*cacheInstance)
+	c.instance.Store(&cacheInstance{})
+	// Once the instance is marked as used, its backend can no longer be created
+	// by a call racing with this one.
+	inst.once.Do(func() {})
+	if meter, ok := inst.meter.Load().(meterHolder); ok {
+		atomic.AddUint64(&c.evictions, meter.meter.Evictions())
+	}
+	if closer, ok := inst.cache.(interface{ Close() }); ok {
+		closer.Close()
+	}
+}
+
+// stats returns the counters of the cache, which are read without blocking
+// the computations using it.
+func (c *lazyCache) stats() RootCacheStats {
+	s := RootCacheStats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+	}
+	inst := c.instance.Load().(*cacheInstance)
+	if meter, ok := inst.meter.Load().(meterHolder); ok {
+		s.Evictions += meter.meter.Evictions()
+		s.Cost = meter.meter.Cost()
+	}
+	return s
+}
+
+// Close stops the goroutines of the hash tree root caches and drops their
+// entries, for programs which need a clean shutdown, such as test suites and
+// plugins. Caching keeps working afterwards: each cache starts over empty the
+// next time it is used. Close must not be called while values are hashed.
+func Close() {
+	liveCaches.Lock()
+	caches := liveCaches.caches
+	liveCaches.caches = make(map[*lazyCache]struct{})
+	liveCaches.Unlock()
+	for c := range caches {
+		c.close()
+	}
+}
@@ -0,0 +1,33 @@
+// +build nocache
+
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewCache_NoCache(t *testing.T) {
+	for name, cache := range map[string]Cache{
+		"basic":       basicFactory.hashCache,
+		"basic array": basicArrayFactory.hashCache,
+		"roots array": rootsArrayFactory.hashCache,
+	} {
+		if _, ok := cache.(*lazyCache).backend().(noopCache); !ok {
+			t.Errorf("Expected the %s factory to use a no-op cache, received %T", name, cache)
+		}
+	}
+	// Roots must be computed all the same without a cache.
+	val := reflect.ValueOf([4]uint64{1, 2, 3, 4})
+	first, err := basicFactory.Root(val, val.Type(), "", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := basicFactory.Root(val, val.Type(), "", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("Expected identical roots, received %#x and %#x", first, second)
+	}
+}
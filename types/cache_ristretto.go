@@ -0,0 +1,18 @@
+// +build !nocache
+
+package types
+
+import (
+	"github.com/524119574/go-ssz/cache/ristretto"
+)
+
+// newCacheBackend creates the backend of a hash tree root cache. Building with
+// the nocache tag replaces it with a no-op cache and drops the ristretto
+// dependency.
+func newCacheBackend(numCounters int64, maxCost int64) (Cache, error) {
+	cache, err := ristretto.New(numCounters, maxCost)
+	if err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
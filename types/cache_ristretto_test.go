@@ -0,0 +1,177 @@
+// +build !nocache
+
+package types
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/524119574/go-ssz/cache/ristretto"
+)
+
+func TestNewCache_UsesRistretto(t *testing.T) {
+	for name, cache := range map[string]Cache{
+		"basic":       basicFactory.hashCache,
+		"basic array": basicArrayFactory.hashCache,
+		"roots array": rootsArrayFactory.hashCache,
+	} {
+		if _, ok := cache.(*lazyCache).backend().(*ristretto.Cache); !ok {
+			t.Errorf("Expected the %s factory to use a ristretto cache, received %T", name, cache)
+		}
+	}
+}
+
+func TestClose_StopsCacheGoroutines(t *testing.T) {
+	ToggleCache(true)
+	defer ToggleCache(false)
+	Close()
+	before := settledGoroutines()
+	val := reflect.ValueOf([4][32]byte{{1}, {2}})
+	for i := 0; i < 10; i++ {
+		if _, err := rootsArrayFactory.Root(val, val.Type(), "", 0, nil); err != nil {
+			t.Fatal(err)
+		}
+		if runtime.NumGoroutine() <= before {
+			t.Fatal("Expected hashing to start the goroutines of the caches")
+		}
+		Close()
+	}
+	if after := settledGoroutines(); after > before {
+		t.Errorf("Expected %d goroutines after closing the caches, received %d", before, after)
+	}
+	// Caches are started again by the next use.
+	if _, err := rootsArrayFactory.Root(val, val.Type(), "", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	Close()
+}
+
+// settledGoroutines returns the number of goroutines once it stops decreasing,
+// as the goroutines of ristretto return shortly after being told to stop.
+func settledGoroutines() int {
+	n := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		next := runtime.NumGoroutine()
+		if next >= n {
+			return next
+		}
+		n = next
+	}
+	return n
+}
+
+func TestConfigure_MaxCost(t *testing.T) {
+	if err := Configure(CacheConfig{RootsArrayMaxCost: 1 << 20}); err != nil {
+		t.Fatal(err)
+	}
+	defer Configure(CacheConfig{})
+	for name, tt := range map[string]struct {
+		cache   Cache
+		maxCost int64
+	}{
+		"basic":       {basicFactory.hashCache, 1 << 23},
+		"basic array": {basicArrayFactory.hashCache, 1 << 22},
+		"roots array": {rootsArrayFactory.hashCache, 1 << 20},
+	} {
+		backend, ok := tt.cache.(*lazyCache).backend().(*ristretto.Cache)
+		if !ok {
+			t.Fatalf("Expected the %s factory to use a ristretto cache, received %T", name, backend)
+		}
+		if backend.MaxCost() != tt.maxCost {
+			t.Errorf("Expected the %s cache to hold at most %d, received %d", name, tt.maxCost, backend.MaxCost())
+		}
+	}
+}
+
+func TestConfigure_Disabled(t *testing.T) {
+	Close()
+	before := settledGoroutines()
+	if err := Configure(CacheConfig{Disabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	defer Configure(CacheConfig{})
+	ToggleCache(true)
+	defer ToggleCache(false)
+	val := reflect.ValueOf([4][32]byte{{1}, {2}})
+	want, err := rootsArrayFactory.Root(val, val.Type(), "", 0, (*HashContext)(nil).WithCache(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		root, err := rootsArrayFactory.Root(val, val.Type(), "", 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root != want {
+			t.Errorf("Expected root %#x, received %#x", want, root)
+		}
+	}
+	if _, ok := rootsArrayFactory.hashCache.(*lazyCache).backend().(noopCache); !ok {
+		t.Error("Expected a disabled cache to hold nothing")
+	}
+	if after := settledGoroutines(); after > before {
+		t.Errorf("Expected no goroutine to be started for disabled caches, received %d more", after-before)
+	}
+}
+
+func TestConfigure_Errors(t *testing.T) {
+	for _, config := range []CacheConfig{
+		{NumCounters: -1},
+		{BasicArrayMaxCost: -1},
+	} {
+		if err := Configure(config); err == nil {
+			t.Errorf("Expected configuring %+v to fail", config)
+		}
+	}
+	if config := cacheConfig.Load().(CacheConfig); config != defaultCacheConfig {
+		t.Errorf("Expected a failed configuration to keep the previous one, received %+v", config)
+	}
+}
+
+func TestReadCacheStats_CountsLookups(t *testing.T) {
+	if err := Configure(CacheConfig{RootsArrayMaxCost: 32 * 4}); err != nil {
+		t.Fatal(err)
+	}
+	defer Configure(CacheConfig{})
+	ToggleCache(true)
+	defer ToggleCache(false)
+	before := ReadCacheStats().RootsArrays
+	val := reflect.ValueOf([8][32]byte{{4}, {2}})
+	// Entries are admitted asynchronously, so the same roots are hashed until
+	// their root is returned from the cache.
+	for i := 0; i < 100 && ReadCacheStats().RootsArrays.Hits == before.Hits; i++ {
+		if _, err := rootsArrayFactory.Root(val, val.Type(), "", 0, nil); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	after := ReadCacheStats().RootsArrays
+	if after.Hits == before.Hits || after.Misses == before.Misses {
+		t.Errorf("Expected a miss then a hit hashing the same roots again, received %+v then %+v", before, after)
+	}
+	if after.Cost != 32 {
+		t.Errorf("Expected the cache to hold a single root, received a cost of %d", after.Cost)
+	}
+	// The cache holds 4 roots, so hashing more distinct vectors evicts some.
+	for i := 0; i < 64; i++ {
+		val := reflect.ValueOf([8][32]byte{{byte(i), 1}})
+		for j := 0; j < 4; j++ {
+			if _, err := rootsArrayFactory.Root(val, val.Type(), "", 0, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	stats := ReadCacheStats().RootsArrays
+	if stats.Cost > 32*4 {
+		t.Errorf("Expected a cost of at most %d, received %d", 32*4, stats.Cost)
+	}
+	// Evictions of closed caches are kept.
+	Close()
+	if closed := ReadCacheStats().RootsArrays; closed.Evictions < stats.Evictions || closed.Hits < stats.Hits || closed.Cost != 0 {
+		t.Errorf("Expected the counters to be kept after closing the cache, received %+v then %+v", stats, closed)
+	}
+}
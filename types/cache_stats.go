@@ -0,0 +1,72 @@
+package types
+
+import (
+	"sync/atomic"
+)
+
+// CacheStats aggregates the counters of the hash tree root caches, to tell
+// whether they are sized well. The counters are updated atomically while
+// hashing and read without blocking it, and only ever grow, so rates are
+// taken from the differences between two reads.
+type CacheStats struct {
+	// Basic, BasicArrays and RootsArrays are the caches of the roots of basic
+	// values, of vectors of basic values and of vectors of roots.
+	Basic       RootCacheStats
+	BasicArrays RootCacheStats
+	RootsArrays RootCacheStats
+	// Layers counts the lookups of the tries of vectors hashed as struct
+	// fields.
+	Layers LayerCacheStats
+}
+
+// RootCacheStats counts the lookups of a cache of hash tree roots.
+type RootCacheStats struct {
+	Hits   uint64
+	Misses uint64
+	// Evictions is the number of entries evicted to make room for others, and
+	// Cost the total cost of the entries held, in bytes. Both stay at zero
+	// without a caching backend.
+	Evictions uint64
+	Cost      int64
+}
+
+// LayerCacheStats counts the lookups of the tries of vectors. A hit updates a
+// cached trie by rehashing the branches of the UpdatedLeaves which changed,
+// while a miss merkleizes the vector from scratch.
+type LayerCacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	UpdatedLeaves uint64
+}
+
+// ReadCacheStats returns the counters of the caches shared by every
+// computation which is not given a HashCache of its own.
+func ReadCacheStats() CacheStats {
+	return readCacheStats(basicFactory, basicArrayFactory, rootsArrayFactory, compositeArrayFactory)
+}
+
+// Stats returns the counters of the cache.
+func (c *HashCache) Stats() CacheStats {
+	return readCacheStats(c.basic, c.basicArrays, c.rootsArrays, c.compositeArrays)
+}
+
+func readCacheStats(basic *basicSSZ, basicArrays *basicArraySSZ, rootsArrays *rootsArraySSZ, compositeArrays *compositeArraySSZ) CacheStats {
+	s := CacheStats{
+		Basic:       cacheStats(basic.hashCache),
+		BasicArrays: cacheStats(basicArrays.hashCache),
+		RootsArrays: cacheStats(rootsArrays.hashCache),
+	}
+	for _, layers := range []*layerCache{basicArrays.layerCache, rootsArrays.layerCache, compositeArrays.layerCache} {
+		s.Layers.Hits += atomic.LoadUint64(&layers.hits)
+		s.Layers.Misses += atomic.LoadUint64(&layers.misses)
+		s.Layers.UpdatedLeaves += atomic.LoadUint64(&layers.updatedLeaves)
+	}
+	return s
+}
+
+func cacheStats(cache Cache) RootCacheStats {
+	if lazy, ok := cache.(*lazyCache); ok {
+		return lazy.stats()
+	}
+	return RootCacheStats{}
+}
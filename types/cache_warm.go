@@ -0,0 +1,250 @@
+package types
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"reflect"
+
+	"github.com/minio/highwayhash"
+	"github.com/pkg/errors"
+	"github.com/protolambda/zssz/merkle"
+)
+
+// cacheExportMagic identifies the layout written by ExportCache.
+var cacheExportMagic = [4]byte{'S', 'S', 'Z', 1}
+
+// importSampleSize is the number of parent nodes per layer which ImportCache
+// recomputes before trusting an imported layer cache.
+const importSampleSize = 16
+
+// WarmCache computes the hash tree root of val so that every cache consulted
+// while hashing, including the layer caches of root arrays, is populated the
+// same way a regular hash tree root computation would populate it. Caching must
+// be enabled with ToggleCache beforehand.
+func WarmCache(val interface{}) error {
+	if !cachingEnabled() {
+		return errors.New("caching is disabled, enable it with ToggleCache before warming the cache")
+	}
+	if val == nil {
+		return errors.New("untyped-value nil cannot be hashed")
+	}
+	rval := reflect.ValueOf(val)
+	factory, err := SSZFactory(rval, rval.Type())
+	if err != nil {
+		return errors.Wrapf(err, "could not generate tree hasher for type: %v", rval.Type())
+	}
+	_, err = factory.Root(rval, rval.Type(), "", 0, nil)
+	return err
+}
+
+// ExportCache writes the layer caches of root arrays to w, so that they can be
+// restored with ImportCache after a restart instead of being recomputed.
+func ExportCache(w io.Writer) error {
+	return rootsArrayFactory.exportLayers(w)
+}
+
+// ImportCache restores layer caches written by ExportCache. Every entry is
+// checked for consistency and a sample of its nodes is recomputed before any of
+// them is used; if any entry fails validation, nothing is imported.
+func ImportCache(r io.Reader) error {
+	return rootsArrayFactory.importLayers(r)
+}
+
+func (a *rootsArraySSZ) exportLayers(w io.Writer) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(cacheExportMagic[:]); err != nil {
+		return err
+	}
+	// Only fields whose layers were fully computed can be restored. Of the tries
+	// cached for the vectors of several values of a field, the most recently
+	// used one is written under the field name alone, as addresses are
+	// meaningless to another process.
+	fieldNames := make([]string, 0, len(a.keys))
+	keys := make(map[string]string, len(a.keys))
+	for fieldName, fieldKeys := range a.keys {
+		for i := len(fieldKeys) - 1; i >= 0; i-- {
+			if leaves, ok := a.cachedLeaves[fieldKeys[i]]; ok && layersComplete(a.layers[fieldKeys[i]], len(leaves)) {
+				fieldNames = append(fieldNames, fieldName)
+				keys[fieldName] = fieldKeys[i]
+				break
+			}
+		}
+	}
+	writeUint32(bw, uint32(len(fieldNames)))
+	for _, fieldName := range fieldNames {
+		writeUint32(bw, uint32(len(fieldName)))
+		if _, err := bw.WriteString(fieldName); err != nil {
+			return err
+		}
+		writeUint32(bw, uint32(len(a.cachedLeaves[keys[fieldName]])))
+		layers := a.layers[keys[fieldName]]
+		writeUint32(bw, uint32(len(layers)))
+		for _, layer := range layers {
+			writeUint32(bw, uint32(len(layer)))
+			for _, node := range layer {
+				if _, err := bw.Write(node); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+func (a *rootsArraySSZ) importLayers(r io.Reader) error {
+	br := bufio.NewReader(r)
+	var magic [4]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil {
+		return errors.Wrap(err, "could not read cache header")
+	}
+	if magic != cacheExportMagic {
+		return errors.Errorf("unrecognized cache header %#x", magic)
+	}
+	numFields, err := readUint32(br)
+	if err != nil {
+		return err
+	}
+	importedLeaves := make(map[string][][]byte, numFields)
+	importedLayers := make(map[string][][][]byte, numFields)
+	for i := uint32(0); i < numFields; i++ {
+		nameLen, err := readUint32(br)
+		if err != nil {
+			return err
+		}
+		name := make([]byte, nameLen)
+		if _, err := io.ReadFull(br, name); err != nil {
+			return errors.Wrap(err, "could not read field name")
+		}
+		fieldName := string(name)
+		numLeaves, err := readUint32(br)
+		if err != nil {
+			return err
+		}
+		numLayers, err := readUint32(br)
+		if err != nil {
+			return err
+		}
+		if numLeaves == 0 || numLayers != uint32(merkle.GetDepth(uint64(numLeaves)))+1 {
+			return errors.Errorf("field %s has %d layers, which does not match its %d leaves", fieldName, numLayers, numLeaves)
+		}
+		layers := make([][][]byte, numLayers)
+		for j := range layers {
+			layerLen, err := readUint32(br)
+			if err != nil {
+				return err
+			}
+			if uint64(layerLen) > uint64(numLeaves)*2 {
+				return errors.Errorf("layer %d of field %s has %d nodes, more than possible for %d leaves", j, fieldName, layerLen, numLeaves)
+			}
+			nodes := make([]byte, int(layerLen)*BytesPerChunk)
+			if _, err := io.ReadFull(br, nodes); err != nil {
+				return errors.Wrapf(err, "could not read layer %d of field %s", j, fieldName)
+			}
+			layers[j] = make([][]byte, layerLen)
+			for k := range layers[j] {
+				layers[j][k] = nodes[k*BytesPerChunk : (k+1)*BytesPerChunk]
+			}
+		}
+		if err := validateLayers(layers, int(numLeaves)); err != nil {
+			return errors.Wrapf(err, "invalid layer cache for field %s", fieldName)
+		}
+		importedLeaves[fieldName] = layers[0][:numLeaves]
+		importedLayers[fieldName] = layers
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for fieldName, leaves := range importedLeaves {
+		a.cachedLeaves[fieldName] = leaves
+		a.layers[fieldName] = importedLayers[fieldName]
+		a.use(fieldName)
+		// Seed the roots cache as well, so that hashing the unchanged field again
+		// does not have to merkleize it from scratch.
+		a.seedRoot(leaves, importedLayers[fieldName])
+	}
+	return nil
+}
+
+func (a *rootsArraySSZ) seedRoot(leaves [][]byte, layers [][][]byte) {
+	hashKeyElements := make([]byte, 0, len(leaves)*BytesPerChunk)
+	for _, leaf := range leaves {
+		hashKeyElements = append(hashKeyElements, leaf...)
+	}
+	hashKey := highwayhash.Sum(hashKeyElements, fastSumHashKey[:])
+	emptyKey := highwayhash.Sum(make([]byte, len(hashKeyElements)), fastSumHashKey[:])
+	if hashKey == emptyKey {
+		return
+	}
+	var root [32]byte
+	copy(root[:], layers[len(layers)-1][0])
+	a.hashCache.Set(string(hashKey[:]), root, 32)
+}
+
+// layersComplete reports whether layers holds a full Merkle trie over numLeaves
+// leaves, as built by merkleize.
+func layersComplete(layers [][][]byte, numLeaves int) bool {
+	if numLeaves == 0 || len(layers) == 0 {
+		return false
+	}
+	width := len(layers[0])
+	if width < numLeaves || !isPowerOf2(width) {
+		return false
+	}
+	for _, layer := range layers {
+		if len(layer) != width {
+			return false
+		}
+		width /= 2
+	}
+	return width == 0
+}
+
+// validateLayers checks the shape of imported layers and recomputes a sample of
+// their parent nodes, always including the root.
+func validateLayers(layers [][][]byte, numLeaves int) error {
+	if !layersComplete(layers, numLeaves) {
+		return errors.New("layers do not form a complete Merkle trie")
+	}
+	// The padding leaves must be zero chunks, as they are never compared against
+	// the leaves of subsequent calls.
+	emptyChunk := make([]byte, BytesPerChunk)
+	for i := numLeaves; i < len(layers[0]); i++ {
+		if !bytes.Equal(layers[0][i], emptyChunk) {
+			return errors.Errorf("padding leaf %d is not empty", i)
+		}
+	}
+	for i := 1; i < len(layers); i++ {
+		samples := []int{0}
+		if len(layers[i]) > 1 {
+			for j := 0; j < importSampleSize && j < len(layers[i]); j++ {
+				samples = append(samples, rand.Intn(len(layers[i])))
+			}
+		}
+		for _, idx := range samples {
+			parent := hash(append(append([]byte{}, layers[i-1][2*idx]...), layers[i-1][2*idx+1]...))
+			if !bytes.Equal(parent[:], layers[i][idx]) {
+				return errors.Errorf("node %d of layer %d does not match its children", idx, i)
+			}
+		}
+	}
+	return nil
+}
+
+func writeUint32(w *bufio.Writer, v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	// Write errors are sticky and surface when the writer is flushed.
+	_, _ = w.Write(b[:])
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, errors.Wrap(err, "could not read cache entry")
+	}
+	return binary.LittleEndian.Uint32(b[:]), nil
+}
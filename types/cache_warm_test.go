@@ -0,0 +1,171 @@
+package types
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type warmState struct {
+	Slot       uint64
+	BlockRoots [64][32]byte
+}
+
+func warmStateFixture() warmState {
+	state := warmState{Slot: 10}
+	for i := range state.BlockRoots {
+		state.BlockRoots[i][0] = byte(i + 1)
+	}
+	return state
+}
+
+func warmStateRoot(t *testing.T, state warmState) [32]byte {
+	val := reflect.ValueOf(state)
+	root, err := StructFactory.Root(val, val.Type(), "", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// withFreshRootsCache simulates a process restart by replacing the roots array
+// factory, and with it all of its caches, until the returned func is called.
+func withFreshRootsCache() func() {
+	previous := rootsArrayFactory
+	ToggleCache(true)
+	rootsArrayFactory = newRootsArraySSZ()
+	// The fields of containers hold the factories resolved for them, so they
+	// are resolved again whenever a factory is replaced.
+	resetResolvedTypes()
+	return func() {
+		rootsArrayFactory = previous
+		resetResolvedTypes()
+		ToggleCache(false)
+	}
+}
+
+func exportedFixtureCache(t *testing.T) []byte {
+	if err := WarmCache(warmStateFixture()); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := ExportCache(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWarmCache_ExportImport(t *testing.T) {
+	defer withFreshRootsCache()()
+	exported := exportedFixtureCache(t)
+
+	state := warmStateFixture()
+	state.BlockRoots[5][31] = 0xff
+	rootsArrayFactory = newRootsArraySSZ()
+	resetResolvedTypes()
+	var coldRoot [32]byte
+	coldHashes := CountHashes(func() {
+		coldRoot = warmStateRoot(t, state)
+	})
+
+	rootsArrayFactory = newRootsArraySSZ()
+	resetResolvedTypes()
+	if err := ImportCache(bytes.NewReader(exported)); err != nil {
+		t.Fatal(err)
+	}
+	var warmRoot [32]byte
+	warmHashes := CountHashes(func() {
+		warmRoot = warmStateRoot(t, state)
+	})
+	if warmRoot != coldRoot {
+		t.Errorf("Expected root %#x after import, received %#x", coldRoot, warmRoot)
+	}
+	// Only the branch of the modified root (6 hashes) and the container (1 hash)
+	// should be recomputed, compared to the full 63 hashes of the cold trie.
+	if warmHashes > 7 {
+		t.Errorf("Expected at most 7 hashes after importing the cache, received %d", warmHashes)
+	}
+	if coldHashes < 63 {
+		t.Errorf("Expected at least 63 hashes without a cache, received %d", coldHashes)
+	}
+}
+
+func TestWarmCache_ImportedTrieUpdatedForAddressedValue(t *testing.T) {
+	defer withFreshRootsCache()()
+	exported := exportedFixtureCache(t)
+	rootsArrayFactory = newRootsArraySSZ()
+	resetResolvedTypes()
+	if err := ImportCache(bytes.NewReader(exported)); err != nil {
+		t.Fatal(err)
+	}
+	// The trie imported under the field name is taken over by the first state
+	// hashed through a pointer.
+	state := warmStateFixture()
+	state.BlockRoots[9][31] = 0xff
+	val := reflect.ValueOf(&state)
+	var root [32]byte
+	hashes := CountHashes(func() {
+		var err error
+		if root, err = StructFactory.Root(val, val.Type(), "", 0, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	ToggleCache(false)
+	want := warmStateRoot(t, state)
+	ToggleCache(true)
+	if root != want {
+		t.Errorf("Expected root %#x, received %#x", want, root)
+	}
+	if hashes > 7 {
+		t.Errorf("Expected at most 7 hashes after importing the cache, received %d", hashes)
+	}
+	var buf bytes.Buffer
+	if err := ExportCache(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rootsArrayFactory = newRootsArraySSZ()
+	resetResolvedTypes()
+	if err := ImportCache(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(rootsArrayFactory.cachedLeaves) != 1 || rootsArrayFactory.cachedLeaves["warmState.BlockRoots"] == nil {
+		t.Error("Expected the trie of the state to be exported under its field name")
+	}
+}
+
+func TestWarmCache_DisabledCache(t *testing.T) {
+	if err := WarmCache(warmStateFixture()); err == nil {
+		t.Error("Expected warming a disabled cache to fail")
+	}
+}
+
+func TestImportCache_RejectsInvalidEntries(t *testing.T) {
+	defer withFreshRootsCache()()
+	exported := exportedFixtureCache(t)
+
+	corruptRoot := append([]byte{}, exported...)
+	corruptRoot[len(corruptRoot)-1] ^= 0xff
+	tests := []struct {
+		name  string
+		input []byte
+		err   string
+	}{
+		{name: "bad header", input: []byte("nope"), err: "unrecognized cache header"},
+		{name: "truncated", input: exported[:len(exported)-10], err: "could not read layer"},
+		{name: "corrupt root", input: corruptRoot, err: "does not match its children"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rootsArrayFactory = newRootsArraySSZ()
+			resetResolvedTypes()
+			err := ImportCache(bytes.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Expected error containing %q, received %v", tt.err, err)
+			}
+			if len(rootsArrayFactory.cachedLeaves) != 0 || len(rootsArrayFactory.layers) != 0 {
+				t.Error("Expected nothing to be imported from an invalid cache")
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// SizeFunc returns the size of the encoding of val, a value of a type with a
+// registered codec.
+type SizeFunc func(val reflect.Value) uint64
+
+// registeredSSZ serializes and hashes the values of a type with the codec
+// registered for it, which is only ever given values of that exact type, with
+// pointers to them handled here.
+type registeredSSZ struct {
+	typ      reflect.Type
+	codec    SSZAble
+	size     SizeFunc
+	variable bool
+}
+
+var (
+	codecLock sync.Mutex
+	// codecs holds a map[reflect.Type]*registeredSSZ which is replaced wholesale
+	// on every registration, allowing lookups on the hot path without taking a
+	// lock.
+	codecs atomic.Value
+)
+
+func init() {
+	codecs.Store(make(map[reflect.Type]*registeredSSZ))
+}
+
+// RegisterCodec installs codec as the way values of typ are marshaled,
+// unmarshaled and hashed, with size returning the size of their encoding.
+// Whether they are laid out as variable-size fields in containers is given by
+// variable. The codec is consulted before any other way of handling typ, and is
+// given values of typ itself, never pointers to them. Registering a codec for
+// a type more than once returns an error.
+func RegisterCodec(typ reflect.Type, codec SSZAble, size SizeFunc, variable bool) error {
+	if typ == nil || codec == nil || size == nil {
+		return fmt.Errorf("codec registration requires a type, a codec and a size function")
+	}
+	if typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Interface {
+		return fmt.Errorf("codecs can only be registered for concrete types, not %v", typ)
+	}
+	if isAliasType(typ) || isUnionType(typ) {
+		return fmt.Errorf("type %v is already registered as an alias or a union", typ)
+	}
+	codecLock.Lock()
+	defer codecLock.Unlock()
+	current, _ := codecs.Load().(map[reflect.Type]*registeredSSZ)
+	if _, ok := current[typ]; ok {
+		return fmt.Errorf("a codec for type %v is already registered", typ)
+	}
+	updated := make(map[reflect.Type]*registeredSSZ, len(current)+1)
+	for k, v := range current {
+		updated[k] = v
+	}
+	updated[typ] = &registeredSSZ{
+		typ:      typ,
+		codec:    codec,
+		size:     size,
+		variable: variable,
+	}
+	codecs.Store(updated)
+	resetResolvedTypes()
+	return nil
+}
+
+func lookupCodec(typ reflect.Type) (*registeredSSZ, bool) {
+	// The registry is empty until its init function runs, which can follow
+	// those of other files registering aliases.
+	registered, _ := codecs.Load().(map[reflect.Type]*registeredSSZ)
+	c, ok := registered[typ]
+	return c, ok
+}
+
+func isCodecType(typ reflect.Type) bool {
+	_, ok := lookupCodec(typ)
+	return ok
+}
+
+func (r *registeredSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return r.Marshal(reflect.New(typ.Elem()).Elem(), typ.Elem(), buf, startOffset)
+		}
+		return r.Marshal(val.Elem(), typ.Elem(), buf, startOffset)
+	}
+	return r.codec.Marshal(val, typ, buf, startOffset)
+}
+
+func (r *registeredSSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64, ctx *DecodeContext) (uint64, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			val.Set(reflect.New(typ.Elem()))
+		}
+		return r.Unmarshal(val.Elem(), typ.Elem(), input, startOffset, ctx)
+	}
+	if err := ctx.step(1); err != nil {
+		return 0, err
+	}
+	return r.codec.Unmarshal(val, typ, input, startOffset, ctx)
+}
+
+func (r *registeredSSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *HashContext) ([32]byte, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return r.Root(reflect.New(typ.Elem()).Elem(), typ.Elem(), fieldName, maxCapacity, ctx)
+		}
+		return r.Root(val.Elem(), typ.Elem(), fieldName, maxCapacity, ctx)
+	}
+	return r.codec.Root(val, typ, fieldName, maxCapacity, ctx)
+}
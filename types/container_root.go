@@ -0,0 +1,166 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/protolambda/zssz/merkle"
+)
+
+// ContainerRoot describes how the root of a container was computed from the
+// roots of its fields, each of which is a chunk of the container's tree.
+type ContainerRoot struct {
+	Type reflect.Type
+	// FieldNames and FieldRoots hold the name and root of every hashed field,
+	// in order.
+	FieldNames []string
+	FieldRoots [][32]byte
+	// Chunks is the number of fields hashed, and Depth the depth of the tree
+	// they are merkleized in, which grows by one whenever Chunks crosses a power
+	// of two, such as when a fork adds a field to a container.
+	Chunks uint64
+	Depth  uint64
+	Root   [32]byte
+}
+
+func (c ContainerRoot) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "container %v: %d chunks, depth %d, root %#x", c.Type, c.Chunks, c.Depth, c.Root)
+	for i, name := range c.FieldNames {
+		fmt.Fprintf(&b, "\n  %s: %#x", name, c.FieldRoots[i])
+	}
+	return b.String()
+}
+
+type containerRootObserverHolder struct {
+	observer func(ContainerRoot)
+}
+
+var containerRootObserver atomic.Value
+
+func init() {
+	containerRootObserver.Store(containerRootObserverHolder{})
+}
+
+// SetContainerRootObserver sets a function called with the details of every
+// container root computed while metrics are enabled with ToggleMetrics, such
+// as to log the chunk counts and depths of containers changed by a fork. A nil
+// observer disables it.
+func SetContainerRootObserver(observer func(ContainerRoot)) {
+	containerRootObserver.Store(containerRootObserverHolder{observer: observer})
+}
+
+func observeContainerRoot(typ reflect.Type, names []string, roots [][]byte, root [32]byte) {
+	if atomic.LoadUint32(&metricsEnabled) != 1 {
+		return
+	}
+	observer := containerRootObserver.Load().(containerRootObserverHolder).observer
+	if observer == nil {
+		return
+	}
+	observer(newContainerRoot(typ, names, roots, root))
+}
+
+func newContainerRoot(typ reflect.Type, names []string, roots [][]byte, root [32]byte) ContainerRoot {
+	c := ContainerRoot{
+		Type:       typ,
+		FieldNames: names,
+		FieldRoots: make([][32]byte, len(roots)),
+		Chunks:     uint64(len(roots)),
+		Depth:      treeDepth(uint64(len(roots))),
+		Root:       root,
+	}
+	for i, r := range roots {
+		copy(c.FieldRoots[i][:], r)
+	}
+	return c
+}
+
+// ContainerDepth returns the depth of the tree the fields of the container
+// type typ are merkleized in, which is the number of hashes between the root of
+// a field and the root of the container.
+func ContainerDepth(typ reflect.Type) (uint64, error) {
+	typ = underlyingType(typ)
+	if typ.Kind() != reflect.Struct || isSequenceType(typ) {
+		return 0, fmt.Errorf("type %v is not a container", typ)
+	}
+	return treeDepth(uint64(len(sszFields(typ)))), nil
+}
+
+// treeDepth returns the depth of the tree the given number of chunks are
+// merkleized in. A single chunk is its own root, at depth zero.
+func treeDepth(chunks uint64) uint64 {
+	if chunks <= 1 {
+		return 0
+	}
+	return uint64(merkle.GetDepth(chunks))
+}
+
+// InspectContainerRoot computes the root of the container val along with the
+// roots of its fields.
+func InspectContainerRoot(val reflect.Value) (ContainerRoot, error) {
+	val, err := containerValue(val)
+	if err != nil {
+		return ContainerRoot{}, err
+	}
+	typ := val.Type()
+	names, roots, err := newStructSSZ().fieldRoots(val, typ, nil)
+	if err != nil {
+		return ContainerRoot{}, err
+	}
+	root, err := bitwiseMerkleize(roots, uint64(len(roots)), uint64(len(roots)))
+	if err != nil {
+		return ContainerRoot{}, err
+	}
+	return newContainerRoot(typ, names, roots, root), nil
+}
+
+// TruncatedContainerRoot computes the root of the container val as if its last
+// dropLast fields were not part of its type, such as the root signed by the
+// signature its last field holds. The dropped fields are not hashed, and must
+// leave at least one field to hash.
+func TruncatedContainerRoot(val reflect.Value, dropLast int) ([32]byte, error) {
+	val, err := containerValue(val)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	typ := val.Type()
+	if err := checkFieldNames(typ); err != nil {
+		return [32]byte{}, err
+	}
+	fields := containerFields(typ)
+	if dropLast < 0 || dropLast >= len(fields) {
+		return [32]byte{}, fmt.Errorf("cannot drop %d of the %d fields of type %v, expected to drop fewer than all of them", dropLast, len(fields), typ)
+	}
+	names, roots, err := newStructSSZ().rootsOfFields(val, typ, fields[:len(fields)-dropLast], nil)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	root, err := bitwiseMerkleize(roots, uint64(len(roots)), uint64(len(roots)))
+	if err != nil {
+		return [32]byte{}, err
+	}
+	observeContainerRoot(typ, names, roots, root)
+	return root, nil
+}
+
+// containerValue returns the container val points to, or its zero value when
+// val is a nil pointer.
+func containerValue(val reflect.Value) (reflect.Value, error) {
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			val = reflect.New(val.Type().Elem())
+		}
+		val = val.Elem()
+	}
+	typ := val.Type()
+	if typ.Kind() != reflect.Struct || isSequenceType(typ) {
+		return reflect.Value{}, fmt.Errorf("type %v is not a container", typ)
+	}
+	if _, ok := lookupAlias(typ); ok {
+		return reflect.Value{}, fmt.Errorf("type %v is an alias, inspect the type it stands for instead", typ)
+	}
+	return val, nil
+}
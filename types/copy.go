@@ -0,0 +1,126 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Copy sets dst, a settable value of the type of src, to the value decoding the
+// encoding of src would give, without encoding it. Pointers and slices of dst
+// are allocated anew rather than shared with src, nil pointers and slices of
+// src are copied as the zero values and empty slices they are encoded as, and
+// the fields left out of encodings are left untouched in dst. Lists of values
+// without pointers, such as lists of roots, are copied as a whole. The values of
+// types with encodings of their own, such as aliases, unions and types with
+// codecs or Marshaler methods, are copied through their encodings.
+func Copy(dst, src reflect.Value) error {
+	if err := CheckType(src.Type()); err != nil {
+		return err
+	}
+	return copyValue(dst, src, src.Type())
+}
+
+func copyValue(dst, src reflect.Value, typ reflect.Type) error {
+	if isAliasType(typ) || isUnionType(typ) || isCodecType(typ) || isCustomType(typ) || isSequenceType(typ) || isBitvectorType(typ) {
+		return copyEncoding(dst, src, typ)
+	}
+	switch kind := typ.Kind(); {
+	case kind == reflect.Bool:
+		dst.SetBool(src.Bool())
+	case kind == reflect.Int32:
+		dst.SetInt(src.Int())
+	case kind == reflect.Uint8 || kind == reflect.Uint16 || kind == reflect.Uint32 || kind == reflect.Uint64:
+		dst.SetUint(src.Uint())
+	case kind == reflect.String:
+		dst.SetString(src.String())
+	case isBitlistType(typ):
+		// Empty bitlists are encoded as their delimiting bit alone.
+		b := src.Bytes()
+		if len(b) == 0 {
+			b = []byte{1}
+		}
+		if _, ok := bitlistLen(b); !ok {
+			return fmt.Errorf("bitlist %#x has no delimiting bit", b)
+		}
+		dst.SetBytes(append([]byte{}, b...))
+	case kind == reflect.Slice:
+		n := src.Len()
+		dst.Set(reflect.MakeSlice(dst.Type(), n, n))
+		return copyElements(dst, src, typ.Elem())
+	case kind == reflect.Array:
+		// Empty slices standing for vectors are encoded as zero vectors.
+		src = vectorValue(src, typ)
+		if src.Len() != typ.Len() {
+			return fmt.Errorf("vector of type %v has %d elements, expected %d", typ, src.Len(), typ.Len())
+		}
+		if dst.Kind() == reflect.Slice {
+			dst.Set(reflect.MakeSlice(dst.Type(), typ.Len(), typ.Len()))
+		}
+		return copyElements(dst, src, typ.Elem())
+	case kind == reflect.Struct:
+		for _, f := range sszFields(typ) {
+			fType, err := determineFieldType(f)
+			if err != nil {
+				return withField(sszFieldName(f), err)
+			}
+			if err := copyValue(dst.Field(f.Index[0]), src.Field(f.Index[0]), fType); err != nil {
+				return withField(sszFieldName(f), err)
+			}
+		}
+	case kind == reflect.Ptr:
+		// Nil pointers are encoded as the zero value they point to, which
+		// decodes into a newly allocated value.
+		dst.Set(reflect.New(dst.Type().Elem()))
+		return copyValue(dst.Elem(), pointedValue(src, typ), typ.Elem())
+	default:
+		return fmt.Errorf("unsupported kind: %v: %w", kind, ErrUnsupportedType)
+	}
+	return nil
+}
+
+// copyElements copies the elements of src, a list or vector of elements of
+// type elemTyp, to those of dst, which has as many.
+func copyElements(dst, src reflect.Value, elemTyp reflect.Type) error {
+	if dst.Type() == src.Type() && src.Type().Elem() == elemTyp && isPlainType(elemTyp) && src.CanInterface() {
+		reflect.Copy(dst, src)
+		return nil
+	}
+	for i := 0; i < src.Len(); i++ {
+		if err := copyValue(dst.Index(i), src.Index(i), elemTyp); err != nil {
+			return withIndex(i, err)
+		}
+	}
+	return nil
+}
+
+// isPlainType reports whether values of typ are held without pointers and are
+// copied as they are, as basic types and vectors of them are.
+func isPlainType(typ reflect.Type) bool {
+	if isAliasType(typ) || isCodecType(typ) || isCustomType(typ) {
+		return false
+	}
+	switch typ.Kind() {
+	case reflect.Array:
+		return isPlainType(typ.Elem())
+	default:
+		return isBasicType(typ.Kind())
+	}
+}
+
+// copyEncoding copies src to dst by encoding it and decoding the encoding.
+func copyEncoding(dst, src reflect.Value, typ reflect.Type) error {
+	size := determineFixedSize(src, typ)
+	if isVariableSizeType(typ) {
+		size = determineVariableSize(src, typ)
+	}
+	enc, err := encodeValue(src, typ, size)
+	if err != nil {
+		return err
+	}
+	factory, err := SSZFactory(dst, typ)
+	if err != nil {
+		return err
+	}
+	_, err = factory.Unmarshal(dst, typ, enc, 0, nil)
+	return err
+}
@@ -0,0 +1,170 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// Marshaler is implemented by types with an SSZ encoding of their own, such as
+// those with encoders generated by fastssz's sszgen, whose method set it has.
+type Marshaler interface {
+	MarshalSSZTo(dst []byte) ([]byte, error)
+	MarshalSSZ() ([]byte, error)
+	SizeSSZ() int
+}
+
+// Unmarshaler is implemented by types with an SSZ decoding of their own.
+type Unmarshaler interface {
+	UnmarshalSSZ(buf []byte) error
+}
+
+// HashRooter is implemented by types with a hash tree root of their own. It is
+// only used for types which also implement Marshaler or Unmarshaler, so that
+// types whose HashTreeRoot method calls back into go-ssz are hashed by
+// reflection rather than recursing.
+type HashRooter interface {
+	HashTreeRoot() ([32]byte, error)
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+)
+
+var customFactory = &customSSZ{}
+
+// customSSZ serializes and hashes values whose type implements Marshaler or
+// Unmarshaler with their own methods, and with reflection over their layout for
+// those of Marshaler, Unmarshaler and HashRooter they leave out. Whether they
+// are laid out as fixed-size or variable-size in containers is given by their
+// Go layout, as it is for the generated encoders of fastssz.
+type customSSZ struct{}
+
+// isCustomType reports whether values of typ, or pointers to them, implement
+// Marshaler or Unmarshaler.
+func isCustomType(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Interface {
+		return false
+	}
+	ptr := reflect.PtrTo(typ)
+	return ptr.Implements(marshalerType) || ptr.Implements(unmarshalerType)
+}
+
+// customMarshaler returns val as a Marshaler, when its type implements it.
+func customMarshaler(val reflect.Value, typ reflect.Type) (Marshaler, bool) {
+	if !isCustomType(typ) || !reflect.PtrTo(typ).Implements(marshalerType) {
+		return nil, false
+	}
+	ptr, ok := customPointer(val)
+	if !ok {
+		return nil, false
+	}
+	m, ok := ptr.Interface().(Marshaler)
+	return m, ok
+}
+
+// customPointer returns a pointer to val, or to a copy of it when it is not
+// addressable, through which methods with pointer receivers are called. Values
+// reached through unexported fields are only supported when addressable.
+func customPointer(val reflect.Value) (reflect.Value, bool) {
+	if val.CanAddr() {
+		return reflect.NewAt(val.Type(), unsafe.Pointer(val.UnsafeAddr())), true
+	}
+	if !val.CanInterface() {
+		return reflect.Value{}, false
+	}
+	ptr := reflect.New(val.Type())
+	ptr.Elem().Set(val)
+	return ptr, true
+}
+
+func (c *customSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return c.Marshal(reflect.New(typ.Elem()).Elem(), typ.Elem(), buf, startOffset)
+		}
+		return c.Marshal(val.Elem(), typ.Elem(), buf, startOffset)
+	}
+	m, ok := customMarshaler(val, typ)
+	if !ok {
+		factory, err := reflectFactory(val, typ)
+		if err != nil {
+			return 0, err
+		}
+		return factory.Marshal(val, typ, buf, startOffset)
+	}
+	if startOffset > uint64(len(buf)) {
+		return 0, fmt.Errorf("startOffset %d is greater than length of buffer %d", startOffset, len(buf))
+	}
+	enc, err := m.MarshalSSZTo(buf[startOffset:startOffset])
+	if err != nil {
+		return 0, err
+	}
+	if uint64(len(enc)) > uint64(len(buf))-startOffset {
+		return 0, fmt.Errorf("encoding of %v has %d bytes, more than the %d reported by its SizeSSZ method", typ, len(enc), m.SizeSSZ())
+	}
+	copy(buf[startOffset:], enc)
+	return startOffset + uint64(len(enc)), nil
+}
+
+// Unmarshal decodes the value from the next bytes of the input, which are as
+// many as its size for fixed-size types and the rest of the input otherwise.
+func (c *customSSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64, ctx *DecodeContext) (uint64, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			instantiateConcreteTypeForElement(val, typ.Elem())
+		}
+		return c.Unmarshal(val.Elem(), typ.Elem(), input, startOffset, ctx)
+	}
+	if !reflect.PtrTo(typ).Implements(unmarshalerType) {
+		factory, err := reflectFactory(val, typ)
+		if err != nil {
+			return 0, err
+		}
+		return factory.Unmarshal(val, typ, input, startOffset, ctx)
+	}
+	if !val.CanAddr() {
+		return 0, fmt.Errorf("cannot decode into value of type %v which is not addressable", typ)
+	}
+	end := uint64(len(input))
+	if !isVariableSizeType(typ) {
+		end = startOffset + determineFixedSize(val, typ)
+	}
+	if startOffset > end || end > uint64(len(input)) {
+		received := uint64(0)
+		if startOffset < uint64(len(input)) {
+			received = uint64(len(input)) - startOffset
+		}
+		return 0, fmt.Errorf("input for type %v is truncated: expected %d bytes, received %d: %w", typ, end-startOffset, received, ErrInputTooShort)
+	}
+	if err := ctx.step(1); err != nil {
+		return 0, err
+	}
+	ptr, _ := customPointer(val)
+	if err := ptr.Interface().(Unmarshaler).UnmarshalSSZ(input[startOffset:end]); err != nil {
+		return 0, err
+	}
+	return end, nil
+}
+
+func (c *customSSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *HashContext) ([32]byte, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return c.Root(reflect.New(typ.Elem()).Elem(), typ.Elem(), fieldName, maxCapacity, ctx)
+		}
+		return c.Root(val.Elem(), typ.Elem(), fieldName, maxCapacity, ctx)
+	}
+	// Roots of their own are computed with sha256, so the type is hashed by its
+	// layout when merkleizing with another hash function.
+	if ptr, ok := customPointer(val); ok && ctx.hasher() == sha256Hasher {
+		if r, ok := ptr.Interface().(HashRooter); ok {
+			return r.HashTreeRoot()
+		}
+	}
+	factory, err := reflectFactory(val, typ)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return factory.Root(val, typ, fieldName, maxCapacity, ctx)
+}
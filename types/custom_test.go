@@ -0,0 +1,66 @@
+package types
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// shortEpoch holds a uint64 encoded in 4 bytes by methods of its own.
+type shortEpoch struct {
+	Epoch uint64
+}
+
+func (e shortEpoch) MarshalSSZTo(dst []byte) ([]byte, error) {
+	return append(dst, byte(e.Epoch), byte(e.Epoch>>8), byte(e.Epoch>>16), byte(e.Epoch>>24)), nil
+}
+
+func (e shortEpoch) MarshalSSZ() ([]byte, error) {
+	return e.MarshalSSZTo(nil)
+}
+
+func (e shortEpoch) SizeSSZ() int {
+	return 4
+}
+
+func (e *shortEpoch) UnmarshalSSZ(buf []byte) error {
+	if len(buf) != 4 {
+		return errors.New("short epochs have 4 bytes")
+	}
+	e.Epoch = uint64(binary.LittleEndian.Uint32(buf))
+	return nil
+}
+
+func TestCustom_FixedSizeElements(t *testing.T) {
+	epochs := [2]shortEpoch{{Epoch: 1}, {Epoch: 2}}
+	val := reflect.ValueOf(&epochs).Elem()
+	if size := DetermineSize(val); size != 8 {
+		t.Errorf("DetermineSize returned %d, expected 8", size)
+	}
+	if _, ok := ConstantSize(val.Type()); ok {
+		t.Error("Expected the size of custom types to depend on their values")
+	}
+	factory, err := SSZFactory(val, val.Type())
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 8)
+	if _, err := factory.Marshal(val, val.Type(), buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{1, 0, 0, 0, 2, 0, 0, 0}; !reflect.DeepEqual(buf, want) {
+		t.Errorf("Marshaled %v, expected %v", buf, want)
+	}
+	var decoded [2]shortEpoch
+	decodedVal := reflect.ValueOf(&decoded).Elem()
+	if _, err := factory.Unmarshal(decodedVal, decodedVal.Type(), buf, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != epochs {
+		t.Errorf("Unmarshaled %v, expected %v", decoded, epochs)
+	}
+	if _, err := factory.Unmarshal(decodedVal, decodedVal.Type(), buf[:6], 0, nil); !errors.Is(err, ErrInputTooShort) {
+		t.Errorf("Expected truncated input to be rejected, received %v", err)
+	}
+}
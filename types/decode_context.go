@@ -0,0 +1,137 @@
+package types
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// ErrBudgetExceeded is returned when decoding is stopped because it exceeded the
+// work budget or the deadline it was given, rather than because the input is
+// malformed.
+var ErrBudgetExceeded = errors.New("decoding budget exceeded")
+
+// ErrAllocationLimit is returned when decoding is stopped because the lists,
+// strings and bitlists it decodes would take more memory than it was allowed
+// to allocate.
+var ErrAllocationLimit = errors.New("decoding allocation limit exceeded")
+
+// deadlineCheckInterval is the number of elements decoded between two checks of
+// the context, which keeps the cost of the checks negligible on honest input.
+const deadlineCheckInterval = 64
+
+// DecodeContext carries the state of a single decoding down through the values
+// being decoded. A nil *DecodeContext is valid, and decodes without any budget.
+type DecodeContext struct {
+	ctx         context.Context
+	maxElements uint64
+	maxDepth    uint64
+	elements    uint64
+	depth       uint64
+	sinceCheck  uint64
+	strict      bool
+	maxBytes    uint64
+	allocated   uint64
+}
+
+// NewDecodeContext returns a context which stops decoding with
+// ErrBudgetExceeded once ctx is done, once more than maxElements container
+// fields and list or vector elements have been decoded, or once values are
+// nested more than maxDepth containers, lists or vectors deep. A nil ctx or a
+// zero maximum leaves the corresponding budget unlimited.
+func NewDecodeContext(ctx context.Context, maxElements uint64, maxDepth uint64) *DecodeContext {
+	return &DecodeContext{ctx: ctx, maxElements: maxElements, maxDepth: maxDepth}
+}
+
+// SetStrict makes the decoding reject list fields holding more elements than
+// their ssz-max tags allow, which are otherwise only enforced for strings and
+// bitlists.
+func (c *DecodeContext) SetStrict() {
+	c.strict = true
+}
+
+func (c *DecodeContext) isStrict() bool {
+	return c != nil && c.strict
+}
+
+// SetMaxBytes makes the decoding fail with ErrAllocationLimit once the lists,
+// strings and bitlists it decodes would allocate more than maxBytes bytes in
+// total. Lists are accounted for by the memory their elements take, including
+// the values their pointers point to, before they are grown, so that a small
+// input claiming a huge number of elements is rejected before it is allocated.
+// Byte lists sharing the memory of the input are not accounted for. A zero
+// maximum leaves allocations unlimited.
+func (c *DecodeContext) SetMaxBytes(maxBytes uint64) {
+	c.maxBytes = maxBytes
+}
+
+// alloc accounts for n bytes about to be allocated.
+func (c *DecodeContext) alloc(n uint64) error {
+	if c == nil || c.maxBytes == 0 {
+		return nil
+	}
+	if n > c.maxBytes-c.allocated {
+		return errors.Wrapf(ErrAllocationLimit, "allocating %d more bytes after %d exceeds the limit of %d", n, c.allocated, c.maxBytes)
+	}
+	c.allocated += n
+	return nil
+}
+
+// allocSize returns the memory taken by a list element of type typ, counting
+// the value it points to if it is a pointer.
+func allocSize(typ reflect.Type) uint64 {
+	size := uint64(typ.Size())
+	if typ.Kind() == reflect.Ptr {
+		size += uint64(typ.Elem().Size())
+	}
+	return size
+}
+
+// step accounts for n decoded elements.
+func (c *DecodeContext) step(n uint64) error {
+	if c == nil {
+		return nil
+	}
+	c.elements += n
+	if c.maxElements > 0 && c.elements > c.maxElements {
+		return errors.Wrapf(ErrBudgetExceeded, "decoded more than %d elements", c.maxElements)
+	}
+	c.sinceCheck += n
+	if c.sinceCheck >= deadlineCheckInterval {
+		c.sinceCheck = 0
+		return c.Err()
+	}
+	return nil
+}
+
+// Err returns an error wrapping ErrBudgetExceeded if the context of the
+// decoding is already done.
+func (c *DecodeContext) Err() error {
+	if c == nil || c.ctx == nil {
+		return nil
+	}
+	if err := c.ctx.Err(); err != nil {
+		return errors.Wrap(ErrBudgetExceeded, err.Error())
+	}
+	return nil
+}
+
+// enter accounts for descending into a container, list or vector, which must
+// be matched by a call to leave once it is decoded.
+func (c *DecodeContext) enter() error {
+	if c == nil {
+		return nil
+	}
+	c.depth++
+	if c.maxDepth > 0 && c.depth > c.maxDepth {
+		return errors.Wrapf(ErrBudgetExceeded, "values are nested more than %d levels deep", c.maxDepth)
+	}
+	return nil
+}
+
+func (c *DecodeContext) leave() {
+	if c != nil {
+		c.depth--
+	}
+}
@@ -0,0 +1,120 @@
+package types
+
+import (
+	"bytes"
+	"reflect"
+)
+
+// DeepEqual reports whether a and b, values of the same type, have the same
+// encoding. They are compared as they are walked, as DetermineSize walks them,
+// so that nil and empty slices are equal, as are nil pointers and pointers to
+// zero values, and the fields left out of encodings are ignored. Lists of
+// different lengths are told apart before their elements are compared. The
+// values of types with encodings of their own, such as aliases, unions and
+// types with codecs or Marshaler methods, are compared by their encodings.
+func DeepEqual(a, b reflect.Value) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+	return sszEqual(a, b, a.Type())
+}
+
+func sszEqual(a, b reflect.Value, typ reflect.Type) bool {
+	if isAliasType(typ) || isUnionType(typ) || isCodecType(typ) || isCustomType(typ) || isSequenceType(typ) || isBitvectorType(typ) {
+		return encodingsEqual(a, b, typ)
+	}
+	switch kind := typ.Kind(); {
+	case kind == reflect.Bool:
+		return a.Bool() == b.Bool()
+	case kind == reflect.Int32:
+		return a.Int() == b.Int()
+	case kind == reflect.Uint8 || kind == reflect.Uint16 || kind == reflect.Uint32 || kind == reflect.Uint64:
+		return a.Uint() == b.Uint()
+	case kind == reflect.String:
+		return a.String() == b.String()
+	case isBitlistType(typ):
+		// Empty bitlists are encoded as their delimiting bit alone.
+		encA, encB := a.Bytes(), b.Bytes()
+		if len(encA) == 0 {
+			encA = []byte{1}
+		}
+		if len(encB) == 0 {
+			encB = []byte{1}
+		}
+		return bytes.Equal(encA, encB)
+	case kind == reflect.Slice || kind == reflect.Array:
+		// Empty slices standing for vectors are encoded as zero vectors.
+		a, b = vectorValue(a, typ), vectorValue(b, typ)
+		if a.Len() != b.Len() {
+			return false
+		}
+		if a.Kind() == reflect.Slice && b.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8 {
+			return bytes.Equal(a.Bytes(), b.Bytes())
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !sszEqual(a.Index(i), b.Index(i), typ.Elem()) {
+				return false
+			}
+		}
+		return true
+	case kind == reflect.Struct:
+		for _, f := range sszFields(typ) {
+			fType, err := determineFieldType(f)
+			if err != nil {
+				return false
+			}
+			if !sszEqual(a.Field(f.Index[0]), b.Field(f.Index[0]), fType) {
+				return false
+			}
+		}
+		return true
+	case kind == reflect.Ptr:
+		// Nil pointers are encoded as the zero value they point to.
+		return sszEqual(pointedValue(a, typ), pointedValue(b, typ), typ.Elem())
+	default:
+		return false
+	}
+}
+
+// pointedValue returns the value val points to, or the zero value of the type
+// it points to when it is nil.
+func pointedValue(val reflect.Value, typ reflect.Type) reflect.Value {
+	if val.IsNil() {
+		return reflect.New(typ.Elem()).Elem()
+	}
+	return val.Elem()
+}
+
+// encodingsEqual compares the encodings of a and b, after their sizes.
+func encodingsEqual(a, b reflect.Value, typ reflect.Type) bool {
+	sizeOf := determineFixedSize
+	if isVariableSizeType(typ) {
+		sizeOf = determineVariableSize
+	}
+	sizeA, sizeB := sizeOf(a, typ), sizeOf(b, typ)
+	if sizeA != sizeB {
+		return false
+	}
+	encA, err := encodeValue(a, typ, sizeA)
+	if err != nil {
+		return false
+	}
+	encB, err := encodeValue(b, typ, sizeB)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(encA, encB)
+}
+
+func encodeValue(val reflect.Value, typ reflect.Type, size uint64) ([]byte, error) {
+	factory, err := SSZFactory(val, typ)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	end, err := factory.Marshal(val, typ, buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:end], nil
+}
@@ -1,16 +1,20 @@
 package types
 
 import (
+	"fmt"
+	"math/bits"
 	"reflect"
-	"strings"
+	"sync"
 )
 
 // DetermineSize returns the required byte size of a buffer for
 // using SSZ to marshal an object.
+// Types which cannot be marshaled, such as those of a platform-dependent
+// width, count as zero bytes; Size rejects them instead.
 func DetermineSize(val reflect.Value) uint64 {
 	if val.Kind() == reflect.Ptr {
 		if val.IsNil() {
-			return DetermineSize(reflect.New(val.Type().Elem()).Elem())
+			return determineZeroValueSize(val.Type().Elem())
 		}
 		return DetermineSize(val.Elem())
 	}
@@ -20,6 +24,91 @@ func DetermineSize(val reflect.Value) uint64 {
 	return determineFixedSize(val, val.Type())
 }
 
+// Size returns the size of the encoding of val like DetermineSize, but fails
+// when its type, or that of any of its elements or fields, is not serializable
+// instead of counting it as zero bytes.
+func Size(val reflect.Value) (uint64, error) {
+	if err := checkSerializable(val.Type(), make(map[reflect.Type]bool)); err != nil {
+		return 0, err
+	}
+	return DetermineSize(val), nil
+}
+
+// checkSerializable verifies every type reached from typ has a factory.
+func checkSerializable(typ reflect.Type, visiting map[reflect.Type]bool) error {
+	// The options of unions are checked when they are registered, and types
+	// encoded by their own methods or registered codecs are left to them.
+	if isAliasType(typ) || isUnionType(typ) || isCodecType(typ) || isCustomType(typ) || visiting[typ] {
+		return nil
+	}
+	if err := checkFixedWidth(typ); err != nil {
+		return err
+	}
+	kind := typ.Kind()
+	switch {
+	case typ == bitvectorType:
+		return fmt.Errorf("bitvectors are only supported as struct fields, whose ssz-size tag declares their length in bits: %w", ErrUnsupportedType)
+	case isBasicType(kind) || kind == reflect.String:
+		return nil
+	case kind == reflect.Ptr:
+		return checkSerializable(typ.Elem(), visiting)
+	case kind == reflect.Array:
+		return checkSerializable(typ.Elem(), visiting)
+	case kind == reflect.Slice:
+		if IsZeroSizeType(typ.Elem()) {
+			return fmt.Errorf("lists of zero-size elements are not supported, elements of type %v are indistinguishable once serialized: %w", typ.Elem(), ErrUnsupportedType)
+		}
+		return checkSerializable(typ.Elem(), visiting)
+	case kind == reflect.Struct && isSequenceType(typ):
+		elemTyp, _ := sequenceElemType(typ)
+		return checkSerializable(elemTyp, visiting)
+	case kind == reflect.Struct:
+		// Types referring to themselves through pointers are only checked once.
+		visiting[typ] = true
+		defer delete(visiting, typ)
+		for _, f := range sszFields(typ) {
+			fType, err := determineFieldType(f)
+			if err != nil {
+				return withField(sszFieldName(f), err)
+			}
+			if err := checkSerializable(fType, visiting); err != nil {
+				return withField(sszFieldName(f), err)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("type %v is not serializable: %w", typ, ErrUnsupportedType)
+	}
+}
+
+// checkFixedWidth rejects types whose width depends on the platform, such as
+// int, uint and uintptr, along with vectors, lists and pointers of them, which
+// would otherwise serialize differently on 32 and 64-bit targets.
+func checkFixedWidth(typ reflect.Type) error {
+	elem := typ
+	for {
+		switch {
+		case isAliasType(elem):
+			// Registered aliases are converted to their fixed-width prototypes.
+			return nil
+		case elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Array || elem.Kind() == reflect.Slice:
+			elem = elem.Elem()
+			continue
+		case isSequenceType(elem):
+			elem, _ = sequenceElemType(elem)
+			continue
+		}
+		break
+	}
+	switch elem.Kind() {
+	case reflect.Int:
+		return fmt.Errorf("type %v has a platform-dependent width, use a fixed-width type such as int32 or uint64 instead: %w", elem, ErrUnsupportedType)
+	case reflect.Uint, reflect.Uintptr:
+		return fmt.Errorf("type %v has a platform-dependent width, use a fixed-width type such as uint32 or uint64 instead: %w", elem, ErrUnsupportedType)
+	}
+	return nil
+}
+
 func isBasicType(kind reflect.Kind) bool {
 	return kind == reflect.Bool ||
 		kind == reflect.Int32 ||
@@ -29,10 +118,38 @@ func isBasicType(kind reflect.Kind) bool {
 		kind == reflect.Uint64
 }
 
+func isAliasType(typ reflect.Type) bool {
+	_, ok := lookupAlias(typ)
+	return ok
+}
+
 func isBasicTypeArray(typ reflect.Type, kind reflect.Kind) bool {
 	return kind == reflect.Array && isBasicType(typ.Elem().Kind())
 }
 
+// packedElemSize returns the serialized size of the elements of a list or
+// vector of elements of type typ when they are packed together into chunks for
+// hashing, which is the case for basic types and pointers to them, and zero
+// otherwise.
+func packedElemSize(typ reflect.Type) uint64 {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if a, ok := lookupAlias(typ); ok {
+		typ = a.protoType
+	}
+	if isCodecType(typ) || isCustomType(typ) {
+		return 0
+	}
+	if isWideUintType(typ) {
+		return uint64(typ.Len())
+	}
+	if !isBasicType(typ.Kind()) {
+		return 0
+	}
+	return determineFixedSize(reflect.New(typ).Elem(), typ)
+}
+
 func isRootsArray(val reflect.Value, typ reflect.Type) bool {
 	elemTyp := typ.Elem()
 	elemKind := elemTyp.Kind()
@@ -41,8 +158,25 @@ func isRootsArray(val reflect.Value, typ reflect.Type) bool {
 }
 
 func isVariableSizeType(typ reflect.Type) bool {
+	if a, ok := lookupAlias(typ); ok {
+		return isVariableSizeType(a.protoType)
+	}
+	if isUnionType(typ) {
+		return true
+	}
+	if c, ok := lookupCodec(typ); ok {
+		return c.variable
+	}
+	if isSequenceType(typ) {
+		// The length of vectors is held by their values, but does not change
+		// whether they are variable-size.
+		elemTyp, isVector := sequenceElemType(typ)
+		return !isVector || isVariableSizeType(elemTyp)
+	}
 	kind := typ.Kind()
 	switch {
+	case kind == reflect.Array && isAliasType(typ.Elem()):
+		return isVariableSizeType(typ.Elem())
 	case isBasicType(kind):
 		return false
 	case isBasicTypeArray(typ, kind):
@@ -52,31 +186,101 @@ func isVariableSizeType(typ reflect.Type) bool {
 	case kind == reflect.String:
 		return true
 	case kind == reflect.Array:
-		return isVariableSizeType(typ.Elem())
+		// Zero-length vectors always serialize to zero bytes, whatever their element type.
+		return typ.Len() > 0 && isVariableSizeType(typ.Elem())
 	case kind == reflect.Struct:
-		for i := 0; i < typ.NumField(); i++ {
-			if strings.Contains(typ.Field(i).Name, "XXX_") {
-				continue
-			}
-			f := typ.Field(i)
+		return isVariableSizeStruct(typ)
+	case kind == reflect.Ptr:
+		return isVariableSizeType(typ.Elem())
+	}
+	return false
+}
+
+// variableSizeStructs caches whether container types are variable-size, so
+// that the tags of their fields are only parsed once per type.
+var variableSizeStructs sync.Map
+
+// isVariableSizeStruct reports whether the container type typ has any
+// variable-size field. It walks sszFields rather than containerFields, which
+// resolve whether the fields of a container are variable-size through it, so
+// that containers referring to themselves are resolved as they are declared.
+func isVariableSizeStruct(typ reflect.Type) bool {
+	if variable, ok := variableSizeStructs.Load(typ); ok {
+		return variable.(bool)
+	}
+	resolvedLock.RLock()
+	generation := resolvedGeneration
+	resolvedLock.RUnlock()
+	variable := false
+	for _, f := range sszFields(typ) {
+		fType, err := determineFieldType(f)
+		if err != nil {
+			break
+		}
+		if isVariableSizeType(fType) {
+			variable = true
+			break
+		}
+	}
+	resolvedLock.RLock()
+	if generation != resolvedGeneration {
+		// An alias, a codec or a union was registered in the meantime, which
+		// may have changed the size of the fields, so they are walked again.
+		resolvedLock.RUnlock()
+		return isVariableSizeStruct(typ)
+	}
+	variableSizeStructs.Store(typ, variable)
+	resolvedLock.RUnlock()
+	return variable
+}
+
+// IsVariableSizeType reports whether the serialized size of values of a type
+// depends on their contents, as opposed to being fixed by the type alone.
+func IsVariableSizeType(typ reflect.Type) bool {
+	return isVariableSizeType(typ)
+}
+
+// IsZeroSizeType reports whether every value of a type serializes to zero bytes,
+// such as empty containers and zero-length vectors.
+func IsZeroSizeType(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Array:
+		return typ.Len() == 0 || IsZeroSizeType(typ.Elem())
+	case reflect.Struct:
+		if isUnionType(typ) || isCodecType(typ) || isCustomType(typ) {
+			return false
+		}
+		for _, f := range sszFields(typ) {
 			fType, err := determineFieldType(f)
-			if err != nil {
+			if err != nil || !IsZeroSizeType(fType) {
 				return false
 			}
-			if isVariableSizeType(fType) {
-				return true
-			}
 		}
+		return true
+	case reflect.Ptr:
+		return IsZeroSizeType(typ.Elem())
+	default:
 		return false
-	case kind == reflect.Ptr:
-		return isVariableSizeType(typ.Elem())
 	}
-	return false
 }
 
 func determineFixedSize(val reflect.Value, typ reflect.Type) uint64 {
+	if a, ok := lookupAlias(typ); ok {
+		return a.size(val)
+	}
+	if c, ok := lookupCodec(typ); ok {
+		return c.size(val)
+	}
+	if m, ok := customMarshaler(val, typ); ok {
+		return uint64(m.SizeSSZ())
+	}
+	if isSequenceType(typ) {
+		return sequenceFactory.size(val)
+	}
 	kind := typ.Kind()
 	switch {
+	case isBitvectorType(typ):
+		return bitvectorSize(typ)
 	case kind == reflect.Bool:
 		return 1
 	case kind == reflect.Uint8:
@@ -92,6 +296,7 @@ func determineFixedSize(val reflect.Value, typ reflect.Type) uint64 {
 	case kind == reflect.Slice && typ.Elem().Kind() == reflect.Uint8:
 		return uint64(val.Len())
 	case kind == reflect.Array || kind == reflect.Slice:
+		val = vectorValue(val, typ)
 		var num uint64
 		for i := 0; i < val.Len(); i++ {
 			num += determineFixedSize(val.Index(i), typ.Elem())
@@ -99,22 +304,17 @@ func determineFixedSize(val reflect.Value, typ reflect.Type) uint64 {
 		return num
 	case kind == reflect.Struct:
 		totalSize := uint64(0)
-		for i := 0; i < typ.NumField(); i++ {
-			if strings.Contains(typ.Field(i).Name, "XXX_") {
-				continue
-			}
-			f := typ.Field(i)
-			fType, err := determineFieldType(f)
-			if err != nil {
+		fields := containerFields(typ)
+		for i := range fields {
+			if fields[i].typErr != nil {
 				return 0
 			}
-			totalSize += determineFixedSize(val.Field(i), fType)
+			totalSize += determineFixedSize(val.Field(fields[i].Index[0]), fields[i].typ)
 		}
 		return totalSize
 	case kind == reflect.Ptr:
 		if val.IsNil() {
-			newElem := reflect.New(typ.Elem()).Elem()
-			return determineVariableSize(newElem, newElem.Type())
+			return determineZeroValueSize(typ.Elem())
 		}
 		return determineFixedSize(val.Elem(), typ.Elem())
 	default:
@@ -123,50 +323,218 @@ func determineFixedSize(val reflect.Value, typ reflect.Type) uint64 {
 }
 
 func determineVariableSize(val reflect.Value, typ reflect.Type) uint64 {
+	if a, ok := lookupAlias(typ); ok {
+		return a.size(val)
+	}
+	if u, ok := lookupUnion(typ); ok {
+		return u.size(val)
+	}
+	if c, ok := lookupCodec(typ); ok {
+		return c.size(val)
+	}
+	if m, ok := customMarshaler(val, typ); ok {
+		return uint64(m.SizeSSZ())
+	}
+	if isSequenceType(typ) {
+		return sequenceFactory.size(val)
+	}
 	kind := typ.Kind()
 	switch {
+	case isBitlistType(typ):
+		return bitlistSize(val)
 	case kind == reflect.Slice && typ.Elem().Kind() == reflect.Uint8:
 		return uint64(val.Len())
 	case kind == reflect.String:
 		return uint64(val.Len())
 	case kind == reflect.Slice || kind == reflect.Array:
+		val = vectorValue(val, typ)
 		totalSize := uint64(0)
+		// The size of elements is determined by the element type rather than by
+		// their values, as tags may declare them as vectors held in slices.
 		for i := 0; i < val.Len(); i++ {
-			varSize := DetermineSize(val.Index(i))
 			if isVariableSizeType(typ.Elem()) {
-				totalSize += varSize + BytesPerLengthOffset
+				totalSize += determineVariableSize(val.Index(i), typ.Elem()) + BytesPerLengthOffset
 			} else {
-				totalSize += varSize
+				totalSize += determineFixedSize(val.Index(i), typ.Elem())
 			}
 		}
 		return totalSize
 	case kind == reflect.Struct:
 		totalSize := uint64(0)
-		for i := 0; i < typ.NumField(); i++ {
-			if strings.Contains(typ.Field(i).Name, "XXX_") {
-				continue
-			}
-			f := typ.Field(i)
-			fType, err := determineFieldType(f)
-			if err != nil {
+		fields := containerFields(typ)
+		for i := range fields {
+			f := &fields[i]
+			if f.typErr != nil {
 				return 0
 			}
-			if isVariableSizeType(fType) {
-				varSize := determineVariableSize(val.Field(i), fType)
+			if f.variable {
+				varSize := determineVariableSize(val.Field(f.Index[0]), f.typ)
 				totalSize += varSize + BytesPerLengthOffset
 			} else {
-				varSize := determineFixedSize(val.Field(i), fType)
+				varSize := determineFixedSize(val.Field(f.Index[0]), f.typ)
 				totalSize += varSize
 			}
 		}
 		return totalSize
 	case kind == reflect.Ptr:
 		if val.IsNil() {
-			newElem := reflect.New(typ.Elem()).Elem()
-			return determineVariableSize(newElem, newElem.Type())
+			return determineZeroValueSize(typ.Elem())
 		}
 		return determineVariableSize(val.Elem(), val.Elem().Type())
 	default:
 		return 0
 	}
 }
+
+// determineZeroValueSize returns the size of the zero value of a type, which is
+// what a nil pointer to that type is marshaled as.
+func determineZeroValueSize(typ reflect.Type) uint64 {
+	zeroVal := reflect.New(typ).Elem()
+	if isVariableSizeType(typ) {
+		return determineVariableSize(zeroVal, typ)
+	}
+	return determineFixedSize(zeroVal, typ)
+}
+
+// CheckType verifies values of typ can be serialized, which is what Size checks
+// before sizing a value.
+func CheckType(typ reflect.Type) error {
+	return checkSerializable(typ, make(map[reflect.Type]bool))
+}
+
+// ConstantSize returns the size of the encoding shared by every value of typ,
+// along with whether there is one. Variable-size types, and fixed-size ones
+// whose length is held by their values such as ssz.Vector, have none.
+func ConstantSize(typ reflect.Type) (uint64, bool) {
+	if isAliasType(typ) || isSequenceType(typ) || isUnionType(typ) || isCodecType(typ) || isCustomType(typ) {
+		return 0, false
+	}
+	switch kind := typ.Kind(); {
+	case isBitvectorType(typ):
+		return bitvectorSize(typ), true
+	case kind == reflect.Bool || kind == reflect.Uint8:
+		return 1, true
+	case kind == reflect.Uint16:
+		return 2, true
+	case kind == reflect.Uint32 || kind == reflect.Int32:
+		return 4, true
+	case kind == reflect.Uint64:
+		return 8, true
+	case kind == reflect.Array:
+		elemSize, ok := ConstantSize(typ.Elem())
+		return uint64(typ.Len()) * elemSize, ok || typ.Len() == 0
+	case kind == reflect.Ptr:
+		return ConstantSize(typ.Elem())
+	case kind == reflect.Struct:
+		size := uint64(0)
+		for _, f := range sszFields(typ) {
+			fType, err := determineFieldType(f)
+			if err != nil {
+				return 0, false
+			}
+			fieldSize, ok := ConstantSize(fType)
+			if !ok {
+				return 0, false
+			}
+			size += fieldSize
+		}
+		return size, true
+	default:
+		return 0, false
+	}
+}
+
+// MaxSize returns the size of the longest encoding of a value of typ, which
+// takes the ssz-max tags of list and string fields into account. Types holding
+// lists or strings without a limit have no such size.
+func MaxSize(typ reflect.Type) (uint64, error) {
+	return maxSize(typ, 0, make(map[reflect.Type]bool))
+}
+
+// maxSize returns the maximum size of typ, holding at most limit elements when
+// it is a list or a string.
+func maxSize(typ reflect.Type, limit uint64, visiting map[reflect.Type]bool) (uint64, error) {
+	if size, ok := ConstantSize(typ); ok {
+		return size, nil
+	}
+	if a, ok := lookupAlias(typ); ok {
+		return maxSize(a.protoType, limit, visiting)
+	}
+	if isSequenceType(typ) {
+		return 0, fmt.Errorf("the limit or length of %v is only known from its values", typ)
+	}
+	if u, ok := lookupUnion(typ); ok {
+		return u.maxSize(visiting)
+	}
+	if isCodecType(typ) {
+		return 0, fmt.Errorf("the size of %v is only known from its registered codec", typ)
+	}
+	if isCustomType(typ) {
+		return 0, fmt.Errorf("the size of %v is only known from its SizeSSZ method", typ)
+	}
+	if err := checkFixedWidth(typ); err != nil {
+		return 0, err
+	}
+	if isBitlistType(typ) {
+		if limit == 0 {
+			return 0, fmt.Errorf("bitlist %v has no limit, declare one with an ssz-max tag", typ)
+		}
+		// The delimiting bit follows the last of the bits.
+		return limit/8 + 1, nil
+	}
+	switch typ.Kind() {
+	case reflect.Ptr:
+		return maxSize(typ.Elem(), limit, visiting)
+	case reflect.String:
+		if limit == 0 {
+			return 0, fmt.Errorf("string has no limit, declare one with an ssz-max tag")
+		}
+		return limit, nil
+	case reflect.Array, reflect.Slice:
+		length := limit
+		if typ.Kind() == reflect.Array {
+			length = uint64(typ.Len())
+		} else if limit == 0 {
+			return 0, fmt.Errorf("list %v has no limit, declare one with an ssz-max tag", typ)
+		}
+		elemSize, err := maxSize(typ.Elem(), 0, visiting)
+		if err != nil {
+			return 0, err
+		}
+		if isVariableSizeType(typ.Elem()) {
+			elemSize += BytesPerLengthOffset
+		}
+		hi, size := bits.Mul64(length, elemSize)
+		if hi != 0 {
+			return 0, fmt.Errorf("maximum size of %v overflows uint64", typ)
+		}
+		return size, nil
+	case reflect.Struct:
+		if visiting[typ] {
+			return 0, fmt.Errorf("type %v is recursive and has no maximum size", typ)
+		}
+		visiting[typ] = true
+		defer delete(visiting, typ)
+		size := uint64(0)
+		for _, field := range sszFields(typ) {
+			fType, err := determineFieldType(field)
+			if err != nil {
+				return 0, withField(sszFieldName(field), err)
+			}
+			fieldSize, err := maxSize(fType, determineFieldCapacity(field), visiting)
+			if err != nil {
+				return 0, withField(sszFieldName(field), err)
+			}
+			if isVariableSizeType(fType) {
+				fieldSize += BytesPerLengthOffset
+			}
+			if size+fieldSize < size {
+				return 0, fmt.Errorf("maximum size of %v overflows uint64", typ)
+			}
+			size += fieldSize
+		}
+		return size, nil
+	default:
+		return 0, fmt.Errorf("type %v is not serializable: %w", typ, ErrUnsupportedType)
+	}
+}
@@ -0,0 +1,91 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// parallelElementRootsThreshold is the number of elements from which
+// ElementRoots splits the elements between goroutines.
+var parallelElementRootsThreshold = 1024
+
+// ElementRoots returns the hash tree root of each element of the slice or array
+// val, as HashTreeRoot would compute it for the element on its own. Nil pointer
+// elements have the root of the zero value they point to. The codec of the
+// elements is only resolved once, and lists of many elements are hashed in
+// parallel while caching is disabled, as the caches are not safe for concurrent
+// use.
+func ElementRoots(val reflect.Value) ([][32]byte, error) {
+	val = elementValue(val)
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a slice or an array, received %v", val.Type())
+	}
+	elemTyp := val.Type().Elem()
+	for elemTyp.Kind() == reflect.Ptr {
+		elemTyp = elemTyp.Elem()
+	}
+	factory, err := SSZFactory(reflect.New(elemTyp).Elem(), elemTyp)
+	if err != nil {
+		return nil, err
+	}
+	roots := make([][32]byte, val.Len())
+	hashRange := func(start int, end int) error {
+		for i := start; i < end; i++ {
+			root, err := factory.Root(elementValue(val.Index(i)), elemTyp, "", 0, nil)
+			if err != nil {
+				return fmt.Errorf("element %d: %v", i, err)
+			}
+			roots[i] = root
+		}
+		return nil
+	}
+	workers := runtime.GOMAXPROCS(0)
+	if len(roots) < parallelElementRootsThreshold || workers < 2 || cachingEnabled() {
+		if err := hashRange(0, len(roots)); err != nil {
+			return nil, err
+		}
+		return roots, nil
+	}
+	batchSize := (len(roots) + workers - 1) / workers
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		start := w * batchSize
+		end := start + batchSize
+		if end > len(roots) {
+			end = len(roots)
+		}
+		if start >= end {
+			break
+		}
+		wg.Add(1)
+		go func(w int, start int, end int) {
+			defer wg.Done()
+			errs[w] = hashRange(start, end)
+		}(w, start, end)
+	}
+	wg.Wait()
+	// The error of the first failing element is returned, as it would be when
+	// hashing sequentially.
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return roots, nil
+}
+
+// elementValue returns the value pointed to by val, or the zero value it points
+// to if it is nil.
+func elementValue(val reflect.Value) reflect.Value {
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			val = reflect.New(val.Type().Elem()).Elem()
+			continue
+		}
+		val = val.Elem()
+	}
+	return val
+}
@@ -0,0 +1,62 @@
+package types
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type elementRootsItem struct {
+	Slot uint64
+	Data []byte `ssz-max:"16"`
+}
+
+func TestElementRoots_ParallelThreshold(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	previous := parallelElementRootsThreshold
+	parallelElementRootsThreshold = 8
+	defer func() {
+		parallelElementRootsThreshold = previous
+	}()
+	for _, n := range []int{7, 8, 9, 33} {
+		items := make([]*elementRootsItem, n)
+		for i := range items {
+			if i%5 == 4 {
+				continue
+			}
+			items[i] = &elementRootsItem{Slot: uint64(i), Data: []byte{byte(i)}}
+		}
+		roots, err := ElementRoots(reflect.ValueOf(items))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, item := range items {
+			val := reflect.ValueOf(item)
+			want, err := StructFactory.Root(val, val.Type(), "", 0, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if roots[i] != want {
+				t.Errorf("Expected root %#x for element %d of %d, received %#x", want, i, n, roots[i])
+			}
+		}
+	}
+}
+
+func TestElementRoots_ParallelError(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	previous := parallelElementRootsThreshold
+	parallelElementRootsThreshold = 8
+	defer func() {
+		parallelElementRootsThreshold = previous
+	}()
+	// Data of more than one chunk exceeds the limit of 16 bytes.
+	items := make([]elementRootsItem, 32)
+	items[20].Data = make([]byte, 40)
+	items[30].Data = make([]byte, 40)
+	_, err := ElementRoots(reflect.ValueOf(items))
+	if err == nil || !strings.HasPrefix(err.Error(), "element 20:") {
+		t.Errorf("Expected the error of element 20, received %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package types
+
+import (
+	"github.com/pkg/errors"
+)
+
+// The errors wrapped by decoding failures, which tell malformed input apart
+// from values of types which cannot be serialized. They are matched with
+// errors.Is, while the messages wrapping them describe the failure.
+var (
+	// ErrOffsetOutOfBounds is wrapped when an offset points beyond the input
+	// or inside the part of the encoding preceding the variable-size values.
+	ErrOffsetOutOfBounds = errors.New("offset out of bounds")
+	// ErrOffsetsNotIncreasing is wrapped when the offset of a variable-size
+	// value precedes the offset of the value before it.
+	ErrOffsetsNotIncreasing = errors.New("offsets must be increasing")
+	// ErrInputTooShort is wrapped when the input ends before the value it
+	// holds, or holds no value at all.
+	ErrInputTooShort = errors.New("input too short")
+	// ErrSizeMismatch is wrapped when the size of the input does not match
+	// the size of the value decoded from it.
+	ErrSizeMismatch = errors.New("size mismatch")
+	// ErrUnsupportedType is wrapped when a value is of a type which cannot be
+	// serialized.
+	ErrUnsupportedType = errors.New("unsupported type")
+)
@@ -3,15 +3,35 @@ package types
 import (
 	"fmt"
 	"reflect"
+	"sync/atomic"
 )
 
-var enableCache = false
+// enableCache is 1 while caching is enabled for computations which do not
+// choose for themselves.
+var enableCache uint32
 
 // ToggleCache enables caching of ssz hash tree root. It is disabled by default.
+//
+// Deprecated: the setting applies to every computation of the process which does
+// not choose for itself, including those in flight. Use HashContext.WithCache
+// or HashContext.WithHashCache to choose per computation instead.
 func ToggleCache(val bool) {
-	enableCache = val
+	if val {
+		atomic.StoreUint32(&enableCache, 1)
+	} else {
+		atomic.StoreUint32(&enableCache, 0)
+	}
+}
+
+// cachingEnabled tells whether caching is enabled by ToggleCache.
+func cachingEnabled() bool {
+	return atomic.LoadUint32(&enableCache) == 1
 }
 
+// The factories below are created once, when the package is initialized, and
+// shared by every caller. The ristretto caches of those which cache hash tree
+// roots are only started on first use, and stopped by Close.
+
 // StructFactory exports an implementation of a interface
 // containing helpers for marshaling/unmarshaling, and determining
 // the hash tree root of struct values.
@@ -22,29 +42,87 @@ var rootsArrayFactory = newRootsArraySSZ()
 var compositeArrayFactory = newCompositeArraySSZ()
 var basicSliceFactory = newBasicSliceSSZ()
 var stringFactory = newStringSSZ()
+var bitlistFactory = newBitlistSSZ()
+var bitvectorFactory = newBitvectorSSZ()
 var compositeSliceFactory = newCompositeSliceSSZ()
+var sequenceFactory = newSequenceSSZ()
 
 // SSZAble defines a type which can marshal/unmarshal and compute its
 // hash tree root according to the Simple Serialize specification.
 // See: https://github.com/ethereum/eth2.0-specs/blob/v0.8.2/specs/simple-serialize.md.
 type SSZAble interface {
 	Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error)
-	Unmarshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error)
+	Unmarshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64, ctx *DecodeContext) (uint64, error)
+	Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *HashContext) ([32]byte, error)
 }
 
 // SSZFactory recursively walks down a type and determines which SSZ-able
 // core type it belongs to, and then returns and implementation of
 // SSZ-able that contains marshal, unmarshal, and hash tree root related
-// functions for use.
+// functions for use. Types implementing Marshaler or Unmarshaler, or whose
+// pointers do, get a factory calling their methods.
 func SSZFactory(val reflect.Value, typ reflect.Type) (SSZAble, error) {
+	if c, ok := lookupCodec(typ); ok {
+		return c, nil
+	}
+	if a, ok := lookupAlias(typ); ok {
+		return a, nil
+	}
+	if u, ok := lookupUnion(typ); ok {
+		return u, nil
+	}
+	if isCustomType(typ) {
+		return customFactory, nil
+	}
+	return reflectFactory(val, typ)
+}
+
+// elementFactory returns the factory of values of type typ. Factories depend
+// on the type alone, so sequences resolve the one of their elements once and
+// reuse it for every element.
+func elementFactory(typ reflect.Type) (SSZAble, error) {
+	return SSZFactory(reflect.Zero(typ), typ)
+}
+
+// reflectFactory returns the factory of typ by reflection over its layout,
+// whether or not it implements Marshaler or Unmarshaler.
+func reflectFactory(val reflect.Value, typ reflect.Type) (SSZAble, error) {
+	if err := checkFixedWidth(typ); err != nil {
+		return nil, err
+	}
 	kind := typ.Kind()
 	switch {
+	case isBitlistType(typ):
+		return bitlistFactory, nil
+	case isBitvectorType(typ):
+		return bitvectorFactory, nil
+	case typ == bitvectorType:
+		return nil, fmt.Errorf("bitvectors are only supported as struct fields, whose ssz-size tag declares their length in bits: %w", ErrUnsupportedType)
+	case (kind == reflect.Array || kind == reflect.Slice) && (isAliasType(typ.Elem()) || isCodecType(typ.Elem()) || isCustomType(typ.Elem())):
+		// Elements of aliased types are dispatched one by one so each goes through its conversion,
+		// and so are those of types with codecs or encoding themselves.
+		if isVariableSizeType(typ.Elem()) {
+			if kind == reflect.Array {
+				return compositeArrayFactory, nil
+			}
+			return compositeSliceFactory, nil
+		}
+		if kind == reflect.Array {
+			return basicArrayFactory, nil
+		}
+		return basicSliceFactory, nil
+	case kind == reflect.Array && typ.Len() == 0:
+		return basicArrayFactory, nil
 	case isBasicType(kind) || isBasicTypeArray(typ, typ.Kind()):
 		return basicFactory, nil
 	case kind == reflect.String:
 		return stringFactory, nil
 	case kind == reflect.Slice:
 		switch {
+		case IsZeroSizeType(typ.Elem()):
+			// Every element would serialize to zero bytes, so the length of such a
+			// list could never be recovered from its encoding.
+			return nil, fmt.Errorf("lists of zero-size elements are not supported, elements of type %v are indistinguishable once serialized: %w", typ.Elem(), ErrUnsupportedType)
 		case isBasicType(typ.Elem().Kind()):
 			return basicSliceFactory, nil
 		case !isVariableSizeType(typ.Elem()):
@@ -63,11 +141,17 @@ func SSZFactory(val reflect.Value, typ reflect.Type) (SSZAble, error) {
 		default:
 			return compositeArrayFactory, nil
 		}
+	case kind == reflect.Struct && isSequenceType(typ):
+		return sequenceFactory, nil
 	case kind == reflect.Struct:
 		return StructFactory, nil
 	case kind == reflect.Ptr:
-		return SSZFactory(val.Elem(), typ.Elem())
+		factory, err := SSZFactory(val.Elem(), typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return pointedSSZ(factory), nil
 	default:
-		return nil, fmt.Errorf("unsupported kind: %v", kind)
+		return nil, fmt.Errorf("unsupported kind: %v: %w", kind, ErrUnsupportedType)
 	}
 }
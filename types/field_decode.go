@@ -0,0 +1,127 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// UnmarshalField decodes the field named name, by its Go name or ssz-name tag,
+// of the container of type typ encoded in input into that field of val, leaving
+// its other fields untouched. Only the offsets needed to locate the field are
+// read, so the rest of the input is neither decoded nor validated.
+func UnmarshalField(val reflect.Value, typ reflect.Type, input []byte, name string, ctx *DecodeContext) error {
+	if typ.Kind() != reflect.Struct {
+		return fmt.Errorf("can only decode single fields of containers, not of %v", typ)
+	}
+	switch {
+	case isAliasType(typ) || isCodecType(typ):
+		return fmt.Errorf("the encoding of %v is converted or handled by a codec, so its fields cannot be located", typ)
+	case isUnionType(typ) || isSequenceType(typ):
+		return fmt.Errorf("%v is not serialized as a container", typ)
+	}
+	fields := sszFields(typ)
+	index := -1
+	for i, field := range fields {
+		if sszFieldName(field) == name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("container %v has no field %s", typ, name)
+	}
+	// The sizes of fixed-size fields only depend on their types, so they are
+	// taken from a zero value rather than from val, which is left as it is.
+	fixedSizes, err := prepareFixedFields(reflect.New(typ).Elem(), typ)
+	if err != nil {
+		return err
+	}
+	start, end, err := fieldRange(typ, fields, fixedSizes, input, index)
+	if err != nil {
+		return withField(name, err)
+	}
+	field := fields[index]
+	fType, err := determineFieldType(field)
+	if err != nil {
+		return withField(name, err)
+	}
+	fieldVal := val.Field(field.Index[0])
+	if fieldVal.Kind() == reflect.Ptr {
+		instantiateField(fieldVal, fType.Elem())
+	}
+	factory, err := fieldFactory(field, fieldVal, fType)
+	if err != nil {
+		return withField(name, err)
+	}
+	if fType.Kind() == reflect.String {
+		if err := checkStringCapacity(field, determineFieldCapacity(field), end-start); err != nil {
+			return withField(name, err)
+		}
+	}
+	if _, err := factory.Unmarshal(fieldVal, fType, input[start:end], 0, ctx); err != nil {
+		return withField(name, err)
+	}
+	if err := checkBitlistCapacity(field, determineFieldCapacity(field), fieldVal); err != nil {
+		return withField(name, err)
+	}
+	return withField(name, checkListCapacity(ctx, field, determineFieldCapacity(field), fieldVal))
+}
+
+// fieldRange returns the bounds in input of the encoding of the field at index
+// of the container typ, from the sizes of the fixed-size fields before it or
+// the offsets of the variable-size field and of the one following it.
+func fieldRange(typ reflect.Type, fields []reflect.StructField, fixedSizes map[int]uint64, input []byte, index int) (uint64, uint64, error) {
+	fixedPartSize := uint64(0)
+	for i := range fields {
+		if item, ok := fixedSizes[i]; ok {
+			fixedPartSize += item
+		} else {
+			fixedPartSize += BytesPerLengthOffset
+		}
+	}
+	if fixedPartSize > uint64(len(input)) {
+		return 0, 0, fmt.Errorf(
+			"input for type %v is truncated: expected %d bytes for its fixed part, received %d: %w",
+			typ,
+			fixedPartSize,
+			len(input),
+			ErrInputTooShort,
+		)
+	}
+	position := uint64(0)
+	for i := 0; i < index; i++ {
+		if item, ok := fixedSizes[i]; ok {
+			position += item
+		} else {
+			position += BytesPerLengthOffset
+		}
+	}
+	if item, ok := fixedSizes[index]; ok {
+		return position, position + item, nil
+	}
+	start, err := readOffset(input, position)
+	if err != nil {
+		return 0, 0, err
+	}
+	// The field extends to the offset of the next variable-size field, or to
+	// the end of the input for the last one.
+	end := uint64(len(input))
+	next := position + BytesPerLengthOffset
+	for i := index + 1; i < len(fields); i++ {
+		if item, ok := fixedSizes[i]; ok {
+			next += item
+			continue
+		}
+		if end, err = readOffset(input, next); err != nil {
+			return 0, 0, err
+		}
+		break
+	}
+	if start < fixedPartSize || start > end {
+		return 0, 0, fmt.Errorf("offset %d of field %s of type %v is out of order, expected between %d and %d: %w", start, fields[index].Name, typ, fixedPartSize, end, ErrOffsetsNotIncreasing)
+	}
+	if end > uint64(len(input)) {
+		return 0, 0, fmt.Errorf("offset %d following field %s of type %v is out of bounds, input has length %d: %w", end, fields[index].Name, typ, len(input), ErrOffsetOutOfBounds)
+	}
+	return start, end, nil
+}
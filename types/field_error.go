@@ -0,0 +1,65 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FieldError is the error a value failed to be marshaled, unmarshaled or hashed
+// with, along with the path to the field or element which failed within it,
+// such as "Body.Attestations[3].Data.Target". Containers prepend the names of
+// their fields to the path, and lists and vectors the indices of their
+// elements, as the error goes up from the value which failed.
+type FieldError struct {
+	path string
+	Err  error
+}
+
+// Path returns the path to the field or element which failed, such as
+// "Body.Attestations[3].Data.Target".
+func (e *FieldError) Path() string {
+	return e.path
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %s: %v", e.path, e.Err)
+}
+
+// Unwrap returns the error the field or element failed with.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// withField returns err as failing in the field name of a container, prepending
+// name to the path err already holds.
+func withField(name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	fe, ok := err.(*FieldError)
+	if !ok {
+		return &FieldError{path: name, Err: err}
+	}
+	if strings.HasPrefix(fe.path, "[") {
+		return &FieldError{path: name + fe.path, Err: fe.Err}
+	}
+	return &FieldError{path: name + "." + fe.path, Err: fe.Err}
+}
+
+// withIndex returns err as failing in the element at index i of a list or
+// vector, prepending the index to the path err already holds.
+func withIndex(i int, err error) error {
+	if err == nil {
+		return nil
+	}
+	index := "[" + strconv.Itoa(i) + "]"
+	fe, ok := err.(*FieldError)
+	if !ok {
+		return &FieldError{path: index, Err: err}
+	}
+	if strings.HasPrefix(fe.path, "[") {
+		return &FieldError{path: index + fe.path, Err: fe.Err}
+	}
+	return &FieldError{path: index + "." + fe.path, Err: fe.Err}
+}
@@ -0,0 +1,52 @@
+package types
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFieldError_Path(t *testing.T) {
+	cause := errors.New("expected 0 or 1 but received 2")
+	err := withField("Body", withField("Attestations", withIndex(3, withField("Data", withField("Target", cause)))))
+	fieldErr, ok := err.(*FieldError)
+	if !ok {
+		t.Fatalf("Expected a *FieldError, received %T", err)
+	}
+	if want := "Body.Attestations[3].Data.Target"; fieldErr.Path() != want {
+		t.Errorf("Expected the path %q, received %q", want, fieldErr.Path())
+	}
+	if fieldErr.Err != cause || !errors.Is(err, cause) {
+		t.Errorf("Expected the error to wrap its cause, received %v", fieldErr.Err)
+	}
+	if err := withIndex(1, withIndex(2, cause)); err.(*FieldError).Path() != "[1][2]" {
+		t.Errorf("Expected the path [1][2], received %q", err.(*FieldError).Path())
+	}
+	if withField("Body", nil) != nil || withIndex(0, nil) != nil {
+		t.Error("Expected nil errors to be left alone")
+	}
+}
+
+func TestFieldError_CompositeListElement(t *testing.T) {
+	type item struct {
+		Data []byte
+	}
+	items := []item{{Data: []byte{1}}, {Data: []byte{2}}, {Data: []byte{3}}}
+	typ := reflect.TypeOf(items)
+	buf := make([]byte, determineVariableSize(reflect.ValueOf(items), typ))
+	factory := newCompositeSliceSSZ()
+	if _, err := factory.Marshal(reflect.ValueOf(items), typ, buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	// The offset of the third element points inside the offset table.
+	buf[8] = 0
+	var decoded []item
+	_, err := factory.Unmarshal(reflect.ValueOf(&decoded).Elem(), typ, buf, 0, nil)
+	fieldErr, ok := err.(*FieldError)
+	if !ok {
+		t.Fatalf("Expected a *FieldError, received %T: %v", err, err)
+	}
+	if fieldErr.Path() != "[2]" {
+		t.Errorf("Expected the path [2], received %q", fieldErr.Path())
+	}
+}
@@ -0,0 +1,165 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// flatStruct decodes fixed-size containers whose fields are all basic values
+// or byte arrays without going through reflect.Value field by field. The
+// encoding of such fields is their in-memory layout on little-endian targets,
+// so the input is copied straight to the offsets of the fields, in as few
+// copies as their layout allows. It is only used when fastPaths is set.
+type flatStruct struct {
+	size uint64
+	// runs are the spans of the encoding copied to memory at once, which merge
+	// the fields laid out back to back in both.
+	runs []flatRun
+	// bools are the boolean fields, whose bytes in the encoding must be 0 or 1.
+	bools []flatBool
+	// numFields is the number of elements decoding accounts for.
+	numFields uint64
+}
+
+type flatRun struct {
+	memOffset uintptr
+	encOffset uint64
+	size      uint64
+}
+
+type flatBool struct {
+	encOffset uint64
+	name      string
+}
+
+// flatStructs caches the *flatStruct of container types, which is nil for
+// types that cannot be decoded flat.
+var flatStructs sync.Map
+
+var (
+	// resolvedLock is held for writing while the types resolved from the
+	// fields of containers are reset, and for reading while one is stored, so
+	// that one resolved before a reset is never stored after it.
+	resolvedLock sync.RWMutex
+	// resolvedGeneration counts the resets of the types resolved from the
+	// fields of containers.
+	resolvedGeneration uint64
+)
+
+// flatStructOf returns the flat decoder of the container type typ, or nil when
+// some of its fields must be decoded by reflection. Decoders built concurrently
+// for the same type are equivalent, and every caller gets the one stored first.
+func flatStructOf(typ reflect.Type) *flatStruct {
+	if f, ok := flatStructs.Load(typ); ok {
+		return f.(*flatStruct)
+	}
+	resolvedLock.RLock()
+	generation := resolvedGeneration
+	resolvedLock.RUnlock()
+	f := newFlatStruct(typ)
+	resolvedLock.RLock()
+	if generation != resolvedGeneration {
+		// An alias or a codec was registered while the decoder was built, which
+		// may have changed how its fields are decoded, so it is built again.
+		resolvedLock.RUnlock()
+		return flatStructOf(typ)
+	}
+	actual, _ := flatStructs.LoadOrStore(typ, f)
+	resolvedLock.RUnlock()
+	return actual.(*flatStruct)
+}
+
+// resetResolvedTypes forgets the flat decoders, the fields of containers and
+// whether containers are variable-size, as the types of their fields may have
+// become aliases, codecs or unions since they were resolved.
+func resetResolvedTypes() {
+	resolvedLock.Lock()
+	defer resolvedLock.Unlock()
+	resolvedGeneration++
+	for _, cache := range []*sync.Map{&flatStructs, &containerFieldsCache, &variableSizeStructs} {
+		cache.Range(func(key, _ interface{}) bool {
+			cache.Delete(key)
+			return true
+		})
+	}
+}
+
+func newFlatStruct(typ reflect.Type) *flatStruct {
+	fields := sszFields(typ)
+	if len(fields) == 0 || len(fields) != typ.NumField() {
+		return nil
+	}
+	f := &flatStruct{}
+	for _, field := range fields {
+		if field.PkgPath != "" || isScaledField(field) {
+			return nil
+		}
+		fType, err := determineFieldType(field)
+		if err != nil || fType != field.Type {
+			return nil
+		}
+		if _, ok := lookupAlias(fType); ok || isCodecType(fType) || isCustomType(fType) {
+			return nil
+		}
+		switch kind := fType.Kind(); {
+		case kind == reflect.Bool:
+			f.bools = append(f.bools, flatBool{encOffset: f.size, name: sszFieldName(field)})
+		case kind == reflect.Uint8 || kind == reflect.Uint16 || kind == reflect.Uint32 || kind == reflect.Uint64:
+		case kind == reflect.Int32:
+		case kind == reflect.Array && fType.Elem().Kind() == reflect.Uint8:
+			if _, ok := lookupAlias(fType.Elem()); ok {
+				return nil
+			}
+		default:
+			return nil
+		}
+		size := uint64(fType.Size())
+		if n := len(f.runs); n > 0 && f.runs[n-1].memOffset+uintptr(f.runs[n-1].size) == field.Offset {
+			f.runs[n-1].size += size
+		} else if size > 0 {
+			f.runs = append(f.runs, flatRun{memOffset: field.Offset, encOffset: f.size, size: size})
+		}
+		f.size += size
+		f.numFields++
+	}
+	return f
+}
+
+// decode decodes the container val, which must be addressable, from the input
+// at startOffset.
+func (f *flatStruct) decode(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64, ctx *DecodeContext) (uint64, error) {
+	if err := ctx.enter(); err != nil {
+		return 0, err
+	}
+	defer ctx.leave()
+	if startOffset+f.size > uint64(len(input)) {
+		received := uint64(0)
+		if startOffset < uint64(len(input)) {
+			received = uint64(len(input)) - startOffset
+		}
+		return 0, fmt.Errorf(
+			"input for type %v is truncated: expected %d bytes for its fixed part, received %d: %w",
+			typ,
+			f.size,
+			received,
+			ErrInputTooShort,
+		)
+	}
+	if err := ctx.step(f.numFields); err != nil {
+		return 0, err
+	}
+	enc := input[startOffset : startOffset+f.size]
+	for _, b := range f.bools {
+		if enc[b.encOffset] > 1 {
+			return 0, withField(b.name, fmt.Errorf("expected 0 or 1 but received %d", enc[b.encOffset]))
+		}
+	}
+	base := unsafe.Pointer(val.UnsafeAddr())
+	for _, run := range f.runs {
+		dst := (*[1 << 30]byte)(unsafe.Pointer(uintptr(base) + run.memOffset))[:run.size:run.size]
+		copy(dst, enc[run.encOffset:run.encOffset+run.size])
+	}
+	return startOffset + f.size, nil
+}
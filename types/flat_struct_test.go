@@ -0,0 +1,178 @@
+package types
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type flatFork struct {
+	PreviousVersion [4]byte
+	CurrentVersion  [4]byte
+	Epoch           uint64
+}
+
+// flatPadded has fields separated by padding in memory, so that it is copied
+// in several runs.
+type flatPadded struct {
+	Flag   bool
+	Slot   uint64
+	Index  uint16
+	Root   [32]byte
+	Weight uint32
+	Level  uint8
+	Shift  int32
+}
+
+type nestedFlat struct {
+	Fork flatFork
+	Slot uint64
+}
+
+// decodeReflectively decodes val with the reflective path of structSSZ, even
+// for types which would otherwise be decoded flat.
+func decodeReflectively(val reflect.Value, input []byte) (uint64, error) {
+	flatStructs.Store(val.Type(), (*flatStruct)(nil))
+	defer flatStructs.Delete(val.Type())
+	return StructFactory.Unmarshal(val, val.Type(), input, 0, nil)
+}
+
+func TestFlatStruct_MatchesReflectiveDecoding(t *testing.T) {
+	if !fastPaths {
+		t.Skip("Flat decoding is disabled on this target")
+	}
+	r := rand.New(rand.NewSource(2753))
+	for _, typ := range []reflect.Type{reflect.TypeOf(flatFork{}), reflect.TypeOf(flatPadded{})} {
+		if flatStructOf(typ) == nil {
+			t.Fatalf("Expected %v to be decoded flat", typ)
+		}
+		size := determineFixedSize(reflect.New(typ).Elem(), typ)
+		for i := 0; i < 100; i++ {
+			input := make([]byte, size)
+			r.Read(input)
+			if typ == reflect.TypeOf(flatPadded{}) {
+				input[0] &= 1
+			}
+			flat := reflect.New(typ).Elem()
+			n, err := StructFactory.Unmarshal(flat, typ, input, 0, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := reflect.New(typ).Elem()
+			wantN, err := decodeReflectively(want, input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != wantN || !reflect.DeepEqual(flat.Interface(), want.Interface()) {
+				t.Fatalf("Decoded %+v (%d bytes) from %#x, expected %+v (%d bytes)", flat, n, input, want, wantN)
+			}
+		}
+	}
+}
+
+func TestFlatStruct_RejectsInvalidInput(t *testing.T) {
+	typ := reflect.TypeOf(flatPadded{})
+	input := make([]byte, determineFixedSize(reflect.New(typ).Elem(), typ))
+	input[0] = 2
+	if _, err := StructFactory.Unmarshal(reflect.New(typ).Elem(), typ, input, 0, nil); err == nil {
+		t.Error("Expected a boolean other than 0 or 1 to be rejected")
+	}
+	if _, err := StructFactory.Unmarshal(reflect.New(typ).Elem(), typ, input[:10], 0, nil); err == nil {
+		t.Error("Expected truncated input to be rejected")
+	}
+}
+
+func TestFlatStruct_Eligibility(t *testing.T) {
+	type unexported struct {
+		Slot  uint64
+		epoch uint64
+	}
+	type scaled struct {
+		Amount uint64 `ssz-scale:"1e9"`
+	}
+	type withList struct {
+		Slot  uint64
+		Roots []byte `ssz-max:"32"`
+	}
+	type withMetadata struct {
+		Slot             uint64
+		XXX_unrecognized []byte
+	}
+	tests := []struct {
+		typ  reflect.Type
+		flat bool
+	}{
+		{reflect.TypeOf(flatFork{}), true},
+		{reflect.TypeOf(flatPadded{}), true},
+		{reflect.TypeOf(nestedFlat{}), false},
+		{reflect.TypeOf(unexported{}), false},
+		{reflect.TypeOf(scaled{}), false},
+		{reflect.TypeOf(withList{}), false},
+		{reflect.TypeOf(withMetadata{}), false},
+		{reflect.TypeOf(struct{}{}), false},
+	}
+	for _, tt := range tests {
+		if flat := flatStructOf(tt.typ) != nil; flat != tt.flat {
+			t.Errorf("Expected %v to be decoded flat: %v, received %v", tt.typ, tt.flat, flat)
+		}
+	}
+}
+
+func BenchmarkUnmarshal_FlatFork(b *testing.B) {
+	typ := reflect.TypeOf(flatFork{})
+	input := make([]byte, 16)
+	val := reflect.New(typ).Elem()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := StructFactory.Unmarshal(val, typ, input, 0, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshal_ReflectiveFork(b *testing.B) {
+	typ := reflect.TypeOf(flatFork{})
+	input := make([]byte, 16)
+	val := reflect.New(typ).Elem()
+	flatStructs.Store(typ, (*flatStruct)(nil))
+	defer flatStructs.Delete(typ)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := StructFactory.Unmarshal(val, typ, input, 0, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestFlatStructOf_ConcurrentWithResets(t *testing.T) {
+	typs := []reflect.Type{reflect.TypeOf(flatFork{}), reflect.TypeOf(flatPadded{}), reflect.TypeOf(nestedFlat{})}
+	want := make([]*flatStruct, len(typs))
+	for i, typ := range typs {
+		want[i] = newFlatStruct(typ)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 0; n < 100; n++ {
+				if g == 0 && n%10 == 0 {
+					resetResolvedTypes()
+				}
+				i := (g + n) % len(typs)
+				if f := flatStructOf(typs[i]); !reflect.DeepEqual(f, want[i]) {
+					t.Errorf("Expected the flat decoder of %v to be built once in full", typs[i])
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	// Every goroutine gets the decoder stored first.
+	for _, typ := range typs {
+		if flatStructOf(typ) != flatStructOf(typ) {
+			t.Errorf("Expected a single flat decoder of %v", typ)
+		}
+	}
+}
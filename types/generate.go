@@ -0,0 +1,206 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+var goTypeNames = map[SchemaKind]string{
+	SchemaBool:   "bool",
+	SchemaUint8:  "byte",
+	SchemaUint16: "uint16",
+	SchemaInt32:  "int32",
+	SchemaUint32: "uint32",
+	SchemaUint64: "uint64",
+	SchemaString: "string",
+}
+
+// GenerateGoType returns gofmt-ed Go source for package pkg declaring a struct
+// type named typeName which implements the given container schema. Nested
+// containers are declared as types of their own, named after the schema name
+// of the container, or after the field holding them when they have none.
+// Vectors become arrays, lists become slices with ssz-max tags, and fields
+// whose names are not exported Go identifiers are renamed with their schema
+// name kept in an ssz-name tag.
+func GenerateGoType(schema *Schema, pkg string, typeName string) ([]byte, error) {
+	if !token.IsIdentifier(pkg) {
+		return nil, fmt.Errorf("invalid package name %q", pkg)
+	}
+	if !token.IsIdentifier(typeName) {
+		return nil, fmt.Errorf("invalid type name %q", typeName)
+	}
+	if schema == nil || schema.Kind != SchemaContainer {
+		return nil, fmt.Errorf("can only generate types for container schemas, received %v", schemaKind(schema))
+	}
+	g := &goTypeGenerator{declared: make(map[string]*Schema)}
+	if err := g.declare(schema, typeName); err != nil {
+		return nil, err
+	}
+	src := bytes.NewBufferString("// Code generated by go-ssz. DO NOT EDIT.\n\n")
+	fmt.Fprintf(src, "package %s\n", pkg)
+	for _, decl := range g.decls {
+		src.WriteString("\n")
+		src.WriteString(decl)
+	}
+	return format.Source(src.Bytes())
+}
+
+type goTypeGenerator struct {
+	declared map[string]*Schema
+	decls    []string
+}
+
+// declare adds the declaration of a struct type for a container schema.
+func (g *goTypeGenerator) declare(schema *Schema, name string) error {
+	if previous, ok := g.declared[name]; ok {
+		if reflect.DeepEqual(previous, schema) {
+			return nil
+		}
+		return fmt.Errorf("different containers would both be declared as type %s", name)
+	}
+	g.declared[name] = schema
+	// The declaration goes before those of the containers it holds.
+	index := len(g.decls)
+	g.decls = append(g.decls, "")
+	decl := &strings.Builder{}
+	fmt.Fprintf(decl, "type %s struct {\n", name)
+	goNames := make(map[string]string, len(schema.Fields))
+	for i, field := range schema.Fields {
+		goName := exportedName(field.Name, i)
+		if other, ok := goNames[goName]; ok {
+			return fmt.Errorf("fields %q and %q of container %s would both be named %s", other, field.Name, name, goName)
+		}
+		goNames[goName] = field.Name
+		fieldType, err := g.goType(field.Schema, name+goName)
+		if err != nil {
+			return fmt.Errorf("field %s of container %s: %v", field.Name, name, err)
+		}
+		var tags []string
+		if field.Schema.Kind == SchemaList || field.Schema.Kind == SchemaString {
+			if field.Schema.Limit > 0 {
+				tags = append(tags, fmt.Sprintf("ssz-max:\"%d\"", field.Schema.Limit))
+			}
+		}
+		if holdsUints(field.Schema) {
+			tags = append(tags, `ssz:"uints"`)
+		}
+		if field.Name != "" && goName != field.Name {
+			tags = append(tags, fmt.Sprintf("ssz-name:%q", field.Name))
+		}
+		fmt.Fprintf(decl, "\t%s %s", goName, fieldType)
+		if len(tags) > 0 {
+			fmt.Fprintf(decl, " `%s`", strings.Join(tags, " "))
+		}
+		decl.WriteString("\n")
+	}
+	decl.WriteString("}\n")
+	g.decls[index] = decl.String()
+	return nil
+}
+
+// goType returns the Go type expression for a schema, declaring the types of
+// the containers it holds, which are named fallbackName if they are unnamed.
+func (g *goTypeGenerator) goType(schema *Schema, fallbackName string) (string, error) {
+	if schema == nil {
+		return "", fmt.Errorf("missing schema")
+	}
+	if name, ok := goTypeNames[schema.Kind]; ok {
+		return name, nil
+	}
+	switch schema.Kind {
+	case SchemaVector, SchemaList:
+		if schema.Elem == nil {
+			return "", fmt.Errorf("%v schema has no element schema", schema.Kind)
+		}
+		// Only the outermost list of a field can be given a limit with a tag.
+		if nestedLimits(schema.Elem) {
+			return "", fmt.Errorf("limits of lists nested in lists or vectors cannot be declared with tags")
+		}
+		elem, err := g.goType(schema.Elem, fallbackName)
+		if err != nil {
+			return "", err
+		}
+		if schema.Uints {
+			elem = "uint8"
+		}
+		if schema.Kind == SchemaVector {
+			return fmt.Sprintf("[%d]%s", schema.Length, elem), nil
+		}
+		return "[]" + elem, nil
+	case SchemaContainer:
+		name := schema.Name
+		if !token.IsIdentifier(name) {
+			name = fallbackName
+		}
+		if err := g.declare(schema, name); err != nil {
+			return "", err
+		}
+		return name, nil
+	default:
+		return "", fmt.Errorf("unsupported schema kind %v", schema.Kind)
+	}
+}
+
+// nestedLimits reports whether a schema contains a limited list or string
+// outside of a container field.
+func nestedLimits(schema *Schema) bool {
+	switch schema.Kind {
+	case SchemaList, SchemaString:
+		return schema.Limit > 0 || (schema.Elem != nil && nestedLimits(schema.Elem))
+	case SchemaVector:
+		return schema.Elem != nil && nestedLimits(schema.Elem)
+	default:
+		return false
+	}
+}
+
+// exportedName turns a schema field name such as "parent_root" into an exported
+// Go identifier such as "ParentRoot".
+func exportedName(name string, index int) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	goName := b.String()
+	switch {
+	case goName == "":
+		return fmt.Sprintf("Field%d", index)
+	case !unicode.IsUpper([]rune(goName)[0]):
+		// Names starting with a digit or an uncased letter cannot be exported.
+		return "F" + goName
+	default:
+		return goName
+	}
+}
+
+func schemaKind(schema *Schema) interface{} {
+	if schema == nil {
+		return "nil schema"
+	}
+	return schema.Kind
+}
+
+// holdsUints reports whether a vector or list of uint8 nested in a field schema
+// holds uints.
+func holdsUints(schema *Schema) bool {
+	for ; schema != nil; schema = schema.Elem {
+		if schema.Uints {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,652 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// rootPkgPath is the import path of the package whose errors the generated
+// methods wrap.
+const rootPkgPath = "github.com/524119574/go-ssz"
+
+var byteType = reflect.TypeOf(byte(0))
+
+// GenerateMethods returns gofmt-ed Go source for package pkg declaring the
+// MarshalSSZ, MarshalSSZTo, UnmarshalSSZ and SizeSSZ methods of the struct
+// types typs, which Marshal, Unmarshal and SizeSSZ then use in place of
+// reflection. The methods are generated from the same fields and ssz-size and
+// ssz-max tags reflection walks, so they produce the same encodings, and decode
+// with the same checks as Unmarshal, which only enforces the ssz-max tags of
+// strings and bitlists. The containers held by fields of typs are generated as
+// well when they are declared in the same package, and encoded with their own
+// methods otherwise, which they must then have.
+//
+// The types must be named structs declared in the same package, and not have
+// methods of their own yet. Fields whose encodings are handled otherwise than
+// by their Go layout, such as aliases, unions, codecs, sequences, bitvectors
+// and scaled integers, are not supported, nor are vectors of variable-size
+// elements.
+func GenerateMethods(pkg string, typs ...reflect.Type) ([]byte, error) {
+	if !token.IsIdentifier(pkg) {
+		return nil, fmt.Errorf("invalid package name %q", pkg)
+	}
+	if len(typs) == 0 {
+		return nil, fmt.Errorf("no types to generate methods for")
+	}
+	g := &methodGenerator{pkgPath: typs[0].PkgPath(), queued: make(map[reflect.Type]bool)}
+	if g.pkgPath != rootPkgPath {
+		g.errPrefix = "ssz."
+	}
+	for _, typ := range typs {
+		if err := g.enqueue(typ); err != nil {
+			return nil, err
+		}
+	}
+	body := &strings.Builder{}
+	for i := 0; i < len(g.queue); i++ {
+		decl, err := g.methods(g.queue[i])
+		if err != nil {
+			return nil, err
+		}
+		body.WriteString(decl)
+	}
+	src := bytes.NewBufferString("// Code generated by go-ssz. DO NOT EDIT.\n\n")
+	fmt.Fprintf(src, "package %s\n\n", pkg)
+	src.WriteString(generatedImports(body.String(), g.errPrefix != ""))
+	src.WriteString(body.String())
+	return format.Source(src.Bytes())
+}
+
+type methodGenerator struct {
+	pkgPath   string
+	errPrefix string
+	queue     []reflect.Type
+	queued    map[reflect.Type]bool
+	// vars counts the temporary variables declared, which are numbered to be
+	// told apart at any depth.
+	vars int
+}
+
+// enqueue adds typ to the types whose methods are generated.
+func (g *methodGenerator) enqueue(typ reflect.Type) error {
+	if g.queued[typ] {
+		return nil
+	}
+	if typ.Kind() != reflect.Struct || typ.Name() == "" {
+		return fmt.Errorf("can only generate methods for named struct types, not %v", typ)
+	}
+	if typ.PkgPath() != g.pkgPath {
+		return fmt.Errorf("type %v is not declared in package %s", typ, g.pkgPath)
+	}
+	if isCustomType(typ) {
+		return fmt.Errorf("type %v already has Marshaler or Unmarshaler methods", typ)
+	}
+	if isAliasType(typ) || isUnionType(typ) || isCodecType(typ) || isSequenceType(typ) {
+		return fmt.Errorf("type %v is not serialized as a container of its fields", typ)
+	}
+	if err := CheckType(typ); err != nil {
+		return err
+	}
+	g.queued[typ] = true
+	g.queue = append(g.queue, typ)
+	return nil
+}
+
+// container returns the name of the container typ held by a field, queueing
+// it for generation when it is declared in the generated package. The name is
+// empty for containers of other packages, which must have methods of their own.
+func (g *methodGenerator) container(typ reflect.Type) (string, error) {
+	if typ.PkgPath() == g.pkgPath && typ.Name() != "" && !isCustomType(typ) {
+		return typ.Name(), g.enqueue(typ)
+	}
+	ptr := reflect.PtrTo(typ)
+	if !ptr.Implements(marshalerType) || !ptr.Implements(unmarshalerType) {
+		return "", fmt.Errorf("container %v is neither declared in package %s nor has Marshaler and Unmarshaler methods", typ, g.pkgPath)
+	}
+	if typ.PkgPath() == g.pkgPath {
+		return typ.Name(), nil
+	}
+	return "", nil
+}
+
+func (g *methodGenerator) tmp(prefix string) string {
+	g.vars++
+	return fmt.Sprintf("%s%d", prefix, g.vars)
+}
+
+// typeName returns the Go source naming typ within the generated package.
+func (g *methodGenerator) typeName(typ reflect.Type) (string, error) {
+	if typ == byteType {
+		return "byte", nil
+	}
+	if typ.Name() != "" {
+		if typ.PkgPath() == "" || typ.PkgPath() == g.pkgPath {
+			return typ.Name(), nil
+		}
+		return "", fmt.Errorf("type %v of package %s cannot be named in package %s", typ, typ.PkgPath(), g.pkgPath)
+	}
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Ptr:
+		elem, err := g.typeName(typ.Elem())
+		if err != nil {
+			return "", err
+		}
+		if typ.Kind() == reflect.Ptr {
+			return "*" + elem, nil
+		}
+		return "[]" + elem, nil
+	case reflect.Array:
+		elem, err := g.typeName(typ.Elem())
+		return fmt.Sprintf("[%d]%s", typ.Len(), elem), err
+	default:
+		return "", fmt.Errorf("type %v cannot be named in package %s", typ, g.pkgPath)
+	}
+}
+
+// field describes a field of a container being generated, with the type it is
+// serialized as.
+type field struct {
+	reflect.StructField
+	sszType  reflect.Type
+	variable bool
+	size     uint64
+}
+
+func (g *methodGenerator) fields(typ reflect.Type) ([]field, error) {
+	var fields []field
+	for _, f := range sszFields(typ) {
+		if isScaledField(f) {
+			return nil, fmt.Errorf("field %s of %v has an ssz-scale tag, which generated methods do not support", f.Name, typ)
+		}
+		fType, err := determineFieldType(f)
+		if err != nil {
+			return nil, withField(sszFieldName(f), err)
+		}
+		fld := field{StructField: f, sszType: fType, variable: isVariableSizeType(fType)}
+		if !fld.variable {
+			fld.size = determineFixedSize(reflect.New(fType).Elem(), fType)
+		}
+		fields = append(fields, fld)
+	}
+	return fields, nil
+}
+
+// methods returns the declarations of the methods of the container typ.
+func (g *methodGenerator) methods(typ reflect.Type) (string, error) {
+	fields, err := g.fields(typ)
+	if err != nil {
+		return "", err
+	}
+	fixedSize := uint64(0)
+	variable := false
+	for _, f := range fields {
+		if f.variable {
+			fixedSize += BytesPerLengthOffset
+			variable = true
+		} else {
+			fixedSize += f.size
+		}
+	}
+	name := typ.Name()
+	decl := &strings.Builder{}
+
+	fmt.Fprintf(decl, "\n// MarshalSSZ returns the SSZ encoding of x.\n")
+	fmt.Fprintf(decl, "func (x *%s) MarshalSSZ() ([]byte, error) {\n", name)
+	fmt.Fprintf(decl, "return x.MarshalSSZTo(make([]byte, 0, x.SizeSSZ()))\n}\n")
+
+	marshal := &strings.Builder{}
+	if variable {
+		fmt.Fprintf(marshal, "start := len(dst)\n")
+	}
+	offsets := make(map[int]string)
+	for i, f := range fields {
+		e := "x." + f.Name
+		if f.variable {
+			offsets[i] = g.tmp("offset")
+			fmt.Fprintf(marshal, "%s := len(dst)\ndst = append(dst, 0, 0, 0, 0)\n", offsets[i])
+			continue
+		}
+		if err := g.marshal(marshal, e, f.Type, f.sszType); err != nil {
+			return "", withField(sszFieldName(f.StructField), err)
+		}
+	}
+	for i, f := range fields {
+		if !f.variable {
+			continue
+		}
+		fmt.Fprintf(marshal, "binary.LittleEndian.PutUint32(dst[%s:], uint32(len(dst)-start))\n", offsets[i])
+		g.checkCapacity(marshal, "x."+f.Name, f.StructField, f.sszType)
+		if err := g.marshal(marshal, "x."+f.Name, f.Type, f.sszType); err != nil {
+			return "", withField(sszFieldName(f.StructField), err)
+		}
+	}
+	fmt.Fprintf(decl, "\n// MarshalSSZTo appends the SSZ encoding of x to dst.\n")
+	fmt.Fprintf(decl, "func (x *%s) MarshalSSZTo(dst []byte) ([]byte, error) {\n", name)
+	if strings.Contains(marshal.String(), ", err = ") {
+		fmt.Fprintf(decl, "var err error\n")
+	}
+	fmt.Fprintf(decl, "%sreturn dst, nil\n}\n", marshal.String())
+
+	fmt.Fprintf(decl, "\n// SizeSSZ returns the size of the SSZ encoding of x.\n")
+	fmt.Fprintf(decl, "func (x *%s) SizeSSZ() int {\n", name)
+	if !variable {
+		fmt.Fprintf(decl, "return %d\n}\n", fixedSize)
+	} else {
+		fmt.Fprintf(decl, "size := %d\n", fixedSize)
+		for _, f := range fields {
+			if !f.variable {
+				continue
+			}
+			if err := g.size(decl, "x."+f.Name, f.Type, f.sszType); err != nil {
+				return "", withField(sszFieldName(f.StructField), err)
+			}
+		}
+		fmt.Fprintf(decl, "return size\n}\n")
+	}
+
+	fmt.Fprintf(decl, "\n// UnmarshalSSZ decodes the SSZ encoding buf into x.\n")
+	fmt.Fprintf(decl, "func (x *%s) UnmarshalSSZ(buf []byte) error {\n", name)
+	if variable {
+		fmt.Fprintf(decl, "if len(buf) < %d {\n", fixedSize)
+		fmt.Fprintf(decl, "return fmt.Errorf(\"input for type %s has %%d bytes, expected at least %d for its fixed part: %%w\", len(buf), %sErrInputTooShort)\n}\n", name, fixedSize, g.errPrefix)
+	} else {
+		fmt.Fprintf(decl, "if len(buf) != %d {\n", fixedSize)
+		fmt.Fprintf(decl, "return fmt.Errorf(\"input for type %s has %%d bytes, expected %d: %%w\", len(buf), %sErrSizeMismatch)\n}\n", name, fixedSize, g.errPrefix)
+	}
+	position := uint64(0)
+	var varFields []int
+	for i, f := range fields {
+		if f.variable {
+			offsets[i] = g.tmp("offset")
+			fmt.Fprintf(decl, "%s := int(binary.LittleEndian.Uint32(buf[%d:%d]))\n", offsets[i], position, position+BytesPerLengthOffset)
+			if len(varFields) == 0 {
+				fmt.Fprintf(decl, "if %s != %d {\n", offsets[i], fixedSize)
+				fmt.Fprintf(decl, "return fmt.Errorf(\"offset %%d of field %s of type %s does not start after its fixed part of %d bytes: %%w\", %s, %sErrOffsetOutOfBounds)\n}\n", f.Name, name, fixedSize, offsets[i], g.errPrefix)
+			} else {
+				previous := offsets[varFields[len(varFields)-1]]
+				fmt.Fprintf(decl, "if %s < %s {\n", offsets[i], previous)
+				fmt.Fprintf(decl, "return fmt.Errorf(\"offset %%d of field %s of type %s is out of order, expected at least %%d: %%w\", %s, %s, %sErrOffsetsNotIncreasing)\n}\n", f.Name, name, offsets[i], previous, g.errPrefix)
+				fmt.Fprintf(decl, "if %s > len(buf) {\n", offsets[i])
+				fmt.Fprintf(decl, "return fmt.Errorf(\"offset %%d of field %s of type %s is out of bounds, input has length %%d: %%w\", %s, len(buf), %sErrOffsetOutOfBounds)\n}\n", f.Name, name, offsets[i], g.errPrefix)
+			}
+			varFields = append(varFields, i)
+			position += BytesPerLengthOffset
+			continue
+		}
+		b := fmt.Sprintf("buf[%d:%d]", position, position+f.size)
+		if err := g.unmarshal(decl, "x."+f.Name, f.Type, f.sszType, b, determineFieldCapacity(f.StructField)); err != nil {
+			return "", withField(sszFieldName(f.StructField), err)
+		}
+		position += f.size
+	}
+	for j, i := range varFields {
+		f := fields[i]
+		end := ""
+		if j+1 < len(varFields) {
+			end = offsets[varFields[j+1]]
+		}
+		b := fmt.Sprintf("buf[%s:%s]", offsets[i], end)
+		if err := g.unmarshal(decl, "x."+f.Name, f.Type, f.sszType, b, determineFieldCapacity(f.StructField)); err != nil {
+			return "", withField(sszFieldName(f.StructField), err)
+		}
+	}
+	fmt.Fprintf(decl, "return nil\n}\n")
+	return decl.String(), nil
+}
+
+// checkCapacity writes the statements checking the string or bitlist field e
+// fits the capacity declared by its ssz-max tag, as Marshal checks it.
+func (g *methodGenerator) checkCapacity(w *strings.Builder, e string, f reflect.StructField, sszTyp reflect.Type) {
+	capacity := determineFieldCapacity(f)
+	if capacity == 0 {
+		return
+	}
+	switch {
+	case sszTyp.Kind() == reflect.String:
+		fmt.Fprintf(w, "if len(%s) > %d {\n", e, capacity)
+		fmt.Fprintf(w, "return nil, fmt.Errorf(\"string field %s has length %%d, exceeding its ssz-max of %d\", len(%s))\n}\n", f.Name, capacity, e)
+	case isBitlistType(sszTyp):
+		v := g.tmp("b")
+		fmt.Fprintf(w, "if %s := %s; len(%s) > 0 && %s[len(%s)-1] != 0 {\n", v, e, v, v, v)
+		fmt.Fprintf(w, "if n := 8*(len(%s)-1) + bits.Len8(%s[len(%s)-1]) - 1; n > %d {\n", v, v, v, capacity)
+		fmt.Fprintf(w, "return nil, fmt.Errorf(\"bitlist field %s has %%d bits, exceeding its ssz-max of %d\", n)\n}\n}\n", f.Name, capacity)
+	}
+}
+
+// checkGeneratedType rejects the types whose encodings are not given by their
+// Go layout.
+func checkGeneratedType(typ reflect.Type) error {
+	switch {
+	case isAliasType(typ):
+		return fmt.Errorf("alias type %v is not supported by generated methods", typ)
+	case isUnionType(typ):
+		return fmt.Errorf("union %v is not supported by generated methods", typ)
+	case isCodecType(typ):
+		return fmt.Errorf("type %v with a registered codec is not supported by generated methods", typ)
+	case isSequenceType(typ):
+		return fmt.Errorf("sequence %v is not supported by generated methods", typ)
+	case isBitvectorType(typ):
+		return fmt.Errorf("bitvector %v is not supported by generated methods", typ)
+	}
+	return nil
+}
+
+// marshal writes the statements appending the encoding of e, a value of type
+// goTyp serialized as sszTyp, to dst.
+func (g *methodGenerator) marshal(w *strings.Builder, e string, goTyp, sszTyp reflect.Type) error {
+	if err := checkGeneratedType(sszTyp); err != nil {
+		return err
+	}
+	switch kind := sszTyp.Kind(); {
+	case kind == reflect.Ptr:
+		if sszTyp.Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("pointers to %v are not supported by generated methods", sszTyp.Elem())
+		}
+		name, err := g.container(sszTyp.Elem())
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			return fmt.Errorf("pointers to container %v of another package are not supported by generated methods", sszTyp.Elem())
+		}
+		// Nil pointers are encoded as the zero value they point to.
+		v := g.tmp("v")
+		fmt.Fprintf(w, "%s := %s\nif %s == nil {\n%s = new(%s)\n}\n", v, e, v, v, name)
+		fmt.Fprintf(w, "if dst, err = %s.MarshalSSZTo(dst); err != nil {\nreturn nil, err\n}\n", v)
+	case kind == reflect.Struct:
+		if _, err := g.container(sszTyp); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "if dst, err = %s.MarshalSSZTo(dst); err != nil {\nreturn nil, err\n}\n", e)
+	case kind == reflect.Bool:
+		fmt.Fprintf(w, "if %s {\ndst = append(dst, 1)\n} else {\ndst = append(dst, 0)\n}\n", e)
+	case kind == reflect.Uint8 || kind == reflect.Uint16 || kind == reflect.Uint32 || kind == reflect.Int32 || kind == reflect.Uint64:
+		parts := make([]string, sszTyp.Size())
+		for i := range parts {
+			if i == 0 {
+				parts[i] = fmt.Sprintf("byte(%s)", e)
+			} else {
+				parts[i] = fmt.Sprintf("byte(%s>>%d)", e, 8*i)
+			}
+		}
+		fmt.Fprintf(w, "dst = append(dst, %s)\n", strings.Join(parts, ", "))
+	case kind == reflect.String:
+		fmt.Fprintf(w, "dst = append(dst, %s...)\n", e)
+	case isBitlistType(sszTyp):
+		// Empty bitlists are encoded as their delimiting bit alone.
+		fmt.Fprintf(w, "if len(%s) == 0 {\ndst = append(dst, 1)\n} else {\ndst = append(dst, %s...)\n}\n", e, e)
+	case kind == reflect.Array:
+		if isVariableSizeType(sszTyp.Elem()) {
+			return fmt.Errorf("vectors of variable-size elements of type %v are not supported by generated methods", sszTyp.Elem())
+		}
+		size := determineFixedSize(reflect.New(sszTyp).Elem(), sszTyp)
+		elems := e
+		if goTyp.Kind() == reflect.Array {
+			elems = e + "[:]"
+		} else {
+			// Empty slices standing for vectors are encoded as zero vectors.
+			fmt.Fprintf(w, "if len(%s) == 0 {\ndst = append(dst, make([]byte, %d)...)\n}", e, size)
+			fmt.Fprintf(w, " else if len(%s) != %d {\n", e, sszTyp.Len())
+			fmt.Fprintf(w, "return nil, fmt.Errorf(\"vector of type %v has %%d elements, expected %d\", len(%s))\n} else {\n", sszTyp, sszTyp.Len(), e)
+		}
+		if err := g.marshalElements(w, e, elems, goTyp, sszTyp); err != nil {
+			return err
+		}
+		if goTyp.Kind() != reflect.Array {
+			fmt.Fprintf(w, "}\n")
+		}
+	case kind == reflect.Slice:
+		if IsZeroSizeType(sszTyp.Elem()) {
+			return fmt.Errorf("lists of zero-size elements of type %v are not supported", sszTyp.Elem())
+		}
+		if !isVariableSizeType(sszTyp.Elem()) {
+			return g.marshalElements(w, e, e, goTyp, sszTyp)
+		}
+		// Variable-size elements are preceded by a table of their offsets.
+		start, i := g.tmp("start"), g.tmp("i")
+		fmt.Fprintf(w, "%s := len(dst)\ndst = append(dst, make([]byte, %d*len(%s))...)\n", start, BytesPerLengthOffset, e)
+		fmt.Fprintf(w, "for %s := range %s {\n", i, e)
+		fmt.Fprintf(w, "binary.LittleEndian.PutUint32(dst[%s+%d*%s:], uint32(len(dst)-%s))\n", start, BytesPerLengthOffset, i, start)
+		if err := g.marshal(w, fmt.Sprintf("%s[%s]", e, i), goTyp.Elem(), sszTyp.Elem()); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "}\n")
+	default:
+		return fmt.Errorf("unsupported kind: %v: %w", kind, ErrUnsupportedType)
+	}
+	return nil
+}
+
+// marshalElements writes the statements appending the fixed-size elements of
+// the list or vector e to dst, with elems slicing e when it is an array.
+func (g *methodGenerator) marshalElements(w *strings.Builder, e string, elems string, goTyp, sszTyp reflect.Type) error {
+	if goTyp.Elem() == byteType && sszTyp.Elem().Kind() == reflect.Uint8 {
+		fmt.Fprintf(w, "dst = append(dst, %s...)\n", elems)
+		return nil
+	}
+	i := g.tmp("i")
+	fmt.Fprintf(w, "for %s := range %s {\n", i, e)
+	if err := g.marshal(w, fmt.Sprintf("%s[%s]", e, i), goTyp.Elem(), sszTyp.Elem()); err != nil {
+		return withIndex(0, err)
+	}
+	fmt.Fprintf(w, "}\n")
+	return nil
+}
+
+// size writes the statements adding the size of the encoding of e, a value of
+// variable-size type sszTyp, to size.
+func (g *methodGenerator) size(w *strings.Builder, e string, goTyp, sszTyp reflect.Type) error {
+	if !isVariableSizeType(sszTyp) {
+		fmt.Fprintf(w, "size += %d\n", determineFixedSize(reflect.New(sszTyp).Elem(), sszTyp))
+		return nil
+	}
+	switch kind := sszTyp.Kind(); {
+	case kind == reflect.Ptr:
+		zero := reflect.New(sszTyp.Elem()).Elem()
+		fmt.Fprintf(w, "if %s == nil {\nsize += %d\n} else {\nsize += %s.SizeSSZ()\n}\n", e, determineVariableSize(zero, sszTyp.Elem()), e)
+	case kind == reflect.Struct:
+		fmt.Fprintf(w, "size += %s.SizeSSZ()\n", e)
+	case kind == reflect.String:
+		fmt.Fprintf(w, "size += len(%s)\n", e)
+	case isBitlistType(sszTyp):
+		fmt.Fprintf(w, "if len(%s) == 0 {\nsize++\n} else {\nsize += len(%s)\n}\n", e, e)
+	case kind == reflect.Slice && !isVariableSizeType(sszTyp.Elem()):
+		if elemSize := determineFixedSize(reflect.New(sszTyp.Elem()).Elem(), sszTyp.Elem()); elemSize != 1 {
+			fmt.Fprintf(w, "size += %d * len(%s)\n", elemSize, e)
+		} else {
+			fmt.Fprintf(w, "size += len(%s)\n", e)
+		}
+	case kind == reflect.Slice:
+		i := g.tmp("i")
+		fmt.Fprintf(w, "for %s := range %s {\nsize += %d\n", i, e, BytesPerLengthOffset)
+		if err := g.size(w, fmt.Sprintf("%s[%s]", e, i), goTyp.Elem(), sszTyp.Elem()); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "}\n")
+	default:
+		return fmt.Errorf("unsupported variable-size type %v", sszTyp)
+	}
+	return nil
+}
+
+// unmarshal writes the statements decoding b, the bytes of a value of type
+// goTyp serialized as sszTyp, into e. The capacity declared for e, if it is a
+// field, is enforced for strings and bitlists as Unmarshal enforces it.
+func (g *methodGenerator) unmarshal(w *strings.Builder, e string, goTyp, sszTyp reflect.Type, b string, capacity uint64) error {
+	if err := checkGeneratedType(sszTyp); err != nil {
+		return err
+	}
+	switch kind := sszTyp.Kind(); {
+	case kind == reflect.Ptr:
+		name, err := g.container(sszTyp.Elem())
+		if err != nil {
+			return err
+		}
+		// Values already pointed to are decoded over.
+		fmt.Fprintf(w, "if %s == nil {\n%s = new(%s)\n}\n", e, e, name)
+		fmt.Fprintf(w, "if err := %s.UnmarshalSSZ(%s); err != nil {\nreturn err\n}\n", e, b)
+	case kind == reflect.Struct:
+		if _, err := g.container(sszTyp); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "if err := %s.UnmarshalSSZ(%s); err != nil {\nreturn err\n}\n", e, b)
+	case kind == reflect.Bool:
+		v := g.tmp("b")
+		fmt.Fprintf(w, "%s := %s\nif %s > 1 {\n", v, indexFirst(b), v)
+		fmt.Fprintf(w, "return fmt.Errorf(\"invalid boolean %%d, expected 0 or 1\", %s)\n}\n", v)
+		fmt.Fprintf(w, "%s = %s == 1\n", e, v)
+	case kind == reflect.Uint8 || kind == reflect.Uint16 || kind == reflect.Uint32 || kind == reflect.Int32 || kind == reflect.Uint64:
+		name, err := g.typeName(goTyp)
+		if err != nil {
+			return err
+		}
+		decoded := fmt.Sprintf("binary.LittleEndian.Uint%d(%s)", 8*sszTyp.Size(), b)
+		if sszTyp.Size() == 1 {
+			decoded = b + "[0]"
+		}
+		if name != fmt.Sprintf("uint%d", 8*sszTyp.Size()) && name != "byte" {
+			decoded = fmt.Sprintf("%s(%s)", name, decoded)
+		}
+		fmt.Fprintf(w, "%s = %s\n", e, decoded)
+	case kind == reflect.String:
+		name, err := g.typeName(goTyp)
+		if err != nil {
+			return err
+		}
+		if capacity > 0 {
+			fmt.Fprintf(w, "if len(%s) > %d {\n", b, capacity)
+			fmt.Fprintf(w, "return fmt.Errorf(\"string has length %%d, exceeding its ssz-max of %d\", len(%s))\n}\n", capacity, b)
+		}
+		fmt.Fprintf(w, "%s = %s(%s)\n", e, name, b)
+	case isBitlistType(sszTyp):
+		v := g.tmp("b")
+		fmt.Fprintf(w, "%s := %s\n", v, b)
+		fmt.Fprintf(w, "if len(%s) == 0 {\n", v)
+		fmt.Fprintf(w, "return fmt.Errorf(\"bitlist holds no bytes, expected at least its delimiting bit: %%w\", %sErrInputTooShort)\n}\n", g.errPrefix)
+		fmt.Fprintf(w, "if %s[len(%s)-1] == 0 {\nreturn fmt.Errorf(\"bitlist ends with a zero byte, which has no delimiting bit\")\n}\n", v, v)
+		if capacity > 0 {
+			fmt.Fprintf(w, "if n := 8*(len(%s)-1) + bits.Len8(%s[len(%s)-1]) - 1; n > %d {\n", v, v, v, capacity)
+			fmt.Fprintf(w, "return fmt.Errorf(\"bitlist has %%d bits, exceeding its ssz-max of %d\", n)\n}\n", capacity)
+		}
+		fmt.Fprintf(w, "%s = make([]byte, len(%s))\ncopy(%s, %s)\n", e, v, e, v)
+	case kind == reflect.Array:
+		elemSize := determineFixedSize(reflect.New(sszTyp.Elem()).Elem(), sszTyp.Elem())
+		if goTyp.Kind() == reflect.Slice {
+			name, err := g.typeName(goTyp)
+			if goTyp.Elem() == byteType {
+				name, err = "[]byte", nil
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s = make(%s, %d)\n", e, name, sszTyp.Len())
+		}
+		if goTyp.Elem() == byteType && sszTyp.Elem().Kind() == reflect.Uint8 {
+			elems := e
+			if goTyp.Kind() == reflect.Array {
+				elems = e + "[:]"
+			}
+			fmt.Fprintf(w, "copy(%s, %s)\n", elems, b)
+			return nil
+		}
+		v, i := g.tmp("b"), g.tmp("i")
+		fmt.Fprintf(w, "%s := %s\nfor %s := 0; %s < %d; %s++ {\n", v, b, i, i, sszTyp.Len(), i)
+		elem := fmt.Sprintf("%s[%s*%d : (%s+1)*%d]", v, i, elemSize, i, elemSize)
+		if err := g.unmarshal(w, fmt.Sprintf("%s[%s]", e, i), goTyp.Elem(), sszTyp.Elem(), elem, 0); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "}\n")
+	case kind == reflect.Slice && !isVariableSizeType(sszTyp.Elem()):
+		elemSize := determineFixedSize(reflect.New(sszTyp.Elem()).Elem(), sszTyp.Elem())
+		if elemSize == 0 {
+			return fmt.Errorf("lists of zero-size elements of type %v are not supported", sszTyp.Elem())
+		}
+		v := g.tmp("b")
+		fmt.Fprintf(w, "%s := %s\n", v, b)
+		if elemSize > 1 {
+			fmt.Fprintf(w, "if len(%s)%%%d != 0 {\n", v, elemSize)
+			fmt.Fprintf(w, "return fmt.Errorf(\"list of type %v has %%d bytes, which is not a multiple of the %d bytes of its elements: %%w\", len(%s), %sErrSizeMismatch)\n}\n", sszTyp, elemSize, v, g.errPrefix)
+		}
+		if goTyp.Elem() == byteType && sszTyp.Elem().Kind() == reflect.Uint8 {
+			fmt.Fprintf(w, "%s = make([]byte, len(%s))\ncopy(%s, %s)\n", e, v, e, v)
+			return nil
+		}
+		name, err := g.typeName(goTyp)
+		if err != nil {
+			return err
+		}
+		i := g.tmp("i")
+		elem := fmt.Sprintf("%s[%s*%d : (%s+1)*%d]", v, i, elemSize, i, elemSize)
+		if elemSize == 1 {
+			fmt.Fprintf(w, "%s = make(%s, len(%s))\nfor %s := range %s {\n", e, name, v, i, e)
+			elem = fmt.Sprintf("%s[%s : %s+1]", v, i, i)
+		} else {
+			fmt.Fprintf(w, "%s = make(%s, len(%s)/%d)\nfor %s := range %s {\n", e, name, v, elemSize, i, e)
+		}
+		if err := g.unmarshal(w, fmt.Sprintf("%s[%s]", e, i), goTyp.Elem(), sszTyp.Elem(), elem, 0); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "}\n")
+	case kind == reflect.Slice:
+		name, err := g.typeName(goTyp)
+		if err != nil {
+			return err
+		}
+		v, first, i, start, end := g.tmp("b"), g.tmp("first"), g.tmp("i"), g.tmp("start"), g.tmp("end")
+		fmt.Fprintf(w, "%s := %s\nif len(%s) == 0 {\n%s = make(%s, 0)\n} else {\n", v, b, v, e, name)
+		fmt.Fprintf(w, "if len(%s) < %d {\n", v, BytesPerLengthOffset)
+		fmt.Fprintf(w, "return fmt.Errorf(\"list of type %v has %%d bytes, too few for its first offset: %%w\", len(%s), %sErrInputTooShort)\n}\n", sszTyp, v, g.errPrefix)
+		fmt.Fprintf(w, "%s := int(binary.LittleEndian.Uint32(%s))\n", first, v)
+		fmt.Fprintf(w, "if %s == 0 || %s%%%d != 0 || %s > len(%s) {\n", first, first, BytesPerLengthOffset, first, v)
+		fmt.Fprintf(w, "return fmt.Errorf(\"first offset %%d of list of type %v is out of bounds, input has length %%d: %%w\", %s, len(%s), %sErrOffsetOutOfBounds)\n}\n", sszTyp, first, v, g.errPrefix)
+		fmt.Fprintf(w, "%s = make(%s, %s/%d)\n", e, name, first, BytesPerLengthOffset)
+		fmt.Fprintf(w, "for %s := range %s {\n", i, e)
+		fmt.Fprintf(w, "%s := int(binary.LittleEndian.Uint32(%s[%d*%s:]))\n", start, v, BytesPerLengthOffset, i)
+		fmt.Fprintf(w, "%s := len(%s)\nif %s+1 < len(%s) {\n", end, v, i, e)
+		fmt.Fprintf(w, "%s = int(binary.LittleEndian.Uint32(%s[%d*(%s+1):]))\n}\n", end, v, BytesPerLengthOffset, i)
+		fmt.Fprintf(w, "if %s < %s {\n", end, start)
+		fmt.Fprintf(w, "return fmt.Errorf(\"offset %%d of element %%d of list of type %v is out of order, expected at least %%d: %%w\", %s, %s+1, %s, %sErrOffsetsNotIncreasing)\n}\n", sszTyp, end, i, start, g.errPrefix)
+		fmt.Fprintf(w, "if %s > len(%s) {\n", end, v)
+		fmt.Fprintf(w, "return fmt.Errorf(\"offset %%d of element %%d of list of type %v is out of bounds, input has length %%d: %%w\", %s, %s+1, len(%s), %sErrOffsetOutOfBounds)\n}\n", sszTyp, end, i, v, g.errPrefix)
+		if err := g.unmarshal(w, fmt.Sprintf("%s[%s]", e, i), goTyp.Elem(), sszTyp.Elem(), fmt.Sprintf("%s[%s:%s]", v, start, end), 0); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "}\n}\n")
+	default:
+		return fmt.Errorf("unsupported kind: %v: %w", kind, ErrUnsupportedType)
+	}
+	return nil
+}
+
+// indexFirst returns the expression indexing the first byte of b, which slices
+// a single byte when it ends with +1].
+func indexFirst(b string) string {
+	if open := strings.LastIndex(b, "["); strings.HasSuffix(b, "+1]") && open >= 0 {
+		return b[:open+1] + strings.TrimSpace(strings.SplitN(b[open+1:], ":", 2)[0]) + "]"
+	}
+	return b + "[0]"
+}
+
+// generatedImports returns the import declaration of the packages used by the
+// generated methods in body.
+func generatedImports(body string, importRoot bool) string {
+	var imports []string
+	for prefix, path := range map[string]string{"binary.": "encoding/binary", "fmt.": "fmt", "bits.": "math/bits"} {
+		if strings.Contains(body, prefix) {
+			imports = append(imports, fmt.Sprintf("%q", path))
+		}
+	}
+	sort.Strings(imports)
+	if importRoot && strings.Contains(body, "ssz.") {
+		imports = append(imports, "", fmt.Sprintf("ssz %q", rootPkgPath))
+	}
+	if len(imports) == 0 {
+		return ""
+	}
+	return "import (\n" + strings.Join(imports, "\n") + "\n)\n"
+}
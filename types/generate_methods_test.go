@@ -0,0 +1,76 @@
+package types
+
+import (
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type generatedCheckpoint struct {
+	Epoch uint64
+	Root  []byte `ssz-size:"32"`
+}
+
+type generatedAttestation struct {
+	AggregationBits Bitlist `ssz-max:"2048"`
+	Source          *generatedCheckpoint
+	Roots           [][]byte `ssz-size:"?,32" ssz-max:"4"`
+	Name            string   `ssz-max:"8"`
+}
+
+func TestGenerateMethods_Source(t *testing.T) {
+	src, err := GenerateMethods("blocks", reflect.TypeOf(generatedAttestation{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(src), "// Code generated by go-ssz. DO NOT EDIT.\n\npackage blocks\n") {
+		t.Errorf("Expected a generated code header, received\n%s", src)
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var imports []string
+	for _, spec := range file.Imports {
+		imports = append(imports, spec.Path.Value)
+	}
+	want := []string{`"encoding/binary"`, `"fmt"`, `"math/bits"`, `"github.com/524119574/go-ssz"`}
+	if !reflect.DeepEqual(imports, want) {
+		t.Errorf("Expected imports %v, received %v", want, imports)
+	}
+	// The checkpoint held by the attestation is generated along with it.
+	for _, name := range []string{"generatedAttestation", "generatedCheckpoint"} {
+		for _, method := range []string{"MarshalSSZ", "MarshalSSZTo", "UnmarshalSSZ", "SizeSSZ"} {
+			if !strings.Contains(string(src), "func (x *"+name+") "+method+"(") {
+				t.Errorf("Expected method %s of %s to be generated", method, name)
+			}
+		}
+	}
+}
+
+func TestGenerateMethods_Errors(t *testing.T) {
+	type bitvectorField struct {
+		Justified Bitvector `ssz-size:"4"`
+	}
+	type nonStruct []uint64
+	tests := []struct {
+		name string
+		pkg  string
+		typs []reflect.Type
+	}{
+		{name: "invalid package name", pkg: "my-blocks", typs: []reflect.Type{reflect.TypeOf(generatedCheckpoint{})}},
+		{name: "no types", pkg: "blocks"},
+		{name: "unnamed struct", pkg: "blocks", typs: []reflect.Type{reflect.TypeOf(struct{ Slot uint64 }{})}},
+		{name: "non-struct", pkg: "blocks", typs: []reflect.Type{reflect.TypeOf(nonStruct{})}},
+		{name: "pointer", pkg: "blocks", typs: []reflect.Type{reflect.TypeOf(&generatedCheckpoint{})}},
+		{name: "custom type", pkg: "blocks", typs: []reflect.Type{reflect.TypeOf(shortEpoch{})}},
+		{name: "bitvector field", pkg: "blocks", typs: []reflect.Type{reflect.TypeOf(bitvectorField{})}},
+	}
+	for _, tt := range tests {
+		if _, err := GenerateMethods(tt.pkg, tt.typs...); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package types
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	gotypes "go/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func bytes32Schema() *Schema {
+	return &Schema{Kind: SchemaVector, Elem: &Schema{Kind: SchemaUint8}, Length: 32}
+}
+
+func generatedBlockSchema() *Schema {
+	checkpoint := &Schema{
+		Kind: SchemaContainer,
+		Name: "Checkpoint",
+		Fields: []SchemaField{
+			{Name: "epoch", Schema: &Schema{Kind: SchemaUint64}},
+			{Name: "root", Schema: bytes32Schema()},
+		},
+	}
+	return &Schema{
+		Kind: SchemaContainer,
+		Fields: []SchemaField{
+			{Name: "slot", Schema: &Schema{Kind: SchemaUint64}},
+			{Name: "parent_root", Schema: bytes32Schema()},
+			{Name: "type", Schema: &Schema{Kind: SchemaUint8}},
+			{Name: "range", Schema: &Schema{Kind: SchemaList, Elem: &Schema{Kind: SchemaUint64}, Limit: 16}},
+			{Name: "source", Schema: checkpoint},
+			{Name: "checkpoints", Schema: &Schema{Kind: SchemaList, Elem: checkpoint, Limit: 4}},
+			{Name: "body", Schema: &Schema{
+				Kind: SchemaContainer,
+				Fields: []SchemaField{
+					{Name: "graffiti", Schema: bytes32Schema()},
+					{Name: "data", Schema: &Schema{Kind: SchemaList, Elem: &Schema{Kind: SchemaUint8}, Limit: 256}},
+				},
+			}},
+			{Name: "flags", Schema: &Schema{Kind: SchemaVector, Elem: &Schema{Kind: SchemaBool}, Length: 3}},
+			{Name: "Name", Schema: &Schema{Kind: SchemaString, Limit: 32}},
+		},
+	}
+}
+
+const generatedBlockSource = "// Code generated by go-ssz. DO NOT EDIT.\n\n" + `package blocks
+
+type Block struct {
+	Slot        uint64       ` + "`ssz-name:\"slot\"`" + `
+	ParentRoot  [32]byte     ` + "`ssz-name:\"parent_root\"`" + `
+	Type        byte         ` + "`ssz-name:\"type\"`" + `
+	Range       []uint64     ` + "`ssz-max:\"16\" ssz-name:\"range\"`" + `
+	Source      Checkpoint   ` + "`ssz-name:\"source\"`" + `
+	Checkpoints []Checkpoint ` + "`ssz-max:\"4\" ssz-name:\"checkpoints\"`" + `
+	Body        BlockBody    ` + "`ssz-name:\"body\"`" + `
+	Flags       [3]bool      ` + "`ssz-name:\"flags\"`" + `
+	Name        string       ` + "`ssz-max:\"32\"`" + `
+}
+
+type Checkpoint struct {
+	Epoch uint64   ` + "`ssz-name:\"epoch\"`" + `
+	Root  [32]byte ` + "`ssz-name:\"root\"`" + `
+}
+
+type BlockBody struct {
+	Graffiti [32]byte ` + "`ssz-name:\"graffiti\"`" + `
+	Data     []byte   ` + "`ssz-max:\"256\" ssz-name:\"data\"`" + `
+}
+`
+
+// The types below are a copy of generatedBlockSource.
+
+type Block struct {
+	Slot        uint64       `ssz-name:"slot"`
+	ParentRoot  [32]byte     `ssz-name:"parent_root"`
+	Type        byte         `ssz-name:"type"`
+	Range       []uint64     `ssz-max:"16" ssz-name:"range"`
+	Source      Checkpoint   `ssz-name:"source"`
+	Checkpoints []Checkpoint `ssz-max:"4" ssz-name:"checkpoints"`
+	Body        BlockBody    `ssz-name:"body"`
+	Flags       [3]bool      `ssz-name:"flags"`
+	Name        string       `ssz-max:"32"`
+}
+
+type Checkpoint struct {
+	Epoch uint64   `ssz-name:"epoch"`
+	Root  [32]byte `ssz-name:"root"`
+}
+
+type BlockBody struct {
+	Graffiti [32]byte `ssz-name:"graffiti"`
+	Data     []byte   `ssz-max:"256" ssz-name:"data"`
+}
+
+func TestGenerateGoType_Source(t *testing.T) {
+	src, err := GenerateGoType(generatedBlockSchema(), "blocks", "Block")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(src) != generatedBlockSource {
+		t.Errorf("Expected source:\n%s\nreceived:\n%s", generatedBlockSource, src)
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "block.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&gotypes.Config{}).Check("blocks", fset, []*ast.File{file}, nil); err != nil {
+		t.Errorf("Generated source does not compile: %v", err)
+	}
+}
+
+func TestGenerateGoType_RoundTrip(t *testing.T) {
+	// The schema of the generated type is the one it was generated from, with
+	// unnamed containers named after their generated types.
+	want := generatedBlockSchema()
+	want.Name = "Block"
+	want.Fields[6].Schema.Name = "BlockBody"
+	got, err := Describe(reflect.TypeOf(Block{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected schema %+v, received %+v", want, got)
+	}
+	// Values of the generated type go through the types engine like any other.
+	block := Block{
+		Slot:        1,
+		Range:       []uint64{2, 3},
+		Checkpoints: []Checkpoint{{Epoch: 4, Root: [32]byte{5}}},
+		Body:        BlockBody{Graffiti: [32]byte{'g'}, Data: []byte{6}},
+		Flags:       [3]bool{true, false, true},
+		Name:        "block",
+	}
+	val := reflect.ValueOf(block)
+	buf := make([]byte, DetermineSize(val))
+	if _, err := StructFactory.Marshal(val, val.Type(), buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	decoded := reflect.New(val.Type())
+	if _, err := StructFactory.Unmarshal(decoded.Elem(), val.Type(), buf, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Elem().Interface(), block) {
+		t.Errorf("Expected %+v, received %+v", block, decoded.Elem().Interface())
+	}
+	if _, err := StructFactory.Root(val, val.Type(), "", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateGoType_Errors(t *testing.T) {
+	uint64Schema := &Schema{Kind: SchemaUint64}
+	tests := []struct {
+		name   string
+		schema *Schema
+		pkg    string
+		err    string
+	}{
+		{
+			name:   "non-container",
+			schema: uint64Schema,
+			pkg:    "blocks",
+			err:    "can only generate types for container schemas",
+		},
+		{
+			name:   "invalid package",
+			schema: &Schema{Kind: SchemaContainer},
+			pkg:    "my-blocks",
+			err:    "invalid package name",
+		},
+		{
+			name: "colliding field names",
+			schema: &Schema{Kind: SchemaContainer, Fields: []SchemaField{
+				{Name: "parent_root", Schema: uint64Schema},
+				{Name: "parentRoot", Schema: uint64Schema},
+			}},
+			pkg: "blocks",
+			err: `fields "parent_root" and "parentRoot" of container Block would both be named ParentRoot`,
+		},
+		{
+			name: "nested list limit",
+			schema: &Schema{Kind: SchemaContainer, Fields: []SchemaField{
+				{Name: "lists", Schema: &Schema{
+					Kind:  SchemaList,
+					Elem:  &Schema{Kind: SchemaList, Elem: uint64Schema, Limit: 4},
+					Limit: 4,
+				}},
+			}},
+			pkg: "blocks",
+			err: "limits of lists nested in lists or vectors cannot be declared with tags",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := GenerateGoType(tt.schema, tt.pkg, "Block")
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected error containing %q, received %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestExportedName(t *testing.T) {
+	for name, want := range map[string]string{
+		"parent_root": "ParentRoot",
+		"func":        "Func",
+		"2nd":         "F2nd",
+		"__":          "Field3",
+		"Slot":        "Slot",
+	} {
+		if got := exportedName(name, 3); got != want {
+			t.Errorf("Expected %q to be exported as %s, received %s", name, want, got)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package types
+
+import (
+	"fmt"
+	"math/bits"
+	"reflect"
+)
+
+// LengthPathElement is the element of a path which designates the length mixed
+// into the root of a list, rather than one of its elements.
+const LengthPathElement = "__len__"
+
+// GeneralizedIndex returns the generalized index, in the hash tree of values of
+// typ, of the node reached by path. The elements of path are the names of
+// container fields, which are their Go names unless renamed with an ssz-name
+// tag, the indices of list and vector elements, and LengthPathElement for the
+// length of a list. Basic elements of lists and vectors are packed into chunks,
+// so the index of such an element is that of the chunk holding it.
+//
+// The shape of the tree is given by the type alone, with the limits of lists
+// declared by the ssz-size and ssz-max tags of the fields holding them. Paths
+// into lists without a declared limit, or into types whose trees depend on
+// their values such as unions, are rejected.
+func GeneralizedIndex(typ reflect.Type, path []interface{}) (uint64, error) {
+	if typ == nil {
+		return 0, fmt.Errorf("untyped nil has no generalized indices")
+	}
+	index := uint64(1)
+	// limit is the ssz-max of the field holding the current list, if any.
+	limit := uint64(0)
+	for _, p := range path {
+		typ = underlyingType(typ)
+		if name, ok := p.(string); ok && name == LengthPathElement {
+			if !isListType(typ) {
+				return 0, fmt.Errorf("%v is not a list and has no length", typ)
+			}
+			// The length is the right child of the root of the list.
+			var err error
+			if index, err = descend(index, 2, 1, 1); err != nil {
+				return 0, err
+			}
+			typ, limit = reflect.TypeOf(uint64(0)), 0
+			continue
+		}
+		var err error
+		if typ.Kind() == reflect.Struct {
+			index, typ, limit, err = fieldIndex(index, typ, p)
+		} else {
+			index, typ, err = elementIndex(index, typ, limit, p)
+			limit = 0
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return index, nil
+}
+
+// isListType reports whether typ, with pointers stripped and aliases resolved,
+// is serialized as a list, whose root mixes in its length.
+func isListType(typ reflect.Type) bool {
+	return isBitlistType(typ) || typ.Kind() == reflect.Slice || typ.Kind() == reflect.String
+}
+
+// fieldIndex returns the generalized index of the field named by p of the
+// container typ at index, along with the type and the capacity of that field.
+func fieldIndex(index uint64, typ reflect.Type, p interface{}) (uint64, reflect.Type, uint64, error) {
+	if err := checkIndexable(typ); err != nil {
+		return 0, nil, 0, err
+	}
+	name, ok := p.(string)
+	if !ok {
+		return 0, nil, 0, fmt.Errorf("fields of container %v are named by strings, not %T", typ, p)
+	}
+	fields := sszFields(typ)
+	for i, field := range fields {
+		if sszFieldName(field) != name {
+			continue
+		}
+		fType, err := determineFieldType(field)
+		if err != nil {
+			return 0, nil, 0, withField(name, err)
+		}
+		index, err = descend(index, uint64(len(fields)), uint64(i), 1)
+		if err != nil {
+			return 0, nil, 0, err
+		}
+		return index, fType, determineFieldCapacity(field), nil
+	}
+	return 0, nil, 0, fmt.Errorf("container %v has no field %s", typ, name)
+}
+
+// elementIndex returns the generalized index of the element at position p of
+// the list or vector typ at index, along with the type of its elements. The
+// limit of lists is given by the capacity of the field holding them.
+func elementIndex(index uint64, typ reflect.Type, limit uint64, p interface{}) (uint64, reflect.Type, error) {
+	if err := checkIndexable(typ); err != nil {
+		return 0, nil, err
+	}
+	position, ok := pathPosition(p)
+	if !ok {
+		return 0, nil, fmt.Errorf("elements of %v are designated by non-negative integers, not %#v", typ, p)
+	}
+	var (
+		elemTyp reflect.Type
+		// length is the length of vectors or the limit of lists, and elemBits
+		// the size of basic elements in bits, which is zero for the elements
+		// hashed into roots of their own.
+		length, elemBits uint64
+		// base is 2 for lists, whose data is the left child of their root.
+		base = uint64(1)
+	)
+	switch {
+	case isBitlistType(typ):
+		elemTyp, length, elemBits, base = reflect.TypeOf(false), limit, 1, 2
+	case isBitvectorType(typ):
+		elemTyp, length, elemBits = reflect.TypeOf(false), uint64(typ.Len()), 1
+	case typ.Kind() == reflect.String:
+		elemTyp, length, elemBits, base = reflect.TypeOf(uint8(0)), limit, 8, 2
+	case typ.Kind() == reflect.Slice:
+		elemTyp, length, elemBits, base = typ.Elem(), limit, packedElemSize(typ.Elem())*8, 2
+	case typ.Kind() == reflect.Array:
+		elemTyp, length, elemBits = typ.Elem(), uint64(typ.Len()), packedElemSize(typ.Elem())*8
+	default:
+		return 0, nil, fmt.Errorf("basic type %v has no elements", typ)
+	}
+	if base == 2 && length == 0 {
+		return 0, nil, fmt.Errorf("list %v has no declared limit, the shape of its tree depends on its length", typ)
+	}
+	if position >= length {
+		return 0, nil, fmt.Errorf("element %d is out of range of %v, which holds up to %d elements", position, typ, length)
+	}
+	chunks, chunk := length, position
+	if elemBits > 0 {
+		// Basic elements are packed into chunks of 256 bits.
+		chunks = (length*elemBits + 255) / 256
+		chunk = position * elemBits / 256
+	}
+	index, err := descend(index, chunks, chunk, base)
+	return index, elemTyp, err
+}
+
+// checkIndexable rejects the types whose trees depend on their values or are
+// computed by methods or codecs of their own.
+func checkIndexable(typ reflect.Type) error {
+	switch {
+	case isUnionType(typ):
+		return fmt.Errorf("the tree of union %v depends on the selected option", typ)
+	case isSequenceType(typ):
+		return fmt.Errorf("the limit or length of %v is only known from its values", typ)
+	case isCodecType(typ) || isCustomType(typ):
+		return fmt.Errorf("the tree of %v is computed by its own codec", typ)
+	}
+	return nil
+}
+
+// descend returns the generalized index of leaf i of the tree of count leaves,
+// padded to a power of two, below the node at index, or below its left child
+// when base is 2.
+func descend(index uint64, count uint64, i uint64, base uint64) (uint64, error) {
+	depth := uint(0)
+	if count > 1 {
+		depth = uint(bits.Len64(count - 1))
+	}
+	if base == 2 {
+		depth++
+	}
+	if bits.Len64(index)+int(depth) > 64 {
+		return 0, fmt.Errorf("generalized index overflows uint64 at depth %d", bits.Len64(index)-1+int(depth))
+	}
+	return index<<depth + i, nil
+}
+
+// pathPosition returns the integer p as an element position.
+func pathPosition(p interface{}) (uint64, bool) {
+	v := reflect.ValueOf(p)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() < 0 {
+			return 0, false
+		}
+		return uint64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint(), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,99 @@
+package types
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prysmaticlabs/go-bitfield"
+)
+
+type gindexValidator struct {
+	Pubkey  [48]byte
+	Balance uint64
+	Slashed bool
+}
+
+type gindexState struct {
+	Balances    []uint64 `ssz-max:"16"`
+	Epochs      [10]uint32
+	Validators  []*gindexValidator `ssz-max:"8"`
+	Bits        bitfield.Bitlist   `ssz-max:"512"`
+	Vote        Bitvector          `ssz-size:"4"`
+	Name        string             `ssz-max:"64"`
+	Unbounded   []uint64
+	Renamed     uint64 `ssz-name:"renamed_field"`
+	Grandparent [2][2][4]uint64
+}
+
+func TestGeneralizedIndex(t *testing.T) {
+	typ := reflect.TypeOf(&gindexState{})
+	tests := []struct {
+		path  []interface{}
+		index uint64
+	}{
+		// Nine fields are padded to sixteen leaves under the root.
+		{path: []interface{}{"Balances"}, index: 16},
+		// Sixteen uint64s are packed into four chunks.
+		{path: []interface{}{"Balances", 5}, index: 16*2*4 + 1},
+		{path: []interface{}{"Balances", LengthPathElement}, index: 16*2 + 1},
+		// Ten uint32s fit in two chunks, the ninth being in the second.
+		{path: []interface{}{"Epochs", uint8(8)}, index: 17*2 + 1},
+		{path: []interface{}{"Validators", 3, "Balance"}, index: ((18*2*8+3)*4 + 1)},
+		{path: []interface{}{"Validators", 7, "Pubkey"}, index: (18*2*8 + 7) * 4},
+		// 512 bits fill two chunks.
+		{path: []interface{}{"Bits", 300}, index: 19*2*2 + 1},
+		{path: []interface{}{"Vote", 3}, index: 20},
+		{path: []interface{}{"Name", 40}, index: 21*2*2 + 1},
+		{path: []interface{}{"renamed_field"}, index: 23},
+		{path: []interface{}{"Grandparent", 1, 0, 3}, index: ((24*2+1)*2 + 0)},
+	}
+	for _, tt := range tests {
+		index, err := GeneralizedIndex(typ, tt.path)
+		if err != nil {
+			t.Errorf("Path %v: %v", tt.path, err)
+			continue
+		}
+		if index != tt.index {
+			t.Errorf("Generalized index of %v is %d, expected %d", tt.path, index, tt.index)
+		}
+	}
+}
+
+func TestGeneralizedIndex_Errors(t *testing.T) {
+	typ := reflect.TypeOf(gindexState{})
+	tests := []struct {
+		path []interface{}
+		err  string
+	}{
+		{path: []interface{}{"Missing"}, err: "has no field Missing"},
+		{path: []interface{}{0}, err: "named by strings"},
+		{path: []interface{}{"Renamed"}, err: "has no field Renamed"},
+		{path: []interface{}{"Unbounded", 0}, err: "no declared limit"},
+		{path: []interface{}{"Balances", 16}, err: "out of range"},
+		{path: []interface{}{"Balances", -1}, err: "non-negative integers"},
+		{path: []interface{}{"Balances", "first"}, err: "non-negative integers"},
+		{path: []interface{}{"Epochs", LengthPathElement}, err: "not a list"},
+		{path: []interface{}{"renamed_field", 0}, err: "has no elements"},
+	}
+	for _, tt := range tests {
+		if _, err := GeneralizedIndex(typ, tt.path); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Expected path %v to fail with %q, received %v", tt.path, tt.err, err)
+		}
+	}
+}
+
+func TestGeneralizedIndex_Overflow(t *testing.T) {
+	type deep struct {
+		Lists [][][]uint64 `ssz-max:"4294967296"`
+	}
+	if _, err := GeneralizedIndex(reflect.TypeOf(deep{}), []interface{}{"Lists", 0}); err != nil {
+		t.Fatal(err)
+	}
+	type deeper struct {
+		Roots [][32]byte `ssz-max:"9223372036854775808"`
+	}
+	if _, err := GeneralizedIndex(reflect.TypeOf(deeper{}), []interface{}{"Roots", 1}); err == nil || !strings.Contains(err.Error(), "overflows") {
+		t.Errorf("Expected an overflow, received %v", err)
+	}
+}
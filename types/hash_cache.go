@@ -0,0 +1,71 @@
+package types
+
+// HashCache holds caches of hash tree roots and of the tries of vectors of its
+// own, which computations given it with HashContext.WithHashCache consult
+// instead of the caches shared by the rest of the process, such as to keep the
+// tries of the head state apart from those of states hashed for verification.
+// A HashCache is safe for concurrent use.
+type HashCache struct {
+	basic           *basicSSZ
+	basicArrays     *basicArraySSZ
+	rootsArrays     *rootsArraySSZ
+	compositeArrays *compositeArraySSZ
+}
+
+// NewHashCache returns an empty cache. The goroutines of its ristretto caches
+// are started on first use and stopped by Close.
+func NewHashCache() *HashCache {
+	return &HashCache{
+		basic:           newBasicSSZ(),
+		basicArrays:     newBasicArraySSZ(),
+		rootsArrays:     newRootsArraySSZ(),
+		compositeArrays: newCompositeArraySSZ(),
+	}
+}
+
+// Close stops the goroutines of the cache and drops its entries. The cache
+// starts over empty if it is used again.
+func (c *HashCache) Close() {
+	for _, cache := range []Cache{c.basic.hashCache, c.basicArrays.hashCache, c.rootsArrays.hashCache} {
+		if lazy, ok := cache.(*lazyCache); ok {
+			lazy.close()
+			liveCaches.Lock()
+			delete(liveCaches.caches, lazy)
+			liveCaches.Unlock()
+		}
+	}
+	for _, layers := range []*layerCache{c.basicArrays.layerCache, c.rootsArrays.layerCache, c.compositeArrays.layerCache} {
+		layers.reset()
+	}
+}
+
+// The factories below return the factory whose caches a computation consults:
+// that of its HashCache if it was given one, or the shared one otherwise.
+
+func (c *HashContext) basicFactory(shared *basicSSZ) *basicSSZ {
+	if c == nil || c.hashCache == nil {
+		return shared
+	}
+	return c.hashCache.basic
+}
+
+func (c *HashContext) basicArrayFactory(shared *basicArraySSZ) *basicArraySSZ {
+	if c == nil || c.hashCache == nil {
+		return shared
+	}
+	return c.hashCache.basicArrays
+}
+
+func (c *HashContext) rootsArrayFactory(shared *rootsArraySSZ) *rootsArraySSZ {
+	if c == nil || c.hashCache == nil {
+		return shared
+	}
+	return c.hashCache.rootsArrays
+}
+
+func (c *HashContext) compositeArrayFactory(shared *compositeArraySSZ) *compositeArraySSZ {
+	if c == nil || c.hashCache == nil {
+		return shared
+	}
+	return c.hashCache.compositeArrays
+}
@@ -0,0 +1,75 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHashCache_KeepsSharedCachesUntouched(t *testing.T) {
+	defer withFreshRootsCache()()
+	defer withFreshLayerCaches()()
+	cache := NewHashCache()
+	defer cache.Close()
+	state := warmStateFixture()
+	val := reflect.ValueOf(state)
+	want := warmStateRoot(t, state)
+	rootsArrayFactory = newRootsArraySSZ()
+	resetResolvedTypes()
+	root, err := StructFactory.Root(val, val.Type(), "", 0, (*HashContext)(nil).WithHashCache(cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != want {
+		t.Errorf("Expected root %#x, received %#x", want, root)
+	}
+	if len(rootsArrayFactory.cachedLeaves) != 0 {
+		t.Error("Expected the shared layer cache to be left untouched")
+	}
+	if len(cache.rootsArrays.cachedLeaves) != 1 {
+		t.Errorf("Expected the trie of the block roots in the cache of the call, received %d tries", len(cache.rootsArrays.cachedLeaves))
+	}
+	cache.Close()
+	if len(cache.rootsArrays.cachedLeaves) != 0 {
+		t.Error("Expected Close to drop the tries of the cache")
+	}
+}
+
+func TestHashContext_WithCache(t *testing.T) {
+	ToggleCache(true)
+	defer ToggleCache(false)
+	var ctx *HashContext
+	if !ctx.caching() || ctx.WithCache(false).caching() {
+		t.Error("Expected a computation to follow ToggleCache unless it disables caching")
+	}
+	ToggleCache(false)
+	if ctx.caching() || !ctx.WithCache(true).caching() {
+		t.Error("Expected a computation to follow ToggleCache unless it enables caching")
+	}
+	if ctx.WithCache(true).layerField("State.Roots") != "State.Roots" || ctx.WithCache(false).layerField("State.Roots") != "" {
+		t.Error("Expected vectors to be kept in layer caches only while caching is enabled")
+	}
+	limited := NewHashContext(map[string]uint64{"Roots": 4}).WithCache(true)
+	if child := limited.field("Roots"); child.limits == nil || !child.caching() {
+		t.Error("Expected the context of a field to keep the limits and the caching of its parent")
+	}
+}
+
+func TestHashCache_Stats(t *testing.T) {
+	cache := NewHashCache()
+	defer cache.Close()
+	ctx := (*HashContext)(nil).WithHashCache(cache)
+	state := layerStateFixture()
+	val := reflect.ValueOf(state)
+	for i := 0; i < 3; i++ {
+		state.Validators[i].Balance++
+		if _, err := StructFactory.Root(val, val.Type(), "", 0, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := cache.Stats()
+	// The attestations and the validators are merkleized on the first call and
+	// updated on the two others, rehashing a single validator.
+	if stats.Layers.Misses != 2 || stats.Layers.Hits != 4 || stats.Layers.UpdatedLeaves != 2 {
+		t.Errorf("Expected 2 misses, 4 hits and 2 updated leaves, received %+v", stats.Layers)
+	}
+}
@@ -0,0 +1,209 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// HashContext carries the state of a single hash tree root computation down
+// through the values being hashed. A nil *HashContext is valid, and hashes
+// lists with the capacities declared by their ssz-max tags alone.
+type HashContext struct {
+	limits    map[string]uint64
+	path      string
+	hash      *hasher
+	cache     cacheMode
+	hashCache *HashCache
+}
+
+// cacheMode tells whether a computation consults the caches.
+type cacheMode uint8
+
+const (
+	// cacheDefault follows the global setting of ToggleCache.
+	cacheDefault cacheMode = iota
+	cacheOn
+	cacheOff
+)
+
+// NewHashContext returns a context which hashes the lists at the given field
+// paths, such as "Body.Attestations", with the given capacities as if they
+// were declared by ssz-max tags. Entries take precedence over existing tags.
+func NewHashContext(limits map[string]uint64) *HashContext {
+	return &HashContext{limits: limits}
+}
+
+// WithHasher returns a copy of the context which merkleizes with the hash
+// function registered under name by RegisterHasher, or with sha256 for SHA256.
+// It can be called on a nil context.
+func (c *HashContext) WithHasher(name string) (*HashContext, error) {
+	h, ok := lookupHasher(name)
+	if !ok {
+		return nil, fmt.Errorf("no hasher named %q is registered", name)
+	}
+	ctx := c.clone()
+	ctx.hash = h
+	return ctx, nil
+}
+
+// WithCache returns a copy of the context which consults the caches if enabled
+// is true and hashes everything from scratch otherwise, whatever ToggleCache
+// was last called with. It can be called on a nil context.
+func (c *HashContext) WithCache(enabled bool) *HashContext {
+	ctx := c.clone()
+	ctx.cache = cacheOff
+	if enabled {
+		ctx.cache = cacheOn
+	}
+	return ctx
+}
+
+// WithHashCache returns a copy of the context which consults cache, rather than
+// the caches shared by every computation, for the roots and the tries of
+// vectors, and which has caching enabled. It can be called on a nil context.
+func (c *HashContext) WithHashCache(cache *HashCache) *HashContext {
+	ctx := c.WithCache(true)
+	ctx.hashCache = cache
+	return ctx
+}
+
+// clone returns a copy of the context, or an empty one if c is nil.
+func (c *HashContext) clone() *HashContext {
+	if c == nil {
+		return &HashContext{}
+	}
+	ctx := *c
+	return &ctx
+}
+
+// field returns the context for the struct field with the given name.
+func (c *HashContext) field(name string) *HashContext {
+	if c == nil {
+		return nil
+	}
+	ctx := *c
+	ctx.path = joinFieldPath(c.path, name)
+	return &ctx
+}
+
+// caching tells whether the computation consults the caches.
+func (c *HashContext) caching() bool {
+	if c == nil || c.cache == cacheDefault {
+		return cachingEnabled()
+	}
+	return c.cache == cacheOn
+}
+
+// hasLimits tells whether the context overrides the capacities of any list.
+func (c *HashContext) hasLimits() bool {
+	return c != nil && c.limits != nil
+}
+
+// hasher returns the hasher the computation merkleizes with.
+func (c *HashContext) hasher() *hasher {
+	if c == nil || c.hash == nil {
+		return sha256Hasher
+	}
+	return c.hash
+}
+
+// layerField returns the field name vectors are kept in layer caches under, which
+// is only ever done for tries hashed with sha256 while caching is enabled, or ""
+// if they are not kept.
+func (c *HashContext) layerField(fieldName string) string {
+	if c.hasher() != sha256Hasher || !c.caching() {
+		return ""
+	}
+	return fieldName
+}
+
+// capacity returns the capacity to hash the given field with, where c is the
+// context of the field itself.
+func (c *HashContext) capacity(field *containerField) uint64 {
+	if c != nil {
+		if limit, ok := c.limits[c.path]; ok {
+			return limit
+		}
+	}
+	return field.capacity
+}
+
+// MissingLimits returns the paths of all list fields reachable from typ which
+// have neither an ssz-max tag nor an entry in limits, in field order. Fields of
+// containers nested in lists and vectors are reached through the path of the
+// list itself, such as "Body.Attestations.AggregationBits". Paths use the names
+// given by ssz-name tags where present.
+func MissingLimits(typ reflect.Type, limits map[string]uint64) []string {
+	return missingLimits(typ, "", limits, make(map[reflect.Type]bool))
+}
+
+func missingLimits(typ reflect.Type, path string, limits map[string]uint64, visiting map[reflect.Type]bool) []string {
+	typ = containedType(typ)
+	if typ.Kind() != reflect.Struct || visiting[typ] {
+		return nil
+	}
+	visiting[typ] = true
+	defer delete(visiting, typ)
+	var missing []string
+	for _, field := range sszFields(typ) {
+		fieldPath := joinFieldPath(path, sszFieldName(field))
+		fType, err := determineFieldType(field)
+		if err != nil {
+			// Hashing reports the invalid tag itself.
+			continue
+		}
+		if kind := underlyingType(fType).Kind(); kind == reflect.Slice || kind == reflect.String {
+			_, hasTag := field.Tag.Lookup("ssz-max")
+			_, hasLimit := limits[fieldPath]
+			if !hasTag && !hasLimit {
+				missing = append(missing, fieldPath)
+			}
+		}
+		missing = append(missing, missingLimits(fType, fieldPath, limits, visiting)...)
+	}
+	return missing
+}
+
+// containedType strips pointers, lists and vectors, including sequences, off
+// typ, resolving aliases to their prototypes, and returns the type of the
+// elements they contain.
+func containedType(typ reflect.Type) reflect.Type {
+	for {
+		if a, ok := lookupAlias(typ); ok {
+			typ = a.protoType
+			continue
+		}
+		if isSequenceType(typ) {
+			typ, _ = sequenceElemType(typ)
+			continue
+		}
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array:
+			typ = typ.Elem()
+		default:
+			return typ
+		}
+	}
+}
+
+// underlyingType strips pointers off typ and resolves aliases to their
+// prototypes, returning the type a value is serialized as.
+func underlyingType(typ reflect.Type) reflect.Type {
+	for {
+		if a, ok := lookupAlias(typ); ok {
+			typ = a.protoType
+			continue
+		}
+		if typ.Kind() != reflect.Ptr {
+			return typ
+		}
+		typ = typ.Elem()
+	}
+}
+
+func joinFieldPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
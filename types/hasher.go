@@ -0,0 +1,150 @@
+package types
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/minio/sha256-simd"
+	"github.com/protolambda/zssz/merkle"
+)
+
+// HashFunc hashes the concatenation of two nodes of a Merkle trie, or any other
+// input merkleization hashes, into a 32 byte node.
+type HashFunc func(data []byte) [32]byte
+
+// hasher merkleizes values with a hash function, along with the roots of the
+// subtrees of zero chunks it yields, which are precomputed when it is created.
+type hasher struct {
+	name       string
+	fn         HashFunc
+	zeroHashes [][32]byte
+}
+
+// maxTrieDepth is the depth of the trie of a list whose limit is the largest
+// uint64.
+const maxTrieDepth = 64
+
+// SHA256 is the name of the hash function of the Simple Serialize
+// specification, which is used unless another is given.
+const SHA256 = "sha256"
+
+var (
+	hasherLock sync.Mutex
+	// hashers holds a map[string]*hasher which is replaced wholesale on every
+	// registration, allowing lookups on the hot path without taking a lock.
+	hashers atomic.Value
+	// sha256Hasher hashes every root computed without another hasher.
+	sha256Hasher = newHasher(SHA256, sha256.Sum256)
+)
+
+func init() {
+	hashers.Store(map[string]*hasher{SHA256: sha256Hasher})
+}
+
+func newHasher(name string, fn HashFunc) *hasher {
+	h := &hasher{name: name, fn: fn, zeroHashes: make([][32]byte, maxTrieDepth+1)}
+	for i := 1; i < len(h.zeroHashes); i++ {
+		h.zeroHashes[i] = h.fn(append(h.zeroHashes[i-1][:], h.zeroHashes[i-1][:]...))
+	}
+	return h
+}
+
+// RegisterHasher installs fn as a hash function roots can be computed with
+// instead of sha256, under the given name, such as keccak256 for roots checked
+// by contracts which only hash keccak256 cheaply. Registering a name more than
+// once returns an error.
+func RegisterHasher(name string, fn HashFunc) error {
+	if name == "" || fn == nil {
+		return fmt.Errorf("hasher registration requires a name and a hash function")
+	}
+	hasherLock.Lock()
+	defer hasherLock.Unlock()
+	current, _ := hashers.Load().(map[string]*hasher)
+	if _, ok := current[name]; ok {
+		return fmt.Errorf("a hasher named %q is already registered", name)
+	}
+	updated := make(map[string]*hasher, len(current)+1)
+	for k, v := range current {
+		updated[k] = v
+	}
+	updated[name] = newHasher(name, fn)
+	hashers.Store(updated)
+	return nil
+}
+
+func lookupHasher(name string) (*hasher, bool) {
+	registered, _ := hashers.Load().(map[string]*hasher)
+	h, ok := registered[name]
+	return h, ok
+}
+
+// hash returns the hash of data.
+func (h *hasher) hash(data []byte) [32]byte {
+	recordHash()
+	return h.fn(data)
+}
+
+// hashPair returns the hash of the concatenation of two sibling nodes, copying
+// them so that neither of their backing arrays is written to.
+func (h *hasher) hashPair(left []byte, right []byte) [32]byte {
+	pair := make([]byte, 0, len(left)+len(right))
+	pair = append(pair, left...)
+	return h.hash(append(pair, right...))
+}
+
+// merkleize returns the root of the trie over the first count chunks, padded
+// with zero chunks to limit rounded up to a power of two. Chunks shorter than
+// 32 bytes are right-padded with zero bytes. The subtrees of padding chunks
+// alone are never hashed.
+func (h *hasher) merkleize(chunks [][]byte, count uint64, limit uint64) ([32]byte, error) {
+	if count > limit {
+		return [32]byte{}, fmt.Errorf("merkleizing list that is too large, over limit")
+	}
+	// A trie over a single chunk is that chunk, while GetDepth rounds a limit of
+	// one up to a depth of one.
+	depth := 0
+	if limit > 1 {
+		depth = int(merkle.GetDepth(limit))
+	}
+	if count == 0 {
+		return h.zeroHashes[depth], nil
+	}
+	layer := make([][32]byte, count)
+	for i := range layer {
+		copy(layer[i][:], chunks[i])
+	}
+	var pair [64]byte
+	// Each layer is hashed in place, as the parent at index i only depends on
+	// the nodes at 2i and 2i+1 of the layer below.
+	for level := 0; level < depth; level++ {
+		if len(layer)%2 == 1 {
+			layer = append(layer, h.zeroHashes[level])
+		}
+		for i := 0; i < len(layer)/2; i++ {
+			copy(pair[:32], layer[2*i][:])
+			copy(pair[32:], layer[2*i+1][:])
+			layer[i] = h.hash(pair[:])
+		}
+		layer = layer[:len(layer)/2]
+	}
+	return layer[0], nil
+}
+
+// mixInLength returns the hash of root followed by length, the little-endian
+// serialization of the length of a list as a uint256.
+func (h *hasher) mixInLength(root [32]byte, length []byte) [32]byte {
+	var chunk [32]byte
+	copy(chunk[:], length)
+	return h.hash(append(root[:], chunk[:]...))
+}
+
+// cacheKey returns the key under which the root of the values keyed by key is
+// cached when hashing with h, so that roots computed with different hash
+// functions are never mixed up.
+func (h *hasher) cacheKey(key string) string {
+	if h == sha256Hasher {
+		return key
+	}
+	return h.name + "/" + key
+}
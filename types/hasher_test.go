@@ -0,0 +1,58 @@
+package types
+
+import (
+	"crypto/sha512"
+	"testing"
+
+	"github.com/protolambda/zssz/htr"
+	"github.com/protolambda/zssz/merkle"
+)
+
+func TestHasher_MerkleizeMatchesZssz(t *testing.T) {
+	chunks := make([][]byte, 40)
+	for i := range chunks {
+		chunks[i] = make([]byte, BytesPerChunk)
+		chunks[i][0] = byte(i + 1)
+	}
+	// The last chunk is right-padded with zero bytes.
+	chunks[len(chunks)-1] = []byte{1, 2, 3}
+	for _, limit := range []uint64{0, 1, 2, 3, 7, 8, 40, 64, 1 << 20, 1<<63 + 1} {
+		for count := uint64(0); count <= limit && count <= uint64(len(chunks)); count++ {
+			got, err := sha256Hasher.merkleize(chunks, count, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := merkle.Merkleize(htr.HashFn(hash), count, limit, func(i uint64) []byte {
+				return chunks[i]
+			})
+			if got != want {
+				t.Errorf("Expected root %#x over %d chunks of a limit of %d, received %#x", want, count, limit, got)
+			}
+		}
+	}
+	if _, err := sha256Hasher.merkleize(chunks, 3, 2); err == nil {
+		t.Error("Expected merkleizing more chunks than the limit to fail")
+	}
+}
+
+func TestHasher_ZeroHashesPerHasher(t *testing.T) {
+	h := newHasher("sha512_256", sha512.Sum512_256)
+	for i := 1; i < len(h.zeroHashes); i++ {
+		if want := sha512.Sum512_256(append(h.zeroHashes[i-1][:], h.zeroHashes[i-1][:]...)); h.zeroHashes[i] != want {
+			t.Errorf("Expected zero hash %#x at depth %d, received %#x", want, i, h.zeroHashes[i])
+		}
+	}
+	if h.zeroHashes[1] == sha256Hasher.zeroHashes[1] {
+		t.Error("Expected the zero hashes of another hash function than sha256")
+	}
+	root, err := h.merkleize(nil, 0, 1<<40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != h.zeroHashes[40] {
+		t.Errorf("Expected the root of an empty list to be the zero hash at its depth, %#x, received %#x", h.zeroHashes[40], root)
+	}
+	if key := h.cacheKey("key"); key == sha256Hasher.cacheKey("key") {
+		t.Errorf("Expected the cache keys of the hash functions to differ, received %q for both", key)
+	}
+}
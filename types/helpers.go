@@ -2,43 +2,30 @@ package types
 
 import (
 	"bytes"
-	"errors"
+	"encoding/binary"
+	"fmt"
 	"reflect"
-
-	"github.com/minio/sha256-simd"
-	"github.com/protolambda/zssz/htr"
-	"github.com/protolambda/zssz/merkle"
 )
 
+// BytesPerLengthOffset is the size of the offsets to variable-size parts of a
+// serialized object. Offsets are only read and written through readOffset and
+// writeOffset.
+const BytesPerLengthOffset uint64 = 4
+
 var (
 	// BytesPerChunk for an SSZ serialized object.
 	BytesPerChunk = 32
-	// BytesPerLengthOffset defines a constant for off-setting serialized chunks.
-	BytesPerLengthOffset = uint64(4)
-	zeroHashes           = make([][32]byte, 100)
+	// zeroHashes[i] is the root of a subtree of 2^i zero chunks, up to the depth
+	// of the trie of a list whose limit is the largest uint64.
+	zeroHashes = sha256Hasher.zeroHashes
 )
 
-func init() {
-	for i := 1; i < 100; i++ {
-		leaf := append(zeroHashes[i-1][:], zeroHashes[i-1][:]...)
-		result := hash(leaf)
-		copy(zeroHashes[i][:], result[:])
-	}
-}
-
 // Given ordered BYTES_PER_CHUNK-byte chunks, if necessary utilize zero chunks so that the
 // number of chunks is a power of two, Merkleize the chunks, and return the root.
 // Note that merkleize on a single chunk is simply that chunk, i.e. the identity
 // when the number of chunks is one.
 func bitwiseMerkleize(chunks [][]byte, count uint64, limit uint64) ([32]byte, error) {
-	if count > limit {
-		return [32]byte{}, errors.New("merkleizing list that is too large, over limit")
-	}
-	hasher := htr.HashFn(hash)
-	leafIndexer := func(i uint64) []byte {
-		return chunks[i]
-	}
-	return merkle.Merkleize(hasher, count, limit, leafIndexer), nil
+	return sha256Hasher.merkleize(chunks, count, limit)
 }
 
 // Given ordered objects of the same basic type, serialize them, pack them into BYTES_PER_CHUNK-byte
@@ -94,45 +81,88 @@ func pack(serializedItems [][]byte) ([][]byte, error) {
 // Given a Merkle root root and a length length ("uint256" little-endian serialization)
 // return hash(root + length).
 func mixInLength(root [32]byte, length []byte) [32]byte {
-	var hash [32]byte
-	h := sha256.New()
-	h.Write(root[:])
-	h.Write(length)
-	// The hash interface never returns an error, for that reason
-	// we are not handling the error below. For reference, it is
-	// stated here https://golang.org/pkg/hash/#Hash
-	// #nosec G104
-	h.Sum(hash[:0])
-	return hash
+	return sha256Hasher.mixInLength(root, length)
 }
 
 // Instantiates a reflect value which may not have a concrete type to have a concrete type
 // for unmarshaling. For example, we cannot unmarshal into a nil value - instead, it must have
 // a concrete type even if all of its values are zero values.
+// The pointer val may point to a slice standing in for a vector of type typ, as declared by
+// ssz-size tags, in which case the slice is created with the lengths of the vector.
 func instantiateConcreteTypeForElement(val reflect.Value, typ reflect.Type) {
-	val.Set(reflect.New(typ))
+	elemTyp := val.Type().Elem()
+	if elemTyp == typ {
+		// Values already pointed to are decoded over, as when decoding into a
+		// target cleared by Reset.
+		if val.IsNil() {
+			val.Set(reflect.New(typ))
+		}
+		return
+	}
+	elem := reflect.New(elemTyp)
+	elem.Elem().Set(growSliceFromSizeTags(elem.Elem(), tagSizes(typ)))
+	val.Set(elem)
 }
 
 // Grows a slice to a new length and instantiates the element at length-1 with a concrete type
-// accordingly if it is set to a pointer.
+// accordingly if it is set to a pointer. The backing array of the slice is reused when it is
+// large enough.
 func growConcreteSliceType(val reflect.Value, typ reflect.Type, length int) {
-	newVal := reflect.MakeSlice(typ, length, length)
-	reflect.Copy(newVal, val)
-	val.Set(newVal)
+	resizeSlice(val, length)
 	if val.Index(length-1).Kind() == reflect.Ptr {
 		instantiateConcreteTypeForElement(val.Index(length-1), typ.Elem().Elem())
 	}
 }
 
+// resizeSlice sets the length of a slice, reusing its backing array when it is
+// large enough and otherwise allocating one of the final length at once. The
+// elements of the previous backing array are kept, so lists of known length are
+// decoded over them as when growing the slice an element at a time.
+func resizeSlice(val reflect.Value, length int) {
+	if val.Cap() >= length {
+		val.SetLen(length)
+		return
+	}
+	newVal := reflect.MakeSlice(val.Type(), length, length)
+	reflect.Copy(newVal, val.Slice(0, val.Cap()))
+	val.Set(newVal)
+}
+
+// truncateSlice empties a slice, keeping its backing array if it has one.
+func truncateSlice(val reflect.Value) {
+	if val.Cap() > 0 {
+		val.SetLen(0)
+		return
+	}
+	val.Set(reflect.MakeSlice(val.Type(), 0, 0))
+}
+
 // hash defines a function that returns the sha256 hash of the data passed in.
 func hash(data []byte) [32]byte {
-	return sha256.Sum256(data)
+	return sha256Hasher.hash(data)
 }
 
 func growSliceFromSizeTags(val reflect.Value, sizes []uint64) reflect.Value {
 	if len(sizes) == 0 {
 		return val
 	}
+	// Elements held through pointers are grown in place, instantiating the
+	// missing ones.
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			val = reflect.New(val.Type().Elem())
+		}
+		val.Elem().Set(growSliceFromSizeTags(val.Elem(), sizes))
+		return val
+	}
+	// Arrays already have the length required by their tag, so only the
+	// slices nested inside of them may need growing.
+	if val.Kind() == reflect.Array {
+		for i := 0; i < val.Len(); i++ {
+			val.Index(i).Set(growSliceFromSizeTags(val.Index(i), sizes[1:]))
+		}
+		return val
+	}
 	finalValue := reflect.MakeSlice(val.Type(), int(sizes[0]), int(sizes[0]))
 	for i := 0; i < int(sizes[0]); i++ {
 		intermediate := growSliceFromSizeTags(finalValue.Index(i), sizes[1:])
@@ -141,8 +171,115 @@ func growSliceFromSizeTags(val reflect.Value, sizes []uint64) reflect.Value {
 	return finalValue
 }
 
-func toBytes32(x []byte) [32]byte {
+// readOffset reads the offset at index of input, failing rather than reading
+// past the end of a truncated input.
+func readOffset(input []byte, index uint64) (uint64, error) {
+	if index+BytesPerLengthOffset > uint64(len(input)) {
+		return 0, fmt.Errorf(
+			"offset at index %d is out of bounds, input has length %d: %w",
+			index,
+			len(input),
+			ErrInputTooShort,
+		)
+	}
+	return uint64(binary.LittleEndian.Uint32(input[index : index+BytesPerLengthOffset])), nil
+}
+
+// writeOffset writes offset at index of buf.
+func writeOffset(buf []byte, index uint64, offset uint64) {
+	binary.LittleEndian.PutUint32(buf[index:index+BytesPerLengthOffset], uint32(offset))
+}
+
+// vectorValue returns the value to serialize for a vector of type typ held in
+// val. Unset vectors held in slices, which are nil or empty, are serialized as
+// the zero vector, so that they encode the same as a zero-filled one.
+func vectorValue(val reflect.Value, typ reflect.Type) reflect.Value {
+	if typ.Kind() == reflect.Array && typ.Len() > 0 && val.Kind() == reflect.Slice && val.Len() == 0 {
+		return reflect.New(typ).Elem()
+	}
+	return val
+}
+
+// tagSizes returns the sizes which ssz-size tags would declare for a type, with
+// the lengths of its vectors and zero for its lists, down to the first type
+// which is neither, through any pointers in between.
+func tagSizes(typ reflect.Type) []uint64 {
+	var sizes []uint64
+	for {
+		typ, _ = stripPointers(typ)
+		switch typ.Kind() {
+		case reflect.Slice:
+			sizes = append(sizes, 0)
+		case reflect.Array:
+			sizes = append(sizes, uint64(typ.Len()))
+		default:
+			return sizes
+		}
+		typ = typ.Elem()
+	}
+}
+
+// padToBytes32 copies x into a [32]byte, truncating or right-padding with zeros.
+// It must only be used where padding is intended; prefer ToBytes32Strict wherever
+// a length other than 32 indicates corrupt data.
+func padToBytes32(x []byte) [32]byte {
 	var y [32]byte
 	copy(y[:], x)
 	return y
 }
+
+// ToBytes4Strict converts a byte slice of exactly 4 bytes into a [4]byte.
+func ToBytes4Strict(x []byte) ([4]byte, error) {
+	var y [4]byte
+	if len(x) != len(y) {
+		return y, errWrongLength(len(y), len(x))
+	}
+	copy(y[:], x)
+	return y, nil
+}
+
+// ToBytes20Strict converts a byte slice of exactly 20 bytes into a [20]byte.
+func ToBytes20Strict(x []byte) ([20]byte, error) {
+	var y [20]byte
+	if len(x) != len(y) {
+		return y, errWrongLength(len(y), len(x))
+	}
+	copy(y[:], x)
+	return y, nil
+}
+
+// ToBytes32Strict converts a byte slice of exactly 32 bytes into a [32]byte. Unlike
+// a plain copy, it returns an error for any other length rather than silently
+// truncating or zero-padding the input.
+func ToBytes32Strict(x []byte) ([32]byte, error) {
+	var y [32]byte
+	if len(x) != len(y) {
+		return y, errWrongLength(len(y), len(x))
+	}
+	copy(y[:], x)
+	return y, nil
+}
+
+// ToBytes48Strict converts a byte slice of exactly 48 bytes into a [48]byte.
+func ToBytes48Strict(x []byte) ([48]byte, error) {
+	var y [48]byte
+	if len(x) != len(y) {
+		return y, errWrongLength(len(y), len(x))
+	}
+	copy(y[:], x)
+	return y, nil
+}
+
+// ToBytes96Strict converts a byte slice of exactly 96 bytes into a [96]byte.
+func ToBytes96Strict(x []byte) ([96]byte, error) {
+	var y [96]byte
+	if len(x) != len(y) {
+		return y, errWrongLength(len(y), len(x))
+	}
+	copy(y[:], x)
+	return y, nil
+}
+
+func errWrongLength(want int, got int) error {
+	return fmt.Errorf("expected byte slice of length %d, received %d", want, got)
+}
@@ -1,8 +1,16 @@
 package types
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestPack_NoItems(t *testing.T) {
@@ -121,6 +129,129 @@ func TestMerkleize_OK(t *testing.T) {
 		})
 	}
 }
+
+func TestCountHashes_EmptyLimitVector(t *testing.T) {
+	limit := uint64(1 << 16)
+	count := CountHashes(func() {
+		if _, err := bitwiseMerkleize([][]byte{}, 0, limit); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// Merkleizing an empty vector should only need to walk up the depth of
+	// the virtual tree rather than hashing every zero leaf.
+	if count > 17 {
+		t.Errorf("Expected at most %d hashes, received %d", 17, count)
+	}
+}
+
+func TestZeroHashes(t *testing.T) {
+	if len(zeroHashes) != 65 {
+		t.Fatalf("Expected zero hashes up to depth 64, received %d", len(zeroHashes)-1)
+	}
+	if zeroHashes[0] != [32]byte{} {
+		t.Errorf("Expected the zero chunk at depth 0, received %#x", zeroHashes[0])
+	}
+	for i := 1; i < len(zeroHashes); i++ {
+		if want := sha256.Sum256(append(zeroHashes[i-1][:], zeroHashes[i-1][:]...)); zeroHashes[i] != want {
+			t.Errorf("Expected zero hash %#x at depth %d, received %#x", want, i, zeroHashes[i])
+		}
+	}
+	// The root of a subtree of two zero chunks, as listed by the consensus specs.
+	if want := "f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a92759fb4b"; hex.EncodeToString(zeroHashes[1][:]) != want {
+		t.Errorf("Expected zero hash %s at depth 1, received %#x", want, zeroHashes[1])
+	}
+}
+
+func TestCountHashes_MixInLength(t *testing.T) {
+	count := CountHashes(func() {
+		mixInLength([32]byte{}, make([]byte, 32))
+	})
+	if count != 1 {
+		t.Errorf("Expected a single hash, received %d", count)
+	}
+}
+
+func TestCountHashes_CacheHit(t *testing.T) {
+	if _, ok := basicFactory.hashCache.(*lazyCache).backend().(noopCache); ok {
+		t.Skip("Built without a caching backend")
+	}
+	val := reflect.ValueOf([8]uint64{1, 2, 3, 4, 5, 6, 7, 8})
+	first := CountHashes(func() {
+		if _, err := basicFactory.Root(val, val.Type(), "", 0, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if first == 0 {
+		t.Fatal("Expected the first root computation to perform hashing")
+	}
+	// The cache applies writes asynchronously, so we wait for the entry to land.
+	key := make([]byte, 64)
+	if _, err := basicFactory.Marshal(val, val.Type(), key, 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, ok := basicFactory.hashCache.Get(string(key)); ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	second := CountHashes(func() {
+		if _, err := basicFactory.Root(val, val.Type(), "", 0, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if second != 0 {
+		t.Errorf("Expected a cache hit to perform no hashing, received %d hashes", second)
+	}
+}
+
+func TestToBytesStrict(t *testing.T) {
+	if _, err := ToBytes4Strict(make([]byte, 4)); err != nil {
+		t.Errorf("Unexpected error for 4 bytes: %v", err)
+	}
+	if _, err := ToBytes4Strict(make([]byte, 3)); err == nil {
+		t.Error("Expected error for 3 bytes")
+	}
+	if _, err := ToBytes20Strict(make([]byte, 20)); err != nil {
+		t.Errorf("Unexpected error for 20 bytes: %v", err)
+	}
+	if _, err := ToBytes20Strict(make([]byte, 32)); err == nil {
+		t.Error("Expected error for 32 bytes")
+	}
+	root, err := ToBytes32Strict([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Errorf("Unexpected error for 32 bytes: %v", err)
+	}
+	if string(root[:]) != "0123456789abcdef0123456789abcdef" {
+		t.Errorf("Unexpected conversion result %v", root)
+	}
+	if _, err := ToBytes32Strict(nil); err == nil {
+		t.Error("Expected error for nil input")
+	}
+	if _, err := ToBytes48Strict(make([]byte, 48)); err != nil {
+		t.Errorf("Unexpected error for 48 bytes: %v", err)
+	}
+	if _, err := ToBytes48Strict(make([]byte, 49)); err == nil {
+		t.Error("Expected error for 49 bytes")
+	}
+	if _, err := ToBytes96Strict(make([]byte, 96)); err != nil {
+		t.Errorf("Unexpected error for 96 bytes: %v", err)
+	}
+	if _, err := ToBytes96Strict(make([]byte, 95)); err == nil {
+		t.Error("Expected error for 95 bytes")
+	}
+}
+
+func TestRecomputeRoot_WrongLengthLeaf(t *testing.T) {
+	a := newRootsArraySSZ()
+	if _, err := a.recomputeRoot(0, [][]byte{make([]byte, 31)}, "Roots"); err == nil {
+		t.Error("Expected a leaf of length 31 to fail recomputing the root")
+	}
+	if _, err := a.recomputeRoot(0, [][]byte{make([]byte, 32)}, "Roots"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
 func BenchmarkPack(b *testing.B) {
 	input := [][]byte{make([]byte, BytesPerChunk*8000)}
 	for n := 0; n < b.N; n++ {
@@ -141,3 +272,83 @@ func BenchmarkMerkleize(b *testing.B) {
 		}
 	}
 }
+
+func TestReadOffset(t *testing.T) {
+	input := make([]byte, 10)
+	writeOffset(input, 2, 0x01020304)
+	offset, err := readOffset(input, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 0x01020304 {
+		t.Errorf("Expected offset %#x, received %#x", 0x01020304, offset)
+	}
+	if _, err := readOffset(input, 6); err != nil {
+		t.Errorf("Expected an offset ending the input to be read, received %v", err)
+	}
+	for _, index := range []uint64{7, 10, 1 << 63} {
+		if _, err := readOffset(input, index); err == nil {
+			t.Errorf("Expected reading an offset at index %d of %d bytes to fail", index, len(input))
+		}
+	}
+}
+
+// TestOffsetsGoThroughHelpers guards against decoders and encoders accessing
+// offsets with the wrong width, by failing when any function other than
+// readOffset and writeOffset slices or converts bytes BytesPerLengthOffset at
+// a time.
+func TestOffsetsGoThroughHelpers(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Name.Name == "readOffset" || fn.Name.Name == "writeOffset" {
+				continue
+			}
+			ast.Inspect(fn, func(n ast.Node) bool {
+				var bounds []ast.Expr
+				switch n := n.(type) {
+				case *ast.SliceExpr:
+					bounds = []ast.Expr{n.Low, n.High}
+				case *ast.CallExpr:
+					if sel, ok := n.Fun.(*ast.SelectorExpr); ok && isLittleEndian(sel.X) {
+						bounds = n.Args
+					}
+				}
+				for _, expr := range bounds {
+					if expr != nil && mentionsOffsetSize(expr) {
+						t.Errorf("%s: %s accesses an offset without readOffset or writeOffset", fset.Position(expr.Pos()), fn.Name.Name)
+					}
+				}
+				return true
+			})
+		}
+	}
+}
+
+func isLittleEndian(expr ast.Expr) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == "LittleEndian"
+}
+
+func mentionsOffsetSize(expr ast.Expr) bool {
+	found := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Name == "BytesPerLengthOffset" {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
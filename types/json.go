@@ -0,0 +1,423 @@
+package types
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MarshalJSON returns the JSON representation of val, a value of type typ, used
+// by the consensus specs and the beacon node APIs. Byte lists and vectors,
+// strings and bitlists are 0x-prefixed hex strings, integers are quoted decimal
+// strings, uint128 and uint256 included, booleans are JSON booleans, lists and
+// vectors of other elements are arrays, and containers are objects keyed by the
+// lowerCamelCase names of their fields, the Go names unless renamed with an
+// ssz-name tag. Values are walked as they are encoded, so nil pointers stand for
+// zero values and fields left out of encodings are left out, while the values
+// of types with encodings of their own, such as unions and types with codecs or
+// Marshaler methods, are the hex strings of their encodings.
+func MarshalJSON(val reflect.Value, typ reflect.Type) ([]byte, error) {
+	if err := CheckType(typ); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := marshalJSON(&buf, val, typ); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON sets val, a settable value of type typ, to the value data
+// represents in the JSON representation written by MarshalJSON. Every field of
+// a container must be present, and objects holding other keys are rejected.
+func UnmarshalJSON(val reflect.Value, typ reflect.Type, data []byte) error {
+	if err := CheckType(typ); err != nil {
+		return err
+	}
+	return jsonDecoder{fieldName: jsonFieldName}.unmarshal(val, typ, data)
+}
+
+// UnmarshalSpecJSON behaves as UnmarshalJSON for data converted from the YAML
+// values of the consensus spec tests, whose integers are plain numbers rather
+// than quoted strings, although quoted ones are accepted as well, and whose
+// containers are keyed by the names given by the json tags of their fields or
+// else by the snake_case forms of their names, such that ParentRoot is keyed by
+// parent_root.
+func UnmarshalSpecJSON(val reflect.Value, typ reflect.Type, data []byte) error {
+	if err := CheckType(typ); err != nil {
+		return err
+	}
+	return jsonDecoder{fieldName: specFieldName, plainNumbers: true}.unmarshal(val, typ, data)
+}
+
+// jsonDecoder decodes the JSON representations of values, which differ by the
+// keys of the fields of containers and by how integers are written.
+type jsonDecoder struct {
+	fieldName func(field reflect.StructField) string
+	// plainNumbers accepts integers written as JSON numbers.
+	plainNumbers bool
+}
+
+func marshalJSON(buf *bytes.Buffer, val reflect.Value, typ reflect.Type) error {
+	if a, ok := lookupAlias(typ); ok {
+		converted, err := a.toPrototype(val)
+		if err != nil {
+			return err
+		}
+		return marshalJSON(buf, converted, a.protoType)
+	}
+	if isWideUintType(typ) {
+		writeJSONString(buf, littleEndianToBig(jsonBytes(val)).String())
+		return nil
+	}
+	if isUnionType(typ) || isCodecType(typ) || isCustomType(typ) || isSequenceType(typ) || isBitvectorType(typ) {
+		size := determineFixedSize(val, typ)
+		if isVariableSizeType(typ) {
+			size = determineVariableSize(val, typ)
+		}
+		enc, err := encodeValue(val, typ, size)
+		if err != nil {
+			return err
+		}
+		writeJSONHex(buf, enc)
+		return nil
+	}
+	switch kind := typ.Kind(); {
+	case kind == reflect.Bool:
+		buf.WriteString(strconv.FormatBool(val.Bool()))
+	case kind == reflect.Int32:
+		writeJSONString(buf, strconv.FormatInt(val.Int(), 10))
+	case kind == reflect.Uint8 || kind == reflect.Uint16 || kind == reflect.Uint32 || kind == reflect.Uint64:
+		writeJSONString(buf, strconv.FormatUint(val.Uint(), 10))
+	case kind == reflect.String:
+		writeJSONHex(buf, []byte(val.String()))
+	case isBitlistType(typ):
+		// Empty bitlists are encoded as their delimiting bit alone.
+		b := val.Bytes()
+		if len(b) == 0 {
+			b = []byte{1}
+		}
+		writeJSONHex(buf, b)
+	case kind == reflect.Slice || kind == reflect.Array:
+		// Empty slices standing for vectors are encoded as zero vectors.
+		val = vectorValue(val, typ)
+		if kind == reflect.Array && val.Len() != typ.Len() {
+			return fmt.Errorf("vector of type %v has %d elements, expected %d", typ, val.Len(), typ.Len())
+		}
+		if typ.Elem().Kind() == reflect.Uint8 && !isAliasType(typ.Elem()) {
+			writeJSONHex(buf, jsonBytes(val))
+			return nil
+		}
+		buf.WriteByte('[')
+		for i := 0; i < val.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := marshalJSON(buf, val.Index(i), typ.Elem()); err != nil {
+				return withIndex(i, err)
+			}
+		}
+		buf.WriteByte(']')
+	case kind == reflect.Struct:
+		buf.WriteByte('{')
+		for i, f := range sszFields(typ) {
+			fType, err := determineFieldType(f)
+			if err != nil {
+				return withField(sszFieldName(f), err)
+			}
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONString(buf, jsonFieldName(f))
+			buf.WriteByte(':')
+			if err := marshalJSON(buf, val.Field(f.Index[0]), fType); err != nil {
+				return withField(sszFieldName(f), err)
+			}
+		}
+		buf.WriteByte('}')
+	case kind == reflect.Ptr:
+		// Nil pointers are encoded as the zero value they point to.
+		return marshalJSON(buf, pointedValue(val, typ), typ.Elem())
+	default:
+		return fmt.Errorf("unsupported kind: %v: %w", kind, ErrUnsupportedType)
+	}
+	return nil
+}
+
+func (d jsonDecoder) unmarshal(val reflect.Value, typ reflect.Type, data []byte) error {
+	// Null leaves Go values untouched when decoded with encoding/json, rather
+	// than standing for any value.
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return fmt.Errorf("null is not a value of type %v", typ)
+	}
+	if a, ok := lookupAlias(typ); ok {
+		protoVal := reflect.New(a.protoType).Elem()
+		if err := d.unmarshal(protoVal, a.protoType, data); err != nil {
+			return err
+		}
+		res, err := a.convertFrom(protoVal.Interface())
+		if err != nil {
+			return fmt.Errorf("could not convert %v into alias type %v: %v", a.protoType, a.typ, err)
+		}
+		resVal := reflect.ValueOf(res)
+		if !resVal.IsValid() || resVal.Type() != a.typ {
+			return fmt.Errorf("alias conversion for type %v returned %T", a.typ, res)
+		}
+		val.Set(resVal)
+		return nil
+	}
+	if isWideUintType(typ) {
+		s, err := d.integer(data, typ)
+		if err != nil {
+			return err
+		}
+		x, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return fmt.Errorf("%q is not a decimal value of type %v", s, typ)
+		}
+		b := make([]byte, typ.Len())
+		if err := putBigUint(b, x); err != nil {
+			return err
+		}
+		setJSONBytes(val, b)
+		return nil
+	}
+	if isUnionType(typ) || isCodecType(typ) || isCustomType(typ) || isSequenceType(typ) || isBitvectorType(typ) {
+		enc, err := jsonHex(data, typ)
+		if err != nil {
+			return err
+		}
+		factory, err := SSZFactory(val, typ)
+		if err != nil {
+			return err
+		}
+		end, err := factory.Unmarshal(val, typ, enc, 0, nil)
+		if err != nil {
+			return err
+		}
+		if end != uint64(len(enc)) {
+			return fmt.Errorf("encoding of type %v has %d bytes, only %d were decoded: %w", typ, len(enc), end, ErrSizeMismatch)
+		}
+		return nil
+	}
+	switch kind := typ.Kind(); {
+	case kind == reflect.Bool:
+		var b bool
+		if err := json.Unmarshal(data, &b); err != nil {
+			return fmt.Errorf("could not decode a value of type %v: %v", typ, err)
+		}
+		val.SetBool(b)
+	case kind == reflect.Int32:
+		s, err := d.integer(data, typ)
+		if err != nil {
+			return err
+		}
+		i, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return fmt.Errorf("could not decode a value of type %v: %v", typ, err)
+		}
+		val.SetInt(i)
+	case kind == reflect.Uint8 || kind == reflect.Uint16 || kind == reflect.Uint32 || kind == reflect.Uint64:
+		s, err := d.integer(data, typ)
+		if err != nil {
+			return err
+		}
+		u, err := strconv.ParseUint(s, 10, typ.Bits())
+		if err != nil {
+			return fmt.Errorf("could not decode a value of type %v: %v", typ, err)
+		}
+		val.SetUint(u)
+	case kind == reflect.String:
+		b, err := jsonHex(data, typ)
+		if err != nil {
+			return err
+		}
+		val.SetString(string(b))
+	case isBitlistType(typ):
+		b, err := jsonHex(data, typ)
+		if err != nil {
+			return err
+		}
+		if _, ok := bitlistLen(b); !ok {
+			return fmt.Errorf("bitlist %#x has no delimiting bit", b)
+		}
+		val.SetBytes(b)
+	case (kind == reflect.Slice || kind == reflect.Array) && typ.Elem().Kind() == reflect.Uint8 && !isAliasType(typ.Elem()):
+		b, err := jsonHex(data, typ)
+		if err != nil {
+			return err
+		}
+		if kind == reflect.Array && len(b) != typ.Len() {
+			return fmt.Errorf("vector of type %v has %d bytes, expected %d: %w", typ, len(b), typ.Len(), ErrSizeMismatch)
+		}
+		setJSONBytes(val, b)
+	case kind == reflect.Slice || kind == reflect.Array:
+		var elems []json.RawMessage
+		if err := json.Unmarshal(data, &elems); err != nil {
+			return fmt.Errorf("could not decode the elements of type %v: %v", typ, err)
+		}
+		if kind == reflect.Array && len(elems) != typ.Len() {
+			return fmt.Errorf("vector of type %v has %d elements, expected %d: %w", typ, len(elems), typ.Len(), ErrSizeMismatch)
+		}
+		if val.Kind() == reflect.Slice {
+			val.Set(reflect.MakeSlice(val.Type(), len(elems), len(elems)))
+		}
+		for i, elem := range elems {
+			if err := d.unmarshal(val.Index(i), typ.Elem(), elem); err != nil {
+				return withIndex(i, err)
+			}
+		}
+	case kind == reflect.Struct:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return fmt.Errorf("could not decode the fields of container %v: %v", typ, err)
+		}
+		for _, f := range sszFields(typ) {
+			fType, err := determineFieldType(f)
+			if err != nil {
+				return withField(sszFieldName(f), err)
+			}
+			name := d.fieldName(f)
+			fieldData, ok := fields[name]
+			if !ok {
+				return fmt.Errorf("container %v is missing field %s", typ, name)
+			}
+			delete(fields, name)
+			if err := d.unmarshal(val.Field(f.Index[0]), fType, fieldData); err != nil {
+				return withField(sszFieldName(f), err)
+			}
+		}
+		if len(fields) > 0 {
+			unknown := make([]string, 0, len(fields))
+			for name := range fields {
+				unknown = append(unknown, name)
+			}
+			sort.Strings(unknown)
+			return fmt.Errorf("container %v has no fields %s", typ, strings.Join(unknown, ", "))
+		}
+	case kind == reflect.Ptr:
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		return d.unmarshal(val.Elem(), typ.Elem(), data)
+	default:
+		return fmt.Errorf("unsupported kind: %v: %w", kind, ErrUnsupportedType)
+	}
+	return nil
+}
+
+// jsonFieldName returns the key of field in the objects of its container, the
+// lowerCamelCase form of its name, such that ParentRoot is keyed by parentRoot
+// and BLSSignature by blsSignature.
+func jsonFieldName(field reflect.StructField) string {
+	name := []rune(sszFieldName(field))
+	for i := 0; i < len(name) && unicode.IsUpper(name[i]); i++ {
+		// The last letter of a leading acronym starts the next word.
+		if i > 0 && i+1 < len(name) && unicode.IsLower(name[i+1]) {
+			break
+		}
+		name[i] = unicode.ToLower(name[i])
+	}
+	return string(name)
+}
+
+// specFieldName returns the key of field in the YAML values of the consensus
+// spec tests, the name given by its json tag or else the snake_case form of its
+// name, such that ParentRoot is keyed by parent_root and BLSSignature by
+// bls_signature.
+func specFieldName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+	name := []rune(sszFieldName(field))
+	var b strings.Builder
+	for i, r := range name {
+		// Words start at upper case letters following lower case ones, and at
+		// the last letter of an acronym followed by a lower case one.
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(name[i-1]) || i+1 < len(name) && unicode.IsLower(name[i+1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// integer returns the decimal digits of the integer data holds for a value of
+// type typ, a quoted string or, for plain numbers, a JSON number.
+func (d jsonDecoder) integer(data []byte, typ reflect.Type) (string, error) {
+	if trimmed := bytes.TrimSpace(data); d.plainNumbers && len(trimmed) > 0 && trimmed[0] != '"' {
+		return string(trimmed), nil
+	}
+	return jsonString(data, typ)
+}
+
+// jsonBytes returns the bytes held by val, a byte list or vector.
+func jsonBytes(val reflect.Value) []byte {
+	if val.Kind() == reflect.Slice && val.Type().Elem() == reflect.TypeOf(byte(0)) {
+		return val.Bytes()
+	}
+	b := make([]byte, val.Len())
+	for i := range b {
+		b[i] = uint8(val.Index(i).Uint())
+	}
+	return b
+}
+
+// setJSONBytes sets val, a byte list or vector of the same length, to b.
+func setJSONBytes(val reflect.Value, b []byte) {
+	if val.Kind() == reflect.Slice {
+		if val.Type().Elem() == reflect.TypeOf(byte(0)) {
+			val.SetBytes(b)
+			return
+		}
+		val.Set(reflect.MakeSlice(val.Type(), len(b), len(b)))
+	}
+	for i, c := range b {
+		val.Index(i).SetUint(uint64(c))
+	}
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	buf.WriteString(s)
+	buf.WriteByte('"')
+}
+
+func writeJSONHex(buf *bytes.Buffer, b []byte) {
+	buf.WriteString(`"0x`)
+	buf.WriteString(hex.EncodeToString(b))
+	buf.WriteByte('"')
+}
+
+// jsonString returns the JSON string data holds for a value of type typ.
+func jsonString(data []byte, typ reflect.Type) (string, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return "", fmt.Errorf("could not decode a value of type %v, expected a string: %v", typ, err)
+	}
+	return s, nil
+}
+
+// jsonHex returns the bytes of the 0x-prefixed hex string data holds for a
+// value of type typ.
+func jsonHex(data []byte, typ reflect.Type) ([]byte, error) {
+	s, err := jsonString(data, typ)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(s, "0x") {
+		return nil, fmt.Errorf("%q is not a 0x-prefixed hex value of type %v", s, typ)
+	}
+	b, err := hex.DecodeString(s[2:])
+	if err != nil {
+		return nil, fmt.Errorf("could not decode the hex value of type %v: %v", typ, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,68 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJSONFieldName(t *testing.T) {
+	type fields struct {
+		Slot           uint64
+		ParentRoot     [32]byte
+		ID             uint64
+		BLSSignature   [96]byte
+		HTTPServerPort uint16
+		Renamed        uint64 `ssz-name:"CustomName"`
+		a              uint64
+	}
+	want := []string{"slot", "parentRoot", "id", "blsSignature", "httpServerPort", "customName", "a"}
+	typ := reflect.TypeOf(fields{})
+	for i, name := range want {
+		if got := jsonFieldName(typ.Field(i)); got != name {
+			t.Errorf("Expected field %s to be keyed by %s, received %s", typ.Field(i).Name, name, got)
+		}
+	}
+}
+
+func TestSpecFieldName(t *testing.T) {
+	type fields struct {
+		ParentRoot   [32]byte
+		ID           uint64
+		BLSSignature [96]byte
+		Tagged       uint64 `json:"custody_bit_0_indices,omitempty"`
+		Skipped      uint64 `json:"-"`
+	}
+	want := []string{"parent_root", "id", "bls_signature", "custody_bit_0_indices", "skipped"}
+	typ := reflect.TypeOf(fields{})
+	for i, name := range want {
+		if got := specFieldName(typ.Field(i)); got != name {
+			t.Errorf("Expected field %s to be keyed by %s, received %s", typ.Field(i).Name, name, got)
+		}
+	}
+}
+
+func TestUnmarshalSpecJSON_PlainNumbers(t *testing.T) {
+	type checkpoint struct {
+		Epoch  uint64
+		Shard  uint8
+		Amount Uint256
+	}
+	var item checkpoint
+	val := reflect.ValueOf(&item).Elem()
+	data := []byte(`{"epoch": 18446744073709551615, "shard": "7", "amount": 1000}`)
+	if err := UnmarshalSpecJSON(val, val.Type(), data); err != nil {
+		t.Fatal(err)
+	}
+	if item.Epoch != 18446744073709551615 || item.Shard != 7 || item.Amount != (Uint256{0xe8, 0x03}) {
+		t.Errorf("Unexpected value %+v", item)
+	}
+	for _, data := range []string{`{"epoch": 1.5, "shard": 0, "amount": 0}`, `{"epoch": 0, "shard": 256, "amount": 0}`} {
+		if err := UnmarshalSpecJSON(val, val.Type(), []byte(data)); err == nil {
+			t.Errorf("Expected %s to be rejected", data)
+		}
+	}
+	// Plain numbers are only accepted in the values of the spec tests.
+	if err := UnmarshalJSON(val, val.Type(), []byte(`{"epoch": 1, "shard": "7", "amount": "0"}`)); err == nil {
+		t.Error("Expected plain numbers to be rejected by UnmarshalJSON")
+	}
+}
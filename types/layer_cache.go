@@ -0,0 +1,450 @@
+package types
+
+import (
+	"bytes"
+	"math/bits"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/minio/highwayhash"
+	"github.com/protolambda/zssz/merkle"
+)
+
+// layerCache keeps the leaves and the layers of the Merkle tries of vectors
+// hashed as struct fields, keyed by "StructName.Field", so that hashing a field
+// again only recomputes the branches above the leaves which changed since the
+// previous call. The leaves are the rows of root arrays, or the roots of the
+// elements of composite vectors, which are tracked by the digest of their
+// encoding so that unchanged elements are not hashed again either. The maps
+// and the cached layers are only accessed with lock held, as values sharing a
+// field name, such as two beacon states, can be hashed concurrently.
+//
+// The tries of the vectors of different values of a field are kept apart under
+// the keys returned by layerKey, up to maxLayerKeysPerField of them per field.
+type layerCache struct {
+	// The counters of LayerCacheStats come first so that they are 64-bit
+	// aligned on 32-bit platforms, as atomic operations require.
+	hits          uint64
+	misses        uint64
+	updatedLeaves uint64
+	lock          sync.Mutex
+	cachedLeaves  map[string][][]byte
+	layers        map[string][][][]byte
+	elements      map[string]*elementDigests
+	// keys holds the keys cached for every field name, least recently used
+	// first.
+	keys map[string][]string
+}
+
+// maxLayerKeysPerField is the number of vectors of a field whose tries are
+// cached at once, such as the block roots of the head state and of the states
+// of a few competing forks.
+const maxLayerKeysPerField = 4
+
+// layerKeySeparator separates the field name of a layer key from the address
+// of the vector it was computed for.
+const layerKeySeparator = "@"
+
+// elementDigests are the digests of the encodings of the elements of a vector,
+// by index, along with the roots computed from them.
+type elementDigests struct {
+	typ     reflect.Type
+	digests [][32]byte
+	roots   [][]byte
+}
+
+func newLayerCache() *layerCache {
+	return &layerCache{
+		cachedLeaves: make(map[string][][]byte),
+		layers:       make(map[string][][][]byte),
+		elements:     make(map[string]*elementDigests),
+		keys:         make(map[string][]string),
+	}
+}
+
+// reset drops everything cached.
+func (c *layerCache) reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cachedLeaves = make(map[string][][]byte)
+	c.layers = make(map[string][][][]byte)
+	c.elements = make(map[string]*elementDigests)
+	c.keys = make(map[string][]string)
+}
+
+// layerKey returns the key the trie of the vector val, hashed as the field
+// fieldName, is cached under. Vectors with an address of their own, such as
+// the arrays of structs hashed through a pointer and the backing arrays of
+// slices, are cached under that address, so that hashing two beacon states in
+// turn does not rehash the trie of one over that of the other. Other vectors
+// share the trie of their field name. Either way, the leaves of a cached trie
+// are compared with those being hashed, so a trie cached for a value whose
+// memory was since reused by another one is merely updated.
+func layerKey(fieldName string, val reflect.Value) string {
+	if fieldName == "" {
+		return ""
+	}
+	var addr uintptr
+	switch {
+	case val.Kind() == reflect.Slice:
+		addr = val.Pointer()
+	case val.CanAddr():
+		addr = val.UnsafeAddr()
+	}
+	if addr == 0 {
+		return fieldName
+	}
+	return fieldName + layerKeySeparator + strconv.FormatUint(uint64(addr), 16)
+}
+
+// layerKeyField returns the field name of a key returned by layerKey.
+func layerKeyField(key string) string {
+	if i := strings.LastIndex(key, layerKeySeparator); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// use marks key as the most recently used key of its field, dropping what is
+// cached for the least recently used keys of the field beyond
+// maxLayerKeysPerField. The lock must be held.
+func (c *layerCache) use(key string) {
+	field := layerKeyField(key)
+	keys := c.keys[field]
+	for i, k := range keys {
+		if k == key {
+			keys = append(keys[:i], keys[i+1:]...)
+			break
+		}
+	}
+	keys = append(keys, key)
+	for len(keys) > maxLayerKeysPerField {
+		delete(c.cachedLeaves, keys[0])
+		delete(c.layers, keys[0])
+		delete(c.elements, keys[0])
+		keys = keys[1:]
+	}
+	c.keys[field] = keys
+}
+
+// adopt moves the trie cached under the field name of key alone, as imported
+// by ImportCache or computed for a vector without an address, to key if nothing
+// is cached under key yet, so that it is updated rather than recomputed. The
+// lock must be held.
+func (c *layerCache) adopt(key string) {
+	field := layerKeyField(key)
+	if field == key {
+		return
+	}
+	if _, ok := c.cachedLeaves[key]; ok {
+		return
+	}
+	leaves, ok := c.cachedLeaves[field]
+	if !ok {
+		return
+	}
+	c.cachedLeaves[key] = leaves
+	c.layers[key] = c.layers[field]
+	delete(c.cachedLeaves, field)
+	delete(c.layers, field)
+	keys := c.keys[field]
+	for i, k := range keys {
+		if k == field {
+			c.keys[field] = append(keys[:i], keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// cachedRoot returns the root of the trie over leaves if a complete trie over
+// as many leaves is cached for fieldName, recomputing the branches of the leaves
+// which changed since the trie was cached. It returns false otherwise. The lock
+// is held throughout, so that the changed leaves are those of the trie which is
+// updated.
+func (c *layerCache) cachedRoot(fieldName string, leaves [][]byte) ([32]byte, bool, error) {
+	if fieldName == "" {
+		return [32]byte{}, false, nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.adopt(fieldName)
+	changed, ok := c.changedLeaves(fieldName, leaves)
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return [32]byte{}, false, nil
+	}
+	atomic.AddUint64(&c.hits, 1)
+	atomic.AddUint64(&c.updatedLeaves, uint64(len(changed)))
+	c.use(fieldName)
+	root, err := c.update(fieldName, leaves, changed)
+	return root, true, err
+}
+
+// changedLeaves returns the indices of the leaves which differ from those cached
+// for fieldName. It returns false if no complete trie over as many leaves is
+// cached, in which case anything cached for the field is dropped, as happens
+// when the number of leaves changes. The lock must be held.
+func (c *layerCache) changedLeaves(fieldName string, leaves [][]byte) ([]int, bool) {
+	cached, ok := c.cachedLeaves[fieldName]
+	if !ok {
+		return nil, false
+	}
+	if len(cached) != len(leaves) || !layersComplete(c.layers[fieldName], len(leaves)) {
+		delete(c.cachedLeaves, fieldName)
+		delete(c.layers, fieldName)
+		return nil, false
+	}
+	changed := make([]int, 0)
+	for i := range leaves {
+		if !bytes.Equal(leaves[i], cached[i]) {
+			changed = append(changed, i)
+		}
+	}
+	return changed, true
+}
+
+// update recomputes the branches of the changed leaves of the trie cached for
+// fieldName, as returned by changedLeaves, and returns the root of the trie.
+// The lock must be held.
+func (c *layerCache) update(fieldName string, leaves [][]byte, changed []int) ([32]byte, error) {
+	for _, idx := range changed {
+		if _, err := c.recomputeRoot(idx, leaves, fieldName); err != nil {
+			return [32]byte{}, err
+		}
+	}
+	c.cachedLeaves[fieldName] = leaves
+	layers := c.layers[fieldName]
+	return ToBytes32Strict(layers[len(layers)-1][0])
+}
+
+// recomputeRoot replaces the leaf at idx of the trie cached for fieldName with
+// chunks[idx], recomputes the branch above it and returns the new root. The lock
+// must be held.
+func (c *layerCache) recomputeRoot(idx int, chunks [][]byte, fieldName string) ([32]byte, error) {
+	return ToBytes32Strict(updateBranch(sha256Hasher, c.layers[fieldName], idx, chunks[idx]))
+}
+
+// updateBranch replaces the leaf at idx of layers with leaf, rehashes the nodes
+// on its path to the root with h and returns the new root. At every layer, the
+// node on the path is the left child of its parent when its index is even, and
+// its parent is at half its index in the layer above.
+func updateBranch(h *hasher, layers [][][]byte, idx int, leaf []byte) []byte {
+	root := leaf
+	if len(layers) > 0 {
+		layers[0][idx] = root
+	}
+	for i := 0; i < len(layers)-1; i++ {
+		var parentHash [32]byte
+		if idx%2 == 0 {
+			parentHash = h.hashPair(root, layers[i][idx+1])
+		} else {
+			parentHash = h.hashPair(layers[i][idx-1], root)
+		}
+		idx /= 2
+		root = parentHash[:]
+		// Update the cached layers at the parent index.
+		layers[i+1][idx] = root
+	}
+	return root
+}
+
+// parallelMerkleizeThreshold is the number of leaves from which merkleize
+// hashes the subtrees of a trie on separate goroutines.
+var parallelMerkleizeThreshold = 1 << 14
+
+// SetParallelMerkleizeThreshold sets the number of leaves from which the tries
+// of vectors, such as the block roots of a beacon state, are hashed in parallel,
+// one subtree per processor. Smaller tries are hashed on the calling goroutine,
+// as starting goroutines would cost more than it saves. Zero or less disables
+// parallel hashing. It is 16384 leaves by default.
+func SetParallelMerkleizeThreshold(leaves int) {
+	parallelMerkleizeThreshold = leaves
+}
+
+// merkleize returns the root of the trie over leaves hashed with h, padded with
+// empty chunks to a power of two, and caches its layers for fieldName unless it
+// is empty, as it is when caching is disabled.
+func (c *layerCache) merkleize(h *hasher, fieldName string, leaves [][]byte) [32]byte {
+	var root [32]byte
+	if len(leaves) == 1 {
+		copy(root[:], leaves[0])
+		return root
+	}
+	layers := buildLayers(h, leaves)
+	if fieldName != "" {
+		c.lock.Lock()
+		c.cachedLeaves[fieldName] = leaves
+		c.layers[fieldName] = layers
+		c.use(fieldName)
+		c.lock.Unlock()
+	}
+	copy(root[:], layers[len(layers)-1][0])
+	return root
+}
+
+// buildLayers returns the layers of the trie over leaves hashed with h, from the
+// leaves padded with empty chunks to a power of two up to the root. The trie of
+// a single leaf is that leaf.
+func buildLayers(h *hasher, leaves [][]byte) [][][]byte {
+	if len(leaves) == 1 {
+		return [][][]byte{{leaves[0]}}
+	}
+	depth := int(merkle.GetDepth(uint64(len(leaves))))
+	width := 1 << depth
+	hashLayer := make([][]byte, width)
+	copy(hashLayer, leaves)
+	// The padding leaves, and the nodes above them alone, are never hashed as
+	// their roots are precomputed. The nodes are shared, which is safe as the
+	// nodes of cached layers are replaced rather than written to.
+	for i := len(leaves); i < width; i++ {
+		hashLayer[i] = h.zeroHashes[0][:]
+	}
+	// We keep track of the hash layers of a Merkle trie until we reach
+	// the top layer of length 1, which contains the single root element.
+	//        [Root]      -> Top layer has length 1.
+	//    [E]       [F]   -> This layer has length 2.
+	// [A]  [B]  [C]  [D] -> The bottom layer has length 4 (needs to be a power of two).
+	layers := make([][][]byte, depth+1)
+	layers[0] = hashLayer
+	for i := 1; i <= depth; i++ {
+		layers[i] = make([][]byte, width>>i)
+	}
+	if subtrees := merkleizeSubtrees(len(leaves), width); subtrees > 1 {
+		// Each goroutine fills in the nodes of its own subtree, which no other
+		// goroutine reads or writes, and the roots of the subtrees are then
+		// hashed together as the leaves of the top of the trie.
+		subtreeWidth := width / subtrees
+		var wg sync.WaitGroup
+		wg.Add(subtrees)
+		for i := 0; i < subtrees; i++ {
+			go func(start int) {
+				defer wg.Done()
+				hashSubtree(h, layers, len(leaves), 0, start, start+subtreeWidth)
+			}(i * subtreeWidth)
+		}
+		wg.Wait()
+		hashSubtree(h, layers, len(leaves), bits.TrailingZeros(uint(subtreeWidth)), 0, subtrees)
+	} else {
+		hashSubtree(h, layers, len(leaves), 0, 0, width)
+	}
+	return layers
+}
+
+// merkleizeSubtrees returns the number of subtrees, a power of two, the trie
+// over numLeaves leaves padded to width is split into to be hashed in parallel,
+// or 1 if it is hashed on the calling goroutine.
+func merkleizeSubtrees(numLeaves int, width int) int {
+	if parallelMerkleizeThreshold <= 0 || numLeaves < parallelMerkleizeThreshold {
+		return 1
+	}
+	subtrees := 1
+	for workers := runtime.GOMAXPROCS(0); subtrees*2 <= workers && subtrees*2 <= width/2; {
+		subtrees *= 2
+	}
+	return subtrees
+}
+
+// hashSubtree fills in the nodes of layers above the nodes [start, end) of the
+// layer at level with h, up to the root of the subtree they span, for a trie
+// over numLeaves leaves. The range must be a power of two long and start at a
+// multiple of its length.
+func hashSubtree(h *hasher, layers [][][]byte, numLeaves int, level int, start int, end int) {
+	for ; end-start > 1; level++ {
+		below, layer := layers[level], layers[level+1]
+		start, end = start/2, end/2
+		// Nodes past the last one with a leaf below them are the roots of
+		// subtrees of padding leaves.
+		filled := (numLeaves + 1<<uint(level+1) - 1) >> uint(level+1)
+		for i := start; i < end; i++ {
+			if i >= filled {
+				layer[i] = h.zeroHashes[level+1][:]
+				continue
+			}
+			hashedChunk := h.hashPair(below[2*i], below[2*i+1])
+			layer[i] = hashedChunk[:]
+		}
+	}
+}
+
+// root returns the root of the trie over leaves hashed with h, recomputing only
+// the branches of the leaves which changed if a trie is cached for fieldName.
+func (c *layerCache) root(h *hasher, fieldName string, leaves [][]byte) ([32]byte, error) {
+	if root, ok, err := c.cachedRoot(fieldName, leaves); ok {
+		return root, err
+	}
+	return c.merkleize(h, fieldName, leaves), nil
+}
+
+// elementRoots returns the roots of the elements of the vector val of type typ,
+// hashed as field fieldName. Unless fieldName is empty, an element whose encoding
+// has the same digest as the element at its index had in the previous call is
+// given the root computed back then rather than being hashed again. Elements
+// hashed with limits given by the context are never reused, as the limits may
+// differ between calls.
+func (c *layerCache) elementRoots(val reflect.Value, typ reflect.Type, factory SSZAble, fieldName string, ctx *HashContext) ([][]byte, error) {
+	numItems := val.Len()
+	elemTyp := typ.Elem()
+	useCache := fieldName != "" && !ctx.hasLimits()
+	var previous *elementDigests
+	if useCache {
+		c.lock.Lock()
+		previous = c.elements[fieldName]
+		c.lock.Unlock()
+		if previous != nil && (previous.typ != elemTyp || len(previous.digests) != numItems) {
+			previous = nil
+		}
+	}
+	digests := make([][32]byte, numItems)
+	roots := make([][]byte, numItems)
+	for i := 0; i < numItems; i++ {
+		if useCache {
+			digest, err := elementDigest(val.Index(i), elemTyp, factory)
+			if err != nil {
+				return nil, withIndex(i, err)
+			}
+			digests[i] = digest
+			if previous != nil && previous.digests[i] == digest {
+				roots[i] = previous.roots[i]
+				continue
+			}
+		}
+		r, err := factory.Root(val.Index(i), elemTyp, "", 0, ctx)
+		if err != nil {
+			return nil, withIndex(i, err)
+		}
+		roots[i] = r[:]
+	}
+	if useCache {
+		c.lock.Lock()
+		c.elements[fieldName] = &elementDigests{typ: elemTyp, digests: digests, roots: roots}
+		c.use(fieldName)
+		c.lock.Unlock()
+	}
+	return roots, nil
+}
+
+// elementDigest returns the digest of the encoding of a vector element, which
+// identifies its value, and thus its root, among the values of its type.
+func elementDigest(val reflect.Value, typ reflect.Type, factory SSZAble) ([32]byte, error) {
+	var size uint64
+	if isVariableSizeType(typ) {
+		size = determineVariableSize(val, typ)
+	} else {
+		size = determineFixedSize(val, typ)
+	}
+	buf := make([]byte, size)
+	if _, err := factory.Marshal(val, typ, buf, 0); err != nil {
+		return [32]byte{}, err
+	}
+	return highwayhash.Sum(buf, fastSumHashKey[:]), nil
+}
+
+// hashPair returns the hash of the concatenation of two sibling nodes, copying
+// them so that neither of their backing arrays is written to.
+func hashPair(left []byte, right []byte) [32]byte {
+	return sha256Hasher.hashPair(left, right)
+}
@@ -0,0 +1,391 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+type layerAttestation struct {
+	AggregationBits []byte `ssz-max:"256"`
+	Slot            uint64
+	InclusionDelay  uint64
+}
+
+type layerValidator struct {
+	Pubkey  [48]byte
+	Balance uint64
+}
+
+type layerState struct {
+	Slot         uint64
+	Attestations [256]layerAttestation
+	Validators   [128]layerValidator
+}
+
+func layerStateFixture() *layerState {
+	state := &layerState{Slot: 1}
+	for i := range state.Attestations {
+		state.Attestations[i] = layerAttestation{
+			AggregationBits: []byte{byte(i), 1},
+			Slot:            uint64(i),
+			InclusionDelay:  1,
+		}
+	}
+	for i := range state.Validators {
+		state.Validators[i].Pubkey[0] = byte(i)
+		state.Validators[i].Balance = uint64(i) * 32
+	}
+	return state
+}
+
+// withFreshLayerCaches enables caching with empty layer caches for vectors until
+// the returned func is called.
+func withFreshLayerCaches() func() {
+	previousBasic, previousComposite := basicArrayFactory, compositeArrayFactory
+	ToggleCache(true)
+	basicArrayFactory = newBasicArraySSZ()
+	compositeArrayFactory = newCompositeArraySSZ()
+	resetResolvedTypes()
+	return func() {
+		basicArrayFactory, compositeArrayFactory = previousBasic, previousComposite
+		resetResolvedTypes()
+		ToggleCache(false)
+	}
+}
+
+func layerStateRoot(t testing.TB, state *layerState) [32]byte {
+	val := reflect.ValueOf(state)
+	root, err := StructFactory.Root(val, val.Type(), "", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func uncachedLayerStateRoot(t testing.TB, state *layerState) [32]byte {
+	ToggleCache(false)
+	defer ToggleCache(true)
+	return layerStateRoot(t, state)
+}
+
+func TestLayerCache_CompositeVectorRehashesChangedElements(t *testing.T) {
+	defer withFreshLayerCaches()()
+	state := layerStateFixture()
+	coldHashes := CountHashes(func() {
+		layerStateRoot(t, state)
+	})
+
+	changed := []int{3, 4, 200}
+	for _, i := range changed {
+		state.Attestations[i].AggregationBits = append(state.Attestations[i].AggregationBits, 0xff)
+	}
+	state.Validators[7].Balance++
+	var warmRoot [32]byte
+	warmHashes := CountHashes(func() {
+		warmRoot = layerStateRoot(t, state)
+	})
+	if want := uncachedLayerStateRoot(t, state); warmRoot != want {
+		t.Fatalf("Expected root %#x, received %#x", want, warmRoot)
+	}
+	// Each changed attestation is hashed again (4 hashes for its bits, 3 for its
+	// fields) along with its branch of the trie over 256 elements (8 hashes), the
+	// changed validator (1 hash for its pubkey, 1 for its fields) along with its
+	// branch (7 hashes), and the state is merkleized over its 3 fields (3 hashes).
+	maxHashes := uint64(len(changed)*(7+8) + 2 + 7 + 3)
+	if warmHashes > maxHashes {
+		t.Errorf("Expected at most %d hashes after changing %d elements, received %d", maxHashes, len(changed)+1, warmHashes)
+	}
+	if warmHashes*10 > coldHashes {
+		t.Errorf("Expected far fewer than the %d hashes of the cold root, received %d", coldHashes, warmHashes)
+	}
+}
+
+func TestLayerCache_SuccessiveChangesToSiblings(t *testing.T) {
+	defer withFreshLayerCaches()()
+	state := layerStateFixture()
+	layerStateRoot(t, state)
+	// Changing neighbouring elements in successive calls uses the sibling leaf
+	// cached by the previous update of the trie.
+	for i := 0; i < 4; i++ {
+		state.Attestations[i].Slot += 100
+		state.Validators[i].Balance += 100
+		if got, want := layerStateRoot(t, state), uncachedLayerStateRoot(t, state); got != want {
+			t.Fatalf("Expected root %#x after changing element %d, received %#x", want, i, got)
+		}
+	}
+}
+
+func TestLayerCache_InvalidatesOnLengthChange(t *testing.T) {
+	defer withFreshLayerCaches()()
+	c := newLayerCache()
+	leaves := func(n int, fill byte) [][]byte {
+		l := make([][]byte, n)
+		for i := range l {
+			l[i] = make([]byte, BytesPerChunk)
+			l[i][0] = fill + byte(i)
+		}
+		return l
+	}
+	if _, err := c.root(sha256Hasher, "State.Field", leaves(4, 1)); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{3, 8, 1, 5} {
+		got, err := c.root(sha256Hasher, "State.Field", leaves(n, 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := newLayerCache().merkleize(sha256Hasher, "", leaves(n, 1))
+		if got != want {
+			t.Errorf("Expected root %#x over %d leaves, received %#x", want, n, got)
+		}
+	}
+}
+
+func TestLayerCache_InterleavedValues(t *testing.T) {
+	defer withFreshLayerCaches()()
+	first, second := layerStateFixture(), layerStateFixture()
+	for i := range second.Validators {
+		second.Validators[i].Balance += 7
+	}
+	layerStateRoot(t, first)
+	layerStateRoot(t, second)
+	// Each state updates a trie of its own, rather than the trie of the state
+	// hashed before it.
+	for i := 0; i < 4; i++ {
+		for _, state := range []*layerState{first, second} {
+			state.Validators[i].Balance++
+			var root [32]byte
+			hashes := CountHashes(func() {
+				root = layerStateRoot(t, state)
+			})
+			if want := uncachedLayerStateRoot(t, state); root != want {
+				t.Fatalf("Expected root %#x, received %#x", want, root)
+			}
+			// The changed validator (2 hashes), its branch (7 hashes) and the
+			// state over its 3 fields (3 hashes).
+			if hashes > 12 {
+				t.Errorf("Expected at most 12 hashes after changing a validator, received %d", hashes)
+			}
+		}
+	}
+}
+
+func TestLayerCache_InterleavedValuesWithoutAddresses(t *testing.T) {
+	defer withFreshLayerCaches()()
+	first, second := layerStateFixture(), layerStateFixture()
+	second.Validators[3].Balance = 1
+	for i := 0; i < 3; i++ {
+		for _, state := range []*layerState{first, second} {
+			state.Attestations[i].Slot++
+			// Hashed by value, the vectors of both states share the trie of their
+			// field name.
+			val := reflect.ValueOf(*state)
+			root, err := StructFactory.Root(val, val.Type(), "", 0, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := uncachedLayerStateRoot(t, state); root != want {
+				t.Fatalf("Expected root %#x, received %#x", want, root)
+			}
+		}
+	}
+}
+
+func TestLayerCache_BoundsValuesPerField(t *testing.T) {
+	defer withFreshLayerCaches()()
+	states := make([]*layerState, maxLayerKeysPerField+3)
+	for i := range states {
+		states[i] = layerStateFixture()
+		states[i].Validators[0].Balance = uint64(i)
+		layerStateRoot(t, states[i])
+	}
+	c := compositeArrayFactory.layerCache
+	for field, keys := range c.keys {
+		if len(keys) > maxLayerKeysPerField {
+			t.Errorf("Expected at most %d keys for %s, received %d", maxLayerKeysPerField, field, len(keys))
+		}
+	}
+	if len(c.cachedLeaves) > maxLayerKeysPerField || len(c.elements) > maxLayerKeysPerField {
+		t.Errorf("Expected the tries of at most %d values, received %d", maxLayerKeysPerField, len(c.cachedLeaves))
+	}
+	// Evicted values are hashed from scratch.
+	for _, state := range states {
+		state.Validators[1].Balance++
+		if got, want := layerStateRoot(t, state), uncachedLayerStateRoot(t, state); got != want {
+			t.Errorf("Expected root %#x, received %#x", want, got)
+		}
+	}
+}
+
+func TestLayerKey(t *testing.T) {
+	state := layerStateFixture()
+	val := reflect.ValueOf(state).Elem().Field(2)
+	key := layerKey("layerState.Validators", val)
+	if key == "layerState.Validators" || layerKeyField(key) != "layerState.Validators" {
+		t.Errorf("Expected a key of the address of the vector, received %q", key)
+	}
+	if other := layerKey("layerState.Validators", reflect.ValueOf(layerStateFixture()).Elem().Field(2)); other == key {
+		t.Error("Expected the vectors of two values to have different keys")
+	}
+	if key := layerKey("layerState.Validators", reflect.ValueOf(*state).Field(2)); key != "layerState.Validators" {
+		t.Errorf("Expected a vector without an address to be keyed by its field, received %q", key)
+	}
+	if key := layerKey("", val); key != "" {
+		t.Errorf("Expected no key without a field name, received %q", key)
+	}
+}
+
+// merkleizeLeaves returns n distinct leaves.
+func merkleizeLeaves(n int) [][]byte {
+	leaves := make([][]byte, n)
+	for i := range leaves {
+		leaves[i] = make([]byte, BytesPerChunk)
+		leaves[i][0], leaves[i][1], leaves[i][2] = byte(i), byte(i>>8), byte(i>>16)
+	}
+	return leaves
+}
+
+// withParallelMerkleizeThreshold sets the threshold from which tries are hashed
+// in parallel until the returned func is called.
+func withParallelMerkleizeThreshold(leaves int) func() {
+	previous := parallelMerkleizeThreshold
+	SetParallelMerkleizeThreshold(leaves)
+	return func() {
+		SetParallelMerkleizeThreshold(previous)
+	}
+}
+
+func TestMerkleize_ParallelMatchesSequential(t *testing.T) {
+	for _, procs := range []int{2, 3, 4, 8} {
+		for _, n := range []int{2, 3, 8, 9, 100, 1024, 1500} {
+			leaves := merkleizeLeaves(n)
+			restore := withParallelMerkleizeThreshold(0)
+			want := newLayerCache().merkleize(sha256Hasher, "", leaves)
+			restore()
+
+			previousProcs := runtime.GOMAXPROCS(procs)
+			restore = withParallelMerkleizeThreshold(2)
+			got := newLayerCache().merkleize(sha256Hasher, "", leaves)
+			restore()
+			runtime.GOMAXPROCS(previousProcs)
+			if got != want {
+				t.Errorf("Expected root %#x over %d leaves with %d processors, received %#x", want, n, procs, got)
+			}
+		}
+	}
+}
+
+func TestMerkleize_ParallelBelowThreshold(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	defer withParallelMerkleizeThreshold(64)()
+	if subtrees := merkleizeSubtrees(63, 64); subtrees != 1 {
+		t.Errorf("Expected 63 leaves to be hashed sequentially, received %d subtrees", subtrees)
+	}
+	if subtrees := merkleizeSubtrees(64, 64); subtrees != 4 {
+		t.Errorf("Expected 64 leaves to be hashed as 4 subtrees, received %d", subtrees)
+	}
+	// The root of the subtrees alone is not hashed in parallel.
+	SetParallelMerkleizeThreshold(2)
+	if subtrees := merkleizeSubtrees(3, 4); subtrees != 2 {
+		t.Errorf("Expected 3 leaves to be hashed as 2 subtrees, received %d", subtrees)
+	}
+}
+
+func TestMerkleize_PaddingSubtreesNotHashed(t *testing.T) {
+	for n := 2; n <= 40; n++ {
+		leaves := merkleizeLeaves(n)
+		// The trie is padded with zero chunks and hashed node by node.
+		layer := append([][]byte{}, leaves...)
+		for !isPowerOf2(len(layer)) {
+			layer = append(layer, make([]byte, BytesPerChunk))
+		}
+		for len(layer) > 1 {
+			next := make([][]byte, len(layer)/2)
+			for i := range next {
+				h := hash(append(append([]byte{}, layer[2*i]...), layer[2*i+1]...))
+				next[i] = h[:]
+			}
+			layer = next
+		}
+		var got [32]byte
+		hashes := CountHashes(func() {
+			got = newLayerCache().merkleize(sha256Hasher, "", leaves)
+		})
+		if !bytes.Equal(got[:], layer[0]) {
+			t.Errorf("Expected root %#x over %d leaves, received %#x", layer[0], n, got)
+		}
+		// Only the nodes with a leaf below them are hashed.
+		want := 0
+		for width := n; width > 1; {
+			width = (width + 1) / 2
+			want += width
+		}
+		if hashes != uint64(want) {
+			t.Errorf("Expected %d hashes over %d leaves, received %d", want, n, hashes)
+		}
+	}
+}
+
+func TestLayerCache_UpdatesParallelTrie(t *testing.T) {
+	defer withFreshLayerCaches()()
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	defer withParallelMerkleizeThreshold(8)()
+	c := newLayerCache()
+	leaves := merkleizeLeaves(100)
+	if _, err := c.root(sha256Hasher, "State.Field", leaves); err != nil {
+		t.Fatal(err)
+	}
+	// The layers cached by the parallel path are updated as the sequential ones
+	// would be.
+	for _, i := range []int{0, 31, 32, 99} {
+		leaves = append([][]byte{}, leaves...)
+		leaves[i] = make([]byte, BytesPerChunk)
+		leaves[i][31] = byte(i)
+		got, err := c.root(sha256Hasher, "State.Field", leaves)
+		if err != nil {
+			t.Fatal(err)
+		}
+		SetParallelMerkleizeThreshold(0)
+		want := newLayerCache().merkleize(sha256Hasher, "", leaves)
+		SetParallelMerkleizeThreshold(8)
+		if got != want {
+			t.Errorf("Expected root %#x after changing leaf %d, received %#x", want, i, got)
+		}
+	}
+}
+
+func BenchmarkMerkleize_Parallel(b *testing.B) {
+	for _, n := range []int{1 << 16, 1 << 20} {
+		leaves := merkleizeLeaves(n)
+		for _, procs := range []int{1, 2, 4, 8} {
+			b.Run(fmt.Sprintf("leaves=%d/procs=%d", n, procs), func(b *testing.B) {
+				defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+				c := newLayerCache()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					c.merkleize(sha256Hasher, "", leaves)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkLayerCache_MutateOnePercent(b *testing.B) {
+	defer withFreshLayerCaches()()
+	state := layerStateFixture()
+	layerStateRoot(b, state)
+	b.ResetTimer()
+	hashes := CountHashes(func() {
+		for n := 0; n < b.N; n++ {
+			// Mutate 1% of the attestations between hashes.
+			for i := 0; i < len(state.Attestations)/100; i++ {
+				state.Attestations[(n*7+i*31)%len(state.Attestations)].Slot++
+			}
+			layerStateRoot(b, state)
+		}
+	})
+	b.ReportMetric(float64(hashes)/float64(b.N), "hashes/op")
+}
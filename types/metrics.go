@@ -0,0 +1,45 @@
+package types
+
+import (
+	"sync/atomic"
+)
+
+var (
+	metricsEnabled uint32
+	hashCount      uint64
+)
+
+// ToggleMetrics enables instrumentation counters, such as the number of hashes
+// performed while computing hash tree roots. It is disabled by default.
+func ToggleMetrics(val bool) {
+	if val {
+		atomic.StoreUint32(&metricsEnabled, 1)
+		return
+	}
+	atomic.StoreUint32(&metricsEnabled, 0)
+}
+
+// HashCount returns the number of 64-byte compressions performed since the counter
+// was last reset. The counter only advances while metrics are enabled.
+func HashCount() uint64 {
+	return atomic.LoadUint64(&hashCount)
+}
+
+// CountHashes resets the hash counter, runs fn with metrics enabled, and returns
+// the number of hashes performed while fn ran. It is meant for tests asserting the
+// hashing cost of a root computation, and is not safe to call concurrently with itself.
+func CountHashes(fn func()) uint64 {
+	wasEnabled := atomic.LoadUint32(&metricsEnabled) == 1
+	ToggleMetrics(true)
+	atomic.StoreUint64(&hashCount, 0)
+	fn()
+	count := atomic.LoadUint64(&hashCount)
+	ToggleMetrics(wasEnabled)
+	return count
+}
+
+func recordHash() {
+	if atomic.LoadUint32(&metricsEnabled) == 1 {
+		atomic.AddUint64(&hashCount, 1)
+	}
+}
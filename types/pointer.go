@@ -0,0 +1,47 @@
+package types
+
+import (
+	"reflect"
+)
+
+// pointerSSZ serializes pointers to lists, vectors and strings as the values
+// they point to, for the factories of those types, which only handle values.
+// Nil pointers stand for zero values, as they do for structs and basic types.
+type pointerSSZ struct {
+	elem SSZAble
+}
+
+// pointedSSZ returns the factory of pointers to the values factory handles,
+// which is factory itself when it dereferences pointers already.
+func pointedSSZ(factory SSZAble) SSZAble {
+	switch factory {
+	case basicSliceFactory, compositeSliceFactory, basicArrayFactory, compositeArrayFactory, rootsArrayFactory, stringFactory:
+		return &pointerSSZ{elem: factory}
+	default:
+		return factory
+	}
+}
+
+// pointee returns the value val points to, or the zero value of its type when
+// val is nil.
+func pointee(val reflect.Value, typ reflect.Type) reflect.Value {
+	if val.IsNil() {
+		return reflect.New(typ.Elem()).Elem()
+	}
+	return val.Elem()
+}
+
+func (p *pointerSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
+	return p.elem.Marshal(pointee(val, typ), typ.Elem(), buf, startOffset)
+}
+
+func (p *pointerSSZ) Unmarshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64, ctx *DecodeContext) (uint64, error) {
+	if val.IsNil() {
+		val.Set(reflect.New(typ.Elem()))
+	}
+	return p.elem.Unmarshal(val.Elem(), typ.Elem(), buf, startOffset, ctx)
+}
+
+func (p *pointerSSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *HashContext) ([32]byte, error) {
+	return p.elem.Root(pointee(val, typ), typ.Elem(), fieldName, maxCapacity, ctx)
+}
@@ -0,0 +1,151 @@
+package types
+
+import (
+	"reflect"
+	"sync"
+)
+
+// resetter clears a value of a given type in place.
+type resetter func(val reflect.Value)
+
+// resetters caches the resetter of every type Reset has cleared.
+var resetters sync.Map
+
+// Reset clears val so that it can be reused as a decoding target. Slices are
+// truncated to a length of zero while keeping their backing arrays, whose
+// elements are cleared up to their capacity, arrays and basic values are
+// zeroed, and values behind pointers are cleared without being freed. Values
+// which hold no slices or pointers are zeroed at once.
+func Reset(val reflect.Value) {
+	resetterFor(val.Type(), make(map[reflect.Type]*resetter))(val)
+}
+
+func resetterFor(typ reflect.Type, building map[reflect.Type]*resetter) resetter {
+	if r, ok := resetters.Load(typ); ok {
+		return r.(resetter)
+	}
+	// Recursive types refer to their own resetter while it is being built.
+	if r, ok := building[typ]; ok {
+		return func(val reflect.Value) {
+			(*r)(val)
+		}
+	}
+	r := new(resetter)
+	building[typ] = r
+	*r = newResetter(typ, building)
+	delete(building, typ)
+	resetters.Store(typ, *r)
+	return *r
+}
+
+func newResetter(typ reflect.Type, building map[reflect.Type]*resetter) resetter {
+	if !holdsReferences(typ) {
+		zero := reflect.Zero(typ)
+		return func(val reflect.Value) {
+			val.Set(zero)
+		}
+	}
+	switch typ.Kind() {
+	case reflect.Ptr:
+		elem := resetterFor(typ.Elem(), building)
+		return func(val reflect.Value) {
+			if !val.IsNil() {
+				elem(val.Elem())
+			}
+		}
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return func(val reflect.Value) {
+				bytes := val.Slice(0, val.Cap()).Bytes()
+				for i := range bytes {
+					bytes[i] = 0
+				}
+				val.SetLen(0)
+			}
+		}
+		elem := resetterFor(typ.Elem(), building)
+		return func(val reflect.Value) {
+			full := val.Slice(0, val.Cap())
+			for i := 0; i < full.Len(); i++ {
+				elem(full.Index(i))
+			}
+			val.SetLen(0)
+		}
+	case reflect.Array:
+		elem := resetterFor(typ.Elem(), building)
+		return func(val reflect.Value) {
+			for i := 0; i < val.Len(); i++ {
+				elem(val.Index(i))
+			}
+		}
+	case reflect.Struct:
+		if isSequenceType(typ) {
+			return sequenceResetter(typ, building)
+		}
+		type fieldResetter struct {
+			index int
+			reset resetter
+		}
+		var fields []fieldResetter
+		for i := 0; i < typ.NumField(); i++ {
+			// Unexported fields cannot be set, and are never serialized.
+			if typ.Field(i).PkgPath != "" {
+				continue
+			}
+			fields = append(fields, fieldResetter{index: i, reset: resetterFor(typ.Field(i).Type, building)})
+		}
+		return func(val reflect.Value) {
+			for _, field := range fields {
+				field.reset(val.Field(field.index))
+			}
+		}
+	default:
+		zero := reflect.Zero(typ)
+		return func(val reflect.Value) {
+			val.Set(zero)
+		}
+	}
+}
+
+// sequenceResetter clears the elements of a list or a vector such as ssz.List
+// and ssz.Vector, truncating lists but keeping the length of vectors, along
+// with their limit.
+func sequenceResetter(typ reflect.Type, building map[reflect.Type]*resetter) resetter {
+	elemTyp, isVector := sequenceElemType(typ)
+	elems := resetterFor(reflect.SliceOf(elemTyp), building)
+	elem := resetterFor(elemTyp, building)
+	return func(val reflect.Value) {
+		seq := val.Addr().Interface().(Sequence)
+		slice := reflect.ValueOf(seq.SSZElements()).Elem()
+		if !isVector {
+			elems(slice)
+			return
+		}
+		for i := 0; i < slice.Len(); i++ {
+			elem(slice.Index(i))
+		}
+	}
+}
+
+// holdsReferences reports whether values of type typ hold slices, pointers or
+// other references, which are cleared element by element rather than zeroed.
+func holdsReferences(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface, reflect.Chan, reflect.Func:
+		return true
+	case reflect.Array:
+		return holdsReferences(typ.Elem())
+	case reflect.Struct:
+		if isSequenceType(typ) {
+			return true
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			if holdsReferences(typ.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
@@ -0,0 +1,88 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// RootTracker keeps the trie of a vector of roots, such as the block roots of a
+// beacon state or the roots of its validators, so that the root of the vector
+// is updated by rehashing the branch of a changed root alone, in time
+// logarithmic in the length of the vector. A RootTracker is not safe for
+// concurrent use.
+type RootTracker struct {
+	numLeaves int
+	layers    [][][]byte
+}
+
+// NewRootTracker returns a tracker of the root of the vector of the given roots,
+// which must hold at least one root.
+func NewRootTracker(roots [][32]byte) (*RootTracker, error) {
+	t := &RootTracker{}
+	if err := t.rebuild(roots); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// rebuild merkleizes roots from scratch.
+func (t *RootTracker) rebuild(roots [][32]byte) error {
+	if len(roots) == 0 {
+		return fmt.Errorf("cannot track the root of a vector of no roots")
+	}
+	// The leaves are copied into a buffer of their own, so that the caller can
+	// reuse roots, and are replaced rather than written to on updates.
+	flat := make([]byte, len(roots)*BytesPerChunk)
+	leaves := make([][]byte, len(roots))
+	for i := range roots {
+		leaves[i] = flat[i*BytesPerChunk : (i+1)*BytesPerChunk]
+		copy(leaves[i], roots[i][:])
+	}
+	t.numLeaves = len(roots)
+	t.layers = buildLayers(sha256Hasher, leaves)
+	return nil
+}
+
+// Len returns the number of roots of the tracked vector.
+func (t *RootTracker) Len() int {
+	return t.numLeaves
+}
+
+// Root returns the root of the tracked vector.
+func (t *RootTracker) Root() [32]byte {
+	var root [32]byte
+	copy(root[:], t.layers[len(t.layers)-1][0])
+	return root
+}
+
+// Update replaces the root at index of the vector with root and returns the new
+// root of the vector.
+func (t *RootTracker) Update(index uint64, root [32]byte) ([32]byte, error) {
+	if index >= uint64(t.numLeaves) {
+		return [32]byte{}, fmt.Errorf("index %d is out of range of a vector of %d roots", index, t.numLeaves)
+	}
+	leaf := make([]byte, BytesPerChunk)
+	copy(leaf, root[:])
+	updateBranch(sha256Hasher, t.layers, int(index), leaf)
+	return t.Root(), nil
+}
+
+// Sync replaces the roots of the vector with roots and returns its new root.
+// Only the branches of the roots which changed are rehashed, unless the number
+// of roots changed, in which case the trie is merkleized from scratch.
+func (t *RootTracker) Sync(roots [][32]byte) ([32]byte, error) {
+	if len(roots) != t.numLeaves {
+		if err := t.rebuild(roots); err != nil {
+			return [32]byte{}, err
+		}
+		return t.Root(), nil
+	}
+	for i := range roots {
+		if !bytes.Equal(t.layers[0][i], roots[i][:]) {
+			if _, err := t.Update(uint64(i), roots[i]); err != nil {
+				return [32]byte{}, err
+			}
+		}
+	}
+	return t.Root(), nil
+}
@@ -0,0 +1,56 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestRootTracker_UpdateRehashesBranch(t *testing.T) {
+	roots := make([][32]byte, 1<<12+1)
+	tracker, err := NewRootTracker(roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A trie over 2^12+1 leaves is 13 layers deep above its leaves.
+	hashes := CountHashes(func() {
+		if _, err := tracker.Update(1<<12, [32]byte{1}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if hashes != 13 {
+		t.Errorf("Expected 13 hashes to update a leaf, received %d", hashes)
+	}
+}
+
+func TestRootTracker_CopiesRoots(t *testing.T) {
+	roots := make([][32]byte, 5)
+	tracker, err := NewRootTracker(roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := tracker.Root()
+	// The roots of the caller are copied rather than referenced.
+	roots[2] = [32]byte{2}
+	if tracker.Root() != root {
+		t.Error("Expected changing the roots the tracker was created with to leave it unchanged")
+	}
+	synced, err := tracker.Sync(roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if synced == root {
+		t.Error("Expected syncing the changed roots to change the root")
+	}
+}
+
+func TestRootTracker_Errors(t *testing.T) {
+	if _, err := NewRootTracker(nil); err == nil {
+		t.Error("Expected tracking no roots to fail")
+	}
+	tracker, err := NewRootTracker(make([][32]byte, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tracker.Update(3, [32]byte{}); err == nil {
+		t.Error("Expected updating a root out of range to fail")
+	}
+}
@@ -0,0 +1,152 @@
+package types
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// scaledSSZ marshals uint64 fields tagged with ssz-scale, such as
+// `ssz-scale:"1e9"`, whose values are held in a unit scale times larger than
+// the one they are serialized in, such as ether for fields serialized in gwei.
+// Values are multiplied by the scale when marshaled and hashed, and divided by
+// it when unmarshaled. Values which would overflow once multiplied, and
+// serialized values which are not a multiple of the scale, are rejected rather
+// than truncated.
+type scaledSSZ struct {
+	field reflect.StructField
+	scale uint64
+}
+
+// scales caches the scales parsed from ssz-scale tags.
+var scales sync.Map
+
+// fieldFactory returns the implementation marshaling, unmarshaling and hashing
+// the field of a container holding val as a value of type fType.
+func fieldFactory(field reflect.StructField, val reflect.Value, fType reflect.Type) (SSZAble, error) {
+	scale, ok, err := parseScaleTag(field)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return &scaledSSZ{field: field, scale: scale}, nil
+	}
+	return SSZFactory(val, fType)
+}
+
+// isScaledField reports whether a field is tagged with ssz-scale.
+func isScaledField(field reflect.StructField) bool {
+	_, ok := field.Tag.Lookup("ssz-scale")
+	return ok
+}
+
+// checkScaleTag verifies an ssz-scale tag is valid and placed on a uint64 field.
+func checkScaleTag(field reflect.StructField) error {
+	if !isScaledField(field) {
+		return nil
+	}
+	if field.Type.Kind() != reflect.Uint64 {
+		return fmt.Errorf("field %s of type %v cannot have an ssz-scale tag, only uint64 fields can be scaled", field.Name, field.Type)
+	}
+	_, _, err := parseScaleTag(field)
+	return err
+}
+
+// parseScaleTag returns the scale declared by the ssz-scale tag of a field,
+// written as an integer such as "1000" or in exponent notation such as "1e9",
+// along with whether the field has one.
+func parseScaleTag(field reflect.StructField) (uint64, bool, error) {
+	tag, ok := field.Tag.Lookup("ssz-scale")
+	if !ok {
+		return 0, false, nil
+	}
+	if scale, ok := scales.Load(tag); ok {
+		return scale.(uint64), true, nil
+	}
+	scale, err := parseScale(tag)
+	if err != nil {
+		return 0, false, fmt.Errorf("field %s has invalid ssz-scale %q: %v", field.Name, tag, err)
+	}
+	scales.Store(tag, scale)
+	return scale, true, nil
+}
+
+func parseScale(tag string) (uint64, error) {
+	mantissa, exponent := tag, "0"
+	if i := strings.IndexAny(tag, "eE"); i >= 0 {
+		mantissa, exponent = tag[:i], tag[i+1:]
+	}
+	scale, err := strconv.ParseUint(mantissa, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	exp, err := strconv.ParseUint(exponent, 10, 8)
+	if err != nil {
+		return 0, err
+	}
+	for i := uint64(0); i < exp; i++ {
+		hi, lo := bits.Mul64(scale, 10)
+		if hi != 0 {
+			return 0, fmt.Errorf("scale overflows uint64")
+		}
+		scale = lo
+	}
+	if scale == 0 {
+		return 0, fmt.Errorf("scale must be positive")
+	}
+	return scale, nil
+}
+
+// wireValue returns the value val is serialized as.
+func (s *scaledSSZ) wireValue(val reflect.Value) (uint64, error) {
+	hi, wire := bits.Mul64(val.Uint(), s.scale)
+	if hi != 0 {
+		return 0, fmt.Errorf(
+			"field %s holds %d, which overflows uint64 once scaled by %d",
+			s.field.Name,
+			val.Uint(),
+			s.scale,
+		)
+	}
+	return wire, nil
+}
+
+func (s *scaledSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
+	wire, err := s.wireValue(val)
+	if err != nil {
+		return 0, err
+	}
+	binary.LittleEndian.PutUint64(buf[startOffset:startOffset+8], wire)
+	return startOffset + 8, nil
+}
+
+func (s *scaledSSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64, ctx *DecodeContext) (uint64, error) {
+	if startOffset+8 > uint64(len(input)) {
+		return 0, fmt.Errorf("input for field %s is truncated: expected 8 bytes: %w", s.field.Name, ErrInputTooShort)
+	}
+	wire := binary.LittleEndian.Uint64(input[startOffset : startOffset+8])
+	if wire%s.scale != 0 {
+		return 0, fmt.Errorf(
+			"field %s is serialized as %d, which is not a multiple of its scale of %d",
+			s.field.Name,
+			wire,
+			s.scale,
+		)
+	}
+	val.SetUint(wire / s.scale)
+	return startOffset + 8, nil
+}
+
+func (s *scaledSSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *HashContext) ([32]byte, error) {
+	wire, err := s.wireValue(val)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	var root [32]byte
+	binary.LittleEndian.PutUint64(root[:8], wire)
+	return root, nil
+}
@@ -0,0 +1,38 @@
+package types
+
+import "testing"
+
+func TestParseScale(t *testing.T) {
+	tests := []struct {
+		tag   string
+		scale uint64
+		err   bool
+	}{
+		{tag: "1", scale: 1},
+		{tag: "1000", scale: 1000},
+		{tag: "1e9", scale: 1000000000},
+		{tag: "25E2", scale: 2500},
+		{tag: "1e19", scale: 10000000000000000000},
+		{tag: "1e20", err: true},
+		{tag: "0", err: true},
+		{tag: "0e5", err: true},
+		{tag: "", err: true},
+		{tag: "1.5", err: true},
+		{tag: "-1", err: true},
+		{tag: "1e-3", err: true},
+	}
+	for _, tt := range tests {
+		scale, err := parseScale(tt.tag)
+		if tt.err {
+			if err == nil {
+				t.Errorf("Expected scale %q to be rejected, received %d", tt.tag, scale)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Could not parse scale %q: %v", tt.tag, err)
+		} else if scale != tt.scale {
+			t.Errorf("Expected scale %q to be %d, received %d", tt.tag, tt.scale, scale)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SchemaKind is the kind of SSZ type described by a Schema.
+type SchemaKind uint8
+
+// The kinds of SSZ types go-ssz supports.
+const (
+	SchemaBool SchemaKind = iota + 1
+	SchemaUint8
+	SchemaUint16
+	SchemaInt32
+	SchemaUint32
+	SchemaUint64
+	SchemaString
+	SchemaVector
+	SchemaList
+	SchemaContainer
+	SchemaBitlist
+	SchemaBitvector
+	SchemaUnion
+	SchemaUint128
+	SchemaUint256
+)
+
+var schemaKindNames = map[SchemaKind]string{
+	SchemaBool:      "bool",
+	SchemaUint8:     "uint8",
+	SchemaUint16:    "uint16",
+	SchemaInt32:     "int32",
+	SchemaUint32:    "uint32",
+	SchemaUint64:    "uint64",
+	SchemaString:    "string",
+	SchemaVector:    "vector",
+	SchemaList:      "list",
+	SchemaContainer: "container",
+	SchemaBitlist:   "bitlist",
+	SchemaBitvector: "bitvector",
+	SchemaUnion:     "union",
+	SchemaUint128:   "uint128",
+	SchemaUint256:   "uint256",
+}
+
+func (k SchemaKind) String() string {
+	if name, ok := schemaKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("SchemaKind(%d)", k)
+}
+
+// Schema describes the layout of an SSZ type independently of the Go type
+// implementing it.
+type Schema struct {
+	Kind SchemaKind
+	// Name is the name of a container or a union, which is informational only.
+	Name string
+	// Elem describes the elements of vectors and lists.
+	Elem *Schema
+	// Length is the number of elements of vectors, or of bits of bitvectors.
+	Length uint64
+	// Limit is the maximum number of elements of lists, of bytes of strings, or
+	// of bits of bitlists, where zero means no limit is declared.
+	Limit uint64
+	// Fields describes the fields of containers, in order.
+	Fields []SchemaField
+	// Options describes the options of unions by selector, where nil stands
+	// for None.
+	Options []*Schema
+	// Uints marks vectors and lists of uint8 whose elements are small numbers
+	// rather than opaque bytes, as declared by an ssz:"uints" field tag. It does
+	// not change how they are serialized or hashed.
+	Uints bool
+}
+
+// SchemaField is a named field of a container.
+type SchemaField struct {
+	Name   string
+	Schema *Schema
+}
+
+var basicSchemaKinds = map[reflect.Kind]SchemaKind{
+	reflect.Bool:   SchemaBool,
+	reflect.Uint8:  SchemaUint8,
+	reflect.Uint16: SchemaUint16,
+	reflect.Int32:  SchemaInt32,
+	reflect.Uint32: SchemaUint32,
+	reflect.Uint64: SchemaUint64,
+}
+
+// Describe returns the schema of the SSZ type implemented by typ, taking the
+// ssz-size, ssz-max, ssz-name and ssz tags of struct fields into account.
+func Describe(typ reflect.Type) (*Schema, error) {
+	return describe(typ, 0, make(map[reflect.Type]bool))
+}
+
+func describe(typ reflect.Type, limit uint64, visiting map[reflect.Type]bool) (*Schema, error) {
+	typ = underlyingType(typ)
+	if u, ok := lookupUnion(typ); ok {
+		if visiting[typ] {
+			return nil, fmt.Errorf("type %v is recursive and has no finite schema", typ)
+		}
+		visiting[typ] = true
+		defer delete(visiting, typ)
+		schema := &Schema{Kind: SchemaUnion, Name: typ.Name()}
+		for _, option := range u.options {
+			var optionSchema *Schema
+			if option != nil {
+				var err error
+				if optionSchema, err = describe(option, 0, visiting); err != nil {
+					return nil, err
+				}
+			}
+			schema.Options = append(schema.Options, optionSchema)
+		}
+		return schema, nil
+	}
+	if isBitlistType(typ) {
+		return &Schema{Kind: SchemaBitlist, Limit: limit}, nil
+	}
+	if isBitvectorType(typ) {
+		return &Schema{Kind: SchemaBitvector, Length: uint64(typ.Len())}, nil
+	}
+	if typ == bitvectorType {
+		return nil, fmt.Errorf("bitvectors are only supported as struct fields, whose ssz-size tag declares their length in bits: %w", ErrUnsupportedType)
+	}
+	switch typ {
+	case uint128Type:
+		return &Schema{Kind: SchemaUint128}, nil
+	case uint256Type:
+		return &Schema{Kind: SchemaUint256}, nil
+	}
+	if kind, ok := basicSchemaKinds[typ.Kind()]; ok {
+		return &Schema{Kind: kind}, nil
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return &Schema{Kind: SchemaString, Limit: limit}, nil
+	case reflect.Array:
+		elem, err := describe(typ.Elem(), 0, visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Kind: SchemaVector, Elem: elem, Length: uint64(typ.Len())}, nil
+	case reflect.Slice:
+		elem, err := describe(typ.Elem(), 0, visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Kind: SchemaList, Elem: elem, Limit: limit}, nil
+	case reflect.Struct:
+		if isSequenceType(typ) {
+			elemTyp, isVector := sequenceElemType(typ)
+			if isVector {
+				return nil, fmt.Errorf("the length of vector type %v is only known from its values", typ)
+			}
+			elem, err := describe(elemTyp, 0, visiting)
+			if err != nil {
+				return nil, err
+			}
+			return &Schema{Kind: SchemaList, Elem: elem, Limit: limit}, nil
+		}
+		if visiting[typ] {
+			return nil, fmt.Errorf("type %v is recursive and has no finite schema", typ)
+		}
+		visiting[typ] = true
+		defer delete(visiting, typ)
+		schema := &Schema{Kind: SchemaContainer, Name: typ.Name()}
+		for _, field := range sszFields(typ) {
+			fType, err := determineFieldType(field)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %v", field.Name, err)
+			}
+			fieldSchema, err := describe(fType, determineFieldCapacity(field), visiting)
+			if err != nil {
+				return nil, err
+			}
+			uints, err := fieldHoldsUints(field)
+			if err != nil {
+				return nil, err
+			}
+			if uints {
+				if err := markUints(fieldSchema); err != nil {
+					return nil, fmt.Errorf("field %s: %v", field.Name, err)
+				}
+			}
+			schema.Fields = append(schema.Fields, SchemaField{Name: sszFieldName(field), Schema: fieldSchema})
+		}
+		return schema, nil
+	default:
+		return nil, fmt.Errorf("type %v is not serializable: %w", typ, ErrUnsupportedType)
+	}
+}
+
+// markUints marks the innermost vector or list of uint8 of a field schema as
+// holding uints.
+func markUints(schema *Schema) error {
+	for schema.Kind == SchemaVector || schema.Kind == SchemaList {
+		if schema.Elem.Kind == SchemaUint8 {
+			schema.Uints = true
+			return nil
+		}
+		schema = schema.Elem
+	}
+	return fmt.Errorf("only vectors and lists of uint8 can hold uints, not %v", schema.Kind)
+}
+
+// String returns the canonical form of the schema, such as
+// "container{Slot: uint64, Roots: list[vector[uint8, 32], 8]}". It leaves out
+// the names of containers and whether vectors and lists hold uints, as neither
+// changes how values are serialized, so types of the same layout have the same
+// canonical form.
+func (s *Schema) String() string {
+	var b strings.Builder
+	s.writeCanonical(&b)
+	return b.String()
+}
+
+func (s *Schema) writeCanonical(b *strings.Builder) {
+	switch s.Kind {
+	case SchemaString, SchemaBitlist:
+		b.WriteString(s.Kind.String())
+		if s.Limit > 0 {
+			fmt.Fprintf(b, "[%d]", s.Limit)
+		}
+	case SchemaBitvector:
+		fmt.Fprintf(b, "%v[%d]", s.Kind, s.Length)
+	case SchemaVector:
+		b.WriteString("vector[")
+		s.Elem.writeCanonical(b)
+		fmt.Fprintf(b, ", %d]", s.Length)
+	case SchemaList:
+		b.WriteString("list[")
+		s.Elem.writeCanonical(b)
+		if s.Limit > 0 {
+			fmt.Fprintf(b, ", %d", s.Limit)
+		}
+		b.WriteString("]")
+	case SchemaUnion:
+		b.WriteString("union[")
+		for i, option := range s.Options {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			if option == nil {
+				b.WriteString("None")
+				continue
+			}
+			option.writeCanonical(b)
+		}
+		b.WriteString("]")
+	case SchemaContainer:
+		b.WriteString("container{")
+		for i, field := range s.Fields {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(field.Name)
+			b.WriteString(": ")
+			field.Schema.writeCanonical(b)
+		}
+		b.WriteString("}")
+	default:
+		b.WriteString(s.Kind.String())
+	}
+}
@@ -0,0 +1,144 @@
+package types
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type describedState struct {
+	Roots    [][]byte `ssz-size:"?,32" ssz-max:"8"`
+	Balances []uint64
+	Vector   [2]uint16
+	Renamed  int32 `ssz-name:"renamed"`
+	XXX_skip uint64
+}
+
+func TestDescribe(t *testing.T) {
+	got, err := Describe(reflect.TypeOf(&describedState{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Schema{
+		Kind: SchemaContainer,
+		Name: "describedState",
+		Fields: []SchemaField{
+			{Name: "Roots", Schema: &Schema{Kind: SchemaList, Elem: bytes32Schema(), Limit: 8}},
+			{Name: "Balances", Schema: &Schema{Kind: SchemaList, Elem: &Schema{Kind: SchemaUint64}}},
+			{Name: "Vector", Schema: &Schema{Kind: SchemaVector, Elem: &Schema{Kind: SchemaUint16}, Length: 2}},
+			{Name: "renamed", Schema: &Schema{Kind: SchemaInt32}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected schema %+v, received %+v", want, got)
+	}
+}
+
+func TestDescribe_Errors(t *testing.T) {
+	type recursive struct {
+		Children []recursive
+	}
+	type platformWidth struct {
+		Value int
+	}
+	type unsupported struct {
+		Value complex128
+	}
+	for _, tt := range []struct {
+		typ reflect.Type
+		err string
+	}{
+		{typ: reflect.TypeOf(recursive{}), err: "is recursive"},
+		{typ: reflect.TypeOf(platformWidth{}), err: "type int has a platform-dependent width"},
+		{typ: reflect.TypeOf(unsupported{}), err: "type complex128 is not serializable"},
+	} {
+		if _, err := Describe(tt.typ); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Expected error containing %q for %v, received %v", tt.err, tt.typ, err)
+		}
+	}
+}
+
+func TestDescribe_Uints(t *testing.T) {
+	type levels struct {
+		Graffiti [32]byte `ssz:"bytes"`
+		Levels   []uint8  `ssz:"uints" ssz-max:"16"`
+		Grid     [][]byte `ssz:"uints" ssz-size:"2,3"`
+		Data     []byte   `ssz-max:"8"`
+	}
+	got, err := Describe(reflect.TypeOf(levels{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uints := func(schema *Schema) *Schema {
+		schema.Uints = true
+		return schema
+	}
+	uint8Schema := &Schema{Kind: SchemaUint8}
+	want := &Schema{
+		Kind: SchemaContainer,
+		Name: "levels",
+		Fields: []SchemaField{
+			{Name: "Graffiti", Schema: bytes32Schema()},
+			{Name: "Levels", Schema: uints(&Schema{Kind: SchemaList, Elem: uint8Schema, Limit: 16})},
+			{Name: "Grid", Schema: &Schema{Kind: SchemaVector, Elem: uints(&Schema{Kind: SchemaVector, Elem: uint8Schema, Length: 3}), Length: 2}},
+			{Name: "Data", Schema: &Schema{Kind: SchemaList, Elem: uint8Schema, Limit: 8}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected schema %+v, received %+v", want, got)
+	}
+
+	src, err := GenerateGoType(got, "levels", "Levels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fields are compared regardless of the alignment applied by gofmt.
+	normalized := strings.Join(strings.Fields(string(src)), " ")
+	for _, decl := range []string{"Levels []uint8 `ssz-max:\"16\" ssz:\"uints\"`", "Grid [2][3]uint8 `ssz:\"uints\"`", "Data []byte `ssz-max:\"8\"`"} {
+		if !strings.Contains(normalized, decl) {
+			t.Errorf("Expected generated source to declare %s, received:\n%s", decl, src)
+		}
+	}
+}
+
+func TestDescribe_UintsErrors(t *testing.T) {
+	type both struct {
+		Levels []uint8 `ssz:"bytes,uints"`
+	}
+	type notBytes struct {
+		Levels []uint16 `ssz:"uints"`
+	}
+	for _, tt := range []struct {
+		typ reflect.Type
+		err string
+	}{
+		{typ: reflect.TypeOf(both{}), err: "cannot hold both bytes and uints"},
+		{typ: reflect.TypeOf(notBytes{}), err: "only vectors and lists of uint8 can hold uints"},
+	} {
+		if _, err := Describe(tt.typ); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Expected error containing %q for %v, received %v", tt.err, tt.typ, err)
+		}
+	}
+}
+
+func TestSchema_String(t *testing.T) {
+	type named struct {
+		Name     string   `ssz-max:"64"`
+		Levels   []uint8  `ssz:"uints" ssz-max:"16"`
+		Children []uint64
+	}
+	type outer struct {
+		Inner named
+		Roots [][]byte `ssz-size:"?,32" ssz-max:"8"`
+		Ok    bool
+	}
+	schema, err := Describe(reflect.TypeOf(outer{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "container{Inner: container{Name: string[64], Levels: list[uint8, 16], Children: list[uint64]}, " +
+		"Roots: list[vector[uint8, 32], 8], Ok: bool}"
+	if got := schema.String(); got != want {
+		t.Errorf("Expected %q, received %q", want, got)
+	}
+}
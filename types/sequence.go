@@ -0,0 +1,137 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Sequence is implemented by pointers to values which hold a list or a vector
+// along with its limit or length, such as ssz.List and ssz.Vector. Such values
+// are serialized and hashed as the list or vector they hold, so they need no
+// ssz-max or ssz-size tags, and the limit of a list overrides any tag of the
+// field holding it.
+type Sequence interface {
+	// SSZElements returns a pointer to the slice holding the elements.
+	SSZElements() interface{}
+	// SSZLimit returns the maximum number of elements of a list, where zero
+	// means no limit is set, or the number of elements of a vector, along with
+	// whether the sequence is a vector.
+	SSZLimit() (limit uint64, isVector bool)
+}
+
+var sequenceInterface = reflect.TypeOf((*Sequence)(nil)).Elem()
+
+type sequenceSSZ struct{}
+
+func newSequenceSSZ() *sequenceSSZ {
+	return &sequenceSSZ{}
+}
+
+func isSequenceType(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Struct && reflect.PtrTo(typ).Implements(sequenceInterface)
+}
+
+// sequenceElemType returns the type of the elements of a sequence type, and
+// whether it is a vector.
+func sequenceElemType(typ reflect.Type) (reflect.Type, bool) {
+	seq := reflect.New(typ).Interface().(Sequence)
+	_, isVector := seq.SSZLimit()
+	return reflect.TypeOf(seq.SSZElements()).Elem().Elem(), isVector
+}
+
+// sequenceElems returns the slice of elements held by the sequence val, along
+// with the type it is serialized as, which is an array type for vectors, and
+// the limit of lists.
+func sequenceElems(val reflect.Value) (reflect.Value, reflect.Type, uint64) {
+	if !val.CanAddr() {
+		ptr := reflect.New(val.Type())
+		ptr.Elem().Set(val)
+		val = ptr.Elem()
+	}
+	seq := val.Addr().Interface().(Sequence)
+	elems := reflect.ValueOf(seq.SSZElements()).Elem()
+	limit, isVector := seq.SSZLimit()
+	if isVector {
+		return elems, reflect.ArrayOf(int(limit), elems.Type().Elem()), 0
+	}
+	return elems, elems.Type(), limit
+}
+
+func (s *sequenceSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return s.Marshal(reflect.New(typ.Elem()).Elem(), typ.Elem(), buf, startOffset)
+		}
+		return s.Marshal(val.Elem(), typ.Elem(), buf, startOffset)
+	}
+	elems, elemsTyp, limit := sequenceElems(val)
+	if elemsTyp.Kind() == reflect.Array && elems.Len() != elemsTyp.Len() {
+		return 0, fmt.Errorf("vector %v holds %d elements, expected %d", typ, elems.Len(), elemsTyp.Len())
+	}
+	if limit > 0 && uint64(elems.Len()) > limit {
+		return 0, fmt.Errorf("list %v holds %d elements, more than its limit of %d", typ, elems.Len(), limit)
+	}
+	factory, err := SSZFactory(elems, elemsTyp)
+	if err != nil {
+		return 0, err
+	}
+	return factory.Marshal(elems, elemsTyp, buf, startOffset)
+}
+
+func (s *sequenceSSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64, ctx *DecodeContext) (uint64, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			instantiateConcreteTypeForElement(val, typ.Elem())
+		}
+		return s.Unmarshal(val.Elem(), typ.Elem(), input, startOffset, ctx)
+	}
+	elems, elemsTyp, limit := sequenceElems(val)
+	if elemsTyp.Kind() == reflect.Array && elems.Len() != elemsTyp.Len() {
+		// A vector always decodes into as many elements as its length.
+		elems.Set(reflect.MakeSlice(elems.Type(), elemsTyp.Len(), elemsTyp.Len()))
+	}
+	factory, err := SSZFactory(elems, elemsTyp)
+	if err != nil {
+		return 0, err
+	}
+	index, err := factory.Unmarshal(elems, elemsTyp, input, startOffset, ctx)
+	if err != nil {
+		return 0, err
+	}
+	if limit > 0 && uint64(elems.Len()) > limit {
+		return 0, fmt.Errorf("list %v decoded %d elements, more than its limit of %d", typ, elems.Len(), limit)
+	}
+	return index, nil
+}
+
+func (s *sequenceSSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *HashContext) ([32]byte, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return s.Root(reflect.New(typ.Elem()).Elem(), typ.Elem(), fieldName, maxCapacity, ctx)
+		}
+		return s.Root(val.Elem(), typ.Elem(), fieldName, maxCapacity, ctx)
+	}
+	elems, elemsTyp, limit := sequenceElems(val)
+	if elemsTyp.Kind() == reflect.Array && elems.Len() != elemsTyp.Len() {
+		return [32]byte{}, fmt.Errorf("vector %v holds %d elements, expected %d", typ, elems.Len(), elemsTyp.Len())
+	}
+	if limit > 0 {
+		if uint64(elems.Len()) > limit {
+			return [32]byte{}, fmt.Errorf("list %v holds %d elements, more than its limit of %d", typ, elems.Len(), limit)
+		}
+		maxCapacity = limit
+	}
+	factory, err := SSZFactory(elems, elemsTyp)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return factory.Root(elems, elemsTyp, fieldName, maxCapacity, ctx)
+}
+
+func (s *sequenceSSZ) size(val reflect.Value) uint64 {
+	elems, elemsTyp, _ := sequenceElems(val)
+	if isVariableSizeType(elemsTyp) {
+		return determineVariableSize(elems, elemsTyp)
+	}
+	return determineFixedSize(elems, elemsTyp)
+}
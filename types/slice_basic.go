@@ -1,6 +1,8 @@
 package types
 
 import (
+	"encoding/binary"
+	"fmt"
 	"reflect"
 )
 
@@ -10,6 +12,60 @@ func newBasicSliceSSZ() *basicSliceSSZ {
 	return &basicSliceSSZ{}
 }
 
+func (b *basicSliceSSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *HashContext) ([32]byte, error) {
+	var factory SSZAble
+	var limit uint64
+	var err error
+	numItems := val.Len()
+	if numItems > 0 {
+		factory, err = SSZFactory(val.Index(0), typ.Elem())
+		if err != nil {
+			return [32]byte{}, err
+		}
+	}
+
+	elemSize := packedElemSize(typ.Elem())
+	if elemSize > 0 {
+		limit = (maxCapacity*elemSize + 31) / 32
+	} else {
+		limit = maxCapacity
+	}
+	if limit == 0 {
+		if numItems == 0 {
+			limit = 1
+		} else {
+			limit = uint64(numItems)
+		}
+	}
+	leaves := make([][]byte, numItems)
+	for i := 0; i < numItems; i++ {
+		if elemSize > 0 {
+			innerBuf := make([]byte, elemSize)
+			if _, err = factory.Marshal(val.Index(i), typ.Elem(), innerBuf, 0); err != nil {
+				return [32]byte{}, withIndex(i, err)
+			}
+			leaves[i] = innerBuf
+		} else {
+			r, err := factory.Root(val.Index(i), typ.Elem(), fieldName, 0, ctx)
+			if err != nil {
+				return [32]byte{}, withIndex(i, err)
+			}
+			leaves[i] = r[:]
+		}
+	}
+	chunks, err := pack(leaves)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	output := make([]byte, 32)
+	binary.LittleEndian.PutUint64(output, uint64(numItems))
+	merkleRoot, err := ctx.hasher().merkleize(chunks, uint64(len(chunks)), limit)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return ctx.hasher().mixInLength(merkleRoot, output), nil
+}
+
 func (b *basicSliceSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
 	index := startOffset
 	var err error
@@ -23,33 +79,33 @@ func (b *basicSliceSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte,
 	for i := 0; i < val.Len(); i++ {
 		index, err = factory.Marshal(val.Index(i), typ.Elem(), buf, index)
 		if err != nil {
-			return 0, err
+			return 0, withIndex(i, err)
 		}
 	}
 	return index, nil
 }
 
-func (b *basicSliceSSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64) (uint64, error) {
+func (b *basicSliceSSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64, ctx *DecodeContext) (uint64, error) {
 	if len(input) == 0 {
-		newVal := reflect.MakeSlice(val.Type(), 0, 0)
-		val.Set(newVal)
+		truncateSlice(val)
 		return 0, nil
 	}
+	// Every element would decode from zero bytes, so the number of elements
+	// could never be recovered from the input.
+	if IsZeroSizeType(typ.Elem()) {
+		return 0, fmt.Errorf("lists of zero-size elements are not supported, elements of type %v are indistinguishable once serialized: %w", typ.Elem(), ErrUnsupportedType)
+	}
+	if err := ctx.enter(); err != nil {
+		return 0, err
+	}
+	defer ctx.leave()
+	elemSize := allocSize(typ.Elem())
+	if err := ctx.alloc(elemSize); err != nil {
+		return 0, err
+	}
 	// If there are struct tags that specify a different type, we handle accordingly.
 	if val.Type() != typ {
-		sizes := []uint64{1}
-		innerElement := typ.Elem()
-		for {
-			if innerElement.Kind() == reflect.Slice {
-				sizes = append(sizes, 0)
-				innerElement = innerElement.Elem()
-			} else if innerElement.Kind() == reflect.Array {
-				sizes = append(sizes, uint64(innerElement.Len()))
-				innerElement = innerElement.Elem()
-			} else {
-				break
-			}
-		}
+		sizes := append([]uint64{1}, tagSizes(typ.Elem())...)
 		// If the item is a slice, we grow it accordingly based on the size tags.
 		result := growSliceFromSizeTags(val, sizes)
 		reflect.Copy(result, val)
@@ -64,40 +120,51 @@ func (b *basicSliceSSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []b
 	if err != nil {
 		return 0, err
 	}
-	index, err = factory.Unmarshal(val.Index(0), typ.Elem(), input, index)
-	if err != nil {
+	if err := ctx.step(1); err != nil {
 		return 0, err
 	}
+	index, err = factory.Unmarshal(val.Index(0), typ.Elem(), input, index, ctx)
+	if err != nil {
+		return 0, withIndex(0, err)
+	}
 
 	elementSize := index - startOffset
+	if elementSize == 0 || uint64(len(input))%elementSize != 0 {
+		return 0, fmt.Errorf(
+			"input for type %v has %d bytes, which is not a multiple of the %d bytes of its elements: %w",
+			typ,
+			len(input),
+			elementSize,
+			ErrSizeMismatch,
+		)
+	}
 	endOffset := uint64(len(input)) / elementSize
+	if err := ctx.alloc((endOffset - 1) * elemSize); err != nil {
+		return 0, err
+	}
 	if val.Type() != typ {
-		sizes := []uint64{endOffset}
-		innerElement := typ.Elem()
-		for {
-			if innerElement.Kind() == reflect.Slice {
-				sizes = append(sizes, 0)
-				innerElement = innerElement.Elem()
-			} else if innerElement.Kind() == reflect.Array {
-				sizes = append(sizes, uint64(innerElement.Len()))
-				innerElement = innerElement.Elem()
-			} else {
-				break
-			}
-		}
+		sizes := append([]uint64{endOffset}, tagSizes(typ.Elem())...)
 		// If the item is a slice, we grow it accordingly based on the size tags.
 		result := growSliceFromSizeTags(val, sizes)
 		reflect.Copy(result, val)
 		val.Set(result)
+	} else {
+		// The number of elements is known from the size of the first one, so the
+		// slice is allocated once rather than grown as they are decoded.
+		resizeSlice(val, int(endOffset))
 	}
+	pointers := val.Type() == typ && typ.Elem().Kind() == reflect.Ptr
 	i := uint64(1)
 	for i < endOffset {
-		if val.Type() == typ {
-			growConcreteSliceType(val, val.Type(), int(i)+1)
+		if err := ctx.step(1); err != nil {
+			return 0, err
 		}
-		index, err = factory.Unmarshal(val.Index(int(i)), typ.Elem(), input, index)
+		if pointers {
+			instantiateConcreteTypeForElement(val.Index(int(i)), typ.Elem().Elem())
+		}
+		index, err = factory.Unmarshal(val.Index(int(i)), typ.Elem(), input, index, ctx)
 		if err != nil {
-			return 0, err
+			return 0, withIndex(int(i), err)
 		}
 		i++
 	}
@@ -0,0 +1,317 @@
+package types
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBasicSliceUnmarshal_ZeroSizeElements(t *testing.T) {
+	var items []struct{}
+	val := reflect.ValueOf(&items).Elem()
+	_, err := newBasicSliceSSZ().Unmarshal(val, val.Type(), []byte{1, 2, 3}, 0, nil)
+	if err == nil || !strings.Contains(err.Error(), "zero-size elements") {
+		t.Errorf("Expected lists of zero-size elements to be rejected, received %v", err)
+	}
+}
+
+func TestBasicSliceUnmarshal_PartialElements(t *testing.T) {
+	tests := []struct {
+		val   interface{}
+		input []byte
+		want  string
+	}{
+		{val: &[]uint16{}, input: []byte{1, 2, 3}, want: "has 3 bytes, which is not a multiple of the 2 bytes of its elements"},
+		{val: &[]uint64{}, input: make([]byte, 12), want: "has 12 bytes, which is not a multiple of the 8 bytes of its elements"},
+		{val: &[][4]byte{}, input: make([]byte, 5), want: "has 5 bytes, which is not a multiple of the 4 bytes of its elements"},
+	}
+	for _, tt := range tests {
+		val := reflect.ValueOf(tt.val).Elem()
+		_, err := newBasicSliceSSZ().Unmarshal(val, val.Type(), tt.input, 0, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%T: expected an error containing %q, received %v", tt.val, tt.want, err)
+		}
+	}
+}
+
+func TestBasicSliceUnmarshal_AllocationLimit(t *testing.T) {
+	var items []uint64
+	val := reflect.ValueOf(&items).Elem()
+	ctx := NewDecodeContext(nil, 0, 0)
+	ctx.SetMaxBytes(8 * 15)
+	_, err := newBasicSliceSSZ().Unmarshal(val, val.Type(), make([]byte, 8*16), 0, ctx)
+	if !errors.Is(err, ErrAllocationLimit) {
+		t.Fatalf("Expected the allocation limit to be exceeded, received %v", err)
+	}
+	// The list is rejected once its length is known from the first element.
+	if cap(items) > 1 {
+		t.Errorf("Expected the list not to be allocated, it has a capacity of %d", cap(items))
+	}
+	ctx = NewDecodeContext(nil, 0, 0)
+	ctx.SetMaxBytes(8 * 16)
+	if _, err := newBasicSliceSSZ().Unmarshal(val, val.Type(), make([]byte, 8*16), 0, ctx); err != nil {
+		t.Errorf("Expected the list to fit within the limit, received %v", err)
+	}
+	if len(items) != 16 {
+		t.Errorf("Expected 16 elements, received %d", len(items))
+	}
+}
+
+func TestElementFactory_MatchesValues(t *testing.T) {
+	type point struct {
+		X, Y uint64
+	}
+	vals := []interface{}{
+		uint64(7),
+		[32]byte{1},
+		[]uint64{1, 2},
+		[][32]byte{{1}},
+		[4][32]byte{{1}},
+		[]string{"a"},
+		point{1, 2},
+		&point{1, 2},
+		&[]uint64{1},
+	}
+	for _, v := range vals {
+		val := reflect.ValueOf(v)
+		want, err := SSZFactory(val, val.Type())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := elementFactory(val.Type())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reflect.TypeOf(got) != reflect.TypeOf(want) {
+			t.Errorf("%T: expected the factory %T, received %T", v, want, got)
+		}
+	}
+}
+
+func TestBasicArrayUnmarshal_PointerElements(t *testing.T) {
+	input := make([]byte, 24)
+	for i := range input {
+		input[i] = byte(i)
+	}
+	var items [3]*uint64
+	val := reflect.ValueOf(&items).Elem()
+	if _, err := newBasicArraySSZ().Unmarshal(val, val.Type(), input, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i, item := range items {
+		if item == nil || *item != binary.LittleEndian.Uint64(input[i*8:]) {
+			t.Errorf("Element %d: expected %d, received %v", i, binary.LittleEndian.Uint64(input[i*8:]), item)
+		}
+	}
+}
+
+func BenchmarkBasicSliceUnmarshal_1MUint64(b *testing.B) {
+	input := make([]byte, 8<<20)
+	typ := reflect.TypeOf([]uint64{})
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		val := reflect.New(typ).Elem()
+		if _, err := newBasicSliceSSZ().Unmarshal(val, typ, input, 0, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBasicSliceUnmarshal_64kRoots(b *testing.B) {
+	input := make([]byte, 32<<16)
+	typ := reflect.TypeOf([][32]byte{})
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		val := reflect.New(typ).Elem()
+		if _, err := newBasicSliceSSZ().Unmarshal(val, typ, input, 0, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompositeSliceUnmarshal_64kLists(b *testing.B) {
+	items := make([][]uint64, 1<<16)
+	for i := range items {
+		items[i] = []uint64{uint64(i)}
+	}
+	typ := reflect.TypeOf(items)
+	val := reflect.ValueOf(items)
+	buf := make([]byte, DetermineSize(val))
+	if _, err := newCompositeSliceSSZ().Marshal(val, typ, buf, 0); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		val := reflect.New(typ).Elem()
+		if _, err := newCompositeSliceSSZ().Unmarshal(val, typ, buf, 0, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type smallPoint struct {
+	X uint64
+	Y uint32
+}
+
+type smallList struct {
+	Tag    uint16
+	Values []uint64 `ssz-max:"16"`
+}
+
+// decodeElementwise decodes input into val growing it an element at a time,
+// as lists were decoded before being allocated at their final length.
+func decodeElementwise(t *testing.T, val reflect.Value, input []byte, elemSize uint64) {
+	typ := val.Type()
+	factory, err := elementFactory(typ.Elem())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bounds [][2]uint64
+	if elemSize > 0 {
+		for i := uint64(0); i < uint64(len(input)); i += elemSize {
+			bounds = append(bounds, [2]uint64{i, i + elemSize})
+		}
+	} else {
+		first := uint64(binary.LittleEndian.Uint32(input))
+		for i := uint64(0); i < first; i += BytesPerLengthOffset {
+			end := uint64(len(input))
+			if i+BytesPerLengthOffset < first {
+				end = uint64(binary.LittleEndian.Uint32(input[i+BytesPerLengthOffset:]))
+			}
+			bounds = append(bounds, [2]uint64{uint64(binary.LittleEndian.Uint32(input[i:])), end})
+		}
+	}
+	for i, b := range bounds {
+		growConcreteSliceType(val, typ, i+1)
+		if _, err := factory.Unmarshal(val.Index(i), typ.Elem(), input[b[0]:b[1]], 0, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSliceUnmarshal_MatchesElementwiseGrowth(t *testing.T) {
+	reused := &smallPoint{X: 9}
+	tests := []struct {
+		name     string
+		items    interface{}
+		elemSize uint64
+		targets  func() []interface{}
+	}{
+		{
+			name:     "uint64",
+			items:    []uint64{1, 2, 3, 4, 5},
+			elemSize: 8,
+			targets: func() []interface{} {
+				return []interface{}{&[]uint64{}, &[]uint64{7}, func() *[]uint64 { s := make([]uint64, 1, 8); return &s }()}
+			},
+		},
+		{
+			name:     "roots",
+			items:    [][32]byte{{1}, {2}, {3}},
+			elemSize: 32,
+			targets: func() []interface{} {
+				return []interface{}{&[][32]byte{}, &[][32]byte{{9}, {9}, {9}, {9}}}
+			},
+		},
+		{
+			name:     "pointers",
+			items:    []*smallPoint{{1, 2}, {3, 4}, {5, 6}, {7, 8}},
+			elemSize: 12,
+			targets: func() []interface{} {
+				s := make([]*smallPoint, 0, 2)
+				s = append(s, &smallPoint{X: 9}, reused)[:0]
+				return []interface{}{&[]*smallPoint{}, &s}
+			},
+		},
+		{
+			name:     "lists",
+			items:    []smallList{{1, []uint64{1, 2}}, {2, []uint64{}}, {3, []uint64{3}}},
+			elemSize: 0,
+			targets: func() []interface{} {
+				s := make([]smallList, 1, 2)
+				s[0].Values = make([]uint64, 0, 4)
+				return []interface{}{&[]smallList{}, &s}
+			},
+		},
+		{
+			name:     "list pointers",
+			items:    []*smallList{{1, []uint64{1}}, {2, []uint64{}}},
+			elemSize: 0,
+			targets: func() []interface{} {
+				return []interface{}{&[]*smallList{}, &[]*smallList{{Tag: 9}}}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			val := reflect.ValueOf(tt.items)
+			typ := val.Type()
+			factory, err := SSZFactory(val, typ)
+			if err != nil {
+				t.Fatal(err)
+			}
+			input := make([]byte, DetermineSize(val))
+			if _, err := factory.Marshal(val, typ, input, 0); err != nil {
+				t.Fatal(err)
+			}
+			targets, references := tt.targets(), tt.targets()
+			for i := range targets {
+				got := reflect.ValueOf(targets[i]).Elem()
+				if _, err := factory.Unmarshal(got, typ, input, 0, nil); err != nil {
+					t.Fatal(err)
+				}
+				want := reflect.ValueOf(references[i]).Elem()
+				decodeElementwise(t, want, input, tt.elemSize)
+				if !reflect.DeepEqual(got.Interface(), want.Interface()) {
+					t.Errorf("Target %d: decoded %+v, expected %+v", i, got.Interface(), want.Interface())
+				}
+				if !reflect.DeepEqual(got.Interface(), tt.items) {
+					t.Errorf("Target %d: decoded %+v, expected the encoded %+v", i, got.Interface(), tt.items)
+				}
+			}
+			if tt.name == "pointers" {
+				// Pointers left in the backing array are decoded over.
+				s := *targets[1].(*[]*smallPoint)
+				if s[1] != reused {
+					t.Error("Expected the pointer in the backing array to be decoded over")
+				}
+			}
+		})
+	}
+}
+
+func TestResizeSlice(t *testing.T) {
+	s := make([]uint64, 1, 3)
+	s = append(s, 5, 6)[:1]
+	val := reflect.ValueOf(&s).Elem()
+	resizeSlice(val, 3)
+	if !reflect.DeepEqual(s, []uint64{0, 5, 6}) || cap(s) != 3 {
+		t.Errorf("Expected the backing array to be reused, received %v with capacity %d", s, cap(s))
+	}
+	resizeSlice(val, 5)
+	if !reflect.DeepEqual(s, []uint64{0, 5, 6, 0, 0}) || cap(s) != 5 {
+		t.Errorf("Expected the elements to be kept in an array of the final length, received %v with capacity %d", s, cap(s))
+	}
+}
+
+func BenchmarkBasicSliceUnmarshal_50kStructs(b *testing.B) {
+	input := make([]byte, 12*50000)
+	typ := reflect.TypeOf([]smallPoint{})
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		val := reflect.New(typ).Elem()
+		if _, err := newBasicSliceSSZ().Unmarshal(val, typ, input, 0, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package types
 
 import (
 	"encoding/binary"
+	"fmt"
 	"reflect"
 )
 
@@ -11,6 +12,48 @@ func newCompositeSliceSSZ() *compositeSliceSSZ {
 	return &compositeSliceSSZ{}
 }
 
+func (b *compositeSliceSSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *HashContext) ([32]byte, error) {
+	output := make([]byte, 32)
+	if val.Len() == 0 && maxCapacity == 0 {
+		root, err := ctx.hasher().merkleize([][]byte{}, 0, 0)
+		if err != nil {
+			return [32]byte{}, err
+		}
+		return ctx.hasher().mixInLength(root, output), nil
+	}
+	numItems := val.Len()
+	var factory SSZAble
+	var err error
+	if numItems > 0 {
+		factory, err = SSZFactory(val.Index(0), typ.Elem())
+		if err != nil {
+			return [32]byte{}, err
+		}
+	}
+	roots := make([][]byte, numItems)
+	for i := 0; i < numItems; i++ {
+		r, err := factory.Root(val.Index(i), typ.Elem(), fieldName, 0, ctx)
+		if err != nil {
+			return [32]byte{}, withIndex(i, err)
+		}
+		roots[i] = r[:]
+	}
+	chunks, err := pack(roots)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	binary.LittleEndian.PutUint64(output, uint64(numItems))
+	objLen := maxCapacity
+	if maxCapacity == 0 {
+		objLen = uint64(val.Len())
+	}
+	root, err := ctx.hasher().merkleize(chunks, uint64(len(chunks)), objLen)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return ctx.hasher().mixInLength(root, output), nil
+}
+
 func (b *compositeSliceSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
 	index := startOffset
 	if val.Len() == 0 {
@@ -26,7 +69,7 @@ func (b *compositeSliceSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []b
 			// into the buffer at the last index we wrote at.
 			index, err = factory.Marshal(val.Index(i), typ.Elem(), buf, index)
 			if err != nil {
-				return 0, err
+				return 0, withIndex(i, err)
 			}
 		}
 		return index, nil
@@ -39,12 +82,10 @@ func (b *compositeSliceSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []b
 	for i := 0; i < val.Len(); i++ {
 		nextOffsetIndex, err = factory.Marshal(val.Index(i), typ.Elem(), buf, currentOffsetIndex)
 		if err != nil {
-			return 0, err
+			return 0, withIndex(i, err)
 		}
 		// Write the offset.
-		offsetBuf := make([]byte, BytesPerLengthOffset)
-		binary.LittleEndian.PutUint32(offsetBuf, uint32(currentOffsetIndex-startOffset))
-		copy(buf[fixedIndex:fixedIndex+BytesPerLengthOffset], offsetBuf)
+		writeOffset(buf, fixedIndex, currentOffsetIndex-startOffset)
 
 		// We increase the offset indices accordingly.
 		currentOffsetIndex = nextOffsetIndex
@@ -54,19 +95,43 @@ func (b *compositeSliceSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []b
 	return index, nil
 }
 
-func (b *compositeSliceSSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64) (uint64, error) {
+func (b *compositeSliceSSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64, ctx *DecodeContext) (uint64, error) {
 	if len(input) == 0 {
-		newVal := reflect.MakeSlice(val.Type(), 0, 0)
-		val.Set(newVal)
+		truncateSlice(val)
 		return 0, nil
 	}
-	growConcreteSliceType(val, typ, 1)
+	if err := ctx.enter(); err != nil {
+		return 0, err
+	}
+	defer ctx.leave()
 	endOffset := uint64(len(input))
 
 	currentIndex := startOffset
 	nextIndex := currentIndex
-	offsetVal := input[startOffset : startOffset+BytesPerLengthOffset]
-	firstOffset := startOffset + uint64(binary.LittleEndian.Uint32(offsetVal))
+	offset, err := readOffset(input, startOffset)
+	if err != nil {
+		return 0, err
+	}
+	// The first offset is also the length of the offset table, which holds an
+	// offset per element.
+	if offset == 0 || offset%BytesPerLengthOffset != 0 {
+		return 0, fmt.Errorf("first offset %d of type %v is not a non-zero multiple of %d: %w", offset, typ, BytesPerLengthOffset, ErrOffsetOutOfBounds)
+	}
+	firstOffset := startOffset + offset
+	if firstOffset > endOffset {
+		return 0, fmt.Errorf("offset %d of type %v is out of bounds, input has length %d: %w", offset, typ, endOffset-startOffset, ErrOffsetOutOfBounds)
+	}
+	if err := ctx.alloc(offset / BytesPerLengthOffset * allocSize(typ.Elem())); err != nil {
+		return 0, err
+	}
+	factory, err := elementFactory(typ.Elem())
+	if err != nil {
+		return 0, err
+	}
+	// The number of elements is known from the offset table, so the slice is
+	// allocated once rather than grown as they are decoded.
+	resizeSlice(val, int(offset/BytesPerLengthOffset))
+	pointers := val.Type().Elem().Kind() == reflect.Ptr
 	currentOffset := firstOffset
 	nextOffset := currentOffset
 	i := 0
@@ -75,24 +140,47 @@ func (b *compositeSliceSSZ) Unmarshal(val reflect.Value, typ reflect.Type, input
 		if nextIndex == firstOffset {
 			nextOffset = endOffset
 		} else {
-			nextOffsetVal := input[nextIndex : nextIndex+BytesPerLengthOffset]
-			nextOffset = startOffset + uint64(binary.LittleEndian.Uint32(nextOffsetVal))
+			offset, err := readOffset(input, nextIndex)
+			if err != nil {
+				return 0, err
+			}
+			nextOffset = startOffset + offset
+			if nextOffset < firstOffset {
+				err := fmt.Errorf("offset %d of type %v points inside its offset table of %d bytes: %w", offset, typ, firstOffset-startOffset, ErrOffsetOutOfBounds)
+				return 0, withIndex(i+1, err)
+			}
+			if nextOffset > endOffset {
+				err := fmt.Errorf("offset %d of type %v is out of bounds, input has length %d: %w", offset, typ, endOffset-startOffset, ErrOffsetOutOfBounds)
+				return 0, withIndex(i+1, err)
+			}
 		}
+		// Elements are laid out in order, so their offsets never decrease. Empty
+		// elements, such as empty lists, share their offset with the next one.
 		if nextOffset < currentOffset {
-			break
+			err := fmt.Errorf(
+				"offset %d of element %d of type %v is out of order, expected at least the offset %d of element %d: %w",
+				nextOffset-startOffset,
+				i+1,
+				typ,
+				currentOffset-startOffset,
+				i,
+				ErrOffsetsNotIncreasing,
+			)
+			return 0, withIndex(i+1, err)
 		}
-		// We grow the slice's size to accommodate a new element being unmarshaled.
-		growConcreteSliceType(val, typ, i+1)
-		factory, err := SSZFactory(val.Index(i), typ.Elem())
-		if err != nil {
+		if err := ctx.step(1); err != nil {
 			return 0, err
 		}
-		if _, err := factory.Unmarshal(val.Index(i), typ.Elem(), input[currentOffset:nextOffset], 0); err != nil {
-			return 0, err
+		if pointers {
+			instantiateConcreteTypeForElement(val.Index(i), typ.Elem().Elem())
+		}
+		if _, err := factory.Unmarshal(val.Index(i), typ.Elem(), input[currentOffset:nextOffset], 0, ctx); err != nil {
+			return 0, withIndex(i, err)
 		}
 		i++
 		currentIndex = nextIndex
 		currentOffset = nextOffset
 	}
-	return currentIndex, nil
+	// The last element extends to the end of the input.
+	return endOffset, nil
 }
@@ -0,0 +1,355 @@
+package types
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DefaultStreamBufferSize is the size of the buffer StreamEncoder writes
+// through unless told otherwise.
+const DefaultStreamBufferSize = 64 << 10
+
+// StreamEncoder marshals values into an io.Writer through a buffer of bounded
+// size. Containers are written field by field and lists and vectors element by
+// element, so the encoding of a value larger than the buffer is never held in
+// memory as a whole, while values which fit in the buffer are marshaled into it
+// at once. The output is identical to that of the factories' Marshal.
+type StreamEncoder struct {
+	w   io.Writer
+	buf []byte
+	// path holds the segments of the path of the field being encoded, such as
+	// "Body", ".Deposits" and "[3]".
+	path []string
+	// err is the error the writer failed with, after which nothing more is
+	// written.
+	err error
+}
+
+// WriteError is returned by StreamEncoder when its writer fails. Path is the
+// path of the field being encoded at the time, such as "Body.Deposits[3]", or
+// empty when the failure concerns the value as a whole.
+type WriteError struct {
+	Path string
+	Err  error
+}
+
+func (e *WriteError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("could not write encoding: %v", e.Err)
+	}
+	return fmt.Sprintf("could not write encoding of field %s: %v", e.Path, e.Err)
+}
+
+// Unwrap returns the error the writer failed with.
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// NewStreamEncoder returns a StreamEncoder writing to w through a buffer of
+// bufferSize bytes, or DefaultStreamBufferSize if it is not positive.
+func NewStreamEncoder(w io.Writer, bufferSize int) *StreamEncoder {
+	if bufferSize <= 0 {
+		bufferSize = DefaultStreamBufferSize
+	}
+	return &StreamEncoder{w: w, buf: make([]byte, 0, bufferSize)}
+}
+
+// Encode writes the encoding of val and flushes it to the writer. Nil pointers
+// are encoded as the zero value of the type they point to. When encoding fails,
+// the part of the encoding which did not fit in the buffer may already have
+// been written. Once the writer fails, every call returns its error.
+func (e *StreamEncoder) Encode(val reflect.Value) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.path = e.path[:0]
+	if val.Kind() != reflect.Ptr && !val.CanAddr() {
+		// Vectors are written in batches of elements sliced out of them, which
+		// requires them to be addressable.
+		addressable := reflect.New(val.Type()).Elem()
+		addressable.Set(val)
+		val = addressable
+	}
+	if err := e.encode(val, val.Type()); err != nil {
+		// What is left of a failed encoding is dropped, so that it does not
+		// precede the encoding of the next value.
+		e.buf = e.buf[:0]
+		return err
+	}
+	return e.flush()
+}
+
+// WriteEncoding writes an encoding produced elsewhere, such as by a generated
+// encoder, and flushes it to the writer.
+func (e *StreamEncoder) WriteEncoding(enc []byte) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.path = e.path[:0]
+	if err := e.write(enc); err != nil {
+		return err
+	}
+	return e.flush()
+}
+
+func (e *StreamEncoder) encode(val reflect.Value, typ reflect.Type) error {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return e.encode(reflect.New(typ.Elem()).Elem(), typ.Elem())
+		}
+		return e.encode(val.Elem(), typ.Elem())
+	}
+	factory, err := SSZFactory(val, typ)
+	if err != nil {
+		return err
+	}
+	size := encodedSize(val, typ)
+	if _, ok := lookupAlias(typ); ok || isBitvectorType(typ) || isUnionType(typ) || isCodecType(typ) || isCustomType(typ) || size <= uint64(cap(e.buf)) {
+		return e.marshal(factory, val, typ, size)
+	}
+	switch {
+	case isSequenceType(typ):
+		elems, elemsTyp, limit := sequenceElems(val)
+		if elemsTyp.Kind() == reflect.Array && elems.Len() != elemsTyp.Len() {
+			return fmt.Errorf("vector %v holds %d elements, expected %d", typ, elems.Len(), elemsTyp.Len())
+		}
+		if limit > 0 && uint64(elems.Len()) > limit {
+			return fmt.Errorf("list %v holds %d elements, more than its limit of %d", typ, elems.Len(), limit)
+		}
+		return e.encode(elems, elemsTyp)
+	case typ.Kind() == reflect.Struct:
+		return e.encodeFields(val, typ)
+	case typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8 && val.Kind() == reflect.Slice && !isBitlistType(typ):
+		return e.write(val.Bytes())
+	case typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array:
+		return e.encodeElements(vectorValue(val, typ), typ)
+	default:
+		return e.marshal(factory, val, typ, size)
+	}
+}
+
+// encodeFields writes the fixed part of the container val, holding its
+// fixed-size fields and the offsets of its variable-size ones, followed by its
+// variable-size fields.
+func (e *StreamEncoder) encodeFields(val reflect.Value, typ reflect.Type) error {
+	fields := sszFields(typ)
+	fieldTypes := make([]reflect.Type, len(fields))
+	fixedLength := uint64(0)
+	for i, field := range fields {
+		fType, err := determineFieldType(field)
+		if err != nil {
+			return withField(sszFieldName(field), err)
+		}
+		fieldTypes[i] = fType
+		if isVariableSizeType(fType) {
+			fixedLength += BytesPerLengthOffset
+		} else {
+			fixedLength += determineFixedSize(val.Field(field.Index[0]), fType)
+		}
+	}
+	offset := fixedLength
+	for i, fType := range fieldTypes {
+		if !isVariableSizeType(fType) {
+			if err := e.encodeField(val, fields[i], fType); err != nil {
+				return err
+			}
+			continue
+		}
+		fieldVal := val.Field(fields[i].Index[0])
+		if fType.Kind() == reflect.String {
+			if err := checkStringCapacity(fields[i], determineFieldCapacity(fields[i]), uint64(fieldVal.Len())); err != nil {
+				return withField(sszFieldName(fields[i]), err)
+			}
+		}
+		if err := checkBitlistCapacity(fields[i], determineFieldCapacity(fields[i]), fieldVal); err != nil {
+			return withField(sszFieldName(fields[i]), err)
+		}
+		if err := e.writeOffset(offset); err != nil {
+			return err
+		}
+		offset += encodedSize(fieldVal, fType)
+	}
+	for i, fType := range fieldTypes {
+		if !isVariableSizeType(fType) {
+			continue
+		}
+		if err := e.encodeField(val, fields[i], fType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *StreamEncoder) encodeField(val reflect.Value, field reflect.StructField, fType reflect.Type) error {
+	name := sszFieldName(field)
+	if len(e.path) > 0 {
+		name = "." + name
+	}
+	e.path = append(e.path, name)
+	defer func() {
+		e.path = e.path[:len(e.path)-1]
+	}()
+	fieldVal := val.Field(field.Index[0])
+	var err error
+	if isScaledField(field) {
+		var factory SSZAble
+		factory, err = fieldFactory(field, fieldVal, fType)
+		if err == nil {
+			err = e.marshal(factory, fieldVal, fType, 8)
+		}
+	} else {
+		err = e.encode(fieldVal, fType)
+	}
+	if _, ok := err.(*WriteError); ok {
+		return err
+	}
+	return withField(sszFieldName(field), err)
+}
+
+// encodeElements writes the elements of the list or vector val. Fixed-size
+// elements are marshaled in batches filling the buffer, while variable-size
+// ones are preceded by their offsets and written one by one.
+func (e *StreamEncoder) encodeElements(val reflect.Value, typ reflect.Type) error {
+	if val.Len() == 0 {
+		return nil
+	}
+	elemTyp := typ.Elem()
+	if isVariableSizeType(elemTyp) {
+		offset := uint64(val.Len()) * BytesPerLengthOffset
+		for i := 0; i < val.Len(); i++ {
+			if err := e.writeOffset(offset); err != nil {
+				return err
+			}
+			offset += encodedSize(val.Index(i), elemTyp)
+		}
+		for i := 0; i < val.Len(); i++ {
+			if err := e.encodeElement(val, elemTyp, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	elemSize := encodedSize(val.Index(0), elemTyp)
+	if elemSize == 0 || elemSize > uint64(cap(e.buf)) {
+		for i := 0; i < val.Len(); i++ {
+			if err := e.encodeElement(val, elemTyp, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	batch := int(uint64(cap(e.buf)) / elemSize)
+	for start := 0; start < val.Len(); start += batch {
+		end := start + batch
+		if end > val.Len() {
+			end = val.Len()
+		}
+		batchVal := val.Slice(start, end)
+		batchTyp := typ
+		if typ.Kind() == reflect.Array {
+			batchTyp = reflect.ArrayOf(end-start, elemTyp)
+		}
+		factory, err := SSZFactory(batchVal, batchTyp)
+		if err != nil {
+			return err
+		}
+		if err := e.marshal(factory, batchVal, batchTyp, uint64(end-start)*elemSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *StreamEncoder) encodeElement(val reflect.Value, elemTyp reflect.Type, i int) error {
+	e.path = append(e.path, "["+strconv.Itoa(i)+"]")
+	err := e.encode(val.Index(i), elemTyp)
+	e.path = e.path[:len(e.path)-1]
+	if _, ok := err.(*WriteError); ok {
+		return err
+	}
+	return withIndex(i, err)
+}
+
+// marshal marshals val into the buffer, flushing it first if it lacks room.
+// Values larger than the buffer are marshaled on their own.
+func (e *StreamEncoder) marshal(factory SSZAble, val reflect.Value, typ reflect.Type, size uint64) error {
+	if size > uint64(cap(e.buf)-len(e.buf)) {
+		if err := e.flush(); err != nil {
+			return err
+		}
+	}
+	var enc []byte
+	if size > uint64(cap(e.buf)) {
+		enc = make([]byte, size)
+	} else {
+		enc = e.buf[len(e.buf) : len(e.buf)+int(size)]
+		for i := range enc {
+			enc[i] = 0
+		}
+	}
+	end, err := factory.Marshal(val, typ, enc, 0)
+	if err != nil {
+		return err
+	}
+	if end != size {
+		return fmt.Errorf("marshaling %v wrote %d bytes, expected %d", typ, end, size)
+	}
+	if size > uint64(cap(e.buf)) {
+		return e.writeThrough(enc)
+	}
+	e.buf = e.buf[:len(e.buf)+int(size)]
+	return nil
+}
+
+func (e *StreamEncoder) writeOffset(offset uint64) error {
+	var enc [BytesPerLengthOffset]byte
+	writeOffset(enc[:], 0, offset)
+	return e.write(enc[:])
+}
+
+// write appends p to the buffer, writing it through once the buffer is full.
+func (e *StreamEncoder) write(p []byte) error {
+	if len(p) <= cap(e.buf)-len(e.buf) {
+		e.buf = append(e.buf, p...)
+		return nil
+	}
+	if err := e.flush(); err != nil {
+		return err
+	}
+	if len(p) <= cap(e.buf) {
+		e.buf = append(e.buf, p...)
+		return nil
+	}
+	return e.writeThrough(p)
+}
+
+func (e *StreamEncoder) flush() error {
+	if len(e.buf) == 0 {
+		return nil
+	}
+	err := e.writeThrough(e.buf)
+	e.buf = e.buf[:0]
+	return err
+}
+
+func (e *StreamEncoder) writeThrough(p []byte) error {
+	if _, err := e.w.Write(p); err != nil {
+		e.err = &WriteError{Path: strings.Join(e.path, ""), Err: err}
+		return e.err
+	}
+	return nil
+}
+
+// encodedSize returns the size of the encoding of val as a value of type typ.
+func encodedSize(val reflect.Value, typ reflect.Type) uint64 {
+	if typ.Kind() == reflect.Ptr && val.IsNil() {
+		return determineZeroValueSize(typ.Elem())
+	}
+	if isVariableSizeType(typ) {
+		return determineVariableSize(val, typ)
+	}
+	return determineFixedSize(val, typ)
+}
@@ -0,0 +1,227 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// StreamDecoder unmarshals values from an io.Reader without reading their
+// whole encoding first. Containers are read field by field: the fixed part of
+// a variable-size container, holding its fixed-size fields and the offsets of
+// its variable-size ones, is read first, followed by each variable-size field
+// in turn, while fixed-size containers are read one field at a time. Other
+// values are read in full before being decoded by their factories, so the
+// decoded values are identical to those of Unmarshal.
+//
+// The encoding of a fixed-size value is as long as the value, so that several
+// such values can be decoded one after the other, while the last variable-size
+// field of a variable-size value extends to the end of the reader.
+type StreamDecoder struct {
+	r   io.Reader
+	ctx *DecodeContext
+	// read counts the bytes read from r.
+	read uint64
+}
+
+// NewStreamDecoder returns a StreamDecoder reading from r, whose work is bounded
+// by ctx, which may be nil.
+func NewStreamDecoder(r io.Reader, ctx *DecodeContext) *StreamDecoder {
+	return &StreamDecoder{r: r, ctx: ctx}
+}
+
+// Decode decodes the value val, which must be settable, and returns the number
+// of bytes read.
+func (d *StreamDecoder) Decode(val reflect.Value) (uint64, error) {
+	start := d.read
+	err := d.decode(val, val.Type(), -1)
+	return d.read - start, err
+}
+
+// decode decodes val as a value of type typ from the next length bytes of the
+// reader, or from the rest of the reader when length is negative and val is
+// variable-size.
+func (d *StreamDecoder) decode(val reflect.Value, typ reflect.Type, length int64) error {
+	if typ.Kind() == reflect.Ptr {
+		instantiateField(val, typ.Elem())
+		return d.decode(val.Elem(), typ.Elem(), length)
+	}
+	if typ.Kind() == reflect.Struct && !isSequenceType(typ) && !isAliasType(typ) && !isUnionType(typ) && !isCodecType(typ) && !isCustomType(typ) {
+		return d.decodeFields(val, typ, length)
+	}
+	if length < 0 && !isVariableSizeType(typ) {
+		length = int64(determineFixedSize(val, typ))
+	}
+	input, err := d.readSegment(length)
+	if err != nil {
+		return err
+	}
+	factory, err := SSZFactory(val, typ)
+	if err != nil {
+		return err
+	}
+	_, err = factory.Unmarshal(val, typ, input, 0, d.ctx)
+	return err
+}
+
+// decodeFields decodes the container val.
+func (d *StreamDecoder) decodeFields(val reflect.Value, typ reflect.Type, length int64) error {
+	if err := d.ctx.enter(); err != nil {
+		return err
+	}
+	defer d.ctx.leave()
+	fields := sszFields(typ)
+	numFields := len(fields)
+	fixedSizes, err := prepareFixedFields(val, typ)
+	if err != nil {
+		return err
+	}
+	fixedPartSize := uint64(0)
+	for i := 0; i < numFields; i++ {
+		if item, ok := fixedSizes[i]; ok {
+			fixedPartSize += item
+		} else {
+			fixedPartSize += BytesPerLengthOffset
+		}
+	}
+	if length >= 0 && uint64(length) < fixedPartSize {
+		return fmt.Errorf(
+			"input for type %v is truncated: expected %d bytes for its fixed part, received %d: %w",
+			typ,
+			fixedPartSize,
+			length,
+			ErrInputTooShort,
+		)
+	}
+	if len(fixedSizes) == numFields {
+		// Fixed-size containers are read straight from the reader, one field at
+		// a time.
+		for i := 0; i < numFields; i++ {
+			if err := d.decodeField(d, val, fields[i], int64(fixedSizes[i])); err != nil {
+				return withField(sszFieldName(fields[i]), err)
+			}
+		}
+		if length >= 0 && uint64(length) != fixedPartSize {
+			return fmt.Errorf("input for type %v has %d bytes, expected %d: %w", typ, length, fixedPartSize, ErrSizeMismatch)
+		}
+		return nil
+	}
+
+	fixedPart, err := d.readSegment(int64(fixedPartSize))
+	if err != nil {
+		return err
+	}
+	fixedDecoder := NewStreamDecoder(bytes.NewReader(fixedPart), d.ctx)
+	offsets := make([]uint64, 0, numFields)
+	index := uint64(0)
+	for i := 0; i < numFields; i++ {
+		if item, ok := fixedSizes[i]; ok {
+			if err := d.decodeField(fixedDecoder, val, fields[i], int64(item)); err != nil {
+				return withField(sszFieldName(fields[i]), err)
+			}
+			index += item
+			continue
+		}
+		offset, err := readOffset(fixedPart, index)
+		if err != nil {
+			return err
+		}
+		// Variable-size fields are read in order, so their offsets must follow
+		// the fixed part and each other.
+		previous := fixedPartSize
+		if len(offsets) > 0 {
+			previous = offsets[len(offsets)-1]
+		}
+		if (len(offsets) == 0 && offset != fixedPartSize) || offset < previous {
+			err := fmt.Errorf("offset %d of field %s of type %v is out of order, expected at least %d: %w", offset, fields[i].Name, typ, previous, ErrOffsetsNotIncreasing)
+			return withField(sszFieldName(fields[i]), err)
+		}
+		if length >= 0 && offset > uint64(length) {
+			err := fmt.Errorf("offset %d of field %s of type %v is out of bounds, input has length %d: %w", offset, fields[i].Name, typ, length, ErrOffsetOutOfBounds)
+			return withField(sszFieldName(fields[i]), err)
+		}
+		offsets = append(offsets, offset)
+		if _, err := fixedDecoder.readSegment(int64(BytesPerLengthOffset)); err != nil {
+			return err
+		}
+		index += BytesPerLengthOffset
+	}
+
+	offsetIndex := 0
+	for i := 0; i < numFields; i++ {
+		if _, ok := fixedSizes[i]; ok {
+			continue
+		}
+		segment := int64(-1)
+		if offsetIndex+1 < len(offsets) {
+			segment = int64(offsets[offsetIndex+1] - offsets[offsetIndex])
+		} else if length >= 0 {
+			segment = length - int64(offsets[offsetIndex])
+		}
+		if err := d.decodeField(d, val, fields[i], segment); err != nil {
+			return withField(sszFieldName(fields[i]), err)
+		}
+		offsetIndex++
+	}
+	return nil
+}
+
+// decodeField decodes the field of the container val from the next length
+// bytes read by from.
+func (d *StreamDecoder) decodeField(from *StreamDecoder, val reflect.Value, field reflect.StructField, length int64) error {
+	if err := d.ctx.step(1); err != nil {
+		return err
+	}
+	fType, err := determineFieldType(field)
+	if err != nil {
+		return err
+	}
+	if !isVariableSizeType(fType) && length == 0 {
+		return nil
+	}
+	if fType.Kind() == reflect.String && length >= 0 {
+		// The length of strings is checked before reading them, so an oversized
+		// input never gets allocated.
+		if err := checkStringCapacity(field, determineFieldCapacity(field), uint64(length)); err != nil {
+			return err
+		}
+	}
+	if isScaledField(field) {
+		factory, err := fieldFactory(field, val.Field(field.Index[0]), fType)
+		if err != nil {
+			return err
+		}
+		input, err := from.readSegment(length)
+		if err != nil {
+			return err
+		}
+		_, err = factory.Unmarshal(val.Field(field.Index[0]), fType, input, 0, d.ctx)
+		return err
+	}
+	if err := from.decode(val.Field(field.Index[0]), fType, length); err != nil {
+		return err
+	}
+	if err := checkBitlistCapacity(field, determineFieldCapacity(field), val.Field(field.Index[0])); err != nil {
+		return err
+	}
+	return checkListCapacity(d.ctx, field, determineFieldCapacity(field), val.Field(field.Index[0]))
+}
+
+// readSegment reads the next length bytes, or the rest of the reader when
+// length is negative. The bytes are read into a buffer growing as they arrive,
+// so that a corrupt length cannot make it allocate more than the reader holds.
+func (d *StreamDecoder) readSegment(length int64) ([]byte, error) {
+	var buf bytes.Buffer
+	if length < 0 {
+		n, err := buf.ReadFrom(d.r)
+		d.read += uint64(n)
+		return buf.Bytes(), err
+	}
+	n, err := io.CopyN(&buf, d.r, length)
+	d.read += uint64(n)
+	if err == io.EOF {
+		return nil, fmt.Errorf("input is truncated: expected %d more bytes, received %d: %w", length, n, ErrInputTooShort)
+	}
+	return buf.Bytes(), err
+}
@@ -0,0 +1,86 @@
+package types
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type streamedHeader struct {
+	Slot      uint64
+	StateRoot [32]byte
+}
+
+type streamedBlock struct {
+	Header     *streamedHeader
+	Roots      [][]byte `ssz-size:"?,32"`
+	Validators []streamedHeader
+	Name       string `ssz-max:"64"`
+	Bits       [4]uint16
+	Nested     []*streamedBlock
+}
+
+// marshalForStreamTest marshals val the way ssz.Marshal does.
+func marshalForStreamTest(t *testing.T, val interface{}) []byte {
+	t.Helper()
+	rval := reflect.ValueOf(val)
+	factory, err := SSZFactory(rval, rval.Type())
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, DetermineSize(rval))
+	if _, err := factory.Marshal(rval, rval.Type(), buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestStreamEncoder_MatchesMarshal(t *testing.T) {
+	block := &streamedBlock{
+		Header:     &streamedHeader{Slot: 9, StateRoot: [32]byte{9}},
+		Roots:      [][]byte{bytes.Repeat([]byte{1}, 32), nil, bytes.Repeat([]byte{3}, 32)},
+		Validators: []streamedHeader{{Slot: 1}, {Slot: 2, StateRoot: [32]byte{2}}},
+		Name:       "streamed",
+		Bits:       [4]uint16{1, 2, 3, 4},
+	}
+	block.Nested = []*streamedBlock{{Name: "child", Roots: [][]byte{bytes.Repeat([]byte{5}, 32)}}, nil}
+	values := []interface{}{
+		block,
+		*block,
+		&streamedBlock{},
+		[]*streamedBlock{block, {}, block},
+		[3]streamedHeader{{Slot: 1}, {Slot: 2}, {Slot: 3}},
+		bytes.Repeat([]byte{0xab}, 100),
+	}
+	for _, val := range values {
+		want := marshalForStreamTest(t, val)
+		// Buffers smaller than any field make every container and list be
+		// streamed, while larger ones marshal them at once.
+		for _, bufferSize := range []int{1, 7, 33, 100, 4096} {
+			var buf bytes.Buffer
+			if err := NewStreamEncoder(&buf, bufferSize).Encode(reflect.ValueOf(val)); err != nil {
+				t.Fatalf("Could not encode %T with a buffer of %d bytes: %v", val, bufferSize, err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("Expected %T encoded with a buffer of %d bytes to be %#x, received %#x", val, bufferSize, want, buf.Bytes())
+			}
+		}
+	}
+}
+
+func TestStreamEncoder_RejectsInvalidValues(t *testing.T) {
+	tooLong := &streamedBlock{Name: string(bytes.Repeat([]byte{'a'}, 65))}
+	var buf bytes.Buffer
+	enc := NewStreamEncoder(&buf, 16)
+	if err := enc.Encode(reflect.ValueOf(tooLong)); err == nil {
+		t.Error("Expected a string exceeding its ssz-max to be rejected")
+	}
+	written := buf.Len()
+	header := &streamedHeader{Slot: 1}
+	if err := enc.Encode(reflect.ValueOf(header)); err != nil {
+		t.Fatal(err)
+	}
+	if want := marshalForStreamTest(t, header); !bytes.Equal(buf.Bytes()[written:], want) {
+		t.Errorf("Expected a failed encoding not to precede the next one, received %#x", buf.Bytes()[written:])
+	}
+}
@@ -1,6 +1,9 @@
 package types
 
 import (
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"reflect"
 )
 
@@ -10,15 +13,64 @@ func newStringSSZ() *stringSSZ {
 	return &stringSSZ{}
 }
 
+func (b *stringSSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *HashContext) ([32]byte, error) {
+	var err error
+	numItems := val.Len()
+	// Strings are byte lists, whose roots depend on their capacity, so there
+	// is no correct root to compute without one.
+	if maxCapacity == 0 {
+		if fieldName == "" {
+			return [32]byte{}, errors.New("cannot hash a string without an ssz-max limit")
+		}
+		return [32]byte{}, fmt.Errorf("cannot hash string field %s without an ssz-max limit", fieldName)
+	}
+	if uint64(numItems) > maxCapacity {
+		return [32]byte{}, fmt.Errorf("string of length %d exceeds its ssz-max of %d", numItems, maxCapacity)
+	}
+	elemSize := uint64(1)
+	limit := (maxCapacity*elemSize + 31) / 32
+	if limit == 0 {
+		limit = 1
+	}
+	leaves := make([][]byte, numItems)
+	for i := 0; i < numItems; i++ {
+		innerBuf := make([]byte, elemSize)
+		if _, err = marshalUint8(val.Index(i), innerBuf, 0); err != nil {
+			return [32]byte{}, err
+		}
+		leaves[i] = innerBuf
+	}
+	chunks, err := pack(leaves)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	output := make([]byte, 32)
+	binary.LittleEndian.PutUint64(output, uint64(numItems))
+	merkleRoot, err := ctx.hasher().merkleize(chunks, uint64(len(chunks)), limit)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return ctx.hasher().mixInLength(merkleRoot, output), nil
+}
+
 func (b *stringSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
-	for i := 0; i < val.Len(); i++ {
-		buf[int(startOffset)+i] = uint8(val.Index(i).Uint())
+	end := startOffset + uint64(val.Len())
+	if end > uint64(len(buf)) {
+		return 0, fmt.Errorf("buffer of %d bytes is too small for a string of %d bytes at offset %d", len(buf), val.Len(), startOffset)
 	}
-	return startOffset + uint64(val.Len()), nil
+	copy(buf[startOffset:end], val.String())
+	return end, nil
 }
 
-func (b *stringSSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64) (uint64, error) {
-	offset := startOffset + uint64(len(input))
-	val.SetString(string(input[startOffset:offset]))
-	return offset, nil
+// Unmarshal decodes the string from the rest of the input after startOffset,
+// which strings always extend to the end of.
+func (b *stringSSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64, ctx *DecodeContext) (uint64, error) {
+	if startOffset > uint64(len(input)) {
+		return 0, fmt.Errorf("offset %d of type %v is out of bounds, input has length %d: %w", startOffset, typ, len(input), ErrOffsetOutOfBounds)
+	}
+	if err := ctx.alloc(uint64(len(input)) - startOffset); err != nil {
+		return 0, err
+	}
+	val.SetString(string(input[startOffset:]))
+	return uint64(len(input)), nil
 }
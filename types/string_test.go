@@ -0,0 +1,39 @@
+package types
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestStringUnmarshal_StartOffset(t *testing.T) {
+	input := []byte("prefix:value")
+	var s string
+	end, err := newStringSSZ().Unmarshal(reflect.ValueOf(&s).Elem(), reflect.TypeOf(s), input, 7, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "value" {
+		t.Errorf("Expected %q, received %q", "value", s)
+	}
+	if end != uint64(len(input)) {
+		t.Errorf("Expected the string to end at %d, received %d", len(input), end)
+	}
+	if _, err := newStringSSZ().Unmarshal(reflect.ValueOf(&s).Elem(), reflect.TypeOf(s), input, 13, nil); err == nil {
+		t.Error("Expected an offset beyond the input to be rejected")
+	}
+}
+
+func TestStringMarshal_StartOffset(t *testing.T) {
+	buf := []byte("prefix:?????")
+	end, err := newStringSSZ().Marshal(reflect.ValueOf("value"), reflect.TypeOf(""), buf, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, []byte("prefix:value")) || end != uint64(len(buf)) {
+		t.Errorf("Expected %q ending at %d, received %q ending at %d", "prefix:value", len(buf), buf, end)
+	}
+	if _, err := newStringSSZ().Marshal(reflect.ValueOf("value"), reflect.TypeOf(""), buf, 8); err == nil {
+		t.Error("Expected a string overflowing the buffer to be rejected")
+	}
+}
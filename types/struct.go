@@ -1,13 +1,12 @@
 package types
 
 import (
-	"encoding/binary"
 	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 
-	"log"
 	"github.com/pkg/errors"
 )
 
@@ -23,8 +22,67 @@ func newStructSSZ() *structSSZ {
 	return &structSSZ{}
 }
 
+func (b *structSSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *HashContext) ([32]byte, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			instance := reflect.New(typ.Elem()).Elem()
+			return b.Root(instance, instance.Type(), fieldName, maxCapacity, ctx)
+		}
+		return b.Root(val.Elem(), typ.Elem(), fieldName, maxCapacity, ctx)
+	}
+	return b.fieldsHasher(val, typ, ctx)
+}
+
+func (b *structSSZ) fieldsHasher(val reflect.Value, typ reflect.Type, ctx *HashContext) ([32]byte, error) {
+	names, roots, err := b.fieldRoots(val, typ, ctx)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	totalCountedFields := uint64(len(roots))
+	root, err := ctx.hasher().merkleize(roots, totalCountedFields, totalCountedFields)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	observeContainerRoot(typ, names, roots, root)
+	return root, nil
+}
+
+// fieldRoots returns the names and roots of the hashed fields of a container.
+func (b *structSSZ) fieldRoots(val reflect.Value, typ reflect.Type, ctx *HashContext) ([]string, [][]byte, error) {
+	if err := checkFieldNames(typ); err != nil {
+		return nil, nil, err
+	}
+	return b.rootsOfFields(val, typ, containerFields(typ), ctx)
+}
+
+// rootsOfFields returns the names and roots of the given fields of a container.
+func (b *structSSZ) rootsOfFields(val reflect.Value, typ reflect.Type, fields []containerField, ctx *HashContext) ([]string, [][]byte, error) {
+	names := make([]string, 0, len(fields))
+	roots := make([][]byte, 0, len(fields))
+	structName := typ.Name()
+	for i := range fields {
+		field := &fields[i]
+		fieldVal := val.Field(field.Index[0])
+		name := field.name
+		fieldCtx := ctx.field(name)
+		fCapacity := fieldCtx.capacity(field)
+		if field.typErr != nil {
+			return nil, nil, withField(name, field.typErr)
+		}
+		if field.factoryErr != nil {
+			return nil, nil, withField(name, field.factoryErr)
+		}
+		r, err := field.factory.Root(fieldVal, field.typ, structName+"."+name, fCapacity, fieldCtx)
+		if err != nil {
+			return nil, nil, withField(name, err)
+		}
+		names = append(names, name)
+		roots = append(roots, r[:])
+	}
+	return names, roots, nil
+}
+
 func (b *structSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
-	log.Printf("weiwu")
 	if typ.Kind() == reflect.Ptr {
 		if val.IsNil() {
 			newVal := reflect.New(typ.Elem()).Elem()
@@ -34,179 +92,515 @@ func (b *structSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, sta
 	}
 	fixedIndex := startOffset
 	fixedLength := uint64(0)
+	fields := containerFields(typ)
 	// For every field, we add up the total length of the items depending if they
 	// are variable or fixed-size fields.
-	for i := 0; i < typ.NumField(); i++ {
-		fType, err := determineFieldType(typ.Field(i))
-		if err != nil {
-			return 0, err
+	for i := range fields {
+		field := &fields[i]
+		if field.typErr != nil {
+			return 0, withField(field.name, field.typErr)
 		}
-		if isVariableSizeType(fType) {
+		if field.variable {
 			fixedLength += BytesPerLengthOffset
 		} else {
 			if val.Type().Kind() == reflect.Ptr && val.IsNil() {
 				elem := reflect.New(val.Type().Elem()).Elem()
-				fixedLength += determineFixedSize(elem, fType)
+				fixedLength += determineFixedSize(elem, field.typ)
 			} else {
-				fixedLength += determineFixedSize(val.Field(i), fType)
+				fixedLength += determineFixedSize(val.Field(field.Index[0]), field.typ)
 			}
 		}
-		log.Printf("fixed length: %d", fixedLength)
 	}
 	currentOffsetIndex := startOffset + fixedLength
-	for i := 0; i < typ.NumField(); i++ {
-		fType, err := determineFieldType(typ.Field(i))
+	for i := range fields {
+		field := &fields[i]
+		fieldVal := val.Field(field.Index[0])
+		fType := field.typ
+		factory, err := field.factory, field.factoryErr
 		if err != nil {
-			return 0, err
+			return 0, withField(field.name, err)
 		}
-		factory, err := SSZFactory(val.Field(i), fType)
-		if err != nil {
-			return 0, err
-		}
-		if !isVariableSizeType(fType) {
-			fixedIndex, err = factory.Marshal(val.Field(i), fType, buf, fixedIndex)
+		if !field.variable {
+			fixedIndex, err = factory.Marshal(fieldVal, fType, buf, fixedIndex)
 			if err != nil {
-				return 0, err
+				return 0, withField(field.name, err)
 			}
 		} else {
-			nextOffsetIndex, err := factory.Marshal(val.Field(i), fType, buf, currentOffsetIndex)
+			if fType.Kind() == reflect.String {
+				if err := checkStringCapacity(field.StructField, field.capacity, uint64(fieldVal.Len())); err != nil {
+					return 0, withField(field.name, err)
+				}
+			}
+			if err := checkBitlistCapacity(field.StructField, field.capacity, fieldVal); err != nil {
+				return 0, withField(field.name, err)
+			}
+			nextOffsetIndex, err := factory.Marshal(fieldVal, fType, buf, currentOffsetIndex)
 			if err != nil {
-				return 0, err
+				return 0, withField(field.name, err)
 			}
 			// Write the offset.
-			offsetBuf := make([]byte, BytesPerLengthOffset)
-			binary.LittleEndian.PutUint32(offsetBuf, uint32(currentOffsetIndex-startOffset))
-			copy(buf[fixedIndex:fixedIndex+BytesPerLengthOffset], offsetBuf)
+			writeOffset(buf, fixedIndex, currentOffsetIndex-startOffset)
 
 			// We increase the offset indices accordingly.
 			currentOffsetIndex = nextOffsetIndex
 			fixedIndex += BytesPerLengthOffset
 		}
-		log.Printf("current offset index: %d, fixed index: %d", currentOffsetIndex, fixedIndex)
 	}
 	return currentOffsetIndex, nil
 }
 
-func (b *structSSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64) (uint64, error) {
+func (b *structSSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64, ctx *DecodeContext) (uint64, error) {
 	if typ.Kind() == reflect.Ptr {
 		if val.IsNil() {
 			return startOffset, nil
 		}
-		return b.Unmarshal(val.Elem(), typ.Elem(), input, startOffset)
+		return b.Unmarshal(val.Elem(), typ.Elem(), input, startOffset, ctx)
+	}
+	if fastPaths && val.CanAddr() {
+		if f := flatStructOf(typ); f != nil {
+			return f.decode(val, typ, input, startOffset, ctx)
+		}
 	}
+	if err := ctx.enter(); err != nil {
+		return 0, err
+	}
+	defer ctx.leave()
 	endOffset := uint64(len(input))
 	currentIndex := startOffset
 	nextIndex := currentIndex
-	numFields := 0
-
-	for i := 0; i < typ.NumField(); i++ {
-		// We skip protobuf related metadata fields.
-		if strings.Contains(typ.Field(i).Name, "XXX_") {
-			continue
-		}
-		numFields++
+	fields := containerFields(typ)
+	numFields := len(fields)
+	fixedSizes, err := prepareFixedFields(val, typ)
+	if err != nil {
+		return 0, err
 	}
 
-	fixedSizes := make(map[int]uint64)
+	// The fixed part holds the fixed-size fields and the offsets of the variable
+	// ones, and must be complete before any field is decoded.
+	fixedPartSize := uint64(0)
 	for i := 0; i < numFields; i++ {
-		fType, err := determineFieldType(typ.Field(i))
-		if err != nil {
-			return 0, err
-		}
-		if isVariableSizeType(fType) {
-			continue
-		}
-		if val.Field(i).Kind() == reflect.Ptr {
-			instantiateConcreteTypeForElement(val.Field(i), fType.Elem())
-		}
-		concreteVal := val.Field(i)
-		sszSizeTags, hasTags, err := parseSSZFieldTags(typ.Field(i))
-		if err != nil {
-			return 0, err
+		if item, ok := fixedSizes[i]; ok {
+			fixedPartSize += item
+		} else {
+			fixedPartSize += BytesPerLengthOffset
 		}
-		if hasTags {
-			concreteType := inferFieldTypeFromSizeTags(typ.Field(i), sszSizeTags)
-			concreteVal = reflect.New(concreteType).Elem()
-			// If the item is a slice, we grow it accordingly based on the size tags.
-			if val.Field(i).Kind() == reflect.Slice {
-				result := growSliceFromSizeTags(val.Field(i), sszSizeTags)
-				val.Field(i).Set(result)
-			}
+	}
+	if startOffset+fixedPartSize > uint64(len(input)) {
+		received := uint64(0)
+		if startOffset < uint64(len(input)) {
+			received = uint64(len(input)) - startOffset
 		}
-		fixedSz := determineFixedSize(concreteVal, fType)
-		fixedSizes[i] = fixedSz
+		return 0, fmt.Errorf(
+			"input for type %v is truncated: expected %d bytes for its fixed part, received %d: %w",
+			typ,
+			fixedPartSize,
+			received,
+			ErrInputTooShort,
+		)
 	}
-
 	offsets := make([]uint64, 0)
 	offsetIndexCounter := startOffset
 	for i := 0; i < numFields; i++ {
 		if item, ok := fixedSizes[i]; ok {
 			offsetIndexCounter += item
 		} else {
-			if offsetIndexCounter+BytesPerLengthOffset > uint64(len(input)) {
-				offsetIndexCounter += BytesPerLengthOffset
-				continue
+			offset, err := readOffset(input, offsetIndexCounter)
+			if err != nil {
+				return 0, err
+			}
+			// The variable-size fields follow the fixed part in order, so the
+			// first offset must point right past it and the others may not go
+			// backwards or beyond the input.
+			previous := startOffset + fixedPartSize
+			if len(offsets) > 0 {
+				previous = offsets[len(offsets)-1]
 			}
-			offsetVal := input[offsetIndexCounter : offsetIndexCounter+BytesPerLengthOffset]
-			offsets = append(offsets, startOffset+uint64(binary.LittleEndian.Uint32(offsetVal)))
+			if (len(offsets) == 0 && offset != fixedPartSize) || startOffset+offset < previous {
+				err := fmt.Errorf("offset %d of field %s of type %v is out of order, expected at least %d: %w", offset, fields[i].Name, typ, previous-startOffset, ErrOffsetsNotIncreasing)
+				return 0, withField(fields[i].name, err)
+			}
+			if startOffset+offset > endOffset {
+				err := fmt.Errorf("offset %d of field %s of type %v is out of bounds, input has length %d: %w", offset, fields[i].Name, typ, endOffset-startOffset, ErrOffsetOutOfBounds)
+				return 0, withField(fields[i].name, err)
+			}
+			offsets = append(offsets, startOffset+offset)
 			offsetIndexCounter += BytesPerLengthOffset
 		}
 	}
 	offsets = append(offsets, endOffset)
 	offsetIndex := uint64(0)
 	for i := 0; i < numFields; i++ {
-		fType, err := determineFieldType(typ.Field(i))
-		if err != nil {
+		if err := ctx.step(1); err != nil {
 			return 0, err
 		}
-		if val.Field(i).Kind() == reflect.Ptr {
-			instantiateConcreteTypeForElement(val.Field(i), fType.Elem())
+		name := fields[i].name
+		fType := fields[i].typ
+		if fields[i].typErr != nil {
+			return 0, withField(name, fields[i].typErr)
 		}
-		factory, err := SSZFactory(val.Field(i), fType)
+		if val.Field(fields[i].Index[0]).Kind() == reflect.Ptr {
+			instantiateField(val.Field(fields[i].Index[0]), fType.Elem())
+		}
+		factory, err := fields[i].factory, fields[i].factoryErr
 		if err != nil {
-			return 0, err
+			return 0, withField(name, err)
 		}
 		if item, ok := fixedSizes[i]; ok {
 			if item == 0 {
 				continue
 			}
 			nextIndex = currentIndex + item
-			if _, err := factory.Unmarshal(val.Field(i), fType, input[currentIndex:nextIndex], 0); err != nil {
-				return 0, err
+			if _, err := factory.Unmarshal(val.Field(fields[i].Index[0]), fType, input[currentIndex:nextIndex], 0, ctx); err != nil {
+				return 0, withField(name, err)
 			}
 			currentIndex = nextIndex
 		} else {
+			// Empty fields at the end of the input are decoded like any other, so
+			// that offsetIndex advances once for every variable-size field.
 			firstOff := offsets[offsetIndex]
-			if firstOff == uint64(len(input)) {
-				currentIndex += BytesPerLengthOffset
-				continue
-			}
 			nextOff := offsets[offsetIndex+1]
 			if nextOff > uint64(len(input)) {
-				return 0, fmt.Errorf("slice bounds out of range [%d:%d]", firstOff, nextOff)
+				return 0, fmt.Errorf("slice bounds out of range [%d:%d]: %w", firstOff, nextOff, ErrOffsetOutOfBounds)
 			}
-			if _, err := factory.Unmarshal(val.Field(i), fType, input[firstOff:nextOff], 0); err != nil {
-				return 0, err
+			// The length of strings is checked before decoding, so an oversized
+			// input never gets allocated.
+			if fType.Kind() == reflect.String {
+				if err := checkStringCapacity(fields[i].StructField, fields[i].capacity, nextOff-firstOff); err != nil {
+					return 0, withField(name, err)
+				}
+			}
+			if _, err := factory.Unmarshal(val.Field(fields[i].Index[0]), fType, input[firstOff:nextOff], 0, ctx); err != nil {
+				return 0, withField(name, err)
+			}
+			if err := checkBitlistCapacity(fields[i].StructField, fields[i].capacity, val.Field(fields[i].Index[0])); err != nil {
+				return 0, withField(name, err)
+			}
+			if err := checkListCapacity(ctx, fields[i].StructField, fields[i].capacity, val.Field(fields[i].Index[0])); err != nil {
+				return 0, withField(name, err)
 			}
 			offsetIndex++
 			currentIndex += BytesPerLengthOffset
 		}
 	}
+	// The last variable-size field extends to the end of the input.
+	if offsetIndex > 0 {
+		return endOffset, nil
+	}
 	return currentIndex, nil
 }
 
+// sszFieldsCache holds the result of sszFields by container type.
+var sszFieldsCache sync.Map
+
+// sszFields returns the fields of the container type typ which are marshaled,
+// decoded and hashed, leaving out the XXX_ metadata fields of protobuf types and
+// the fields tagged ssz:"-". Every code path walking the fields of a container
+// goes through it, so that they all agree on the fields making up its encoding
+// and root.
+func sszFields(typ reflect.Type) []reflect.StructField {
+	if fields, ok := sszFieldsCache.Load(typ); ok {
+		return fields.([]reflect.StructField)
+	}
+	fields := make([]reflect.StructField, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		// We skip protobuf related metadata fields.
+		if strings.HasPrefix(typ.Field(i).Name, "XXX_") || typ.Field(i).Tag.Get("ssz") == "-" {
+			continue
+		}
+		fields = append(fields, typ.Field(i))
+	}
+	sszFieldsCache.Store(typ, fields)
+	return fields
+}
+
+// containerField is a field of a container type along with what is resolved
+// from its declaration, which is the same on every call, so that its tags are
+// parsed and its factory is looked up once per container type.
+type containerField struct {
+	reflect.StructField
+	// name is the name identifying the field, as returned by sszFieldName.
+	name string
+	// typ is the type the field is handled as, as returned by
+	// determineFieldType, or typErr when it cannot be handled.
+	typ    reflect.Type
+	typErr error
+	// variable reports whether values of typ are of variable size.
+	variable bool
+	// factory is the factory of the field, as returned by fieldFactory.
+	factory    SSZAble
+	factoryErr error
+	// sizeTags are the sizes declared by the ssz-size tag of the field.
+	sizeTags    []uint64
+	hasSizeTags bool
+	// capacity is the limit declared by the ssz-max tag of the field.
+	capacity uint64
+}
+
+// containerFieldsCache holds the result of containerFields by container type.
+var containerFieldsCache sync.Map
+
+// containerFields returns the fields of the container type typ, in the order
+// of sszFields, along with their resolved types and factories. Fields resolved
+// concurrently for the same type are equivalent, and every caller gets those
+// stored first.
+func containerFields(typ reflect.Type) []containerField {
+	if fields, ok := containerFieldsCache.Load(typ); ok {
+		return fields.([]containerField)
+	}
+	resolvedLock.RLock()
+	generation := resolvedGeneration
+	resolvedLock.RUnlock()
+	fields := newContainerFields(typ)
+	resolvedLock.RLock()
+	if generation != resolvedGeneration {
+		// An alias, a codec or a union was registered while the fields were
+		// resolved, which may have changed their factories, so they are
+		// resolved again.
+		resolvedLock.RUnlock()
+		return containerFields(typ)
+	}
+	actual, _ := containerFieldsCache.LoadOrStore(typ, fields)
+	resolvedLock.RUnlock()
+	return actual.([]containerField)
+}
+
+func newContainerFields(typ reflect.Type) []containerField {
+	fields := sszFields(typ)
+	resolved := make([]containerField, len(fields))
+	for i, field := range fields {
+		f := &resolved[i]
+		f.StructField = field
+		f.name = sszFieldName(field)
+		f.capacity = determineFieldCapacity(field)
+		f.typ, f.typErr = determineFieldType(field)
+		if f.typErr != nil {
+			continue
+		}
+		f.variable = isVariableSizeType(f.typ)
+		f.sizeTags, f.hasSizeTags, _ = parseSSZFieldTags(field)
+		// Factories depend on the type alone, so that of the zero value is the
+		// factory of every value of the field.
+		f.factory, f.factoryErr = fieldFactory(field, reflect.Zero(f.typ), f.typ)
+	}
+	return resolved
+}
+
+// prepareFixedFields readies the fixed-size fields among the fields of the
+// container val of type typ for decoding, instantiating pointers and growing
+// slices to the lengths of their size tags, and returns their sizes by index in
+// sszFields.
+func prepareFixedFields(val reflect.Value, typ reflect.Type) (map[int]uint64, error) {
+	fixedSizes := make(map[int]uint64)
+	fields := containerFields(typ)
+	for i := range fields {
+		field := &fields[i]
+		fType := field.typ
+		if field.typErr != nil {
+			return nil, withField(field.name, field.typErr)
+		}
+		if field.variable {
+			continue
+		}
+		if bitvector, _ := stripPointers(fType); isBitvectorType(bitvector) {
+			// The size tags of bitvectors count bits rather than elements, so
+			// their values are left for the factory to size.
+			fixedSizes[i] = bitvectorSize(bitvector)
+			continue
+		}
+		fieldVal := val.Field(field.Index[0])
+		if fieldVal.Kind() == reflect.Ptr {
+			instantiateField(fieldVal, fType.Elem())
+		}
+		concreteVal := fieldVal
+		if field.hasSizeTags {
+			// The type inferred from the size tags is the type of the field.
+			concreteVal = reflect.New(fType).Elem()
+			// If the item is a slice, or an array of slices, we grow it accordingly
+			// based on the size tags.
+			if kind := fieldVal.Kind(); kind == reflect.Slice || kind == reflect.Array {
+				result := growSliceFromSizeTags(fieldVal, field.sizeTags)
+				fieldVal.Set(result)
+			}
+		}
+		fixedSizes[i] = determineFixedSize(concreteVal, fType)
+	}
+	return fixedSizes, nil
+}
+
+// checkedFieldNames holds the struct types whose field names have been
+// verified by checkFieldNames.
+var checkedFieldNames sync.Map
+
+// sszFieldName returns the name identifying a field in limit paths and caches,
+// which is its Go name unless overridden with an ssz-name tag.
+func sszFieldName(field reflect.StructField) string {
+	if name := field.Tag.Get("ssz-name"); name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// checkFieldNames verifies no two fields of a struct type resolve to the same
+// name, as their limits and cached roots could not be told apart otherwise.
+func checkFieldNames(typ reflect.Type) error {
+	if _, ok := checkedFieldNames.Load(typ); ok {
+		return nil
+	}
+	seen := make(map[string]string, typ.NumField())
+	for _, field := range sszFields(typ) {
+		name := sszFieldName(field)
+		if strings.Contains(name, ".") {
+			return fmt.Errorf("field %s.%s has invalid ssz-name %q: names cannot contain dots", typ, field.Name, name)
+		}
+		if other, ok := seen[name]; ok {
+			return fmt.Errorf(
+				"fields %s.%s and %s.%s both resolve to the name %q, rename one of them with an ssz-name tag",
+				typ, other, typ, field.Name, name,
+			)
+		}
+		seen[name] = field.Name
+	}
+	checkedFieldNames.Store(typ, true)
+	return nil
+}
+
 func determineFieldType(field reflect.StructField) (reflect.Type, error) {
+	if err := checkContainerFieldTags(field); err != nil {
+		return nil, err
+	}
+	if err := checkScaleTag(field); err != nil {
+		return nil, err
+	}
+	if err := checkFixedWidth(field.Type); err != nil {
+		return nil, err
+	}
+	if bitvector, _ := stripPointers(field.Type); bitvector == bitvectorType {
+		return bitvectorFieldType(field)
+	}
+	if bitlist, _ := stripPointers(field.Type); isBitlistType(bitlist) {
+		// The limit of bitlists is declared by ssz-max, so ssz-size can only mark
+		// them as unbounded.
+		if tag, ok := field.Tag.Lookup("ssz-size"); ok && tag != UnboundedSSZFieldSizeMarker {
+			return nil, fmt.Errorf("bitlist field %s cannot have an ssz-size tag of %q, declare its limit with an ssz-max tag", field.Name, tag)
+		}
+		return field.Type, nil
+	}
 	fieldSizeTags, exists, err := parseSSZFieldTags(field)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not parse ssz struct field tags")
 	}
 	if exists {
+		if err := checkFieldHoldsSizeTags(field, fieldSizeTags); err != nil {
+			return nil, err
+		}
 		// If the field does indeed specify ssz struct tags, we infer the field's type.
 		return inferFieldTypeFromSizeTags(field, fieldSizeTags), nil
 	}
 	return field.Type, nil
 }
 
+// containerFieldTags are the tags describing lists and vectors, which cannot be
+// placed on fields holding containers.
+var containerFieldTags = []string{"ssz-size", "ssz-max", "ssz"}
+
+// checkContainerFieldTags rejects the tags of lists and vectors on a field
+// holding a container, named or anonymous. Such tags do not apply to the fields
+// inside the container, as might be expected, but would be ignored. Sequences
+// such as ssz.List are lists rather than containers, so they may be tagged.
+func checkContainerFieldTags(field reflect.StructField) error {
+	typ := underlyingType(field.Type)
+	if typ.Kind() != reflect.Struct || isSequenceType(typ) {
+		return nil
+	}
+	for _, tag := range containerFieldTags {
+		if _, ok := field.Tag.Lookup(tag); ok {
+			return fmt.Errorf(
+				"field %s of container type %v cannot have an %s tag, place it on the field inside the container it applies to",
+				field.Name,
+				field.Type,
+				tag,
+			)
+		}
+	}
+	return nil
+}
+
+// checkFieldHoldsSizeTags verifies the declared Go type of a field can hold
+// values of the type inferred from its ssz-size tags. Every dimension in the
+// tag must be backed by a slice or an array in the Go type, and an array can
+// only back a dimension of exactly its own length. Slices may back any
+// dimension as they are grown to the tagged length on decoding.
+func checkFieldHoldsSizeTags(field reflect.StructField, sizes []uint64) error {
+	// The inferred type can only be built when the declared type is nested at
+	// least as deeply as the tag, otherwise we describe it by the tag itself.
+	inferred := fmt.Sprintf("ssz-size:%q", field.Tag.Get("ssz-size"))
+	current := field.Type
+	for depth := range sizes {
+		if depth > 0 {
+			current, _ = stripPointers(current)
+		}
+		if current.Kind() != reflect.Slice && current.Kind() != reflect.Array {
+			return fmt.Errorf(
+				"field %s of type %v cannot hold the type inferred from %s: tag has %d dimensions",
+				field.Name,
+				field.Type,
+				inferred,
+				len(sizes),
+			)
+		}
+		current = current.Elem()
+	}
+	inferredType := inferFieldTypeFromSizeTags(field, sizes)
+	current = field.Type
+	for depth, size := range sizes {
+		if depth > 0 {
+			current, _ = stripPointers(current)
+		}
+		if current.Kind() == reflect.Array && uint64(current.Len()) != size {
+			return fmt.Errorf(
+				"field %s of type %v cannot hold tag-inferred type %v: dimension %d is a fixed-size array of length %d",
+				field.Name,
+				field.Type,
+				inferredType,
+				depth,
+				current.Len(),
+			)
+		}
+		current = current.Elem()
+	}
+	return nil
+}
+
+// checkStringCapacity verifies a string field of the given length fits the
+// capacity declared by its ssz-max tag, if any.
+func checkStringCapacity(field reflect.StructField, capacity uint64, length uint64) error {
+	if capacity > 0 && length > capacity {
+		return fmt.Errorf("string field %s has length %d, exceeding its ssz-max of %d", field.Name, length, capacity)
+	}
+	return nil
+}
+
+// checkListCapacity verifies, when decoding strictly, that the list decoded into
+// a field holds no more elements than the capacity declared by its ssz-max tag.
+func checkListCapacity(ctx *DecodeContext, field reflect.StructField, capacity uint64, val reflect.Value) error {
+	if !ctx.isStrict() {
+		return nil
+	}
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Slice || isBitlistType(val.Type()) {
+		return nil
+	}
+	if capacity > 0 && uint64(val.Len()) > capacity {
+		return fmt.Errorf("list field %s has %d elements, exceeding its ssz-max of %d", field.Name, val.Len(), capacity)
+	}
+	return nil
+}
+
 func determineFieldCapacity(field reflect.StructField) uint64 {
 	tag, exists := field.Tag.Lookup("ssz-max")
 	if !exists {
@@ -219,6 +613,26 @@ func determineFieldCapacity(field reflect.StructField) uint64 {
 	return val
 }
 
+// fieldHoldsUints reports whether the uint8 elements of a field are small
+// numbers, as declared by an ssz:"uints" tag, rather than the opaque bytes
+// declared by an ssz:"bytes" tag, which is the default. Both are serialized and
+// hashed alike, so the tag only changes how values are represented.
+func fieldHoldsUints(field reflect.StructField) (bool, error) {
+	var bytesTag, uintsTag bool
+	for _, option := range strings.Split(field.Tag.Get("ssz"), ",") {
+		switch strings.TrimSpace(option) {
+		case "bytes":
+			bytesTag = true
+		case "uints":
+			uintsTag = true
+		}
+	}
+	if bytesTag && uintsTag {
+		return false, fmt.Errorf("field %s cannot hold both bytes and uints", field.Name)
+	}
+	return uintsTag, nil
+}
+
 // TODO: change this.
 func parseSSZFieldTags(field reflect.StructField) ([]uint64, bool, error) {
 	tag, exists := field.Tag.Lookup("ssz-size")
@@ -243,12 +657,19 @@ func parseSSZFieldTags(field reflect.StructField) ([]uint64, bool, error) {
 }
 
 func inferFieldTypeFromSizeTags(field reflect.StructField, sizes []uint64) reflect.Type {
+	// Pointers to the elements of every dimension are kept in the inferred type,
+	// so that it holds the elements of the field as they are.
+	pointers := make([]int, len(sizes))
 	innerElement := field.Type.Elem()
 	for i := 1; i < len(sizes); i++ {
+		innerElement, pointers[i-1] = stripPointers(innerElement)
 		innerElement = innerElement.Elem()
 	}
 	currentType := innerElement
 	for i := len(sizes) - 1; i >= 0; i-- {
+		for j := 0; j < pointers[i]; j++ {
+			currentType = reflect.PtrTo(currentType)
+		}
 		if sizes[i] == 0 {
 			currentType = reflect.SliceOf(currentType)
 		} else {
@@ -257,3 +678,24 @@ func inferFieldTypeFromSizeTags(field reflect.StructField, sizes []uint64) refle
 	}
 	return currentType
 }
+
+// stripPointers returns the type typ points to through any number of pointers,
+// along with that number.
+func stripPointers(typ reflect.Type) (reflect.Type, int) {
+	count := 0
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+		count++
+	}
+	return typ, count
+}
+
+// instantiateField points a pointer field at a new value of type typ to decode
+// into, unless it already points at a sequence, whose value holds the limit or
+// length it is decoded with.
+func instantiateField(field reflect.Value, typ reflect.Type) {
+	if !field.IsNil() && isSequenceType(typ) {
+		return
+	}
+	instantiateConcreteTypeForElement(field, typ)
+}
@@ -2,6 +2,7 @@ package types
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -79,3 +80,273 @@ func TestParseSSZFieldTags_HandlesOverflow(t *testing.T) {
 		t.Errorf("got: %d, wanted %d", result, want)
 	}
 }
+
+func TestDetermineFieldType_RejectsShapeMismatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		input interface{}
+		want  string
+	}{
+		{
+			name: "outer array with unbounded tag",
+			input: struct {
+				Roots [2][32]byte `ssz-size:"?,32"`
+			}{},
+			want: "field Roots of type [2][32]uint8 cannot hold tag-inferred type [][32]uint8: dimension 0 is a fixed-size array of length 2",
+		},
+		{
+			name: "outer array of the wrong length",
+			input: struct {
+				Roots [2][]byte `ssz-size:"4,32"`
+			}{},
+			want: "field Roots of type [2][]uint8 cannot hold tag-inferred type [4][32]uint8: dimension 0 is a fixed-size array of length 2",
+		},
+		{
+			name: "inner array of the wrong length",
+			input: struct {
+				Roots [][16]byte `ssz-size:"?,32"`
+			}{},
+			want: "field Roots of type [][16]uint8 cannot hold tag-inferred type [][32]uint8: dimension 1 is a fixed-size array of length 16",
+		},
+		{
+			name: "more dimensions than the field",
+			input: struct {
+				Data []byte `ssz-size:"4,32"`
+			}{},
+			want: `field Data of type []uint8 cannot hold the type inferred from ssz-size:"4,32": tag has 2 dimensions`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := determineFieldType(reflect.TypeOf(tt.input).Field(0))
+			if err == nil {
+				t.Fatal("Expected error, received nil")
+			}
+			if err.Error() != tt.want {
+				t.Errorf("Expected error %q, received %q", tt.want, err.Error())
+			}
+		})
+	}
+}
+
+func TestDetermineFieldType_AcceptsCompatibleShapes(t *testing.T) {
+	input := struct {
+		SliceOfSlices [][]byte   `ssz-size:"4,32"`
+		SliceOfArrays [][32]byte `ssz-size:"?,32"`
+		ArrayOfSlices [4][]byte  `ssz-size:"4,32"`
+	}{}
+	typ := reflect.TypeOf(input)
+	want := []reflect.Type{
+		reflect.TypeOf([4][32]byte{}),
+		reflect.TypeOf([][32]byte{}),
+		reflect.TypeOf([4][32]byte{}),
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		fType, err := determineFieldType(typ.Field(i))
+		if err != nil {
+			t.Fatalf("Field %s: %v", typ.Field(i).Name, err)
+		}
+		if fType != want[i] {
+			t.Errorf("Field %s: expected inferred type %v, received %v", typ.Field(i).Name, want[i], fType)
+		}
+	}
+}
+
+func TestCheckFieldNames_RejectsDuplicates(t *testing.T) {
+	type checkpoint struct {
+		Epoch uint64
+	}
+	type ambiguous struct {
+		checkpoint
+		Checkpoint uint64 `ssz-name:"checkpoint"`
+	}
+	err := checkFieldNames(reflect.TypeOf(ambiguous{}))
+	if err == nil {
+		t.Fatal("Expected fields resolving to the same name to be rejected")
+	}
+	for _, name := range []string{"ambiguous.checkpoint", "ambiguous.Checkpoint", `"checkpoint"`} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected error to mention %s, received %v", name, err)
+		}
+	}
+	type dotted struct {
+		Epoch uint64 `ssz-name:"Target.Epoch"`
+	}
+	if err := checkFieldNames(reflect.TypeOf(dotted{})); err == nil {
+		t.Error("Expected a name containing a path separator to be rejected")
+	}
+}
+
+func TestCheckFieldNames_RenamedFields(t *testing.T) {
+	type checkpoint struct {
+		Epoch uint64
+	}
+	type renamed struct {
+		checkpoint
+		Checkpoint uint64 `ssz-name:"CheckpointEpoch"`
+	}
+	if err := checkFieldNames(reflect.TypeOf(renamed{})); err != nil {
+		t.Fatal(err)
+	}
+	field, _ := reflect.TypeOf(renamed{}).FieldByName("Checkpoint")
+	if name := sszFieldName(field); name != "CheckpointEpoch" {
+		t.Errorf("Expected name CheckpointEpoch, received %s", name)
+	}
+}
+
+type preForkContainer struct {
+	Slot    uint64
+	Epoch   uint64
+	Root    [32]byte
+	Balance uint64
+}
+
+// postForkContainer adds a fifth field to preForkContainer, which takes its
+// fields into a tree one level deeper.
+type postForkContainer struct {
+	Slot     uint64
+	Epoch    uint64
+	Root     [32]byte
+	Balance  uint64
+	Added    uint64
+	XXX_skip []byte
+}
+
+func TestContainerDepth(t *testing.T) {
+	tests := []struct {
+		typ  reflect.Type
+		want uint64
+	}{
+		{typ: reflect.TypeOf(struct{ A uint64 }{}), want: 0},
+		{typ: reflect.TypeOf(struct{ A, B uint64 }{}), want: 1},
+		{typ: reflect.TypeOf(struct{ A, B, C uint64 }{}), want: 2},
+		{typ: reflect.TypeOf(preForkContainer{}), want: 2},
+		{typ: reflect.TypeOf(&postForkContainer{}), want: 3},
+	}
+	for _, tt := range tests {
+		depth, err := ContainerDepth(tt.typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if depth != tt.want {
+			t.Errorf("Expected %v to have depth %d, received %d", tt.typ, tt.want, depth)
+		}
+	}
+	if _, err := ContainerDepth(reflect.TypeOf([]uint64{})); err == nil {
+		t.Error("Expected a list to be rejected")
+	}
+}
+
+func TestContainerRootObserver(t *testing.T) {
+	val := &postForkContainer{Slot: 1, Epoch: 2, Root: [32]byte{3}, Balance: 4, Added: 5}
+	var observed []ContainerRoot
+	SetContainerRootObserver(func(c ContainerRoot) {
+		observed = append(observed, c)
+	})
+	defer SetContainerRootObserver(nil)
+	factory, err := SSZFactory(reflect.ValueOf(val), reflect.TypeOf(val))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := factory.Root(reflect.ValueOf(val), reflect.TypeOf(val), "", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(observed) != 0 {
+		t.Errorf("Expected no container to be observed while metrics are disabled, received %v", observed)
+	}
+
+	var root [32]byte
+	CountHashes(func() {
+		root, err = factory.Root(reflect.ValueOf(val), reflect.TypeOf(val), "", 0, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(observed) != 1 {
+		t.Fatalf("Expected a single container to be observed, received %v", observed)
+	}
+	c := observed[0]
+	if c.Type != reflect.TypeOf(postForkContainer{}) || c.Chunks != 5 || c.Depth != 3 || c.Root != root {
+		t.Errorf("Expected 5 chunks at depth 3 with root %#x, received %v", root, c)
+	}
+	wantNames := []string{"Slot", "Epoch", "Root", "Balance", "Added"}
+	if !reflect.DeepEqual(c.FieldNames, wantNames) || c.FieldRoots[2] != val.Root || c.FieldRoots[4] != [32]byte{5} {
+		t.Errorf("Expected the roots of fields %v, received %v", wantNames, c)
+	}
+
+	inspected, err := InspectContainerRoot(reflect.ValueOf(val))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(inspected, c) {
+		t.Errorf("Expected inspecting the container to give %v, received %v", c, inspected)
+	}
+}
+
+// taggedContainer declares the shapes of its fields through ssz-size and
+// ssz-max tags, which are parsed once per type rather than once per call.
+type taggedContainer struct {
+	Slot   uint64
+	Roots  [][]byte `ssz-size:"?,32" ssz-max:"16"`
+	Extra  []byte   `ssz-max:"64"`
+	Fixed  [][]byte `ssz-size:"2,32"`
+	Memo   string   `ssz-max:"32"`
+	Hidden []byte   `ssz:"-"`
+}
+
+func newTaggedContainer() taggedContainer {
+	return taggedContainer{
+		Slot:  3,
+		Roots: [][]byte{make([]byte, 32)},
+		Extra: []byte{1, 2},
+		Fixed: [][]byte{make([]byte, 32), make([]byte, 32)},
+		Memo:  "memo",
+	}
+}
+
+func TestStructMarshal_ResolvesFieldTagsOnce(t *testing.T) {
+	val := reflect.ValueOf(newTaggedContainer())
+	typ := val.Type()
+	buf := make([]byte, 512)
+	if _, err := StructFactory.Marshal(val, typ, buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := StructFactory.Marshal(val, typ, buf, 0); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Expected marshaling into a buffer to allocate nothing once the fields of %v are resolved, received %v allocations", typ, allocs)
+	}
+}
+
+func BenchmarkStructMarshal_TaggedFields(b *testing.B) {
+	val := reflect.ValueOf(newTaggedContainer())
+	typ := val.Type()
+	buf := make([]byte, 512)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := StructFactory.Marshal(val, typ, buf, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStructUnmarshal_TaggedFields(b *testing.B) {
+	val := reflect.ValueOf(newTaggedContainer())
+	typ := val.Type()
+	buf := make([]byte, 512)
+	n, err := StructFactory.Marshal(val, typ, buf, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	out := reflect.New(typ).Elem()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		out.Set(reflect.Zero(typ))
+		if _, err := StructFactory.Unmarshal(out, typ, buf[:n], 0, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
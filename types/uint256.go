@@ -0,0 +1,112 @@
+package types
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+// Uint128 is an SSZ uint128, held as its 16 bytes in little-endian order.
+type Uint128 [16]byte
+
+// Uint256 is an SSZ uint256, held as its 32 bytes in little-endian order.
+type Uint256 [32]byte
+
+// BigUint128 wraps a big.Int serialized as an SSZ uint128, where a nil Int
+// stands for zero. Marshaling and hashing fail for negative values and values
+// which do not fit in 128 bits.
+type BigUint128 struct {
+	*big.Int
+}
+
+// BigUint256 wraps a big.Int serialized as an SSZ uint256, where a nil Int
+// stands for zero. Marshaling and hashing fail for negative values and values
+// which do not fit in 256 bits.
+type BigUint256 struct {
+	*big.Int
+}
+
+var (
+	uint128Type    = reflect.TypeOf(Uint128{})
+	uint256Type    = reflect.TypeOf(Uint256{})
+	bigUint128Type = reflect.TypeOf(BigUint128{})
+	bigUint256Type = reflect.TypeOf(BigUint256{})
+)
+
+func init() {
+	// The big.Int wrappers are aliases of the fixed-size types, converted on
+	// every use.
+	if err := RegisterAlias(bigUint128Type, uint128Type, func(v interface{}) (interface{}, error) {
+		return Uint128FromBig(v.(BigUint128).Int)
+	}, func(v interface{}) (interface{}, error) {
+		return BigUint128{Int: v.(Uint128).Big()}, nil
+	}); err != nil {
+		panic(err)
+	}
+	if err := RegisterAlias(bigUint256Type, uint256Type, func(v interface{}) (interface{}, error) {
+		return Uint256FromBig(v.(BigUint256).Int)
+	}, func(v interface{}) (interface{}, error) {
+		return BigUint256{Int: v.(Uint256).Big()}, nil
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// isWideUintType reports whether typ is Uint128 or Uint256, which are basic
+// types packed into chunks like the other uints rather than vectors of bytes.
+func isWideUintType(typ reflect.Type) bool {
+	return typ == uint128Type || typ == uint256Type
+}
+
+// Uint128FromBig returns the Uint128 holding x, or an error when x is negative
+// or does not fit in 128 bits.
+func Uint128FromBig(x *big.Int) (Uint128, error) {
+	var u Uint128
+	err := putBigUint(u[:], x)
+	return u, err
+}
+
+// Uint256FromBig returns the Uint256 holding x, or an error when x is negative
+// or does not fit in 256 bits.
+func Uint256FromBig(x *big.Int) (Uint256, error) {
+	var u Uint256
+	err := putBigUint(u[:], x)
+	return u, err
+}
+
+// Big returns the value of u as a big.Int.
+func (u Uint128) Big() *big.Int {
+	return littleEndianToBig(u[:])
+}
+
+// Big returns the value of u as a big.Int.
+func (u Uint256) Big() *big.Int {
+	return littleEndianToBig(u[:])
+}
+
+// putBigUint writes x into buf in little-endian order, where a nil x stands
+// for zero.
+func putBigUint(buf []byte, x *big.Int) error {
+	if x == nil {
+		return nil
+	}
+	if x.Sign() < 0 {
+		return fmt.Errorf("negative value %v cannot be held by a uint%d", x, len(buf)*8)
+	}
+	if x.BitLen() > len(buf)*8 {
+		return fmt.Errorf("value %v overflows a uint%d", x, len(buf)*8)
+	}
+	bigEndian := x.Bytes()
+	for i, b := range bigEndian {
+		buf[len(bigEndian)-1-i] = b
+	}
+	return nil
+}
+
+func littleEndianToBig(buf []byte) *big.Int {
+	bigEndian := make([]byte, len(buf))
+	for i, b := range buf {
+		bigEndian[len(buf)-1-i] = b
+	}
+	return new(big.Int).SetBytes(bigEndian)
+}
@@ -0,0 +1,45 @@
+package types
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestUint256_Schema(t *testing.T) {
+	type balances struct {
+		Total   Uint256
+		Rewards []Uint128 `ssz-max:"8"`
+		Supply  *BigUint256
+	}
+	schema, err := Describe(reflect.TypeOf(balances{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := schema.String(); s != "container{Total: uint256, Rewards: list[uint128, 8], Supply: uint256}" {
+		t.Errorf("Unexpected schema %s", s)
+	}
+	if size := packedElemSize(reflect.TypeOf(BigUint128{})); size != 16 {
+		t.Errorf("Packed size of BigUint128 is %d, expected 16", size)
+	}
+}
+
+func TestUint256_FromBig(t *testing.T) {
+	x := new(big.Int).SetBytes([]byte{1, 2, 3})
+	u, err := Uint256FromBig(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u != (Uint256{3, 2, 1}) {
+		t.Errorf("Uint256FromBig returned %#x, expected little-endian bytes", u)
+	}
+	if u.Big().Cmp(x) != 0 {
+		t.Errorf("Big returned %v, expected %v", u.Big(), x)
+	}
+	if _, err := Uint128FromBig(new(big.Int).Lsh(big.NewInt(1), 128)); err == nil {
+		t.Error("Expected a value of 129 bits to overflow a uint128")
+	}
+	if _, err := Uint128FromBig(big.NewInt(-2)); err == nil {
+		t.Error("Expected a negative value to be rejected")
+	}
+}
@@ -0,0 +1,280 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// MaxUnionOptions is the number of options a union can have at most, as its
+// selector is a byte whose values from 128 on are reserved.
+const MaxUnionOptions = 128
+
+// ErrUnknownSelector is returned when decoding a union whose selector byte has
+// no option.
+type ErrUnknownSelector struct {
+	Type     reflect.Type
+	Selector uint8
+}
+
+func (e *ErrUnknownSelector) Error() string {
+	return fmt.Sprintf("union %v has no option for selector %d", e.Type, e.Selector)
+}
+
+// unionSSZ serializes and hashes a registered union type, a struct type holding
+// a Selector uint8 field and a Value field of an interface type, which holds a
+// value of the type of the selected option.
+type unionSSZ struct {
+	typ reflect.Type
+	// options are the types of the options by selector, where nil stands for
+	// None, which can only be the first option.
+	options  []reflect.Type
+	selector int
+	value    int
+}
+
+var (
+	unionLock sync.Mutex
+	// unions holds a map[reflect.Type]*unionSSZ which is replaced wholesale on
+	// every registration, allowing lookups on the hot path without taking a lock.
+	unions atomic.Value
+)
+
+func init() {
+	unions.Store(make(map[reflect.Type]*unionSSZ))
+}
+
+// RegisterUnion registers typ as a union of the types of options, selected by
+// their index. The struct type typ must hold a Selector uint8 field and a Value
+// field of an interface type implemented by every option, and no other fields
+// which are serialized. A nil option stands for None, which can only be the
+// first of at least two options.
+func RegisterUnion(typ reflect.Type, options []reflect.Type) error {
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return fmt.Errorf("union type %v must be a struct type", typ)
+	}
+	fields := sszFields(typ)
+	selector, hasSelector := typ.FieldByName("Selector")
+	value, hasValue := typ.FieldByName("Value")
+	if len(fields) != 2 || !hasSelector || !hasValue || len(selector.Index) != 1 || len(value.Index) != 1 {
+		return fmt.Errorf("union type %v must hold exactly a Selector and a Value field", typ)
+	}
+	if selector.Type.Kind() != reflect.Uint8 {
+		return fmt.Errorf("field Selector of union type %v must be a uint8, not %v", typ, selector.Type)
+	}
+	if value.Type.Kind() != reflect.Interface {
+		return fmt.Errorf("field Value of union type %v must be an interface, not %v", typ, value.Type)
+	}
+	if len(options) == 0 || len(options) > MaxUnionOptions {
+		return fmt.Errorf("union type %v has %d options, expected between 1 and %d", typ, len(options), MaxUnionOptions)
+	}
+	for i, option := range options {
+		if option == nil {
+			if i > 0 {
+				return fmt.Errorf("option %d of union type %v is None, which can only be the first option", i, typ)
+			}
+			if len(options) == 1 {
+				return fmt.Errorf("union type %v only has None as option", typ)
+			}
+			continue
+		}
+		if !option.AssignableTo(value.Type) {
+			return fmt.Errorf("option %d of union type %v is %v, which cannot be held by its Value field of type %v", i, typ, option, value.Type)
+		}
+		if err := CheckType(option); err != nil {
+			return fmt.Errorf("option %d of union type %v is not serializable: %v", i, typ, err)
+		}
+	}
+	unionLock.Lock()
+	defer unionLock.Unlock()
+	current := unions.Load().(map[reflect.Type]*unionSSZ)
+	if _, ok := current[typ]; ok {
+		return fmt.Errorf("type %v is already registered as a union", typ)
+	}
+	updated := make(map[reflect.Type]*unionSSZ, len(current)+1)
+	for k, v := range current {
+		updated[k] = v
+	}
+	updated[typ] = &unionSSZ{
+		typ:      typ,
+		options:  append([]reflect.Type{}, options...),
+		selector: selector.Index[0],
+		value:    value.Index[0],
+	}
+	unions.Store(updated)
+	resetResolvedTypes()
+	return nil
+}
+
+func lookupUnion(typ reflect.Type) (*unionSSZ, bool) {
+	// The registry is empty until its init function runs, which can follow
+	// those of other files registering aliases.
+	registered, _ := unions.Load().(map[reflect.Type]*unionSSZ)
+	u, ok := registered[typ]
+	return u, ok
+}
+
+func isUnionType(typ reflect.Type) bool {
+	_, ok := lookupUnion(typ)
+	return ok
+}
+
+// selected returns the selector of the union val, along with the value of the
+// selected option and its type, which are invalid and nil for None. A nil value
+// stands for the zero value of the selected option.
+func (u *unionSSZ) selected(val reflect.Value) (uint8, reflect.Value, reflect.Type, error) {
+	selector := uint8(val.Field(u.selector).Uint())
+	value := val.Field(u.value)
+	if int(selector) >= len(u.options) {
+		return 0, reflect.Value{}, nil, fmt.Errorf("union %v selects option %d, but has %d options", u.typ, selector, len(u.options))
+	}
+	option := u.options[selector]
+	if option == nil {
+		if !value.IsNil() {
+			return 0, reflect.Value{}, nil, fmt.Errorf("union %v selects None, but holds a value of type %v", u.typ, value.Elem().Type())
+		}
+		return selector, reflect.Value{}, nil, nil
+	}
+	// Like nil pointers, a nil value stands for the zero value of its type.
+	if value.IsNil() {
+		return selector, reflect.New(option).Elem(), option, nil
+	}
+	if value.Elem().Type() != option {
+		return 0, reflect.Value{}, nil, fmt.Errorf("union %v selects option %d of type %v, but holds a value of type %v", u.typ, selector, option, value.Elem().Type())
+	}
+	return selector, value.Elem(), option, nil
+}
+
+// size returns the size of the encoding of the union val, which is the
+// selector byte followed by the encoding of the selected value.
+func (u *unionSSZ) size(val reflect.Value) uint64 {
+	_, value, option, err := u.selected(val)
+	if err != nil || option == nil {
+		return 1
+	}
+	if isVariableSizeType(option) {
+		return 1 + determineVariableSize(value, option)
+	}
+	return 1 + determineFixedSize(value, option)
+}
+
+func (u *unionSSZ) Marshal(val reflect.Value, typ reflect.Type, buf []byte, startOffset uint64) (uint64, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return u.Marshal(reflect.New(typ.Elem()).Elem(), typ.Elem(), buf, startOffset)
+		}
+		return u.Marshal(val.Elem(), typ.Elem(), buf, startOffset)
+	}
+	selector, value, option, err := u.selected(val)
+	if err != nil {
+		return 0, err
+	}
+	if startOffset >= uint64(len(buf)) {
+		return 0, fmt.Errorf("buffer of %d bytes is too small for the selector of union %v at offset %d", len(buf), typ, startOffset)
+	}
+	buf[startOffset] = selector
+	if option == nil {
+		return startOffset + 1, nil
+	}
+	factory, err := SSZFactory(value, option)
+	if err != nil {
+		return 0, err
+	}
+	end, err := factory.Marshal(value, option, buf, startOffset+1)
+	return end, withField("Value", err)
+}
+
+// Unmarshal decodes the union from the rest of the input after startOffset,
+// which unions always extend to the end of, into a new value of the type of
+// the option its selector byte selects.
+func (u *unionSSZ) Unmarshal(val reflect.Value, typ reflect.Type, input []byte, startOffset uint64, ctx *DecodeContext) (uint64, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			val.Set(reflect.New(typ.Elem()))
+		}
+		return u.Unmarshal(val.Elem(), typ.Elem(), input, startOffset, ctx)
+	}
+	if startOffset >= uint64(len(input)) {
+		return 0, fmt.Errorf("union %v holds no selector at offset %d, input has length %d: %w", typ, startOffset, len(input), ErrInputTooShort)
+	}
+	selector := input[startOffset]
+	if int(selector) >= len(u.options) {
+		return 0, &ErrUnknownSelector{Type: typ, Selector: selector}
+	}
+	option := u.options[selector]
+	if option == nil {
+		if startOffset+1 != uint64(len(input)) {
+			return 0, fmt.Errorf("union %v selects None, but is followed by %d bytes: %w", typ, uint64(len(input))-startOffset-1, ErrSizeMismatch)
+		}
+		val.Field(u.selector).SetUint(uint64(selector))
+		val.Field(u.value).Set(reflect.Zero(val.Field(u.value).Type()))
+		return startOffset + 1, nil
+	}
+	value := reflect.New(option).Elem()
+	if option.Kind() == reflect.Ptr {
+		instantiateField(value, option.Elem())
+	}
+	factory, err := SSZFactory(value, option)
+	if err != nil {
+		return 0, err
+	}
+	end, err := factory.Unmarshal(value, option, input, startOffset+1, ctx)
+	if err != nil {
+		return 0, withField("Value", err)
+	}
+	if end != uint64(len(input)) {
+		return 0, fmt.Errorf("option %d of union %v ends at %d, input has length %d: %w", selector, typ, end, len(input), ErrSizeMismatch)
+	}
+	val.Field(u.selector).SetUint(uint64(selector))
+	val.Field(u.value).Set(value)
+	return end, nil
+}
+
+// Root mixes the selector into the root of the selected value, which is zero
+// for None.
+func (u *unionSSZ) Root(val reflect.Value, typ reflect.Type, fieldName string, maxCapacity uint64, ctx *HashContext) ([32]byte, error) {
+	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return u.Root(reflect.New(typ.Elem()).Elem(), typ.Elem(), fieldName, maxCapacity, ctx)
+		}
+		return u.Root(val.Elem(), typ.Elem(), fieldName, maxCapacity, ctx)
+	}
+	selector, value, option, err := u.selected(val)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	var root [32]byte
+	if option != nil {
+		factory, err := SSZFactory(value, option)
+		if err != nil {
+			return [32]byte{}, err
+		}
+		if root, err = factory.Root(value, option, "", 0, ctx); err != nil {
+			return [32]byte{}, withField("Value", err)
+		}
+	}
+	return ctx.hasher().mixInLength(root, []byte{selector}), nil
+}
+
+// maxSize returns the size of the longest encoding of the union, which is that
+// of its largest option following the selector byte.
+func (u *unionSSZ) maxSize(visiting map[reflect.Type]bool) (uint64, error) {
+	size := uint64(0)
+	for i, option := range u.options {
+		if option == nil {
+			continue
+		}
+		optionSize, err := maxSize(option, 0, visiting)
+		if err != nil {
+			return 0, fmt.Errorf("option %d of union %v: %v", i, u.typ, err)
+		}
+		if optionSize > size {
+			size = optionSize
+		}
+	}
+	if size+1 < size {
+		return 0, fmt.Errorf("maximum size of %v overflows uint64", u.typ)
+	}
+	return size + 1, nil
+}
@@ -0,0 +1,353 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// View gives access to the fields and elements of an encoded value without
+// decoding it. Navigating a view only resolves the offsets locating the parts
+// it is asked for, and bytes are only copied out when Value decodes a part.
+// Views over the same part of a value, such as copies and the views returned
+// again by Field and Index, share the layout resolved for it, so that the
+// offsets of every container and list are validated once, the first time they
+// are touched, after which their fields and elements are reached in constant
+// time. Views are safe for concurrent use.
+type View struct {
+	input []byte
+	// declared is the type of the values decoded from the view, and typ the one
+	// describing its encoding, which differs for bitvector fields and fields
+	// whose lengths are declared by size tags.
+	declared reflect.Type
+	typ      reflect.Type
+	// field is the container field the view is over, if any, whose tags give
+	// the limit of lists and how values are decoded.
+	field  *reflect.StructField
+	layout *viewLayout
+}
+
+// viewLayout holds the bounds of the fields or elements of the value of a view,
+// resolved when first needed.
+type viewLayout struct {
+	once sync.Once
+	err  error
+	// fields and fieldTypes are the fields of containers and the types of their
+	// encodings.
+	fields     []reflect.StructField
+	fieldTypes []reflect.Type
+	// ranges holds the bounds of every field or variable-size element, while
+	// the elements of a fixed size elemSize are located from their positions.
+	ranges   [][2]uint64
+	elemSize uint64
+	length   uint64
+	isList   bool
+	isBits   bool
+	// children holds the layouts of fields and variable-size elements by their
+	// index, so that views over them share the layout resolved for them.
+	children sync.Map
+}
+
+// child returns the layout of the field or variable-size element at index i.
+func (l *viewLayout) child(i uint64) *viewLayout {
+	if c, ok := l.children.Load(i); ok {
+		return c.(*viewLayout)
+	}
+	c, _ := l.children.LoadOrStore(i, &viewLayout{})
+	return c.(*viewLayout)
+}
+
+// NewView returns a view over input, the encoding of a value of type typ. The
+// size of the encodings of fixed-size types is checked right away, while the
+// offsets of variable-size ones are checked as the view is navigated.
+func NewView(input []byte, typ reflect.Type) (View, error) {
+	if typ == nil {
+		return View{}, fmt.Errorf("cannot view input as untyped nil")
+	}
+	if err := CheckType(typ); err != nil {
+		return View{}, err
+	}
+	if !isVariableSizeType(typ) {
+		if size := determineFixedSize(reflect.New(typ).Elem(), typ); size != uint64(len(input)) {
+			return View{}, fmt.Errorf("encoding of %v has %d bytes, expected %d: %w", typ, len(input), size, ErrSizeMismatch)
+		}
+	}
+	return newView(input, typ, typ, nil), nil
+}
+
+func newView(input []byte, declared reflect.Type, typ reflect.Type, field *reflect.StructField) View {
+	return View{input: input, declared: declared, typ: typ, field: field, layout: &viewLayout{}}
+}
+
+// Type returns the type of the values decoded from the view.
+func (v View) Type() reflect.Type {
+	return v.declared
+}
+
+// Field returns a view over the field of a container named name, by its Go name
+// or its ssz-name tag.
+func (v View) Field(name string) (View, error) {
+	layout, err := v.resolve()
+	if err != nil {
+		return View{}, err
+	}
+	if layout.fields == nil {
+		return View{}, fmt.Errorf("%v is not a container and has no field %s", v.declared, name)
+	}
+	for i := range layout.fields {
+		field := &layout.fields[i]
+		if sszFieldName(*field) == name {
+			r := layout.ranges[i]
+			return View{input: v.input[r[0]:r[1]], declared: field.Type, typ: layout.fieldTypes[i], field: field, layout: layout.child(uint64(i))}, nil
+		}
+	}
+	return View{}, fmt.Errorf("container %v has no field %s", v.declared, name)
+}
+
+// Len returns the number of elements of a list or vector.
+func (v View) Len() (uint64, error) {
+	layout, err := v.resolve()
+	if err != nil {
+		return 0, err
+	}
+	if !layout.isList {
+		return 0, fmt.Errorf("%v is not a list or vector", v.declared)
+	}
+	return layout.length, nil
+}
+
+// Index returns a view over the element at position i of a list or vector.
+// The bits of bitlists and bitvectors are not addressable on their own, so
+// those are decoded whole with Value.
+func (v View) Index(i uint64) (View, error) {
+	layout, err := v.resolve()
+	if err != nil {
+		return View{}, err
+	}
+	if !layout.isList {
+		return View{}, fmt.Errorf("%v is not a list or vector", v.declared)
+	}
+	if layout.isBits {
+		return View{}, fmt.Errorf("bits of %v are not addressable, decode it with Value", v.declared)
+	}
+	if i >= layout.length {
+		return View{}, fmt.Errorf("element %d is out of range of %v, which has %d elements", i, v.declared, layout.length)
+	}
+	elemTyp := reflect.TypeOf(uint8(0))
+	if typ := underlyingType(v.typ); typ.Kind() != reflect.String {
+		elemTyp = typ.Elem()
+	}
+	if layout.elemSize > 0 {
+		start := i * layout.elemSize
+		return newView(v.input[start:start+layout.elemSize], elemTyp, elemTyp, nil), nil
+	}
+	r := layout.ranges[i]
+	return View{input: v.input[r[0]:r[1]], declared: elemTyp, typ: elemTyp, layout: layout.child(i)}, nil
+}
+
+// Value decodes the value of the view into out, which must be a pointer to a
+// value of the type given by Type.
+func (v View) Value(out interface{}) error {
+	if v.layout == nil {
+		return fmt.Errorf("view was not created by NewView")
+	}
+	rval := reflect.ValueOf(out)
+	if out == nil || rval.Kind() != reflect.Ptr || rval.IsNil() {
+		return fmt.Errorf("can only decode a view into a non-nil pointer, not %T", out)
+	}
+	val := rval.Elem()
+	if val.Type() != v.declared {
+		return fmt.Errorf("cannot decode a view of %v into %v", v.declared, val.Type())
+	}
+	if val.Kind() == reflect.Ptr {
+		instantiateField(val, v.typ.Elem())
+	}
+	var factory SSZAble
+	var err error
+	if v.field != nil {
+		if v.typ.Kind() == reflect.String {
+			if err := checkStringCapacity(*v.field, determineFieldCapacity(*v.field), uint64(len(v.input))); err != nil {
+				return err
+			}
+		}
+		factory, err = fieldFactory(*v.field, val, v.typ)
+	} else {
+		factory, err = SSZFactory(val, v.typ)
+	}
+	if err != nil {
+		return err
+	}
+	end, err := factory.Unmarshal(val, v.typ, v.input, 0, nil)
+	if err != nil {
+		return err
+	}
+	if end != uint64(len(v.input)) {
+		return fmt.Errorf("decoding %v consumed %d of its %d bytes: %w", v.declared, end, len(v.input), ErrSizeMismatch)
+	}
+	if v.field != nil {
+		return checkBitlistCapacity(*v.field, determineFieldCapacity(*v.field), val)
+	}
+	return nil
+}
+
+// resolve returns the layout of the value of the view, validating its offsets
+// the first time it is called.
+func (v View) resolve() (*viewLayout, error) {
+	if v.layout == nil {
+		return nil, fmt.Errorf("view was not created by NewView")
+	}
+	v.layout.once.Do(func() {
+		v.layout.err = v.layout.resolve(v.input, v.typ, v.field)
+	})
+	return v.layout, v.layout.err
+}
+
+func (l *viewLayout) resolve(input []byte, typ reflect.Type, field *reflect.StructField) error {
+	typ = underlyingType(typ)
+	if isUnionType(typ) || isSequenceType(typ) || isCodecType(typ) {
+		return fmt.Errorf("the encoding of %v can only be decoded whole", typ)
+	}
+	capacity := uint64(0)
+	if field != nil {
+		capacity = determineFieldCapacity(*field)
+	}
+	kind := typ.Kind()
+	switch {
+	case kind == reflect.Struct:
+		return l.resolveContainer(input, typ)
+	case isBitlistType(typ):
+		n, ok := bitlistLen(input)
+		if !ok {
+			return fmt.Errorf("bitlist %v has no delimiting bit", typ)
+		}
+		l.isList, l.isBits, l.length = true, true, n
+	case isBitvectorType(typ):
+		l.isList, l.isBits, l.length = true, true, uint64(typ.Len())
+	case kind == reflect.String:
+		l.isList, l.elemSize, l.length = true, 1, uint64(len(input))
+	case kind == reflect.Slice || kind == reflect.Array:
+		if err := l.resolveElements(input, typ); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%v has no fields or elements", typ)
+	}
+	if capacity > 0 && l.length > capacity {
+		return fmt.Errorf("%v has %d elements, exceeding its ssz-max of %d", typ, l.length, capacity)
+	}
+	return nil
+}
+
+// resolveContainer locates the fields of a container, checking that the offsets
+// of the variable-size ones follow its fixed part in order within the input.
+func (l *viewLayout) resolveContainer(input []byte, typ reflect.Type) error {
+	fields := sszFields(typ)
+	fieldTypes := make([]reflect.Type, len(fields))
+	for i, field := range fields {
+		fType, err := determineFieldType(field)
+		if err != nil {
+			return withField(sszFieldName(field), err)
+		}
+		fieldTypes[i] = fType
+	}
+	// The sizes of fixed-size fields only depend on their types.
+	fixedSizes, err := prepareFixedFields(reflect.New(typ).Elem(), typ)
+	if err != nil {
+		return err
+	}
+	fixedPartSize := uint64(0)
+	for i := range fields {
+		if item, ok := fixedSizes[i]; ok {
+			fixedPartSize += item
+		} else {
+			fixedPartSize += BytesPerLengthOffset
+		}
+	}
+	if fixedPartSize > uint64(len(input)) {
+		return fmt.Errorf("input for type %v is truncated: expected %d bytes for its fixed part, received %d: %w", typ, fixedPartSize, len(input), ErrInputTooShort)
+	}
+	ranges := make([][2]uint64, len(fields))
+	position := uint64(0)
+	// last is the index of the last variable-size field, which extends to the
+	// end of the input.
+	last := -1
+	for i := range fields {
+		if item, ok := fixedSizes[i]; ok {
+			ranges[i] = [2]uint64{position, position + item}
+			position += item
+			continue
+		}
+		offset, err := readOffset(input, position)
+		if err != nil {
+			return err
+		}
+		if (last < 0 && offset != fixedPartSize) || (last >= 0 && offset < ranges[last][0]) {
+			err := fmt.Errorf("offset %d of field %s of type %v is out of order: %w", offset, fields[i].Name, typ, ErrOffsetsNotIncreasing)
+			return withField(sszFieldName(fields[i]), err)
+		}
+		if offset > uint64(len(input)) {
+			err := fmt.Errorf("offset %d of field %s of type %v is out of bounds, input has length %d: %w", offset, fields[i].Name, typ, len(input), ErrOffsetOutOfBounds)
+			return withField(sszFieldName(fields[i]), err)
+		}
+		if last >= 0 {
+			ranges[last][1] = offset
+		}
+		ranges[i][0] = offset
+		last = i
+		position += BytesPerLengthOffset
+	}
+	if last >= 0 {
+		ranges[last][1] = uint64(len(input))
+	} else if fixedPartSize != uint64(len(input)) {
+		return fmt.Errorf("encoding of %v has %d bytes, expected %d: %w", typ, len(input), fixedPartSize, ErrSizeMismatch)
+	}
+	l.fields, l.fieldTypes, l.ranges = fields, fieldTypes, ranges
+	return nil
+}
+
+// resolveElements locates the elements of a list or vector, from their size
+// when fixed and from the offsets preceding them otherwise.
+func (l *viewLayout) resolveElements(input []byte, typ reflect.Type) error {
+	l.isList = true
+	elemTyp := typ.Elem()
+	if !isVariableSizeType(elemTyp) {
+		l.elemSize = determineFixedSize(reflect.New(elemTyp).Elem(), elemTyp)
+		if l.elemSize == 0 {
+			return fmt.Errorf("elements of %v serialize to zero bytes and cannot be located", typ)
+		}
+		if uint64(len(input))%l.elemSize != 0 {
+			return fmt.Errorf("encoding of %v has %d bytes, which is not a multiple of the %d of its elements: %w", typ, len(input), l.elemSize, ErrSizeMismatch)
+		}
+		l.length = uint64(len(input)) / l.elemSize
+	} else if len(input) > 0 {
+		first, err := readOffset(input, 0)
+		if err != nil {
+			return err
+		}
+		if first == 0 || first%BytesPerLengthOffset != 0 || first > uint64(len(input)) {
+			return fmt.Errorf("first offset %d of %v is not a multiple of %d within the input of length %d: %w", first, typ, BytesPerLengthOffset, len(input), ErrOffsetOutOfBounds)
+		}
+		l.length = first / BytesPerLengthOffset
+		l.ranges = make([][2]uint64, l.length)
+		for i := uint64(0); i < l.length; i++ {
+			offset, err := readOffset(input, i*BytesPerLengthOffset)
+			if err != nil {
+				return err
+			}
+			if i > 0 && offset < l.ranges[i-1][0] {
+				return fmt.Errorf("offset %d of element %d of %v is out of order: %w", offset, i, typ, ErrOffsetsNotIncreasing)
+			}
+			if offset > uint64(len(input)) {
+				return fmt.Errorf("offset %d of element %d of %v is out of bounds, input has length %d: %w", offset, i, typ, len(input), ErrOffsetOutOfBounds)
+			}
+			if i > 0 {
+				l.ranges[i-1][1] = offset
+			}
+			l.ranges[i] = [2]uint64{offset, uint64(len(input))}
+		}
+	}
+	if typ.Kind() == reflect.Array && l.length != uint64(typ.Len()) {
+		return fmt.Errorf("vector %v has %d elements, expected %d: %w", typ, l.length, typ.Len(), ErrSizeMismatch)
+	}
+	return nil
+}
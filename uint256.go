@@ -0,0 +1,42 @@
+package ssz
+
+import (
+	"math/big"
+
+	"github.com/524119574/go-ssz/types"
+)
+
+// Uint128 is an SSZ uint128, held as its 16 bytes in little-endian order. It is
+// serialized as those bytes and packed into chunks for hashing like the other
+// uints, so that two of them share a chunk in lists and vectors.
+type Uint128 = types.Uint128
+
+// Uint256 is an SSZ uint256, held as its 32 bytes in little-endian order.
+type Uint256 = types.Uint256
+
+// BigUint128 wraps a big.Int serialized as an SSZ uint128:
+//
+//  type Deposit struct {
+//      Amount ssz.BigUint128
+//  }
+//
+//  enc, err := Marshal(&Deposit{Amount: ssz.BigUint128{Int: big.NewInt(32)}})
+//
+// A nil Int stands for zero, and unmarshaling sets a new one. Marshaling and
+// hashing fail for negative values and values which do not fit in 128 bits.
+type BigUint128 = types.BigUint128
+
+// BigUint256 wraps a big.Int serialized as an SSZ uint256, like BigUint128.
+type BigUint256 = types.BigUint256
+
+// Uint128FromBig returns the Uint128 holding x, or an error when x is negative
+// or does not fit in 128 bits.
+func Uint128FromBig(x *big.Int) (Uint128, error) {
+	return types.Uint128FromBig(x)
+}
+
+// Uint256FromBig returns the Uint256 holding x, or an error when x is negative
+// or does not fit in 256 bits.
+func Uint256FromBig(x *big.Int) (Uint256, error) {
+	return types.Uint256FromBig(x)
+}
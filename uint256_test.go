@@ -0,0 +1,151 @@
+package ssz
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type uint256Balances struct {
+	Total    Uint256
+	Rewards  []Uint128 `ssz-max:"8"`
+	Deposits [3]Uint128
+	Supply   BigUint256
+}
+
+func TestUint256_RoundTrip(t *testing.T) {
+	total, err := Uint256FromBig(new(big.Int).Lsh(big.NewInt(1), 255))
+	if err != nil {
+		t.Fatal(err)
+	}
+	balances := &uint256Balances{
+		Total:    total,
+		Rewards:  []Uint128{{1}, {2, 1}},
+		Deposits: [3]Uint128{{3}},
+		Supply:   BigUint256{Int: big.NewInt(0x0102)},
+	}
+	enc, err := Marshal(balances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make([]byte, 32+4+3*16+32+2*16)
+	want[31] = 0x80
+	want[32] = 32 + 4 + 3*16 + 32
+	want[36] = 3
+	want[84], want[85] = 0x02, 0x01
+	want[116] = 1
+	want[132], want[133] = 2, 1
+	if !bytes.Equal(enc, want) {
+		t.Fatalf("Marshaled %#x, expected %#x", enc, want)
+	}
+	var decoded uint256Balances
+	if err := Unmarshal(enc, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, balances) {
+		t.Errorf("Unmarshaled %+v, expected %+v", decoded, balances)
+	}
+	if decoded.Total.Big().Cmp(new(big.Int).Lsh(big.NewInt(1), 255)) != 0 {
+		t.Errorf("Unexpected total %v", decoded.Total.Big())
+	}
+	size, err := SizeSSZ(balances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != uint64(len(want)) {
+		t.Errorf("SizeSSZ returned %d, expected %d", size, len(want))
+	}
+}
+
+func TestUint256_HashTreeRoot(t *testing.T) {
+	// Uint128 values are packed two to a chunk, just like pairs of uint64
+	// values holding their halves.
+	deposits, err := HashTreeRoot([3]Uint128{{5}, {}, {0, 0, 0, 0, 0, 0, 0, 0, 6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	halves, err := HashTreeRoot([6]uint64{5, 0, 0, 0, 0, 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deposits != halves {
+		t.Errorf("Root of packed uint128s is %#x, expected %#x", deposits, halves)
+	}
+	type rewards struct {
+		Rewards []Uint128 `ssz-max:"8"`
+	}
+	root, err := HashTreeRoot(&rewards{Rewards: []Uint128{{1}, {2}, {3}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The limit of 8 values is 4 chunks, the first two of which hold them.
+	hash := func(a, b [32]byte) [32]byte {
+		return sha256.Sum256(append(a[:], b[:]...))
+	}
+	first, second := [32]byte{0: 1, 16: 2}, [32]byte{0: 3}
+	data := hash(hash(first, second), hash([32]byte{}, [32]byte{}))
+	want := hash(data, [32]byte{0: 3})
+	if root != want {
+		t.Errorf("Root of a list of uint128s is %#x, expected %#x", root, want)
+	}
+	// A single value is a chunk of its own, zero-padded for uint128.
+	value, err := HashTreeRoot(Uint128{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != [32]byte{1, 2} {
+		t.Errorf("Root of uint128 is %#x, expected its padded bytes", value)
+	}
+	big256, err := HashTreeRoot(BigUint256{Int: big.NewInt(0x0102)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if big256 != [32]byte{2, 1} {
+		t.Errorf("Root of big uint256 is %#x, expected its bytes", big256)
+	}
+}
+
+func TestUint256_BigOverflow(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		err   string
+	}{
+		{value: &BigUint128{Int: new(big.Int).Lsh(big.NewInt(1), 128)}, err: "overflows a uint128"},
+		{value: &BigUint256{Int: new(big.Int).Lsh(big.NewInt(1), 256)}, err: "overflows a uint256"},
+		{value: &BigUint256{Int: big.NewInt(-1)}, err: "negative value -1"},
+	}
+	for _, tt := range tests {
+		if _, err := Marshal(tt.value); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Expected marshaling %v to fail with %q, received %v", tt.value, tt.err, err)
+		}
+		if _, err := HashTreeRoot(tt.value); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Expected hashing %v to fail with %q, received %v", tt.value, tt.err, err)
+		}
+	}
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+	enc, err := Marshal(&BigUint128{Int: max})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, bytes.Repeat([]byte{0xff}, 16)) {
+		t.Errorf("Marshaled %#x, expected 16 bytes of 0xff", enc)
+	}
+	var decoded BigUint128
+	if err := Unmarshal(enc, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Cmp(max) != 0 {
+		t.Errorf("Unmarshaled %v, expected %v", decoded, max)
+	}
+	// A nil Int stands for zero.
+	enc, err = Marshal(&BigUint256{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, make([]byte, 32)) {
+		t.Errorf("Marshaled %#x, expected 32 zero bytes", enc)
+	}
+}
@@ -0,0 +1,46 @@
+package ssz
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+	"github.com/524119574/go-ssz/types"
+)
+
+// ErrUnknownSelector is the error unmarshaling fails with when the selector of a
+// union has no option, which is matched with errors.As.
+type ErrUnknownSelector = types.ErrUnknownSelector
+
+// RegisterUnion registers the struct type of prototype as an SSZ union of the
+// types of options, which are selected by their index. The struct holds the
+// selector in a Selector uint8 field, and the value of the selected option in a
+// Value field of an interface type implemented by every option:
+//
+//  type Payload struct {
+//      Selector uint8
+//      Value    interface{}
+//  }
+//
+//  err := RegisterUnion(Payload{}, nil, Transfer{}, &Deposit{})
+//
+// A nil option stands for None, which can only be the first option. Unions are
+// serialized as their selector byte followed by the encoding of their value,
+// and unmarshaling sets Value to a new value of the type of the selected
+// option. Their root mixes the selector into the root of their value.
+// Registering the same type more than once returns an error.
+func RegisterUnion(prototype interface{}, options ...interface{}) error {
+	if prototype == nil {
+		return errors.New("untyped-value nil cannot be registered as a union")
+	}
+	optionTypes := make([]reflect.Type, len(options))
+	for i, option := range options {
+		if option != nil {
+			optionTypes[i] = reflect.TypeOf(option)
+		}
+	}
+	if err := types.RegisterUnion(reflect.TypeOf(prototype), optionTypes); err != nil {
+		return err
+	}
+	resetCodecs()
+	return nil
+}
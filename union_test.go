@@ -0,0 +1,264 @@
+package ssz
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/524119574/go-ssz/types"
+)
+
+type unionTransfer struct {
+	Amount uint64
+	To     [4]byte
+}
+
+type unionMemo struct {
+	Text []byte `ssz-max:"32"`
+}
+
+type unionPayload struct {
+	Selector uint8
+	Value    interface{}
+}
+
+type unionEnvelope struct {
+	Nonce   uint64
+	Payload unionPayload
+}
+
+func init() {
+	if err := RegisterUnion(unionPayload{}, nil, uint64(0), unionTransfer{}, &unionMemo{}); err != nil {
+		panic(err)
+	}
+}
+
+func TestUnion_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload unionPayload
+		enc     []byte
+	}{
+		{
+			name:    "None",
+			payload: unionPayload{},
+			enc:     []byte{0},
+		},
+		{
+			name:    "Basic",
+			payload: unionPayload{Selector: 1, Value: uint64(7)},
+			enc:     []byte{1, 7, 0, 0, 0, 0, 0, 0, 0},
+		},
+		{
+			name:    "FixedContainer",
+			payload: unionPayload{Selector: 2, Value: unionTransfer{Amount: 3, To: [4]byte{1, 2, 3, 4}}},
+			enc:     []byte{2, 3, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4},
+		},
+		{
+			name:    "VariableContainer",
+			payload: unionPayload{Selector: 3, Value: &unionMemo{Text: []byte("hi")}},
+			enc:     []byte{3, 4, 0, 0, 0, 'h', 'i'},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envelope := &unionEnvelope{Nonce: 9, Payload: tt.payload}
+			enc, err := Marshal(envelope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := append([]byte{9, 0, 0, 0, 0, 0, 0, 0, 12, 0, 0, 0}, tt.enc...)
+			if !bytes.Equal(enc, want) {
+				t.Fatalf("Marshaled %#x, expected %#x", enc, want)
+			}
+			var decoded unionEnvelope
+			if err := Unmarshal(enc, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(&decoded, envelope) {
+				t.Errorf("Unmarshaled %+v, expected %+v", decoded, envelope)
+			}
+			var streamed bytes.Buffer
+			if err := NewEncoder(&streamed).Encode(envelope); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(streamed.Bytes(), want) {
+				t.Errorf("Encoded %#x, expected %#x", streamed.Bytes(), want)
+			}
+			var fromStream unionEnvelope
+			if err := NewDecoder(bytes.NewReader(want)).Decode(&fromStream); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(&fromStream, envelope) {
+				t.Errorf("Decoded %+v, expected %+v", fromStream, envelope)
+			}
+			size, err := SizeSSZ(envelope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != uint64(len(want)) {
+				t.Errorf("SizeSSZ returned %d, expected %d", size, len(want))
+			}
+		})
+	}
+}
+
+func TestUnion_HashTreeRoot(t *testing.T) {
+	mixInSelector := func(root [32]byte, selector byte) [32]byte {
+		var chunk [32]byte
+		chunk[0] = selector
+		return sha256.Sum256(append(root[:], chunk[:]...))
+	}
+	none, err := HashTreeRoot(unionPayload{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mixInSelector([32]byte{}, 0); none != want {
+		t.Errorf("Root of None is %#x, expected %#x", none, want)
+	}
+	basic, err := HashTreeRoot(unionPayload{Selector: 1, Value: uint64(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var valueRoot [32]byte
+	binary.LittleEndian.PutUint64(valueRoot[:], 7)
+	if want := mixInSelector(valueRoot, 1); basic != want {
+		t.Errorf("Root of uint64 option is %#x, expected %#x", basic, want)
+	}
+	memo := &unionMemo{Text: []byte("hi")}
+	memoRoot, err := HashTreeRoot(memo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := HashTreeRoot(&unionPayload{Selector: 3, Value: memo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mixInSelector(memoRoot, 3); root != want {
+		t.Errorf("Root of container option is %#x, expected %#x", root, want)
+	}
+	// A nil value stands for the zero value of the selected option.
+	zero, err := HashTreeRoot(unionPayload{Selector: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zeroTransfer, err := HashTreeRoot(unionPayload{Selector: 2, Value: unionTransfer{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zero != zeroTransfer {
+		t.Errorf("Root of nil value %#x differs from that of the zero value %#x", zero, zeroTransfer)
+	}
+}
+
+func TestUnion_MalformedInput(t *testing.T) {
+	var payload unionPayload
+	err := Unmarshal([]byte{4, 1, 2}, &payload)
+	var unknown *ErrUnknownSelector
+	if !errors.As(err, &unknown) || unknown.Selector != 4 {
+		t.Errorf("Expected an unknown selector error, received %v", err)
+	}
+	if err := Unmarshal([]byte{0, 1}, &payload); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("Expected None followed by bytes to be rejected, received %v", err)
+	}
+	if err := Unmarshal([]byte{1, 7, 0, 0, 0, 0, 0, 0, 0, 0}, &payload); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("Expected a value followed by bytes to be rejected, received %v", err)
+	}
+	if err := Unmarshal([]byte{1, 7, 0}, &payload); !errors.Is(err, ErrInputTooShort) {
+		t.Errorf("Expected a truncated value to be rejected, received %v", err)
+	}
+	if err := Unmarshal([]byte{}, &payload); err == nil {
+		t.Error("Expected an empty union to be rejected")
+	}
+	enc := []byte{0, 0, 0, 0, 0, 0, 0, 0, 12, 0, 0, 0, 9}
+	var envelope unionEnvelope
+	err = Unmarshal(enc, &envelope)
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Path() != "Payload" || !errors.As(err, &unknown) {
+		t.Errorf("Expected an unknown selector error in field Payload, received %v", err)
+	}
+}
+
+func TestUnion_InvalidValues(t *testing.T) {
+	tests := []struct {
+		payload unionPayload
+		err     string
+	}{
+		{payload: unionPayload{Selector: 0, Value: uint64(1)}, err: "selects None, but holds a value"},
+		{payload: unionPayload{Selector: 1, Value: uint32(1)}, err: "holds a value of type uint32"},
+		{payload: unionPayload{Selector: 3, Value: unionMemo{}}, err: "holds a value of type ssz.unionMemo"},
+		{payload: unionPayload{Selector: 4}, err: "selects option 4, but has 4 options"},
+	}
+	for _, tt := range tests {
+		if _, err := Marshal(tt.payload); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Expected marshaling %+v to fail with %q, received %v", tt.payload, tt.err, err)
+		}
+		if _, err := HashTreeRoot(tt.payload); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Expected hashing %+v to fail with %q, received %v", tt.payload, tt.err, err)
+		}
+	}
+}
+
+func TestRegisterUnion_Errors(t *testing.T) {
+	type noSelector struct {
+		Value interface{}
+	}
+	type byteValue struct {
+		Selector uint8
+		Value    byte
+	}
+	type union struct {
+		Selector uint8
+		Value    interface{}
+	}
+	type stringer interface {
+		String() string
+	}
+	type typedUnion struct {
+		Selector uint8
+		Value    stringer
+	}
+	tests := []struct {
+		prototype interface{}
+		options   []interface{}
+		err       string
+	}{
+		{prototype: noSelector{}, options: []interface{}{uint64(0)}, err: "exactly a Selector and a Value field"},
+		{prototype: byteValue{}, options: []interface{}{uint64(0)}, err: "must be an interface"},
+		{prototype: union{}, options: nil, err: "has 0 options"},
+		{prototype: union{}, options: []interface{}{nil}, err: "only has None"},
+		{prototype: union{}, options: []interface{}{uint64(0), nil}, err: "can only be the first option"},
+		{prototype: union{}, options: []interface{}{int(0)}, err: "not serializable"},
+		{prototype: typedUnion{}, options: []interface{}{uint64(0)}, err: "cannot be held by its Value field"},
+		{prototype: unionPayload{}, options: []interface{}{uint64(0)}, err: "already registered"},
+	}
+	for _, tt := range tests {
+		if err := RegisterUnion(tt.prototype, tt.options...); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Expected registering %T to fail with %q, received %v", tt.prototype, tt.err, err)
+		}
+	}
+}
+
+func TestUnion_Schema(t *testing.T) {
+	schema, err := types.Describe(reflect.TypeOf(unionEnvelope{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "container{Nonce: uint64, Payload: union[None, uint64, container{Amount: uint64, To: vector[uint8, 4]}, container{Text: list[uint8, 32]}]}"
+	if s := schema.String(); s != want {
+		t.Errorf("Schema is %s, expected %s", s, want)
+	}
+	size, err := types.MaxSize(reflect.TypeOf(unionEnvelope{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The nonce, the offset of the payload, its selector and the longest of its
+	// options, which is the memo of up to 32 bytes following its offset.
+	if want := uint64(8 + 4 + 1 + 4 + 32); size != want {
+		t.Errorf("Maximum size is %d, expected %d", size, want)
+	}
+}
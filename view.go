@@ -0,0 +1,25 @@
+package ssz
+
+import (
+	"reflect"
+
+	"github.com/524119574/go-ssz/types"
+)
+
+// View gives access to the fields and elements of an encoded value without
+// decoding it, such as to answer queries over stored states. Offsets are only
+// resolved for the parts of the value navigated to, and bytes are only copied
+// when a part is decoded with Value:
+//
+//	state, err := NewView(enc, reflect.TypeOf(BeaconState{}))
+//	validators, err := state.Field("Validators")
+//	n, err := validators.Len()
+//	validator, err := validators.Index(n - 1)
+//	var last Validator
+//	err = validator.Value(&last)
+type View = types.View
+
+// NewView returns a view over input, the encoding of a value of type typ.
+func NewView(input []byte, typ reflect.Type) (View, error) {
+	return types.NewView(input, typ)
+}
@@ -0,0 +1,273 @@
+package ssz
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type viewValidator struct {
+	Pubkey  [4]byte
+	Balance uint64
+}
+
+type viewAttestation struct {
+	Slot uint64
+	Bits Bitlist `ssz-max:"16"`
+}
+
+type viewState struct {
+	Slot         uint64
+	Validators   []*viewValidator  `ssz-max:"8"`
+	Attestations []viewAttestation `ssz-max:"4"`
+	Name         string            `ssz-max:"8"`
+	Roots        [2][32]byte
+	Balances     []uint64 `ssz-max:"8"`
+}
+
+func viewStateFixture() *viewState {
+	return &viewState{
+		Slot: 5,
+		Validators: []*viewValidator{
+			{Pubkey: [4]byte{1}, Balance: 32},
+			{Pubkey: [4]byte{2}, Balance: 31},
+		},
+		Attestations: []viewAttestation{
+			{Slot: 3, Bits: Bitlist{0x05}},
+			{Slot: 4, Bits: Bitlist{0x1f}},
+			{Slot: 5, Bits: Bitlist{0xff, 0x01}},
+		},
+		Name:     "state",
+		Roots:    [2][32]byte{{1}, {2}},
+		Balances: []uint64{9, 8, 7},
+	}
+}
+
+func TestView(t *testing.T) {
+	state := viewStateFixture()
+	enc, err := Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view, err := NewView(enc, reflect.TypeOf(viewState{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded viewState
+	if err := view.Value(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, state) {
+		t.Errorf("Decoded %+v, expected %+v", decoded, state)
+	}
+
+	attestations, err := view.Field("Attestations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := attestations.Len()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != uint64(len(state.Attestations)) {
+		t.Fatalf("Attestations have length %d, expected %d", n, len(state.Attestations))
+	}
+	for i := uint64(0); i < n; i++ {
+		elem, err := attestations.Index(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bits, err := elem.Field("Bits")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b Bitlist
+		if err := bits.Value(&b); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(b, state.Attestations[i].Bits) {
+			t.Errorf("Bits of attestation %d are %#x, expected %#x", i, b, state.Attestations[i].Bits)
+		}
+		length, err := bits.Len()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if length != state.Attestations[i].Bits.Len() {
+			t.Errorf("Bits of attestation %d have length %d, expected %d", i, length, state.Attestations[i].Bits.Len())
+		}
+	}
+
+	validators, err := view.Field("Validators")
+	if err != nil {
+		t.Fatal(err)
+	}
+	validator, err := validators.Index(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v *viewValidator
+	if err := validator.Value(&v); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, state.Validators[1]) {
+		t.Errorf("Decoded validator %+v, expected %+v", v, state.Validators[1])
+	}
+
+	balances, err := view.Field("Balances")
+	if err != nil {
+		t.Fatal(err)
+	}
+	balance, err := balances.Index(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b uint64
+	if err := balance.Value(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b != 7 {
+		t.Errorf("Decoded balance %d, expected 7", b)
+	}
+
+	roots, err := view.Field("Roots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := roots.Index(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r [32]byte
+	if err := root.Value(&r); err != nil || r != state.Roots[1] {
+		t.Errorf("Decoded root %#x, expected %#x: %v", r, state.Roots[1], err)
+	}
+
+	name, err := view.Field("Name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	if err := name.Value(&s); err != nil || s != state.Name {
+		t.Errorf("Decoded name %q, expected %q: %v", s, state.Name, err)
+	}
+}
+
+func TestView_ValidatesTouchedRegions(t *testing.T) {
+	state := viewStateFixture()
+	enc, err := Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The offset of the attestations follows the slot and the offset of the
+	// validators, and points to the offsets of their elements.
+	attestationsStart := int(enc[12]) | int(enc[13])<<8
+	corrupted := append([]byte{}, enc...)
+	corrupted[attestationsStart+4] = 0xff
+	view, err := NewView(corrupted, reflect.TypeOf(viewState{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Regions which do not hold the corrupted offset remain readable.
+	validators, err := view.Field("Validators")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := validators.Len(); err != nil || n != 2 {
+		t.Errorf("Expected 2 validators, received %d, %v", n, err)
+	}
+	attestations, err := view.Field("Attestations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := attestations.Len(); !errors.Is(err, ErrOffsetOutOfBounds) {
+		t.Errorf("Expected an offset out of bounds, received %v", err)
+	}
+	// The error is resolved once and returned on every access.
+	if _, err := attestations.Index(0); !errors.Is(err, ErrOffsetOutOfBounds) {
+		t.Errorf("Expected an offset out of bounds, received %v", err)
+	}
+
+	truncated, err := NewView(enc[:10], reflect.TypeOf(viewState{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := truncated.Field("Slot"); !errors.Is(err, ErrInputTooShort) {
+		t.Errorf("Expected truncated input to be rejected, received %v", err)
+	}
+}
+
+func TestView_Errors(t *testing.T) {
+	enc, err := Marshal(viewStateFixture())
+	if err != nil {
+		t.Fatal(err)
+	}
+	view, err := NewView(enc, reflect.TypeOf(viewState{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	slot, err := view.Field("Slot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	balances, err := view.Field("Balances")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var slotValue uint32
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "MissingField", err: func() error { _, err := view.Field("Missing"); return err }(), want: "has no field Missing"},
+		{name: "FieldOfBasic", err: func() error { _, err := slot.Field("Slot"); return err }(), want: "no fields or elements"},
+		{name: "LenOfContainer", err: func() error { _, err := view.Len(); return err }(), want: "not a list"},
+		{name: "OutOfRange", err: func() error { _, err := balances.Index(3); return err }(), want: "out of range"},
+		{name: "WrongType", err: slot.Value(&slotValue), want: "into uint32"},
+		{name: "NotPointer", err: slot.Value(uint64(0)), want: "non-nil pointer"},
+		{name: "ZeroView", err: View{}.Value(&slotValue), want: "not created by NewView"},
+		{name: "FixedSize", err: func() error { _, err := NewView(enc[:4], reflect.TypeOf(viewValidator{})); return err }(), want: "expected 12"},
+	}
+	for _, tt := range tests {
+		if tt.err == nil || !strings.Contains(tt.err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, received %v", tt.name, tt.want, tt.err)
+		}
+	}
+}
+
+func TestView_Concurrent(t *testing.T) {
+	state := viewStateFixture()
+	enc, err := Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view, err := NewView(enc, reflect.TypeOf(viewState{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestations, err := view.Field("Attestations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The layout of the attestations is resolved by whichever goroutine gets
+	// to it first, and shared with the others.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			elem, err := attestations.Index(uint64(i % 3))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			var a viewAttestation
+			if err := elem.Value(&a); err != nil || !reflect.DeepEqual(a, state.Attestations[i%3]) {
+				t.Errorf("Decoded %+v, expected %+v: %v", a, state.Attestations[i%3], err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}